@@ -141,7 +141,7 @@ func (a *App) RunLayerTests(selectedLayers []int) ([]common.TestResult, error) {
 			Message:     "Comprehensive security assessment results",
 			StartTime:   time.Now().Add(-1 * time.Second),
 			EndTime:     time.Now(),
-			Diagnostics: diagnostics,
+			Diagnostics: common.DiagnosticsPayload{Generic: diagnostics},
 		})
 	}
 
@@ -0,0 +1,219 @@
+// Package analysis looks for regressions across stored test runs
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"ghostshell/app/layers/common"
+	"ghostshell/app/layers/history"
+)
+
+// Regression types
+const (
+	RegressionStatusDegradation = "status_degradation"
+	RegressionLatencyTrend      = "latency_trend"
+)
+
+// minTrendSamples is the fewest latency samples a trend is fitted to
+const minTrendSamples = 3
+
+// Regression is a test that got worse over the analysed runs
+type Regression struct {
+	TestName       string            `json:"test_name"`
+	Layer          int               `json:"layer"`
+	RegressionType string            `json:"regression_type"`
+	Severity       common.TestStatus `json:"severity"`
+	Details        string            `json:"details"`
+}
+
+// testKey identifies the same test across runs
+type testKey struct {
+	layer int
+	name  string
+}
+
+// observation is one run's outcome of a test
+type observation struct {
+	time    time.Time
+	status  common.TestStatus
+	latency time.Duration
+}
+
+// DetectRegressions analyses the last windowSize runs in store. For every
+// test, including sub-tests, it reports:
+//
+//   - status_degradation when the test went from Passed to Failed between
+//     consecutive runs; it is a failure while the test is still failing
+//   - latency_trend when a least-squares fit of its latency over time is
+//     rising and has crossed the latency warning or error threshold
+//
+// Regressions are ordered by layer and test name.
+func DetectRegressions(store history.Store, windowSize int, thresholds common.AlertThresholds) ([]Regression, error) {
+	if windowSize < 2 {
+		return nil, fmt.Errorf("window size must be at least 2, got %d", windowSize)
+	}
+
+	runs, err := store.List(windowSize, 0, history.OrderDesc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history: %w", err)
+	}
+
+	// Oldest run first
+	series := make(map[testKey][]observation)
+	for i := len(runs) - 1; i >= 0; i-- {
+		results, err := store.Get(runs[i].RunID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load run %s: %w", runs[i].RunID, err)
+		}
+		collect(series, results, runs[i].Timestamp)
+	}
+
+	keys := make([]testKey, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].layer != keys[j].layer {
+			return keys[i].layer < keys[j].layer
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	regressions := []Regression{}
+	for _, key := range keys {
+		obs := series[key]
+		if r, ok := statusDegradation(key, obs); ok {
+			regressions = append(regressions, r)
+		}
+		if r, ok := latencyTrend(key, obs, thresholds); ok {
+			regressions = append(regressions, r)
+		}
+	}
+	return regressions, nil
+}
+
+// collect adds the outcome of every test in results, recursing into
+// sub-results, to series. Only the first result with a given layer and name
+// in a run is used.
+func collect(series map[testKey][]observation, results []common.TestResult, timestamp time.Time) {
+	seen := make(map[testKey]bool)
+	var walk func([]common.TestResult)
+	walk = func(results []common.TestResult) {
+		for _, result := range results {
+			key := testKey{result.Layer, result.Name}
+			if !seen[key] {
+				seen[key] = true
+				series[key] = append(series[key], observation{
+					time:    timestamp,
+					status:  result.Status,
+					latency: result.Metrics.Latency,
+				})
+			}
+			walk(result.SubResults)
+		}
+	}
+	walk(results)
+}
+
+// statusDegradation reports the share of a test's status transitions that
+// went from Passed to Failed
+func statusDegradation(key testKey, obs []observation) (Regression, bool) {
+	if len(obs) < 2 {
+		return Regression{}, false
+	}
+
+	degradations := 0
+	for i := 1; i < len(obs); i++ {
+		if obs[i-1].status == common.StatusPassed && obs[i].status == common.StatusFailed {
+			degradations++
+		}
+	}
+	if degradations == 0 {
+		return Regression{}, false
+	}
+
+	transitions := len(obs) - 1
+	pct := float64(degradations) / float64(transitions) * 100
+	latest := obs[len(obs)-1].status
+
+	severity := common.StatusWarning
+	if latest == common.StatusFailed {
+		severity = common.StatusFailed
+	}
+	return Regression{
+		TestName:       key.name,
+		Layer:          key.layer,
+		RegressionType: RegressionStatusDegradation,
+		Severity:       severity,
+		Details: fmt.Sprintf("%d of %d transitions (%.0f%%) went from Passed to Failed; latest status %s",
+			degradations, transitions, pct, latest),
+	}, true
+}
+
+// latencyTrend fits a line to a test's latency over time and reports it when
+// it is rising and its value at the latest run crosses a latency threshold
+func latencyTrend(key testKey, obs []observation, thresholds common.AlertThresholds) (Regression, bool) {
+	var xs, ys []float64
+	var start time.Time
+	for _, o := range obs {
+		if o.latency <= 0 {
+			continue
+		}
+		if start.IsZero() {
+			start = o.time
+		}
+		xs = append(xs, o.time.Sub(start).Hours())
+		ys = append(ys, float64(o.latency)/float64(time.Millisecond))
+	}
+	if len(xs) < minTrendSamples {
+		return Regression{}, false
+	}
+
+	slope, intercept, ok := linearFit(xs, ys)
+	if !ok || slope <= 0 {
+		return Regression{}, false
+	}
+	fitted := slope*xs[len(xs)-1] + intercept
+
+	var severity common.TestStatus
+	var threshold int
+	switch {
+	case thresholds.LatencyErrorMs > 0 && fitted >= float64(thresholds.LatencyErrorMs):
+		severity, threshold = common.StatusFailed, thresholds.LatencyErrorMs
+	case thresholds.LatencyWarningMs > 0 && fitted >= float64(thresholds.LatencyWarningMs):
+		severity, threshold = common.StatusWarning, thresholds.LatencyWarningMs
+	default:
+		return Regression{}, false
+	}
+
+	return Regression{
+		TestName:       key.name,
+		Layer:          key.layer,
+		RegressionType: RegressionLatencyTrend,
+		Severity:       severity,
+		Details: fmt.Sprintf("Latency rising %.2f ms/hour over %d runs, trend at %.1f ms (threshold %d ms)",
+			slope, len(xs), fitted, threshold),
+	}, true
+}
+
+// linearFit returns the least-squares slope and intercept of ys over xs. It
+// fails when all xs are equal.
+func linearFit(xs, ys []float64) (slope, intercept float64, ok bool) {
+	n := float64(len(xs))
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, 0, false
+	}
+	slope = (n*sumXY - sumX*sumY) / denominator
+	intercept = (sumY - slope*sumX) / n
+	return slope, intercept, true
+}
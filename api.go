@@ -3,20 +3,184 @@ package layers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
+	"ghostshell/app/layers/analysis"
 	"ghostshell/app/layers/common"
+	"ghostshell/app/layers/history"
+	"ghostshell/app/layers/layer7"
+	"ghostshell/app/layers/sse"
+	"ghostshell/app/layers/visualization"
 )
 
+// Events published on the /api/v1/events stream
+const (
+	EventTestStarted    = "test.started"
+	EventTestProgress   = "test.progress"
+	EventTestCompleted  = "test.completed"
+	EventLayerCompleted = "layer.completed"
+)
+
+// TestEvent is the payload of test.started and test.completed events
+type TestEvent struct {
+	ID          string `json:"id"`
+	Status      string `json:"status"`
+	Layers      []int  `json:"layers,omitempty"`
+	ResultCount int    `json:"result_count,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Code        string `json:"code,omitempty"`        // common.ErrorCode of a failed run
+	HTTPStatus  int    `json:"http_status,omitempty"` // HTTP status the failure maps to
+}
+
+// ProgressEvent is the payload of test.progress events
+type ProgressEvent struct {
+	ID        string `json:"id"`
+	Layer     int    `json:"layer"`
+	Completed int    `json:"completed"`
+	Total     int    `json:"total"`
+	Status    string `json:"status"`
+}
+
+// LayerResultEvent is the payload of layer.completed events, a test result
+// tagged with the run that produced it
+type LayerResultEvent struct {
+	RunID string `json:"run_id"`
+	common.TestResult
+}
+
+// ScheduledJob describes a recurring test run
+type ScheduledJob struct {
+	ID       string     `json:"id"`
+	CronExpr string     `json:"cron_expr"`
+	Layers   []int      `json:"layers"`
+	NextRun  time.Time  `json:"next_run"`
+	LastRun  *time.Time `json:"last_run,omitempty"`
+}
+
+// JobScheduler runs tests on a cron schedule for the /api/v1/schedule
+// endpoints. schedule.Scheduler implements it.
+type JobScheduler interface {
+	ScheduleJob(cronExpr string, config *Config) (string, error)
+	Jobs() []ScheduledJob
+}
+
+// TestInfo describes a test session in /api/v1/tests listings
+type TestInfo struct {
+	ID        string    `json:"id"`
+	Status    string    `json:"status"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time,omitempty"`
+	Layers    []int     `json:"layers"`
+}
+
+// TestResultsResponse is the body of GET /api/v1/tests/{id}/results
+type TestResultsResponse struct {
+	Results []common.TestResult `json:"results"`
+	Summary SessionSummary      `json:"summary"`
+	Failure *TestFailure        `json:"failure,omitempty"` // Set when the session failed
+}
+
+// TestFailure describes why a session started through the API failed. The
+// session runs after POST /api/v1/tests has returned, so its error is
+// reported by GET /api/v1/tests/{id} and /api/v1/tests/{id}/results.
+type TestFailure struct {
+	Error      string `json:"error"`
+	Code       string `json:"code,omitempty"` // common.ErrorCode of the error, if it has one
+	HTTPStatus int    `json:"http_status"`    // Status the error maps to, see errorStatus
+}
+
+// newTestFailure describes a session that failed with err
+func newTestFailure(err error) *TestFailure {
+	return &TestFailure{
+		Error:      err.Error(),
+		Code:       string(common.ErrorCodeOf(err)),
+		HTTPStatus: errorStatus(err, http.StatusInternalServerError),
+	}
+}
+
+// ConfigValidationResponse is the body of a PUT /api/v1/config rejected by
+// the config schema
+type ConfigValidationResponse struct {
+	Error            string            `json:"error"`
+	ValidationErrors []ValidationError `json:"validation_errors"`
+}
+
+// TestRequest is the body of POST /api/v1/tests
+type TestRequest struct {
+	Layers        []int                  `json:"layers"`
+	Tags          []string               `json:"tags,omitempty"`
+	BaselineRunID string                 `json:"baseline_run_id,omitempty"`
+	Config        map[string]interface{} `json:"config,omitempty"`
+}
+
+// ScheduleRequest is the body of POST /api/v1/schedule
+type ScheduleRequest struct {
+	CronExpr string `json:"cron_expr"`
+	Layers   []int  `json:"layers,omitempty"`
+}
+
+// LayerInfo describes a layer in /api/v1/layers listings
+type LayerInfo struct {
+	ID           int      `json:"id"`
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Enabled      bool     `json:"enabled"`
+	Dependencies []int    `json:"dependencies"`
+	Priority     int      `json:"priority"`
+	Tags         []string `json:"tags,omitempty"`
+}
+
+// HistoryPage is a page of stored runs from /api/v1/history
+type HistoryPage struct {
+	Items      []history.RunSummary `json:"items"`
+	NextCursor string               `json:"next_cursor"`
+	Total      int                  `json:"total"`
+}
+
+// HistorySearchPage is a page of stored runs from /api/v1/history/search
+type HistorySearchPage struct {
+	Items         []history.RunSummary `json:"items"`
+	TotalMatching int                  `json:"total_matching"`
+}
+
+// CompareRequest is the body of POST /api/v1/history/compare
+type CompareRequest struct {
+	BaseID             string `json:"base_id"`
+	CompareID          string `json:"compare_id"`
+	LatencyThresholdMs *int   `json:"latency_threshold_ms,omitempty"` // Defaults to common.DefaultLatencyDeltaThresholdMs
+	Format             string `json:"format,omitempty"`               // Also write a "md", "html" or "json" report
+}
+
+// ReportItem describes a generated report file
+type ReportItem struct {
+	ID            string    `json:"id"`
+	Timestamp     time.Time `json:"timestamp"`
+	Format        string    `json:"format"`
+	FilePath      string    `json:"file_path"`
+	SignaturePath string    `json:"signature_path,omitempty"` // HMAC signature of the report, when it was signed
+}
+
+// ReportRequest is the body of POST /api/v1/reports/generate
+type ReportRequest struct {
+	TestID  string         `json:"test_id"`
+	Format  string         `json:"format"`
+	Options map[string]any `json:"options,omitempty"`
+}
+
 // API represents the REST API for the Layers testing system
 type API struct {
 	Router       *mux.Router
@@ -24,6 +188,33 @@ type API struct {
 	Logger       *zap.Logger
 	ActiveTests  map[string]*TestSession
 	ResultsCache map[string][]common.TestResult
+	Failures     map[string]*TestFailure // Sessions in ResultsCache that failed
+	History      history.Store
+
+	// Scheduler backs the /api/v1/schedule endpoints, which return 503
+	// until it is set
+	Scheduler JobScheduler
+
+	// TracerProvider, when set, receives the spans of sessions started
+	// through the API. See EnableTracing.
+	TracerProvider trace.TracerProvider
+
+	// broker fans test events out to /api/v1/events subscribers
+	broker *sse.Broker
+
+	// configMu guards swaps of Config, which is replaced rather than
+	// modified so readers can keep using the pointer they loaded
+	configMu sync.RWMutex
+	// configPath is the file config changes are saved to and reloaded from
+	configPath string
+
+	// openAPI is the OpenAPI document of the registered routes
+	openAPI map[string]interface{}
+
+	// healthChecks are the components reported by /healthz
+	healthMu     sync.RWMutex
+	healthChecks map[string]HealthChecker
+	startTime    time.Time
 }
 
 // NewAPI creates a new API instance
@@ -34,6 +225,12 @@ func NewAPI(config *Config) (*API, error) {
 		return nil, fmt.Errorf("failed to initialize API logger: %w", err)
 	}
 
+	// Open the history store
+	store, err := OpenHistoryStore(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history store: %w", err)
+	}
+
 	// Create API
 	api := &API{
 		Router:       mux.NewRouter(),
@@ -41,19 +238,42 @@ func NewAPI(config *Config) (*API, error) {
 		Logger:       logger,
 		ActiveTests:  make(map[string]*TestSession),
 		ResultsCache: make(map[string][]common.TestResult),
+		Failures:     make(map[string]*TestFailure),
+		History:      store,
+		broker:       sse.NewBroker(),
+		startTime:    time.Now(),
 	}
+	api.registerDefaultHealthChecks()
 
 	// Register routes
 	api.registerRoutes()
 
+	// Document the routes; Run replaces the server with the listen address
+	api.openAPI, err = buildOpenAPISpec(api.Router, "/", requiresAuth(config))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build OpenAPI document: %w", err)
+	}
+
 	return api, nil
 }
 
 // registerRoutes sets up the API routes
 func (api *API) registerRoutes() {
+	// Self-documentation and health checks, outside the authenticated subrouter
+	api.registerDocRoutes()
+	api.Router.HandleFunc(healthzPath, api.handleHealthz).Methods("GET")
+
 	// API version prefix
 	v1 := api.Router.PathPrefix("/api/v1").Subrouter()
 
+	// Require a Bearer token on every endpoint when a secret is configured,
+	// resolving it per request so config reloads and updates apply
+	v1.Use(WithJWTAuth(
+		func() []byte { return ResolveAPISecret(api.currentConfig()) },
+		func() bool { return api.currentConfig().AllowUnauthenticated },
+	))
+	api.warnIfUnauthenticated(api.Config)
+
 	// Layer testing endpoints
 	v1.HandleFunc("/tests", api.handleGetAllTests).Methods("GET")
 	v1.HandleFunc("/tests", api.handleCreateTest).Methods("POST")
@@ -61,6 +281,13 @@ func (api *API) registerRoutes() {
 	v1.HandleFunc("/tests/{id}/cancel", api.handleCancelTest).Methods("POST")
 	v1.HandleFunc("/tests/{id}/results", api.handleGetTestResults).Methods("GET")
 
+	// Scheduling endpoints
+	v1.HandleFunc("/schedule", api.handleGetSchedule).Methods("GET")
+	v1.HandleFunc("/schedule", api.handleCreateSchedule).Methods("POST")
+
+	// Event stream endpoint
+	v1.HandleFunc("/events", api.handleEvents).Methods("GET")
+
 	// Configuration endpoints
 	v1.HandleFunc("/config", api.handleGetConfig).Methods("GET")
 	v1.HandleFunc("/config", api.handleUpdateConfig).Methods("PUT")
@@ -74,33 +301,140 @@ func (api *API) registerRoutes() {
 
 	// History endpoints
 	v1.HandleFunc("/history", api.handleGetHistory).Methods("GET")
+	v1.HandleFunc("/history/search", api.handleSearchHistory).Methods("GET")
 	v1.HandleFunc("/history/{id}", api.handleGetHistoryItem).Methods("GET")
+	v1.HandleFunc("/history/{id}", api.handleDeleteHistoryItem).Methods("DELETE")
 	v1.HandleFunc("/history/compare", api.handleCompareHistory).Methods("POST")
 
+	// Analysis endpoints
+	v1.HandleFunc("/analysis/regressions", api.handleGetRegressions).Methods("GET")
+
 	// Report endpoints
 	v1.HandleFunc("/reports", api.handleGetReports).Methods("GET")
 	v1.HandleFunc("/reports/generate", api.handleGenerateReport).Methods("POST")
+
+	// SLA endpoints
+	v1.HandleFunc("/sla", api.handleGetSLA).Methods("GET")
+
+	// Prometheus alert rules
+	v1.HandleFunc("/alert-rules", api.handleGetAlertRules).Methods("GET")
+
+	// Grafana dashboard
+	v1.HandleFunc("/grafana-dashboard", api.handleGetGrafanaDashboard).Methods("GET")
 }
 
-// Run starts the API server
-func (api *API) Run(addr string) error {
+// EnableTracing sends session spans to tp and parents them on the trace
+// context found in incoming request headers
+func (api *API) EnableTracing(tp trace.TracerProvider) {
+	api.TracerProvider = tp
+	api.Router.Use(WithTraceContext())
+}
+
+// sessionOptions returns the session options for a test started by r
+func (api *API) sessionOptions(r *http.Request) []SessionOption {
+	if api.TracerProvider == nil {
+		return nil
+	}
+	return []SessionOption{WithTracerProvider(api.TracerProvider), WithParentContext(r.Context())}
+}
+
+// Run starts the API server. When configPath is set, changes to that file
+// are reloaded into the running API.
+func (api *API) Run(addr, configPath string) error {
+	if configPath != "" {
+		stop, err := api.WatchConfig(configPath)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	api.openAPI["servers"] = []map[string]string{{"url": serverURL(addr)}}
+
 	api.Logger.Info("Starting API server", zap.String("address", addr))
 	return http.ListenAndServe(addr, api.Router)
 }
 
+// WatchConfig saves config changes made through the API to configPath and
+// reloads the file into the running API when it changes, as Run does. It is
+// for servers that serve Router themselves. The returned function stops
+// watching.
+func (api *API) WatchConfig(configPath string) (func(), error) {
+	api.configPath = configPath
+	stop, err := WatchConfig(configPath, api.swapConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch config: %w", err)
+	}
+	return stop, nil
+}
+
+// currentConfig returns the active configuration. Callers must not modify it.
+func (api *API) currentConfig() *Config {
+	api.configMu.RLock()
+	defer api.configMu.RUnlock()
+	return api.Config
+}
+
+// swapConfig replaces the active configuration
+func (api *API) swapConfig(config *Config) {
+	api.configMu.Lock()
+	api.Config = config
+	api.configMu.Unlock()
+	api.warnIfUnauthenticated(config)
+}
+
+// warnIfUnauthenticated logs an error when config has no signing secret, so
+// every /api/v1 request is rejected, and a warning when allow_unauthenticated
+// lets anyone who can reach the API run tests and change the configuration
+func (api *API) warnIfUnauthenticated(config *Config) {
+	if ResolveAPISecret(config) != nil {
+		return
+	}
+	if config.AllowUnauthenticated {
+		api.Logger.Warn("No API secret configured and allow_unauthenticated is set, REST API is UNAUTHENTICATED")
+		return
+	}
+	api.Logger.Error("No API secret configured, REST API requests are rejected; set one in the config or the environment",
+		zap.String("env", APISecretEnv))
+}
+
+// saveConfig writes config to the file the API was started with
+func (api *API) saveConfig(config *Config) {
+	configPath := api.configPath
+	if configPath == "" {
+		configPath = "config.json"
+	}
+	if err := SaveConfig(config, configPath); err != nil {
+		api.Logger.Error("Failed to save config", zap.Error(err))
+		// Continue anyway, just log the error
+	}
+}
+
+// UpdateResults publishes a layer.completed event for each result of a run
+func (api *API) UpdateResults(runID string, results []common.TestResult) {
+	for _, result := range results {
+		api.publish(EventLayerCompleted, LayerResultEvent{RunID: runID, TestResult: result})
+	}
+}
+
+// publish sends an event to the event stream subscribers
+func (api *API) publish(name string, data interface{}) {
+	if err := api.broker.Publish(name, data); err != nil {
+		api.Logger.Error("Failed to publish event", zap.String("event", name), zap.Error(err))
+	}
+}
+
+// handleEvents streams test events to the client as Server-Sent Events
+func (api *API) handleEvents(w http.ResponseWriter, r *http.Request) {
+	api.Logger.Debug("Event stream client connected", zap.String("remote", r.RemoteAddr))
+	api.broker.ServeHTTP(w, r)
+	api.Logger.Debug("Event stream client disconnected", zap.String("remote", r.RemoteAddr))
+}
+
 // Test Management API Handlers
 
 // handleGetAllTests returns all tests (active and completed)
 func (api *API) handleGetAllTests(w http.ResponseWriter, r *http.Request) {
-	// Create response struct
-	type TestInfo struct {
-		ID        string    `json:"id"`
-		Status    string    `json:"status"`
-		StartTime time.Time `json:"start_time"`
-		EndTime   time.Time `json:"end_time,omitempty"`
-		Layers    []int     `json:"layers"`
-	}
-
 	// Collect active tests
 	tests := make([]TestInfo, 0, len(api.ActiveTests))
 	for id, session := range api.ActiveTests {
@@ -108,7 +442,7 @@ func (api *API) handleGetAllTests(w http.ResponseWriter, r *http.Request) {
 			ID:        id,
 			Status:    "running",
 			StartTime: session.StartTime,
-			Layers:    api.Config.GetEnabledLayers(),
+			Layers:    api.currentConfig().GetEnabledLayers(),
 		})
 	}
 
@@ -120,11 +454,6 @@ func (api *API) handleGetAllTests(w http.ResponseWriter, r *http.Request) {
 // handleCreateTest starts a new test session
 func (api *API) handleCreateTest(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
-	type TestRequest struct {
-		Layers []int                  `json:"layers"`
-		Config map[string]interface{} `json:"config,omitempty"`
-	}
-
 	var req TestRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		api.respondWithError(w, http.StatusBadRequest, "Invalid request payload")
@@ -132,42 +461,84 @@ func (api *API) handleCreateTest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create test session with default config
-	config := api.Config
+	config := api.currentConfig()
 	if req.Config != nil {
 		// Apply any config overrides
 		// In a real implementation, this would merge req.Config into api.Config
 	}
 
-	session, err := NewTestSession(config)
+	// Reject runs the configuration can't support
+	if err := validateTestRequest(config, req.Layers); err != nil {
+		api.respondWithError(w, errorStatus(err, http.StatusBadRequest), err.Error())
+		return
+	}
+
+	// Only run layers matching the requested tags
+	opts := api.sessionOptions(r)
+	if len(req.Tags) > 0 {
+		opts = append(opts, WithTags(req.Tags))
+	}
+	// Compare against a stored run instead of the configured baseline
+	if req.BaselineRunID != "" {
+		opts = append(opts, WithBaseline(req.BaselineRunID))
+	}
+
+	session, err := NewTestSession(config, opts...)
 	if err != nil {
-		api.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create test session: %v", err))
+		api.respondWithError(w, errorStatus(err, http.StatusInternalServerError), fmt.Sprintf("Failed to create test session: %v", err))
 		return
 	}
 
 	// Store session
 	api.ActiveTests[session.RunID] = session
 
+	// Stream progress and layer results to event subscribers
+	session.SetProgressCallback(func(layer int, completed, total int, status string) {
+		api.publish(EventTestProgress, ProgressEvent{
+			ID:        session.RunID,
+			Layer:     layer,
+			Completed: completed,
+			Total:     total,
+			Status:    status,
+		})
+	})
+	session.SetResultCallback(func(layer int, results []common.TestResult) {
+		api.UpdateResults(session.RunID, results)
+	})
+
+	layers := req.Layers
+	if len(layers) == 0 {
+		layers = config.GetEnabledLayers()
+	}
+	layers = session.matchingLayers(layers)
+	api.publish(EventTestStarted, TestEvent{ID: session.RunID, Status: "running", Layers: layers})
+
 	// Run tests in a goroutine
 	go func() {
-		var results []common.TestResult
-		var err error
+		results, err := runSession(session, req.Layers)
 
-		if len(req.Layers) > 0 {
-			results, err = session.RunSelectedLayers(req.Layers)
-		} else {
-			results, err = session.RunAllTests()
-		}
-
-		// Store results
+		// Store results, and the failure for clients polling the session
 		api.ResultsCache[session.RunID] = results
+		if err != nil {
+			api.Failures[session.RunID] = newTestFailure(err)
+		}
 
 		// Remove from active tests
 		delete(api.ActiveTests, session.RunID)
 
+		completed := TestEvent{ID: session.RunID, Status: "completed", ResultCount: len(results)}
+
 		// Log any errors
 		if err != nil {
 			api.Logger.Error("Test session failed", zap.String("id", session.RunID), zap.Error(err))
+			completed.Status = "failed"
+			failure := api.Failures[session.RunID]
+			completed.Error = failure.Error
+			completed.Code = failure.Code
+			completed.HTTPStatus = failure.HTTPStatus
 		}
+
+		api.publish(EventTestCompleted, completed)
 	}()
 
 	// Return session ID
@@ -191,7 +562,20 @@ func (api *API) handleGetTest(w http.ResponseWriter, r *http.Request) {
 			"id":         id,
 			"status":     "running",
 			"start_time": session.StartTime,
-			"layers":     api.Config.GetEnabledLayers(),
+			"layers":     api.currentConfig().GetEnabledLayers(),
+		})
+		return
+	}
+
+	// A failed test reports why, with the status its error maps to
+	if failure, ok := api.Failures[id]; ok {
+		api.respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"id":          id,
+			"status":      "failed",
+			"error":       failure.Error,
+			"code":        failure.Code,
+			"http_status": failure.HTTPStatus,
+			"message":     "Test failed. Use /tests/{id}/results to get partial results.",
 		})
 		return
 	}
@@ -250,7 +634,13 @@ func (api *API) handleGetTestResults(w http.ResponseWriter, r *http.Request) {
 
 	// Check if test results are in cache
 	if results, ok := api.ResultsCache[id]; ok {
-		api.respondWithJSON(w, http.StatusOK, results)
+		// A failed session answers with the status its error maps to
+		code := http.StatusOK
+		failure := api.Failures[id]
+		if failure != nil {
+			code = failure.HTTPStatus
+		}
+		api.respondWithJSON(w, code, TestResultsResponse{Results: results, Summary: Summarize(results), Failure: failure})
 		return
 	}
 
@@ -264,13 +654,38 @@ func (api *API) handleGetTestResults(w http.ResponseWriter, r *http.Request) {
 
 // handleGetConfig returns the current configuration
 func (api *API) handleGetConfig(w http.ResponseWriter, r *http.Request) {
-	api.respondWithJSON(w, http.StatusOK, api.Config)
+	// Never expose the signing secrets
+	config := *api.currentConfig()
+	config.APISecret = ""
+	config.ReportSigningKey = ""
+	api.respondWithJSON(w, http.StatusOK, config)
 }
 
 // handleUpdateConfig updates the configuration
 func (api *API) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || !json.Valid(body) {
+		api.respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+
+	if errs := ValidateConfigAgainstSchema(body); len(errs) > 0 {
+		api.respondWithJSON(w, http.StatusBadRequest, ConfigValidationResponse{
+			Error:            "Invalid configuration",
+			ValidationErrors: errs,
+		})
+		return
+	}
+
+	// The schema accepts duration strings that encoding/json does not
+	body, err = normalizeDurations(body)
+	if err != nil {
+		api.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid configuration: %v", err))
+		return
+	}
+
 	var newConfig Config
-	if err := json.NewDecoder(r.Body).Decode(&newConfig); err != nil {
+	if err := json.Unmarshal(body, &newConfig); err != nil {
 		api.respondWithError(w, http.StatusBadRequest, "Invalid request payload")
 		return
 	}
@@ -281,15 +696,21 @@ func (api *API) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The secrets are redacted from GET responses, so keep the current ones
+	if newConfig.APISecret == "" {
+		newConfig.APISecret = api.currentConfig().APISecret
+	}
+	if newConfig.ReportSigningKey == "" {
+		newConfig.ReportSigningKey = api.currentConfig().ReportSigningKey
+	}
+	// Authentication can only be switched off in the config file, not by a client
+	newConfig.AllowUnauthenticated = api.currentConfig().AllowUnauthenticated
+
 	// Update config
-	api.Config = &newConfig
+	api.swapConfig(&newConfig)
 
 	// Save config to file
-	configPath := "config.json"
-	if err := SaveConfig(api.Config, configPath); err != nil {
-		api.Logger.Error("Failed to save config", zap.Error(err))
-		// Continue anyway, just log the error
-	}
+	api.saveConfig(&newConfig)
 
 	api.respondWithJSON(w, http.StatusOK, map[string]string{
 		"message": "Configuration updated successfully",
@@ -299,7 +720,7 @@ func (api *API) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 // handleResetConfig resets the configuration to defaults
 func (api *API) handleResetConfig(w http.ResponseWriter, r *http.Request) {
 	// Create default config
-	api.Config = &Config{
+	config := &Config{
 		OutputFormat:  "pdf",
 		LogLevel:      "info",
 		GlobalTimeout: 30 * time.Second,
@@ -323,7 +744,7 @@ func (api *API) handleResetConfig(w http.ResponseWriter, r *http.Request) {
 
 	// Apply default layer configs
 	// This is simplified - in a real implementation, set all layer configs
-	api.Config.Layer1 = LayerConfig{
+	config.Layer1 = LayerConfig{
 		Enabled:  true,
 		Timeout:  5 * time.Second,
 		Priority: 1,
@@ -331,6 +752,7 @@ func (api *API) handleResetConfig(w http.ResponseWriter, r *http.Request) {
 			"attempt_count": 3,
 		},
 	}
+	api.swapConfig(config)
 
 	api.respondWithJSON(w, http.StatusOK, map[string]string{
 		"message": "Configuration reset to defaults",
@@ -342,35 +764,23 @@ func (api *API) handleResetConfig(w http.ResponseWriter, r *http.Request) {
 // handleGetLayers returns information about all layers
 func (api *API) handleGetLayers(w http.ResponseWriter, r *http.Request) {
 	// Create test session to get layer information
-	session, err := NewTestSession(api.Config)
+	session, err := NewTestSession(api.currentConfig())
 	if err != nil {
 		api.respondWithError(w, http.StatusInternalServerError, "Failed to create session")
 		return
 	}
 
-	// Initialize runners for all layers
-	allLayers := []int{1, 2, 3, 4, 5, 6, 7}
-	runners, err := session.initializeRunners(allLayers)
+	// Initialize runners for all layers, including plugin layers
+	runners, err := session.initializeRunners(api.currentConfig().AllLayers())
 	if err != nil {
 		api.respondWithError(w, http.StatusInternalServerError, "Failed to initialize runners")
 		return
 	}
 
-	// Collect layer information
-	type LayerInfo struct {
-		ID           int      `json:"id"`
-		Name         string   `json:"name"`
-		Description  string   `json:"description"`
-		Enabled      bool     `json:"enabled"`
-		Dependencies []int    `json:"dependencies"`
-		Priority     int      `json:"priority"`
-		Tags         []string `json:"tags,omitempty"`
-	}
-
 	// Build layer info
 	layerInfos := make([]LayerInfo, 0, len(runners))
 	for layer, runner := range runners {
-		config, err := api.Config.GetLayerConfig(layer)
+		config, err := api.currentConfig().GetLayerConfig(layer)
 		if err != nil {
 			continue
 		}
@@ -401,13 +811,13 @@ func (api *API) handleGetLayerInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate layer
-	if layer < 1 || layer > 7 {
-		api.respondWithError(w, http.StatusBadRequest, "Layer ID must be between 1 and 7")
+	if !api.currentConfig().HasLayer(layer) {
+		api.respondWithError(w, http.StatusBadRequest, "Layer ID must be between 1 and 7 or a plugin layer")
 		return
 	}
 
 	// Create test session to get layer information
-	session, err := NewTestSession(api.Config)
+	session, err := NewTestSession(api.currentConfig())
 	if err != nil {
 		api.respondWithError(w, http.StatusInternalServerError, "Failed to create session")
 		return
@@ -427,7 +837,7 @@ func (api *API) handleGetLayerInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get layer config
-	config, err := api.Config.GetLayerConfig(layer)
+	config, err := api.currentConfig().GetLayerConfig(layer)
 	if err != nil {
 		api.respondWithError(w, http.StatusInternalServerError, "Failed to get layer config")
 		return
@@ -459,13 +869,13 @@ func (api *API) handleGetLayerConfig(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate layer
-	if layer < 1 || layer > 7 {
-		api.respondWithError(w, http.StatusBadRequest, "Layer ID must be between 1 and 7")
+	if !api.currentConfig().HasLayer(layer) {
+		api.respondWithError(w, http.StatusBadRequest, "Layer ID must be between 1 and 7 or a plugin layer")
 		return
 	}
 
 	// Get layer config
-	config, err := api.Config.GetLayerConfig(layer)
+	config, err := api.currentConfig().GetLayerConfig(layer)
 	if err != nil {
 		api.respondWithError(w, http.StatusInternalServerError, "Failed to get layer config")
 		return
@@ -486,8 +896,14 @@ func (api *API) handleUpdateLayerConfig(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Validate layer
-	if layer < 1 || layer > 7 {
-		api.respondWithError(w, http.StatusBadRequest, "Layer ID must be between 1 and 7")
+	if !api.currentConfig().HasLayer(layer) {
+		api.respondWithError(w, http.StatusBadRequest, "Layer ID must be between 1 and 7 or a plugin layer")
+		return
+	}
+
+	// Custom plugin layers are configured by their plugin entry
+	if layer > 7 {
+		api.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Layer %d is a plugin layer; update its entry in plugins instead", layer))
 		return
 	}
 
@@ -498,102 +914,218 @@ func (api *API) handleUpdateLayerConfig(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Update layer config
+	// Update layer config on a copy so running sessions keep a stable config
+	config := *api.currentConfig()
 	switch layer {
 	case 1:
-		api.Config.Layer1 = newConfig
+		config.Layer1 = newConfig
 	case 2:
-		api.Config.Layer2 = newConfig
+		config.Layer2 = newConfig
 	case 3:
-		api.Config.Layer3 = newConfig
+		config.Layer3 = newConfig
 	case 4:
-		api.Config.Layer4 = newConfig
+		config.Layer4 = newConfig
 	case 5:
-		api.Config.Layer5 = newConfig
+		config.Layer5 = newConfig
 	case 6:
-		api.Config.Layer6 = newConfig
+		config.Layer6 = newConfig
 	case 7:
-		api.Config.Layer7 = newConfig
+		config.Layer7 = newConfig
 	}
+	api.swapConfig(&config)
 
 	// Save config to file
-	configPath := "config.json"
-	if err := SaveConfig(api.Config, configPath); err != nil {
-		api.Logger.Error("Failed to save config", zap.Error(err))
-		// Continue anyway, just log the error
-	}
+	api.saveConfig(&config)
 
 	api.respondWithJSON(w, http.StatusOK, map[string]string{
 		"message": fmt.Sprintf("Layer %d configuration updated successfully", layer),
 	})
 }
 
+// Scheduling API Handlers
+
+// handleGetSchedule returns the scheduled jobs, soonest first
+func (api *API) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	if api.Scheduler == nil {
+		api.respondWithError(w, http.StatusServiceUnavailable, "Scheduling is not enabled")
+		return
+	}
+
+	jobs := api.Scheduler.Jobs()
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].NextRun.Before(jobs[j].NextRun)
+	})
+
+	api.respondWithJSON(w, http.StatusOK, jobs)
+}
+
+// handleCreateSchedule schedules a recurring run of the current configuration,
+// optionally restricted to some of its layers
+func (api *API) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	if api.Scheduler == nil {
+		api.respondWithError(w, http.StatusServiceUnavailable, "Scheduling is not enabled")
+		return
+	}
+
+	var req ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.respondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	if req.CronExpr == "" {
+		api.respondWithError(w, http.StatusBadRequest, "cron_expr is required")
+		return
+	}
+
+	// Schedule a copy so later config changes don't alter the job
+	config := *api.currentConfig()
+	if len(req.Layers) > 0 {
+		config.restrictLayers(req.Layers)
+		if len(config.GetEnabledLayers()) == 0 {
+			api.respondWithError(w, http.StatusBadRequest, "None of the requested layers are enabled")
+			return
+		}
+	}
+
+	id, err := api.Scheduler.ScheduleJob(req.CronExpr, &config)
+	if err != nil {
+		api.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Failed to schedule job: %v", err))
+		return
+	}
+
+	api.respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":        id,
+		"cron_expr": req.CronExpr,
+		"layers":    config.GetEnabledLayers(),
+	})
+}
+
 // History API Handlers
 
 // handleGetHistory returns test history
 func (api *API) handleGetHistory(w http.ResponseWriter, r *http.Request) {
 	// Get query parameters
+	query := r.URL.Query()
 	limit := 10 // Default
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+	if limitStr := query.Get("limit"); limitStr != "" {
 		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
 			limit = l
 		}
 	}
 
-	// List history directory
-	historyDir := filepath.Join(common.MetricsDir, "history")
-	files, err := os.ReadDir(historyDir)
+	order := strings.ToLower(query.Get("order"))
+	if order == "" {
+		order = history.OrderDesc
+	}
+	if order != history.OrderAsc && order != history.OrderDesc {
+		api.respondWithError(w, http.StatusBadRequest, "Invalid order: must be asc or desc")
+		return
+	}
+	after := query.Get("after")
+
+	// The cursor is a run ID, so the full ordering is needed to locate it
+	runs, err := api.History.List(0, 0, order)
 	if err != nil {
-		if os.IsNotExist(err) {
-			// No history yet
-			api.respondWithJSON(w, http.StatusOK, []interface{}{})
+		api.respondWithError(w, http.StatusInternalServerError, "Failed to list history")
+		return
+	}
+
+	// Start after the cursor run
+	start := 0
+	if after != "" {
+		start = -1
+		for i, run := range runs {
+			if run.RunID == after {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			api.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid cursor: %s", after))
 			return
 		}
-		api.respondWithError(w, http.StatusInternalServerError, "Failed to read history directory")
-		return
 	}
 
-	// Process files
-	type HistoryItem struct {
-		ID        string    `json:"id"`
-		Timestamp time.Time `json:"timestamp"`
-		FilePath  string    `json:"file_path"`
+	end := start + limit
+	if end > len(runs) {
+		end = len(runs)
 	}
 
-	var historyItems []HistoryItem
-	for _, file := range files {
-		if !strings.HasSuffix(file.Name(), ".json") {
-			continue
-		}
+	page := HistoryPage{
+		Items: runs[start:end],
+		Total: len(runs),
+	}
+	if end < len(runs) {
+		page.NextCursor = runs[end-1].RunID
+	}
 
-		// Parse timestamp from filename
-		name := file.Name()
-		name = strings.TrimSuffix(name, ".json")
-		parts := strings.Split(name, "_")
-		if len(parts) < 3 {
-			continue
-		}
+	api.respondWithJSON(w, http.StatusOK, page)
+}
+
+// handleSearchHistory returns the stored runs with results matching the
+// layer, status and name filters within a time range
+func (api *API) handleSearchHistory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := history.SearchFilter{
+		NamePattern: query.Get("name_pattern"),
+		Limit:       10, // Default
+		Order:       strings.ToLower(query.Get("order")),
+	}
 
-		// Extract timestamp from the last part
-		timestampStr := parts[len(parts)-1]
-		timestamp, err := time.Parse("20060102_150405", timestampStr)
+	intParam := func(name string, min int, dst *int) bool {
+		s := query.Get(name)
+		if s == "" {
+			return true
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min {
+			api.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s: %s", name, s))
+			return false
+		}
+		*dst = n
+		return true
+	}
+	timeParam := func(name string, dst *time.Time) bool {
+		s := query.Get(name)
+		if s == "" {
+			return true
+		}
+		t, err := time.Parse(time.RFC3339, s)
 		if err != nil {
-			continue
+			api.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s: must be an RFC3339 time", name))
+			return false
 		}
-
-		historyItems = append(historyItems, HistoryItem{
-			ID:        timestampStr,
-			Timestamp: timestamp,
-			FilePath:  filepath.Join(historyDir, file.Name()),
-		})
-
-		// Limit number of items
-		if len(historyItems) >= limit {
-			break
+		*dst = t
+		return true
+	}
+	if !intParam("layer", 1, &filter.Layer) || !intParam("limit", 1, &filter.Limit) ||
+		!intParam("offset", 0, &filter.Offset) || !timeParam("from", &filter.From) || !timeParam("to", &filter.To) {
+		return
+	}
+	if filter.Layer != 0 && !api.currentConfig().HasLayer(filter.Layer) {
+		api.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid layer: %d", filter.Layer))
+		return
+	}
+	if s := query.Get("status"); s != "" {
+		status, err := common.ParseTestStatus(s)
+		if err != nil {
+			api.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid status: %s", s))
+			return
 		}
+		filter.Status = &status
+	}
+	if filter.Order != "" && filter.Order != history.OrderAsc && filter.Order != history.OrderDesc {
+		api.respondWithError(w, http.StatusBadRequest, "Invalid order: must be asc or desc")
+		return
+	}
+
+	items, total, err := api.History.Search(filter)
+	if err != nil {
+		api.respondWithError(w, http.StatusInternalServerError, "Failed to search history")
+		return
 	}
 
-	api.respondWithJSON(w, http.StatusOK, historyItems)
+	api.respondWithJSON(w, http.StatusOK, HistorySearchPage{Items: items, TotalMatching: total})
 }
 
 // handleGetHistoryItem returns a specific history item
@@ -602,40 +1134,42 @@ func (api *API) handleGetHistoryItem(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	// Construct file path
-	filePath := filepath.Join(common.MetricsDir, "history", fmt.Sprintf("layer_tests_%s.json", id))
-
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		api.respondWithError(w, http.StatusNotFound, "History item not found")
-		return
-	}
-
-	// Read file
-	data, err := os.ReadFile(filePath)
+	results, err := api.History.Get(id)
 	if err != nil {
-		api.respondWithError(w, http.StatusInternalServerError, "Failed to read history file")
+		if errors.Is(err, history.ErrNotFound) {
+			api.respondWithError(w, http.StatusNotFound, "History item not found")
+			return
+		}
+		api.respondWithError(w, http.StatusInternalServerError, "Failed to load history item")
 		return
 	}
 
-	// Parse JSON
-	var results []common.TestResult
-	if err := json.Unmarshal(data, &results); err != nil {
-		api.respondWithError(w, http.StatusInternalServerError, "Failed to parse history file")
+	api.respondWithJSON(w, http.StatusOK, results)
+}
+
+// handleDeleteHistoryItem removes a specific history item
+func (api *API) handleDeleteHistoryItem(w http.ResponseWriter, r *http.Request) {
+	// Get history ID from URL
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := api.History.Delete(id); err != nil {
+		if errors.Is(err, history.ErrNotFound) {
+			api.respondWithError(w, http.StatusNotFound, "History item not found")
+			return
+		}
+		api.respondWithError(w, http.StatusInternalServerError, "Failed to delete history item")
 		return
 	}
 
-	api.respondWithJSON(w, http.StatusOK, results)
+	api.respondWithJSON(w, http.StatusOK, map[string]string{
+		"message": "History item deleted",
+	})
 }
 
 // handleCompareHistory compares two history items
 func (api *API) handleCompareHistory(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
-	type CompareRequest struct {
-		BaseID    string `json:"base_id"`
-		CompareID string `json:"compare_id"`
-	}
-
 	var req CompareRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		api.respondWithError(w, http.StatusBadRequest, "Invalid request payload")
@@ -643,81 +1177,142 @@ func (api *API) handleCompareHistory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Load base results
-	baseFilePath := filepath.Join(common.MetricsDir, "history", fmt.Sprintf("layer_tests_%s.json", req.BaseID))
-	baseData, err := os.ReadFile(baseFilePath)
+	baseResults, err := api.History.Get(req.BaseID)
+	if err != nil {
+		if errors.Is(err, history.ErrNotFound) {
+			api.respondWithError(w, http.StatusNotFound, "Base history item not found")
+			return
+		}
+		api.respondWithError(w, http.StatusInternalServerError, "Failed to load base history item")
+		return
+	}
+
+	// Load compare results
+	compareResults, err := api.History.Get(req.CompareID)
 	if err != nil {
-		api.respondWithError(w, http.StatusNotFound, "Base history item not found")
+		if errors.Is(err, history.ErrNotFound) {
+			api.respondWithError(w, http.StatusNotFound, "Compare history item not found")
+			return
+		}
+		api.respondWithError(w, http.StatusInternalServerError, "Failed to load compare history item")
 		return
 	}
 
-	var baseResults []common.TestResult
-	if err := json.Unmarshal(baseData, &baseResults); err != nil {
-		api.respondWithError(w, http.StatusInternalServerError, "Failed to parse base history file")
+	// Perform comparison
+	generator := common.NewDiffReportGenerator(baseResults, compareResults)
+	if req.LatencyThresholdMs != nil {
+		generator.LatencyDeltaThresholdMs = *req.LatencyThresholdMs
+	}
+
+	if req.Format == "" {
+		api.respondWithJSON(w, http.StatusOK, generator.Diff())
 		return
 	}
 
-	// Load compare results
-	compareFilePath := filepath.Join(common.MetricsDir, "history", fmt.Sprintf("layer_tests_%s.json", req.CompareID))
-	compareData, err := os.ReadFile(compareFilePath)
+	// Write the comparison as a report file as well
+	reportPath, err := generator.Generate(common.ReportFormat(req.Format), "")
 	if err != nil {
-		api.respondWithError(w, http.StatusNotFound, "Compare history item not found")
+		api.respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Failed to generate comparison report: %v", err))
 		return
 	}
 
-	var compareResults []common.TestResult
-	if err := json.Unmarshal(compareData, &compareResults); err != nil {
-		api.respondWithError(w, http.StatusInternalServerError, "Failed to parse compare history file")
+	api.respondWithJSON(w, http.StatusOK, map[string]any{
+		"message": "Comparison report generated successfully",
+		"path":    reportPath,
+		"format":  req.Format,
+		"diff":    generator.Diff(),
+	})
+}
+
+// Analysis API Handlers
+
+// handleGetRegressions returns the regressions found in the last window runs
+func (api *API) handleGetRegressions(w http.ResponseWriter, r *http.Request) {
+	window := 10 // Default
+	if windowStr := r.URL.Query().Get("window"); windowStr != "" {
+		n, err := strconv.Atoi(windowStr)
+		if err != nil || n < 2 {
+			api.respondWithError(w, http.StatusBadRequest, "Invalid window: must be an integer of at least 2")
+			return
+		}
+		window = n
+	}
+
+	regressions, err := analysis.DetectRegressions(api.History, window, api.currentConfig().AlertThresholds)
+	if err != nil {
+		api.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to analyse history: %v", err))
 		return
 	}
 
-	// Perform comparison
-	// In a real implementation, this would be much more sophisticated
-	type ComparisonResult struct {
-		Layer            int     `json:"layer"`
-		Name             string  `json:"name"`
-		BaseStatus       string  `json:"base_status"`
-		CompareStatus    string  `json:"compare_status"`
-		StatusChanged    bool    `json:"status_changed"`
-		LatencyDiff      float64 `json:"latency_diff_ms,omitempty"`
-		PacketLossDiff   float64 `json:"packet_loss_diff_pct,omitempty"`
-		TransferRateDiff float64 `json:"transfer_rate_diff_mb_s,omitempty"`
-	}
-
-	var comparison []ComparisonResult
-
-	// Simple comparison by layer
-	for _, baseResult := range baseResults {
-		// Find matching result in compare set
-		for _, compareResult := range compareResults {
-			if baseResult.Layer == compareResult.Layer && baseResult.Name == compareResult.Name {
-				comp := ComparisonResult{
-					Layer:         baseResult.Layer,
-					Name:          baseResult.Name,
-					BaseStatus:    string(baseResult.Status),
-					CompareStatus: string(compareResult.Status),
-					StatusChanged: baseResult.Status != compareResult.Status,
-				}
-
-				// Compare metrics
-				if baseResult.Metrics.Latency > 0 && compareResult.Metrics.Latency > 0 {
-					comp.LatencyDiff = float64(compareResult.Metrics.Latency.Milliseconds() - baseResult.Metrics.Latency.Milliseconds())
-				}
-
-				if baseResult.Metrics.PacketLoss > 0 || compareResult.Metrics.PacketLoss > 0 {
-					comp.PacketLossDiff = compareResult.Metrics.PacketLoss - baseResult.Metrics.PacketLoss
-				}
-
-				if baseResult.Metrics.TransferRate > 0 || compareResult.Metrics.TransferRate > 0 {
-					comp.TransferRateDiff = compareResult.Metrics.TransferRate - baseResult.Metrics.TransferRate
-				}
-
-				comparison = append(comparison, comp)
-				break
-			}
+	api.respondWithJSON(w, http.StatusOK, regressions)
+}
+
+// handleGetAlertRules serves Prometheus alert rules built from the configured
+// alert thresholds
+func (api *API) handleGetAlertRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := visualization.GenerateAlertRules(api.currentConfig().AlertThresholds)
+	if err != nil {
+		api.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to generate alert rules: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(rules))
+}
+
+// handleGetGrafanaDashboard returns a Grafana dashboard for the exported
+// Prometheus metrics, ready to import
+func (api *API) handleGetGrafanaDashboard(w http.ResponseWriter, r *http.Request) {
+	dashboard, err := visualization.GenerateGrafanaDashboard(api.currentConfig().GrafanaDatasource)
+	if err != nil {
+		api.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to generate Grafana dashboard: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(dashboard)
+}
+
+// SLA API Handlers
+
+// handleGetSLA returns SLA compliance for a test computed from history
+func (api *API) handleGetSLA(w http.ResponseWriter, r *http.Request) {
+	testName := r.URL.Query().Get("test")
+	if testName == "" {
+		api.respondWithError(w, http.StatusBadRequest, "Missing test parameter")
+		return
+	}
+
+	target := 99.9 // Default
+	if targetStr := r.URL.Query().Get("target"); targetStr != "" {
+		t, err := strconv.ParseFloat(targetStr, 64)
+		if err != nil || t <= 0 || t > 100 {
+			api.respondWithError(w, http.StatusBadRequest, "Target must be a percentage between 0 and 100")
+			return
 		}
+		target = t
 	}
 
-	api.respondWithJSON(w, http.StatusOK, comparison)
+	window := 720 // Default: 30 days
+	if windowStr := r.URL.Query().Get("window"); windowStr != "" {
+		w2, err := strconv.Atoi(windowStr)
+		if err != nil || w2 <= 0 {
+			api.respondWithError(w, http.StatusBadRequest, "Window must be a positive number of hours")
+			return
+		}
+		window = w2
+	}
+
+	historyDir := filepath.Join(common.MetricsDir, "history")
+	result, err := layer7.TrackSLACompliance(testName, historyDir, target, window)
+	if err != nil {
+		api.respondWithError(w, http.StatusNotFound, fmt.Sprintf("Failed to compute SLA: %v", err))
+		return
+	}
+
+	api.respondWithJSON(w, http.StatusOK, result)
 }
 
 // Report API Handlers
@@ -737,13 +1332,6 @@ func (api *API) handleGetReports(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Process files
-	type ReportItem struct {
-		ID        string    `json:"id"`
-		Timestamp time.Time `json:"timestamp"`
-		Format    string    `json:"format"`
-		FilePath  string    `json:"file_path"`
-	}
-
 	var reportItems []ReportItem
 	for _, file := range files {
 		if file.IsDir() {
@@ -752,31 +1340,34 @@ func (api *API) handleGetReports(w http.ResponseWriter, r *http.Request) {
 
 		name := file.Name()
 		format := filepath.Ext(name)
-		if format == "" {
+		if format == "" || format == common.SignatureExt {
+			// Signatures are listed with the report they sign
 			continue
 		}
 		format = format[1:] // Remove the dot
 
-		// Try to parse timestamp from filename
-		parts := strings.Split(name, "_")
-		if len(parts) < 2 {
+		// Parse the timestamp from a <test>_<date>_<time>.<ext> file name
+		const timestampLayout = "20060102_150405"
+		stem, _, _ := strings.Cut(name, ".")
+		if len(stem) <= len(timestampLayout) {
 			continue
 		}
-
-		timestampPart := parts[len(parts)-1]
-		timestampPart = strings.TrimSuffix(timestampPart, format)
-		timestampPart = strings.TrimSuffix(timestampPart, ".")
-		timestamp, err := time.Parse("20060102_150405", timestampPart)
+		timestampPart := stem[len(stem)-len(timestampLayout):]
+		timestamp, err := time.Parse(timestampLayout, timestampPart)
 		if err != nil {
 			continue
 		}
 
-		reportItems = append(reportItems, ReportItem{
+		item := ReportItem{
 			ID:        timestampPart,
 			Timestamp: timestamp,
 			Format:    format,
 			FilePath:  filepath.Join(common.ReportDir, name),
-		})
+		}
+		if _, err := os.Stat(item.FilePath + common.SignatureExt); err == nil {
+			item.SignaturePath = item.FilePath + common.SignatureExt
+		}
+		reportItems = append(reportItems, item)
 	}
 
 	api.respondWithJSON(w, http.StatusOK, reportItems)
@@ -785,12 +1376,6 @@ func (api *API) handleGetReports(w http.ResponseWriter, r *http.Request) {
 // handleGenerateReport generates a report from test results
 func (api *API) handleGenerateReport(w http.ResponseWriter, r *http.Request) {
 	// Parse request body
-	type ReportRequest struct {
-		TestID  string         `json:"test_id"`
-		Format  string         `json:"format"`
-		Options map[string]any `json:"options,omitempty"`
-	}
-
 	var req ReportRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		api.respondWithError(w, http.StatusBadRequest, "Invalid request payload")
@@ -799,13 +1384,17 @@ func (api *API) handleGenerateReport(w http.ResponseWriter, r *http.Request) {
 
 	// Validate format
 	validFormats := map[string]bool{
-		"csv":  true,
-		"pdf":  true,
-		"json": true,
-		"yaml": true,
-		"html": true,
-		"md":   true,
-		"xml":  true,
+		"csv":    true,
+		"pdf":    true,
+		"json":   true,
+		"yaml":   true,
+		"html":   true,
+		"md":     true,
+		"xml":    true,
+		"junit":  true,
+		"xlsx":   true,
+		"sarif":  true,
+		"influx": true,
 	}
 	if !validFormats[req.Format] {
 		api.respondWithError(w, http.StatusBadRequest, "Invalid format")
@@ -820,28 +1409,26 @@ func (api *API) handleGenerateReport(w http.ResponseWriter, r *http.Request) {
 		results = cachedResults
 	} else {
 		// Try to load from history
-		historyPath := filepath.Join(common.MetricsDir, "history", fmt.Sprintf("layer_tests_%s.json", req.TestID))
-		if _, err := os.Stat(historyPath); os.IsNotExist(err) {
-			api.respondWithError(w, http.StatusNotFound, "Test results not found")
-			return
-		}
-
-		// Read history file
-		data, err := os.ReadFile(historyPath)
+		stored, err := api.History.Get(req.TestID)
 		if err != nil {
-			api.respondWithError(w, http.StatusInternalServerError, "Failed to read history file")
-			return
-		}
-
-		// Parse JSON
-		if err := json.Unmarshal(data, &results); err != nil {
-			api.respondWithError(w, http.StatusInternalServerError, "Failed to parse history file")
+			if errors.Is(err, history.ErrNotFound) {
+				api.respondWithError(w, http.StatusNotFound, "Test results not found")
+				return
+			}
+			api.respondWithError(w, http.StatusInternalServerError, "Failed to load test results")
 			return
 		}
+		results = stored
 	}
 
-	// Create report generator
+	// Create report generator, signing the report when a key is configured
 	generator := common.NewReportGenerator(results, "layer_tests")
+	key, err := ResolveReportSigningKey(api.currentConfig())
+	if err != nil {
+		api.respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Invalid report signing key: %v", err))
+		return
+	}
+	generator.SigningKey = key
 
 	// Generate report
 	reportPath, err := generator.GenerateReport(common.ReportFormat(req.Format))
@@ -851,12 +1438,16 @@ func (api *API) handleGenerateReport(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Return report info
-	api.respondWithJSON(w, http.StatusOK, map[string]string{
+	response := map[string]string{
 		"message": "Report generated successfully",
 		"path":    reportPath,
 		"format":  req.Format,
 		"test_id": req.TestID,
-	})
+	}
+	if key != nil {
+		response["signature_path"] = reportPath + common.SignatureExt
+	}
+	api.respondWithJSON(w, http.StatusOK, response)
 }
 
 // Helper methods
@@ -866,6 +1457,43 @@ func (api *API) respondWithError(w http.ResponseWriter, code int, message string
 	api.respondWithJSON(w, code, map[string]string{"error": message})
 }
 
+// validateTestRequest checks that config can run layers, or all its enabled
+// layers when none are given
+func validateTestRequest(config *Config, layers []int) error {
+	if err := config.ValidateConfig(); err != nil {
+		return &common.LayerError{Code: common.ErrConfigInvalid, Cause: err}
+	}
+	for _, layer := range layers {
+		if _, err := config.GetLayerConfig(layer); err != nil {
+			return &common.LayerError{Layer: layer, Code: common.ErrConfigInvalid, Cause: err}
+		}
+	}
+	return nil
+}
+
+// runSession runs a session started through the API, over layers or all
+// enabled layers when there are none. Tests replace it to control the outcome.
+var runSession = func(session *TestSession, layers []int) ([]common.TestResult, error) {
+	if len(layers) > 0 {
+		return session.RunSelectedLayers(layers)
+	}
+	return session.RunAllTests()
+}
+
+// errorStatus returns the HTTP status for the LayerError code in err's
+// chain, or fallback when err has none or its code has no better match
+func errorStatus(err error, fallback int) int {
+	switch common.ErrorCodeOf(err) {
+	case common.ErrTimeout:
+		return http.StatusGatewayTimeout
+	case common.ErrPermissionDenied:
+		return http.StatusServiceUnavailable
+	case common.ErrConfigInvalid:
+		return http.StatusBadRequest
+	}
+	return fallback
+}
+
 // respondWithJSON returns a JSON response
 func (api *API) respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, err := json.Marshal(payload)
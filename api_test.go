@@ -0,0 +1,234 @@
+package layers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/common"
+	"ghostshell/app/layers/history"
+	"ghostshell/app/layers/sse"
+)
+
+// newHistoryAPI creates an API backed by a file store holding runs saved in
+// the given order, oldest first
+func newHistoryAPI(t *testing.T, runIDs ...string) *API {
+	t.Helper()
+
+	dir := t.TempDir()
+	store := history.NewFileStore(dir)
+	base := time.Now().Add(-time.Hour)
+	for i, id := range runIDs {
+		results := []common.TestResult{{Layer: 1, Name: "Link Test", Status: common.StatusPassed}}
+		if err := store.Save(id, results); err != nil {
+			t.Fatal(err)
+		}
+		// Listing is ordered by modification time, so space the runs apart
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, fmt.Sprintf("layer_tests_%s.json", id)), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &API{History: store, Logger: zap.NewNop()}
+}
+
+func TestHandleGetHistoryPagination(t *testing.T) {
+	api := newHistoryAPI(t,
+		"20250101_100000", "20250101_110000", "20250101_120000",
+		"20250101_130000", "20250101_140000")
+
+	tests := []struct {
+		name       string
+		query      string
+		wantIDs    []string
+		wantCursor string
+	}{
+		{
+			name:       "first page",
+			query:      "limit=2",
+			wantIDs:    []string{"20250101_140000", "20250101_130000"},
+			wantCursor: "20250101_130000",
+		},
+		{
+			name:       "middle page",
+			query:      "limit=2&after=20250101_130000",
+			wantIDs:    []string{"20250101_120000", "20250101_110000"},
+			wantCursor: "20250101_110000",
+		},
+		{
+			name:    "last page",
+			query:   "limit=2&after=20250101_110000",
+			wantIDs: []string{"20250101_100000"},
+		},
+		{
+			name:       "ascending first page",
+			query:      "limit=3&order=asc",
+			wantIDs:    []string{"20250101_100000", "20250101_110000", "20250101_120000"},
+			wantCursor: "20250101_120000",
+		},
+		{
+			name:    "ascending last page",
+			query:   "limit=3&order=asc&after=20250101_120000",
+			wantIDs: []string{"20250101_130000", "20250101_140000"},
+		},
+		{
+			name:    "after final run",
+			query:   "after=20250101_100000",
+			wantIDs: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			api.handleGetHistory(rec, httptest.NewRequest(http.MethodGet, "/api/v1/history?"+tt.query, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+			}
+
+			var page HistoryPage
+			if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+				t.Fatal(err)
+			}
+
+			ids := make([]string, 0, len(page.Items))
+			for _, item := range page.Items {
+				ids = append(ids, item.RunID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("items %v, want %v", ids, tt.wantIDs)
+			}
+			if page.NextCursor != tt.wantCursor {
+				t.Errorf("next_cursor %q, want %q", page.NextCursor, tt.wantCursor)
+			}
+			if page.Total != 5 {
+				t.Errorf("total %d, want 5", page.Total)
+			}
+		})
+	}
+}
+
+func TestHandleGetHistoryInvalidParams(t *testing.T) {
+	api := newHistoryAPI(t, "20250101_100000", "20250101_110000")
+
+	for _, query := range []string{"after=20240101_000000", "order=sideways"} {
+		t.Run(query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			api.handleGetHistory(rec, httptest.NewRequest(http.MethodGet, "/api/v1/history?"+query, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body)
+			}
+		})
+	}
+}
+
+func TestFailedSessionReportsErrorStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode string
+		want     int
+	}{
+		{"timeout", &common.LayerError{Layer: 3, Code: common.ErrTimeout, Cause: errors.New("deadline exceeded")}, "timeout", http.StatusGatewayTimeout},
+		{"permission", &common.LayerError{Layer: 2, Code: common.ErrPermissionDenied, Cause: errors.New("raw socket")}, "permission_denied", http.StatusServiceUnavailable},
+		{"config", &common.LayerError{Layer: 1, Code: common.ErrConfigInvalid, Cause: errors.New("bad option")}, "config_invalid", http.StatusBadRequest},
+		{"uncoded", errors.New("disk full"), "", http.StatusInternalServerError},
+	}
+
+	t.Setenv(APISecretEnv, "")
+	config := loadDefaultConfig(t)
+	config.AllowUnauthenticated = true
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			partial := []common.TestResult{{Layer: 1, Name: "Link Test", Status: common.StatusPassed}}
+			defer func(run func(*TestSession, []int) ([]common.TestResult, error)) { runSession = run }(runSession)
+			runSession = func(*TestSession, []int) ([]common.TestResult, error) { return partial, tt.err }
+
+			api := &API{
+				Router:       mux.NewRouter(),
+				Config:       config,
+				Logger:       zap.NewNop(),
+				ActiveTests:  make(map[string]*TestSession),
+				ResultsCache: make(map[string][]common.TestResult),
+				Failures:     make(map[string]*TestFailure),
+				History:      history.NewFileStore(t.TempDir()),
+				broker:       sse.NewBroker(),
+				startTime:    time.Now(),
+			}
+			api.registerRoutes()
+			events, unsubscribe := api.broker.Subscribe()
+			defer unsubscribe()
+
+			serve := func(method, path string, body string) *httptest.ResponseRecorder {
+				rec := httptest.NewRecorder()
+				api.Router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+				return rec
+			}
+
+			rec := serve(http.MethodPost, "/api/v1/tests", `{"layers":[1]}`)
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("POST /api/v1/tests returned %d: %s", rec.Code, rec.Body)
+			}
+			var created map[string]string
+			if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil {
+				t.Fatal(err)
+			}
+			id := created["id"]
+
+			// The session fails after the 201, so wait for it to complete
+			timeout := time.After(5 * time.Second)
+			for completed := false; !completed; {
+				select {
+				case event := <-events:
+					completed = event.Name == EventTestCompleted
+				case <-timeout:
+					t.Fatal("session did not complete")
+				}
+			}
+
+			rec = serve(http.MethodGet, "/api/v1/tests/"+id, "")
+			var info struct {
+				Status     string `json:"status"`
+				Error      string `json:"error"`
+				Code       string `json:"code"`
+				HTTPStatus int    `json:"http_status"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+				t.Fatalf("invalid GET /api/v1/tests/%s body %q: %v", id, rec.Body, err)
+			}
+			if rec.Code != http.StatusOK || info.Status != "failed" || info.Error != tt.err.Error() ||
+				info.Code != tt.wantCode || info.HTTPStatus != tt.want {
+				t.Errorf("GET /api/v1/tests/%s = %d %+v, want failed with code %q and http_status %d",
+					id, rec.Code, info, tt.wantCode, tt.want)
+			}
+
+			rec = serve(http.MethodGet, "/api/v1/tests/"+id+"/results", "")
+			var response TestResultsResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("invalid results body %q: %v", rec.Body, err)
+			}
+			if rec.Code != tt.want {
+				t.Errorf("GET /api/v1/tests/%s/results returned %d, want %d", id, rec.Code, tt.want)
+			}
+			if response.Failure == nil || response.Failure.Code != tt.wantCode || response.Failure.HTTPStatus != tt.want {
+				t.Errorf("results failure = %+v, want code %q and http_status %d", response.Failure, tt.wantCode, tt.want)
+			}
+			if len(response.Results) != len(partial) {
+				t.Errorf("got %d results, want the %d partial results", len(response.Results), len(partial))
+			}
+		})
+	}
+}
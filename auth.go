@@ -0,0 +1,104 @@
+package layers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+// APISecretEnv is the environment variable holding the API signing secret.
+// It takes precedence over Config.APISecret.
+const APISecretEnv = "LAYERS_API_SECRET"
+
+// ResolveAPISecret returns the API signing secret from the environment or config
+func ResolveAPISecret(config *Config) []byte {
+	if secret := os.Getenv(APISecretEnv); secret != "" {
+		return []byte(secret)
+	}
+	if config != nil && config.APISecret != "" {
+		return []byte(config.APISecret)
+	}
+	return nil
+}
+
+// requiresAuth reports whether the /api/v1 endpoints require a Bearer token
+// under config, which they do unless no secret is set and the operator opted out
+func requiresAuth(config *Config) bool {
+	return ResolveAPISecret(config) != nil || !config.AllowUnauthenticated
+}
+
+// GenerateToken mints an HS256 token for subject that expires after expiry
+func GenerateToken(subject string, expiry time.Duration, secret []byte) (string, error) {
+	if len(secret) == 0 {
+		return "", fmt.Errorf("signing secret must not be empty")
+	}
+
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Subject:   subject,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return token, nil
+}
+
+// WithJWTAuth returns middleware that rejects requests without a valid HS256
+// Bearer token signed with the key signingKey returns. The key is looked up
+// on every request, so a secret set or rotated by a config change applies at
+// once. While it returns nil every request is rejected, unless
+// allowUnauthenticated reports that the operator opted out of authentication.
+func WithJWTAuth(signingKey func() []byte, allowUnauthenticated func() bool) mux.MiddlewareFunc {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := signingKey()
+			if key == nil {
+				if allowUnauthenticated != nil && allowUnauthenticated() {
+					next.ServeHTTP(w, r)
+					return
+				}
+				writeUnauthorized(w, "no API secret is configured")
+				return
+			}
+			keyFunc := func(*jwt.Token) (interface{}, error) {
+				return key, nil
+			}
+
+			scheme, tokenString, found := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") || tokenString == "" {
+				writeUnauthorized(w, "missing or malformed Authorization header")
+				return
+			}
+
+			if _, err := parser.Parse(strings.TrimSpace(tokenString), keyFunc); err != nil {
+				writeUnauthorized(w, fmt.Sprintf("invalid token: %v", err))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeUnauthorized sends a 401 with the API's JSON error body
+func writeUnauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer realm="layers"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
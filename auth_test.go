@@ -0,0 +1,180 @@
+package layers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/history"
+)
+
+func TestWithJWTAuth(t *testing.T) {
+	secret := []byte("test-secret")
+	handler := WithJWTAuth(func() []byte { return secret }, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	valid, err := GenerateToken("tester", time.Hour, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := GenerateToken("tester", -time.Minute, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := GenerateToken("tester", time.Hour, []byte("other-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{"valid", "Bearer " + valid, http.StatusNoContent},
+		{"lowercase scheme", "bearer " + valid, http.StatusNoContent},
+		{"expired", "Bearer " + expired, http.StatusUnauthorized},
+		{"malformed", "Bearer not.a.token", http.StatusUnauthorized},
+		{"wrong key", "Bearer " + otherKey, http.StatusUnauthorized},
+		{"wrong scheme", "Basic " + valid, http.StatusUnauthorized},
+		{"missing", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/tests", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestWithJWTAuthReadsSecretPerRequest(t *testing.T) {
+	var mu sync.Mutex
+	var secret []byte
+	handler := WithJWTAuth(func() []byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return secret
+	}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tests", nil))
+		return rec.Code
+	}
+
+	if code := serve(); code != http.StatusUnauthorized {
+		t.Errorf("status %d without a secret, want %d", code, http.StatusUnauthorized)
+	}
+
+	// A secret set later, as by a config reload, applies to the next request
+	mu.Lock()
+	secret = []byte("reloaded-secret")
+	mu.Unlock()
+	if code := serve(); code != http.StatusUnauthorized {
+		t.Errorf("status %d after a secret was set, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestWithJWTAuthAllowUnauthenticated(t *testing.T) {
+	var secret []byte
+	allow := false
+	handler := WithJWTAuth(func() []byte { return secret }, func() bool { return allow })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/tests", nil))
+		return rec.Code
+	}
+
+	if code := serve(); code != http.StatusUnauthorized {
+		t.Errorf("status %d without a secret or opt-out, want %d", code, http.StatusUnauthorized)
+	}
+
+	allow = true
+	if code := serve(); code != http.StatusNoContent {
+		t.Errorf("status %d with allow_unauthenticated, want the request let through", code)
+	}
+
+	// A configured secret still applies when the opt-out is set
+	secret = []byte("test-secret")
+	if code := serve(); code != http.StatusUnauthorized {
+		t.Errorf("status %d with a secret and allow_unauthenticated, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestAPIRejectsConfigUpdateWithoutSecret(t *testing.T) {
+	t.Setenv(APISecretEnv, "")
+	config := loadDefaultConfig(t)
+	config.APISecret = ""
+
+	api := &API{
+		Router:    mux.NewRouter(),
+		Config:    config,
+		Logger:    zap.NewNop(),
+		History:   history.NewFileStore(t.TempDir()),
+		startTime: time.Now(),
+	}
+	api.registerRoutes()
+
+	body := `{"api_secret":"attacker-secret"}`
+	rec := httptest.NewRecorder()
+	api.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/api/v1/config", strings.NewReader(body)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("PUT /api/v1/config without a secret returned %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if api.currentConfig().APISecret != "" {
+		t.Error("unauthenticated request changed the API secret")
+	}
+}
+
+func TestUpdateConfigKeepsAllowUnauthenticated(t *testing.T) {
+	t.Setenv(APISecretEnv, "")
+	config := loadDefaultConfig(t)
+	config.APISecret = "test-secret"
+
+	api := &API{
+		Config:     config,
+		Logger:     zap.NewNop(),
+		configPath: filepath.Join(t.TempDir(), "config.json"),
+	}
+
+	update := *config
+	update.AllowUnauthenticated = true
+	body, err := json.Marshal(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	api.handleUpdateConfig(rec, httptest.NewRequest(http.MethodPut, "/api/v1/config", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /api/v1/config returned %d: %s", rec.Code, rec.Body)
+	}
+	if api.currentConfig().AllowUnauthenticated {
+		t.Error("a client switched off authentication through the config endpoint")
+	}
+}
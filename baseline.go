@@ -0,0 +1,117 @@
+package layers
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/common"
+)
+
+// WithBaseline compares the session's results against the stored run
+// runID, overriding Config.BaselineRunID. An empty runID disables the
+// comparison.
+func WithBaseline(runID string) SessionOption {
+	return func(ts *TestSession) {
+		ts.BaselineRunID = runID
+	}
+}
+
+// baselineKey identifies the same test in the baseline run
+type baselineKey struct {
+	layer int
+	name  string
+}
+
+// loadBaseline reads the session's baseline run from history. It returns nil
+// when no baseline is set.
+func (ts *TestSession) loadBaseline() ([]common.TestResult, error) {
+	if ts.BaselineRunID == "" {
+		return nil, nil
+	}
+
+	store, err := OpenHistoryStore(ts.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history store: %w", err)
+	}
+	defer store.Close()
+
+	results, err := store.Get(ts.BaselineRunID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load baseline run %s: %w", ts.BaselineRunID, err)
+	}
+
+	ts.Logger.Info("Loaded baseline run",
+		zap.String("baseline_run_id", ts.BaselineRunID),
+		zap.Int("results", len(results)),
+	)
+	return results, nil
+}
+
+// applyBaseline attaches a BaselineDiff to every one of a layer's results
+// that has a counterpart in the session's baseline, matching tests by layer
+// and name. A test that passed in the baseline and no longer does is marked
+// as failed. It runs as each layer completes, before its results are stored
+// or streamed.
+func (ts *TestSession) applyBaseline(layer int, results []common.TestResult) {
+	if len(ts.baseline) == 0 {
+		return
+	}
+
+	regressions := compareWithBaseline(results, ts.baseline, ts.BaselineRunID)
+	if regressions > 0 {
+		ts.Logger.Warn("Tests regressed from baseline run",
+			zap.Int("layer", layer),
+			zap.String("baseline_run_id", ts.BaselineRunID),
+			zap.Int("regressions", regressions),
+		)
+	}
+}
+
+// compareWithBaseline diffs results against baseline, recursing into
+// sub-results, and returns the number of regressions
+func compareWithBaseline(results, baseline []common.TestResult, baselineRunID string) int {
+	byKey := make(map[baselineKey]*common.TestResult, len(baseline))
+	for i := range baseline {
+		key := baselineKey{baseline[i].Layer, baseline[i].Name}
+		if _, ok := byKey[key]; !ok {
+			byKey[key] = &baseline[i]
+		}
+	}
+
+	regressions := 0
+	for i := range results {
+		result := &results[i]
+		previous, ok := byKey[baselineKey{result.Layer, result.Name}]
+		if !ok {
+			continue
+		}
+
+		// A regressed sub-test fails its parent too
+		subRegressions := compareWithBaseline(result.SubResults, previous.SubResults, baselineRunID)
+		regressions += subRegressions
+		if subRegressions > 0 {
+			for _, sub := range result.SubResults {
+				result.Status = common.MaxSeverity(result.Status, sub.Status)
+			}
+		}
+
+		diff := &common.BaselineDiff{
+			PreviousStatus:   previous.Status,
+			StatusChanged:    result.Status != previous.Status,
+			PacketLossChange: result.Metrics.PacketLoss - previous.Metrics.PacketLoss,
+		}
+		if result.Metrics.Latency > 0 && previous.Metrics.Latency > 0 {
+			diff.LatencyDeltaMs = (result.Metrics.Latency - previous.Metrics.Latency).Milliseconds()
+		}
+		result.BaselineDiff = diff
+
+		if diff.StatusChanged && previous.Status == common.StatusPassed {
+			result.Message = fmt.Sprintf("Regressed from %s in baseline run %s: %s",
+				previous.Status, baselineRunID, result.Message)
+			result.Status = common.StatusFailed
+			regressions++
+		}
+	}
+	return regressions
+}
@@ -0,0 +1,67 @@
+package layers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ghostshell/app/layers/common"
+)
+
+func TestSubResultRegressionFailsParent(t *testing.T) {
+	baseline := []common.TestResult{{
+		Layer:  7,
+		Name:   "HTTP Test",
+		Status: common.StatusPassed,
+		SubResults: []common.TestResult{
+			{Layer: 7, Name: "HTTP (a)", Status: common.StatusPassed},
+			{Layer: 7, Name: "HTTP (b)", Status: common.StatusPassed},
+		},
+	}}
+	results := []common.TestResult{{
+		Layer:  7,
+		Name:   "HTTP Test",
+		Status: common.StatusPassed,
+		SubResults: []common.TestResult{
+			{Layer: 7, Name: "HTTP (a)", Status: common.StatusPassed},
+			{Layer: 7, Name: "HTTP (b)", Status: common.StatusWarning, Message: "slow"},
+		},
+	}}
+
+	if regressions := compareWithBaseline(results, baseline, "run-1"); regressions != 2 {
+		t.Errorf("regressions = %d, want the sub-test and its parent", regressions)
+	}
+	parent := results[0]
+	if parent.SubResults[1].Status != common.StatusFailed {
+		t.Errorf("regressed sub-test reported %s, want Failed", parent.SubResults[1].Status)
+	}
+	if parent.Status != common.StatusFailed || !strings.HasPrefix(parent.Message, "Regressed from Passed in baseline run run-1") {
+		t.Errorf("parent reported %s %q, want it failed as a regression", parent.Status, parent.Message)
+	}
+	if parent.BaselineDiff == nil || !parent.BaselineDiff.StatusChanged {
+		t.Errorf("parent baseline diff %+v, want a status change", parent.BaselineDiff)
+	}
+}
+
+func TestBaselineAppliedBeforeStreaming(t *testing.T) {
+	ts := newTestSession(t)
+	ts.BaselineRunID = "run-1"
+	ts.baseline = []common.TestResult{{Layer: 1, Name: "failing", Status: common.StatusPassed}}
+
+	var streamed []common.TestResult
+	_, err := ts.runSequentialTests(context.Background(), map[int]common.LayerRunner{
+		1: &stubRunner{name: "failing", run: func(ctx context.Context) ([]common.TestResult, error) {
+			return []common.TestResult{{Layer: 1, Name: "failing", Status: common.StatusWarning}}, nil
+		}},
+	}, func(result common.TestResult) { streamed = append(streamed, result) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(streamed) != 1 || streamed[0].BaselineDiff == nil || streamed[0].Status != common.StatusFailed {
+		t.Fatalf("streamed %+v, want the regression applied", streamed)
+	}
+	if stored := ts.Results[1]; len(stored) != 1 || stored[0].Status != common.StatusFailed {
+		t.Errorf("stored %+v, want the regression applied", stored)
+	}
+}
@@ -0,0 +1,106 @@
+package layers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"ghostshell/app/layers/common"
+)
+
+// CIPlatform identifies the continuous integration system the tests run under
+type CIPlatform string
+
+const (
+	CINone          CIPlatform = ""
+	CIGitHubActions CIPlatform = "github"
+	CIGitLab        CIPlatform = "gitlab"
+	CIJenkins       CIPlatform = "jenkins"
+)
+
+// DetectCIPlatform inspects the environment for well-known CI variables
+func DetectCIPlatform() CIPlatform {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return CIGitHubActions
+	case os.Getenv("GITLAB_CI") != "":
+		return CIGitLab
+	case os.Getenv("JENKINS_URL") != "":
+		return CIJenkins
+	default:
+		return CINone
+	}
+}
+
+// CIFormatter renders test results as annotations for a CI platform
+type CIFormatter struct {
+	Platform CIPlatform
+}
+
+// NewCIFormatter creates a formatter for the detected CI platform
+func NewCIFormatter() *CIFormatter {
+	return &CIFormatter{Platform: DetectCIPlatform()}
+}
+
+// Format returns the annotation line for a result, or "" if the result
+// does not need one
+func (f *CIFormatter) Format(result common.TestResult) string {
+	var level, label string
+	switch result.Status {
+	case common.StatusFailed:
+		level, label = "error", "Error"
+	case common.StatusWarning:
+		level, label = "warning", "Warning"
+	default:
+		return ""
+	}
+
+	msg := annotationMessage(result)
+
+	switch f.Platform {
+	case CIGitHubActions:
+		return fmt.Sprintf("::%s file=layer%d::%s", level, result.Layer, escapeGitHubAnnotation(msg))
+	case CIGitLab:
+		return fmt.Sprintf("[L%d %s] %s", result.Layer, label, msg)
+	default:
+		return fmt.Sprintf("%s: [Layer %d] %s", strings.ToUpper(level), result.Layer, msg)
+	}
+}
+
+// Write emits an annotation for every failed or warning result, including sub-results
+func (f *CIFormatter) Write(results []common.TestResult, writer io.Writer) error {
+	for _, result := range results {
+		if line := f.Format(result); line != "" {
+			if _, err := fmt.Fprintln(writer, line); err != nil {
+				return fmt.Errorf("failed to write CI annotation: %w", err)
+			}
+		}
+		if err := f.Write(result.SubResults, writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCIAnnotations writes annotations for the detected CI platform to writer
+func WriteCIAnnotations(results []common.TestResult, writer io.Writer) error {
+	return NewCIFormatter().Write(results, writer)
+}
+
+// annotationMessage builds a single-line message naming the test
+func annotationMessage(result common.TestResult) string {
+	msg, _, _ := strings.Cut(strings.TrimSpace(result.Message), "\n")
+	if msg == "" {
+		return result.Name
+	}
+	if result.Name == "" || strings.Contains(msg, result.Name) {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", result.Name, msg)
+}
+
+// escapeGitHubAnnotation escapes characters that GitHub workflow commands treat specially
+func escapeGitHubAnnotation(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
@@ -0,0 +1,108 @@
+package layers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"ghostshell/app/layers/common"
+)
+
+func TestDetectCIPlatform(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want CIPlatform
+	}{
+		{"none", nil, CINone},
+		{"github", map[string]string{"GITHUB_ACTIONS": "true"}, CIGitHubActions},
+		{"github disabled", map[string]string{"GITHUB_ACTIONS": "false"}, CINone},
+		{"gitlab", map[string]string{"GITLAB_CI": "true"}, CIGitLab},
+		{"jenkins", map[string]string{"JENKINS_URL": "https://ci.example.com/"}, CIJenkins},
+		{"github wins", map[string]string{"GITHUB_ACTIONS": "true", "GITLAB_CI": "true"}, CIGitHubActions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"GITHUB_ACTIONS", "GITLAB_CI", "JENKINS_URL"} {
+				t.Setenv(key, tt.env[key])
+			}
+			if got := DetectCIPlatform(); got != tt.want {
+				t.Errorf("DetectCIPlatform() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCIFormatterFormat(t *testing.T) {
+	failed := common.TestResult{
+		Layer:   3,
+		Name:    "Ping Test",
+		Status:  common.StatusFailed,
+		Message: "100% packet loss\nsecond line",
+	}
+	warning := common.TestResult{
+		Layer:   7,
+		Name:    "HTTP Test",
+		Status:  common.StatusWarning,
+		Message: "HTTP Test slow response",
+	}
+	passed := common.TestResult{Layer: 1, Name: "Link Test", Status: common.StatusPassed, Message: "ok"}
+
+	tests := []struct {
+		platform CIPlatform
+		result   common.TestResult
+		want     string
+	}{
+		{CIGitHubActions, failed, "::error file=layer3::Ping Test: 100%25 packet loss"},
+		{CIGitHubActions, warning, "::warning file=layer7::HTTP Test slow response"},
+		{CIGitLab, failed, "[L3 Error] Ping Test: 100% packet loss"},
+		{CIGitLab, warning, "[L7 Warning] HTTP Test slow response"},
+		{CIJenkins, failed, "ERROR: [Layer 3] Ping Test: 100% packet loss"},
+		{CINone, warning, "WARNING: [Layer 7] HTTP Test slow response"},
+		{CIGitHubActions, passed, ""},
+		{CIGitLab, passed, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.platform)+"/"+tt.result.Status.String(), func(t *testing.T) {
+			f := &CIFormatter{Platform: tt.platform}
+			if got := f.Format(tt.result); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteCIAnnotations(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITLAB_CI", "")
+	t.Setenv("JENKINS_URL", "")
+
+	results := []common.TestResult{
+		{
+			Layer:   4,
+			Name:    "Transport Layer Tests",
+			Status:  common.StatusFailed,
+			Message: "Layer 4 tests failed",
+			SubResults: []common.TestResult{
+				{Layer: 4, Name: "TCP Test", Status: common.StatusPassed, Message: "connected"},
+				{Layer: 4, Name: "UDP Test", Status: common.StatusFailed, Message: "timed out"},
+			},
+		},
+		{Layer: 5, Name: "Session Layer Tests", Status: common.StatusPassed, Message: "ok"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCIAnnotations(results, &buf); err != nil {
+		t.Fatalf("WriteCIAnnotations() error = %v", err)
+	}
+
+	want := []string{
+		"::error file=layer4::Transport Layer Tests: Layer 4 tests failed",
+		"::error file=layer4::UDP Test: timed out",
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("annotations =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers"
+	"ghostshell/app/layers/schedule"
+	"ghostshell/app/layers/visualization"
+)
+
+// apiPrefix is where the REST API is served on the dashboard's address
+const apiPrefix = "/api/v1/"
+
+// startAPI serves the REST API for config, loaded from configPath, under
+// apiPrefix on the dashboard's address, with a scheduler behind its schedule
+// endpoints. Scheduled runs are shown on the dashboard, which counts down to
+// the next one. It must be called before the visualizer is started; the
+// returned function stops the scheduler.
+func startAPI(logger *zap.Logger, vis *visualization.Visualizer, config *layers.Config, configPath string) (func(), error) {
+	if config == nil {
+		return nil, fmt.Errorf("the REST API needs a config file, %s does not exist", configPath)
+	}
+
+	api, err := layers.NewAPI(config)
+	if err != nil {
+		return nil, err
+	}
+	stopWatch, err := api.WatchConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduler := schedule.NewScheduler(logger, func(result schedule.JobResult) {
+		if result.Err != nil {
+			logger.Error("Scheduled run failed", zap.String("job_id", result.JobID), zap.Error(result.Err))
+		}
+		if len(result.Results) > 0 {
+			vis.UpdateResults(result.Results)
+		}
+	})
+	if err := scheduler.Start(); err != nil {
+		stopWatch()
+		return nil, err
+	}
+	api.Scheduler = scheduler
+
+	vis.Handle(apiPrefix, api.Router)
+	vis.SetScheduleURL(apiPrefix + "schedule")
+	logger.Info("Serving REST API on the dashboard address", zap.String("prefix", apiPrefix))
+
+	return func() {
+		scheduler.Stop()
+		stopWatch()
+	}, nil
+}
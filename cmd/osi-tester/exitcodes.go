@@ -0,0 +1,43 @@
+package main
+
+import "ghostshell/app/layers/common"
+
+// Process exit codes, so CI scripts can tell test results apart from
+// problems running the tool
+const (
+	// ExitOK is emitted when every test passed or was skipped
+	ExitOK = 0
+	// ExitTestFailure is emitted when at least one test failed
+	ExitTestFailure = 1
+	// ExitTestWarning is emitted when no test failed but at least one warned
+	ExitTestWarning = 2
+	// ExitConfigError is emitted for invalid flags, input or logger setup
+	// before any test has run
+	ExitConfigError = 3
+	// ExitRuntimeError is emitted when the tests or dashboard could not be run
+	ExitRuntimeError = 4
+	// ExitSignatureMismatch is emitted by --verify-report when the report
+	// does not match its signature
+	ExitSignatureMismatch = 2
+)
+
+// exitCodeForResults returns the exit code for the most severe result,
+// including sub-results
+func exitCodeForResults(results []common.TestResult) int {
+	code := ExitOK
+	for _, result := range results {
+		switch result.Status {
+		case common.StatusFailed:
+			return ExitTestFailure
+		case common.StatusWarning:
+			code = ExitTestWarning
+		}
+		switch exitCodeForResults(result.SubResults) {
+		case ExitTestFailure:
+			return ExitTestFailure
+		case ExitTestWarning:
+			code = ExitTestWarning
+		}
+	}
+	return code
+}
@@ -3,12 +3,15 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -38,17 +41,14 @@ func openBrowser(url string) error {
 	return exec.Command(cmd, args...).Start()
 }
 
-func promptForLayerSelection() ([]int, error) {
+// promptForLayerSelection asks which of the available layers to test
+func promptForLayerSelection(available []int) ([]int, error) {
 	fmt.Println("\nOSI Layer Test Selection")
 	fmt.Println("------------------------")
 	fmt.Println("Available layers:")
-	fmt.Println("1. Physical Layer")
-	fmt.Println("2. Data Link Layer")
-	fmt.Println("3. Network Layer")
-	fmt.Println("4. Transport Layer")
-	fmt.Println("5. Session Layer")
-	fmt.Println("6. Presentation Layer")
-	fmt.Println("7. Application Layer")
+	for _, layer := range available {
+		fmt.Printf("%d. %s\n", layer, layerName(layer))
+	}
 	fmt.Println("0. Test All Layers")
 	fmt.Print("\nEnter layer numbers to test (comma-separated, e.g. 1,2,3 or 0 for all): ")
 
@@ -60,7 +60,7 @@ func promptForLayerSelection() ([]int, error) {
 
 	input = strings.TrimSpace(input)
 	if input == "0" {
-		return []int{1, 2, 3, 4, 5, 6, 7}, nil
+		return available, nil
 	}
 
 	var selectedLayers []int
@@ -69,8 +69,8 @@ func promptForLayerSelection() ([]int, error) {
 		if err != nil {
 			return nil, fmt.Errorf("invalid layer number: %s", s)
 		}
-		if layer < 1 || layer > 7 {
-			return nil, fmt.Errorf("layer number must be between 1 and 7: %d", layer)
+		if !slices.Contains(available, layer) {
+			return nil, fmt.Errorf("layer number must be one of %v: %d", available, layer)
 		}
 		selectedLayers = append(selectedLayers, layer)
 	}
@@ -78,32 +78,207 @@ func promptForLayerSelection() ([]int, error) {
 	return selectedLayers, nil
 }
 
+// runCI runs every available layer without prompting, writes CI annotations
+// to stdout and a JUnit report for the platform to collect. It returns the
+// exit code.
+func runCI(logger *zap.Logger, platform layers.CIPlatform, available []int, opts ...layers.SessionOption) int {
+	logger.Info("CI environment detected, running non-interactively", zap.String("platform", string(platform)))
+
+	results, err := layers.RunLayerTests(available, opts...)
+	if err != nil {
+		logger.Error("Failed to run layer tests", zap.Error(err))
+		return ExitRuntimeError
+	}
+
+	if err := layers.WriteCIAnnotations(results, os.Stdout); err != nil {
+		logger.Error("Failed to write CI annotations", zap.Error(err))
+	}
+	printSummary(os.Stdout, layers.Summarize(results))
+
+	generator := common.NewReportGenerator(results, "ci_layer_tests")
+	generator.SigningKey, err = layers.ResolveReportSigningKey(nil)
+	var path string
+	if err == nil {
+		path, err = generator.GenerateReport(common.ReportJUnit)
+	}
+	if err != nil {
+		logger.Error("Failed to write JUnit report", zap.Error(err))
+	} else {
+		fmt.Printf("JUnit report written to %s\n", path)
+	}
+
+	return exitCodeForResults(results)
+}
+
+// analysisWindow is how many stored runs the dashboard analyses for
+// regressions, the same default as the API's
+const analysisWindow = 10
+
+// loadOptionalConfig loads the config file at path, returning nil when there
+// is none
+func loadOptionalConfig(path string) (*layers.Config, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return layers.LoadConfig(path)
+}
+
+// watchMode runs the selected layers every interval until ctx is cancelled,
+// tagging each run's results with its iteration number and pushing them to
+// the visualizer. A run in progress is allowed to finish.
+func watchMode(ctx context.Context, selectedLayers []int, vis *visualization.Visualizer, interval time.Duration, sessionOpts ...layers.SessionOption) {
+	for iteration := 1; ; iteration++ {
+		fmt.Printf("Starting run %d for layers: %v\n", iteration, selectedLayers)
+
+		opts := append([]layers.SessionOption(nil), sessionOpts...)
+		if stream := vis.ResultStreamCallback(); stream != nil {
+			opts = append(opts, layers.WithResultStream(func(result common.TestResult) {
+				result.RunIteration = iteration
+				stream(result)
+			}))
+		}
+
+		results, err := layers.RunLayerTests(selectedLayers, opts...)
+		if err != nil {
+			common.Logger.Error("Failed to run layer tests", zap.Int("iteration", iteration), zap.Error(err))
+		} else {
+			for i := range results {
+				results[i].RunIteration = iteration
+			}
+			vis.UpdateResults(results)
+		}
+
+		fmt.Printf("Run %d completed. Next run in %v, press Ctrl+C to exit.\n", iteration, interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
 func main() {
-	// Parse command line flags
+	// Parse command line flags, exiting with ExitConfigError on bad flags
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
 	addr := flag.String("addr", ":8080", "Address to serve visualization dashboard")
-	flag.Parse()
+	watch := flag.Bool("watch", false, "Re-run the selected layers continuously")
+	interval := flag.Duration("interval", 60*time.Second, "Time to wait between runs in watch mode")
+	stream := flag.Bool("stream", true, "Show each layer's results on the dashboard as soon as it completes")
+	tags := flag.String("tags", "", "Comma-separated list of tags; only layers with at least one of them are tested")
+	baseline := flag.String("baseline", "", "History run ID to compare results against; tests that passed in it and no longer do fail")
+	noTUI := flag.Bool("no-tui", false, "Use the plain text prompt instead of the interactive terminal UI")
+	verify := flag.Bool("verify-report", false, "Check a report against its HMAC signature and exit: 0 if it matches, 2 if not")
+	reportFile := flag.String("file", "", "Report to check with --verify-report")
+	sigFile := flag.String("sig", "", "Signature to check with --verify-report (default: the report path plus .sig)")
+	configPath := flag.String("config", "config.json", "Optional config file: report_signing_key for --verify-report, plugin layers, the history the dashboard analyses, and the config -api serves")
+	serveAPI := flag.Bool("api", false, "Serve the REST API and test scheduler under /api/v1 on the dashboard address, using -config")
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(ExitOK)
+		}
+		os.Exit(ExitConfigError)
+	}
+
+	if *verify {
+		os.Exit(verifyReport(*reportFile, *sigFile, *configPath))
+	}
 
 	// Initialize logger
 	logger, cleanup, err := layers.InitializeLogger()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
-		os.Exit(1)
+		os.Exit(ExitConfigError)
 	}
 	defer cleanup()
 
 	common.Logger = logger
 
-	// Get layer selection from user
-	selectedLayers, err := promptForLayerSelection()
+	if *watch && *interval <= 0 {
+		logger.Error("Watch interval must be greater than 0", zap.Duration("interval", *interval))
+		cleanup()
+		os.Exit(ExitConfigError)
+	}
+
+	// The config file is optional unless the REST API is served
+	config, err := loadOptionalConfig(*configPath)
+	if err != nil {
+		logger.Error("Failed to load config", zap.String("path", *configPath), zap.Error(err))
+		cleanup()
+		os.Exit(ExitConfigError)
+	}
+
+	var sessionOpts []layers.SessionOption
+	if selectedTags := layers.ParseTags(*tags); len(selectedTags) > 0 {
+		sessionOpts = append(sessionOpts, layers.WithTags(selectedTags))
+	}
+	if *baseline != "" {
+		sessionOpts = append(sessionOpts, layers.WithBaseline(*baseline))
+	}
+
+	// Offer the config's plugin layers alongside the built-in ones
+	available := []int{1, 2, 3, 4, 5, 6, 7}
+	if config != nil && len(config.Plugins) > 0 {
+		available = config.AllLayers()
+		sessionOpts = append(sessionOpts, layers.WithPlugins(config.Plugins, config.AllowPluginOverride))
+	}
+
+	// Skip the prompt and dashboard when running under a CI system
+	if platform := layers.DetectCIPlatform(); platform != layers.CINone {
+		code := runCI(logger, platform, available, sessionOpts...)
+		cleanup()
+		os.Exit(code)
+	}
+
+	// Get layer selection from user, with the terminal UI when interactive
+	tui := useTUI(*noTUI)
+	var selectedLayers []int
+	if tui {
+		selectedLayers, err = tuiLayerSelection(available)
+	} else {
+		selectedLayers, err = promptForLayerSelection(available)
+	}
+	if errors.Is(err, errSelectionCancelled) {
+		cleanup()
+		os.Exit(ExitOK)
+	}
 	if err != nil {
 		logger.Error("Failed to get layer selection", zap.Error(err))
-		os.Exit(1)
+		cleanup()
+		os.Exit(ExitConfigError)
 	}
 
 	// Create visualizer
 	vis, err := visualization.NewVisualizer(logger)
 	if err != nil {
-		logger.Fatal("Failed to create visualizer", zap.Error(err))
+		logger.Error("Failed to create visualizer", zap.Error(err))
+		cleanup()
+		os.Exit(ExitRuntimeError)
+	}
+	vis.EnableStreaming(*stream)
+
+	// Analyse stored runs for regressions when history is enabled
+	if config != nil && config.SaveHistoricalData {
+		store, err := layers.OpenHistoryStore(config)
+		if err != nil {
+			logger.Error("Failed to open history store", zap.Error(err))
+			cleanup()
+			os.Exit(ExitConfigError)
+		}
+		defer store.Close()
+		vis.EnableAnalysis(store, analysisWindow, config.AlertThresholds)
+	}
+
+	// Serve the REST API next to the dashboard, which shows scheduled runs
+	if *serveAPI {
+		stopAPI, err := startAPI(logger, vis, config, *configPath)
+		common.Logger = logger
+		if err != nil {
+			logger.Error("Failed to start REST API", zap.Error(err))
+			cleanup()
+			os.Exit(ExitConfigError)
+		}
+		defer stopAPI()
 	}
 
 	// Create context that can be cancelled
@@ -142,15 +317,32 @@ func main() {
 	fmt.Printf("\nStarting OSI layer tests for layers: %v\n", selectedLayers)
 	fmt.Printf("View results at: %s\n\n", url)
 
+	if *watch {
+		watchMode(ctx, selectedLayers, vis, *interval, sessionOpts...)
+		if err := vis.Stop(); err != nil {
+			logger.Error("Failed to stop visualizer", zap.Error(err))
+		}
+		return
+	}
+
 	// Run layer tests
-	results, err := layers.RunLayerTests(selectedLayers)
+	var results []common.TestResult
+	if tui {
+		results, err = tuiRunTests(selectedLayers, vis.ResultStreamCallback(), cancel, sessionOpts...)
+		common.Logger = logger
+	} else {
+		results, err = layers.RunLayerTests(selectedLayers, append(sessionOpts, layers.WithResultStream(vis.ResultStreamCallback()))...)
+	}
 	if err != nil {
 		logger.Error("Failed to run layer tests", zap.Error(err))
-		os.Exit(1)
+		vis.Stop()
+		cleanup()
+		os.Exit(ExitRuntimeError)
 	}
 
 	// Update visualizer with results
 	vis.UpdateResults(results)
+	printSummary(os.Stdout, layers.Summarize(results))
 
 	fmt.Println("\nTests completed. Press Ctrl+C to exit.")
 
@@ -161,4 +353,8 @@ func main() {
 	if err := vis.Stop(); err != nil {
 		logger.Error("Failed to stop visualizer", zap.Error(err))
 	}
+
+	// Report the most severe test outcome to the caller
+	cleanup()
+	os.Exit(exitCodeForResults(results))
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"ghostshell/app/layers"
+	"ghostshell/app/layers/common"
+)
+
+// formatLatency renders a latency in milliseconds, or "-" when none was measured
+func formatLatency(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f ms", float64(d.Microseconds())/1000)
+}
+
+// printSummary writes summary as a table with a row per layer and a total row
+func printSummary(w io.Writer, summary layers.SessionSummary) {
+	fmt.Fprintln(w, "\nTest Summary")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LAYER\tSTATUS\tTESTS\tPASSED\tWARNING\tFAILED\tSKIPPED\tMIXED\tAVG LATENCY\tMAX LATENCY\tDURATION")
+
+	layerNums := make([]int, 0, len(summary.LayerSummaries))
+	for layer := range summary.LayerSummaries {
+		layerNums = append(layerNums, layer)
+	}
+	sort.Ints(layerNums)
+	for _, layer := range layerNums {
+		s := summary.LayerSummaries[layer]
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t%s\n",
+			layer, s.Status, s.TotalTests, s.Passed, s.Warning, s.Failed, s.Skipped, s.Mixed,
+			formatLatency(s.AverageLatency), formatLatency(s.MaxLatency), s.Duration.Round(time.Millisecond))
+	}
+	fmt.Fprintf(tw, "Total\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t%s\n",
+		summary.OverallStatus, summary.TotalTests, summary.Passed, summary.Warning, summary.Failed,
+		summary.Skipped, summary.Mixed, formatLatency(summary.AverageLatency), formatLatency(summary.MaxLatency),
+		summary.TotalDuration.Round(time.Millisecond))
+	tw.Flush()
+
+	if summary.WorstLayer != 0 && summary.WorstLayerStatus != common.StatusPassed {
+		fmt.Fprintf(w, "Worst layer: %d (%s)\n", summary.WorstLayer, summary.WorstLayerStatus)
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+
+	"ghostshell/app/layers"
+	"ghostshell/app/layers/common"
+)
+
+// layerNames are the names of the built-in layers 1-7
+var layerNames = []string{
+	"Physical Layer",
+	"Data Link Layer",
+	"Network Layer",
+	"Transport Layer",
+	"Session Layer",
+	"Presentation Layer",
+	"Application Layer",
+}
+
+// layerName returns the name shown for layer; layers above 7 are added by
+// plugins
+func layerName(layer int) string {
+	if layer >= 1 && layer <= len(layerNames) {
+		return layerNames[layer-1]
+	}
+	return "Plugin Layer"
+}
+
+// errSelectionCancelled is returned when the user quits the selection
+var errSelectionCancelled = errors.New("layer selection cancelled")
+
+var (
+	titleStyle   = lipgloss.NewStyle().Bold(true)
+	cursorStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("12")).Bold(true)
+	helpStyle    = lipgloss.NewStyle().Faint(true)
+	passedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	failedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	warningStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+	skippedStyle = lipgloss.NewStyle().Faint(true)
+)
+
+// statusStyle returns the colour a result with status is shown in
+func statusStyle(status common.TestStatus) lipgloss.Style {
+	switch status {
+	case common.StatusPassed:
+		return passedStyle
+	case common.StatusFailed, common.StatusMixed:
+		return failedStyle
+	case common.StatusWarning:
+		return warningStyle
+	}
+	return skippedStyle
+}
+
+// useTUI reports whether the interactive UI can be used: it is not disabled
+// and both stdin and stdout are terminals
+func useTUI(noTUI bool) bool {
+	return !noTUI && term.IsTerminal(os.Stdin.Fd()) && term.IsTerminal(os.Stdout.Fd())
+}
+
+// selectModel is the layer selection checklist. The row after the layers is
+// the confirm button.
+type selectModel struct {
+	cursor    int
+	available []int // Layers offered, in order
+	selected  []bool
+	confirmed bool
+	cancelled bool
+	warning   string
+}
+
+func newSelectModel(available []int) selectModel {
+	return selectModel{available: available, selected: make([]bool, len(available))}
+}
+
+func (m selectModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m selectModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	confirmRow := len(m.available)
+	switch key.String() {
+	case "ctrl+c", "q", "esc":
+		m.cancelled = true
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j", "tab":
+		if m.cursor < confirmRow {
+			m.cursor++
+		}
+	case "a":
+		all := !allSelected(m.selected)
+		for i := range m.selected {
+			m.selected[i] = all
+		}
+	case " ", "enter":
+		if m.cursor < confirmRow {
+			m.selected[m.cursor] = !m.selected[m.cursor]
+			m.warning = ""
+			return m, nil
+		}
+		if len(m.layers()) == 0 {
+			m.warning = "Select at least one layer"
+			return m, nil
+		}
+		m.confirmed = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m selectModel) View() string {
+	if m.confirmed || m.cancelled {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("OSI Layer Test Selection") + "\n\n")
+	for i, layer := range m.available {
+		cursor := "  "
+		if m.cursor == i {
+			cursor = cursorStyle.Render("> ")
+		}
+		check := "[ ]"
+		if m.selected[i] {
+			check = "[x]"
+		}
+		fmt.Fprintf(&b, "%s%s %d. %s\n", cursor, check, layer, layerName(layer))
+	}
+
+	button := "[ Run tests ]"
+	if m.cursor == len(m.available) {
+		button = cursorStyle.Render("> " + button)
+	} else {
+		button = "  " + button
+	}
+	b.WriteString("\n" + button + "\n")
+	if m.warning != "" {
+		b.WriteString(warningStyle.Render(m.warning) + "\n")
+	}
+	b.WriteString("\n" + helpStyle.Render("↑/↓ move • space toggle • a all • enter confirm • q quit") + "\n")
+	return b.String()
+}
+
+// layers returns the selected layer numbers
+func (m selectModel) layers() []int {
+	var selected []int
+	for i, ok := range m.selected {
+		if ok {
+			selected = append(selected, m.available[i])
+		}
+	}
+	return selected
+}
+
+func allSelected(selected []bool) bool {
+	for _, ok := range selected {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// tuiLayerSelection shows the checklist of the available layers and returns
+// the chosen ones
+func tuiLayerSelection(available []int) ([]int, error) {
+	final, err := tea.NewProgram(newSelectModel(available)).Run()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run layer selection: %w", err)
+	}
+	m := final.(selectModel)
+	if m.cancelled {
+		return nil, errSelectionCancelled
+	}
+	return m.layers(), nil
+}
+
+// progressMsg carries a TestSession progress callback into the run UI
+type progressMsg struct {
+	layer  int
+	status string
+}
+
+// resultMsg carries a result streamed as its layer completes
+type resultMsg struct {
+	result common.TestResult
+}
+
+// runDoneMsg is sent once RunLayerTests returns
+type runDoneMsg struct {
+	results []common.TestResult
+	err     error
+}
+
+// layerState is the progress of one layer in the run UI
+type layerState struct {
+	status  string
+	results []common.TestResult
+}
+
+// runModel shows a spinner per layer while the tests run and each layer's
+// results once it completes
+type runModel struct {
+	layers      []int
+	states      map[int]*layerState
+	spinner     spinner.Model
+	updates     <-chan tea.Msg
+	done        bool
+	interrupted bool
+}
+
+func newRunModel(selected []int, updates <-chan tea.Msg) runModel {
+	states := make(map[int]*layerState, len(selected))
+	for _, layer := range selected {
+		states[layer] = &layerState{status: "Pending"}
+	}
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	return runModel{layers: selected, states: states, spinner: s, updates: updates}
+}
+
+// waitForUpdate delivers the next message from the running tests
+func waitForUpdate(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-updates
+	}
+}
+
+func (m runModel) Init() tea.Cmd {
+	return tea.Batch(m.spinner.Tick, waitForUpdate(m.updates))
+}
+
+func (m runModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			m.interrupted = true
+			return m, tea.Quit
+		}
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case progressMsg:
+		if state, ok := m.states[msg.layer]; ok {
+			state.status = msg.status
+		}
+		return m, waitForUpdate(m.updates)
+	case resultMsg:
+		if state, ok := m.states[msg.result.Layer]; ok {
+			state.status = "Complete"
+			state.results = append(state.results, msg.result)
+		}
+		return m, waitForUpdate(m.updates)
+	case runDoneMsg:
+		m.done = true
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m runModel) View() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(fmt.Sprintf("Running OSI layer tests for layers %v", m.layers)) + "\n\n")
+	for _, layer := range m.layers {
+		state := m.states[layer]
+		name := fmt.Sprintf("Layer %d: %s", layer, layerName(layer))
+
+		if len(state.results) == 0 {
+			icon := "  "
+			if state.status != "Pending" && state.status != "Complete" {
+				icon = m.spinner.View()
+			}
+			fmt.Fprintf(&b, "%s%s %s\n", icon, name, helpStyle.Render(state.status))
+			continue
+		}
+
+		statuses := make([]common.TestStatus, len(state.results))
+		for i, result := range state.results {
+			statuses[i] = result.Status
+		}
+		worst := common.MaxSeverity(statuses...)
+		fmt.Fprintf(&b, "%s %s\n", statusStyle(worst).Render("●"), statusStyle(worst).Render(name+" "+worst.String()))
+		for _, result := range state.results {
+			writeResultLines(&b, result, "    ")
+		}
+	}
+
+	if !m.done && !m.interrupted {
+		b.WriteString("\n" + helpStyle.Render("ctrl+c cancel") + "\n")
+	}
+	return b.String()
+}
+
+// writeResultLines writes result and its sub-results, one per line
+func writeResultLines(b *strings.Builder, result common.TestResult, indent string) {
+	line := fmt.Sprintf("%s%-7s %s", indent, result.Status, result.Name)
+	fmt.Fprintln(b, statusStyle(result.Status).Render(line))
+	for _, sub := range result.SubResults {
+		writeResultLines(b, sub, indent+"  ")
+	}
+}
+
+// tuiRunTests runs the selected layers while showing their progress and
+// results. stream, if not nil, also receives each result. The UI stops when
+// the run completes or on ctrl+c, which calls cancel as a SIGINT would; the
+// run is still waited for.
+func tuiRunTests(selected []int, stream func(common.TestResult), cancel context.CancelFunc, opts ...layers.SessionOption) ([]common.TestResult, error) {
+	updates := make(chan tea.Msg, 64)
+	quit := make(chan struct{})
+	send := func(msg tea.Msg) {
+		select {
+		case updates <- msg:
+		case <-quit:
+		}
+	}
+
+	opts = append(opts,
+		// Keep session logs off the terminal while the UI owns it
+		layers.WithFileLogging(),
+		layers.WithProgress(func(layer, completed, total int, status string) {
+			send(progressMsg{layer: layer, status: status})
+		}),
+		layers.WithResultStream(func(result common.TestResult) {
+			if stream != nil {
+				stream(result)
+			}
+			send(resultMsg{result: result})
+		}),
+	)
+
+	done := make(chan runDoneMsg, 1)
+	go func() {
+		results, err := layers.RunLayerTests(selected, opts...)
+		done <- runDoneMsg{results: results, err: err}
+		send(runDoneMsg{results: results, err: err})
+	}()
+
+	final, uiErr := tea.NewProgram(newRunModel(selected, updates)).Run()
+	close(quit)
+	if uiErr != nil {
+		fmt.Fprintf(os.Stderr, "Progress display failed: %v\n", uiErr)
+	} else if final.(runModel).interrupted {
+		cancel()
+		fmt.Println("Cancelled, waiting for running tests to finish...")
+	}
+
+	run := <-done
+	return run.results, run.err
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"ghostshell/app/layers"
+	"ghostshell/app/layers/common"
+)
+
+// verifyReport checks the HMAC signature of the report at reportPath and
+// returns the exit code. The key comes from LAYERS_REPORT_SIGNING_KEY or the
+// report_signing_key of the config file at configPath, if it exists.
+func verifyReport(reportPath, sigPath, configPath string) int {
+	if reportPath == "" {
+		fmt.Fprintln(os.Stderr, "--verify-report requires --file")
+		return ExitConfigError
+	}
+	if sigPath == "" {
+		sigPath = reportPath + common.SignatureExt
+	}
+
+	var config *layers.Config
+	if _, err := os.Stat(configPath); err == nil {
+		if config, err = layers.LoadConfig(configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+			return ExitConfigError
+		}
+	}
+	key, err := layers.ResolveReportSigningKey(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid report signing key: %v\n", err)
+		return ExitConfigError
+	}
+	if key == nil {
+		fmt.Fprintf(os.Stderr, "No report signing key: set report_signing_key in %s or %s\n", configPath, layers.ReportSigningKeyEnv)
+		return ExitConfigError
+	}
+
+	valid, err := common.VerifyReport(reportPath, sigPath, key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to verify report: %v\n", err)
+		return ExitRuntimeError
+	}
+	if !valid {
+		fmt.Printf("Signature mismatch: %s has been modified or was not signed with this key\n", reportPath)
+		return ExitSignatureMismatch
+	}
+	fmt.Printf("Signature valid: %s\n", reportPath)
+	return ExitOK
+}
@@ -0,0 +1,946 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DiagnosticsPayload is the diagnostic data of a test result. One field is
+// set, normally the one for the result's layer; Generic holds anything else.
+// It marshals to the set value alone, so the JSON is the same flat object
+// the layers reported before the payload was typed.
+type DiagnosticsPayload struct {
+	Physical     *PhysicalDiagnostics
+	DataLink     *DataLinkDiagnostics
+	Network      *NetworkDiagnostics
+	Transport    *TransportDiagnostics
+	Session      *SessionDiagnostics
+	Presentation *PresentationDiagnostics
+	Application  *ApplicationDiagnostics
+	Generic      map[string]interface{}
+
+	// raw is the undecoded JSON until the owning TestResult resolves it
+	raw json.RawMessage
+}
+
+// Ptr returns a pointer to v. Diagnostic fields whose zero value is a real
+// result, such as no packets received, are pointers so that they are only
+// left out of the JSON when a test didn't set them.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// IsZero reports whether no diagnostics are set
+func (d DiagnosticsPayload) IsZero() bool {
+	return d.value() == nil
+}
+
+// value returns the set field, or nil when none is set
+func (d DiagnosticsPayload) value() interface{} {
+	switch {
+	case d.Physical != nil:
+		return d.Physical
+	case d.DataLink != nil:
+		return d.DataLink
+	case d.Network != nil:
+		return d.Network
+	case d.Transport != nil:
+		return d.Transport
+	case d.Session != nil:
+		return d.Session
+	case d.Presentation != nil:
+		return d.Presentation
+	case d.Application != nil:
+		return d.Application
+	case d.Generic != nil:
+		return d.Generic
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (d DiagnosticsPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.value())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The layer can't be told from the
+// object alone, so it is decoded into Generic until TestResult resolves it.
+func (d *DiagnosticsPayload) UnmarshalJSON(data []byte) error {
+	*d = DiagnosticsPayload{}
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	if err := json.Unmarshal(data, &d.Generic); err != nil {
+		return err
+	}
+	d.raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// MarshalYAML implements yaml.Marshaler, keeping the JSON key names
+func (d DiagnosticsPayload) MarshalYAML() (interface{}, error) {
+	if d.IsZero() {
+		return nil, nil
+	}
+	data, err := json.Marshal(d.value())
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// resolve decodes JSON read by UnmarshalJSON into the type for layer
+func (d *DiagnosticsPayload) resolve(layer int) error {
+	if d.raw == nil {
+		return nil
+	}
+	raw := d.raw
+	d.raw = nil
+
+	var target interface{}
+	switch layer {
+	case 1:
+		d.Physical = &PhysicalDiagnostics{}
+		target = d.Physical
+	case 2:
+		d.DataLink = &DataLinkDiagnostics{}
+		target = d.DataLink
+	case 3:
+		d.Network = &NetworkDiagnostics{}
+		target = d.Network
+	case 4:
+		d.Transport = &TransportDiagnostics{}
+		target = d.Transport
+	case 5:
+		d.Session = &SessionDiagnostics{}
+		target = d.Session
+	case 6:
+		d.Presentation = &PresentationDiagnostics{}
+		target = d.Presentation
+	case 7:
+		d.Application = &ApplicationDiagnostics{}
+		target = d.Application
+	default:
+		return nil
+	}
+	d.Generic = nil
+	if err := json.Unmarshal(raw, target); err != nil {
+		return fmt.Errorf("failed to decode layer %d diagnostics: %w", layer, err)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, omitting empty diagnostics
+func (r TestResult) MarshalJSON() ([]byte, error) {
+	type plain TestResult
+	var diagnostics *DiagnosticsPayload
+	if !r.Diagnostics.IsZero() {
+		diagnostics = &r.Diagnostics
+	}
+	return json.Marshal(struct {
+		plain
+		Diagnostics *DiagnosticsPayload `json:"diagnostics,omitempty"`
+	}{plain(r), diagnostics})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, decoding the diagnostics into
+// the type for the result's layer
+func (r *TestResult) UnmarshalJSON(data []byte) error {
+	type plain TestResult
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	return r.Diagnostics.resolve(r.Layer)
+}
+
+// PhysicalDiagnostics is the diagnostic data of Layer 1 tests
+type PhysicalDiagnostics struct {
+	Interface    string `json:"interface,omitempty"`
+	HardwareAddr string `json:"hardware_addr,omitempty"`
+	MTU          int    `json:"mtu,omitempty"`
+	Flags        string `json:"flags,omitempty"`
+	SuccessCount *int   `json:"success_count,omitempty"`
+	FailCount    *int   `json:"fail_count,omitempty"`
+	OperState    string `json:"oper_state,omitempty"`
+	Carrier      *int   `json:"carrier,omitempty"`
+	TxBytes      *int64 `json:"tx_bytes,omitempty"`
+	RxBytes      *int64 `json:"rx_bytes,omitempty"`
+	IsVPN        *bool  `json:"is_vpn,omitempty"`
+
+	// Wireless signal strength
+	SignalStrength int    `json:"signal_strength,omitempty"`
+	MinThreshold   int    `json:"min_threshold,omitempty"`
+	LinkQuality    int    `json:"link_quality,omitempty"`
+	NoiseLevel     int    `json:"noise_level,omitempty"`
+	BitRate        string `json:"bit_rate,omitempty"`
+	Frequency      string `json:"frequency,omitempty"`
+
+	// Error rate monitoring
+	Samples        int     `json:"samples,omitempty"`
+	SampleInterval string  `json:"sample_interval,omitempty"`
+	ThresholdPPS   float64 `json:"threshold_pps,omitempty"`
+	OverThreshold  *bool   `json:"over_threshold,omitempty"`
+
+	// Packet capture
+	CapturePath     string `json:"capture_path,omitempty"`
+	PacketsCaptured *int   `json:"packets_captured,omitempty"`
+
+	// Link aggregation
+	Bonding         *BondInfo `json:"bonding,omitempty"`
+	BondDownMembers []string  `json:"bond_down_members,omitempty"`
+
+	// Wi-Fi environment scan
+	WiFiNetworks []WiFiNetwork `json:"wifi_networks,omitempty"`
+
+	// Link state changes seen while monitoring
+	LinkEvents []LinkEvent `json:"link_events,omitempty"`
+}
+
+// LinkEvent is a change of a network link's state
+type LinkEvent struct {
+	Interface string    `json:"interface"`
+	EventType string    `json:"event_type"`         // "up", "down" or "renamed"
+	OldName   string    `json:"old_name,omitempty"` // Previous name of a renamed interface
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DataLinkDiagnostics is the diagnostic data of Layer 2 tests
+type DataLinkDiagnostics struct {
+	Interface    string `json:"interface,omitempty"`
+	Type         string `json:"type,omitempty"`
+	HardwareAddr string `json:"hardware_addr,omitempty"`
+	MTU          int    `json:"mtu,omitempty"`
+	Flags        string `json:"flags,omitempty"`
+	OperState    string `json:"oper_state,omitempty"`
+	Carrier      *int   `json:"carrier,omitempty"`
+	TxBytes      *int64 `json:"tx_bytes,omitempty"`
+	RxBytes      *int64 `json:"rx_bytes,omitempty"`
+	Addresses    string `json:"addresses,omitempty"`
+	IsVPN        *bool  `json:"is_vpn,omitempty"`
+
+	// Ethernet OAM
+	RemoteMEP int        `json:"remote_mep,omitempty"`
+	Timeout   string     `json:"timeout,omitempty"`
+	OAM       *OAMResult `json:"oam,omitempty"`
+
+	// ARP inspection
+	Entry    *ARPEntry  `json:"entry,omitempty"`
+	IPAddr   string     `json:"ip_addr,omitempty"`
+	MACAddrs []string   `json:"mac_addrs,omitempty"`
+	Entries  []ARPEntry `json:"entries,omitempty"`
+
+	// VLAN detection
+	VLANs []VLANInfo `json:"vlans,omitempty"`
+
+	// Frame error counters
+	FrameErrors FrameErrorStats `json:"frame_errors,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// NetworkDiagnostics is the diagnostic data of Layer 3 tests
+type NetworkDiagnostics struct {
+	Target string `json:"target,omitempty"`
+	IP     string `json:"ip,omitempty"`
+
+	// Ping
+	Method     string    `json:"method,omitempty"`
+	Sent       *int      `json:"sent,omitempty"`
+	Received   *int      `json:"received,omitempty"`
+	RTTsMs     []float64 `json:"rtts_ms,omitempty"`
+	PacketLoss *float64  `json:"packet_loss,omitempty"`
+
+	// Path MTU discovery and traceroute
+	PathMTU    int         `json:"path_mtu,omitempty"`
+	Traceroute []HopResult `json:"traceroute,omitempty"`
+
+	// WHOIS ownership
+	WHOIS           *WHOISInfo `json:"whois,omitempty"`
+	ExpectedOrg     string     `json:"expected_org,omitempty"`
+	VerifyOwnership *bool      `json:"verify_ownership,omitempty"`
+
+	// Geolocation
+	GeoInfo             *GeoPathInfo `json:"geo_info,omitempty"`
+	HopDiscoveryError   string       `json:"hop_discovery_error,omitempty"`
+	DirectGeoDistanceKm *float64     `json:"direct_geo_distance_km,omitempty"`
+	TotalGeoDistanceKm  *float64     `json:"total_geo_distance_km,omitempty"`
+
+	// ASN lookup of the ping target
+	Geolocation *ASNInfo `json:"geolocation,omitempty"`
+
+	// Forward-confirmed reverse DNS
+	PTRRecord   string `json:"ptr_record,omitempty"`
+	FCrDNSValid *bool  `json:"fcrdns_valid,omitempty"`
+
+	// DNSSEC validation
+	DNSSEC *DNSSECInfo `json:"dnssec,omitempty"`
+
+	// Default gateway reachability
+	DefaultGateway string `json:"default_gateway,omitempty"`
+
+	// Multicast group membership
+	Interface   string            `json:"interface,omitempty"`
+	Required    []string          `json:"required,omitempty"`
+	Memberships []MulticastResult `json:"memberships,omitempty"`
+	Missing     []string          `json:"missing,omitempty"`
+	Unexpected  []string          `json:"unexpected,omitempty"`
+
+	// Multicast group join and leave
+	MulticastJoin *MulticastJoinInfo `json:"multicast_join,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// TransportDiagnostics is the diagnostic data of Layer 4 tests
+type TransportDiagnostics struct {
+	Target    string               `json:"target,omitempty"`
+	RateLimit *ICMPRateLimitResult `json:"rate_limit,omitempty"`
+	Bandwidth *BandwidthResult     `json:"bandwidth,omitempty"`
+
+	ConnectDistribution *DistributionResult `json:"connect_distribution,omitempty"`
+
+	// DTLS handshake
+	RTTMs        *int64     `json:"rtt_ms,omitempty"`
+	TLSVersion   string     `json:"tls_version,omitempty"`
+	DTLS13Status string     `json:"dtls13_status,omitempty"`
+	CertExpiry   *time.Time `json:"cert_expiry,omitempty"`
+	CertSubject  string     `json:"cert_subject,omitempty"`
+
+	// TCP Fast Open; unset when undetermined
+	TFOKernelSupported *bool `json:"tfo_kernel_supported,omitempty"`
+	TFOServerSupported *bool `json:"tfo_server_supported,omitempty"`
+
+	// TCP retransmissions sampled over the test window
+	Retransmissions *RetransmissionSample `json:"retransmissions,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// RetransmissionSample holds the change in the system wide TCP segment
+// counters over a sample window
+type RetransmissionSample struct {
+	RetransSegs int64   `json:"retrans_segs"`
+	OutSegs     int64   `json:"out_segs"`
+	RatePct     float64 `json:"rate_pct"`
+	WindowMs    float64 `json:"window_ms"`
+}
+
+// SessionDiagnostics is the diagnostic data of Layer 5 tests
+type SessionDiagnostics struct {
+	Target string `json:"target,omitempty"`
+
+	// Session establishment
+	Timeout          string `json:"timeout,omitempty"`
+	ConnectionState  string `json:"connection_state,omitempty"`
+	LocalAddr        string `json:"local_addr,omitempty"`
+	RemoteAddr       string `json:"remote_addr,omitempty"`
+	KeepaliveEnabled bool   `json:"keepalive_enabled,omitempty"`
+
+	// RDP
+	ConnectMs        float64 `json:"connect_ms,omitempty"`
+	ResponseBytes    *int    `json:"response_bytes,omitempty"`
+	ResponseMs       float64 `json:"response_ms,omitempty"`
+	RDPVersionHint   string  `json:"rdp_version_hint,omitempty"`
+	SelectedProtocol string  `json:"selected_protocol,omitempty"`
+	RDPHostRole      string  `json:"rdp_host_role,omitempty"`
+
+	// TLS session ticket lifetime
+	MaxWaitS       float64    `json:"max_wait_s,omitempty"`
+	PollIntervalS  float64    `json:"poll_interval_s,omitempty"`
+	RecommendedMax string     `json:"recommended_max,omitempty"`
+	Issued         *time.Time `json:"issued,omitempty"`
+	LifetimeS      *float64   `json:"lifetime_s,omitempty"`
+	StillValid     *bool      `json:"still_valid,omitempty"`
+	Expired        *time.Time `json:"expired,omitempty"`
+
+	// SSH
+	ServerVersion   string  `json:"server_version,omitempty"`
+	HostKeyType     string  `json:"host_key_type,omitempty"`
+	Fingerprint     string  `json:"fingerprint,omitempty"`
+	KeyExchange     string  `json:"key_exchange,omitempty"`
+	Cipher          string  `json:"cipher,omitempty"`
+	MAC             string  `json:"mac,omitempty"`
+	HandshakeMs     float64 `json:"handshake_ms,omitempty"`
+	HostKeyVerified *bool   `json:"host_key_verified,omitempty"`
+	Authenticated   *bool   `json:"authenticated,omitempty"`
+
+	// gRPC health
+	Address       string `json:"address,omitempty"`
+	Service       string `json:"service,omitempty"`
+	ServingStatus string `json:"serving_status,omitempty"`
+
+	// MQTT
+	Broker          string  `json:"broker,omitempty"`
+	ClientID        string  `json:"client_id,omitempty"`
+	Topic           string  `json:"topic,omitempty"`
+	QoS             *byte   `json:"qos,omitempty"`
+	ProtocolVersion string  `json:"protocol_version,omitempty"`
+	RoundTripMs     float64 `json:"round_trip_ms,omitempty"`
+
+	TLS           *bool   `json:"tls,omitempty"`
+	ConnectTimeMs float64 `json:"connect_time_ms,omitempty"`
+
+	// WebSocket
+	URL               string            `json:"url,omitempty"`
+	UpgradeMs         float64           `json:"upgrade_ms,omitempty"`
+	FirstMessageRTTMs float64           `json:"first_message_rtt_ms,omitempty"`
+	Subprotocol       string            `json:"subprotocol,omitempty"`
+	TLSHandshake      *TLSHandshakeInfo `json:"-"` // Written as "tls" in place of the TLS flag
+
+	// HTTP/2 multiplexing
+	StreamsMultiplexed      int   `json:"streams_multiplexed,omitempty"`
+	ConnectionReuseVerified *bool `json:"connection_reuse_verified,omitempty"`
+	DistinctRemoteAddrs     int   `json:"distinct_remote_addrs,omitempty"`
+
+	// WireGuard; every peer of the interface
+	WireGuardPeers []WireGuardPeer `json:"wireguard_peers,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. Tests that captured the TLS
+// handshake report it under "tls"; the others report whether TLS was used.
+func (s SessionDiagnostics) MarshalJSON() ([]byte, error) {
+	type plain SessionDiagnostics
+	if s.TLSHandshake == nil {
+		return json.Marshal(plain(s))
+	}
+	return json.Marshal(struct {
+		plain
+		TLS *TLSHandshakeInfo `json:"tls"`
+	}{plain(s), s.TLSHandshake})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting "tls" as either the
+// TLS flag or the handshake details
+func (s *SessionDiagnostics) UnmarshalJSON(data []byte) error {
+	type plain SessionDiagnostics
+	var aux struct {
+		plain
+		TLS json.RawMessage `json:"tls"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	*s = SessionDiagnostics(aux.plain)
+	tls := bytes.TrimSpace(aux.TLS)
+	switch {
+	case len(tls) == 0 || bytes.Equal(tls, []byte("null")):
+		return nil
+	case tls[0] == '{':
+		s.TLS = Ptr(true)
+		s.TLSHandshake = &TLSHandshakeInfo{}
+		return json.Unmarshal(tls, s.TLSHandshake)
+	}
+	return json.Unmarshal(tls, &s.TLS)
+}
+
+// WireGuardPeer is a peer of a WireGuard interface
+type WireGuardPeer struct {
+	PublicKey           string     `json:"public_key"`
+	Endpoint            string     `json:"endpoint,omitempty"`
+	AllowedIPs          []string   `json:"allowed_ips,omitempty"`
+	LatestHandshake     *time.Time `json:"latest_handshake,omitempty"` // Unset when there has been none
+	TransferRx          int64      `json:"transfer_rx"`
+	TransferTx          int64      `json:"transfer_tx"`
+	PersistentKeepalive int        `json:"persistent_keepalive"` // Seconds; 0 when off
+}
+
+// TLSHandshakeInfo describes the TLS handshake of a session
+type TLSHandshakeInfo struct {
+	Version           string    `json:"version"`
+	CipherSuite       string    `json:"cipher_suite"`
+	ServerName        string    `json:"server_name,omitempty"`
+	HandshakeMs       float64   `json:"handshake_ms"`
+	Verified          bool      `json:"verified"`
+	CertificateExpiry time.Time `json:"certificate_expiry,omitempty"`
+}
+
+// PresentationDiagnostics is the diagnostic data of Layer 6 tests
+type PresentationDiagnostics struct {
+	// JSON, Base64 and ASN.1 round trips
+	DataSize      int    `json:"data_size,omitempty"`
+	EncodedSize   int    `json:"encoded_size,omitempty"`
+	OriginalSize  int    `json:"original_size,omitempty"`
+	DecodedSize   int    `json:"decoded_size,omitempty"`
+	MismatchedKey string `json:"mismatched_key,omitempty"`
+	Success       bool   `json:"success,omitempty"`
+
+	// ASN.1
+	DERBytes       int  `json:"der_bytes,omitempty"`
+	SequenceLength int  `json:"sequence_length,omitempty"`
+	TrailingBytes  int  `json:"trailing_bytes,omitempty"`
+	DecodedLength  int  `json:"decoded_length,omitempty"`
+	BERDecodedOK   bool `json:"ber_decoded_ok,omitempty"`
+	BERElements    int  `json:"ber_elements,omitempty"`
+
+	// Compression
+	Algorithm        string  `json:"algorithm,omitempty"`
+	CompressedSize   int     `json:"compressed_size,omitempty"`
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+	CompressTimeMs   float64 `json:"compress_time_ms,omitempty"`
+	DecompressTimeMs float64 `json:"decompress_time_ms,omitempty"`
+	DecompressedSize int     `json:"decompressed_size,omitempty"`
+
+	// Encryption
+	KeyLength      int     `json:"key_length,omitempty"`
+	PlaintextSize  int     `json:"plaintext_size,omitempty"`
+	CiphertextSize int     `json:"ciphertext_size,omitempty"`
+	ExpansionRatio float64 `json:"expansion_ratio,omitempty"`
+	EncryptTimeMs  float64 `json:"encrypt_time_ms,omitempty"`
+	DecryptTimeMs  float64 `json:"decrypt_time_ms,omitempty"`
+	DecryptedSize  int     `json:"decrypted_size,omitempty"`
+
+	// Protobuf
+	WireSize     int     `json:"wire_size,omitempty"`
+	JSONSize     int     `json:"json_size,omitempty"`
+	SizeRatio    float64 `json:"size_ratio,omitempty"` // Protobuf or MessagePack wire size / JSON size
+	EncodeTimeMs float64 `json:"encode_time_ms,omitempty"`
+	DecodeTimeMs float64 `json:"decode_time_ms,omitempty"`
+
+	// TLS 1.3 session resumption for 0-RTT
+	Target             string   `json:"target,omitempty"`
+	PayloadSize        int      `json:"payload_size,omitempty"`
+	FullHandshakeMs    float64  `json:"full_handshake_ms,omitempty"`
+	ResumedHandshakeMs float64  `json:"resumed_handshake_ms,omitempty"`
+	ImprovementMs      *float64 `json:"improvement_ms,omitempty"`
+	ImprovementPct     float64  `json:"improvement_pct,omitempty"`
+	DidResume          *bool    `json:"did_resume,omitempty"`
+	TLSVersion         string   `json:"tls_version,omitempty"`
+	CipherSuite        string   `json:"cipher_suite,omitempty"`
+	HTTPStatus         int      `json:"http_status,omitempty"`
+	EarlyData          string   `json:"early_data,omitempty"` // Always untested, crypto/tls cannot send early data
+
+	Stage string `json:"stage,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ApplicationDiagnostics is the diagnostic data of Layer 7 tests. HTTP
+// endpoint tests set Request and security header checks set SecurityHeaders;
+// either is reported as the whole diagnostics object.
+type ApplicationDiagnostics struct {
+	Request         *HTTPRequestInfo      `json:"-"`
+	SecurityHeaders *SecurityHeaderResult `json:"-"`
+
+	URL string `json:"url,omitempty"`
+
+	// GraphQL health checks
+	Introspection *HTTPRequestInfo `json:"introspection,omitempty"`
+	Query         *HTTPRequestInfo `json:"query,omitempty"`
+	Errors        string           `json:"errors,omitempty"`
+	MissingFields []string         `json:"missing_fields,omitempty"`
+
+	// GraphQL subscriptions
+	SubscriptionID   string `json:"subscription_id,omitempty"`
+	ExpectedMessages *int   `json:"expected_messages,omitempty"`
+	MessagesReceived *int   `json:"messages_received,omitempty"`
+	FirstMessageMs   int64  `json:"first_message_ms,omitempty"`
+	LastMessageMs    int64  `json:"last_message_ms,omitempty"`
+
+	// DNS-over-HTTPS queries
+	QueryName string   `json:"query_name,omitempty"`
+	QueryType string   `json:"query_type,omitempty"`
+	RCode     string   `json:"rcode,omitempty"`
+	Answers   []string `json:"answers,omitempty"`
+
+	// CORS preflight checks
+	Origin            string            `json:"origin,omitempty"`
+	StatusCode        int               `json:"status_code,omitempty"`
+	CORSHeaders       map[string]string `json:"cors_headers,omitempty"`
+	MissingHeaders    []string          `json:"missing_headers,omitempty"`
+	MismatchedHeaders []string          `json:"mismatched_headers,omitempty"`
+
+	// Certificate Transparency
+	CTVerified *bool      `json:"ct_verified,omitempty"`
+	CTLog      *CTLogInfo `json:"ct_log,omitempty"`
+
+	// SMTP connectivity
+	SMTP *SMTPInfo `json:"smtp,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// CTLogInfo describes a certificate and the SCTs it was delivered with
+type CTLogInfo struct {
+	CertSubject string    `json:"cert_subject"`
+	CertIssuer  string    `json:"cert_issuer"`
+	NotBefore   time.Time `json:"not_before"`
+	SCTs        []SCTInfo `json:"scts,omitempty"`
+}
+
+// SMTPInfo describes an SMTP session with a mail server
+type SMTPInfo struct {
+	Host          string   `json:"host"`
+	Port          int      `json:"port"`
+	Banner        string   `json:"banner,omitempty"`
+	Extensions    []string `json:"extensions,omitempty"` // ESMTP extensions from the EHLO response, e.g. "STARTTLS" or "SIZE 35882577"
+	ImplicitTLS   bool     `json:"implicit_tls"`
+	TLSUpgraded   bool     `json:"tls_upgraded"`
+	Authenticated bool     `json:"authenticated"`
+	VRFYCode      int      `json:"vrfy_code,omitempty"`
+	ConnectMs     float64  `json:"connect_ms"`
+	StartTLSMs    float64  `json:"starttls_ms,omitempty"`
+}
+
+// SCTInfo is a signed certificate timestamp and the log that issued it
+type SCTInfo struct {
+	Source         string    `json:"source"` // Where the SCT came from: "tls", "embedded" or "ocsp"
+	LogID          string    `json:"log_id"`
+	LogDescription string    `json:"log_description,omitempty"`
+	Operator       string    `json:"operator,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+	Verified       bool      `json:"verified"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (a ApplicationDiagnostics) MarshalJSON() ([]byte, error) {
+	switch {
+	case a.Request != nil:
+		return json.Marshal(a.Request)
+	case a.SecurityHeaders != nil:
+		return json.Marshal(a.SecurityHeaders)
+	}
+	type plain ApplicationDiagnostics
+	return json.Marshal(plain(a))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, telling HTTP request and
+// security header diagnostics apart by their keys
+func (a *ApplicationDiagnostics) UnmarshalJSON(data []byte) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+
+	*a = ApplicationDiagnostics{}
+	if _, ok := keys["method"]; ok {
+		a.Request = &HTTPRequestInfo{}
+		return json.Unmarshal(data, a.Request)
+	}
+	if _, ok := keys["findings"]; ok {
+		a.SecurityHeaders = &SecurityHeaderResult{}
+		return json.Unmarshal(data, a.SecurityHeaders)
+	}
+	type plain ApplicationDiagnostics
+	return json.Unmarshal(data, (*plain)(a))
+}
+
+// Layer 2 diagnostic data
+
+// ARPEntry is a single IP to MAC mapping from the ARP cache
+type ARPEntry struct {
+	IPAddr    string `json:"ip_addr"`
+	MACAddr   string `json:"mac_addr"`
+	Interface string `json:"interface"`
+	State     string `json:"state"` // "reachable", "permanent", "incomplete", "dynamic" or "static"
+}
+
+// BondInfo is a bonded (Linux) or teamed (Windows) interface aggregating
+// several member links
+type BondInfo struct {
+	BondName     string   `json:"bond_name"`
+	Mode         string   `json:"mode"`
+	Members      []string `json:"members"`
+	ActiveMember string   `json:"active_member,omitempty"` // Set when only one member carries traffic, as in active-backup
+}
+
+// WiFiNetwork is a wireless network seen by a scan
+type WiFiNetwork struct {
+	SSID           string `json:"ssid"`
+	BSSID          string `json:"bssid"`
+	Channel        int    `json:"channel"`
+	SignalStrength int    `json:"signal_strength"` // Percentage (0-100)
+	SecurityType   string `json:"security_type"`
+}
+
+// FrameErrorStats maps an interface's frame error counters, e.g.
+// rx_crc_errors, to their values
+type FrameErrorStats map[string]int64
+
+// VLANInfo is an 802.1Q VLAN interface configured on a host
+type VLANInfo struct {
+	Interface       string   `json:"interface"`
+	VLANID          int      `json:"vlan_id"`
+	ParentInterface string   `json:"parent_interface"`
+	Addresses       []string `json:"addresses,omitempty"`
+}
+
+// OAMResult holds the outcome of a Y.1731 loopback test
+type OAMResult struct {
+	LoopbackResponseMs int     `json:"loopback_response_ms"`
+	FrameLoss          float64 `json:"frame_loss"`
+	DelayVariation     int     `json:"delay_variation"`
+	RemoteMAC          string  `json:"remote_mac,omitempty"`
+	Level              int     `json:"level"`
+	RepliesReceived    int     `json:"replies_received"`
+}
+
+// Layer 3 diagnostic data
+
+// HopResult is a single hop on the path to a traceroute target
+type HopResult struct {
+	Index    int             `json:"index"`
+	IP       string          `json:"ip,omitempty"` // Empty if the hop did not answer
+	Hostname string          `json:"hostname,omitempty"`
+	RTTs     []time.Duration `json:"rtts"` // Zero for probes that timed out
+	Reached  bool            `json:"reached"`
+}
+
+// BestRTT returns the lowest answered RTT, or 0 if no probe was answered
+func (h HopResult) BestRTT() time.Duration {
+	var best time.Duration
+	for _, rtt := range h.RTTs {
+		if rtt > 0 && (best == 0 || rtt < best) {
+			best = rtt
+		}
+	}
+	return best
+}
+
+// DNSSECInfo holds the result of checking a hostname's DNSSEC signatures
+type DNSSECInfo struct {
+	Resolver       string  `json:"resolver"`
+	Zone           string  `json:"zone,omitempty"` // Zone containing the hostname
+	QueryLatencyMs float64 `json:"query_latency_ms"`
+	Validated      bool    `json:"dnssec_validated"` // Resolver set the AD bit on the answer
+	HasDS          bool    `json:"has_ds"`
+	HasRRSIG       bool    `json:"has_rrsig"`
+	DNSKEYCount    int     `json:"dnskey_count"`
+	ChainDepth     int     `json:"chain_depth"` // Signed zones from the root down to Zone, including both
+}
+
+// WHOISInfo holds the ownership details of an IP address
+type WHOISInfo struct {
+	NetName    string `json:"net_name"`
+	OrgName    string `json:"org_name"`
+	Country    string `json:"country"`
+	CIDR       string `json:"cidr"`
+	ARINHandle string `json:"arin_handle,omitempty"`
+	AbuseEmail string `json:"abuse_email,omitempty"`
+	Server     string `json:"server"`
+}
+
+// GeoInfo holds the geographic location of an IP address
+type GeoInfo struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Country   string  `json:"country"`
+	Continent string  `json:"continent"`
+	City      string  `json:"city"`
+	ISP       string  `json:"isp,omitempty"`
+}
+
+// ASNInfo holds the ASN and approximate location of an IP address
+type ASNInfo struct {
+	IP      string `json:"ip"`
+	ASN     uint   `json:"asn,omitempty"`
+	ASNName string `json:"asn_name,omitempty"`
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+	Source  string `json:"source,omitempty"` // Where the ASN came from: "geoip" or "bgpview"
+}
+
+// GeoHop is a traceroute hop annotated with its location
+type GeoHop struct {
+	TTL        int      `json:"ttl"`
+	IP         string   `json:"ip"`
+	Geo        *GeoInfo `json:"geo,omitempty"`
+	DistanceKm float64  `json:"distance_km"` // From the previous located hop
+}
+
+// GeoPathInfo is the located path from this host to a target
+type GeoPathInfo struct {
+	Target   GeoInfo  `json:"target"`
+	Hops     []GeoHop `json:"hops"`
+	Origin   *GeoInfo `json:"origin,omitempty"`
+	OriginIP string   `json:"origin_ip,omitempty"`
+}
+
+// MulticastResult describes the membership state of a multicast group on an interface
+type MulticastResult struct {
+	Group      string   `json:"group"`
+	Interface  string   `json:"interface"`
+	Joined     bool     `json:"joined"`
+	SourceList []string `json:"source_list,omitempty"`
+}
+
+// MulticastJoinInfo holds the result of joining and leaving a multicast
+// group on one interface
+type MulticastJoinInfo struct {
+	Group            string  `json:"group"`
+	Interface        string  `json:"interface"`
+	JoinLatencyMs    float64 `json:"join_latency_ms"`
+	PreviouslyJoined bool    `json:"previously_joined"`         // Another socket already held the membership
+	InKernelTable    *bool   `json:"in_kernel_table,omitempty"` // Membership listed in /proc/net/igmp or igmp6; nil when not checked
+	ProbeSent        bool    `json:"probe_sent"`
+	ProbeLooped      bool    `json:"probe_looped"` // The datagram sent to the group was received back
+	Left             bool    `json:"left"`
+}
+
+// Layer 4 diagnostic data
+
+// Throughput holds upload and download rates in megabits per second
+type Throughput struct {
+	Upload   float64 `json:"upload"`
+	Download float64 `json:"download"`
+}
+
+// BandwidthResult summarises a bandwidth test across all streams
+type BandwidthResult struct {
+	Target         string     `json:"target"`
+	Streams        int        `json:"streams"`
+	SentBytes      int64      `json:"sent_bytes"`
+	ReceivedBytes  int64      `json:"received_bytes"`
+	DurationMs     float64    `json:"duration_ms"`
+	ThroughputMbps Throughput `json:"throughput_mbps"`
+}
+
+// DistributionResult summarises the dial latency of repeated TCP connections
+type DistributionResult struct {
+	Target    string  `json:"target"`
+	Samples   int     `json:"samples"`
+	Failed    int     `json:"failed"`
+	MinMs     float64 `json:"min_ms"`
+	MaxMs     float64 `json:"max_ms"`
+	MeanMs    float64 `json:"mean_ms"`
+	P50Ms     float64 `json:"p50_ms"`
+	P95Ms     float64 `json:"p95_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+// ICMPRateStep is the outcome of probing at a single rate
+type ICMPRateStep struct {
+	PPS        int     `json:"pps"`
+	Sent       int     `json:"sent"`
+	Received   int     `json:"received"`
+	DroppedPct float64 `json:"dropped_pct"`
+}
+
+// ICMPRateLimitResult summarises an ICMP rate limit probe
+type ICMPRateLimitResult struct {
+	Sent           int            `json:"sent"`
+	Received       int            `json:"received"`
+	DroppedPct     float64        `json:"dropped_pct"`
+	LimitDetected  bool           `json:"limit_detected"`
+	ApproxLimitPPS int            `json:"approx_limit_pps"`
+	Steps          []ICMPRateStep `json:"steps"`
+}
+
+// Layer 7 diagnostic data
+
+// HTTPRequestInfo stores detailed information about an HTTP request
+type HTTPRequestInfo struct {
+	URL               string            `json:"url"`
+	Method            string            `json:"method"`
+	DNSLookupTime     time.Duration     `json:"dns_lookup_time_ms"`
+	ConnectTime       time.Duration     `json:"connect_time_ms"`
+	TLSHandshakeTime  time.Duration     `json:"tls_handshake_time_ms,omitempty"`
+	FirstByteTime     time.Duration     `json:"first_byte_time_ms"`
+	TotalTime         time.Duration     `json:"total_time_ms"`
+	StatusCode        int               `json:"status_code"`
+	ContentLength     int64             `json:"content_length"`
+	ContentType       string            `json:"content_type"`
+	RemoteAddr        string            `json:"remote_addr"`
+	TLSVersion        string            `json:"tls_version,omitempty"`
+	TLSCipherSuite    string            `json:"tls_cipher_suite,omitempty"`
+	CertificateExpiry time.Time         `json:"certificate_expiry,omitempty"`
+	ServerHeaders     map[string]string `json:"server_headers"`
+	RedirectCount     int               `json:"redirect_count"`
+	Error             string            `json:"error,omitempty"`
+	ContentMatch      bool              `json:"content_match,omitempty"`
+	SLACompliance     *SLAResult        `json:"sla_compliance,omitempty"`
+	CSPAnalysis       *CSPAnalysis      `json:"csp_analysis,omitempty"`
+	AllowedMethods    []string          `json:"allowed_methods,omitempty"`
+	DangerousMethods  []string          `json:"dangerous_methods_enabled,omitempty"`
+}
+
+// SLAResult summarises availability of a single test over a time window
+type SLAResult struct {
+	TestName       string  `json:"test_name"`
+	TargetPct      float64 `json:"target_pct"`
+	WindowHours    int     `json:"window_hours"`
+	TotalRuns      int     `json:"total_runs"`
+	PassedRuns     int     `json:"passed_runs"`
+	UptimePct      float64 `json:"uptime_pct"`
+	DowntimeMins   float64 `json:"downtime_mins"`
+	SLAMet         bool    `json:"sla_met"`
+	ViolationCount int     `json:"violation_count"`
+}
+
+// CSP issue severities
+const (
+	CSPSeverityHigh   = "high"
+	CSPSeverityMedium = "medium"
+	CSPSeverityLow    = "low"
+)
+
+// CSPIssue describes a weakness found in a Content-Security-Policy
+type CSPIssue struct {
+	Directive   string `json:"directive"`
+	Value       string `json:"value,omitempty"`
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+}
+
+// CSPAnalysis is the parsed form of a Content-Security-Policy header with its issues
+type CSPAnalysis struct {
+	Directives map[string][]string `json:"directives"`
+	Issues     []CSPIssue          `json:"issues"`
+	Score      int                 `json:"score"`
+}
+
+// HighestSeverity returns the most severe issue level found, or an empty string
+func (a CSPAnalysis) HighestSeverity() string {
+	highest := ""
+	for _, issue := range a.Issues {
+		switch issue.Severity {
+		case CSPSeverityHigh:
+			return CSPSeverityHigh
+		case CSPSeverityMedium:
+			highest = CSPSeverityMedium
+		case CSPSeverityLow:
+			if highest == "" {
+				highest = CSPSeverityLow
+			}
+		}
+	}
+	return highest
+}
+
+// SecurityHeaderFinding is the assessment of a single response header
+type SecurityHeaderFinding struct {
+	Header  string     `json:"header"`
+	Value   string     `json:"value,omitempty"`
+	Status  TestStatus `json:"status"`
+	Points  int        `json:"points"`
+	Max     int        `json:"max"`
+	Message string     `json:"message"`
+}
+
+// SecurityHeaderResult is the security header assessment of an endpoint
+type SecurityHeaderResult struct {
+	Endpoint   string                  `json:"endpoint"`
+	StatusCode int                     `json:"status_code"`
+	Score      int                     `json:"score"` // 0-100
+	Findings   []SecurityHeaderFinding `json:"findings"`
+}
+
+// Warnings returns the findings that need attention
+func (s SecurityHeaderResult) Warnings() []SecurityHeaderFinding {
+	var warnings []SecurityHeaderFinding
+	for _, finding := range s.Findings {
+		if finding.Status != StatusPassed {
+			warnings = append(warnings, finding)
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,298 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultLatencyDeltaThresholdMs is the latency change reported when no
+// threshold is configured
+const DefaultLatencyDeltaThresholdMs = 50
+
+// TestDiff compares one test present in both runs
+type TestDiff struct {
+	Layer            int        `json:"layer"`
+	Name             string     `json:"name"`
+	BaseStatus       TestStatus `json:"base_status"`
+	CompareStatus    TestStatus `json:"compare_status"`
+	StatusChanged    bool       `json:"status_changed"`
+	LatencyDiffMs    float64    `json:"latency_diff_ms,omitempty"`
+	PacketLossDiff   float64    `json:"packet_loss_diff_pct,omitempty"`
+	TransferRateDiff float64    `json:"transfer_rate_diff_mb_s,omitempty"`
+	LatencyChanged   bool       `json:"latency_changed"` // Latency moved by more than the threshold
+}
+
+// DiffReport is the difference between two sets of test results
+type DiffReport struct {
+	StatusChanges           []TestDiff   `json:"status_changes"`
+	MetricChanges           []TestDiff   `json:"metric_changes"`
+	OnlyInBase              []TestResult `json:"only_in_base"`
+	OnlyInCompare           []TestResult `json:"only_in_compare"`
+	Unchanged               int          `json:"unchanged"`
+	LatencyDeltaThresholdMs int          `json:"latency_delta_threshold_ms"`
+}
+
+// DiffReportGenerator generates reports comparing two test runs
+type DiffReportGenerator struct {
+	BaseResults             []TestResult
+	CompareResults          []TestResult
+	LatencyDeltaThresholdMs int // Latency changes at or below this are ignored
+	CreatedAt               time.Time
+}
+
+// NewDiffReportGenerator creates a diff report generator for base and compare
+func NewDiffReportGenerator(base, compare []TestResult) *DiffReportGenerator {
+	return &DiffReportGenerator{
+		BaseResults:             base,
+		CompareResults:          compare,
+		LatencyDeltaThresholdMs: DefaultLatencyDeltaThresholdMs,
+		CreatedAt:               time.Now(),
+	}
+}
+
+// diffKey identifies the same test across runs
+type diffKey struct {
+	layer int
+	name  string
+}
+
+// Diff compares the two result sets, matching tests by layer and name
+func (dg *DiffReportGenerator) Diff() DiffReport {
+	report := DiffReport{
+		StatusChanges:           []TestDiff{},
+		MetricChanges:           []TestDiff{},
+		OnlyInBase:              []TestResult{},
+		OnlyInCompare:           []TestResult{},
+		LatencyDeltaThresholdMs: dg.LatencyDeltaThresholdMs,
+	}
+
+	compareByKey := make(map[diffKey]TestResult, len(dg.CompareResults))
+	for _, result := range dg.CompareResults {
+		key := diffKey{result.Layer, result.Name}
+		if _, ok := compareByKey[key]; !ok {
+			compareByKey[key] = result
+		}
+	}
+
+	matched := make(map[diffKey]bool, len(dg.BaseResults))
+	for _, base := range dg.BaseResults {
+		key := diffKey{base.Layer, base.Name}
+		if matched[key] {
+			continue
+		}
+		compare, ok := compareByKey[key]
+		if !ok {
+			report.OnlyInBase = append(report.OnlyInBase, base)
+			continue
+		}
+		matched[key] = true
+
+		diff := TestDiff{
+			Layer:         base.Layer,
+			Name:          base.Name,
+			BaseStatus:    base.Status,
+			CompareStatus: compare.Status,
+			StatusChanged: base.Status != compare.Status,
+		}
+		if base.Metrics.Latency > 0 && compare.Metrics.Latency > 0 {
+			diff.LatencyDiffMs = float64(compare.Metrics.Latency-base.Metrics.Latency) / float64(time.Millisecond)
+			delta := diff.LatencyDiffMs
+			if delta < 0 {
+				delta = -delta
+			}
+			diff.LatencyChanged = delta > float64(dg.LatencyDeltaThresholdMs)
+		}
+		diff.PacketLossDiff = compare.Metrics.PacketLoss - base.Metrics.PacketLoss
+		diff.TransferRateDiff = compare.Metrics.TransferRate - base.Metrics.TransferRate
+
+		if diff.StatusChanged {
+			report.StatusChanges = append(report.StatusChanges, diff)
+		}
+		if diff.LatencyChanged {
+			report.MetricChanges = append(report.MetricChanges, diff)
+		}
+		if !diff.StatusChanged && !diff.LatencyChanged {
+			report.Unchanged++
+		}
+	}
+
+	added := make(map[diffKey]bool)
+	for _, compare := range dg.CompareResults {
+		key := diffKey{compare.Layer, compare.Name}
+		if matched[key] || added[key] {
+			continue
+		}
+		added[key] = true
+		report.OnlyInCompare = append(report.OnlyInCompare, compare)
+	}
+
+	return report
+}
+
+// Generate writes the diff report in format to path and returns the path.
+// When path is empty the report is written to ReportDir.
+func (dg *DiffReportGenerator) Generate(format ReportFormat, path string) (string, error) {
+	if path == "" {
+		path = filepath.Join(ReportDir, fmt.Sprintf("layer_tests_diff_%s.%s", dg.CreatedAt.Format("20060102_150405"), format))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	report := dg.Diff()
+
+	var data []byte
+	switch format {
+	case ReportMarkdown:
+		data = []byte(dg.markdown(report))
+	case ReportHTML:
+		data = []byte(dg.html(report))
+	case ReportJSON:
+		var err error
+		data, err = json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal diff report: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("unsupported diff report format: %s", format)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write diff report: %w", err)
+	}
+	return path, nil
+}
+
+// markdown renders the diff report as Markdown
+func (dg *DiffReportGenerator) markdown(report DiffReport) string {
+	var md strings.Builder
+
+	md.WriteString("# OSI Layer Test Comparison\n\n")
+	md.WriteString(fmt.Sprintf("Generated on: %s\n\n", dg.CreatedAt.Format("2006-01-02 15:04:05")))
+
+	md.WriteString("## Summary\n\n")
+	md.WriteString(fmt.Sprintf("- **Status Changes:** %d\n", len(report.StatusChanges)))
+	md.WriteString(fmt.Sprintf("- **Latency Changes (> %d ms):** %d\n", report.LatencyDeltaThresholdMs, len(report.MetricChanges)))
+	md.WriteString(fmt.Sprintf("- **Only in Base:** %d\n", len(report.OnlyInBase)))
+	md.WriteString(fmt.Sprintf("- **Only in Compare:** %d\n", len(report.OnlyInCompare)))
+	md.WriteString(fmt.Sprintf("- **Unchanged:** %d\n\n", report.Unchanged))
+
+	if len(report.StatusChanges) > 0 {
+		md.WriteString("## Status Changes\n\n")
+		md.WriteString("| Layer | Test | Base | Compare |\n|---|---|---|---|\n")
+		for _, diff := range report.StatusChanges {
+			md.WriteString(fmt.Sprintf("| %d | %s | %s | %s |\n", diff.Layer, escapeMarkdownCell(diff.Name), diff.BaseStatus, diff.CompareStatus))
+		}
+		md.WriteString("\n")
+	}
+
+	if len(report.MetricChanges) > 0 {
+		md.WriteString("## Latency Changes\n\n")
+		md.WriteString("| Layer | Test | Latency Change |\n|---|---|---|\n")
+		for _, diff := range report.MetricChanges {
+			md.WriteString(fmt.Sprintf("| %d | %s | %+.2f ms |\n", diff.Layer, escapeMarkdownCell(diff.Name), diff.LatencyDiffMs))
+		}
+		md.WriteString("\n")
+	}
+
+	writeResults := func(title string, results []TestResult) {
+		if len(results) == 0 {
+			return
+		}
+		md.WriteString(fmt.Sprintf("## %s\n\n", title))
+		md.WriteString("| Layer | Test | Status |\n|---|---|---|\n")
+		for _, result := range results {
+			md.WriteString(fmt.Sprintf("| %d | %s | %s |\n", result.Layer, escapeMarkdownCell(result.Name), result.Status))
+		}
+		md.WriteString("\n")
+	}
+	writeResults("Only in Base", report.OnlyInBase)
+	writeResults("Only in Compare", report.OnlyInCompare)
+
+	return md.String()
+}
+
+// escapeMarkdownCell keeps pipes in a value from splitting a table cell
+func escapeMarkdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// html renders the diff report as a standalone HTML page
+func (dg *DiffReportGenerator) html(report DiffReport) string {
+	var b strings.Builder
+
+	b.WriteString(`<!DOCTYPE html>
+<html>
+<head>
+    <title>OSI Layer Test Comparison</title>
+    <style>
+        body { font-family: Arial, sans-serif; margin: 20px; }
+        h1 { color: #333; }
+        .summary { margin: 20px 0; padding: 10px; background-color: #f5f5f5; border-radius: 5px; }
+        table { border-collapse: collapse; margin: 10px 0 20px; }
+        th, td { border: 1px solid #ddd; padding: 6px 10px; text-align: left; }
+        th { background-color: #f5f5f5; }
+        .passed { background-color: #dff0d8; }
+        .failed { background-color: #f2dede; }
+        .warning { background-color: #fcf8e3; }
+        .skipped { background-color: #eee; }
+    </style>
+</head>
+<body>
+    <h1>OSI Layer Test Comparison</h1>
+`)
+	b.WriteString(fmt.Sprintf(`    <div class="summary">
+        <p>Generated on: %s</p>
+        <p>Status Changes: %d</p>
+        <p>Latency Changes (&gt; %d ms): %d</p>
+        <p>Only in Base: %d</p>
+        <p>Only in Compare: %d</p>
+        <p>Unchanged: %d</p>
+    </div>
+`, dg.CreatedAt.Format("2006-01-02 15:04:05"), len(report.StatusChanges), report.LatencyDeltaThresholdMs,
+		len(report.MetricChanges), len(report.OnlyInBase), len(report.OnlyInCompare), report.Unchanged))
+
+	statusCell := func(status TestStatus) string {
+		return fmt.Sprintf(`<td class="%s">%s</td>`, strings.ToLower(status.String()), html.EscapeString(status.String()))
+	}
+
+	if len(report.StatusChanges) > 0 {
+		b.WriteString("<h2>Status Changes</h2>\n<table>\n<tr><th>Layer</th><th>Test</th><th>Base</th><th>Compare</th></tr>\n")
+		for _, diff := range report.StatusChanges {
+			b.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td>%s%s</tr>\n",
+				diff.Layer, html.EscapeString(diff.Name), statusCell(diff.BaseStatus), statusCell(diff.CompareStatus)))
+		}
+		b.WriteString("</table>\n")
+	}
+
+	if len(report.MetricChanges) > 0 {
+		b.WriteString("<h2>Latency Changes</h2>\n<table>\n<tr><th>Layer</th><th>Test</th><th>Latency Change</th></tr>\n")
+		for _, diff := range report.MetricChanges {
+			b.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td><td>%+.2f ms</td></tr>\n",
+				diff.Layer, html.EscapeString(diff.Name), diff.LatencyDiffMs))
+		}
+		b.WriteString("</table>\n")
+	}
+
+	writeResults := func(title string, results []TestResult) {
+		if len(results) == 0 {
+			return
+		}
+		b.WriteString(fmt.Sprintf("<h2>%s</h2>\n<table>\n<tr><th>Layer</th><th>Test</th><th>Status</th></tr>\n", title))
+		for _, result := range results {
+			b.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td>%s</tr>\n",
+				result.Layer, html.EscapeString(result.Name), statusCell(result.Status)))
+		}
+		b.WriteString("</table>\n")
+	}
+	writeResults("Only in Base", report.OnlyInBase)
+	writeResults("Only in Compare", report.OnlyInCompare)
+
+	b.WriteString("</body>\n</html>")
+	return b.String()
+}
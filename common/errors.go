@@ -0,0 +1,84 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// ErrorCode classifies why a layer's tests failed
+type ErrorCode string
+
+const (
+	ErrNetworkUnreachable ErrorCode = "network_unreachable"
+	ErrTimeout            ErrorCode = "timeout"
+	ErrPermissionDenied   ErrorCode = "permission_denied"
+	ErrConfigInvalid      ErrorCode = "config_invalid"
+	ErrDependencyFailed   ErrorCode = "dependency_failed"
+	ErrTestCancelled      ErrorCode = "test_cancelled"
+	ErrTestFailed         ErrorCode = "test_failed" // Tests ran and some failed
+)
+
+// LayerError is an error returned by a layer runner
+type LayerError struct {
+	Layer   int
+	Code    ErrorCode
+	Cause   error
+	Details map[string]interface{}
+}
+
+// NewLayerError wraps err for layer with the code ClassifyError picks for it
+func NewLayerError(layer int, err error) *LayerError {
+	return &LayerError{Layer: layer, Code: ClassifyError(err), Cause: err}
+}
+
+func (e *LayerError) Error() string {
+	if e.Cause == nil {
+		return fmt.Sprintf("layer %d: %s", e.Layer, e.Code)
+	}
+	return e.Cause.Error()
+}
+
+func (e *LayerError) Unwrap() error {
+	return e.Cause
+}
+
+// Retryable reports whether running the tests again could succeed. Missing
+// privileges, bad configuration and cancellation won't change on a retry.
+func (e *LayerError) Retryable() bool {
+	switch e.Code {
+	case ErrPermissionDenied, ErrConfigInvalid, ErrTestCancelled:
+		return false
+	}
+	return true
+}
+
+// ErrorCodeOf returns the code of the LayerError in err's chain, or "" when
+// there is none
+func ErrorCodeOf(err error) ErrorCode {
+	var layerErr *LayerError
+	if errors.As(err, &layerErr) {
+		return layerErr.Code
+	}
+	return ""
+}
+
+// ClassifyError picks the error code for a lower level error. Errors it
+// doesn't recognise are test failures.
+func ClassifyError(err error) ErrorCode {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrTestCancelled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrTimeout
+	case errors.Is(err, os.ErrPermission), errors.Is(err, syscall.EPERM), errors.Is(err, syscall.EACCES):
+		return ErrPermissionDenied
+	case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH):
+		return ErrNetworkUnreachable
+	}
+	return ErrTestFailed
+}
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestGenerateExcelReport(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	results := []TestResult{
+		{Layer: 1, Name: "Link Test", Status: StatusPassed, StartTime: start, EndTime: start.Add(time.Second),
+			Metrics: TestMetrics{Duration: time.Second, Latency: 2 * time.Millisecond}},
+		{Layer: 3, Name: "Ping Test", Status: StatusFailed, Message: "100% packet loss", StartTime: start, EndTime: start,
+			Metrics: TestMetrics{PacketLoss: 100}},
+		{Layer: 3, Name: "Traceroute Test", Status: StatusWarning, StartTime: start, EndTime: start,
+			Metrics: TestMetrics{Custom: map[string]interface{}{"hops": 12}}},
+		{Layer: 7, Name: "HTTP Test", Status: StatusSkipped, StartTime: start, EndTime: start},
+	}
+
+	rg := NewReportGenerator(results, "excel_test")
+	rg.OutputDir = t.TempDir()
+	path, err := rg.GenerateReport(ReportExcel)
+	if err != nil {
+		t.Fatalf("GenerateReport(ReportExcel) error = %v", err)
+	}
+	if !strings.HasSuffix(path, ".xlsx") {
+		t.Errorf("report path %q does not end in .xlsx", path)
+	}
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatalf("failed to open report: %v", err)
+	}
+	defer f.Close()
+
+	wantSheets := []string{"Summary", "By Layer", "All Results"}
+	if got := f.GetSheetList(); !reflect.DeepEqual(got, wantSheets) {
+		t.Fatalf("sheets = %v, want %v", got, wantSheets)
+	}
+
+	rows := func(sheet string) [][]string {
+		t.Helper()
+		rows, err := f.GetRows(sheet)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", sheet, err)
+		}
+		return rows
+	}
+
+	// Header plus total and one row per status
+	summary := rows("Summary")
+	if len(summary) != 6 {
+		t.Errorf("Summary has %d rows, want 6", len(summary))
+	}
+	if got := summary[1]; !reflect.DeepEqual(got, []string{"Total", "4"}) {
+		t.Errorf("Summary total row = %v, want [Total 4]", got)
+	}
+
+	// Header plus layers 1, 3 and 7
+	byLayer := rows("By Layer")
+	if len(byLayer) != 4 {
+		t.Errorf("By Layer has %d rows, want 4", len(byLayer))
+	}
+	if got := byLayer[2][:4]; !reflect.DeepEqual(got, []string{"3", "2", "0", "1"}) {
+		t.Errorf("layer 3 row starts %v, want [3 2 0 1]", got)
+	}
+
+	allResults := rows("All Results")
+	if len(allResults) != len(results)+1 {
+		t.Errorf("All Results has %d rows, want %d", len(allResults), len(results)+1)
+	}
+	if got := allResults[0]; len(got) != 14 {
+		t.Errorf("All Results header has %d columns, want 14: %v", len(got), got)
+	}
+	if got := allResults[3][len(allResults[3])-1]; got != `{"hops":12}` {
+		t.Errorf("custom metrics cell = %q, want {\"hops\":12}", got)
+	}
+
+	// Rows are filled by status
+	for cell, want := range map[string]string{
+		"A2": "C6EFCE", // passed
+		"A3": "FFC7CE", // failed
+		"A4": "FFEB9C", // warning
+	} {
+		styleID, err := f.GetCellStyle("All Results", cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		style, err := f.GetStyle(styleID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(style.Fill.Color) == 0 || !strings.EqualFold(strings.TrimPrefix(style.Fill.Color[0], "#"), want) {
+			t.Errorf("All Results %s fill = %v, want %s", cell, style.Fill.Color, want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// influxMeasurement is the measurement every test result is written to
+const influxMeasurement = "osi_layer_test"
+
+var (
+	// influxTagEscaper escapes tag keys, tag values and field keys. Line
+	// protocol cannot escape newlines, so they become escaped spaces.
+	influxTagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\ `, "\r", `\ `)
+	// influxMeasurementEscaper escapes measurement names
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+)
+
+// influxField is one field of a line
+type influxField struct {
+	key   string
+	value float64
+}
+
+// influxFloat converts a Custom metric to a float field value. Integers are
+// written as floats too, so a field keeps one type across runs.
+func influxFloat(v interface{}) (float64, bool) {
+	var f float64
+	switch n := v.(type) {
+	case float64:
+		f = n
+	case float32:
+		f = float64(n)
+	case int:
+		f = float64(n)
+	case int8:
+		f = float64(n)
+	case int16:
+		f = float64(n)
+	case int32:
+		f = float64(n)
+	case int64:
+		f = float64(n)
+	case uint:
+		f = float64(n)
+	case uint8:
+		f = float64(n)
+	case uint16:
+		f = float64(n)
+	case uint32:
+		f = float64(n)
+	case uint64:
+		f = float64(n)
+	default:
+		return 0, false
+	}
+	// Line protocol has no representation for NaN or infinity
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+// influxLine renders result as one line of InfluxDB line protocol. parent is
+// the name of the enclosing test for sub-results.
+func influxLine(result TestResult, parent string) string {
+	var b strings.Builder
+	b.WriteString(influxMeasurementEscaper.Replace(influxMeasurement))
+
+	// Tags in key order, as InfluxDB recommends; empty values are not allowed
+	tags := [][2]string{
+		{"layer", strconv.Itoa(result.Layer)},
+		{"parent", parent},
+		{"status", result.Status.String()},
+		{"test_name", result.Name},
+	}
+	for _, tag := range tags {
+		if tag[1] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ",%s=%s", tag[0], influxTagEscaper.Replace(tag[1]))
+	}
+
+	fields := []influxField{
+		{"duration_ms", durationMilliseconds(result.Metrics.Duration)},
+		{"latency_ms", durationMilliseconds(result.Metrics.Latency)},
+		{"packet_loss_pct", result.Metrics.PacketLoss},
+		{"transfer_rate", result.Metrics.TransferRate},
+		{"reliability_pct", result.Metrics.ReliabilityPct},
+	}
+	standard := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		standard[field.key] = true
+	}
+
+	custom := make([]string, 0, len(result.Metrics.Custom))
+	for key := range result.Metrics.Custom {
+		if !standard[key] && key != "" {
+			custom = append(custom, key)
+		}
+	}
+	sort.Strings(custom)
+	for _, key := range custom {
+		if value, ok := influxFloat(result.Metrics.Custom[key]); ok {
+			fields = append(fields, influxField{key, value})
+		}
+	}
+
+	for i, field := range fields {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		fmt.Fprintf(&b, "%s%s=%s", sep, influxTagEscaper.Replace(field.key),
+			strconv.FormatFloat(field.value, 'f', -1, 64))
+	}
+
+	// Without a timestamp InfluxDB uses the time the line is written
+	if !result.EndTime.IsZero() {
+		fmt.Fprintf(&b, " %d", result.EndTime.UnixNano())
+	}
+
+	return b.String()
+}
+
+// generateInfluxReport writes every result, including sub-results, as
+// InfluxDB line protocol for `influx write` or Telegraf's file input
+func (rg *ReportGenerator) generateInfluxReport(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	var b strings.Builder
+	var writeResults func(results []TestResult, parent string)
+	writeResults = func(results []TestResult, parent string) {
+		for _, result := range results {
+			b.WriteString(influxLine(result, parent))
+			b.WriteByte('\n')
+			writeResults(result.SubResults, result.Name)
+		}
+	}
+	for _, layer := range sortedLayers(rg.ResultsByLayer) {
+		writeResults(rg.ResultsByLayer[layer], "")
+	}
+
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write InfluxDB line protocol file: %w", err)
+	}
+
+	return nil
+}
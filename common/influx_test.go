@@ -0,0 +1,171 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// influxPoint is one parsed line of line protocol
+type influxPoint struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]float64
+	timestamp   int64
+}
+
+// splitUnescaped splits s at every sep that is not preceded by a backslash
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescapeInflux removes the backslashes line protocol escapes with
+func unescapeInflux(s string) string {
+	return strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ").Replace(s)
+}
+
+// parseInfluxLine parses a line the way InfluxDB does, rejecting anything
+// that is not valid line protocol with float fields
+func parseInfluxLine(line string) (influxPoint, error) {
+	point := influxPoint{tags: map[string]string{}, fields: map[string]float64{}}
+	if strings.ContainsAny(line, "\r\n") {
+		return point, fmt.Errorf("line contains a newline")
+	}
+
+	sections := splitUnescaped(line, ' ')
+	if len(sections) < 2 || len(sections) > 3 {
+		return point, fmt.Errorf("expected 2 or 3 sections, got %d", len(sections))
+	}
+
+	series := splitUnescaped(sections[0], ',')
+	point.measurement = unescapeInflux(series[0])
+	if point.measurement == "" {
+		return point, fmt.Errorf("empty measurement")
+	}
+	for _, tag := range series[1:] {
+		kv := splitUnescaped(tag, '=')
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return point, fmt.Errorf("invalid tag %q", tag)
+		}
+		point.tags[unescapeInflux(kv[0])] = unescapeInflux(kv[1])
+	}
+
+	for _, field := range splitUnescaped(sections[1], ',') {
+		kv := splitUnescaped(field, '=')
+		if len(kv) != 2 || kv[0] == "" {
+			return point, fmt.Errorf("invalid field %q", field)
+		}
+		value, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil {
+			return point, fmt.Errorf("invalid field value %q: %w", kv[1], err)
+		}
+		point.fields[unescapeInflux(kv[0])] = value
+	}
+
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return point, fmt.Errorf("invalid timestamp %q: %w", sections[2], err)
+		}
+		point.timestamp = ts
+	}
+	return point, nil
+}
+
+func TestGenerateInfluxReport(t *testing.T) {
+	end := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	results := []TestResult{
+		{
+			Layer:   3,
+			Name:    "Ping Test (8.8.8.8, dns=google)",
+			Status:  StatusPassed,
+			EndTime: end,
+			Metrics: TestMetrics{
+				Duration:   1500 * time.Millisecond,
+				Latency:    12 * time.Millisecond,
+				PacketLoss: 0.5,
+				Custom: map[string]interface{}{
+					"hop count": 7,
+					"label":     "ignored",
+				},
+			},
+			SubResults: []TestResult{
+				{Layer: 3, Name: "multi\nline\rname", Status: StatusFailed, EndTime: end},
+			},
+		},
+		{Layer: 7, Name: "HTTP Test", Status: StatusWarning},
+	}
+
+	rg := NewReportGenerator(results, "influx")
+	path := filepath.Join(t.TempDir(), "report.influx")
+	if err := rg.generateInfluxReport(path); err != nil {
+		t.Fatalf("generateInfluxReport: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3:\n%s", len(lines), data)
+	}
+
+	points := make([]influxPoint, len(lines))
+	for i, line := range lines {
+		point, err := parseInfluxLine(line)
+		if err != nil {
+			t.Fatalf("line %d %q is not valid line protocol: %v", i+1, line, err)
+		}
+		if point.measurement != influxMeasurement {
+			t.Errorf("line %d: measurement %q, want %q", i+1, point.measurement, influxMeasurement)
+		}
+		points[i] = point
+	}
+
+	ping := points[0]
+	if ping.tags["test_name"] != "Ping Test (8.8.8.8, dns=google)" || ping.tags["layer"] != "3" || ping.tags["status"] != "Passed" {
+		t.Errorf("unexpected tags %v", ping.tags)
+	}
+	wantFields := map[string]float64{
+		"duration_ms":     1500,
+		"latency_ms":      12,
+		"packet_loss_pct": 0.5,
+		"hop count":       7,
+	}
+	for key, want := range wantFields {
+		if got, ok := ping.fields[key]; !ok || got != want {
+			t.Errorf("field %s = %v (present %v), want %v", key, got, ok, want)
+		}
+	}
+	if _, ok := ping.fields["label"]; ok {
+		t.Error("non-numeric custom metric was written as a field")
+	}
+	if ping.timestamp != end.UnixNano() {
+		t.Errorf("timestamp %d, want %d", ping.timestamp, end.UnixNano())
+	}
+
+	sub := points[1]
+	if sub.tags["test_name"] != "multi line name" || sub.tags["parent"] != ping.tags["test_name"] {
+		t.Errorf("unexpected sub-result tags %v", sub.tags)
+	}
+
+	if points[2].timestamp != 0 {
+		t.Errorf("result without an end time got timestamp %d", points[2].timestamp)
+	}
+}
@@ -0,0 +1,25 @@
+package common
+
+import "sync"
+
+// testResultPool reuses the TestResult structs that test goroutines fill in
+// before sending a copy to their layer's results channel
+var testResultPool = sync.Pool{New: func() interface{} { return &TestResult{} }}
+
+// GetTestResult returns an empty TestResult from the pool. It is for results
+// that are sent on by value: the pointer itself must not be stored in a
+// SubResults slice or anywhere else that outlives the goroutine.
+func GetTestResult() *TestResult {
+	return testResultPool.Get().(*TestResult)
+}
+
+// PutTestResult zeroes r and returns it to the pool. SubResults keeps its
+// backing array for reuse, so r must not be put back while a copy of it still
+// refers to its sub-results.
+func PutTestResult(r *TestResult) {
+	if r == nil {
+		return
+	}
+	*r = TestResult{SubResults: r.SubResults[:0]}
+	testResultPool.Put(r)
+}
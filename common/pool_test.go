@@ -0,0 +1,75 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPutTestResult(t *testing.T) {
+	r := GetTestResult()
+	r.Layer = 3
+	r.Name = "Ping Test"
+	r.Status = StatusFailed
+	r.Metrics.Latency = time.Millisecond
+	r.Metrics.Custom = map[string]interface{}{"hops": 4}
+	r.SubResults = append(r.SubResults, TestResult{Name: "sub"}, TestResult{Name: "sub"})
+
+	// A copy sent on before the result is returned must be unaffected
+	sent := *r
+	PutTestResult(r)
+
+	if r.Layer != 0 || r.Name != "" || r.Status != StatusPassed || r.Metrics.Latency != 0 || r.Metrics.Custom != nil {
+		t.Errorf("PutTestResult left fields set: %+v", *r)
+	}
+	if len(r.SubResults) != 0 {
+		t.Errorf("SubResults length %d after put, want 0", len(r.SubResults))
+	}
+	if cap(r.SubResults) < 2 {
+		t.Errorf("SubResults capacity %d after put, want the backing array kept", cap(r.SubResults))
+	}
+	if sent.Name != "Ping Test" || sent.Metrics.Custom["hops"] != 4 || len(sent.SubResults) != 2 {
+		t.Errorf("copy changed after put: %+v", sent)
+	}
+
+	PutTestResult(nil)
+}
+
+// sharedResult stands in for the goroutine closures that keep a pointer to
+// the result, which moves it to the heap in the layer runners
+var sharedResult *TestResult
+
+// resultSink receives each finished result, as a layer's results channel does
+var resultSink TestResult
+
+// fillResult populates r the way a layer 1 connection test does
+func fillResult(r *TestResult, i int) {
+	sharedResult = r
+	r.Layer = 1
+	r.Name = fmt.Sprintf("Interface eth%d Connection", i%8)
+	r.StartTime = time.Now()
+	r.Status = StatusPassed
+	r.Metrics.Latency = time.Duration(i) * time.Microsecond
+	r.EndTime = r.StartTime.Add(time.Millisecond)
+	r.Metrics.Duration = r.EndTime.Sub(r.StartTime)
+	resultSink = *r
+}
+
+func BenchmarkTestResultAllocation(b *testing.B) {
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r := &TestResult{}
+			fillResult(r, i)
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			r := GetTestResult()
+			fillResult(r, i)
+			PutTestResult(r)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// LogPanic logs a value recovered from a panicking test with the stack of the
+// goroutine that panicked. It must be called from the deferred function.
+func LogPanic(logger *zap.Logger, layer int, recovered interface{}) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	logger.Error("Test panicked",
+		zap.Int("layer", layer),
+		zap.String("panic", fmt.Sprint(recovered)),
+		zap.Stack("stack"))
+}
+
+// PanicResult is the failed result reported in place of a test that panicked
+func PanicResult(layer int, recovered interface{}) TestResult {
+	now := time.Now()
+	return TestResult{
+		Layer:     layer,
+		Name:      fmt.Sprintf("Layer %d Test Panic", layer),
+		Status:    StatusFailed,
+		Message:   fmt.Sprintf("panic: %v", recovered),
+		StartTime: now,
+		EndTime:   now,
+	}
+}
+
+// RecoverTestGoroutine returns the function a test goroutine defers to turn a
+// panic into a failed result on results, so the layer's other results are
+// kept:
+//
+//	defer common.RecoverTestGoroutine(layer, logger, resultsChan)()
+//
+// The result is dropped, and only logged, if results has no room for it.
+// Helper goroutines that report no results of their own pass a nil results;
+// their panic is logged and the enclosing test sees the work as missing.
+func RecoverTestGoroutine(layer int, logger *zap.Logger, results chan<- TestResult) func() {
+	return func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		LogPanic(logger, layer, recovered)
+		if results == nil {
+			return
+		}
+		select {
+		case results <- PanicResult(layer, recovered):
+		default:
+			if logger != nil {
+				logger.Error("Results channel full, dropping panic result", zap.Int("layer", layer))
+			}
+		}
+	}
+}
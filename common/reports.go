@@ -2,8 +2,12 @@
 package common
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,6 +17,7 @@ import (
 
 	"github.com/jung-kurt/gofpdf"
 	"github.com/wcharczuk/go-chart/v2"
+	"github.com/xuri/excelize/v2"
 	"gopkg.in/yaml.v3"
 )
 
@@ -27,8 +32,15 @@ const (
 	ReportHTML     ReportFormat = "html"
 	ReportMarkdown ReportFormat = "md"
 	ReportXML      ReportFormat = "xml"
+	ReportJUnit    ReportFormat = "junit"
+	ReportExcel    ReportFormat = "xlsx"
+	ReportSARIF    ReportFormat = "sarif"
+	ReportInflux   ReportFormat = "influx"
 )
 
+// SignatureExt is appended to a report's path to name its signature file
+const SignatureExt = ".sig"
+
 // ReportGenerator generates reports in various formats
 type ReportGenerator struct {
 	ResultsByLayer map[int][]TestResult
@@ -36,6 +48,19 @@ type ReportGenerator struct {
 	TestName       string
 	CreatedAt      time.Time
 	OutputDir      string
+	SigningKey     []byte // When set, each report gets an HMAC-SHA256 signature file next to it
+	ConfigPath     string // Config file of the run; SARIF results are located in it when set
+}
+
+// sortedLayers returns the layers of resultsByLayer in ascending order,
+// including custom plugin layers above 7
+func sortedLayers(resultsByLayer map[int][]TestResult) []int {
+	layers := make([]int, 0, len(resultsByLayer))
+	for layer := range resultsByLayer {
+		layers = append(layers, layer)
+	}
+	sort.Ints(layers)
+	return layers
 }
 
 // NewReportGenerator creates a new report generator
@@ -58,30 +83,108 @@ func NewReportGenerator(results []TestResult, testName string) *ReportGenerator
 func (rg *ReportGenerator) GenerateReport(format ReportFormat) (string, error) {
 	timestamp := rg.CreatedAt.Format("20060102_150405")
 	fileName := fmt.Sprintf("%s_%s", rg.TestName, timestamp)
-	filePath := filepath.Join(rg.OutputDir, fileName+"."+string(format))
+	ext := string(format)
+	switch format {
+	case ReportJUnit:
+		// JUnit reports are XML but must not overwrite the ReportXML output
+		ext = "junit.xml"
+	case ReportInflux:
+		// Conventional extension for line protocol files
+		ext = "lp"
+	}
+	filePath := filepath.Join(rg.OutputDir, fileName+"."+ext)
 
 	if err := os.MkdirAll(rg.OutputDir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create report directory: %w", err)
 	}
 
+	var err error
 	switch format {
 	case ReportCSV:
-		return filePath, rg.generateCSVReport(filePath)
+		err = rg.generateCSVReport(filePath)
 	case ReportPDF:
-		return filePath, rg.generatePDFReport(filePath)
+		err = rg.generatePDFReport(filePath)
 	case ReportJSON:
-		return filePath, rg.generateJSONReport(filePath)
+		err = rg.generateJSONReport(filePath)
 	case ReportYAML:
-		return filePath, rg.generateYAMLReport(filePath)
+		err = rg.generateYAMLReport(filePath)
 	case ReportHTML:
-		return filePath, rg.generateHTMLReport(filePath)
+		err = rg.generateHTMLReport(filePath)
 	case ReportMarkdown:
-		return filePath, rg.generateMarkdownReport(filePath)
+		err = rg.generateMarkdownReport(filePath)
 	case ReportXML:
-		return filePath, rg.generateXMLReport(filePath)
+		err = rg.generateXMLReport(filePath)
+	case ReportJUnit:
+		err = rg.generateJUnitReport(filePath)
+	case ReportExcel:
+		err = rg.generateExcelReport(filePath)
+	case ReportSARIF:
+		err = rg.generateSARIFReport(filePath)
+	case ReportInflux:
+		err = rg.generateInfluxReport(filePath)
 	default:
 		return "", fmt.Errorf("unsupported report format: %s", format)
 	}
+	if err != nil {
+		return filePath, err
+	}
+
+	if len(rg.SigningKey) > 0 {
+		if _, err := SignReport(filePath, rg.SigningKey); err != nil {
+			return filePath, err
+		}
+	}
+	return filePath, nil
+}
+
+// reportMAC returns the HMAC-SHA256 of the report at path under key
+func reportMAC(path string, key []byte) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(content)
+	return mac.Sum(nil), nil
+}
+
+// SignReport writes the hex-encoded HMAC-SHA256 of the report at path to
+// path+SignatureExt and returns the signature's path
+func SignReport(path string, key []byte) (string, error) {
+	if len(key) == 0 {
+		return "", fmt.Errorf("report signing key must not be empty")
+	}
+	sum, err := reportMAC(path, key)
+	if err != nil {
+		return "", err
+	}
+	sigPath := path + SignatureExt
+	if err := os.WriteFile(sigPath, []byte(hex.EncodeToString(sum)+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to write report signature: %w", err)
+	}
+	return sigPath, nil
+}
+
+// VerifyReport reports whether the signature in sigPath, as written by
+// SignReport, matches the report at path under key. An error means the
+// check could not be made, not that the report was modified.
+func VerifyReport(path string, sigPath string, key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, fmt.Errorf("report signing key must not be empty")
+	}
+	encoded, err := os.ReadFile(sigPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to read report signature: %w", err)
+	}
+	expected, err := hex.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return false, fmt.Errorf("invalid report signature: %w", err)
+	}
+	sum, err := reportMAC(path, key)
+	if err != nil {
+		return false, err
+	}
+	return hmac.Equal(sum, expected), nil
 }
 
 // GenerateAllReports generates reports in all supported formats
@@ -143,11 +246,8 @@ func (rg *ReportGenerator) GenerateCharts() error {
 func (rg *ReportGenerator) generateStatusChart(filePath string) error {
 	var passed, failed, warning, skipped []chart.Value
 
-	for layer := 1; layer <= 7; layer++ {
-		results, ok := rg.ResultsByLayer[layer]
-		if !ok {
-			continue
-		}
+	for _, layer := range sortedLayers(rg.ResultsByLayer) {
+		results := rg.ResultsByLayer[layer]
 
 		passCount, failCount, warnCount, skipCount := 0, 0, 0, 0
 		for _, result := range results {
@@ -261,15 +361,15 @@ func WriteCSVReport(results []TestResult, path string) error {
 		if err := writer.Write([]string{
 			fmt.Sprintf("%d", r.Layer),
 			r.Name,
-			string(r.Status),
+			r.Status.String(),
 			r.Message,
 			r.StartTime.Format(time.RFC3339),
 			r.EndTime.Format(time.RFC3339),
-			fmt.Sprintf("%.2f", r.Metrics.Duration.Milliseconds()),
+			fmt.Sprintf("%d", r.Metrics.Duration.Milliseconds()),
 			fmt.Sprintf("%.2f", r.Metrics.TransferRate),
-			fmt.Sprintf("%.2f", r.Metrics.Latency.Milliseconds()),
+			fmt.Sprintf("%d", r.Metrics.Latency.Milliseconds()),
 			fmt.Sprintf("%.2f", r.Metrics.PacketLoss),
-			fmt.Sprintf("%.2f", r.Metrics.ResponseTime.Milliseconds()),
+			fmt.Sprintf("%d", r.Metrics.ResponseTime.Milliseconds()),
 		}); err != nil {
 			return fmt.Errorf("failed to write CSV row: %w", err)
 		}
@@ -340,11 +440,8 @@ func WritePDFReport(results []TestResult, path string) error {
 	}
 
 	pdf.SetFont("Arial", "", 12)
-	for layer := 1; layer <= 7; layer++ {
-		layerResults, ok := resultsByLayer[layer]
-		if !ok {
-			continue
-		}
+	for _, layer := range sortedLayers(resultsByLayer) {
+		layerResults := resultsByLayer[layer]
 
 		pdf.SetFont("Arial", "B", 12)
 		pdf.Cell(0, 8, fmt.Sprintf("Layer %d:", layer))
@@ -352,7 +449,7 @@ func WritePDFReport(results []TestResult, path string) error {
 		pdf.SetFont("Arial", "", 12)
 
 		for _, result := range layerResults {
-			statusStr := string(result.Status)
+			statusStr := result.Status.String()
 			var color string
 			switch result.Status {
 			case StatusPassed:
@@ -521,18 +618,15 @@ func (rg *ReportGenerator) generateHTMLReport(path string) error {
 		total, passCount, failCount, warnCount, skipCount)
 
 	// Add layer results
-	for layer := 1; layer <= 7; layer++ {
-		results, ok := rg.ResultsByLayer[layer]
-		if !ok {
-			continue
-		}
+	for _, layer := range sortedLayers(rg.ResultsByLayer) {
+		results := rg.ResultsByLayer[layer]
 
 		content += fmt.Sprintf("<div class=\"layer\">\n<div class=\"layer-title\">Layer %d</div>\n", layer)
 
 		for _, result := range results {
-			statusClass := strings.ToLower(string(result.Status))
+			statusClass := strings.ToLower(result.Status.String())
 			content += fmt.Sprintf("<div class=\"test %s\">\n", statusClass)
-			content += fmt.Sprintf("<div><strong>%s:</strong> %s</div>\n", result.Name, string(result.Status))
+			content += fmt.Sprintf("<div><strong>%s:</strong> %s</div>\n", result.Name, result.Status.String())
 			content += fmt.Sprintf("<div>%s</div>\n", result.Message)
 
 			if result.Metrics.Duration > 0 || result.Metrics.Latency > 0 || result.Metrics.PacketLoss > 0 {
@@ -602,11 +696,8 @@ func (rg *ReportGenerator) generateMarkdownReport(path string) error {
 	md.WriteString(fmt.Sprintf("- **Skipped:** %d\n\n", skipCount))
 
 	// Results by layer
-	for layer := 1; layer <= 7; layer++ {
-		results, ok := rg.ResultsByLayer[layer]
-		if !ok {
-			continue
-		}
+	for _, layer := range sortedLayers(rg.ResultsByLayer) {
+		results := rg.ResultsByLayer[layer]
 
 		md.WriteString(fmt.Sprintf("## Layer %d\n\n", layer))
 
@@ -625,7 +716,7 @@ func (rg *ReportGenerator) generateMarkdownReport(path string) error {
 				statusEmoji = "❓"
 			}
 
-			md.WriteString(fmt.Sprintf("### %s %s: %s\n\n", statusEmoji, result.Name, string(result.Status)))
+			md.WriteString(fmt.Sprintf("### %s %s: %s\n\n", statusEmoji, result.Name, result.Status.String()))
 			md.WriteString(fmt.Sprintf("%s\n\n", result.Message))
 
 			if result.Metrics.Duration > 0 || result.Metrics.Latency > 0 || result.Metrics.PacketLoss > 0 {
@@ -666,11 +757,8 @@ func (rg *ReportGenerator) generateXMLReport(path string) error {
 	xml.WriteString("<TestResults>\n")
 	xml.WriteString(fmt.Sprintf("  <GeneratedAt>%s</GeneratedAt>\n", time.Now().Format(time.RFC3339)))
 
-	for layer := 1; layer <= 7; layer++ {
-		results, ok := rg.ResultsByLayer[layer]
-		if !ok {
-			continue
-		}
+	for _, layer := range sortedLayers(rg.ResultsByLayer) {
+		results := rg.ResultsByLayer[layer]
 
 		xml.WriteString(fmt.Sprintf("  <Layer id=\"%d\">\n", layer))
 
@@ -704,3 +792,351 @@ func (rg *ReportGenerator) generateXMLReport(path string) error {
 
 	return nil
 }
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     float64          `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite groups the test cases of one layer
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      float64         `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is a single test result
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitMessage is the body of a failure or skipped element
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// generateJUnitReport writes the results in JUnit XML format, one test suite
+// per layer, so CI systems can collect them as structured test results
+func (rg *ReportGenerator) generateJUnitReport(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	report := junitTestSuites{Name: rg.TestName}
+
+	for _, layer := range sortedLayers(rg.ResultsByLayer) {
+		results := rg.ResultsByLayer[layer]
+
+		suite := junitTestSuite{
+			Name:      fmt.Sprintf("Layer %d", layer),
+			Timestamp: rg.CreatedAt.Format(time.RFC3339),
+		}
+
+		// Leaf results become test cases so each sub-test is reported individually
+		var addCases func(results []TestResult)
+		addCases = func(results []TestResult) {
+			for _, result := range results {
+				if len(result.SubResults) > 0 {
+					addCases(result.SubResults)
+					continue
+				}
+
+				tc := junitTestCase{
+					Name:      result.Name,
+					ClassName: fmt.Sprintf("layer%d", layer),
+					Time:      result.Metrics.Duration.Seconds(),
+				}
+				switch result.Status {
+				case StatusFailed:
+					tc.Failure = &junitMessage{Message: result.Message, Type: result.Status.String(), Text: result.Message}
+					suite.Failures++
+				case StatusSkipped:
+					tc.Skipped = &junitMessage{Message: result.Message}
+					suite.Skipped++
+				case StatusWarning:
+					tc.SystemOut = fmt.Sprintf("WARNING: %s", result.Message)
+				}
+
+				suite.Tests++
+				suite.Time += tc.Time
+				suite.Cases = append(suite.Cases, tc)
+			}
+		}
+		addCases(results)
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		report.Time += suite.Time
+		report.Suites = append(report.Suites, suite)
+	}
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JUnit report: %w", err)
+	}
+
+	if err := os.WriteFile(path, append([]byte(xml.Header), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write JUnit file: %w", err)
+	}
+
+	return nil
+}
+
+// Excel report sheet names
+const (
+	excelSummarySheet = "Summary"
+	excelLayerSheet   = "By Layer"
+	excelResultsSheet = "All Results"
+)
+
+// excelStatusFills are the row background colours of each status
+var excelStatusFills = map[TestStatus]string{
+	StatusPassed:  "#C6EFCE",
+	StatusFailed:  "#FFC7CE",
+	StatusWarning: "#FFEB9C",
+}
+
+// excelNoStatus marks rows that aren't coloured by status
+const excelNoStatus TestStatus = -1
+
+// excelSheet accumulates the rows of a worksheet and the width of each column
+type excelSheet struct {
+	file   *excelize.File
+	name   string
+	row    int
+	widths []int
+}
+
+// appendRow writes values to the next row and returns its row number
+func (s *excelSheet) appendRow(values ...interface{}) (int, error) {
+	s.row++
+	cell, err := excelize.CoordinatesToCellName(1, s.row)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.file.SetSheetRow(s.name, cell, &values); err != nil {
+		return 0, fmt.Errorf("failed to write %s row %d: %w", s.name, s.row, err)
+	}
+
+	for i, v := range values {
+		width := len([]rune(fmt.Sprint(v)))
+		if i >= len(s.widths) {
+			s.widths = append(s.widths, width)
+		} else if width > s.widths[i] {
+			s.widths[i] = width
+		}
+	}
+	return s.row, nil
+}
+
+// styleRow applies style to the written cells of row
+func (s *excelSheet) styleRow(row, style int) error {
+	first, err := excelize.CoordinatesToCellName(1, row)
+	if err != nil {
+		return err
+	}
+	last, err := excelize.CoordinatesToCellName(len(s.widths), row)
+	if err != nil {
+		return err
+	}
+	return s.file.SetCellStyle(s.name, first, last, style)
+}
+
+// autoFit sizes every column to its longest value, capped so long messages
+// do not produce unreadably wide columns
+func (s *excelSheet) autoFit() error {
+	for i, width := range s.widths {
+		col, err := excelize.ColumnNumberToName(i + 1)
+		if err != nil {
+			return err
+		}
+		if width > 80 {
+			width = 80
+		}
+		if err := s.file.SetColWidth(s.name, col, col, float64(width+2)); err != nil {
+			return fmt.Errorf("failed to size %s column %s: %w", s.name, col, err)
+		}
+	}
+	return nil
+}
+
+// generateExcelReport writes an Excel workbook with a status summary, per
+// layer aggregates and every result with its metrics, coloured by status
+func (rg *ReportGenerator) generateExcelReport(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName(f.GetSheetName(0), excelSummarySheet); err != nil {
+		return fmt.Errorf("failed to create %s sheet: %w", excelSummarySheet, err)
+	}
+	for _, name := range []string{excelLayerSheet, excelResultsSheet} {
+		if _, err := f.NewSheet(name); err != nil {
+			return fmt.Errorf("failed to create %s sheet: %w", name, err)
+		}
+	}
+
+	headerStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return fmt.Errorf("failed to create header style: %w", err)
+	}
+	statusStyles := make(map[TestStatus]int, len(excelStatusFills))
+	for status, color := range excelStatusFills {
+		style, err := f.NewStyle(&excelize.Style{
+			Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{color}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create %s style: %w", status, err)
+		}
+		statusStyles[status] = style
+	}
+
+	// writeSheet writes a header row followed by rows, colouring each row by
+	// its status where one is given
+	writeSheet := func(name string, header []interface{}, rows [][]interface{}, statuses []TestStatus) error {
+		sheet := &excelSheet{file: f, name: name}
+		row, err := sheet.appendRow(header...)
+		if err != nil {
+			return err
+		}
+		if err := sheet.styleRow(row, headerStyle); err != nil {
+			return err
+		}
+		for i, values := range rows {
+			row, err := sheet.appendRow(values...)
+			if err != nil {
+				return err
+			}
+			if i < len(statuses) {
+				if style, ok := statusStyles[statuses[i]]; ok {
+					if err := sheet.styleRow(row, style); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return sheet.autoFit()
+	}
+
+	// Summary: overall counts by status
+	counts := make(map[TestStatus]int)
+	for _, r := range rg.AllResults {
+		counts[r.Status]++
+	}
+	summaryStatuses := []TestStatus{StatusPassed, StatusFailed, StatusWarning, StatusSkipped}
+	summaryRows := [][]interface{}{{"Total", len(rg.AllResults)}}
+	rowStatuses := []TestStatus{excelNoStatus}
+	for _, status := range summaryStatuses {
+		summaryRows = append(summaryRows, []interface{}{status.String(), counts[status]})
+		rowStatuses = append(rowStatuses, status)
+	}
+	if err := writeSheet(excelSummarySheet, []interface{}{"Status", "Count"}, summaryRows, rowStatuses); err != nil {
+		return err
+	}
+
+	// By Layer: one row per layer with aggregated metrics
+	var layerRows [][]interface{}
+	for _, layer := range sortedLayers(rg.ResultsByLayer) {
+		results := rg.ResultsByLayer[layer]
+		layerCounts := make(map[TestStatus]int)
+		var duration, latency time.Duration
+		var packetLoss, transferRate float64
+		for _, r := range results {
+			layerCounts[r.Status]++
+			duration += r.Metrics.Duration
+			latency += r.Metrics.Latency
+			packetLoss += r.Metrics.PacketLoss
+			transferRate += r.Metrics.TransferRate
+		}
+		n := len(results)
+		layerRows = append(layerRows, []interface{}{
+			layer,
+			n,
+			layerCounts[StatusPassed],
+			layerCounts[StatusFailed],
+			layerCounts[StatusWarning],
+			layerCounts[StatusSkipped],
+			durationMilliseconds(duration),
+			durationMilliseconds(latency / time.Duration(n)),
+			packetLoss / float64(n),
+			transferRate / float64(n),
+		})
+	}
+	layerHeader := []interface{}{
+		"Layer", "Tests", "Passed", "Failed", "Warnings", "Skipped",
+		"Total Duration (ms)", "Avg Latency (ms)", "Avg Packet Loss (%)", "Avg Transfer Rate (MB/s)",
+	}
+	if err := writeSheet(excelLayerSheet, layerHeader, layerRows, nil); err != nil {
+		return err
+	}
+
+	// All Results: one row per result with every metric in its own column
+	resultRows := make([][]interface{}, 0, len(rg.AllResults))
+	resultStatuses := make([]TestStatus, 0, len(rg.AllResults))
+	for _, r := range rg.AllResults {
+		custom := ""
+		if len(r.Metrics.Custom) > 0 {
+			data, err := json.Marshal(r.Metrics.Custom)
+			if err != nil {
+				return fmt.Errorf("failed to marshal custom metrics of %s: %w", r.Name, err)
+			}
+			custom = string(data)
+		}
+		resultRows = append(resultRows, []interface{}{
+			r.Layer,
+			r.Name,
+			r.Status.String(),
+			r.Message,
+			r.StartTime.Format(time.RFC3339),
+			r.EndTime.Format(time.RFC3339),
+			durationMilliseconds(r.Metrics.Duration),
+			r.Metrics.TransferRate,
+			durationMilliseconds(r.Metrics.Latency),
+			r.Metrics.PacketLoss,
+			durationMilliseconds(r.Metrics.ResponseTime),
+			durationMilliseconds(r.Metrics.Jitter),
+			r.Metrics.ReliabilityPct,
+			custom,
+		})
+		resultStatuses = append(resultStatuses, r.Status)
+	}
+	resultHeader := []interface{}{
+		"Layer", "Test Name", "Status", "Message", "Start Time", "End Time",
+		"Duration (ms)", "Transfer Rate (MB/s)", "Latency (ms)", "Packet Loss (%)",
+		"Response Time (ms)", "Jitter (ms)", "Reliability (%)", "Custom Metrics",
+	}
+	if err := writeSheet(excelResultsSheet, resultHeader, resultRows, resultStatuses); err != nil {
+		return err
+	}
+
+	if err := f.SaveAs(path); err != nil {
+		return fmt.Errorf("failed to write Excel file: %w", err)
+	}
+	return nil
+}
+
+// durationMilliseconds converts d to fractional milliseconds
+func durationMilliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
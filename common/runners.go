@@ -11,34 +11,137 @@ type Layer1Runner struct {
 
 // Layer2Runner implements data link layer tests
 type Layer2Runner struct {
-	Targets  []string
-	CheckMAC bool
-	CheckMTU bool
+	Targets          []string
+	CheckMAC         bool
+	CheckMTU         bool
+	CheckEthernetOAM bool
+	OAMInterface     string
+	RemoteMEP        int
+	OAMTimeout       time.Duration
+	CheckARP         bool
+	DetectVLAN       bool
+
+	CheckFrameErrors    bool
+	FrameErrorThreshold int     // Frame error counters above this warn
+	PacketLossErrorPct  float64 // Frame errors above this percentage of packets fail
 }
 
 // Layer3Runner implements network layer tests
 type Layer3Runner struct {
-	Hostname  string
-	PingAddr  string
-	PingCount int
+	Hostname                string
+	PingAddr                string
+	PingCount               int
+	UseRawICMP              bool // Ping with native ICMP echo requests; false forces the ping binary
+	Traceroute              bool
+	CheckPMTU               bool
+	MaxHops                 int
+	CheckMulticast          bool
+	MulticastInterface      string
+	RequiredMulticastGroups []string
+	CheckWHOIS              bool
+	VerifyIPOwnership       bool
+	ExpectedOrgName         string
+	CheckGeolocation        bool
+	GeoIPDBPath             string
+	MaxExpectedDistanceKm   int
+	LookupASN               bool   // Add the ASN and location of PingAddr to successful ping results
+	CheckReverseDNS         bool   // Verify each address Hostname resolves to has a PTR record that resolves back to it
+	CheckDNSSEC             bool   // Check that Hostname's DNSSEC signatures are returned and validated
+	DNSSECResolver          string // Resolver used for the DNSSEC check, as host or host:port; the first system resolver when empty
+	TestGateway             bool   // Ping the default gateway first and skip the external tests if it does not answer
+	TestMulticast           bool   // Join and leave each of MulticastGroups on every up multicast interface, or only MulticastInterface when set
+	MulticastGroups         []string
+	MulticastSend           bool // Also send a datagram to each joined group and wait for it to loop back
 }
 
 // Layer4Runner implements transport layer tests
 type Layer4Runner struct {
-	TCPAddresses []string
-	UDPAddress   string
-	Timeout      time.Duration
+	TCPAddresses  []string
+	UDPAddress    string
+	Timeout       time.Duration
+	DTLSTargets   []string
+	RequireDTLS13 bool
+
+	CheckICMPRateLimit bool
+
+	EnableBandwidth   bool
+	BandwidthTarget   string // TCP echo service; empty uses a local echo server
+	BandwidthDuration time.Duration
+	BandwidthStreams  int
+	BandwidthSamples  int // Sequential dials per TCP address for the connect time distribution; 1 or less disables it
+
+	LatencyErrorMs int // p95 connect time above this fails the distribution; 0 disables the check
+
+	UDPSamples int // RTT probes sent by the UDP loopback and DNS RTT tests; defaults to 10
+
+	DetectTFO bool // Check TCP Fast Open support locally and against the first TCP address
+
+	MeasureRetransmissions bool    // Sample the system TCP retransmission rate while the other Layer 4 tests run
+	PacketLossWarningPct   float64 // Retransmission rates above this percentage warn; 0 disables the warning
+	PacketLossErrorPct     float64 // Retransmission rates above this percentage fail; 0 disables the failure
 }
 
 // Layer5Runner implements session layer tests
 type Layer5Runner struct {
-	Targets []string
-	Timeout time.Duration
+	Targets     []string
+	Timeout     time.Duration
+	RDPTargets  []string
+	FlagOpenRDP bool
+	RDPServers  []string // Hosts expected to serve RDP; FlagOpenRDP warns about any other target
+
+	MeasureTicketLifetime bool
+	TicketTargets         []string // Defaults to Targets when empty
+	MaxWait               time.Duration
+	PollInterval          time.Duration
+
+	SSHTargets     []string
+	SSHUser        string
+	SSHKeyPath     string // Private key for public key authentication; none when empty
+	KnownHostsPath string // Verify host keys against this known_hosts file when set
+
+	GRPCTargets      []GRPCTarget
+	MQTTTargets      []MQTTTarget
+	WebSocketTargets []WebSocketTarget
+	HTTP2Targets     []string // HTTPS URLs to check for HTTP/2 stream multiplexing
+
+	WireGuardInterfaces      []string
+	WireGuardHandshakeMaxAge time.Duration // Peers without a handshake for longer warn; defaults to 3 minutes
+}
+
+// GRPCTarget is a gRPC server to query with the standard health check RPC
+type GRPCTarget struct {
+	Address            string
+	ServiceName        string // Empty checks the server's overall health
+	TLSEnabled         bool
+	InsecureSkipVerify bool
+}
+
+// MQTTTarget is an MQTT broker to test with a publish/subscribe round trip
+type MQTTTarget struct {
+	BrokerURL  string // e.g. mqtt://broker:1883
+	ClientID   string // Generated when empty
+	Topic      string // Defaults to osi-tester/<client ID>
+	QoS        byte
+	TLSEnabled bool
+}
+
+// WebSocketTarget is a WebSocket echo endpoint to test with a message round trip
+type WebSocketTarget struct {
+	URL         string `json:"url"`                    // ws:// or wss:// URL
+	PingPayload string `json:"ping_payload,omitempty"` // Generated when empty
+	TLSVerify   bool   `json:"tls_verify"`
 }
 
 // Layer6Runner implements presentation layer tests
 type Layer6Runner struct {
-	DataSets []map[string]string
+	DataSets          []map[string]string
+	Algorithms        []string // Compression algorithms: gzip, zstd, brotli
+	TestASN1          bool
+	TestEncryption    bool // AES-GCM round trip of each dataset
+	TestProtobuf      bool // Protobuf (google.protobuf.Struct) round trip of each dataset
+	TestMessagePack   bool // MessagePack round trip of each dataset
+	Test0RTT          bool
+	EarlyDataEndpoint string
 }
 
 // Layer7Runner implements application layer tests
@@ -0,0 +1,200 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// SARIF document constants
+const (
+	sarifVersion  = "2.1.0"
+	sarifSchema   = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifToolName = "osi-tester"
+)
+
+// sarifLog is the root of a SARIF 2.1.0 document
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// sarifRun is one run of the tool
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+// sarifRule describes the checks of one layer
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+// sarifResult is a failed or warning test
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	RuleIndex  int             `json:"ruleIndex"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties map[string]any  `json:"properties,omitempty"`
+}
+
+// sarifLocation names the test a result came from. Tests have no source
+// file, so the physical location is the config file that set the test up,
+// or the report itself when the config was not loaded from a file.
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifRuleID returns the rule ID of layer, e.g. OSI-L3
+func sarifRuleID(layer int) string {
+	return fmt.Sprintf("OSI-L%d", layer)
+}
+
+// sarifArtifactURI returns path as a SARIF artifact URI: a file URI when
+// absolute, otherwise a relative reference with forward slashes
+func sarifArtifactURI(path string) string {
+	if filepath.IsAbs(path) {
+		return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+	}
+	return (&url.URL{Path: filepath.ToSlash(path)}).String()
+}
+
+// sarifLevel maps a status to a SARIF level; only failures and warnings are
+// reported
+func sarifLevel(status TestStatus) (string, bool) {
+	switch status {
+	case StatusFailed:
+		return "error", true
+	case StatusWarning:
+		return "warning", true
+	}
+	return "", false
+}
+
+// generateSARIFReport writes failed and warning tests as a SARIF 2.1.0 log
+// for code scanning tools. There is one rule per tested layer, and each leaf
+// result, as in the JUnit report, becomes a SARIF result.
+func (rg *ReportGenerator) generateSARIFReport(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: sarifToolName, Rules: []sarifRule{}}},
+		Results: []sarifResult{},
+	}
+
+	artifact := filepath.Base(path)
+	if rg.ConfigPath != "" {
+		artifact = rg.ConfigPath
+	}
+	physical := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: sarifArtifactURI(artifact)}}
+
+	for i, layer := range sortedLayers(rg.ResultsByLayer) {
+		results := rg.ResultsByLayer[layer]
+
+		name := fmt.Sprintf("Layer %d Tests", layer)
+		if len(results) > 0 && results[0].Name != "" {
+			name = results[0].Name
+		}
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               sarifRuleID(layer),
+			Name:             fmt.Sprintf("Layer%dTests", layer),
+			ShortDescription: sarifMessage{Text: fmt.Sprintf("Layer %d: %s", layer, name)},
+		})
+
+		var addResults func(results []TestResult, parent string)
+		addResults = func(results []TestResult, parent string) {
+			for _, result := range results {
+				qualified := result.Name
+				if parent != "" {
+					qualified = parent + "/" + result.Name
+				}
+				if len(result.SubResults) > 0 {
+					addResults(result.SubResults, qualified)
+					continue
+				}
+
+				level, ok := sarifLevel(result.Status)
+				if !ok {
+					continue
+				}
+				message := result.Message
+				if message == "" {
+					message = fmt.Sprintf("%s: %s", result.Name, result.Status)
+				}
+				run.Results = append(run.Results, sarifResult{
+					RuleID:    sarifRuleID(layer),
+					RuleIndex: i,
+					Level:     level,
+					Message:   sarifMessage{Text: message},
+					Locations: []sarifLocation{{
+						PhysicalLocation: physical,
+						LogicalLocations: []sarifLogicalLocation{{
+							Name:               result.Name,
+							FullyQualifiedName: fmt.Sprintf("layer%d/%s", layer, qualified),
+							Kind:               "function",
+						}},
+					}},
+					Properties: map[string]any{
+						"status":      result.Status.String(),
+						"duration_ms": durationMilliseconds(result.Metrics.Duration),
+					},
+				})
+			}
+		}
+		addResults(results, "")
+	}
+
+	report := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal SARIF report: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write SARIF file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateSARIFReport(t *testing.T) {
+	results := []TestResult{
+		{Layer: 1, Name: "Physical Layer Tests", Status: StatusPassed},
+		{
+			Layer:  3,
+			Name:   "Network Layer Tests",
+			Status: StatusFailed,
+			SubResults: []TestResult{
+				{Layer: 3, Name: "Ping Test (8.8.8.8)", Status: StatusFailed, Message: "100% packet loss"},
+				{Layer: 3, Name: "Traceroute Test (8.8.8.8)", Status: StatusWarning},
+				{Layer: 3, Name: "DNS Test", Status: StatusPassed},
+			},
+		},
+		{Layer: 9, Name: "Plugin Tests", Status: StatusPassed},
+	}
+
+	rg := NewReportGenerator(results, "sarif")
+	rg.ConfigPath = "configs/layers.yaml"
+	path := filepath.Join(t.TempDir(), "report.sarif")
+	if err := rg.generateSARIFReport(path); err != nil {
+		t.Fatalf("generateSARIFReport: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report sarifLog
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if report.Version != sarifVersion || len(report.Runs) != 1 {
+		t.Fatalf("version %q with %d runs, want %q with 1 run", report.Version, len(report.Runs), sarifVersion)
+	}
+	run := report.Runs[0]
+
+	// One rule per tested layer, including plugin layers
+	if len(run.Tool.Driver.Rules) != len(rg.ResultsByLayer) {
+		t.Errorf("got %d rules, want one for each of the %d tested layers", len(run.Tool.Driver.Rules), len(rg.ResultsByLayer))
+	}
+	for i, layer := range []int{1, 3, 9} {
+		if got := run.Tool.Driver.Rules[i].ID; got != sarifRuleID(layer) {
+			t.Errorf("rule %d has ID %s, want %s", i, got, sarifRuleID(layer))
+		}
+	}
+
+	// Only the failed and warning leaves are results
+	if len(run.Results) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(run.Results), run.Results)
+	}
+	levels := []string{"error", "warning"}
+	for i, result := range run.Results {
+		if result.RuleID != "OSI-L3" || run.Tool.Driver.Rules[result.RuleIndex].ID != result.RuleID {
+			t.Errorf("result %d has rule %s at index %d, want OSI-L3", i, result.RuleID, result.RuleIndex)
+		}
+		if result.Level != levels[i] {
+			t.Errorf("result %d has level %s, want %s", i, result.Level, levels[i])
+		}
+		if len(result.Locations) != 1 {
+			t.Fatalf("result %d has %d locations, want 1", i, len(result.Locations))
+		}
+		if uri := result.Locations[0].PhysicalLocation.ArtifactLocation.URI; uri != "configs/layers.yaml" {
+			t.Errorf("result %d is located in %q, want the config file", i, uri)
+		}
+	}
+	if name := run.Results[0].Locations[0].LogicalLocations[0].FullyQualifiedName; name != "layer3/Network Layer Tests/Ping Test (8.8.8.8)" {
+		t.Errorf("fully qualified name %q", name)
+	}
+}
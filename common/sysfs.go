@@ -0,0 +1,27 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sysClassNet is where linux exposes network interfaces
+var sysClassNet = "/sys/class/net"
+
+// ReadInterfaceStatistic reads a counter of a network interface, such as
+// rx_errors, from /sys/class/net/<iface>/statistics. The counters only exist
+// on linux.
+func ReadInterfaceStatistic(ifaceName, name string) (int64, error) {
+	data, err := os.ReadFile(filepath.Join(sysClassNet, ifaceName, "statistics", name))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value: %w", name, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadInterfaceStatistic(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "eth0", "statistics")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"rx_errors": "42\n", "tx_errors": "garbage\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saved := sysClassNet
+	sysClassNet = root
+	defer func() { sysClassNet = saved }()
+
+	if value, err := ReadInterfaceStatistic("eth0", "rx_errors"); err != nil || value != 42 {
+		t.Errorf("rx_errors = %d, %v, want 42", value, err)
+	}
+	if _, err := ReadInterfaceStatistic("eth0", "tx_errors"); err == nil {
+		t.Error("a non-numeric counter was accepted")
+	}
+	if _, err := ReadInterfaceStatistic("eth0", "collisions"); err == nil {
+		t.Error("a missing counter was accepted")
+	}
+}
@@ -3,33 +3,147 @@ package common
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 )
 
-// TestStatus defines the possible outcomes of a test
-type TestStatus string
+// TestStatus defines the possible outcomes of a test, ordered by severity.
+// It is serialised by name, e.g. "Passed".
+type TestStatus int
 
 const (
-	StatusPassed  TestStatus = "Passed"
-	StatusFailed  TestStatus = "Failed"
-	StatusWarning TestStatus = "Warning"
-	StatusSkipped TestStatus = "Skipped"
-	StatusMixed   TestStatus = "Mixed" // For tests with both passed and failed sub-results
+	StatusPassed  TestStatus = 0
+	StatusSkipped TestStatus = 1
+	StatusWarning TestStatus = 2
+	StatusFailed  TestStatus = 3
+	StatusMixed   TestStatus = 4 // For tests with both passed and failed sub-results
 )
 
+// statusNames are the names of the statuses, indexed by value
+var statusNames = [...]string{
+	StatusPassed:  "Passed",
+	StatusSkipped: "Skipped",
+	StatusWarning: "Warning",
+	StatusFailed:  "Failed",
+	StatusMixed:   "Mixed",
+}
+
+// String returns the status name, e.g. "Passed"
+func (s TestStatus) String() string {
+	if s < 0 || int(s) >= len(statusNames) {
+		return fmt.Sprintf("TestStatus(%d)", int(s))
+	}
+	return statusNames[s]
+}
+
+// ParseTestStatus returns the status named name, ignoring case
+func ParseTestStatus(name string) (TestStatus, error) {
+	for s, n := range statusNames {
+		if strings.EqualFold(n, name) {
+			return TestStatus(s), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown test status: %q", name)
+}
+
+// IsWorseThan reports whether s is more severe than other
+func (s TestStatus) IsWorseThan(other TestStatus) bool {
+	return s > other
+}
+
+// MaxSeverity returns the most severe of statuses, or StatusPassed when there
+// are none
+func MaxSeverity(statuses ...TestStatus) TestStatus {
+	worst := StatusPassed
+	for _, s := range statuses {
+		if s.IsWorseThan(worst) {
+			worst = s
+		}
+	}
+	return worst
+}
+
+// MarshalText encodes the status as its name
+func (s TestStatus) MarshalText() ([]byte, error) {
+	if s < 0 || int(s) >= len(statusNames) {
+		return nil, fmt.Errorf("invalid test status: %d", int(s))
+	}
+	return []byte(statusNames[s]), nil
+}
+
+// UnmarshalText decodes a status name
+func (s *TestStatus) UnmarshalText(text []byte) error {
+	status, err := ParseTestStatus(string(text))
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+// MarshalJSON encodes the status as a JSON string, e.g. "Passed"
+func (s TestStatus) MarshalJSON() ([]byte, error) {
+	text, err := s.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON decodes a status from its name
+func (s *TestStatus) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("test status must be a string: %w", err)
+	}
+	return s.UnmarshalText([]byte(name))
+}
+
 // TestResult represents one outcome from a single layer test or sub-test.
 type TestResult struct {
-	Layer       int          `json:"layer"`
-	Name        string       `json:"name"`                  // Test name
-	Status      TestStatus   `json:"status"`                // e.g. "Passed", "Failed", "Warning", "Skipped"
-	Message     string       `json:"message"`               // Additional details
-	StartTime   time.Time    `json:"start_time"`            // When the test started
-	EndTime     time.Time    `json:"end_time"`              // When the test completed
-	Metrics     TestMetrics  `json:"metrics"`               // Performance metrics
-	SubResults  []TestResult `json:"sub_results,omitempty"` // Results of subtests
-	Diagnostics interface{}  `json:"diagnostics,omitempty"` // Detailed diagnostic data including network and security info
+	Layer         int                `json:"layer"`
+	Name          string             `json:"name"`                     // Test name
+	Status        TestStatus         `json:"status"`                   // e.g. "Passed", "Failed", "Warning", "Skipped"
+	Message       string             `json:"message"`                  // Additional details
+	StartTime     time.Time          `json:"start_time"`               // When the test started
+	EndTime       time.Time          `json:"end_time"`                 // When the test completed
+	Metrics       TestMetrics        `json:"metrics"`                  // Performance metrics
+	SubResults    []TestResult       `json:"sub_results,omitempty"`    // Results of subtests
+	Diagnostics   DiagnosticsPayload `json:"diagnostics,omitempty"`    // Detailed diagnostic data including network and security info
+	RunIteration  int                `json:"run_iteration,omitempty"`  // Watch mode iteration that produced the result
+	BaselineDiff  *BaselineDiff      `json:"baseline_diff,omitempty"`  // Change from the same test in the baseline run, if one was set
+	ResourceUsage *ResourceUsage     `json:"resource_usage,omitempty"` // Process resources used by the layer's run
+}
+
+// Finish records the outcome of r, stamps its end time and duration, and
+// returns a copy of the finished result
+func (r *TestResult) Finish(status TestStatus, msg string) TestResult {
+	r.Status = status
+	r.Message = msg
+	r.EndTime = time.Now()
+	r.Metrics.Duration = r.EndTime.Sub(r.StartTime)
+	return *r
+}
+
+// ResourceUsage records the change in process resources across a layer's
+// run. When layers run concurrently the goroutine delta counts the goroutines
+// the layer started that are still running, and the heap delta also includes
+// the other layers.
+type ResourceUsage struct {
+	GoroutinesDelta int   `json:"goroutines_delta"` // Goroutines after the run minus before
+	HeapDeltaBytes  int64 `json:"heap_delta_bytes"` // HeapInuse after the run minus before
+}
+
+// BaselineDiff compares a test result with the same test in a baseline run
+type BaselineDiff struct {
+	PreviousStatus   TestStatus `json:"previous_status"`
+	StatusChanged    bool       `json:"status_changed"`
+	LatencyDeltaMs   int64      `json:"latency_delta_ms"`   // Current minus baseline latency
+	PacketLossChange float64    `json:"packet_loss_change"` // Current minus baseline packet loss, in percentage points
 }
 
 // TestMetrics contains performance and reliability metrics
@@ -78,9 +192,31 @@ type LayerRunner interface {
 	ValidateConfig() error
 }
 
+// Symbols a layer runner plugin must export
+const (
+	PluginFactorySymbol = "LayerRunnerFactory"
+	PluginLayerSymbol   = "LayerNumber"
+)
+
+// PluginContract is the ABI of an external layer runner. A plugin is a main
+// package built with -buildmode=plugin, using the same Go toolchain and
+// version of this module as the host, that exports:
+//
+//	var LayerNumber int // Layer slot the runner fills; 1-7 replace a built-in layer
+//	func LayerRunnerFactory() common.LayerRunner
+//
+// The factory is called for every test session and must return a new runner
+// whose results carry LayerNumber as their Layer.
+type PluginContract interface {
+	LayerRunner
+}
+
 // TestProgressCallback is a function called to update test progress
 type TestProgressCallback func(layer int, completed, total int, status string)
 
+// TestResultCallback is a function called with the results of each completed layer
+type TestResultCallback func(layer int, results []TestResult)
+
 // TestConfig holds common test configuration
 type TestConfig struct {
 	Enabled       bool                   `json:"enabled"`
@@ -117,3 +253,15 @@ const (
 	DefaultRetryCount  = 3
 	RetryBackoff       = 500 * time.Millisecond
 )
+
+// AlertThresholds defines thresholds for various metrics that trigger alerts
+type AlertThresholds struct {
+	LatencyWarningMs      int     `json:"latency_warning_ms" yaml:"latency_warning_ms" toml:"latency_warning_ms"`                // Latency warning threshold in ms
+	LatencyErrorMs        int     `json:"latency_error_ms" yaml:"latency_error_ms" toml:"latency_error_ms"`                      // Latency error threshold in ms
+	PacketLossWarningPct  float64 `json:"packet_loss_warning_pct" yaml:"packet_loss_warning_pct" toml:"packet_loss_warning_pct"` // Packet loss warning threshold
+	PacketLossErrorPct    float64 `json:"packet_loss_error_pct" yaml:"packet_loss_error_pct" toml:"packet_loss_error_pct"`       // Packet loss error threshold
+	SignalStrengthWarning int     `json:"signal_strength_warning" yaml:"signal_strength_warning" toml:"signal_strength_warning"` // Signal strength warning threshold
+	SignalStrengthError   int     `json:"signal_strength_error" yaml:"signal_strength_error" toml:"signal_strength_error"`       // Signal strength error threshold
+	JitterWarningMs       int     `json:"jitter_warning_ms" yaml:"jitter_warning_ms" toml:"jitter_warning_ms"`                   // Jitter warning threshold in ms
+	JitterErrorMs         int     `json:"jitter_error_ms" yaml:"jitter_error_ms" toml:"jitter_error_ms"`                         // Jitter error threshold in ms
+}
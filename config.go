@@ -3,80 +3,214 @@ package layers
 import (
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/BurntSushi/toml"
+	"go.uber.org/zap"
 	"gopkg.in/yaml.v3"
+
+	"ghostshell/app/layers/common"
 )
 
 // LayerConfig represents configuration for a specific OSI layer
 type LayerConfig struct {
-	Enabled  bool           `json:"enabled" yaml:"enabled"`       // Whether to test this layer
-	Timeout  time.Duration  `json:"timeout" yaml:"timeout"`       // Layer-specific timeout
-	Targets  []string       `json:"targets" yaml:"targets"`       // Target hosts/addresses to test
-	Options  map[string]any `json:"options" yaml:"options"`       // Layer-specific options
-	Retry    RetryConfig    `json:"retry,omitempty" yaml:"retry"` // Retry configuration
-	Priority int            `json:"priority" yaml:"priority"`     // Execution priority (lower runs first)
-	Tags     []string       `json:"tags,omitempty" yaml:"tags"`   // Tags for grouping tests
+	Enabled  bool           `json:"enabled" yaml:"enabled" toml:"enabled"`               // Whether to test this layer
+	Timeout  time.Duration  `json:"timeout" yaml:"timeout" toml:"timeout"`               // Layer-specific timeout
+	Targets  []string       `json:"targets" yaml:"targets" toml:"targets"`               // Target hosts/addresses to test
+	Options  map[string]any `json:"options" yaml:"options" toml:"options"`               // Layer-specific options
+	Retry    RetryConfig    `json:"retry,omitempty" yaml:"retry" toml:"retry,omitempty"` // Retry configuration
+	Priority int            `json:"priority" yaml:"priority" toml:"priority"`            // Execution priority (lower runs first)
+	Tags     []string       `json:"tags,omitempty" yaml:"tags" toml:"tags,omitempty"`    // Tags for grouping tests
 }
 
 // RetryConfig controls retry behavior for failed tests
 type RetryConfig struct {
-	Enabled       bool          `json:"enabled" yaml:"enabled"`               // Whether retries are enabled
-	Count         int           `json:"count" yaml:"count"`                   // Number of retry attempts
-	Interval      time.Duration `json:"interval" yaml:"interval"`             // Time to wait between retries
-	BackoffFactor float64       `json:"backoff_factor" yaml:"backoff_factor"` // Multiplier for increasing wait time
+	Enabled       bool          `json:"enabled" yaml:"enabled" toml:"enabled"`                                                 // Whether retries are enabled
+	Count         int           `json:"count" yaml:"count" toml:"count"`                                                       // Number of retry attempts
+	Interval      time.Duration `json:"interval" yaml:"interval" toml:"interval"`                                              // Time to wait between retries
+	BackoffFactor float64       `json:"backoff_factor" yaml:"backoff_factor" toml:"backoff_factor"`                            // Multiplier for increasing wait time
+	JitterFactor  *float64      `json:"jitter_factor,omitempty" yaml:"jitter_factor,omitempty" toml:"jitter_factor,omitempty"` // Random extra wait as a fraction of the backoff (0-1); 0 disables jitter, unset means 0.2
+	MaxInterval   time.Duration `json:"max_interval" yaml:"max_interval" toml:"max_interval"`                                  // Upper bound on the wait between retries
+}
+
+// Retry defaults for the backoff cap and jitter
+const (
+	defaultRetryJitterFactor = 0.2
+	defaultRetryMaxInterval  = 30 * time.Second
+)
+
+// withDefaults returns rc with an unset JitterFactor and MaxInterval defaulted
+func (rc RetryConfig) withDefaults() RetryConfig {
+	if rc.JitterFactor == nil {
+		jitter := defaultRetryJitterFactor
+		rc.JitterFactor = &jitter
+	}
+	if rc.MaxInterval <= 0 {
+		rc.MaxInterval = defaultRetryMaxInterval
+	}
+	return rc
+}
+
+// jitterFactor returns JitterFactor, or the default when it is unset
+func (rc RetryConfig) jitterFactor() float64 {
+	if rc.JitterFactor == nil {
+		return defaultRetryJitterFactor
+	}
+	return *rc.JitterFactor
+}
+
+// Backoff returns the wait before the given retry attempt (1 for the first
+// retry): Interval grown by BackoffFactor per attempt, plus up to
+// JitterFactor of that again at random, never exceeding MaxInterval when set
+func (rc RetryConfig) Backoff(attempt int) time.Duration {
+	wait := float64(rc.Interval)
+	for i := 1; i < attempt; i++ {
+		wait *= rc.BackoffFactor
+	}
+	// Leave room for the jitter so waits keep varying once the cap is reached
+	jitterFactor := rc.jitterFactor()
+	if limit := float64(rc.MaxInterval) / (1 + jitterFactor); rc.MaxInterval > 0 && wait > limit {
+		wait = limit
+	}
+
+	waitTime := time.Duration(wait)
+	if jitter := int64(wait * jitterFactor); jitter > 0 {
+		waitTime += time.Duration(rand.Int63n(jitter))
+	}
+	if rc.MaxInterval > 0 && waitTime > rc.MaxInterval {
+		waitTime = rc.MaxInterval
+	}
+	return waitTime
+}
+
+// validateRetry checks the jitter and interval cap of a retry configuration,
+// with the defaults setConfigDefaults would give them applied, so a default
+// MaxInterval below Interval is caught
+func validateRetry(name string, rc RetryConfig) error {
+	rc = rc.withDefaults()
+	if jitter := *rc.JitterFactor; jitter < 0 || jitter > 1 {
+		return fmt.Errorf("%s retry jitter factor must be between 0 and 1", name)
+	}
+	if rc.MaxInterval < 0 {
+		return fmt.Errorf("%s retry max interval cannot be negative", name)
+	}
+	if rc.MaxInterval <= rc.Interval {
+		return fmt.Errorf("%s retry max interval %s must be greater than the retry interval %s", name, rc.MaxInterval, rc.Interval)
+	}
+	return nil
 }
 
 // Config represents the structure for application configuration
 type Config struct {
 	// General settings
-	OutputFormat  string        `json:"output_format" yaml:"output_format"`   // Output format: "csv", "pdf", "json", etc.
-	OutputPath    string        `json:"output_path" yaml:"output_path"`       // Path for saving the output
-	LogLevel      string        `json:"log_level" yaml:"log_level"`           // Log level: "info", "debug", or "error"
-	GlobalTimeout time.Duration `json:"global_timeout" yaml:"global_timeout"` // Global timeout for all tests
+	Environment          string        `json:"environment,omitempty" yaml:"environment" toml:"environment,omitempty"`                               // Deployment environment: "development", "staging", "production"
+	OutputFormat         string        `json:"output_format" yaml:"output_format" toml:"output_format"`                                             // Output format: "csv", "pdf", "json", etc.
+	OutputPath           string        `json:"output_path" yaml:"output_path" toml:"output_path"`                                                   // Path for saving the output
+	LogLevel             string        `json:"log_level" yaml:"log_level" toml:"log_level"`                                                         // Log level: "info", "debug", or "error"
+	GlobalTimeout        time.Duration `json:"global_timeout" yaml:"global_timeout" toml:"global_timeout"`                                          // Global timeout for all tests
+	APISecret            string        `json:"api_secret,omitempty" yaml:"api_secret" toml:"api_secret,omitempty"`                                  // HS256 secret for REST API tokens; overridden by LAYERS_API_SECRET
+	AllowUnauthenticated bool          `json:"allow_unauthenticated,omitempty" yaml:"allow_unauthenticated" toml:"allow_unauthenticated,omitempty"` // Serve the REST API without tokens when no API secret is set; requests are rejected otherwise
+	ReportSigningKey     string        `json:"report_signing_key,omitempty" yaml:"report_signing_key" toml:"report_signing_key,omitempty"`          // Hex-encoded 32-byte HMAC-SHA256 key for report signatures; overridden by LAYERS_REPORT_SIGNING_KEY
+	SwaggerUI            bool          `json:"swagger_ui,omitempty" yaml:"swagger_ui" toml:"swagger_ui,omitempty"`                                  // Serve Swagger UI for the REST API at /api/v1/docs/
 
 	// Advanced settings
-	ConcurrentMode     bool   `json:"concurrent_mode" yaml:"concurrent_mode"`           // Run tests concurrently
-	MaxConcurrent      int    `json:"max_concurrent" yaml:"max_concurrent"`             // Maximum concurrent tests
-	StopOnFailure      bool   `json:"stop_on_failure" yaml:"stop_on_failure"`           // Stop testing on first failure
-	DependencyMode     string `json:"dependency_mode" yaml:"dependency_mode"`           // How to handle dependencies: "strict", "warn", "ignore"
-	ProgressReporting  bool   `json:"progress_reporting" yaml:"progress_reporting"`     // Enable real-time progress reporting
-	DetailedMetrics    bool   `json:"detailed_metrics" yaml:"detailed_metrics"`         // Collect detailed performance metrics
-	SaveHistoricalData bool   `json:"save_historical_data" yaml:"save_historical_data"` // Save test results for historical comparison
-	HistoryRetention   int    `json:"history_retention" yaml:"history_retention"`       // Number of historical results to keep
+	ConcurrentMode       bool   `json:"concurrent_mode" yaml:"concurrent_mode" toml:"concurrent_mode"`                                          // Run tests concurrently
+	MaxConcurrent        int    `json:"max_concurrent" yaml:"max_concurrent" toml:"max_concurrent"`                                             // Maximum concurrent tests
+	MaxGoroutineLeak     int    `json:"max_goroutine_leak" yaml:"max_goroutine_leak" toml:"max_goroutine_leak"`                                 // Goroutines a layer may leave running before a resource leak warning
+	StopOnFailure        bool   `json:"stop_on_failure" yaml:"stop_on_failure" toml:"stop_on_failure"`                                          // Stop testing on first failure
+	DependencyMode       string `json:"dependency_mode" yaml:"dependency_mode" toml:"dependency_mode"`                                          // How to handle dependencies: "strict", "warn", "ignore"
+	UntaggedLayersPolicy string `json:"untagged_layers_policy,omitempty" yaml:"untagged_layers_policy" toml:"untagged_layers_policy,omitempty"` // Whether layers without tags run under a tag filter: "include" or "exclude"
+	ProgressReporting    bool   `json:"progress_reporting" yaml:"progress_reporting" toml:"progress_reporting"`                                 // Enable real-time progress reporting
+	DetailedMetrics      bool   `json:"detailed_metrics" yaml:"detailed_metrics" toml:"detailed_metrics"`                                       // Collect detailed performance metrics
+	SaveHistoricalData   bool   `json:"save_historical_data" yaml:"save_historical_data" toml:"save_historical_data"`                           // Save test results for historical comparison
+	HistoryRetention     int    `json:"history_retention" yaml:"history_retention" toml:"history_retention"`                                    // Number of historical results to keep
+	HistoryBackend       string `json:"history_backend,omitempty" yaml:"history_backend" toml:"history_backend,omitempty"`                      // History storage: "file" or "sqlite"
+	HistoryDBPath        string `json:"history_db_path,omitempty" yaml:"history_db_path" toml:"history_db_path,omitempty"`                      // SQLite database path for the sqlite backend
 
 	// Global retry configuration (can be overridden per layer)
-	GlobalRetry RetryConfig `json:"global_retry" yaml:"global_retry"` // Global retry settings
+	GlobalRetry RetryConfig `json:"global_retry" yaml:"global_retry" toml:"global_retry"` // Global retry settings
 
 	// Layer-specific configurations
-	Layer1 LayerConfig `json:"layer1" yaml:"layer1"` // Physical Layer
-	Layer2 LayerConfig `json:"layer2" yaml:"layer2"` // Data Link Layer
-	Layer3 LayerConfig `json:"layer3" yaml:"layer3"` // Network Layer
-	Layer4 LayerConfig `json:"layer4" yaml:"layer4"` // Transport Layer
-	Layer5 LayerConfig `json:"layer5" yaml:"layer5"` // Session Layer
-	Layer6 LayerConfig `json:"layer6" yaml:"layer6"` // Presentation Layer
-	Layer7 LayerConfig `json:"layer7" yaml:"layer7"` // Application Layer
+	Layer1 LayerConfig `json:"layer1" yaml:"layer1" toml:"layer1"` // Physical Layer
+	Layer2 LayerConfig `json:"layer2" yaml:"layer2" toml:"layer2"` // Data Link Layer
+	Layer3 LayerConfig `json:"layer3" yaml:"layer3" toml:"layer3"` // Network Layer
+	Layer4 LayerConfig `json:"layer4" yaml:"layer4" toml:"layer4"` // Transport Layer
+	Layer5 LayerConfig `json:"layer5" yaml:"layer5" toml:"layer5"` // Session Layer
+	Layer6 LayerConfig `json:"layer6" yaml:"layer6" toml:"layer6"` // Presentation Layer
+	Layer7 LayerConfig `json:"layer7" yaml:"layer7" toml:"layer7"` // Application Layer
 
 	// Alert thresholds
-	AlertThresholds AlertThresholds `json:"alert_thresholds" yaml:"alert_thresholds"` // Thresholds for alerts
+	AlertThresholds AlertThresholds `json:"alert_thresholds" yaml:"alert_thresholds" toml:"alert_thresholds"` // Thresholds for alerts
+
+	// IP geolocation
+	GeoIPDBPath string `json:"geoip_db_path,omitempty" yaml:"geoip_db_path" toml:"geoip_db_path,omitempty"` // MaxMind GeoIP2/GeoLite2 database used for Layer 3 geolocation and ASN lookups
+
+	// Baseline comparison
+	BaselineRunID string `json:"baseline_run_id,omitempty" yaml:"baseline_run_id" toml:"baseline_run_id,omitempty"` // History run that results are compared against; a test that passed there and no longer does fails
+
+	// Grafana dashboard export
+	GrafanaDatasource string `json:"grafana_datasource,omitempty" yaml:"grafana_datasource" toml:"grafana_datasource,omitempty"` // Default Prometheus datasource of the exported dashboard
+
+	// External exporters
+	Exporters ExportersConfig `json:"exporters,omitempty" yaml:"exporters" toml:"exporters,omitempty"` // Result exporters
+
+	// Run completion notifications
+	Notifications NotificationsConfig `json:"notifications,omitempty" yaml:"notifications" toml:"notifications,omitempty"` // Notifications sent when a run completes
+
+	// External layer runners
+	Plugins             []PluginConfig `json:"plugins,omitempty" yaml:"plugins" toml:"plugins,omitempty"`                                           // Layer runner plugins to load
+	AllowPluginOverride bool           `json:"allow_plugin_override,omitempty" yaml:"allow_plugin_override" toml:"allow_plugin_override,omitempty"` // Let plugins replace built-in layers 1-7
+
+	// sourcePath is the file LoadConfig read the config from
+	sourcePath string
+}
+
+// PluginConfig configures a layer runner loaded from a Go plugin
+type PluginConfig struct {
+	Path        string        `json:"path" yaml:"path" toml:"path"`                              // Plugin .so file
+	LayerNumber int           `json:"layer_number" yaml:"layer_number" toml:"layer_number"`      // Layer slot; must match the plugin's LayerNumber. Above 7 adds a custom layer
+	Timeout     time.Duration `json:"timeout,omitempty" yaml:"timeout" toml:"timeout,omitempty"` // Timeout of a custom layer; defaults to the global timeout
+}
+
+// pluginForLayer returns the plugin configured for layer, if any
+func (c *Config) pluginForLayer(layer int) (PluginConfig, bool) {
+	for _, p := range c.Plugins {
+		if p.LayerNumber == layer {
+			return p, true
+		}
+	}
+	return PluginConfig{}, false
+}
+
+// NotificationsConfig controls where and when run completion notifications are sent
+type NotificationsConfig struct {
+	SlackWebhook string   `json:"slack_webhook,omitempty" yaml:"slack_webhook" toml:"slack_webhook,omitempty"` // Slack incoming webhook URL; Slack is not notified when empty
+	OnStatus     []string `json:"on_status,omitempty" yaml:"on_status" toml:"on_status,omitempty"`             // Run outcomes that notify: "failure", "warning", "always"
+	SummaryURL   string   `json:"summary_url,omitempty" yaml:"summary_url" toml:"summary_url,omitempty"`       // Visualizer URL linked from notifications
+}
+
+// ExportersConfig groups settings for exporting results to external systems
+type ExportersConfig struct {
+	OTLPLogs OTLPLogsConfig `json:"otlp_logs,omitempty" yaml:"otlp_logs" toml:"otlp_logs,omitempty"` // OpenTelemetry log export
 }
 
-// AlertThresholds defines thresholds for various metrics that trigger alerts
-type AlertThresholds struct {
-	LatencyWarningMs      int     `json:"latency_warning_ms" yaml:"latency_warning_ms"`           // Latency warning threshold in ms
-	LatencyErrorMs        int     `json:"latency_error_ms" yaml:"latency_error_ms"`               // Latency error threshold in ms
-	PacketLossWarningPct  float64 `json:"packet_loss_warning_pct" yaml:"packet_loss_warning_pct"` // Packet loss warning threshold
-	PacketLossErrorPct    float64 `json:"packet_loss_error_pct" yaml:"packet_loss_error_pct"`     // Packet loss error threshold
-	SignalStrengthWarning int     `json:"signal_strength_warning" yaml:"signal_strength_warning"` // Signal strength warning threshold
-	SignalStrengthError   int     `json:"signal_strength_error" yaml:"signal_strength_error"`     // Signal strength error threshold
-	JitterWarningMs       int     `json:"jitter_warning_ms" yaml:"jitter_warning_ms"`             // Jitter warning threshold in ms
-	JitterErrorMs         int     `json:"jitter_error_ms" yaml:"jitter_error_ms"`                 // Jitter error threshold in ms
+// OTLPLogsConfig controls exporting test results as OTLP log records
+type OTLPLogsConfig struct {
+	Enabled   bool   `json:"enabled" yaml:"enabled" toml:"enabled"`          // Whether to export results as logs
+	Endpoint  string `json:"endpoint" yaml:"endpoint" toml:"endpoint"`       // Collector host:port for OTLP/HTTP
+	BatchSize int    `json:"batch_size" yaml:"batch_size" toml:"batch_size"` // Maximum records per export batch
+	Insecure  bool   `json:"insecure" yaml:"insecure" toml:"insecure"`       // Use plain HTTP instead of HTTPS
 }
 
+// AlertThresholds defines thresholds for various metrics that trigger alerts.
+// It is shared with the visualization package, which builds alert rules from it.
+type AlertThresholds = common.AlertThresholds
+
 // LoadConfig reads the configuration from a file
 func LoadConfig(filePath string) (*Config, error) {
 	data, err := os.ReadFile(filePath)
@@ -84,12 +218,26 @@ func LoadConfig(filePath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var config Config
-
 	// Determine file format by extension
 	ext := strings.ToLower(filepath.Ext(filePath))
+
+	// Check the document against the schema first, so wrong types and
+	// unknown fields are reported with their paths
+	doc, err := configDocument(data, ext)
+	if err != nil {
+		return nil, err
+	}
+	if errs := ValidateConfigAgainstSchema(doc); len(errs) > 0 {
+		return nil, fmt.Errorf("config does not match schema: %w", ValidationErrors(errs))
+	}
+
+	var config Config
 	switch ext {
 	case ".json":
+		// The schema accepts duration strings that encoding/json does not
+		if data, err = normalizeDurations(data); err != nil {
+			return nil, err
+		}
 		if err := json.Unmarshal(data, &config); err != nil {
 			return nil, fmt.Errorf("failed to parse JSON config: %w", err)
 		}
@@ -97,16 +245,26 @@ func LoadConfig(filePath string) (*Config, error) {
 		if err := yaml.Unmarshal(data, &config); err != nil {
 			return nil, fmt.Errorf("failed to parse YAML config: %w", err)
 		}
+	case ".toml":
+		if err := toml.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse TOML config: %w", err)
+		}
 	default:
 		return nil, fmt.Errorf("unsupported config format: %s", ext)
 	}
+	normalizeLayerOptions(&config)
 
 	// Validate config and set defaults
 	if err := validateConfig(&config); err != nil {
 		return nil, err
 	}
 
+	if err := validateEnvironment(&config); err != nil {
+		return nil, err
+	}
+
 	setConfigDefaults(&config)
+	config.sourcePath = filePath
 	return &config, nil
 }
 
@@ -133,6 +291,11 @@ func SaveConfig(config *Config, filePath string) error {
 		if err != nil {
 			return fmt.Errorf("failed to marshal config to YAML: %w", err)
 		}
+	case ".toml":
+		data, err = toml.Marshal(config)
+		if err != nil {
+			return fmt.Errorf("failed to marshal config to TOML: %w", err)
+		}
 	default:
 		return fmt.Errorf("unsupported config format: %s", ext)
 	}
@@ -148,17 +311,21 @@ func SaveConfig(config *Config, filePath string) error {
 func validateConfig(config *Config) error {
 	// Validate general settings
 	validOutputFormats := map[string]struct{}{
-		"csv":  {},
-		"pdf":  {},
-		"json": {},
-		"yaml": {},
-		"html": {},
-		"md":   {},
-		"xml":  {},
+		"csv":    {},
+		"pdf":    {},
+		"json":   {},
+		"yaml":   {},
+		"html":   {},
+		"md":     {},
+		"xml":    {},
+		"junit":  {},
+		"xlsx":   {},
+		"sarif":  {},
+		"influx": {},
 	}
 
 	if _, valid := validOutputFormats[config.OutputFormat]; !valid {
-		return fmt.Errorf("invalid output format: %s. Allowed formats: csv, pdf, json, yaml, html, md, xml", config.OutputFormat)
+		return fmt.Errorf("invalid output format: %s. Allowed formats: csv, pdf, json, yaml, html, md, xml, junit, xlsx, sarif, influx", config.OutputFormat)
 	}
 
 	validLogLevels := map[string]struct{}{
@@ -172,6 +339,13 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("invalid log level: %s. Allowed levels: info, debug, error, warn", config.LogLevel)
 	}
 
+	// Validate environment
+	if config.Environment != "" {
+		if _, valid := environmentValidators[config.Environment]; !valid {
+			return fmt.Errorf("invalid environment: %s. Allowed environments: development, staging, production", config.Environment)
+		}
+	}
+
 	// Validate dependency mode
 	validDependencyModes := map[string]struct{}{
 		"strict": {},
@@ -183,6 +357,50 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("invalid dependency mode: %s. Allowed modes: strict, warn, ignore", config.DependencyMode)
 	}
 
+	// Validate untagged layers policy
+	if config.UntaggedLayersPolicy != "" && config.UntaggedLayersPolicy != UntaggedLayersInclude && config.UntaggedLayersPolicy != UntaggedLayersExclude {
+		return fmt.Errorf("invalid untagged layers policy: %s. Allowed policies: include, exclude", config.UntaggedLayersPolicy)
+	}
+
+	// Validate notification triggers
+	for _, status := range config.Notifications.OnStatus {
+		switch status {
+		case NotifyOnFailure, NotifyOnWarning, NotifyAlways:
+		default:
+			return fmt.Errorf("invalid notification status: %s. Allowed values: failure, warning, always", status)
+		}
+	}
+
+	// Validate report signing key
+	if config.ReportSigningKey != "" {
+		if _, err := parseReportSigningKey(config.ReportSigningKey); err != nil {
+			return err
+		}
+	}
+
+	// Validate history backend
+	if config.HistoryBackend != "" && config.HistoryBackend != "file" && config.HistoryBackend != "sqlite" {
+		return fmt.Errorf("invalid history backend: %s. Allowed backends: file, sqlite", config.HistoryBackend)
+	}
+
+	// Validate plugins
+	pluginLayers := make(map[int]string)
+	for _, p := range config.Plugins {
+		if p.Path == "" {
+			return fmt.Errorf("plugin for layer %d has no path", p.LayerNumber)
+		}
+		if p.LayerNumber < 1 {
+			return fmt.Errorf("invalid layer number %d for plugin %s", p.LayerNumber, p.Path)
+		}
+		if other, ok := pluginLayers[p.LayerNumber]; ok {
+			return fmt.Errorf("plugins %s and %s both use layer %d", other, p.Path, p.LayerNumber)
+		}
+		pluginLayers[p.LayerNumber] = p.Path
+		if p.LayerNumber <= 7 && !config.AllowPluginOverride {
+			return fmt.Errorf("plugin %s replaces built-in layer %d; set allow_plugin_override to allow it", p.Path, p.LayerNumber)
+		}
+	}
+
 	// Validate global retry settings
 	if config.GlobalRetry.Enabled {
 		if config.GlobalRetry.Count <= 0 {
@@ -191,6 +409,14 @@ func validateConfig(config *Config) error {
 		if config.GlobalRetry.Interval <= 0 {
 			return fmt.Errorf("global retry interval must be greater than 0 when retry is enabled")
 		}
+		if err := validateRetry("global", config.GlobalRetry); err != nil {
+			return err
+		}
+	}
+
+	// Validate exporter settings
+	if config.Exporters.OTLPLogs.Enabled && config.Exporters.OTLPLogs.Endpoint == "" {
+		return fmt.Errorf("OTLP logs endpoint must be specified when the exporter is enabled")
 	}
 
 	// Validate layer configurations
@@ -224,26 +450,166 @@ func validateConfig(config *Config) error {
 				if layer.config.Retry.Interval <= 0 {
 					return fmt.Errorf("%s: retry interval must be greater than 0 when retry is enabled", layer.name)
 				}
+				if err := validateRetry(layer.name, layer.config.Retry); err != nil {
+					return err
+				}
 			}
 		}
 	}
 
-	// Validate alert thresholds
+	// Validate alert thresholds. Development environments accept any
+	// values, so there they are only warned about.
+	for _, err := range alertThresholdErrors(config) {
+		if config.Environment != "development" {
+			return err
+		}
+		zap.L().Warn("Accepting invalid alert thresholds in development environment", zap.Error(err))
+	}
+
+	return nil
+}
+
+// alertThresholdErrors returns every alert threshold whose warning level is
+// not below its error level
+func alertThresholdErrors(config *Config) []error {
+	var errs []error
 	if config.AlertThresholds.LatencyWarningMs >= config.AlertThresholds.LatencyErrorMs {
-		return fmt.Errorf("latency warning threshold must be less than error threshold")
+		errs = append(errs, fmt.Errorf("latency warning threshold must be less than error threshold"))
 	}
 
 	if config.AlertThresholds.PacketLossWarningPct >= config.AlertThresholds.PacketLossErrorPct {
-		return fmt.Errorf("packet loss warning threshold must be less than error threshold")
+		errs = append(errs, fmt.Errorf("packet loss warning threshold must be less than error threshold"))
 	}
 
 	if config.AlertThresholds.JitterWarningMs >= config.AlertThresholds.JitterErrorMs {
-		return fmt.Errorf("jitter warning threshold must be less than error threshold")
+		errs = append(errs, fmt.Errorf("jitter warning threshold must be less than error threshold"))
+	}
+	return errs
+}
+
+// ValidatorFunc checks a configuration against an environment-specific rule
+type ValidatorFunc func(*Config) error
+
+// environmentValidators lists the extra rules applied in each environment
+var environmentValidators = map[string][]ValidatorFunc{
+	"development": {},
+	"staging":     {},
+	"production": {
+		validateProductionSSL,
+		validateProductionLogLevel,
+		validateProductionDependencyMode,
+		validateProductionRetry,
+	},
+}
+
+// validateEnvironment runs the validators registered for the configured environment
+func validateEnvironment(config *Config) error {
+	for _, validator := range environmentValidators[config.Environment] {
+		if err := validator(config); err != nil {
+			return fmt.Errorf("%s environment: %w", config.Environment, err)
+		}
 	}
+	return nil
+}
 
+// validateProductionSSL ensures certificate verification is not disabled for Layer 7
+func validateProductionSSL(config *Config) error {
+	if val, ok := config.Layer7.Options["verify_ssl"]; ok {
+		if verify, ok := val.(bool); ok && !verify {
+			return fmt.Errorf("SSL verification must be enabled for Layer 7 targets")
+		}
+	}
 	return nil
 }
 
+// validateProductionLogLevel disallows debug logging
+func validateProductionLogLevel(config *Config) error {
+	if config.LogLevel == "debug" {
+		return fmt.Errorf("log level must not be debug")
+	}
+	return nil
+}
+
+// validateProductionDependencyMode requires layer dependencies to be honoured
+func validateProductionDependencyMode(config *Config) error {
+	if config.DependencyMode == "ignore" {
+		return fmt.Errorf("dependency mode must not be ignore")
+	}
+	return nil
+}
+
+// validateProductionRetry requires enough global retries to ride out transient failures
+func validateProductionRetry(config *Config) error {
+	if config.GlobalRetry.Count < 3 {
+		return fmt.Errorf("global retry count must be at least 3")
+	}
+	return nil
+}
+
+// normalizeLayerOptions converts the numbers in every layer's options to
+// float64. The runners read numeric options as float64, which is what JSON
+// decodes to, but TOML decodes integers as int64 and YAML as int.
+func normalizeLayerOptions(config *Config) {
+	for _, lc := range []*LayerConfig{&config.Layer1, &config.Layer2, &config.Layer3, &config.Layer4, &config.Layer5, &config.Layer6, &config.Layer7} {
+		for key, value := range lc.Options {
+			lc.Options[key] = normalizeOptionValue(value)
+		}
+	}
+}
+
+// normalizeOptionValue returns v with every number in it, including those in
+// nested tables and arrays, converted to float64
+func normalizeOptionValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = normalizeOptionValue(value)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalizeOptionValue(value)
+		}
+		return m
+	case []any:
+		for i, value := range v {
+			v[i] = normalizeOptionValue(value)
+		}
+		return v
+	case []map[string]any:
+		// TOML arrays of tables
+		s := make([]any, len(v))
+		for i, value := range v {
+			s[i] = normalizeOptionValue(value)
+		}
+		return s
+	case int:
+		return float64(v)
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+	return v
+}
+
 // setConfigDefaults sets default values for optional configuration settings
 func setConfigDefaults(config *Config) {
 	// Set general defaults
@@ -259,14 +625,38 @@ func setConfigDefaults(config *Config) {
 		config.MaxConcurrent = 5
 	}
 
+	if config.MaxGoroutineLeak <= 0 {
+		config.MaxGoroutineLeak = 10
+	}
+
 	if config.DependencyMode == "" {
 		config.DependencyMode = "warn"
 	}
 
+	if config.UntaggedLayersPolicy == "" {
+		config.UntaggedLayersPolicy = UntaggedLayersExclude
+	}
+
 	if config.HistoryRetention <= 0 {
 		config.HistoryRetention = 30
 	}
 
+	if config.HistoryBackend == "" {
+		config.HistoryBackend = "file"
+	}
+
+	if config.HistoryBackend == "sqlite" && config.HistoryDBPath == "" {
+		config.HistoryDBPath = filepath.Join(common.MetricsDir, "history.db")
+	}
+
+	if config.Notifications.SlackWebhook != "" && len(config.Notifications.OnStatus) == 0 {
+		config.Notifications.OnStatus = []string{NotifyOnFailure}
+	}
+
+	if config.GrafanaDatasource == "" {
+		config.GrafanaDatasource = "Prometheus"
+	}
+
 	// Set global retry defaults
 	if config.GlobalRetry.Enabled && config.GlobalRetry.Count <= 0 {
 		config.GlobalRetry.Count = 3
@@ -280,6 +670,10 @@ func setConfigDefaults(config *Config) {
 		config.GlobalRetry.BackoffFactor = 1.5
 	}
 
+	if config.GlobalRetry.Enabled {
+		config.GlobalRetry = config.GlobalRetry.withDefaults()
+	}
+
 	// Set layer-specific defaults
 	layers := []*LayerConfig{
 		&config.Layer1,
@@ -309,6 +703,10 @@ func setConfigDefaults(config *Config) {
 		if !layer.Retry.Enabled && config.GlobalRetry.Enabled {
 			layer.Retry = config.GlobalRetry
 		}
+
+		if layer.Retry.Enabled {
+			layer.Retry = layer.Retry.withDefaults()
+		}
 	}
 
 	// Set default alert thresholds
@@ -363,10 +761,40 @@ func (c *Config) GetLayerConfig(layer int) (LayerConfig, error) {
 	case 7:
 		return c.Layer7, nil
 	default:
+		// Custom layers added by plugins are always enabled
+		if p, ok := c.pluginForLayer(layer); ok {
+			timeout := p.Timeout
+			if timeout <= 0 {
+				timeout = c.GlobalTimeout
+			}
+			return LayerConfig{Enabled: true, Timeout: timeout}, nil
+		}
 		return LayerConfig{}, fmt.Errorf("invalid layer: %d", layer)
 	}
 }
 
+// restrictLayers disables every layer not in layers, dropping the plugins of
+// custom layers that aren't selected
+func (c *Config) restrictLayers(layers []int) {
+	selected := make(map[int]bool, len(layers))
+	for _, l := range layers {
+		selected[l] = true
+	}
+	for i, lc := range []*LayerConfig{&c.Layer1, &c.Layer2, &c.Layer3, &c.Layer4, &c.Layer5, &c.Layer6, &c.Layer7} {
+		if !selected[i+1] {
+			lc.Enabled = false
+		}
+	}
+
+	var plugins []PluginConfig
+	for _, p := range c.Plugins {
+		if p.LayerNumber <= 7 || selected[p.LayerNumber] {
+			plugins = append(plugins, p)
+		}
+	}
+	c.Plugins = plugins
+}
+
 // GetEnabledLayers returns a list of enabled layer numbers in priority order
 func (c *Config) GetEnabledLayers() []int {
 	type layerInfo struct {
@@ -410,7 +838,36 @@ func (c *Config) GetEnabledLayers() []int {
 		result[i] = l.layer
 	}
 
-	return result
+	// Custom plugin layers run after the built-in ones
+	return append(result, c.customLayers()...)
+}
+
+// customLayers returns the layers above 7 added by plugins, in order
+func (c *Config) customLayers() []int {
+	var custom []int
+	for _, p := range c.Plugins {
+		if p.LayerNumber > 7 {
+			custom = append(custom, p.LayerNumber)
+		}
+	}
+	sort.Ints(custom)
+	return custom
+}
+
+// AllLayers returns every layer that can be tested, enabled or not: the
+// built-in layers 1-7 followed by the custom layers added by plugins
+func (c *Config) AllLayers() []int {
+	return append([]int{1, 2, 3, 4, 5, 6, 7}, c.customLayers()...)
+}
+
+// HasLayer reports whether layer is a built-in layer or a custom layer added
+// by a plugin
+func (c *Config) HasLayer(layer int) bool {
+	if layer >= 1 && layer <= 7 {
+		return true
+	}
+	_, ok := c.pluginForLayer(layer)
+	return ok
 }
 
 // PrintConfig displays the configuration values
@@ -422,11 +879,18 @@ func PrintConfig(config *Config) {
 	fmt.Printf("  Global Timeout: %s\n", config.GlobalTimeout)
 	fmt.Printf("  Concurrent Mode: %v\n", config.ConcurrentMode)
 	fmt.Printf("  Max Concurrent: %d\n", config.MaxConcurrent)
+	fmt.Printf("  Max Goroutine Leak: %d\n", config.MaxGoroutineLeak)
 	fmt.Printf("  Stop On Failure: %v\n", config.StopOnFailure)
 	fmt.Printf("  Dependency Mode: %s\n", config.DependencyMode)
+	fmt.Printf("  Untagged Layers Policy: %s\n", config.UntaggedLayersPolicy)
 	fmt.Printf("  Progress Reporting: %v\n", config.ProgressReporting)
 	fmt.Printf("  Save Historical Data: %v\n", config.SaveHistoricalData)
 	fmt.Printf("  History Retention: %d days\n", config.HistoryRetention)
+	fmt.Printf("  History Backend: %s\n", config.HistoryBackend)
+	if config.HistoryBackend == "sqlite" {
+		fmt.Printf("  History Database: %s\n", config.HistoryDBPath)
+	}
+	fmt.Printf("  Grafana Datasource: %s\n", config.GrafanaDatasource)
 
 	fmt.Println("\nGlobal Retry Configuration:")
 	fmt.Printf("  Enabled: %v\n", config.GlobalRetry.Enabled)
@@ -434,6 +898,8 @@ func PrintConfig(config *Config) {
 		fmt.Printf("  Count: %d\n", config.GlobalRetry.Count)
 		fmt.Printf("  Interval: %s\n", config.GlobalRetry.Interval)
 		fmt.Printf("  Backoff Factor: %.2f\n", config.GlobalRetry.BackoffFactor)
+		fmt.Printf("  Jitter Factor: %.2f\n", config.GlobalRetry.jitterFactor())
+		fmt.Printf("  Max Interval: %s\n", config.GlobalRetry.MaxInterval)
 	}
 
 	fmt.Println("\nAlert Thresholds:")
@@ -494,22 +960,27 @@ func CreateDefaultConfig(filePath string) error {
 		LogLevel:      "info",
 		GlobalTimeout: 30 * time.Second,
 
-		ConcurrentMode:     true,
-		MaxConcurrent:      5,
-		StopOnFailure:      false,
-		DependencyMode:     "warn",
-		ProgressReporting:  true,
-		DetailedMetrics:    true,
-		SaveHistoricalData: true,
-		HistoryRetention:   30,
+		ConcurrentMode:       true,
+		MaxConcurrent:        5,
+		MaxGoroutineLeak:     10,
+		StopOnFailure:        false,
+		DependencyMode:       "warn",
+		UntaggedLayersPolicy: UntaggedLayersExclude,
+		ProgressReporting:    true,
+		DetailedMetrics:      true,
+		SaveHistoricalData:   true,
+		HistoryRetention:     30,
 
 		GlobalRetry: RetryConfig{
 			Enabled:       true,
 			Count:         3,
 			Interval:      500 * time.Millisecond,
 			BackoffFactor: 1.5,
+			MaxInterval:   defaultRetryMaxInterval,
 		},
 
+		GrafanaDatasource: "Prometheus",
+
 		AlertThresholds: AlertThresholds{
 			LatencyWarningMs:      100,
 			LatencyErrorMs:        500,
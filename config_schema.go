@@ -0,0 +1,239 @@
+package layers
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// configSchemaFile is the JSON Schema a configuration document must match
+const configSchemaFile = "config.schema.json"
+
+//go:embed config.schema.json
+var configSchemaFS embed.FS
+
+var (
+	configSchemaOnce sync.Once
+	configSchema     *gojsonschema.Schema
+	configSchemaErr  error
+)
+
+// ValidationError is a schema violation at one field of a configuration
+// document. Field is the dotted path to the field, e.g. "layer1.timeout" or
+// "plugins.0.path", and is empty for the document itself.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors is the error LoadConfig returns when the configuration
+// does not match the schema
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// durationFormatChecker implements the "duration" format: a non-negative
+// time.ParseDuration string. Numbers are checked by the schema's minimum.
+type durationFormatChecker struct{}
+
+func (durationFormatChecker) IsFormat(input interface{}) bool {
+	s, ok := input.(string)
+	if !ok {
+		return true
+	}
+	d, err := time.ParseDuration(s)
+	return err == nil && d >= 0
+}
+
+// loadConfigSchema compiles the embedded schema once
+func loadConfigSchema() (*gojsonschema.Schema, error) {
+	configSchemaOnce.Do(func() {
+		gojsonschema.FormatCheckers.Add("duration", durationFormatChecker{})
+		data, err := configSchemaFS.ReadFile(configSchemaFile)
+		if err != nil {
+			configSchemaErr = fmt.Errorf("failed to read config schema: %w", err)
+			return
+		}
+		configSchema, configSchemaErr = gojsonschema.NewSchema(gojsonschema.NewBytesLoader(data))
+		if configSchemaErr != nil {
+			configSchemaErr = fmt.Errorf("failed to compile config schema: %w", configSchemaErr)
+		}
+	})
+	return configSchema, configSchemaErr
+}
+
+// ValidateConfigAgainstSchema checks a JSON configuration document against
+// the embedded config.schema.json and returns every violation, sorted by
+// field. It catches wrong types, unknown or missing fields and out of range
+// values before the document is decoded into a Config.
+func ValidateConfigAgainstSchema(data []byte) []ValidationError {
+	schema, err := loadConfigSchema()
+	if err != nil {
+		return []ValidationError{{Message: err.Error()}}
+	}
+
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		return []ValidationError{{Message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	errs := make([]ValidationError, 0, len(result.Errors()))
+	for _, re := range result.Errors() {
+		errs = append(errs, ValidationError{Field: schemaErrorField(re), Message: re.Description()})
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Field < errs[j].Field
+	})
+	return errs
+}
+
+// schemaErrorField returns the path of the field a schema error is about.
+// Missing and unknown properties are reported by gojsonschema against their
+// parent object, so the property name is appended.
+func schemaErrorField(re gojsonschema.ResultError) string {
+	field := re.Field()
+	if field == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+		field = ""
+	}
+	switch re.Type() {
+	case "required", "additional_property_not_allowed":
+		if property, ok := re.Details()["property"].(string); ok {
+			if field == "" {
+				return property
+			}
+			return field + "." + property
+		}
+	}
+	return field
+}
+
+// normalizeDurations rewrites the duration strings a schema-valid JSON
+// configuration may contain, e.g. "30s", as integer nanoseconds so the
+// document decodes into time.Duration fields with encoding/json. YAML and
+// TOML decoders parse duration strings themselves.
+func normalizeDurations(data []byte) ([]byte, error) {
+	var doc map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON config: %w", err)
+	}
+
+	changed := false
+	normalize := func(obj map[string]interface{}, key string) error {
+		s, ok := obj[key].(string)
+		if !ok {
+			return nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q for %s: %w", s, key, err)
+		}
+		obj[key] = int64(d)
+		changed = true
+		return nil
+	}
+	normalizeRetry := func(retry interface{}) error {
+		rc, ok := retry.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if err := normalize(rc, "interval"); err != nil {
+			return err
+		}
+		return normalize(rc, "max_interval")
+	}
+
+	if err := normalize(doc, "global_timeout"); err != nil {
+		return nil, err
+	}
+	if err := normalizeRetry(doc["global_retry"]); err != nil {
+		return nil, err
+	}
+	for layer := 1; layer <= 7; layer++ {
+		lc, ok := doc[fmt.Sprintf("layer%d", layer)].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if err := normalize(lc, "timeout"); err != nil {
+			return nil, err
+		}
+		if err := normalizeRetry(lc["retry"]); err != nil {
+			return nil, err
+		}
+	}
+	if plugins, ok := doc["plugins"].([]interface{}); ok {
+		for _, p := range plugins {
+			if pc, ok := p.(map[string]interface{}); ok {
+				if err := normalize(pc, "timeout"); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if !changed {
+		return data, nil
+	}
+	normalized, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode normalized config: %w", err)
+	}
+	return normalized, nil
+}
+
+// configDocument converts a configuration file in the format given by its
+// extension to JSON so it can be checked against the schema
+func configDocument(data []byte, ext string) ([]byte, error) {
+	var doc interface{}
+	switch ext {
+	case ".json":
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON config: %w", err)
+		}
+		return data, nil
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML config: %w", err)
+		}
+	case ".toml":
+		var table map[string]interface{}
+		if err := toml.Unmarshal(data, &table); err != nil {
+			return nil, fmt.Errorf("failed to parse TOML config: %w", err)
+		}
+		doc = table
+	default:
+		return nil, fmt.Errorf("unsupported config format: %s", ext)
+	}
+
+	converted, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert config to JSON for schema validation: %w", err)
+	}
+	return converted, nil
+}
@@ -0,0 +1,304 @@
+package layers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	rc := RetryConfig{
+		Enabled:       true,
+		Count:         10,
+		Interval:      time.Second,
+		BackoffFactor: 2,
+		MaxInterval:   10 * time.Second,
+	}.withDefaults()
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 50; i++ {
+			wait := rc.Backoff(attempt)
+			if wait > rc.MaxInterval {
+				t.Fatalf("attempt %d waited %s, more than MaxInterval %s", attempt, wait, rc.MaxInterval)
+			}
+			if wait < rc.Interval {
+				t.Fatalf("attempt %d waited %s, less than Interval %s", attempt, wait, rc.Interval)
+			}
+			seen[wait] = true
+		}
+		// Jitter keeps the waits apart, even once the cap is reached
+		if len(seen) < 2 {
+			t.Errorf("attempt %d always waited %v, want jitter", attempt, seen)
+		}
+	}
+}
+
+func TestRetryBackoffWithoutJitter(t *testing.T) {
+	off := 0.0
+	rc := RetryConfig{
+		Interval:      time.Second,
+		BackoffFactor: 2,
+		JitterFactor:  &off,
+		MaxInterval:   5 * time.Second,
+	}.withDefaults()
+
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := rc.Backoff(i + 1); got != w {
+			t.Errorf("attempt %d waited %s, want exactly %s with jitter disabled", i+1, got, w)
+		}
+	}
+}
+
+func TestValidateRetry(t *testing.T) {
+	half, tooMuch := 0.5, 1.5
+	tests := []struct {
+		name    string
+		rc      RetryConfig
+		wantErr string
+	}{
+		{"defaults", RetryConfig{Interval: time.Second}, ""},
+		{"explicit", RetryConfig{Interval: time.Second, JitterFactor: &half, MaxInterval: time.Minute}, ""},
+		{"jitter above 1", RetryConfig{Interval: time.Second, JitterFactor: &tooMuch}, "jitter factor"},
+		{"cap below interval", RetryConfig{Interval: 10 * time.Second, MaxInterval: 5 * time.Second}, "max interval"},
+		// The 30s default cap is checked too, not only an explicit one
+		{"interval above default cap", RetryConfig{Interval: time.Minute}, "max interval 30s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRetry("global", tt.rc)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFormatsAgree(t *testing.T) {
+	dir := t.TempDir()
+	loaded := make(map[string]*Config)
+	for _, ext := range []string{".json", ".yaml", ".toml"} {
+		path := filepath.Join(dir, "config"+ext)
+		if err := CreateDefaultConfig(path); err != nil {
+			t.Fatalf("CreateDefaultConfig(%s): %v", ext, err)
+		}
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("LoadConfig(%s): %v", ext, err)
+		}
+
+		// Integer options reach the runners as float64 whatever the format
+		for key, want := range map[string]float64{"attempt_count": 3, "min_signal_strength": 50} {
+			if got, ok := config.Layer1.Options[key].(float64); !ok || got != want {
+				t.Errorf("%s: layer 1 option %s = %#v, want float64 %v", ext, key, config.Layer1.Options[key], want)
+			}
+		}
+		config.sourcePath = ""
+		loaded[ext] = config
+	}
+
+	// A TOML round trip gives the same config as JSON
+	if !reflect.DeepEqual(loaded[".toml"], loaded[".json"]) {
+		t.Errorf("config loaded from TOML differs from JSON:\n%+v\n%+v", loaded[".toml"], loaded[".json"])
+	}
+}
+
+func TestNormalizeOptionValue(t *testing.T) {
+	got := normalizeOptionValue(map[string]any{
+		"count":  int64(3),
+		"ratio":  float32(0.5),
+		"name":   "eth0",
+		"nested": map[string]any{"port": 443},
+		"list":   []any{int64(1), "two", map[any]any{"three": uint8(3)}},
+		"tables": []map[string]any{{"pps": int64(100)}},
+	})
+	want := map[string]any{
+		"count":  3.0,
+		"ratio":  0.5,
+		"name":   "eth0",
+		"nested": map[string]any{"port": 443.0},
+		"list":   []any{1.0, "two", map[string]any{"three": 3.0}},
+		"tables": []any{map[string]any{"pps": 100.0}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalized to %#v, want %#v", got, want)
+	}
+}
+
+// writeJSONConfig writes the default config to a JSON file after edit has
+// changed its document
+func writeJSONConfig(t *testing.T, edit func(doc map[string]interface{})) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := CreateDefaultConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	edit(doc)
+	if data, err = json.Marshal(doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigDurationStrings(t *testing.T) {
+	path := writeJSONConfig(t, func(doc map[string]interface{}) {
+		doc["global_timeout"] = "45s"
+		doc["global_retry"].(map[string]interface{})["interval"] = "2s"
+		doc["global_retry"].(map[string]interface{})["max_interval"] = "1m"
+		layer3 := doc["layer3"].(map[string]interface{})
+		layer3["timeout"] = "1m30s"
+		layer3["retry"] = map[string]interface{}{"enabled": true, "count": 2, "interval": "500ms", "max_interval": "5s"}
+	})
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	for _, tt := range []struct {
+		name      string
+		got, want time.Duration
+	}{
+		{"global_timeout", config.GlobalTimeout, 45 * time.Second},
+		{"global_retry.interval", config.GlobalRetry.Interval, 2 * time.Second},
+		{"global_retry.max_interval", config.GlobalRetry.MaxInterval, time.Minute},
+		{"layer3.timeout", config.Layer3.Timeout, 90 * time.Second},
+		{"layer3.retry.interval", config.Layer3.Retry.Interval, 500 * time.Millisecond},
+		{"layer3.retry.max_interval", config.Layer3.Retry.MaxInterval, 5 * time.Second},
+	} {
+		if tt.got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestValidateConfigAgainstSchemaInvalid(t *testing.T) {
+	path := writeJSONConfig(t, func(doc map[string]interface{}) {
+		doc["global_timeout"] = "soon"
+		doc["layer1"].(map[string]interface{})["enabled"] = "yes"
+		doc["unknown_field"] = true
+	})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := ValidateConfigAgainstSchema(data)
+	fields := make(map[string]bool)
+	for _, e := range errs {
+		fields[e.Field] = true
+	}
+	for _, field := range []string{"global_timeout", "layer1.enabled", "unknown_field"} {
+		if !fields[field] {
+			t.Errorf("no error reported for %s, got %v", field, errs)
+		}
+	}
+
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "does not match schema") {
+		t.Errorf("LoadConfig error %v, want a schema error", err)
+	}
+}
+
+// loadDefaultConfig returns the default config as LoadConfig reads it
+func loadDefaultConfig(t *testing.T) *Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := CreateDefaultConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestEnvironmentValidation(t *testing.T) {
+	base := loadDefaultConfig(t)
+
+	invertedThresholds := func(c *Config) {
+		c.AlertThresholds.LatencyWarningMs = c.AlertThresholds.LatencyErrorMs + 1
+	}
+	tests := []struct {
+		name        string
+		environment string
+		modify      func(*Config)
+		wantErr     string
+	}{
+		{"production accepts the defaults", "production", func(c *Config) {}, ""},
+		{"production requires SSL verification", "production", func(c *Config) { c.Layer7.Options["verify_ssl"] = false }, "SSL verification"},
+		{"production rejects debug logging", "production", func(c *Config) { c.LogLevel = "debug" }, "debug"},
+		{"production rejects ignored dependencies", "production", func(c *Config) { c.DependencyMode = "ignore" }, "dependency mode"},
+		{"production requires 3 retries", "production", func(c *Config) { c.GlobalRetry.Count = 2 }, "at least 3"},
+		{"production rejects inverted thresholds", "production", invertedThresholds, "latency warning threshold"},
+		{"staging allows debug logging", "staging", func(c *Config) { c.LogLevel = "debug" }, ""},
+		{"staging rejects inverted thresholds", "staging", invertedThresholds, "latency warning threshold"},
+		{"development allows disabled SSL verification", "development", func(c *Config) { c.Layer7.Options["verify_ssl"] = false }, ""},
+		{"development accepts inverted thresholds", "development", invertedThresholds, ""},
+		{"unknown environment", "qa", func(c *Config) {}, "invalid environment"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := *base
+			config.Layer7.Options = map[string]any{}
+			config.GlobalRetry.Count = 3
+			config.Environment = tt.environment
+			tt.modify(&config)
+
+			err := validateConfig(&config)
+			if err == nil {
+				err = validateEnvironment(&config)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDevelopmentThresholdsWarn(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	config := loadDefaultConfig(t)
+	config.Environment = "development"
+	config.AlertThresholds.LatencyWarningMs = config.AlertThresholds.LatencyErrorMs + 100
+	config.AlertThresholds.JitterWarningMs = config.AlertThresholds.JitterErrorMs
+	if err := validateConfig(config); err != nil {
+		t.Fatalf("validateConfig: %v", err)
+	}
+	// The latency and jitter thresholds are logged, the packet loss ones are valid
+	if n := logs.Len(); n != 2 {
+		t.Errorf("logged %d warnings, want 2: %v", n, logs.All())
+	}
+}
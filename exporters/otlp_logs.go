@@ -0,0 +1,133 @@
+// Package exporters forwards test results to external observability backends
+package exporters
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	"ghostshell/app/layers/common"
+)
+
+// defaultLogBatchSize is used when no batch size is configured
+const defaultLogBatchSize = 512
+
+// OTLPLogExporter sends test results to an OpenTelemetry Collector as log records
+type OTLPLogExporter struct {
+	Endpoint  string
+	BatchSize int
+
+	provider *sdklog.LoggerProvider
+	logger   log.Logger
+}
+
+// NewOTLPLogExporter creates an exporter that batches records to the OTLP/HTTP endpoint
+func NewOTLPLogExporter(ctx context.Context, endpoint string, batchSize int, insecure bool) (*OTLPLogExporter, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("OTLP logs endpoint must be specified")
+	}
+
+	opts := []otlploghttp.Option{otlploghttp.WithEndpoint(endpoint)}
+	if insecure {
+		opts = append(opts, otlploghttp.WithInsecure())
+	}
+
+	exporter, err := otlploghttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP log exporter: %w", err)
+	}
+
+	return newOTLPLogExporter(endpoint, batchSize, exporter), nil
+}
+
+// newOTLPLogExporter wires an SDK exporter into a batching logger provider
+func newOTLPLogExporter(endpoint string, batchSize int, exporter sdklog.Exporter) *OTLPLogExporter {
+	if batchSize <= 0 {
+		batchSize = defaultLogBatchSize
+	}
+
+	processor := sdklog.NewBatchProcessor(exporter, sdklog.WithExportMaxBatchSize(batchSize))
+	provider := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(processor),
+		sdklog.WithResource(resource.NewSchemaless(semconv.ServiceName("osi-layer-tester"))),
+	)
+
+	return &OTLPLogExporter{
+		Endpoint:  endpoint,
+		BatchSize: batchSize,
+		provider:  provider,
+		logger:    provider.Logger("ghostshell/app/layers"),
+	}
+}
+
+// Export emits one log record per result, including sub-results, and flushes the batch
+func (e *OTLPLogExporter) Export(ctx context.Context, results []common.TestResult) error {
+	for _, result := range results {
+		e.emit(ctx, result)
+	}
+
+	if err := e.provider.ForceFlush(ctx); err != nil {
+		return fmt.Errorf("failed to flush OTLP log records: %w", err)
+	}
+	return nil
+}
+
+// Shutdown flushes any pending records and releases the exporter
+func (e *OTLPLogExporter) Shutdown(ctx context.Context) error {
+	return e.provider.Shutdown(ctx)
+}
+
+// emit converts a result tree into log records
+func (e *OTLPLogExporter) emit(ctx context.Context, result common.TestResult) {
+	e.logger.Emit(ctx, resultToLogRecord(result))
+
+	for _, sub := range result.SubResults {
+		e.emit(ctx, sub)
+	}
+}
+
+// resultToLogRecord maps a TestResult onto an OTLP log record
+func resultToLogRecord(result common.TestResult) log.Record {
+	var record log.Record
+
+	timestamp := result.EndTime
+	if timestamp.IsZero() {
+		timestamp = result.StartTime
+	}
+	record.SetTimestamp(timestamp)
+	record.SetBody(log.StringValue(result.Message))
+
+	severity := severityForStatus(result.Status)
+	record.SetSeverity(severity)
+	record.SetSeverityText(severity.String())
+
+	record.AddAttributes(
+		log.Int("layer", result.Layer),
+		log.String("test_name", result.Name),
+		log.Float64("duration_ms", float64(result.Metrics.Duration.Microseconds())/1000),
+		log.String("status", result.Status.String()),
+	)
+
+	return record
+}
+
+// severityForStatus maps a test status to an OpenTelemetry log severity
+func severityForStatus(status common.TestStatus) log.Severity {
+	switch status {
+	case common.StatusPassed:
+		return log.SeverityInfo
+	case common.StatusWarning, common.StatusMixed:
+		return log.SeverityWarn
+	case common.StatusFailed:
+		return log.SeverityError
+	case common.StatusSkipped:
+		return log.SeverityDebug
+	default:
+		return log.SeverityUndefined
+	}
+}
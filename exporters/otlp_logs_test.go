@@ -0,0 +1,143 @@
+package exporters
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+
+	"ghostshell/app/layers/common"
+)
+
+// logReceiver is an in-memory OTLP/HTTP logs endpoint
+type logReceiver struct {
+	mu       sync.Mutex
+	requests int
+	records  []*logspb.LogRecord
+}
+
+func (lr *logReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/logs" {
+		http.NotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req collogspb.ExportLogsServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lr.mu.Lock()
+	lr.requests++
+	for _, rl := range req.ResourceLogs {
+		for _, sl := range rl.ScopeLogs {
+			lr.records = append(lr.records, sl.LogRecords...)
+		}
+	}
+	lr.mu.Unlock()
+
+	resp, _ := proto.Marshal(&collogspb.ExportLogsServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(resp)
+}
+
+func TestOTLPLogExporter(t *testing.T) {
+	receiver := &logReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exporter, err := NewOTLPLogExporter(ctx, strings.TrimPrefix(server.URL, "http://"), 2, true)
+	if err != nil {
+		t.Fatalf("NewOTLPLogExporter: %v", err)
+	}
+	defer exporter.Shutdown(ctx)
+
+	end := time.Now()
+	results := []common.TestResult{
+		{
+			Layer:   3,
+			Name:    "Network Layer Tests",
+			Status:  common.StatusWarning,
+			Message: "1 of 2 tests warned",
+			EndTime: end,
+			Metrics: common.TestMetrics{Duration: 1500 * time.Microsecond},
+			SubResults: []common.TestResult{
+				{Layer: 3, Name: "Ping Test", Status: common.StatusPassed, Message: "0% loss", EndTime: end},
+				{Layer: 3, Name: "DNS Test", Status: common.StatusFailed, Message: "DNS resolution failed", EndTime: end},
+			},
+		},
+		{Layer: 7, Name: "Application Layer Tests", Status: common.StatusSkipped, Message: "disabled", EndTime: end},
+	}
+	if err := exporter.Export(ctx, results); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+
+	// One record per result including sub-results, sent in batches of 2
+	if len(receiver.records) != 4 {
+		t.Fatalf("received %d records, want 4", len(receiver.records))
+	}
+	if receiver.requests < 2 {
+		t.Errorf("records arrived in %d requests, want batches of at most 2", receiver.requests)
+	}
+
+	want := []struct {
+		name     string
+		body     string
+		severity logspb.SeverityNumber
+		status   common.TestStatus
+	}{
+		{"Network Layer Tests", "1 of 2 tests warned", logspb.SeverityNumber_SEVERITY_NUMBER_WARN, common.StatusWarning},
+		{"Ping Test", "0% loss", logspb.SeverityNumber_SEVERITY_NUMBER_INFO, common.StatusPassed},
+		{"DNS Test", "DNS resolution failed", logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, common.StatusFailed},
+		{"Application Layer Tests", "disabled", logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG, common.StatusSkipped},
+	}
+	for i, w := range want {
+		record := receiver.records[i]
+		if got := record.Body.GetStringValue(); got != w.body {
+			t.Errorf("record %d body %q, want %q", i, got, w.body)
+		}
+		if record.SeverityNumber != w.severity {
+			t.Errorf("record %d severity %s, want %s", i, record.SeverityNumber, w.severity)
+		}
+		if record.TimeUnixNano != uint64(end.UnixNano()) {
+			t.Errorf("record %d timestamp %d, want %d", i, record.TimeUnixNano, end.UnixNano())
+		}
+
+		attrs := make(map[string]string)
+		for _, kv := range record.Attributes {
+			switch {
+			case kv.Key == "layer":
+				attrs[kv.Key] = fmt.Sprint(kv.Value.GetIntValue())
+			case kv.Key == "duration_ms":
+				attrs[kv.Key] = fmt.Sprint(kv.Value.GetDoubleValue())
+			default:
+				attrs[kv.Key] = kv.Value.GetStringValue()
+			}
+		}
+		if attrs["test_name"] != w.name || attrs["status"] != w.status.String() {
+			t.Errorf("record %d attributes %v, want test_name %q and status %q", i, attrs, w.name, w.status)
+		}
+		if i == 0 && (attrs["layer"] != "3" || attrs["duration_ms"] != "1.5") {
+			t.Errorf("record 0 attributes %v, want layer 3 and duration_ms 1.5", attrs)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package layers
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/history"
+)
+
+// healthzPath is the health check endpoint, served without authentication
+// for load balancers and liveness probes
+const healthzPath = "/healthz"
+
+// HealthChecker is a component whose health is reported by /healthz
+type HealthChecker interface {
+	// CheckHealth returns an error when the component is unhealthy
+	CheckHealth() error
+}
+
+// HealthCheckFunc adapts a function to a HealthChecker
+type HealthCheckFunc func() error
+
+// CheckHealth calls f
+func (f HealthCheckFunc) CheckHealth() error {
+	return f()
+}
+
+// HealthResponse is the body of GET /healthz
+type HealthResponse struct {
+	Status        string            `json:"status"` // "ok" or "degraded"
+	Version       string            `json:"version"`
+	UptimeSeconds int64             `json:"uptime_seconds"`
+	Components    map[string]string `json:"components,omitempty"` // Component name to "ok" or "error"
+}
+
+// RegisterHealthCheck adds a component to the /healthz report, replacing
+// any registered under the same name
+func (api *API) RegisterHealthCheck(name string, checker HealthChecker) {
+	api.healthMu.Lock()
+	defer api.healthMu.Unlock()
+	if api.healthChecks == nil {
+		api.healthChecks = make(map[string]HealthChecker)
+	}
+	api.healthChecks[name] = checker
+}
+
+// registerDefaultHealthChecks reports the history store and the Prometheus
+// registry
+func (api *API) registerDefaultHealthChecks() {
+	api.RegisterHealthCheck("history", HealthCheckFunc(func() error {
+		_, err := api.History.List(1, 0, history.OrderDesc)
+		return err
+	}))
+	api.RegisterHealthCheck("metrics", HealthCheckFunc(func() error {
+		_, err := prometheus.DefaultGatherer.Gather()
+		return err
+	}))
+}
+
+// handleHealthz reports 200 when every registered component is healthy and
+// 503 with the failing components otherwise
+func (api *API) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	api.healthMu.RLock()
+	names := make([]string, 0, len(api.healthChecks))
+	checkers := make(map[string]HealthChecker, len(api.healthChecks))
+	for name, checker := range api.healthChecks {
+		names = append(names, name)
+		checkers[name] = checker
+	}
+	api.healthMu.RUnlock()
+	sort.Strings(names)
+
+	response := HealthResponse{
+		Status:        "ok",
+		Version:       APIVersion,
+		UptimeSeconds: int64(time.Since(api.startTime).Seconds()),
+	}
+	status := http.StatusOK
+	if len(names) > 0 {
+		response.Components = make(map[string]string, len(names))
+	}
+	for _, name := range names {
+		if err := checkers[name].CheckHealth(); err != nil {
+			api.Logger.Warn("Health check failed", zap.String("component", name), zap.Error(err))
+			response.Components[name] = "error"
+			response.Status = "degraded"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		response.Components[name] = "ok"
+	}
+
+	api.respondWithJSON(w, status, response)
+}
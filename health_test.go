@@ -0,0 +1,105 @@
+package layers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/history"
+)
+
+// getHealthz calls the /healthz handler and decodes its response
+func getHealthz(t *testing.T, api *API) (int, HealthResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	api.handleHealthz(rec, httptest.NewRequest(http.MethodGet, healthzPath, nil))
+
+	var response HealthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("invalid /healthz body %q: %v", rec.Body, err)
+	}
+	return rec.Code, response
+}
+
+func TestHealthzHealthy(t *testing.T) {
+	api := &API{
+		Logger:    zap.NewNop(),
+		History:   history.NewFileStore(t.TempDir()),
+		startTime: time.Now().Add(-90 * time.Second),
+	}
+	api.registerDefaultHealthChecks()
+
+	code, response := getHealthz(t, api)
+	if code != http.StatusOK {
+		t.Fatalf("status %d, want %d", code, http.StatusOK)
+	}
+	if response.Status != "ok" || response.Version != APIVersion {
+		t.Errorf("status %q version %q, want ok and %s", response.Status, response.Version, APIVersion)
+	}
+	if response.UptimeSeconds < 90 {
+		t.Errorf("uptime_seconds = %d, want at least 90", response.UptimeSeconds)
+	}
+	want := map[string]string{"history": "ok", "metrics": "ok"}
+	if !reflect.DeepEqual(response.Components, want) {
+		t.Errorf("components = %v, want %v", response.Components, want)
+	}
+}
+
+func TestHealthzDegraded(t *testing.T) {
+	api := &API{Logger: zap.NewNop(), startTime: time.Now()}
+	api.RegisterHealthCheck("history", HealthCheckFunc(func() error { return errors.New("database is locked") }))
+	api.RegisterHealthCheck("metrics", HealthCheckFunc(func() error { return nil }))
+
+	code, response := getHealthz(t, api)
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	if response.Status != "degraded" {
+		t.Errorf("status %q, want degraded", response.Status)
+	}
+	want := map[string]string{"history": "error", "metrics": "ok"}
+	if !reflect.DeepEqual(response.Components, want) {
+		t.Errorf("components = %v, want %v", response.Components, want)
+	}
+
+	// Registering under the same name replaces the failing check
+	api.RegisterHealthCheck("history", HealthCheckFunc(func() error { return nil }))
+	if code, _ := getHealthz(t, api); code != http.StatusOK {
+		t.Errorf("status %d after replacing the failing check, want %d", code, http.StatusOK)
+	}
+}
+
+func TestHealthzSkipsAuth(t *testing.T) {
+	t.Setenv(APISecretEnv, "")
+	config := loadDefaultConfig(t)
+	config.APISecret = "test-secret"
+
+	api := &API{
+		Router:    mux.NewRouter(),
+		Config:    config,
+		Logger:    zap.NewNop(),
+		History:   history.NewFileStore(t.TempDir()),
+		startTime: time.Now(),
+	}
+	api.registerDefaultHealthChecks()
+	api.registerRoutes()
+
+	serve := func(path string) int {
+		rec := httptest.NewRecorder()
+		api.Router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+	if code := serve(healthzPath); code != http.StatusOK {
+		t.Errorf("GET %s without a token returned %d, want %d", healthzPath, code, http.StatusOK)
+	}
+	if code := serve("/api/v1/tests"); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/v1/tests without a token returned %d, want %d", code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,85 @@
+package history
+
+import (
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// SearchFilter selects stored runs by their timestamp and results. Zero
+// fields don't filter. A run matches when its timestamp is in range and at
+// least one of its results, or their sub-results, matches Layer, Status and
+// NamePattern together.
+type SearchFilter struct {
+	Layer       int                // Layer of the result; any when 0
+	Status      *common.TestStatus // Status of the result; any when nil
+	From        time.Time          // Earliest run timestamp, inclusive
+	To          time.Time          // Latest run timestamp, inclusive
+	NamePattern string             // Case-insensitive substring of the result name
+	Limit       int                // Page size; all matches when 0 or less
+	Offset      int
+	Order       string // OrderAsc or OrderDesc; newest first when empty
+}
+
+// inRange reports whether a run at timestamp falls between From and To
+func (f SearchFilter) inRange(timestamp time.Time) bool {
+	if !f.From.IsZero() && timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && timestamp.After(f.To) {
+		return false
+	}
+	return true
+}
+
+// matchResult reports whether a single result matches the filter
+func (f SearchFilter) matchResult(result common.TestResult) bool {
+	if f.Layer != 0 && result.Layer != f.Layer {
+		return false
+	}
+	if f.Status != nil && result.Status != *f.Status {
+		return false
+	}
+	if f.NamePattern != "" && !strings.Contains(strings.ToLower(result.Name), strings.ToLower(f.NamePattern)) {
+		return false
+	}
+	return true
+}
+
+// countMatches returns the number of results and sub-results that match
+func (f SearchFilter) countMatches(results []common.TestResult) int {
+	count := 0
+	for _, result := range results {
+		if f.matchResult(result) {
+			count++
+		}
+		count += f.countMatches(result.SubResults)
+	}
+	return count
+}
+
+// Search returns the page of runs matching filter, with the number of
+// matching results in each summary, and the total number of matching runs
+func (s *FileStore) Search(filter SearchFilter) ([]RunSummary, int, error) {
+	runs, err := s.List(0, 0, filter.Order)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	matching := []RunSummary{}
+	for _, run := range runs {
+		if !filter.inRange(run.Timestamp) {
+			continue
+		}
+		results, err := s.Get(run.RunID)
+		if err != nil {
+			continue
+		}
+		if run.Matches = filter.countMatches(results); run.Matches > 0 {
+			matching = append(matching, run)
+		}
+	}
+
+	return page(matching, filter.Limit, filter.Offset), len(matching), nil
+}
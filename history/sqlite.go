@@ -0,0 +1,243 @@
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // Registers the "sqlite" driver
+
+	"ghostshell/app/layers/common"
+)
+
+// sqliteSchema creates the runs table. Results are kept as a JSON document
+// next to the per-status counts used for listing.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	run_id     TEXT PRIMARY KEY,
+	created_at INTEGER NOT NULL,
+	total      INTEGER NOT NULL,
+	passed     INTEGER NOT NULL,
+	failed     INTEGER NOT NULL,
+	warnings   INTEGER NOT NULL,
+	skipped    INTEGER NOT NULL,
+	results    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS runs_created_at ON runs (created_at);
+`
+
+// SQLiteStore keeps run history in a SQLite database
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens or creates the history database at path
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history database directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database %s: %w", path, err)
+	}
+
+	// SQLite allows a single writer; serialise access through one connection
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history database: %w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// Save stores the results of a run, replacing any run with the same ID
+func (s *SQLiteStore) Save(runID string, results []common.TestResult) error {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to encode results: %w", err)
+	}
+
+	summary := summarize(runID, time.Now(), results)
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO runs
+		(run_id, created_at, total, passed, failed, warnings, skipped, results)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		runID, summary.Timestamp.UnixNano(), summary.Total, summary.Passed,
+		summary.Failed, summary.Warnings, summary.Skipped, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save run %s: %w", runID, err)
+	}
+	return nil
+}
+
+// List returns summaries of the stored runs ordered by the time they were saved
+func (s *SQLiteStore) List(limit, offset int, order string) ([]RunSummary, error) {
+	order, err := validateOrder(order)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	direction := "DESC"
+	if order == OrderAsc {
+		direction = "ASC"
+	}
+
+	rows, err := s.db.Query(`SELECT run_id, created_at, total, passed, failed, warnings, skipped
+		FROM runs ORDER BY created_at `+direction+`, run_id `+direction+` LIMIT ? OFFSET ?`,
+		limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []RunSummary{}
+	for rows.Next() {
+		var summary RunSummary
+		var createdAt int64
+		if err := rows.Scan(&summary.RunID, &createdAt, &summary.Total, &summary.Passed,
+			&summary.Failed, &summary.Warnings, &summary.Skipped); err != nil {
+			return nil, fmt.Errorf("failed to read run: %w", err)
+		}
+		summary.Timestamp = time.Unix(0, createdAt)
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+
+	return summaries, nil
+}
+
+// Get returns the results of a run
+func (s *SQLiteStore) Get(runID string) ([]common.TestResult, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT results FROM runs WHERE run_id = ?`, runID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load run %s: %w", runID, err)
+	}
+
+	var results []common.TestResult
+	if err := json.Unmarshal([]byte(data), &results); err != nil {
+		return nil, fmt.Errorf("failed to decode run %s: %w", runID, err)
+	}
+	return results, nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, using \ as the escape
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search returns the page of runs matching filter and the total number of
+// matching runs. The result filters are applied in SQL to the results and
+// sub-results of the stored JSON.
+func (s *SQLiteStore) Search(filter SearchFilter) ([]RunSummary, int, error) {
+	order, err := validateOrder(filter.Order)
+	if err != nil {
+		return nil, 0, err
+	}
+	limit, offset := filter.Limit, filter.Offset
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	direction := "DESC"
+	if order == OrderAsc {
+		direction = "ASC"
+	}
+
+	// Results are the objects of the top-level array and of every
+	// sub_results array. SQLite may quote keys in paths, as ."sub_results".
+	matches := `SELECT COUNT(*) FROM json_tree(runs.results) AS t
+		WHERE t.type = 'object' AND (t.path = '$' OR replace(t.path, '"', '') LIKE '%.sub\_results' ESCAPE '\')`
+	var args []interface{}
+	if filter.Layer != 0 {
+		matches += ` AND json_extract(t.value, '$.layer') = ?`
+		args = append(args, filter.Layer)
+	}
+	if filter.Status != nil {
+		matches += ` AND json_extract(t.value, '$.status') = ?`
+		args = append(args, filter.Status.String())
+	}
+	if filter.NamePattern != "" {
+		matches += ` AND json_extract(t.value, '$.name') LIKE ? ESCAPE '\'`
+		args = append(args, "%"+likeEscaper.Replace(filter.NamePattern)+"%")
+	}
+
+	var where []string
+	if !filter.From.IsZero() {
+		where = append(where, "created_at >= ?")
+		args = append(args, filter.From.UnixNano())
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "created_at <= ?")
+		args = append(args, filter.To.UnixNano())
+	}
+	runs := `SELECT run_id, created_at, total, passed, failed, warnings, skipped, (` + matches + `) AS matches FROM runs`
+	if len(where) > 0 {
+		runs += " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM (`+runs+`) WHERE matches > 0`, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to search runs: %w", err)
+	}
+
+	rows, err := s.db.Query(`SELECT * FROM (`+runs+`) WHERE matches > 0
+		ORDER BY created_at `+direction+`, run_id `+direction+` LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search runs: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := []RunSummary{}
+	for rows.Next() {
+		var summary RunSummary
+		var createdAt int64
+		if err := rows.Scan(&summary.RunID, &createdAt, &summary.Total, &summary.Passed,
+			&summary.Failed, &summary.Warnings, &summary.Skipped, &summary.Matches); err != nil {
+			return nil, 0, fmt.Errorf("failed to read run: %w", err)
+		}
+		summary.Timestamp = time.Unix(0, createdAt)
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to search runs: %w", err)
+	}
+
+	return summaries, total, nil
+}
+
+// Delete removes a run
+func (s *SQLiteStore) Delete(runID string) error {
+	res, err := s.db.Exec(`DELETE FROM runs WHERE run_id = ?`, runID)
+	if err != nil {
+		return fmt.Errorf("failed to delete run %s: %w", runID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
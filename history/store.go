@@ -0,0 +1,228 @@
+// Package history stores the results of past test runs for later comparison
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// ErrNotFound is returned when a run is not in the store
+var ErrNotFound = errors.New("history run not found")
+
+// Sort orders accepted by Store.List
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// RunSummary describes a stored test run without its results
+type RunSummary struct {
+	RunID     string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Total     int       `json:"total"`
+	Passed    int       `json:"passed"`
+	Failed    int       `json:"failed"`
+	Warnings  int       `json:"warnings"`
+	Skipped   int       `json:"skipped"`
+	Matches   int       `json:"matches,omitempty"` // Results matching a search filter
+}
+
+// Store persists test run results
+type Store interface {
+	// Save stores the results of a run, replacing any run with the same ID
+	Save(runID string, results []common.TestResult) error
+	// List returns run summaries ordered by time. A limit of 0 or less returns
+	// every run after offset.
+	List(limit, offset int, order string) ([]RunSummary, error)
+	// Get returns the results of a run, or ErrNotFound
+	Get(runID string) ([]common.TestResult, error)
+	// Search returns the page of runs selected by filter and the total
+	// number of runs it selects
+	Search(filter SearchFilter) ([]RunSummary, int, error)
+	// Delete removes a run, or returns ErrNotFound
+	Delete(runID string) error
+	// Close releases any resources held by the store
+	Close() error
+}
+
+// summarize counts the results of a run by status
+func summarize(runID string, timestamp time.Time, results []common.TestResult) RunSummary {
+	summary := RunSummary{RunID: runID, Timestamp: timestamp, Total: len(results)}
+	for _, result := range results {
+		switch result.Status {
+		case common.StatusPassed:
+			summary.Passed++
+		case common.StatusFailed:
+			summary.Failed++
+		case common.StatusWarning:
+			summary.Warnings++
+		case common.StatusSkipped:
+			summary.Skipped++
+		}
+	}
+	return summary
+}
+
+// validateOrder normalises order, defaulting to newest first
+func validateOrder(order string) (string, error) {
+	switch strings.ToLower(order) {
+	case "", OrderDesc:
+		return OrderDesc, nil
+	case OrderAsc:
+		return OrderAsc, nil
+	default:
+		return "", fmt.Errorf("invalid order: %s. Allowed orders: asc, desc", order)
+	}
+}
+
+// page returns the window of items selected by limit and offset
+func page[T any](items []T, limit, offset int) []T {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// FileStore keeps one JSON report per run in a directory
+type FileStore struct {
+	Dir string
+}
+
+// NewFileStore creates a store that writes run reports to dir
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+// path returns the report file for runID
+func (s *FileStore) path(runID string) string {
+	return filepath.Join(s.Dir, fmt.Sprintf("layer_tests_%s.json", runID))
+}
+
+// Save writes the results of a run to its report file
+func (s *FileStore) Save(runID string, results []common.TestResult) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	if err := common.WriteJSONReport(results, s.path(runID)); err != nil {
+		return fmt.Errorf("failed to save historical data: %w", err)
+	}
+	return nil
+}
+
+// List returns summaries of the stored runs ordered by file modification time
+func (s *FileStore) List(limit, offset int, order string) ([]RunSummary, error) {
+	order, err := validateOrder(order)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// No history yet
+			return []RunSummary{}, nil
+		}
+		return nil, fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	type run struct {
+		id    string
+		mtime time.Time
+	}
+
+	var runs []run
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, "layer_tests_") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		runs = append(runs, run{
+			id:    strings.TrimSuffix(strings.TrimPrefix(name, "layer_tests_"), ".json"),
+			mtime: info.ModTime(),
+		})
+	}
+
+	// Sort by modification time, using the ID to keep the order stable
+	sort.Slice(runs, func(i, j int) bool {
+		a, b := runs[i], runs[j]
+		if order == OrderDesc {
+			a, b = b, a
+		}
+		if !a.mtime.Equal(b.mtime) {
+			return a.mtime.Before(b.mtime)
+		}
+		return a.id < b.id
+	})
+
+	runs = page(runs, limit, offset)
+	summaries := make([]RunSummary, 0, len(runs))
+	for _, r := range runs {
+		// The run ID is the timestamp the session started
+		timestamp, err := time.ParseInLocation("20060102_150405", r.id, time.Local)
+		if err != nil {
+			timestamp = r.mtime
+		}
+
+		results, err := s.Get(r.id)
+		if err != nil {
+			// Keep unreadable runs listed so they can still be deleted
+			summaries = append(summaries, RunSummary{RunID: r.id, Timestamp: timestamp})
+			continue
+		}
+		summaries = append(summaries, summarize(r.id, timestamp, results))
+	}
+
+	return summaries, nil
+}
+
+// Get reads the results of a run from its report file
+func (s *FileStore) Get(runID string) ([]common.TestResult, error) {
+	data, err := os.ReadFile(s.path(runID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	var results []common.TestResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse history file: %w", err)
+	}
+	return results, nil
+}
+
+// Delete removes the report file of a run
+func (s *FileStore) Delete(runID string) error {
+	if err := os.Remove(s.path(runID)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to delete history file: %w", err)
+	}
+	return nil
+}
+
+// Close is a no-op for the file store
+func (s *FileStore) Close() error {
+	return nil
+}
@@ -11,12 +11,13 @@ import (
 
 // InputArgs holds the parsed command-line arguments.
 type InputArgs struct {
-	Layers       []int  // Layers to test (1-7 or empty for all)
-	OutputFormat string // Desired output format: csv, pdf, or json
-	OutputPath   string // Path to save the output report
-	ConfigPath   string // Path to the configuration file
-	Verbose      bool   // Enable verbose output
-	Timeout      int    // Timeout in seconds for each test
+	Layers       []int    // Layers to test (1-7 or empty for all)
+	OutputFormat string   // Desired output format: csv, pdf, or json
+	OutputPath   string   // Path to save the output report
+	ConfigPath   string   // Path to the configuration file
+	Verbose      bool     // Enable verbose output
+	Timeout      int      // Timeout in seconds for each test
+	Tags         []string // Only run layers with at least one of these tags
 }
 
 // ParseInput parses and validates command-line arguments.
@@ -28,6 +29,7 @@ func ParseInput() (*InputArgs, error) {
 	configPath := flag.String("config", "config.json", "Path to the configuration file")
 	verbose := flag.Bool("verbose", false, "Enable verbose output")
 	timeout := flag.Int("timeout", 30, "Timeout in seconds for each test")
+	tags := flag.String("tags", "", "Comma-separated list of tags; only layers with at least one of them are tested")
 
 	// Parse flags
 	flag.Parse()
@@ -45,6 +47,9 @@ func ParseInput() (*InputArgs, error) {
 		}
 	}
 
+	// Parse tags
+	selectedTags := ParseTags(*tags)
+
 	// Validate output format
 	if *outputFormat != "csv" && *outputFormat != "pdf" && *outputFormat != "json" {
 		return nil, fmt.Errorf("invalid output format: %s. Allowed values are: csv, pdf, json", *outputFormat)
@@ -64,6 +69,7 @@ func ParseInput() (*InputArgs, error) {
 		ConfigPath:   *configPath,
 		Verbose:      *verbose,
 		Timeout:      *timeout,
+		Tags:         selectedTags,
 	}, nil
 }
 
@@ -79,6 +85,8 @@ func PrintUsage() {
 	fmt.Println("    osi-tester")
 	fmt.Println("  Test specific layers:")
 	fmt.Println("    osi-tester -layers 3,4 -format json")
+	fmt.Println("  Test only layers tagged tcp or http:")
+	fmt.Println("    osi-tester -tags tcp,http")
 	fmt.Println("  Test with custom timeout:")
 	fmt.Println("    osi-tester -layers 1,2,3 -timeout 60 -verbose")
 }
@@ -0,0 +1,129 @@
+package layer1
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"ghostshell/app/layers/common"
+)
+
+// BondInfo is a bonded or teamed interface and its member links
+type BondInfo = common.BondInfo
+
+// detectBondedInterfaces lists the bonded interfaces on Linux and the NIC
+// teams on Windows. Other platforms have none.
+func detectBondedInterfaces() ([]BondInfo, error) {
+	switch runtime.GOOS {
+	case "linux":
+		return detectLinuxBonds()
+	case "windows":
+		return detectWindowsTeams()
+	default:
+		return nil, nil
+	}
+}
+
+// detectLinuxBonds reads the bonding directory of each interface in sysfs
+func detectLinuxBonds() ([]BondInfo, error) {
+	dirs, err := filepath.Glob("/sys/class/net/*/bonding")
+	if err != nil {
+		return nil, err
+	}
+
+	var bonds []BondInfo
+	for _, dir := range dirs {
+		bond := BondInfo{BondName: filepath.Base(filepath.Dir(dir))}
+
+		// mode reads like "802.3ad 4"
+		if data, err := os.ReadFile(filepath.Join(dir, "mode")); err == nil {
+			if fields := strings.Fields(string(data)); len(fields) > 0 {
+				bond.Mode = fields[0]
+			}
+		}
+		if data, err := os.ReadFile(filepath.Join(dir, "slaves")); err == nil {
+			bond.Members = strings.Fields(string(data))
+		}
+		// Only active-backup style modes have an active slave
+		if data, err := os.ReadFile(filepath.Join(dir, "active_slave")); err == nil {
+			bond.ActiveMember = strings.TrimSpace(string(data))
+		}
+
+		bonds = append(bonds, bond)
+	}
+	return bonds, nil
+}
+
+// detectWindowsTeams lists LBFO teams with Get-NetLbfoTeam. The active member
+// is reported when exactly one member is active and the rest are standby.
+func detectWindowsTeams() ([]BondInfo, error) {
+	cmd := exec.Command("powershell", "-Command",
+		"Get-NetLbfoTeam | ForEach-Object { $active = @(Get-NetLbfoTeamMember -Team $_.Name | Where-Object {$_.AdministrativeMode -eq 'Active'} | Select-Object -ExpandProperty Name); "+
+			"\"$($_.Name)|$($_.TeamingMode)|$($_.Members -join ',')|$($active -join ',')\" }")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Get-NetLbfoTeam failed: %w", err)
+	}
+
+	var bonds []BondInfo
+	for _, line := range strings.Split(string(output), "\n") {
+		parts := strings.Split(strings.TrimSpace(line), "|")
+		if len(parts) != 4 || parts[0] == "" {
+			continue
+		}
+		bond := BondInfo{
+			BondName: parts[0],
+			Mode:     parts[1],
+			Members:  splitList(parts[2]),
+		}
+		if active := splitList(parts[3]); len(active) == 1 && len(bond.Members) > 1 {
+			bond.ActiveMember = active[0]
+		}
+		bonds = append(bonds, bond)
+	}
+	return bonds, nil
+}
+
+// splitList splits a comma separated list, dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// checkBondMembers checks each member of bond and aggregates them. Losing
+// the active member, or every member, fails the bond; losing any other
+// member is a warning since the bond keeps carrying traffic.
+func checkBondMembers(bond BondInfo) (common.TestStatus, string, []string) {
+	var down []string
+	for _, member := range bond.Members {
+		if !checkPhysicalConnection(member) {
+			down = append(down, member)
+		}
+	}
+
+	switch {
+	case len(bond.Members) == 0:
+		return common.StatusWarning, fmt.Sprintf("Bond %s (%s) has no members", bond.BondName, bond.Mode), nil
+	case len(down) == 0:
+		return common.StatusPassed, fmt.Sprintf("Bond %s (%s): all %d members up", bond.BondName, bond.Mode, len(bond.Members)), nil
+	case len(down) == len(bond.Members):
+		return common.StatusFailed, fmt.Sprintf("Bond %s (%s): all members down (%s)",
+			bond.BondName, bond.Mode, strings.Join(down, ", ")), down
+	}
+	for _, member := range down {
+		if member == bond.ActiveMember {
+			return common.StatusFailed, fmt.Sprintf("Bond %s (%s): active member %s is down",
+				bond.BondName, bond.Mode, member), down
+		}
+	}
+	return common.StatusWarning, fmt.Sprintf("Bond %s (%s): %d of %d members down (%s)",
+		bond.BondName, bond.Mode, len(down), len(bond.Members), strings.Join(down, ", ")), down
+}
@@ -0,0 +1,82 @@
+package layer1
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// maxCapturePackets caps the packets captured on each interface
+const maxCapturePackets = 1000
+
+// captureSnaplen is the number of bytes captured from each packet
+const captureSnaplen = 65535
+
+// capture is a packet capture running in the background
+type capture struct {
+	path    string
+	stop    chan struct{}
+	done    chan struct{}
+	packets int
+	err     error
+}
+
+// Stop ends the capture and returns the number of packets written
+func (c *capture) Stop() (int, error) {
+	close(c.stop)
+	<-c.done
+	return c.packets, c.err
+}
+
+// captureDir returns the directory captures are written to
+func (r *Runner) captureDir() string {
+	if r.CaptureDir != "" {
+		return r.CaptureDir
+	}
+	return filepath.Join(common.ReportDir, "captures")
+}
+
+// beginCapture starts capturing on iface into a new .pcap file. A nil capture
+// is returned with the reason when capturing isn't possible.
+func (r *Runner) beginCapture(iface string) (*capture, error) {
+	dir := r.captureDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s.pcap", iface, time.Now().Format("20060102_150405")))
+	return startCapture(iface, path, maxCapturePackets)
+}
+
+// captureResult builds the packet capture sub-test for iface from the
+// outcome of beginCapture and Stop
+func captureResult(iface string, start time.Time, c *capture, startErr error) common.TestResult {
+	result := common.TestResult{
+		Layer:     1,
+		Name:      fmt.Sprintf("Interface %s Packet Capture", iface),
+		StartTime: start,
+	}
+
+	if startErr != nil {
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("Packet capture unavailable on %s: %v", iface, startErr))
+	}
+
+	packets, err := c.Stop()
+	result.Diagnostics.Physical = &common.PhysicalDiagnostics{
+		Interface:       iface,
+		CapturePath:     c.path,
+		PacketsCaptured: common.Ptr(packets),
+	}
+	result.Metrics.Custom = map[string]interface{}{
+		"capture_path":     c.path,
+		"packets_captured": packets,
+	}
+	if err != nil {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Packet capture on %s stopped early after %d packets: %v (saved to %s)",
+			iface, packets, err, c.path))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("Captured %d packets on %s to %s", packets, iface, c.path))
+}
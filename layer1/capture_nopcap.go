@@ -0,0 +1,11 @@
+//go:build !(linux && cgo && pcap)
+
+package layer1
+
+import "errors"
+
+// startCapture needs libpcap, which is only linked into linux builds with the
+// pcap build tag
+func startCapture(iface, path string, maxPackets int) (*capture, error) {
+	return nil, errors.New("built without pcap support (requires linux, cgo and the pcap build tag)")
+}
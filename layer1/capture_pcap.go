@@ -0,0 +1,76 @@
+//go:build linux && cgo && pcap
+
+package layer1
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/gopacket/pcap"
+	"github.com/google/gopacket/pcapgo"
+
+	"ghostshell/app/layers/common"
+)
+
+// captureReadTimeout bounds each read so a stop request is noticed promptly
+const captureReadTimeout = 100 * time.Millisecond
+
+// startCapture captures up to maxPackets packets on iface into a pcap file
+// at path until stopped. Opening the interface needs CAP_NET_RAW.
+func startCapture(iface, path string, maxPackets int) (*capture, error) {
+	handle, err := pcap.OpenLive(iface, captureSnaplen, false, captureReadTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s (CAP_NET_RAW is required): %w", iface, err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		handle.Close()
+		return nil, fmt.Errorf("failed to create capture file: %w", err)
+	}
+	w := pcapgo.NewWriter(f)
+	if err := w.WriteFileHeader(captureSnaplen, handle.LinkType()); err != nil {
+		handle.Close()
+		f.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to write capture header: %w", err)
+	}
+
+	c := &capture{
+		path: path,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(c.done)
+		defer handle.Close()
+		defer f.Close()
+		defer common.RecoverTestGoroutine(1, common.Logger, nil)()
+
+		for c.packets < maxPackets {
+			select {
+			case <-c.stop:
+				return
+			default:
+			}
+
+			data, ci, err := handle.ReadPacketData()
+			if err == pcap.NextErrorTimeoutExpired {
+				continue
+			}
+			if err != nil {
+				c.err = err
+				return
+			}
+			if err := w.WritePacket(ci, data); err != nil {
+				c.err = err
+				return
+			}
+			c.packets++
+		}
+		// The packet limit was reached; wait for the test to finish
+		<-c.stop
+	}()
+	return c, nil
+}
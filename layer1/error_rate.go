@@ -0,0 +1,146 @@
+package layer1
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// errorRateSampleInterval is the time between error counter readings
+const errorRateSampleInterval = time.Second
+
+// errorRateHysteresisSamples is how many consecutive samples must cross a
+// threshold before the alert state changes
+const errorRateHysteresisSamples = 3
+
+// ethernetErrorCounters is a snapshot of an interface's error counters
+type ethernetErrorCounters struct {
+	RxErrors   int64
+	TxErrors   int64
+	Collisions int64
+	Timestamp  time.Time
+}
+
+// ErrorRateSample holds per-second error rates averaged over a monitoring window
+type ErrorRateSample struct {
+	RxErrorsPerSec   float64 `json:"rx_errors_per_sec"`
+	TxErrorsPerSec   float64 `json:"tx_errors_per_sec"`
+	CollisionsPerSec float64 `json:"collisions_per_sec"`
+	OverThreshold    bool    `json:"over_threshold"`
+	Samples          int     `json:"samples"` // Counter readings taken, fewer than asked for when the deadline is near
+}
+
+// getEthernetErrorCounters reads the current error counters for an interface
+func getEthernetErrorCounters(interfaceName string) (ethernetErrorCounters, error) {
+	counters := ethernetErrorCounters{Timestamp: time.Now()}
+
+	if runtime.GOOS != "linux" {
+		return counters, fmt.Errorf("error counters are only supported on linux")
+	}
+
+	fields := []struct {
+		name string
+		dst  *int64
+	}{
+		{"rx_errors", &counters.RxErrors},
+		{"tx_errors", &counters.TxErrors},
+		{"collisions", &counters.Collisions},
+	}
+
+	for _, f := range fields {
+		value, err := common.ReadInterfaceStatistic(interfaceName, f.name)
+		if err != nil {
+			return counters, err
+		}
+		*f.dst = value
+	}
+
+	return counters, nil
+}
+
+// monitorErrorRate samples the interface error counters and applies hysteresis
+// to the combined RX/TX error rate against ErrorRateWarningPPS. The window is
+// shortened to end a sample interval before ctx's deadline.
+func (r *Runner) monitorErrorRate(ctx context.Context, interfaceName string, sampleInterval time.Duration, samples int) (ErrorRateSample, error) {
+	var result ErrorRateSample
+
+	if samples < 2 {
+		return result, fmt.Errorf("at least 2 samples are required to compute a rate")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if fit := int(time.Until(deadline) / sampleInterval); fit < samples {
+			samples = fit
+		}
+	}
+	if samples < 2 {
+		return result, fmt.Errorf("too close to the deadline to take 2 samples %v apart", sampleInterval)
+	}
+
+	prev, err := getEthernetErrorCounters(interfaceName)
+	if err != nil {
+		return result, err
+	}
+	first := prev
+
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+
+	overCount, underCount := 0, 0
+	for i := 1; i < samples; i++ {
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := getEthernetErrorCounters(interfaceName)
+		if err != nil {
+			return result, err
+		}
+
+		// Finite difference between consecutive readings
+		elapsed := current.Timestamp.Sub(prev.Timestamp).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		rate := float64((current.RxErrors-prev.RxErrors)+(current.TxErrors-prev.TxErrors)) / elapsed
+		prev = current
+
+		if !result.OverThreshold {
+			if rate > r.ErrorRateWarningPPS {
+				overCount++
+			} else {
+				overCount = 0
+			}
+			if overCount >= errorRateHysteresisSamples {
+				result.OverThreshold = true
+				underCount = 0
+			}
+		} else {
+			if rate < r.ErrorRateWarningPPS*0.5 {
+				underCount++
+			} else {
+				underCount = 0
+			}
+			if underCount >= errorRateHysteresisSamples {
+				result.OverThreshold = false
+				overCount = 0
+			}
+		}
+	}
+
+	result.Samples = samples
+
+	// Report average rates across the whole window
+	window := prev.Timestamp.Sub(first.Timestamp).Seconds()
+	if window > 0 {
+		result.RxErrorsPerSec = float64(prev.RxErrors-first.RxErrors) / window
+		result.TxErrorsPerSec = float64(prev.TxErrors-first.TxErrors) / window
+		result.CollisionsPerSec = float64(prev.Collisions-first.Collisions) / window
+	}
+
+	return result, nil
+}
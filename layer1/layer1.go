@@ -25,6 +25,26 @@ type Runner struct {
 	AttemptCount      int
 	MinSignalStrength int
 	Interfaces        []string
+
+	// Error rate monitoring with hysteresis
+	MonitorErrorRate    bool
+	ErrorRateWarningPPS float64
+	MonitorSamples      int
+
+	// Utilization and error rate measurement
+	SampleDuration       time.Duration // Measurement window; defaults to 1s
+	PacketLossWarningPct float64       // Error rates above this percentage warn
+	PacketLossErrorPct   float64       // Error rates above this percentage fail
+
+	// Packet capture during the connection test
+	EnableCapture bool
+	CaptureDir    string // Where .pcap files are saved; defaults to captures under the report directory
+
+	// Nearby wireless networks
+	RunWiFiScan bool
+
+	// Link flap monitoring alongside the other tests; disabled when 0
+	MonitorDuration time.Duration
 }
 
 // New creates a new Layer1Runner with the specified parameters
@@ -40,9 +60,14 @@ func New(attemptCount int, minSignalStrength int) *Runner {
 	defaultInterfaces := getDefaultInterfaces()
 
 	return &Runner{
-		AttemptCount:      attemptCount,
-		MinSignalStrength: minSignalStrength,
-		Interfaces:        defaultInterfaces,
+		AttemptCount:         attemptCount,
+		MinSignalStrength:    minSignalStrength,
+		Interfaces:           defaultInterfaces,
+		ErrorRateWarningPPS:  1,
+		MonitorSamples:       10,
+		SampleDuration:       defaultSampleDuration,
+		PacketLossWarningPct: 1,
+		PacketLossErrorPct:   5,
 	}
 }
 
@@ -101,6 +126,9 @@ func (r *Runner) ValidateConfig() error {
 	if r.MinSignalStrength <= 0 || r.MinSignalStrength > 100 {
 		return fmt.Errorf("min signal strength must be between 1 and 100")
 	}
+	if r.MonitorErrorRate && r.MonitorSamples <= errorRateHysteresisSamples {
+		return fmt.Errorf("monitor samples must be greater than %d", errorRateHysteresisSamples)
+	}
 	return nil
 }
 
@@ -130,7 +158,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		parentResult.Status = common.StatusFailed
 		parentResult.Message = fmt.Sprintf("Failed to get network interfaces: %v", err)
 		parentResult.EndTime = time.Now()
-		return []common.TestResult{parentResult}, err
+		return []common.TestResult{parentResult}, common.NewLayerError(1, err)
 	}
 
 	// Filter out loopback interfaces but include all others
@@ -150,9 +178,45 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		return []common.TestResult{parentResult}, nil
 	}
 
+	// Bonded interfaces are judged by their members
+	bonds := make(map[string]BondInfo)
+	bondOf := make(map[string]BondInfo)
+	detected, err := detectBondedInterfaces()
+	if err != nil {
+		logger.Debug("Failed to detect bonded interfaces", zap.Error(err))
+	}
+	for _, bond := range detected {
+		bonds[bond.BondName] = bond
+		for _, member := range bond.Members {
+			bondOf[member] = bond
+		}
+	}
+
 	// Test each interface
 	var wg sync.WaitGroup
-	resultsChan := make(chan common.TestResult, len(matchedInterfaces)*2)
+	resultsChan := make(chan common.TestResult, len(matchedInterfaces)*5+2)
+
+	// Watch for link flaps while the interfaces are tested
+	if r.MonitorDuration > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer common.RecoverTestGoroutine(1, logger, resultsChan)()
+			if result, ok := r.testLinkEvents(ctx); ok {
+				resultsChan <- result
+			}
+		}()
+	}
+
+	// Scan the wireless environment once for all interfaces
+	if r.RunWiFiScan {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer common.RecoverTestGoroutine(1, logger, resultsChan)()
+			resultsChan <- r.testWiFiScan()
+		}()
+	}
 
 	for _, iface := range matchedInterfaces {
 		iface := iface // Capture variable for goroutine
@@ -161,14 +225,14 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			defer common.RecoverTestGoroutine(1, logger, resultsChan)()
 
 			// Create a test result for this interface's connection
-			connResult := common.TestResult{
-				Layer:     1,
-				Name:      fmt.Sprintf("Interface %s Connection", iface.Name),
-				StartTime: time.Now(),
-				Metrics:   common.TestMetrics{},
-			}
+			connResult := common.GetTestResult()
+			defer common.PutTestResult(connResult)
+			connResult.Layer = 1
+			connResult.Name = fmt.Sprintf("Interface %s Connection", iface.Name)
+			connResult.StartTime = time.Now()
 
 			// Check if context is done
 			select {
@@ -177,12 +241,21 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 				connResult.Message = "Test was cancelled"
 				connResult.EndTime = time.Now()
 				connResult.Metrics.Duration = connResult.EndTime.Sub(connResult.StartTime)
-				resultsChan <- connResult
+				resultsChan <- *connResult
 				return
 			default:
 				// Continue with test
 			}
 
+			// Capture packets while the connection is tested
+			if r.EnableCapture {
+				captureStart := time.Now()
+				c, err := r.beginCapture(iface.Name)
+				defer func() {
+					resultsChan <- captureResult(iface.Name, captureStart, c, err)
+				}()
+			}
+
 			// Check if this is a VPN interface
 			isVPN := isVPNInterface(iface.Name)
 
@@ -194,6 +267,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 				connWg.Add(1)
 				go func(iter int) {
 					defer connWg.Done()
+					defer common.RecoverTestGoroutine(1, logger, resultsChan)()
 					connectionResults <- checkPhysicalConnection(iface.Name)
 				}(i)
 			}
@@ -238,6 +312,28 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 				}
 			}
 
+			// A bond fails on losing its active member or all members. A member
+			// that is down only warns, the bond's own result carries the failure.
+			var bonding *BondInfo
+			var bondDown []string
+			if bond, ok := bonds[iface.Name]; ok {
+				status, msg, down := checkBondMembers(bond)
+				if connResult.Status != common.StatusFailed {
+					connResult.Status = status
+					connResult.Message = msg
+				} else {
+					connResult.Message += "\n" + msg
+				}
+				bonding, bondDown = &bond, down
+			} else if bond, ok := bondOf[iface.Name]; ok {
+				if connResult.Status == common.StatusFailed {
+					connResult.Status = common.StatusWarning
+					connResult.Message = fmt.Sprintf("Member %s of bond %s is down (%d/%d attempts failed)",
+						iface.Name, bond.BondName, failCount, r.AttemptCount)
+				}
+				bonding = &bond
+			}
+
 			// Get MTU and carrier info
 			mtu := iface.MTU
 			operstate, carrier := getInterfaceDetails(iface.Name)
@@ -249,35 +345,37 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 			connResult.Metrics.ReliabilityPct = connReliability
 
 			// Add connection diagnostic data
-			connResult.Diagnostics = map[string]interface{}{
-				"interface":     iface.Name,
-				"hardware_addr": iface.HardwareAddr.String(),
-				"mtu":           mtu,
-				"flags":         iface.Flags.String(),
-				"success_count": successCount,
-				"fail_count":    failCount,
-				"oper_state":    operstate,
-				"carrier":       carrier,
-				"tx_bytes":      txBytes,
-				"rx_bytes":      rxBytes,
-				"is_vpn":        isVPN,
+			connResult.Diagnostics.Physical = &common.PhysicalDiagnostics{
+				Interface:    iface.Name,
+				HardwareAddr: iface.HardwareAddr.String(),
+				MTU:          mtu,
+				Flags:        iface.Flags.String(),
+				SuccessCount: common.Ptr(successCount),
+				FailCount:    common.Ptr(failCount),
+				OperState:    operstate,
+				Carrier:      common.Ptr(carrier),
+				TxBytes:      common.Ptr(txBytes),
+				RxBytes:      common.Ptr(rxBytes),
+				IsVPN:        common.Ptr(isVPN),
+				Bonding:      bonding,
 			}
+			connResult.Diagnostics.Physical.BondDownMembers = bondDown
 
-			resultsChan <- connResult
+			resultsChan <- *connResult
 		}()
 
 		// Test signal strength (for wireless interfaces)
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			defer common.RecoverTestGoroutine(1, logger, resultsChan)()
 
 			// Create a test result for this interface's signal strength
-			signalResult := common.TestResult{
-				Layer:     1,
-				Name:      fmt.Sprintf("Interface %s Signal Strength", iface.Name),
-				StartTime: time.Now(),
-				Metrics:   common.TestMetrics{},
-			}
+			signalResult := common.GetTestResult()
+			defer common.PutTestResult(signalResult)
+			signalResult.Layer = 1
+			signalResult.Name = fmt.Sprintf("Interface %s Signal Strength", iface.Name)
+			signalResult.StartTime = time.Now()
 
 			// Check if context is done
 			select {
@@ -286,7 +384,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 				signalResult.Message = "Test was cancelled"
 				signalResult.EndTime = time.Now()
 				signalResult.Metrics.Duration = signalResult.EndTime.Sub(signalResult.StartTime)
-				resultsChan <- signalResult
+				resultsChan <- *signalResult
 				return
 			default:
 				// Continue with test
@@ -299,7 +397,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 				signalResult.Message = "Not a wireless interface, skipping signal strength test"
 				signalResult.EndTime = time.Now()
 				signalResult.Metrics.Duration = signalResult.EndTime.Sub(signalResult.StartTime)
-				resultsChan <- signalResult
+				resultsChan <- *signalResult
 				return
 			}
 
@@ -328,17 +426,35 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 			}
 
 			// Add signal strength diagnostic data
-			signalResult.Diagnostics = map[string]interface{}{
-				"interface":       iface.Name,
-				"signal_strength": strength,
-				"min_threshold":   r.MinSignalStrength,
-				"link_quality":    linkQuality,
-				"noise_level":     noise,
-				"bit_rate":        bitRate,
-				"frequency":       frequency,
+			signalResult.Diagnostics.Physical = &common.PhysicalDiagnostics{
+				Interface:      iface.Name,
+				SignalStrength: strength,
+				MinThreshold:   r.MinSignalStrength,
+				LinkQuality:    linkQuality,
+				NoiseLevel:     noise,
+				BitRate:        bitRate,
+				Frequency:      frequency,
 			}
 
-			resultsChan <- signalResult
+			resultsChan <- *signalResult
+		}()
+
+		// Monitor RX/TX error rates
+		if r.MonitorErrorRate {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer common.RecoverTestGoroutine(1, logger, resultsChan)()
+				resultsChan <- r.testErrorRate(ctx, iface.Name)
+			}()
+		}
+
+		// Measure throughput and error/drop rates
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer common.RecoverTestGoroutine(1, logger, resultsChan)()
+			resultsChan <- r.testUtilization(ctx, iface.Name)
 		}()
 	}
 
@@ -416,7 +532,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 
 	parentResult.Message = messageBuilder.String()
 	logger.Info("Layer 1 tests completed",
-		zap.String("status", string(parentResult.Status)),
+		zap.String("status", parentResult.Status.String()),
 		zap.Int("total_interfaces", len(subResults)),
 		zap.Int("passed", successCount),
 		zap.Int("failed", failureCount),
@@ -424,11 +540,72 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 	)
 
 	if failureCount > 0 {
-		return []common.TestResult{parentResult}, fmt.Errorf("layer 1 tests failed with %d failures", failureCount)
+		return []common.TestResult{parentResult}, &common.LayerError{
+			Layer: 1,
+			Code:  common.ErrTestFailed,
+			Cause: fmt.Errorf("layer 1 tests failed with %d failures", failureCount),
+		}
 	}
 	return []common.TestResult{parentResult}, nil
 }
 
+// testErrorRate monitors an interface's error counters and reports sustained error rates
+func (r *Runner) testErrorRate(ctx context.Context, interfaceName string) common.TestResult {
+	result := common.TestResult{
+		Layer:     1,
+		Name:      fmt.Sprintf("Interface %s Error Rate", interfaceName),
+		StartTime: time.Now(),
+		Metrics:   common.TestMetrics{},
+	}
+
+	// Check if context is done
+	select {
+	case <-ctx.Done():
+		result.Status = common.StatusSkipped
+		result.Message = "Test was cancelled"
+		result.EndTime = time.Now()
+		result.Metrics.Duration = result.EndTime.Sub(result.StartTime)
+		return result
+	default:
+		// Continue with test
+	}
+
+	sample, err := r.monitorErrorRate(ctx, interfaceName, errorRateSampleInterval, r.MonitorSamples)
+	result.EndTime = time.Now()
+	result.Metrics.Duration = result.EndTime.Sub(result.StartTime)
+
+	if err != nil {
+		result.Status = common.StatusSkipped
+		result.Message = fmt.Sprintf("Error rate monitoring unavailable: %v", err)
+		return result
+	}
+
+	if sample.OverThreshold {
+		result.Status = common.StatusFailed
+		result.Message = fmt.Sprintf("Sustained error rate on %s above %.2f errors/s (RX %.2f/s, TX %.2f/s)",
+			interfaceName, r.ErrorRateWarningPPS, sample.RxErrorsPerSec, sample.TxErrorsPerSec)
+	} else {
+		result.Status = common.StatusPassed
+		result.Message = fmt.Sprintf("Error rate on %s within threshold (RX %.2f/s, TX %.2f/s)",
+			interfaceName, sample.RxErrorsPerSec, sample.TxErrorsPerSec)
+	}
+
+	result.Metrics.Custom = map[string]interface{}{
+		"rx_errors_per_sec":  sample.RxErrorsPerSec,
+		"tx_errors_per_sec":  sample.TxErrorsPerSec,
+		"collisions_per_sec": sample.CollisionsPerSec,
+	}
+	result.Diagnostics.Physical = &common.PhysicalDiagnostics{
+		Interface:      interfaceName,
+		Samples:        sample.Samples,
+		SampleInterval: errorRateSampleInterval.String(),
+		ThresholdPPS:   r.ErrorRateWarningPPS,
+		OverThreshold:  common.Ptr(sample.OverThreshold),
+	}
+
+	return result
+}
+
 // Helper functions for physical layer tests
 
 // checkPhysicalConnection tests the physical connectivity of an interface
@@ -879,7 +1056,6 @@ func getMacWirelessInfo(interfaceName string) (int, int, int, string, string) {
 	frequency := "unknown"
 
 	// Get wireless info using airport command
-	airportPath := "/System/Library/PrivateFrameworks/Apple80211.framework/Versions/Current/Resources/airport"
 	cmd := exec.Command(airportPath, "-I")
 	output, err := cmd.Output()
 
@@ -0,0 +1,142 @@
+package layer1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// LinkEvent is a change of a network link's state
+type LinkEvent = common.LinkEvent
+
+// Link event types
+const (
+	LinkEventUp      = "up"
+	LinkEventDown    = "down"
+	LinkEventRenamed = "renamed"
+)
+
+// errLinkEventsUnsupported is returned by MonitorLinkEvents where link
+// events can't be watched
+var errLinkEventsUnsupported = errors.New("link event monitoring is only supported on linux")
+
+// linkFlapResults returns a result for each interface that went down in
+// events: failed when it was still down at the end, a warning when it came
+// back up. Renamed interfaces are reported under their latest name.
+func linkFlapResults(events []LinkEvent, start, end time.Time) []common.TestResult {
+	var order []string
+	byInterface := make(map[string][]LinkEvent)
+	for _, event := range events {
+		if event.EventType == LinkEventRenamed {
+			if earlier, ok := byInterface[event.OldName]; ok {
+				delete(byInterface, event.OldName)
+				byInterface[event.Interface] = append(earlier, byInterface[event.Interface]...)
+				for i, name := range order {
+					if name == event.OldName {
+						order[i] = event.Interface
+					}
+				}
+			}
+			continue
+		}
+		if _, seen := byInterface[event.Interface]; !seen {
+			order = append(order, event.Interface)
+		}
+		byInterface[event.Interface] = append(byInterface[event.Interface], event)
+	}
+
+	var results []common.TestResult
+	for _, name := range order {
+		ifaceEvents := byInterface[name]
+		downs := 0
+		for _, event := range ifaceEvents {
+			if event.EventType == LinkEventDown {
+				downs++
+			}
+		}
+		if downs == 0 {
+			continue
+		}
+
+		result := common.TestResult{
+			Layer:     1,
+			Name:      fmt.Sprintf("Link Flap (%s)", name),
+			StartTime: start,
+			EndTime:   end,
+			Metrics: common.TestMetrics{
+				Duration: end.Sub(start),
+				Custom:   map[string]interface{}{"down_events": downs},
+			},
+			Diagnostics: common.DiagnosticsPayload{Physical: &common.PhysicalDiagnostics{Interface: name, LinkEvents: ifaceEvents}},
+		}
+		last := ifaceEvents[len(ifaceEvents)-1]
+		if last.EventType == LinkEventUp {
+			result.Status = common.StatusWarning
+			result.Message = fmt.Sprintf("Link %s went down and recovered (%d down events, back up at %s)",
+				name, downs, last.Timestamp.Format(time.RFC3339))
+		} else {
+			result.Status = common.StatusFailed
+			result.Message = fmt.Sprintf("Link %s went down at %s and was still down when monitoring ended",
+				name, last.Timestamp.Format(time.RFC3339))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// testLinkEvents watches link state changes for MonitorDuration and reports
+// each interface that flapped as a sub-result. It returns false where link
+// events are not supported, as the check is then silently left out.
+func (r *Runner) testLinkEvents(ctx context.Context) (common.TestResult, bool) {
+	result := common.TestResult{
+		Layer:     1,
+		Name:      "Link Event Monitor",
+		StartTime: time.Now(),
+	}
+
+	var mu sync.Mutex
+	var events []LinkEvent
+	monitorCtx, cancel := context.WithTimeout(ctx, r.MonitorDuration)
+	defer cancel()
+	err := MonitorLinkEvents(monitorCtx, func(event LinkEvent) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	})
+	if errors.Is(err, errLinkEventsUnsupported) {
+		return result, false
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	diagnostics := &common.PhysicalDiagnostics{
+		SampleInterval: r.MonitorDuration.String(),
+		LinkEvents:     events,
+	}
+	result.Diagnostics.Physical = diagnostics
+	if err != nil {
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("Link event monitoring failed: %v", err)), true
+	}
+	if ctx.Err() != nil {
+		return result.Finish(common.StatusSkipped, "Test was cancelled"), true
+	}
+
+	result.SubResults = linkFlapResults(events, result.StartTime, time.Now())
+	result.Metrics.Custom = map[string]interface{}{
+		"link_events":        len(events),
+		"flapped_interfaces": len(result.SubResults),
+	}
+	if len(result.SubResults) == 0 {
+		return result.Finish(common.StatusPassed, fmt.Sprintf("No link flaps in %v (%d link events)", r.MonitorDuration, len(events))), true
+	}
+
+	statuses := make([]common.TestStatus, len(result.SubResults))
+	for i, sub := range result.SubResults {
+		statuses[i] = sub.Status
+	}
+	return result.Finish(common.MaxSeverity(statuses...), fmt.Sprintf("%d interfaces flapped in %v", len(result.SubResults), r.MonitorDuration)), true
+}
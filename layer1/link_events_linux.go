@@ -0,0 +1,96 @@
+package layer1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// linkState is the last known name and state of a link
+type linkState struct {
+	name string
+	up   bool
+}
+
+// linkUp reports whether a link is operationally up. Links whose driver
+// doesn't report an operational state, such as tunnels, count as up when
+// administratively up.
+func linkUp(attrs *netlink.LinkAttrs) bool {
+	if attrs.OperState == netlink.OperUnknown {
+		return attrs.Flags&net.FlagUp != 0
+	}
+	return attrs.OperState == netlink.OperUp
+}
+
+// MonitorLinkEvents subscribes to RTMGRP_LINK netlink messages and calls
+// callback with each link that goes up or down or is renamed, until ctx is
+// done. Messages that don't change a link's state are ignored.
+func MonitorLinkEvents(ctx context.Context, callback func(LinkEvent)) error {
+	updates := make(chan netlink.LinkUpdate, 64)
+	done := make(chan struct{})
+	defer close(done)
+
+	subErr := make(chan error, 1)
+	err := netlink.LinkSubscribeWithOptions(updates, done, netlink.LinkSubscribeOptions{
+		ErrorCallback: func(err error) {
+			select {
+			case subErr <- err:
+			default:
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to link events: %w", err)
+	}
+
+	// Seed the current state so only changes are reported
+	links, err := netlink.LinkList()
+	if err != nil {
+		return fmt.Errorf("failed to list links: %w", err)
+	}
+	states := make(map[int]linkState, len(links))
+	for _, link := range links {
+		attrs := link.Attrs()
+		states[attrs.Index] = linkState{name: attrs.Name, up: linkUp(attrs)}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-subErr:
+			return fmt.Errorf("link event subscription failed: %w", err)
+		case update, ok := <-updates:
+			if !ok {
+				return errors.New("link event subscription closed")
+			}
+
+			attrs := update.Attrs()
+			now := time.Now()
+			prev, known := states[attrs.Index]
+			current := linkState{name: attrs.Name, up: linkUp(attrs)}
+			if update.Header.Type == unix.RTM_DELLINK {
+				current.up = false
+				delete(states, attrs.Index)
+			} else {
+				states[attrs.Index] = current
+			}
+
+			if known && prev.name != current.name {
+				callback(LinkEvent{Interface: current.name, EventType: LinkEventRenamed, OldName: prev.name, Timestamp: now})
+			}
+			if prev.up != current.up {
+				eventType := LinkEventDown
+				if current.up {
+					eventType = LinkEventUp
+				}
+				callback(LinkEvent{Interface: current.name, EventType: eventType, Timestamp: now})
+			}
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package layer1
+
+import "context"
+
+// MonitorLinkEvents is only implemented on linux
+func MonitorLinkEvents(ctx context.Context, callback func(LinkEvent)) error {
+	return errLinkEventsUnsupported
+}
@@ -0,0 +1,160 @@
+package layer1
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// defaultSampleDuration is the utilization measurement window used when
+// SampleDuration is not set
+const defaultSampleDuration = time.Second
+
+// interfaceCounters is a snapshot of an interface's traffic counters
+type interfaceCounters struct {
+	RxBytes   int64
+	TxBytes   int64
+	RxPackets int64
+	TxPackets int64
+	RxErrors  int64
+	TxErrors  int64
+	RxDropped int64
+	TxDropped int64
+	Timestamp time.Time
+}
+
+// UtilizationMetrics holds an interface's throughput and error rates over a
+// measurement window
+type UtilizationMetrics struct {
+	RxMbps    float64 `json:"rx_mbps"`
+	TxMbps    float64 `json:"tx_mbps"`
+	ErrorRate float64 `json:"error_rate"` // RX+TX errors per packet
+	DropRate  float64 `json:"drop_rate"`  // RX+TX drops per packet
+	Packets   int64   `json:"packets"`
+	Errors    int64   `json:"errors"`
+	Dropped   int64   `json:"dropped"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// getInterfaceCounters reads the current traffic counters for an interface
+func getInterfaceCounters(interfaceName string) (interfaceCounters, error) {
+	counters := interfaceCounters{Timestamp: time.Now()}
+
+	if runtime.GOOS != "linux" {
+		return counters, fmt.Errorf("interface counters are only supported on linux")
+	}
+
+	fields := []struct {
+		name string
+		dst  *int64
+	}{
+		{"rx_bytes", &counters.RxBytes},
+		{"tx_bytes", &counters.TxBytes},
+		{"rx_packets", &counters.RxPackets},
+		{"tx_packets", &counters.TxPackets},
+		{"rx_errors", &counters.RxErrors},
+		{"tx_errors", &counters.TxErrors},
+		{"rx_dropped", &counters.RxDropped},
+		{"tx_dropped", &counters.TxDropped},
+	}
+
+	for _, f := range fields {
+		value, err := common.ReadInterfaceStatistic(interfaceName, f.name)
+		if err != nil {
+			return counters, err
+		}
+		*f.dst = value
+	}
+
+	return counters, nil
+}
+
+// collectInterfaceUtilization reads the interface counters twice,
+// sampleDuration apart, and computes the throughput in each direction and
+// the share of packets that errored or were dropped in between
+func collectInterfaceUtilization(ifaceName string, sampleDuration time.Duration) UtilizationMetrics {
+	var metrics UtilizationMetrics
+
+	before, err := getInterfaceCounters(ifaceName)
+	if err != nil {
+		metrics.Error = err.Error()
+		return metrics
+	}
+	time.Sleep(sampleDuration)
+	after, err := getInterfaceCounters(ifaceName)
+	if err != nil {
+		metrics.Error = err.Error()
+		return metrics
+	}
+
+	elapsed := after.Timestamp.Sub(before.Timestamp).Seconds()
+	if elapsed <= 0 {
+		metrics.Error = "measurement window too short"
+		return metrics
+	}
+
+	metrics.RxMbps = float64(after.RxBytes-before.RxBytes) * 8 / elapsed / 1e6
+	metrics.TxMbps = float64(after.TxBytes-before.TxBytes) * 8 / elapsed / 1e6
+	metrics.Packets = (after.RxPackets - before.RxPackets) + (after.TxPackets - before.TxPackets)
+	metrics.Errors = (after.RxErrors - before.RxErrors) + (after.TxErrors - before.TxErrors)
+	metrics.Dropped = (after.RxDropped - before.RxDropped) + (after.TxDropped - before.TxDropped)
+	if metrics.Packets > 0 {
+		metrics.ErrorRate = float64(metrics.Errors) / float64(metrics.Packets)
+		metrics.DropRate = float64(metrics.Dropped) / float64(metrics.Packets)
+	}
+
+	return metrics
+}
+
+// testUtilization measures an interface's throughput and error rate, warning
+// or failing when the error rate crosses the packet loss thresholds
+func (r *Runner) testUtilization(ctx context.Context, interfaceName string) common.TestResult {
+	result := common.TestResult{
+		Layer:     1,
+		Name:      fmt.Sprintf("Interface %s Utilization", interfaceName),
+		StartTime: time.Now(),
+		Metrics:   common.TestMetrics{},
+	}
+
+	select {
+	case <-ctx.Done():
+		return result.Finish(common.StatusSkipped, "Test was cancelled")
+	default:
+	}
+
+	sampleDuration := r.SampleDuration
+	if sampleDuration <= 0 {
+		sampleDuration = defaultSampleDuration
+	}
+
+	metrics := collectInterfaceUtilization(interfaceName, sampleDuration)
+	result.Diagnostics.Physical = &common.PhysicalDiagnostics{
+		Interface:      interfaceName,
+		SampleInterval: sampleDuration.String(),
+	}
+	if metrics.Error != "" {
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("Utilization measurement unavailable: %s", metrics.Error))
+	}
+
+	result.Metrics.Custom = map[string]interface{}{
+		"tx_mbps":    metrics.TxMbps,
+		"rx_mbps":    metrics.RxMbps,
+		"error_rate": metrics.ErrorRate,
+		"drop_rate":  metrics.DropRate,
+	}
+
+	summary := fmt.Sprintf("RX %.2f Mbps, TX %.2f Mbps, %d errors and %d drops in %d packets over %v",
+		metrics.RxMbps, metrics.TxMbps, metrics.Errors, metrics.Dropped, metrics.Packets, sampleDuration)
+	switch {
+	case r.PacketLossErrorPct > 0 && metrics.ErrorRate > r.PacketLossErrorPct/100:
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Error rate on %s is %.2f%% (above %.2f%%): %s",
+			interfaceName, metrics.ErrorRate*100, r.PacketLossErrorPct, summary))
+	case r.PacketLossWarningPct > 0 && metrics.ErrorRate > r.PacketLossWarningPct/100:
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Error rate on %s is %.2f%% (above %.2f%%): %s",
+			interfaceName, metrics.ErrorRate*100, r.PacketLossWarningPct, summary))
+	}
+	return result.Finish(common.StatusPassed, fmt.Sprintf("Utilization of %s: %s", interfaceName, summary))
+}
@@ -0,0 +1,310 @@
+package layer1
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// WiFiNetwork is a wireless network seen by a scan
+type WiFiNetwork = common.WiFiNetwork
+
+// airportPath is the macOS utility used for wireless scans
+const airportPath = "/System/Library/PrivateFrameworks/Apple80211.framework/Versions/Current/Resources/airport"
+
+// wifiScanTimeout bounds a single scan command
+const wifiScanTimeout = 30 * time.Second
+
+// wifiInterferenceSignal is the signal strength, as a percentage, from which
+// another network on the same channel is reported as interference
+const wifiInterferenceSignal = 60
+
+// errNoWirelessInterface is returned when there is nothing to scan with
+var errNoWirelessInterface = errors.New("no wireless interface found")
+
+var (
+	iwBSSPattern       = regexp.MustCompile(`^BSS ([0-9a-fA-F:]{17})`)
+	airportLinePattern = regexp.MustCompile(`^\s*(.*?)\s+([0-9a-fA-F]{2}(?::[0-9a-fA-F]{2}){5})\s+(-?\d+)\s+(\d+)\S*\s+\S+\s+\S+\s+(.+?)\s*$`)
+	netshKeyPattern    = regexp.MustCompile(`^\s*(.+?)\s*:\s*(.*?)\s*$`)
+)
+
+// ScanWiFiNetworks lists the wireless networks visible from this host using
+// iw on Linux, airport on macOS and netsh on Windows
+func ScanWiFiNetworks() ([]WiFiNetwork, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), wifiScanTimeout)
+	defer cancel()
+
+	switch runtime.GOOS {
+	case "linux":
+		iface, err := findWirelessInterface()
+		if err != nil {
+			return nil, err
+		}
+		output, err := exec.CommandContext(ctx, "iw", "dev", iface, "scan").CombinedOutput()
+		if err != nil {
+			// Triggering a scan needs CAP_NET_ADMIN; the cached results do not
+			if dump, dumpErr := exec.CommandContext(ctx, "iw", "dev", iface, "scan", "dump").CombinedOutput(); dumpErr == nil {
+				return parseIWScan(string(dump)), nil
+			}
+			return nil, fmt.Errorf("iw scan failed: %v - %s", err, strings.TrimSpace(string(output)))
+		}
+		return parseIWScan(string(output)), nil
+	case "darwin":
+		output, err := exec.CommandContext(ctx, airportPath, "-s").CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("airport scan failed: %v - %s", err, strings.TrimSpace(string(output)))
+		}
+		return parseAirportScan(string(output)), nil
+	case "windows":
+		output, err := exec.CommandContext(ctx, "netsh", "wlan", "show", "networks", "mode=bssid").CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("netsh scan failed: %v - %s", err, strings.TrimSpace(string(output)))
+		}
+		return parseNetshNetworks(string(output)), nil
+	default:
+		return nil, fmt.Errorf("Wi-Fi scanning is not supported on %s", runtime.GOOS)
+	}
+}
+
+// findWirelessInterface returns the first interface with a wireless
+// directory in sysfs
+func findWirelessInterface() (string, error) {
+	dirs, err := filepath.Glob("/sys/class/net/*/wireless")
+	if err != nil || len(dirs) == 0 {
+		return "", errNoWirelessInterface
+	}
+	sort.Strings(dirs)
+	return filepath.Base(filepath.Dir(dirs[0])), nil
+}
+
+// frequencyToChannel converts a centre frequency in MHz to its 802.11 channel
+func frequencyToChannel(freq int) int {
+	switch {
+	case freq == 2484:
+		return 14
+	case freq >= 2412 && freq < 2484:
+		return (freq - 2407) / 5
+	case freq >= 5955 && freq <= 7115:
+		return (freq - 5950) / 5
+	case freq >= 5000 && freq < 5955:
+		return (freq - 5000) / 5
+	}
+	return 0
+}
+
+// parseIWScan parses the output of `iw dev <iface> scan`
+func parseIWScan(output string) []WiFiNetwork {
+	var networks []WiFiNetwork
+	var current *WiFiNetwork
+	var rsn, wpa, privacy, sae bool
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		switch {
+		case rsn && sae:
+			current.SecurityType = "WPA3"
+		case rsn:
+			current.SecurityType = "WPA2"
+		case wpa:
+			current.SecurityType = "WPA"
+		case privacy:
+			current.SecurityType = "WEP"
+		default:
+			current.SecurityType = "Open"
+		}
+		networks = append(networks, *current)
+		current = nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := iwBSSPattern.FindStringSubmatch(line); m != nil {
+			flush()
+			current = &WiFiNetwork{BSSID: strings.ToLower(m[1])}
+			rsn, wpa, privacy, sae = false, false, false, false
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		field := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(field, "SSID:"):
+			current.SSID = strings.TrimSpace(strings.TrimPrefix(field, "SSID:"))
+		case strings.HasPrefix(field, "freq:"):
+			freq, _ := strconv.ParseFloat(strings.TrimSpace(strings.TrimPrefix(field, "freq:")), 64)
+			if current.Channel == 0 {
+				current.Channel = frequencyToChannel(int(freq))
+			}
+		case strings.HasPrefix(field, "DS Parameter set: channel"):
+			if channel, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(field, "DS Parameter set: channel"))); err == nil {
+				current.Channel = channel
+			}
+		case strings.HasPrefix(field, "signal:"):
+			value := strings.TrimSuffix(strings.TrimSpace(strings.TrimPrefix(field, "signal:")), "dBm")
+			if dbm, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				current.SignalStrength = normalizeSignalStrength(int(dbm), "dbm")
+			}
+		case strings.HasPrefix(field, "capability:"):
+			privacy = strings.Contains(field, "Privacy")
+		case strings.HasPrefix(field, "RSN:"):
+			rsn = true
+		case strings.HasPrefix(field, "WPA:"):
+			wpa = true
+		case strings.Contains(field, "Authentication suites:") && strings.Contains(field, "SAE"):
+			sae = true
+		}
+	}
+	flush()
+	return networks
+}
+
+// parseAirportScan parses the table printed by `airport -s`. SSIDs are right
+// aligned and may contain spaces, so each row is matched from the BSSID on.
+func parseAirportScan(output string) []WiFiNetwork {
+	var networks []WiFiNetwork
+	for _, line := range strings.Split(output, "\n") {
+		m := airportLinePattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		rssi, _ := strconv.Atoi(m[3])
+		channel, _ := strconv.Atoi(m[4])
+		security := m[5]
+		if security == "NONE" {
+			security = "Open"
+		}
+		networks = append(networks, WiFiNetwork{
+			SSID:           m[1],
+			BSSID:          strings.ToLower(m[2]),
+			Channel:        channel,
+			SignalStrength: normalizeSignalStrength(rssi, "rssi"),
+			SecurityType:   security,
+		})
+	}
+	return networks
+}
+
+// parseNetshNetworks parses `netsh wlan show networks mode=bssid`, returning
+// one network per BSSID
+func parseNetshNetworks(output string) []WiFiNetwork {
+	var networks []WiFiNetwork
+	var ssid, security string
+	var current *WiFiNetwork
+
+	flush := func() {
+		if current != nil {
+			networks = append(networks, *current)
+			current = nil
+		}
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		m := netshKeyPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key, value := m[1], m[2]
+		switch {
+		case strings.HasPrefix(key, "SSID"):
+			flush()
+			ssid, security = value, ""
+		case key == "Authentication":
+			security = value
+		case strings.HasPrefix(key, "BSSID"):
+			flush()
+			current = &WiFiNetwork{SSID: ssid, BSSID: strings.ToLower(value), SecurityType: security}
+		case current == nil:
+		case key == "Signal":
+			pct, _ := strconv.Atoi(strings.TrimSuffix(value, "%"))
+			current.SignalStrength = pct
+		case key == "Channel":
+			current.Channel, _ = strconv.Atoi(value)
+		}
+	}
+	flush()
+	return networks
+}
+
+// findInterference returns, for each channel with more than one strong
+// network, the networks on it
+func findInterference(networks []WiFiNetwork) map[int][]WiFiNetwork {
+	strong := make(map[int][]WiFiNetwork)
+	for _, network := range networks {
+		if network.Channel > 0 && network.SignalStrength >= wifiInterferenceSignal {
+			strong[network.Channel] = append(strong[network.Channel], network)
+		}
+	}
+	for channel, onChannel := range strong {
+		if len(onChannel) < 2 {
+			delete(strong, channel)
+		}
+	}
+	return strong
+}
+
+// testWiFiScan lists the nearby wireless networks and warns about channels
+// shared by several strong networks
+func (r *Runner) testWiFiScan() common.TestResult {
+	result := common.TestResult{
+		Layer:     1,
+		Name:      "Wi-Fi Environment Scan",
+		StartTime: time.Now(),
+	}
+
+	networks, err := ScanWiFiNetworks()
+	if err != nil {
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("Wi-Fi scan unavailable: %v", err))
+	}
+
+	sort.Slice(networks, func(i, j int) bool {
+		return networks[i].SignalStrength > networks[j].SignalStrength
+	})
+	result.Diagnostics.Physical = &common.PhysicalDiagnostics{WiFiNetworks: networks}
+
+	interference := findInterference(networks)
+	channels := make([]int, 0, len(interference))
+	for channel := range interference {
+		channels = append(channels, channel)
+	}
+	sort.Ints(channels)
+
+	result.Metrics.Custom = map[string]interface{}{
+		"networks_found":       len(networks),
+		"interfering_channels": len(channels),
+	}
+
+	if len(channels) == 0 {
+		return result.Finish(common.StatusPassed, fmt.Sprintf("Found %d Wi-Fi networks, no channel shared by several strong networks", len(networks)))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Found %d Wi-Fi networks, potential interference on %d channels:", len(networks), len(channels))
+	for _, channel := range channels {
+		names := make([]string, 0, len(interference[channel]))
+		for _, network := range interference[channel] {
+			ssid := network.SSID
+			if ssid == "" {
+				ssid = "<hidden>"
+			}
+			names = append(names, fmt.Sprintf("%s (%s, %d%%)", ssid, network.BSSID, network.SignalStrength))
+		}
+		fmt.Fprintf(&b, "\n- Channel %d: %s", channel, strings.Join(names, ", "))
+	}
+	return result.Finish(common.StatusWarning, b.String())
+}
@@ -0,0 +1,275 @@
+package layer2
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// ARP entry flags from /proc/net/arp (linux/if_arp.h)
+const (
+	atfComplete  = 0x02
+	atfPermanent = 0x04
+)
+
+// ARPEntry is a single IP to MAC mapping from the ARP cache
+type ARPEntry = common.ARPEntry
+
+// InspectARPTable reads the system ARP cache
+func InspectARPTable() ([]ARPEntry, error) {
+	switch runtime.GOOS {
+	case "linux":
+		data, err := os.ReadFile("/proc/net/arp")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ARP table: %w", err)
+		}
+		return parseProcNetARP(string(data)), nil
+	case "darwin":
+		output, err := exec.Command("arp", "-a", "-n").Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to run arp: %w", err)
+		}
+		return parseDarwinARP(string(output)), nil
+	case "windows":
+		output, err := exec.Command("arp", "-a").Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to run arp: %w", err)
+		}
+		return parseWindowsARP(string(output)), nil
+	default:
+		return nil, fmt.Errorf("ARP table inspection is not supported on %s", runtime.GOOS)
+	}
+}
+
+// parseProcNetARP parses the Linux ARP table:
+// "IP address  HW type  Flags  HW address  Mask  Device"
+func parseProcNetARP(data string) []ARPEntry {
+	var entries []ARPEntry
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	scanner.Scan() // Skip the header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 6 {
+			continue
+		}
+
+		flags, _ := strconv.ParseInt(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+		state := "reachable"
+		switch {
+		case flags&atfComplete == 0:
+			state = "incomplete"
+		case flags&atfPermanent != 0:
+			state = "permanent"
+		}
+
+		entries = append(entries, ARPEntry{
+			IPAddr:    fields[0],
+			MACAddr:   normalizeMAC(fields[3]),
+			Interface: fields[5],
+			State:     state,
+		})
+	}
+
+	return entries
+}
+
+// darwinARPLine matches "? (192.168.1.1) at 0:11:22:33:44:55 on en0 ifscope [ethernet]"
+var darwinARPLine = regexp.MustCompile(`\((\S+)\) at (\S+) on (\S+)(.*)$`)
+
+// parseDarwinARP parses the output of "arp -a -n" on macOS
+func parseDarwinARP(output string) []ARPEntry {
+	var entries []ARPEntry
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		m := darwinARPLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		entry := ARPEntry{IPAddr: m[1], Interface: m[3], State: "reachable"}
+		switch {
+		case m[2] == "(incomplete)":
+			entry.State = "incomplete"
+		default:
+			entry.MACAddr = normalizeMAC(m[2])
+			if strings.Contains(m[4], "permanent") {
+				entry.State = "permanent"
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// parseWindowsARP parses the output of "arp -a" on Windows, where entries
+// are grouped under "Interface: 192.168.1.10 --- 0x4" headers
+func parseWindowsARP(output string) []ARPEntry {
+	var entries []ARPEntry
+	var iface string
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "Interface:" {
+			iface = fields[1]
+			continue
+		}
+		if len(fields) != 3 || net.ParseIP(fields[0]) == nil {
+			continue
+		}
+		entries = append(entries, ARPEntry{
+			IPAddr:    fields[0],
+			MACAddr:   normalizeMAC(fields[1]),
+			Interface: iface,
+			State:     strings.ToLower(fields[2]),
+		})
+	}
+
+	return entries
+}
+
+// normalizeMAC converts a MAC address to lower case, colon separated,
+// zero padded form
+func normalizeMAC(mac string) string {
+	parts := strings.FieldsFunc(strings.ToLower(mac), func(r rune) bool { return r == ':' || r == '-' })
+	if len(parts) != 6 {
+		return strings.ToLower(mac)
+	}
+	for i, part := range parts {
+		if len(part) == 1 {
+			parts[i] = "0" + part
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+// isIncompleteMAC reports whether mac is missing or all zeros
+func isIncompleteMAC(mac string) bool {
+	return mac == "" || mac == "00:00:00:00:00:00"
+}
+
+// isMulticastMAC reports whether the group bit of the first octet is set
+func isMulticastMAC(mac string) bool {
+	hw, err := net.ParseMAC(mac)
+	return err == nil && len(hw) > 0 && hw[0]&0x01 != 0
+}
+
+// isUnicastIP reports whether ip is expected to map to a unicast MAC
+func isUnicastIP(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.IsMulticast() || parsed.Equal(net.IPv4bcast) {
+		return false
+	}
+	// Subnet broadcast addresses legitimately map to ff:ff:ff:ff:ff:ff
+	if v4 := parsed.To4(); v4 != nil && v4[3] == 255 {
+		return false
+	}
+	return true
+}
+
+// testARPTable inspects the ARP cache and returns a summary sub-test followed
+// by a sub-test for each anomaly found
+func (r *Runner) testARPTable() []common.TestResult {
+	summary := common.TestResult{
+		Layer:     2,
+		Name:      "ARP Table Inspection",
+		StartTime: time.Now(),
+	}
+
+	entries, err := InspectARPTable()
+	if err != nil {
+		summary.Diagnostics.DataLink = &common.DataLinkDiagnostics{Error: err.Error()}
+		return []common.TestResult{summary.Finish(common.StatusWarning, fmt.Sprintf("ARP table inspection unavailable: %v", err))}
+	}
+
+	var issues []common.TestResult
+	var warnings, failures int
+
+	// Multicast MACs should never answer for unicast addresses
+	for _, entry := range entries {
+		if isIncompleteMAC(entry.MACAddr) || !isMulticastMAC(entry.MACAddr) || !isUnicastIP(entry.IPAddr) {
+			continue
+		}
+		issue := common.TestResult{
+			Layer:     2,
+			Name:      fmt.Sprintf("ARP Multicast MAC Check (%s)", entry.IPAddr),
+			StartTime: time.Now(),
+			Diagnostics: common.DiagnosticsPayload{
+				DataLink: &common.DataLinkDiagnostics{Entry: &entry},
+			},
+		}
+		issues = append(issues, issue.Finish(common.StatusWarning,
+			fmt.Sprintf("Unicast address %s on %s resolves to multicast MAC %s", entry.IPAddr, entry.Interface, entry.MACAddr)))
+		warnings++
+	}
+
+	// The same IP answering from different MACs suggests ARP spoofing
+	macsByIP := make(map[string]map[string]bool)
+	for _, entry := range entries {
+		if isIncompleteMAC(entry.MACAddr) {
+			continue
+		}
+		if macsByIP[entry.IPAddr] == nil {
+			macsByIP[entry.IPAddr] = make(map[string]bool)
+		}
+		macsByIP[entry.IPAddr][entry.MACAddr] = true
+	}
+	ips := make([]string, 0, len(macsByIP))
+	for ip, macs := range macsByIP {
+		if len(macs) > 1 {
+			ips = append(ips, ip)
+		}
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		macs := make([]string, 0, len(macsByIP[ip]))
+		for mac := range macsByIP[ip] {
+			macs = append(macs, mac)
+		}
+		sort.Strings(macs)
+
+		issue := common.TestResult{
+			Layer:     2,
+			Name:      fmt.Sprintf("ARP Spoofing Check (%s)", ip),
+			StartTime: time.Now(),
+			Diagnostics: common.DiagnosticsPayload{
+				DataLink: &common.DataLinkDiagnostics{IPAddr: ip, MACAddrs: macs},
+			},
+		}
+		issues = append(issues, issue.Finish(common.StatusFailed,
+			fmt.Sprintf("Address %s is claimed by %d MACs (%s); possible ARP spoofing", ip, len(macs), strings.Join(macs, ", "))))
+		failures++
+	}
+
+	summary.Diagnostics.DataLink = &common.DataLinkDiagnostics{Entries: entries}
+	summary.Metrics.Custom = map[string]interface{}{
+		"arp_entries": len(entries),
+	}
+
+	var result common.TestResult
+	switch {
+	case failures > 0:
+		result = summary.Finish(common.StatusFailed,
+			fmt.Sprintf("ARP table has %d entries with %d conflicting addresses", len(entries), failures))
+	case warnings > 0:
+		result = summary.Finish(common.StatusWarning,
+			fmt.Sprintf("ARP table has %d entries with %d multicast MAC mappings", len(entries), warnings))
+	default:
+		result = summary.Finish(common.StatusPassed, fmt.Sprintf("ARP table has %d entries, no anomalies found", len(entries)))
+	}
+
+	return append([]common.TestResult{result}, issues...)
+}
@@ -0,0 +1,105 @@
+package layer2
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// frameErrorCounters are the sysfs statistics counting frames damaged on
+// the medium or lost by the NIC
+var frameErrorCounters = []string{
+	"rx_crc_errors",
+	"rx_frame_errors",
+	"rx_length_errors",
+	"tx_aborted_errors",
+	"tx_carrier_errors",
+	"tx_fifo_errors",
+}
+
+// FrameErrorStats maps each frame error counter to its value; -1 when the
+// counter could not be read
+type FrameErrorStats = common.FrameErrorStats
+
+// collectFrameErrors reads the frame error counters of an interface. Every
+// counter is -1 on platforms other than linux.
+func collectFrameErrors(ifaceName string) FrameErrorStats {
+	stats := make(FrameErrorStats, len(frameErrorCounters))
+	for _, name := range frameErrorCounters {
+		stats[name] = -1
+		if runtime.GOOS != "linux" {
+			continue
+		}
+		if value, err := common.ReadInterfaceStatistic(ifaceName, name); err == nil {
+			stats[name] = value
+		}
+	}
+	return stats
+}
+
+// testFrameErrors checks an interface's frame error counters, which count up
+// when cabling or hardware is faulty. A counter above FrameErrorThreshold
+// warns; errors above PacketLossErrorPct of the packets sent and received
+// fail.
+func (r *Runner) testFrameErrors(ifaceName string) common.TestResult {
+	result := common.TestResult{
+		Layer:     2,
+		Name:      fmt.Sprintf("Frame Errors (%s)", ifaceName),
+		StartTime: time.Now(),
+	}
+
+	stats := collectFrameErrors(ifaceName)
+	diagnostics := &common.DataLinkDiagnostics{Interface: ifaceName, FrameErrors: stats}
+	result.Diagnostics.DataLink = diagnostics
+
+	if runtime.GOOS != "linux" {
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("Frame error counters are not available on %s", runtime.GOOS))
+	}
+
+	var total int64
+	var exceeded []string
+	for _, name := range frameErrorCounters {
+		value := stats[name]
+		if value < 0 {
+			continue
+		}
+		total += value
+		if value > int64(r.FrameErrorThreshold) {
+			exceeded = append(exceeded, fmt.Sprintf("%s=%d", name, value))
+		}
+	}
+
+	var packets int64
+	for _, name := range []string{"rx_packets", "tx_packets"} {
+		value, err := common.ReadInterfaceStatistic(ifaceName, name)
+		if err != nil {
+			diagnostics.Error = err.Error()
+			return result.Finish(common.StatusSkipped, fmt.Sprintf("Failed to read %s packet counters: %v", ifaceName, err))
+		}
+		packets += value
+	}
+
+	var errorRate float64
+	if packets > 0 {
+		errorRate = float64(total) / float64(packets)
+	}
+	result.Metrics.Custom = map[string]interface{}{
+		"frame_errors":     total,
+		"frame_error_rate": errorRate,
+	}
+
+	summary := fmt.Sprintf("%d frame errors in %d packets", total, packets)
+	if r.PacketLossErrorPct > 0 && errorRate > r.PacketLossErrorPct/100 {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Frame error rate on %s is %.2f%% (above %.2f%%): %s",
+			ifaceName, errorRate*100, r.PacketLossErrorPct, summary))
+	}
+	if len(exceeded) > 0 {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Frame error counters on %s above %d: %s (%s)",
+			ifaceName, r.FrameErrorThreshold, strings.Join(exceeded, ", "), summary))
+	}
+	return result.Finish(common.StatusPassed, fmt.Sprintf("No frame error counter on %s above %d: %s",
+		ifaceName, r.FrameErrorThreshold, summary))
+}
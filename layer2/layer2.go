@@ -3,6 +3,7 @@ package layer2
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
@@ -68,7 +69,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 			Layer:   2,
 			Status:  common.StatusFailed,
 			Message: msg,
-		}}, err
+		}}, common.NewLayerError(2, err)
 	}
 
 	if len(interfaces) == 0 {
@@ -78,7 +79,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 			Layer:   2,
 			Status:  common.StatusFailed,
 			Message: msg,
-		}}, fmt.Errorf(msg)
+		}}, &common.LayerError{Layer: 2, Code: common.ErrNetworkUnreachable, Cause: errors.New(msg)}
 	}
 
 	var subResults []common.TestResult
@@ -189,21 +190,82 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 
 		ifaceResult.EndTime = time.Now()
 		ifaceResult.Metrics.Duration = ifaceResult.EndTime.Sub(ifaceResult.StartTime)
-		ifaceResult.Diagnostics = map[string]interface{}{
-			"interface":     iface.Name,
-			"type":          getInterfaceType(iface.Name, isVPN),
-			"hardware_addr": iface.HardwareAddr.String(),
-			"mtu":           iface.MTU,
-			"flags":         iface.Flags.String(),
-			"oper_state":    operstate,
-			"carrier":       carrier,
-			"tx_bytes":      txBytes,
-			"rx_bytes":      rxBytes,
-			"addresses":     formatAddresses(addrs),
-			"is_vpn":        isVPN,
+		ifaceResult.Diagnostics.DataLink = &common.DataLinkDiagnostics{
+			Interface:    iface.Name,
+			Type:         getInterfaceType(iface.Name, isVPN),
+			HardwareAddr: iface.HardwareAddr.String(),
+			MTU:          iface.MTU,
+			Flags:        iface.Flags.String(),
+			OperState:    operstate,
+			Carrier:      common.Ptr(carrier),
+			TxBytes:      common.Ptr(txBytes),
+			RxBytes:      common.Ptr(rxBytes),
+			Addresses:    formatAddresses(addrs),
+			IsVPN:        common.Ptr(isVPN),
 		}
 
 		subResults = append(subResults, ifaceResult)
+
+		// Frame error counters
+		if r.CheckFrameErrors {
+			frameResult := r.testFrameErrors(iface.Name)
+			switch frameResult.Status {
+			case common.StatusFailed:
+				failedTests = append(failedTests, frameResult.Message)
+			case common.StatusWarning:
+				warningTests = append(warningTests, frameResult.Message)
+			}
+			subResults = append(subResults, frameResult)
+		}
+	}
+
+	// Ethernet OAM loopback test
+	if r.CheckEthernetOAM {
+		oamResult := r.testEthernetOAM(interfaces)
+		switch oamResult.Status {
+		case common.StatusFailed:
+			failedTests = append(failedTests, oamResult.Message)
+		case common.StatusWarning:
+			warningTests = append(warningTests, oamResult.Message)
+		case common.StatusPassed:
+			successCount++
+		}
+		subResults = append(subResults, oamResult)
+	}
+
+	// ARP cache inspection
+	if r.CheckARP {
+		arpResults := r.testARPTable()
+		if len(arpResults) == 1 {
+			switch arpResults[0].Status {
+			case common.StatusWarning:
+				warningTests = append(warningTests, arpResults[0].Message)
+			case common.StatusPassed:
+				successCount++
+			}
+		}
+		// Anomalies follow the summary as their own sub-tests
+		for _, issue := range arpResults[1:] {
+			switch issue.Status {
+			case common.StatusFailed:
+				failedTests = append(failedTests, issue.Message)
+			case common.StatusWarning:
+				warningTests = append(warningTests, issue.Message)
+			}
+		}
+		subResults = append(subResults, arpResults...)
+	}
+
+	// VLAN detection
+	if r.DetectVLAN {
+		vlanResult := r.testVLANs()
+		switch vlanResult.Status {
+		case common.StatusWarning:
+			warningTests = append(warningTests, vlanResult.Message)
+		case common.StatusPassed:
+			successCount++
+		}
+		subResults = append(subResults, vlanResult)
 	}
 
 	// Create parent result
@@ -251,7 +313,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 	parentResult.Metrics.Duration = parentResult.EndTime.Sub(parentResult.StartTime)
 
 	logger.Info("Layer 2 tests completed",
-		zap.String("status", string(parentResult.Status)),
+		zap.String("status", parentResult.Status.String()),
 		zap.Int("total_interfaces", len(subResults)),
 		zap.Int("passed", successCount),
 		zap.Int("failed", len(failedTests)),
@@ -259,11 +321,64 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 	)
 
 	if len(failedTests) > 0 {
-		return []common.TestResult{parentResult}, fmt.Errorf("layer 2 tests failed")
+		return []common.TestResult{parentResult}, &common.LayerError{Layer: 2, Code: common.ErrTestFailed, Cause: fmt.Errorf("layer 2 tests failed")}
 	}
 	return []common.TestResult{parentResult}, nil
 }
 
+// testEthernetOAM runs a Y.1731 loopback against the configured remote MEP
+func (r *Runner) testEthernetOAM(interfaces []net.Interface) common.TestResult {
+	interfaceName := r.OAMInterface
+	if interfaceName == "" {
+		// Default to the first active Ethernet-capable interface
+		for _, iface := range interfaces {
+			if iface.Flags&net.FlagLoopback == 0 && iface.Flags&net.FlagUp != 0 && len(iface.HardwareAddr) == 6 {
+				interfaceName = iface.Name
+				break
+			}
+		}
+	}
+
+	result := common.TestResult{
+		Layer:     2,
+		Name:      fmt.Sprintf("Ethernet OAM Loopback Test (%s, MEP %d)", interfaceName, r.RemoteMEP),
+		StartTime: time.Now(),
+	}
+
+	timeout := r.OAMTimeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+
+	oam, err := runEthernetOAMLoopback(interfaceName, r.RemoteMEP, timeout)
+	result.EndTime = time.Now()
+	result.Metrics.Duration = result.EndTime.Sub(result.StartTime)
+	result.Diagnostics.DataLink = &common.DataLinkDiagnostics{
+		Interface: interfaceName,
+		RemoteMEP: r.RemoteMEP,
+		Timeout:   timeout.String(),
+		OAM:       &oam,
+	}
+
+	if err != nil {
+		result.Status = common.StatusFailed
+		result.Message = fmt.Sprintf("Ethernet OAM loopback failed on %s: %v", interfaceName, err)
+		return result
+	}
+
+	result.Metrics.Latency = time.Duration(oam.LoopbackResponseMs) * time.Millisecond
+	result.Metrics.Jitter = time.Duration(oam.DelayVariation) * time.Millisecond
+	result.Metrics.PacketLoss = oam.FrameLoss
+
+	result.Status = common.StatusPassed
+	result.Message = fmt.Sprintf("Ethernet OAM loopback to MEP %d successful:\n"+
+		"- Frame delay: %d ms\n"+
+		"- Delay variation: %d ms\n"+
+		"- Frame loss: %.1f%%",
+		r.RemoteMEP, oam.LoopbackResponseMs, oam.DelayVariation, oam.FrameLoss)
+	return result
+}
+
 // formatAddresses formats a list of network addresses as a string
 func formatAddresses(addrs []net.Addr) string {
 	var addrStrs []string
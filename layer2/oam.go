@@ -0,0 +1,192 @@
+package layer2
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/mdlayher/ethernet"
+	"github.com/mdlayher/packet"
+
+	"ghostshell/app/layers/common"
+)
+
+// etherTypeCFM is the EtherType for IEEE 802.1ag / Y.1731 CFM frames
+const etherTypeCFM ethernet.EtherType = 0x8902
+
+// Y.1731 CFM opcodes
+const (
+	cfmOpcodeCCM = 1
+	cfmOpcodeLBR = 2
+	cfmOpcodeLBM = 3
+)
+
+// oamLoopbackCount is the number of LBMs sent per test
+const oamLoopbackCount = 5
+
+// OAMResult holds the outcome of a Y.1731 loopback test
+type OAMResult = common.OAMResult
+
+// runEthernetOAMLoopback sends Y.1731 Loopback Messages to the remote MEP and
+// measures frame delay, loss and delay variation from the Loopback Replies.
+// The MEP's MAC address is learnt from its CCMs; if none are seen the LBMs are
+// sent to the class 1 multicast address instead.
+func runEthernetOAMLoopback(interfaceName string, mep int, timeout time.Duration) (OAMResult, error) {
+	var result OAMResult
+
+	if runtime.GOOS != "linux" {
+		return result, fmt.Errorf("Ethernet OAM is only supported on linux")
+	}
+
+	ifi, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return result, fmt.Errorf("failed to find interface %s: %w", interfaceName, err)
+	}
+
+	conn, err := packet.Listen(ifi, packet.Raw, int(etherTypeCFM), nil)
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return result, fmt.Errorf("raw Ethernet sockets require CAP_NET_RAW: %w", err)
+		}
+		return result, fmt.Errorf("failed to open raw socket on %s: %w", interfaceName, err)
+	}
+	defer conn.Close()
+
+	// Learn the remote MEP's address and MD level from its continuity checks
+	dst, level := learnRemoteMEP(conn, mep, timeout)
+	if dst == nil {
+		dst = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x30 | byte(level)}
+	} else {
+		result.RemoteMAC = dst.String()
+	}
+	result.Level = level
+
+	buf := make([]byte, ifi.MTU+14)
+	var rtts []time.Duration
+
+	for i := 0; i < oamLoopbackCount; i++ {
+		transactionID := uint32(time.Now().UnixNano()) + uint32(i)
+
+		frame := ethernet.Frame{
+			Destination: dst,
+			Source:      ifi.HardwareAddr,
+			EtherType:   etherTypeCFM,
+			Payload:     buildLBM(level, transactionID),
+		}
+		wire, err := frame.MarshalBinary()
+		if err != nil {
+			return result, fmt.Errorf("failed to build LBM: %w", err)
+		}
+
+		start := time.Now()
+		if _, err := conn.WriteTo(wire, &packet.Addr{HardwareAddr: dst}); err != nil {
+			return result, fmt.Errorf("failed to send LBM: %w", err)
+		}
+
+		// Wait for the LBR carrying our transaction ID
+		deadline := start.Add(timeout)
+		conn.SetReadDeadline(deadline)
+		for time.Now().Before(deadline) {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				break
+			}
+			rtt := time.Since(start)
+
+			var reply ethernet.Frame
+			if err := reply.UnmarshalBinary(buf[:n]); err != nil || reply.EtherType != etherTypeCFM {
+				continue
+			}
+			if opcode, id, ok := parseLoopback(reply.Payload); ok && opcode == cfmOpcodeLBR && id == transactionID {
+				rtts = append(rtts, rtt)
+				break
+			}
+		}
+	}
+
+	result.RepliesReceived = len(rtts)
+	result.FrameLoss = float64(oamLoopbackCount-len(rtts)) / float64(oamLoopbackCount) * 100
+
+	if len(rtts) == 0 {
+		return result, fmt.Errorf("no loopback reply received from MEP %d", mep)
+	}
+
+	var total time.Duration
+	for _, rtt := range rtts {
+		total += rtt
+	}
+	result.LoopbackResponseMs = int((total / time.Duration(len(rtts))).Milliseconds())
+
+	// Delay variation is the mean difference between consecutive frame delays
+	if len(rtts) > 1 {
+		var variation time.Duration
+		for i := 1; i < len(rtts); i++ {
+			diff := rtts[i] - rtts[i-1]
+			if diff < 0 {
+				diff = -diff
+			}
+			variation += diff
+		}
+		result.DelayVariation = int((variation / time.Duration(len(rtts)-1)).Milliseconds())
+	}
+
+	return result, nil
+}
+
+// learnRemoteMEP listens for a CCM from mep and returns its source address and MD level
+func learnRemoteMEP(conn *packet.Conn, mep int, timeout time.Duration) (net.HardwareAddr, int) {
+	buf := make([]byte, 1518)
+	deadline := time.Now().Add(timeout)
+	conn.SetReadDeadline(deadline)
+
+	for time.Now().Before(deadline) {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+
+		var frame ethernet.Frame
+		if err := frame.UnmarshalBinary(buf[:n]); err != nil || frame.EtherType != etherTypeCFM {
+			continue
+		}
+
+		// CCM: MEL/version, opcode, flags, TLV offset, sequence number, MEP ID
+		pdu := frame.Payload
+		if len(pdu) < 10 || pdu[1] != cfmOpcodeCCM {
+			continue
+		}
+		if int(binary.BigEndian.Uint16(pdu[8:10])&0x1fff) == mep {
+			return frame.Source, int(pdu[0] >> 5)
+		}
+	}
+
+	return nil, 0
+}
+
+// buildLBM encodes a Loopback Message PDU with an End TLV
+func buildLBM(level int, transactionID uint32) []byte {
+	pdu := make([]byte, 9)
+	pdu[0] = byte(level&0x07) << 5 // MEL, version 0
+	pdu[1] = cfmOpcodeLBM
+	pdu[2] = 0 // Flags
+	pdu[3] = 4 // First TLV offset
+	binary.BigEndian.PutUint32(pdu[4:8], transactionID)
+	pdu[8] = 0 // End TLV
+	return pdu
+}
+
+// parseLoopback returns the opcode and transaction ID of an LBM or LBR PDU
+func parseLoopback(pdu []byte) (int, uint32, bool) {
+	if len(pdu) < 8 {
+		return 0, 0, false
+	}
+	opcode := int(pdu[1])
+	if opcode != cfmOpcodeLBM && opcode != cfmOpcodeLBR {
+		return 0, 0, false
+	}
+	return opcode, binary.BigEndian.Uint32(pdu[4:8]), true
+}
@@ -0,0 +1,238 @@
+package layer2
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// vlanDotName matches Linux VLAN interfaces named <parent>.<vid>, e.g. eth0.10
+var vlanDotName = regexp.MustCompile(`^(.+)\.(\d{1,4})$`)
+
+// VLANInfo is an 802.1Q VLAN interface configured on this host
+type VLANInfo = common.VLANInfo
+
+// DetectVLANs lists the VLAN interfaces configured on this host, with the
+// addresses assigned to each
+func DetectVLANs() ([]VLANInfo, error) {
+	var vlans []VLANInfo
+	var err error
+
+	switch runtime.GOOS {
+	case "linux":
+		vlans, err = detectLinuxVLANs()
+	case "darwin":
+		var output []byte
+		output, err = exec.Command("networksetup", "-listVLANs").Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to run networksetup: %w", err)
+		}
+		vlans = parseNetworksetupVLANs(string(output))
+	case "windows":
+		var output []byte
+		output, err = exec.Command("powershell", "-NoProfile", "-Command",
+			`Get-NetAdapterAdvancedProperty -RegistryKeyword VlanID -ErrorAction SilentlyContinue | ForEach-Object { "$($_.Name)|$($_.RegistryValue)" }`).Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to query adapter VLAN IDs: %w", err)
+		}
+		vlans = parseWindowsVLANs(string(output))
+	default:
+		return nil, fmt.Errorf("VLAN detection is not supported on %s", runtime.GOOS)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range vlans {
+		iface, err := net.InterfaceByName(vlans[i].Interface)
+		if err != nil {
+			continue
+		}
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				vlans[i].Addresses = append(vlans[i].Addresses, addr.String())
+			}
+		}
+	}
+	return vlans, nil
+}
+
+// detectLinuxVLANs reads the 8021q module's VLAN table and adds any
+// interface named in dot notation that the table does not list
+func detectLinuxVLANs() ([]VLANInfo, error) {
+	var vlans []VLANInfo
+	if data, err := os.ReadFile("/proc/net/vlan/config"); err == nil {
+		vlans = parseProcNetVLAN(string(data))
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read VLAN table: %w", err)
+	}
+
+	known := make(map[string]bool, len(vlans))
+	for _, vlan := range vlans {
+		known[vlan.Interface] = true
+	}
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interfaces: %w", err)
+	}
+	for _, iface := range interfaces {
+		if known[iface.Name] {
+			continue
+		}
+		m := vlanDotName.FindStringSubmatch(iface.Name)
+		if m == nil {
+			continue
+		}
+		id, err := strconv.Atoi(m[2])
+		if err != nil || id < 1 || id > 4094 {
+			continue
+		}
+		vlans = append(vlans, VLANInfo{Interface: iface.Name, VLANID: id, ParentInterface: m[1]})
+	}
+
+	sort.Slice(vlans, func(i, j int) bool { return vlans[i].Interface < vlans[j].Interface })
+	return vlans, nil
+}
+
+// parseProcNetVLAN parses /proc/net/vlan/config: two header lines followed
+// by "<device> | <vid> | <parent>" rows
+func parseProcNetVLAN(data string) []VLANInfo {
+	var vlans []VLANInfo
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) != 3 {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+		if err != nil {
+			continue // Header line
+		}
+		vlans = append(vlans, VLANInfo{
+			Interface:       strings.TrimSpace(fields[0]),
+			VLANID:          id,
+			ParentInterface: strings.TrimSpace(fields[2]),
+		})
+	}
+	return vlans
+}
+
+// parseNetworksetupVLANs parses "networksetup -listVLANs", which describes
+// each VLAN in a block of "Key: value" lines
+func parseNetworksetupVLANs(output string) []VLANInfo {
+	var vlans []VLANInfo
+	var current VLANInfo
+
+	flush := func() {
+		if current.Interface != "" && current.VLANID > 0 {
+			vlans = append(vlans, current)
+		}
+		current = VLANInfo{}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch {
+		case strings.HasPrefix(key, "VLAN User Defined Name"):
+			flush()
+		case strings.HasPrefix(key, "Parent Device"):
+			current.ParentInterface = value
+		case strings.HasPrefix(key, "Device"):
+			current.Interface = value
+		case strings.HasPrefix(key, "Tag"):
+			current.VLANID, _ = strconv.Atoi(value)
+		}
+	}
+	flush()
+	return vlans
+}
+
+// parseWindowsVLANs parses "<adapter>|<vlan id>" lines. The adapter tags
+// its own traffic, so it is both the interface and the parent.
+func parseWindowsVLANs(output string) []VLANInfo {
+	var vlans []VLANInfo
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "|")
+		if !ok {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || id == 0 { // 0 means the adapter is untagged
+			continue
+		}
+		vlans = append(vlans, VLANInfo{Interface: name, VLANID: id, ParentInterface: name})
+	}
+	return vlans
+}
+
+// ipForwardingDisabled reports whether the host is known not to forward IPv4
+// packets, which stops it routing between its VLANs
+func ipForwardingDisabled() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	data, err := os.ReadFile("/proc/sys/net/ipv4/ip_forward")
+	return err == nil && strings.TrimSpace(string(data)) == "0"
+}
+
+// testVLANs detects VLAN interfaces, warning when any of them cannot be
+// routed: it has no address, or the host has several but does not forward
+func (r *Runner) testVLANs() common.TestResult {
+	result := common.TestResult{
+		Layer:     2,
+		Name:      "VLAN Detection",
+		StartTime: time.Now(),
+	}
+
+	vlans, err := DetectVLANs()
+	if err != nil {
+		result.Diagnostics.DataLink = &common.DataLinkDiagnostics{Error: err.Error()}
+		return result.Finish(common.StatusWarning, fmt.Sprintf("VLAN detection unavailable: %v", err))
+	}
+
+	result.Diagnostics.DataLink = &common.DataLinkDiagnostics{VLANs: vlans}
+	result.Metrics.Custom = map[string]interface{}{
+		"vlan_count": len(vlans),
+	}
+	if len(vlans) == 0 {
+		return result.Finish(common.StatusPassed, "No VLAN interfaces detected")
+	}
+
+	names := make([]string, len(vlans))
+	var unaddressed []string
+	for i, vlan := range vlans {
+		names[i] = fmt.Sprintf("%s (VLAN %d on %s)", vlan.Interface, vlan.VLANID, vlan.ParentInterface)
+		if len(vlan.Addresses) == 0 {
+			unaddressed = append(unaddressed, vlan.Interface)
+		}
+	}
+	summary := fmt.Sprintf("%d VLAN interfaces detected: %s", len(vlans), strings.Join(names, ", "))
+
+	if len(unaddressed) > 0 {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("%s; no IP address on %s, so traffic cannot be routed to or from it",
+			summary, strings.Join(unaddressed, ", ")))
+	}
+	if len(vlans) > 1 && ipForwardingDisabled() {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("%s; IP forwarding is disabled, so the host does not route between them", summary))
+	}
+	return result.Finish(common.StatusPassed, summary)
+}
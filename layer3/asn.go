@@ -0,0 +1,144 @@
+package layer3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/oschwald/geoip2-golang"
+
+	"ghostshell/app/layers/common"
+)
+
+// bgpViewIPURL is the BGPView API queried for the ASN of an IP when the
+// GeoIP database has no ASN data
+const bgpViewIPURL = "https://api.bgpview.io/ip/"
+
+// asnLookupTimeout bounds the BGPView API request
+const asnLookupTimeout = 10 * time.Second
+
+// ASNInfo holds the ASN and approximate location of an IP address
+type ASNInfo = common.ASNInfo
+
+// bgpViewIPResponse is the part of the BGPView /ip response used here
+type bgpViewIPResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Prefixes []struct {
+			ASN struct {
+				ASN         uint   `json:"asn"`
+				Name        string `json:"name"`
+				Description string `json:"description"`
+			} `json:"asn"`
+		} `json:"prefixes"`
+	} `json:"data"`
+}
+
+// LookupASN finds the ASN, country and city of ip. Location comes from the
+// MaxMind database at geoipDBPath, as does the ASN when it is an ASN
+// database; otherwise the ASN is fetched from the BGPView API.
+func LookupASN(ctx context.Context, ip string, geoipDBPath string) (ASNInfo, error) {
+	info := ASNInfo{IP: ip}
+
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return info, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	db, err := geoip2.Open(geoipDBPath)
+	if err != nil {
+		return info, fmt.Errorf("failed to open GeoIP database %s: %w", geoipDBPath, err)
+	}
+	defer db.Close()
+
+	if city, err := db.City(parsed); err == nil {
+		info.Country = city.Country.IsoCode
+		info.City = city.City.Names["en"]
+	}
+
+	if asn, err := db.ASN(parsed); err == nil && asn.AutonomousSystemNumber != 0 {
+		info.ASN = asn.AutonomousSystemNumber
+		info.ASNName = asn.AutonomousSystemOrganization
+		info.Source = "geoip"
+		return info, nil
+	}
+
+	asn, name, err := queryBGPView(ctx, ip)
+	if err != nil {
+		return info, fmt.Errorf("ASN lookup failed for %s: %w", ip, err)
+	}
+	info.ASN = asn
+	info.ASNName = name
+	info.Source = "bgpview"
+	return info, nil
+}
+
+// queryBGPView returns the ASN and its name for the first prefix BGPView
+// reports as announcing ip
+func queryBGPView(ctx context.Context, ip string) (uint, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, asnLookupTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bgpViewIPURL+ip, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("BGPView returned HTTP %d", resp.StatusCode)
+	}
+
+	var body bgpViewIPResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return 0, "", fmt.Errorf("invalid BGPView response: %w", err)
+	}
+	if body.Status != "ok" || len(body.Data.Prefixes) == 0 {
+		return 0, "", fmt.Errorf("no announcing prefix found")
+	}
+
+	asn := body.Data.Prefixes[0].ASN
+	name := asn.Description
+	if name == "" {
+		name = asn.Name
+	}
+	return asn.ASN, name, nil
+}
+
+// enrichWithASN adds the ASN and location of PingAddr to a successful ping
+// result. The lookup is skipped when no GeoIP database is configured, and
+// a failed lookup leaves the result's status alone.
+func (r *Runner) enrichWithASN(ctx context.Context, result *common.TestResult) error {
+	if r.GeoIPDBPath == "" || result.Status != common.StatusPassed {
+		return nil
+	}
+
+	ip := r.PingAddr
+	if net.ParseIP(ip) == nil {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, ip)
+		if err != nil || len(addrs) == 0 {
+			return fmt.Errorf("could not resolve %s", r.PingAddr)
+		}
+		ip = addrs[0]
+	}
+
+	info, err := LookupASN(ctx, ip, r.GeoIPDBPath)
+	if info.ASN == 0 && info.Country == "" && info.City == "" {
+		return err
+	}
+	if result.Diagnostics.Network == nil {
+		result.Diagnostics.Network = &common.NetworkDiagnostics{Target: r.PingAddr}
+	}
+	result.Diagnostics.Network.Geolocation = &info
+	return err
+}
@@ -0,0 +1,198 @@
+package layer3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"ghostshell/app/layers/common"
+)
+
+// dnssecQueryTimeout bounds each query of the DNSSEC check
+const dnssecQueryTimeout = 5 * time.Second
+
+// dnssecResolver returns the resolver address used for the DNSSEC check
+func (r *Runner) dnssecResolver() (string, error) {
+	if r.DNSSECResolver != "" {
+		if _, _, err := net.SplitHostPort(r.DNSSECResolver); err != nil {
+			return net.JoinHostPort(r.DNSSECResolver, "53"), nil
+		}
+		return r.DNSSECResolver, nil
+	}
+	conf, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return "", fmt.Errorf("no DNSSEC resolver configured and system resolvers unavailable: %w", err)
+	}
+	if len(conf.Servers) == 0 {
+		return "", errors.New("no DNSSEC resolver configured and no system resolvers found")
+	}
+	return net.JoinHostPort(conf.Servers[0], conf.Port), nil
+}
+
+// dnssecQuery sends a query for name and qtype to resolver with the DO and AD
+// bits set, retrying over TCP when the UDP answer is truncated
+func dnssecQuery(ctx context.Context, resolver, name string, qtype uint16) (*dns.Msg, time.Duration, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(name), qtype)
+	msg.AuthenticatedData = true
+	msg.SetEdns0(4096, true)
+
+	client := &dns.Client{Timeout: dnssecQueryTimeout}
+	resp, rtt, err := client.ExchangeContext(ctx, msg, resolver)
+	if err == nil && resp.Truncated {
+		client.Net = "tcp"
+		resp, rtt, err = client.ExchangeContext(ctx, msg, resolver)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, rtt, fmt.Errorf("%s query for %s returned %s", dns.TypeToString[qtype], name, dns.RcodeToString[resp.Rcode])
+	}
+	return resp, rtt, nil
+}
+
+// hasRecord reports whether rrs contains a record of type rrtype
+func hasRecord(rrs []dns.RR, rrtype uint16) bool {
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == rrtype {
+			return true
+		}
+	}
+	return false
+}
+
+// countRecords returns the number of records of type rrtype in rrs
+func countRecords(rrs []dns.RR, rrtype uint16) int {
+	count := 0
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == rrtype {
+			count++
+		}
+	}
+	return count
+}
+
+// findZone returns the apex of the zone containing name, taken from the SOA
+// record in the answer or authority section of an SOA query
+func findZone(ctx context.Context, resolver, name string) (string, error) {
+	resp, _, err := dnssecQuery(ctx, resolver, name, dns.TypeSOA)
+	if err != nil {
+		return "", err
+	}
+	for _, rr := range append(resp.Answer, resp.Ns...) {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Hdr.Name, nil
+		}
+	}
+	return "", fmt.Errorf("no SOA record found for %s", name)
+}
+
+// hasDS reports whether zone has a DS record in its parent
+func hasDS(ctx context.Context, resolver, zone string) (bool, error) {
+	resp, _, err := dnssecQuery(ctx, resolver, zone, dns.TypeDS)
+	if err != nil {
+		return false, err
+	}
+	return hasRecord(resp.Answer, dns.TypeDS), nil
+}
+
+// testDNSSEC checks that the A records of Hostname are signed and that the
+// resolver validated them. Hostnames in unsigned zones are skipped.
+func (r *Runner) testDNSSEC(ctx context.Context) common.TestResult {
+	result := common.TestResult{
+		Layer:     3,
+		Name:      fmt.Sprintf("DNSSEC Validation (%s)", r.Hostname),
+		StartTime: time.Now(),
+	}
+
+	info := &common.DNSSECInfo{}
+	diagnostics := &common.NetworkDiagnostics{Target: r.Hostname, DNSSEC: info}
+	result.Diagnostics.Network = diagnostics
+
+	fail := func(status common.TestStatus, msg string, err error) common.TestResult {
+		diagnostics.Error = err.Error()
+		return result.Finish(status, fmt.Sprintf("%s: %v", msg, err))
+	}
+
+	resolver, err := r.dnssecResolver()
+	if err != nil {
+		return fail(common.StatusFailed, "DNSSEC check could not start", err)
+	}
+	info.Resolver = resolver
+	diagnostics.IP = resolver
+
+	resp, rtt, err := dnssecQuery(ctx, resolver, r.Hostname, dns.TypeA)
+	if err != nil {
+		return fail(common.StatusFailed, fmt.Sprintf("DNSSEC query for %s failed", r.Hostname), err)
+	}
+	info.QueryLatencyMs = float64(rtt.Microseconds()) / 1000
+	info.Validated = resp.AuthenticatedData
+	info.HasRRSIG = hasRecord(resp.Answer, dns.TypeRRSIG)
+	result.Metrics.Latency = rtt
+
+	zone, err := findZone(ctx, resolver, r.Hostname)
+	if err != nil {
+		return fail(common.StatusFailed, fmt.Sprintf("Failed to find the zone of %s", r.Hostname), err)
+	}
+	info.Zone = strings.TrimSuffix(zone, ".")
+
+	// A signed zone is delegated with a DS record; the root has none but is
+	// always signed
+	if zone != "." {
+		info.HasDS, err = hasDS(ctx, resolver, zone)
+		if err != nil {
+			return fail(common.StatusFailed, fmt.Sprintf("DS query for %s failed", info.Zone), err)
+		}
+	}
+
+	keys, _, err := dnssecQuery(ctx, resolver, zone, dns.TypeDNSKEY)
+	if err == nil {
+		info.DNSKEYCount = countRecords(keys.Answer, dns.TypeDNSKEY)
+	}
+
+	// Count the signed zones from the root down to the hostname's zone
+	info.ChainDepth = 1
+	labels := dns.SplitDomainName(zone)
+	for i := len(labels) - 1; i >= 0; i-- {
+		name := dns.Fqdn(strings.Join(labels[i:], "."))
+		signed := info.HasDS
+		if name != zone {
+			if signed, err = hasDS(ctx, resolver, name); err != nil {
+				break
+			}
+		}
+		if !signed {
+			break
+		}
+		info.ChainDepth++
+	}
+
+	result.Metrics.Custom = map[string]interface{}{
+		"dnssec_validated": info.Validated,
+		"chain_depth":      info.ChainDepth,
+		"query_latency_ms": info.QueryLatencyMs,
+	}
+
+	if !info.HasDS && zone != "." {
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("DNSSEC is not configured for %s: zone %s has no DS record",
+			r.Hostname, info.Zone))
+	}
+	if !info.HasRRSIG {
+		diagnostics.Error = "signed zone returned no RRSIG"
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Zone %s is signed but the answer for %s from %s has no RRSIG record",
+			info.Zone, r.Hostname, resolver))
+	}
+	if !info.Validated {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Answer for %s is signed but resolver %s did not validate it (AD bit not set)",
+			r.Hostname, resolver))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("DNSSEC validated for %s by %s: zone %s, chain depth %d, %d DNSKEY records",
+		r.Hostname, resolver, info.Zone, info.ChainDepth, info.DNSKEYCount))
+}
@@ -0,0 +1,207 @@
+package layer3
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/common"
+)
+
+// gatewayUnreachableMsg is the message of the external tests skipped because
+// the default gateway did not answer
+const gatewayUnreachableMsg = "Gateway unreachable, external connectivity not tested"
+
+// errNoDefaultRoute is returned when the routing table has no default route
+var errNoDefaultRoute = errors.New("no default route")
+
+// routeGatewayPattern matches the gateway line of `route -n get default`
+var routeGatewayPattern = regexp.MustCompile(`(?m)^\s*gateway:\s*(\S+)`)
+
+// defaultGateway returns the IPv4 next hop of the default route
+func defaultGateway(ctx context.Context) (string, error) {
+	switch runtime.GOOS {
+	case "linux":
+		f, err := os.Open("/proc/net/route")
+		if err != nil {
+			return "", fmt.Errorf("failed to read routing table: %w", err)
+		}
+		defer f.Close()
+		return parseProcNetRoute(f)
+	case "darwin":
+		output, err := exec.CommandContext(ctx, "route", "-n", "get", "default").CombinedOutput()
+		if err != nil {
+			if strings.Contains(string(output), "not in table") {
+				return "", errNoDefaultRoute
+			}
+			return "", fmt.Errorf("route failed: %v - %s", err, strings.TrimSpace(string(output)))
+		}
+		return parseRouteGetOutput(string(output))
+	case "windows":
+		output, err := exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command",
+			"Get-NetRoute -DestinationPrefix 0.0.0.0/0 -ErrorAction SilentlyContinue | "+
+				"Sort-Object RouteMetric | Select-Object -First 1 -ExpandProperty NextHop").CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("Get-NetRoute failed: %v - %s", err, strings.TrimSpace(string(output)))
+		}
+		gateway := strings.TrimSpace(string(output))
+		if gateway == "" || gateway == "0.0.0.0" {
+			return "", errNoDefaultRoute
+		}
+		if net.ParseIP(gateway) == nil {
+			return "", fmt.Errorf("unexpected Get-NetRoute output %q", gateway)
+		}
+		return gateway, nil
+	}
+	return "", fmt.Errorf("default gateway lookup is not supported on %s", runtime.GOOS)
+}
+
+// parseProcNetRoute returns the gateway of the default route with the lowest
+// metric in a /proc/net/route table, whose addresses are little-endian hex
+func parseProcNetRoute(r io.Reader) (string, error) {
+	const rtfUp, rtfGateway = 0x1, 0x2
+
+	var gateway net.IP
+	bestMetric := -1
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // Header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 16)
+		if err != nil || flags&(rtfUp|rtfGateway) != rtfUp|rtfGateway {
+			continue
+		}
+		raw, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil {
+			continue
+		}
+		metric, err := strconv.Atoi(fields[6])
+		if err != nil {
+			continue
+		}
+		if bestMetric >= 0 && metric >= bestMetric {
+			continue
+		}
+		gateway = make(net.IP, net.IPv4len)
+		binary.LittleEndian.PutUint32(gateway, uint32(raw))
+		bestMetric = metric
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read routing table: %w", err)
+	}
+	if gateway == nil {
+		return "", errNoDefaultRoute
+	}
+	return gateway.String(), nil
+}
+
+// parseRouteGetOutput returns the gateway reported by `route -n get default`
+func parseRouteGetOutput(output string) (string, error) {
+	m := routeGatewayPattern.FindStringSubmatch(output)
+	if m == nil {
+		return "", errNoDefaultRoute
+	}
+	return m[1], nil
+}
+
+// testGateway pings the default gateway once, so that failures of the
+// external tests can be told apart from a broken local route
+func (r *Runner) testGateway(ctx context.Context, logger *zap.Logger) common.TestResult {
+	result := common.TestResult{
+		Layer:     3,
+		Name:      "Default Gateway Reachability",
+		StartTime: time.Now(),
+	}
+
+	diagnostics := &common.NetworkDiagnostics{Sent: common.Ptr(1)}
+	result.Diagnostics.Network = diagnostics
+
+	gateway, err := defaultGateway(ctx)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		if errors.Is(err, errNoDefaultRoute) {
+			return result.Finish(common.StatusFailed, "No default route configured, external hosts cannot be reached")
+		}
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Could not determine the default gateway: %v", err))
+	}
+	diagnostics.DefaultGateway = gateway
+	diagnostics.Target = gateway
+
+	diagnostics.Method = "raw_icmp"
+	rtts, _, err := RawICMPPing(ctx, gateway, 1, 0)
+	if err != nil && len(rtts) == 0 {
+		logger.Debug("Native ICMP ping unavailable, falling back to ping binary", zap.Error(err))
+		diagnostics.Method = "ping_binary"
+		var output string
+		if output, err = runPing(gateway, 1); err == nil {
+			rtts, _ = parsePingOutput(output, 1)
+		}
+	}
+	if err != nil {
+		diagnostics.Error = err.Error()
+	}
+
+	diagnostics.Received = common.Ptr(len(rtts))
+	diagnostics.RTTsMs = durationsToMs(rtts)
+	if len(rtts) == 0 {
+		diagnostics.PacketLoss = common.Ptr(100.0)
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Default gateway %s did not answer an ICMP echo request", gateway))
+	}
+
+	result.Metrics.Latency = rtts[0]
+	return result.Finish(common.StatusPassed, fmt.Sprintf("Default gateway %s is reachable (RTT %s)", gateway, rtts[0]))
+}
+
+// skipExternalTests returns a skipped result for the ping, DNS and each
+// enabled test that needs a route off the local network
+func (r *Runner) skipExternalTests() []common.TestResult {
+	names := []string{
+		fmt.Sprintf("Ping Test (%s)", r.PingAddr),
+		fmt.Sprintf("DNS Resolution Test (%s)", r.Hostname),
+	}
+	if r.CheckDNSSEC {
+		names = append(names, fmt.Sprintf("DNSSEC Validation (%s)", r.Hostname))
+	}
+	if r.CheckPMTU {
+		names = append(names, fmt.Sprintf("Path MTU Discovery Test (%s)", r.PingAddr))
+	}
+	if r.Traceroute {
+		names = append(names, fmt.Sprintf("Traceroute Test (%s)", r.PingAddr))
+	}
+	if r.CheckWHOIS {
+		names = append(names, fmt.Sprintf("WHOIS Ownership Test (%s)", r.PingAddr))
+	}
+	if r.CheckGeolocation {
+		names = append(names, fmt.Sprintf("Geolocation Test (%s)", r.PingAddr))
+	}
+
+	now := time.Now()
+	results := make([]common.TestResult, 0, len(names))
+	for _, name := range names {
+		results = append(results, common.TestResult{
+			Layer:     3,
+			Name:      name,
+			Status:    common.StatusSkipped,
+			Message:   gatewayUnreachableMsg,
+			StartTime: now,
+			EndTime:   now,
+		})
+	}
+	return results
+}
@@ -0,0 +1,84 @@
+package layer3
+
+import (
+	"fmt"
+	"math"
+	"net"
+
+	"github.com/oschwald/geoip2-golang"
+
+	"ghostshell/app/layers/common"
+)
+
+// earthRadiusKm is the mean Earth radius used by the Haversine formula
+const earthRadiusKm = 6371.0
+
+// GeoInfo holds the geographic location of an IP address
+type GeoInfo = common.GeoInfo
+
+// GeoHop is a traceroute hop annotated with its location
+type GeoHop = common.GeoHop
+
+// GeolocateIP looks up ip in the MaxMind GeoIP2/GeoLite2 City database at geoipDBPath
+func GeolocateIP(ip string, geoipDBPath string) (GeoInfo, error) {
+	db, err := geoip2.Open(geoipDBPath)
+	if err != nil {
+		return GeoInfo{}, fmt.Errorf("failed to open GeoIP database %s: %w", geoipDBPath, err)
+	}
+	defer db.Close()
+
+	return geolocate(db, ip)
+}
+
+// geolocate looks up ip in an open GeoIP database
+func geolocate(db *geoip2.Reader, ip string) (GeoInfo, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return GeoInfo{}, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	city, err := db.City(parsed)
+	if err != nil {
+		return GeoInfo{}, fmt.Errorf("GeoIP lookup failed for %s: %w", ip, err)
+	}
+	if city.Location.Latitude == 0 && city.Location.Longitude == 0 {
+		return GeoInfo{}, fmt.Errorf("no location found for %s", ip)
+	}
+
+	info := GeoInfo{
+		Latitude:  city.Location.Latitude,
+		Longitude: city.Location.Longitude,
+		Country:   city.Country.IsoCode,
+		Continent: city.Continent.Code,
+		City:      city.City.Names["en"],
+	}
+
+	// ISP data is only present in the commercial ISP database
+	if isp, err := db.ISP(parsed); err == nil {
+		info.ISP = isp.ISP
+	}
+
+	return info, nil
+}
+
+// haversineKm returns the great-circle distance between two locations
+func haversineKm(a, b GeoInfo) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(b.Latitude - a.Latitude)
+	dLon := toRad(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Latitude))*math.Cos(toRad(b.Latitude))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// outboundIP returns the local address used to reach target
+func outboundIP(target string) (string, error) {
+	conn, err := net.Dial("udp4", net.JoinHostPort(target, "53"))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
@@ -0,0 +1,220 @@
+package layer3
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// protocolICMP is the IANA protocol number for ICMP over IPv4
+const protocolICMP = 1
+
+// RawICMPPing sends count ICMP echo requests to target and returns the RTT of
+// every probe that was answered along with the packet loss percentage.
+// The sending goroutine is pinned to an OS thread so RTTs are not skewed by
+// goroutine migration between the write and the read.
+func RawICMPPing(ctx context.Context, target string, count int, interval time.Duration) ([]time.Duration, float64, error) {
+	if runtime.GOOS == "windows" {
+		return nil, 0, fmt.Errorf("raw ICMP sockets are not supported on windows")
+	}
+	if count <= 0 {
+		return nil, 0, fmt.Errorf("count must be greater than 0")
+	}
+
+	dst, err := net.ResolveIPAddr("ip4", target)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to resolve %s: %w", target, err)
+	}
+
+	conn, network, err := listenICMP()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// Unprivileged datagram sockets expect a UDP address and rewrite the ID
+	var addr net.Addr = dst
+	if network == "udp4" {
+		addr = &net.UDPAddr{IP: dst.IP}
+	}
+
+	id := os.Getpid() & 0xffff
+	readBuf := make([]byte, 1500)
+	var rtts []time.Duration
+
+	for seq := 0; seq < count; seq++ {
+		if seq > 0 && interval > 0 {
+			select {
+			case <-ctx.Done():
+				return rtts, lossPct(count, len(rtts)), ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+
+		msg := icmp.Message{
+			Type: ipv4.ICMPTypeEcho,
+			Code: 0,
+			Body: &icmp.Echo{
+				ID:   id,
+				Seq:  seq,
+				Data: []byte("GhostSuite-ICMP-Probe"),
+			},
+		}
+		wb, err := msg.Marshal(nil)
+		if err != nil {
+			return rtts, lossPct(count, len(rtts)), fmt.Errorf("failed to marshal ICMP message: %w", err)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return rtts, lossPct(count, len(rtts)), fmt.Errorf("failed to set read deadline: %w", err)
+		}
+
+		start := time.Now()
+		if _, err := conn.WriteTo(wb, addr); err != nil {
+			continue
+		}
+
+		// Read until our echo reply arrives or the deadline expires
+		for {
+			n, _, err := conn.ReadFrom(readBuf)
+			if err != nil {
+				break
+			}
+			rtt := time.Since(start)
+
+			reply, err := icmp.ParseMessage(protocolICMP, readBuf[:n])
+			if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+				continue
+			}
+			echo, ok := reply.Body.(*icmp.Echo)
+			if !ok || echo.Seq != seq {
+				continue
+			}
+			if network == "ip4:icmp" && echo.ID != id {
+				continue
+			}
+
+			rtts = append(rtts, rtt)
+			break
+		}
+	}
+
+	return rtts, lossPct(count, len(rtts)), nil
+}
+
+// listenICMP opens a privileged raw ICMP socket, falling back to an
+// unprivileged datagram ICMP socket where the kernel allows it
+func listenICMP() (*icmp.PacketConn, string, error) {
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err == nil {
+		return conn, "ip4:icmp", nil
+	}
+
+	conn, udpErr := icmp.ListenPacket("udp4", "0.0.0.0")
+	if udpErr == nil {
+		return conn, "udp4", nil
+	}
+
+	return nil, "", fmt.Errorf("failed to open ICMP socket: %v", err)
+}
+
+// lossPct returns the percentage of probes that were not answered
+func lossPct(sent, received int) float64 {
+	if sent == 0 {
+		return 0
+	}
+	return float64(sent-received) / float64(sent) * 100
+}
+
+// percentile returns the p-th percentile (0-100) of the given RTTs using nearest-rank
+func percentile(rtts []time.Duration, p float64) time.Duration {
+	if len(rtts) == 0 {
+		return 0
+	}
+
+	sorted := make([]time.Duration, len(rtts))
+	copy(sorted, rtts)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// averageRTT returns the mean of the given RTTs
+func averageRTT(rtts []time.Duration) time.Duration {
+	if len(rtts) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, rtt := range rtts {
+		total += rtt
+	}
+	return total / time.Duration(len(rtts))
+}
+
+// durationsToMs converts RTTs to fractional milliseconds for diagnostics
+func durationsToMs(rtts []time.Duration) []float64 {
+	ms := make([]float64, len(rtts))
+	for i, rtt := range rtts {
+		ms[i] = float64(rtt.Microseconds()) / 1000
+	}
+	return ms
+}
+
+// RTTStats summarises the round trip times of a ping run
+type RTTStats struct {
+	Min    time.Duration
+	Max    time.Duration
+	Avg    time.Duration
+	StdDev time.Duration
+	Jitter time.Duration // Mean absolute difference between consecutive RTTs
+}
+
+// computeRTTStats returns the min, max, mean, standard deviation and jitter of rtts
+func computeRTTStats(rtts []time.Duration) RTTStats {
+	if len(rtts) == 0 {
+		return RTTStats{}
+	}
+
+	stats := RTTStats{Min: rtts[0], Max: rtts[0], Avg: averageRTT(rtts)}
+	var variance, jitter float64
+	for i, rtt := range rtts {
+		if rtt < stats.Min {
+			stats.Min = rtt
+		}
+		if rtt > stats.Max {
+			stats.Max = rtt
+		}
+		d := float64(rtt - stats.Avg)
+		variance += d * d
+		if i > 0 {
+			jitter += math.Abs(float64(rtt - rtts[i-1]))
+		}
+	}
+	stats.StdDev = time.Duration(math.Sqrt(variance / float64(len(rtts))))
+	if len(rtts) > 1 {
+		stats.Jitter = time.Duration(jitter / float64(len(rtts)-1))
+	}
+	return stats
+}
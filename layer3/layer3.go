@@ -3,6 +3,7 @@ package layer3
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os/exec"
@@ -10,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/oschwald/geoip2-golang"
 	"go.uber.org/zap"
 
 	"ghostshell/app/layers/common"
@@ -24,9 +26,10 @@ type Runner struct {
 func New(hostname string, pingAddr string, pingCount int) *Runner {
 	return &Runner{
 		Layer3Runner: &common.Layer3Runner{
-			Hostname:  hostname,
-			PingAddr:  pingAddr,
-			PingCount: pingCount,
+			Hostname:   hostname,
+			PingAddr:   pingAddr,
+			PingCount:  pingCount,
+			UseRawICMP: true,
 		},
 	}
 }
@@ -53,27 +56,40 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		parentResult.Status = common.StatusFailed
 		parentResult.Message = "Test cancelled"
 		parentResult.EndTime = time.Now()
-		return []common.TestResult{parentResult}, ctx.Err()
+		return []common.TestResult{parentResult}, common.NewLayerError(3, ctx.Err())
 	default:
 		var failedTests []string
 
-		// Run ping test
-		pingResult := common.TestResult{
-			Layer:     3,
-			Name:      fmt.Sprintf("Ping Test (%s)", r.PingAddr),
-			StartTime: time.Now(),
+		// Check the local route before blaming external targets
+		if r.TestGateway {
+			gatewayResult := r.testGateway(ctx, logger)
+			parentResult.SubResults = append(parentResult.SubResults, gatewayResult)
+			if gatewayResult.Status == common.StatusFailed {
+				parentResult.SubResults = append(parentResult.SubResults, r.skipExternalTests()...)
+				if r.CheckMulticast {
+					parentResult.SubResults = append(parentResult.SubResults, r.testMulticastMembership())
+				}
+				if r.TestMulticast {
+					parentResult.SubResults = append(parentResult.SubResults, r.testMulticastGroups(ctx)...)
+				}
+				parentResult.Status = common.StatusFailed
+				parentResult.Message = fmt.Sprintf("Layer 3 tests failed: %s\n\n%s", gatewayResult.Message, gatewayUnreachableMsg)
+				logger.Error(parentResult.Message)
+				parentResult.EndTime = time.Now()
+				return []common.TestResult{parentResult}, &common.LayerError{Layer: 3, Code: common.ErrNetworkUnreachable, Cause: fmt.Errorf("default gateway unreachable")}
+			}
 		}
 
-		output, err := runPing(r.PingAddr, r.PingCount)
-		if err != nil {
-			pingResult.Status = common.StatusFailed
-			pingResult.Message = fmt.Sprintf("Ping test failed: %v\nOutput: %s", err, output)
+		// Run ping test
+		pingResult := r.testPing(ctx, logger)
+		if r.LookupASN {
+			if err := r.enrichWithASN(ctx, &pingResult); err != nil {
+				logger.Warn("ASN lookup failed", zap.String("target", r.PingAddr), zap.Error(err))
+			}
+		}
+		if pingResult.Status == common.StatusFailed {
 			failedTests = append(failedTests, pingResult.Message)
-		} else {
-			pingResult.Status = common.StatusPassed
-			pingResult.Message = fmt.Sprintf("Ping test successful:\n%s", output)
 		}
-		pingResult.EndTime = time.Now()
 		parentResult.SubResults = append(parentResult.SubResults, pingResult)
 
 		// DNS resolution test
@@ -96,6 +112,87 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		dnsResult.EndTime = time.Now()
 		parentResult.SubResults = append(parentResult.SubResults, dnsResult)
 
+		// Forward-confirmed reverse DNS of each resolved address
+		if r.CheckReverseDNS && err == nil {
+			for _, addr := range addrs {
+				rdnsResult := r.testReverseDNS(ctx, addr, addrs)
+				if rdnsResult.Status == common.StatusFailed {
+					failedTests = append(failedTests, rdnsResult.Message)
+				}
+				parentResult.SubResults = append(parentResult.SubResults, rdnsResult)
+			}
+		}
+
+		// DNSSEC signatures of the hostname
+		if r.CheckDNSSEC {
+			dnssecResult := r.testDNSSEC(ctx)
+			if dnssecResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, dnssecResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, dnssecResult)
+		}
+
+		// Path MTU discovery
+		if r.CheckPMTU {
+			pmtuResult := r.testPathMTU(ctx)
+			if pmtuResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, pmtuResult.Message)
+			}
+			if mtu, ok := pmtuResult.Metrics.Custom["path_mtu"]; ok {
+				if parentResult.Metrics.Custom == nil {
+					parentResult.Metrics.Custom = make(map[string]interface{})
+				}
+				parentResult.Metrics.Custom["path_mtu"] = mtu
+			}
+			parentResult.SubResults = append(parentResult.SubResults, pmtuResult)
+		}
+
+		// Traceroute to the ping address
+		if r.Traceroute {
+			traceResult := r.testTraceroute(ctx)
+			if traceResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, traceResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, traceResult)
+		}
+
+		// WHOIS ownership test
+		if r.CheckWHOIS {
+			whoisResult := r.testWHOISOwnership(ctx)
+			if whoisResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, whoisResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, whoisResult)
+		}
+
+		// Geolocation and routing distance test
+		if r.CheckGeolocation {
+			geoResult := r.testGeolocation(ctx)
+			if geoResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, geoResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, geoResult)
+		}
+
+		// Multicast group membership test
+		if r.CheckMulticast {
+			multicastResult := r.testMulticastMembership()
+			if multicastResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, multicastResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, multicastResult)
+		}
+
+		// Multicast group join and leave test
+		if r.TestMulticast {
+			for _, groupResult := range r.testMulticastGroups(ctx) {
+				if groupResult.Status == common.StatusFailed {
+					failedTests = append(failedTests, groupResult.Message)
+				}
+				parentResult.SubResults = append(parentResult.SubResults, groupResult)
+			}
+		}
+
 		// Set overall test status and message
 		if len(failedTests) > 0 {
 			parentResult.Status = common.StatusFailed
@@ -103,7 +200,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 				len(failedTests), strings.Join(failedTests, "\n\n"))
 			logger.Error(parentResult.Message)
 			parentResult.EndTime = time.Now()
-			return []common.TestResult{parentResult}, fmt.Errorf("layer 3 tests failed")
+			return []common.TestResult{parentResult}, &common.LayerError{Layer: 3, Code: common.ErrTestFailed, Cause: fmt.Errorf("layer 3 tests failed")}
 		}
 
 		parentResult.Status = common.StatusPassed
@@ -117,6 +214,319 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 	}
 }
 
+// testPathMTU discovers the path MTU to the ping address
+func (r *Runner) testPathMTU(ctx context.Context) common.TestResult {
+	result := common.TestResult{
+		Layer:     3,
+		Name:      fmt.Sprintf("Path MTU Discovery Test (%s)", r.PingAddr),
+		StartTime: time.Now(),
+	}
+
+	mtu, err := r.RunPMTUD(ctx, r.PingAddr)
+	if errors.Is(err, errPMTUDUnavailable) {
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("Path MTU discovery skipped: %v", err))
+	}
+	if err != nil {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Path MTU discovery to %s failed: %v", r.PingAddr, err))
+	}
+
+	result.Diagnostics.Network = &common.NetworkDiagnostics{
+		Target:  r.PingAddr,
+		PathMTU: mtu,
+	}
+	result.Metrics.Custom = map[string]interface{}{"path_mtu": mtu}
+
+	if mtu < pmtudMaxPayload+pmtudHeaderBytes {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Path MTU to %s is %d bytes, below the standard 1500", r.PingAddr, mtu))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("Path MTU to %s is %d bytes", r.PingAddr, mtu))
+}
+
+// testTraceroute traces the path to the ping address and records per-hop latency
+func (r *Runner) testTraceroute(ctx context.Context) common.TestResult {
+	result := common.TestResult{
+		Layer:     3,
+		Name:      fmt.Sprintf("Traceroute Test (%s)", r.PingAddr),
+		StartTime: time.Now(),
+	}
+
+	hops, err := r.RunTraceroute(ctx, r.PingAddr, r.MaxHops)
+	result.Diagnostics.Network = &common.NetworkDiagnostics{
+		Target:     r.PingAddr,
+		Traceroute: hops,
+	}
+
+	// The worst hop is the one with the highest best-case RTT
+	var worst HopResult
+	for _, hop := range hops {
+		if hop.BestRTT() > worst.BestRTT() {
+			worst = hop
+		}
+	}
+	result.Metrics.Custom = map[string]interface{}{
+		"hop_count":            len(hops),
+		"worst_hop":            worst.Index,
+		"worst_hop_latency_ms": float64(worst.BestRTT().Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		// Raw sockets and tracert are not always available, which is not a network fault
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Traceroute to %s unavailable: %v", r.PingAddr, err))
+	}
+
+	if len(hops) == 0 || !hops[len(hops)-1].Reached {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Traceroute to %s did not reach the target within %d hops",
+			r.PingAddr, len(hops)))
+	}
+
+	last := hops[len(hops)-1]
+	result.Metrics.Latency = last.BestRTT()
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("Traceroute to %s successful:\n"+
+		"- Hops: %d\n"+
+		"- Worst hop: %d (%s) at %s",
+		r.PingAddr, len(hops), worst.Index, worst.IP, worst.BestRTT()))
+}
+
+// testWHOISOwnership looks up the owner of the ping address and optionally
+// verifies it matches the expected organisation
+func (r *Runner) testWHOISOwnership(ctx context.Context) common.TestResult {
+	result := common.TestResult{
+		Layer:     3,
+		Name:      fmt.Sprintf("WHOIS Ownership Test (%s)", r.PingAddr),
+		StartTime: time.Now(),
+	}
+
+	ip := r.PingAddr
+	if net.ParseIP(ip) == nil {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, ip)
+		if err != nil || len(addrs) == 0 {
+			return result.Finish(common.StatusWarning, fmt.Sprintf("WHOIS lookup skipped: could not resolve %s", r.PingAddr))
+		}
+		ip = addrs[0]
+	}
+
+	info, err := QueryWHOIS(ctx, ip)
+	if err != nil {
+		// Registry outages and rate limits are not network layer failures
+		return result.Finish(common.StatusWarning, fmt.Sprintf("WHOIS lookup for %s failed: %v", ip, err))
+	}
+
+	result.Diagnostics.Network = &common.NetworkDiagnostics{
+		IP:              ip,
+		WHOIS:           &info,
+		ExpectedOrg:     r.ExpectedOrgName,
+		VerifyOwnership: common.Ptr(r.VerifyIPOwnership),
+	}
+
+	if r.VerifyIPOwnership && r.ExpectedOrgName != "" &&
+		!strings.Contains(strings.ToLower(info.OrgName), strings.ToLower(r.ExpectedOrgName)) {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("%s is owned by %q, expected %q",
+			ip, info.OrgName, r.ExpectedOrgName))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("WHOIS lookup for %s successful:\n"+
+		"- Network: %s (%s)\n"+
+		"- Organisation: %s\n"+
+		"- Country: %s",
+		ip, info.NetName, info.CIDR, info.OrgName, info.Country))
+}
+
+// testGeolocation locates this host, the ping address and every hop in
+// between, and flags routes that detour through other continents or exceed
+// the expected total distance
+func (r *Runner) testGeolocation(ctx context.Context) common.TestResult {
+	result := common.TestResult{
+		Layer:     3,
+		Name:      fmt.Sprintf("Geolocation Test (%s)", r.PingAddr),
+		StartTime: time.Now(),
+	}
+
+	if r.GeoIPDBPath == "" {
+		return result.Finish(common.StatusFailed, "Geolocation test requires a GeoIP database path")
+	}
+
+	db, err := geoip2.Open(r.GeoIPDBPath)
+	if err != nil {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to open GeoIP database %s: %v", r.GeoIPDBPath, err))
+	}
+	defer db.Close()
+
+	ip := r.PingAddr
+	if net.ParseIP(ip) == nil {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, ip)
+		if err != nil || len(addrs) == 0 {
+			return result.Finish(common.StatusFailed, fmt.Sprintf("Geolocation failed: could not resolve %s", r.PingAddr))
+		}
+		ip = addrs[0]
+	}
+
+	targetGeo, err := geolocate(db, ip)
+	if err != nil {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Could not geolocate %s: %v", ip, err))
+	}
+
+	// Hop discovery needs a raw socket; without it only the direct distance is known
+	traceHops, hopErr := r.RunTraceroute(ctx, ip, r.MaxHops)
+
+	var hops []GeoHop
+	for _, traceHop := range traceHops {
+		hop := GeoHop{TTL: traceHop.Index, IP: traceHop.IP}
+		if traceHop.IP != "" {
+			if info, err := geolocate(db, traceHop.IP); err == nil {
+				hop.Geo = &info
+			}
+		}
+		hops = append(hops, hop)
+	}
+
+	// Private source addresses can't be located, so fall back to the first located hop
+	var origin *GeoInfo
+	originIP, err := outboundIP(ip)
+	if err == nil {
+		if info, err := geolocate(db, originIP); err == nil {
+			origin = &info
+		}
+	}
+	if origin == nil {
+		for _, hop := range hops {
+			if hop.Geo != nil && hop.IP != ip {
+				origin, originIP = hop.Geo, hop.IP
+				break
+			}
+		}
+	}
+
+	geoInfo := &common.GeoPathInfo{
+		Target: targetGeo,
+		Hops:   hops,
+	}
+	diagnostics := &common.NetworkDiagnostics{
+		IP:      ip,
+		GeoInfo: geoInfo,
+	}
+	if hopErr != nil {
+		diagnostics.HopDiscoveryError = hopErr.Error()
+		diagnostics.TotalGeoDistanceKm = common.Ptr(0.0)
+	}
+	result.Diagnostics.Network = diagnostics
+
+	if origin == nil {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("%s is located in %s, %s; "+
+			"the testing machine could not be geolocated", ip, targetGeo.City, targetGeo.Country))
+	}
+	geoInfo.Origin = origin
+	geoInfo.OriginIP = originIP
+
+	// Sum the distance along the path, skipping hops that could not be located
+	directKm := haversineKm(*origin, targetGeo)
+	totalKm := 0.0
+	prev := *origin
+	var detours []string
+	for i := range hops {
+		if hops[i].Geo == nil {
+			continue
+		}
+		hops[i].DistanceKm = haversineKm(prev, *hops[i].Geo)
+		totalKm += hops[i].DistanceKm
+		prev = *hops[i].Geo
+
+		// A hop outside both endpoints' continents means traffic left and came back
+		if c := hops[i].Geo.Continent; c != origin.Continent && c != targetGeo.Continent {
+			detours = append(detours, fmt.Sprintf("%s (%s)", hops[i].IP, hops[i].Geo.Country))
+		}
+	}
+	totalKm += haversineKm(prev, targetGeo)
+
+	diagnostics.DirectGeoDistanceKm = common.Ptr(directKm)
+	diagnostics.TotalGeoDistanceKm = common.Ptr(totalKm)
+	result.Metrics.Custom = map[string]interface{}{
+		"direct_geo_distance_km": directKm,
+		"total_geo_distance_km":  totalKm,
+	}
+
+	if len(detours) > 0 {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Traffic from %s to %s routes geographically backward via %s",
+			origin.Country, targetGeo.Country, strings.Join(detours, ", ")))
+	}
+
+	if r.MaxExpectedDistanceKm > 0 && totalKm > float64(r.MaxExpectedDistanceKm) {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Route to %s covers %.0f km, exceeding the expected maximum of %d km",
+			ip, totalKm, r.MaxExpectedDistanceKm))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("Geolocation of %s successful:\n"+
+		"- Location: %s, %s\n"+
+		"- Direct distance: %.0f km\n"+
+		"- Route distance: %.0f km over %d hops",
+		ip, targetGeo.City, targetGeo.Country, directKm, totalKm, len(hops)))
+}
+
+// testMulticastMembership verifies required multicast groups are joined and
+// flags any unexpected memberships
+func (r *Runner) testMulticastMembership() common.TestResult {
+	result := common.TestResult{
+		Layer:     3,
+		Name:      "Multicast Group Membership Test",
+		StartTime: time.Now(),
+	}
+
+	memberships, err := checkMulticastGroupMembership(r.MulticastInterface, r.RequiredMulticastGroups)
+	if err != nil {
+		result.Status = common.StatusFailed
+		result.Message = fmt.Sprintf("Multicast membership check failed: %v", err)
+		result.EndTime = time.Now()
+		result.Metrics.Duration = result.EndTime.Sub(result.StartTime)
+		return result
+	}
+
+	required := make(map[string]bool)
+	for _, group := range r.RequiredMulticastGroups {
+		if ip := net.ParseIP(group); ip != nil {
+			required[ip.String()] = true
+		}
+	}
+
+	var missing, unexpected []string
+	for _, m := range memberships {
+		if required[m.Group] {
+			if !m.Joined {
+				missing = append(missing, m.Group)
+			}
+			continue
+		}
+		if m.Joined && !isBaselineMulticastGroup(m.Group) {
+			unexpected = append(unexpected, fmt.Sprintf("%s on %s", m.Group, m.Interface))
+		}
+	}
+
+	result.Diagnostics.Network = &common.NetworkDiagnostics{
+		Interface:   r.MulticastInterface,
+		Required:    r.RequiredMulticastGroups,
+		Memberships: memberships,
+		Missing:     missing,
+		Unexpected:  unexpected,
+	}
+
+	switch {
+	case len(missing) > 0:
+		result.Status = common.StatusFailed
+		result.Message = fmt.Sprintf("Required multicast groups not joined: %s", strings.Join(missing, ", "))
+	case len(unexpected) > 0:
+		result.Status = common.StatusWarning
+		result.Message = fmt.Sprintf("Unexpected multicast groups joined (potential multicast leak): %s",
+			strings.Join(unexpected, ", "))
+	default:
+		result.Status = common.StatusPassed
+		result.Message = fmt.Sprintf("All %d required multicast groups joined", len(r.RequiredMulticastGroups))
+	}
+
+	result.EndTime = time.Now()
+	result.Metrics.Duration = result.EndTime.Sub(result.StartTime)
+	return result
+}
+
 // runPing executes the ping command appropriate for the OS
 func runPing(ip string, count int) (string, error) {
 	var cmd *exec.Cmd
@@ -136,7 +546,8 @@ func runPing(ip string, count int) (string, error) {
 	lines := strings.Split(outputStr, "\n")
 	var relevantLines []string
 	for _, line := range lines {
-		if strings.Contains(line, "time=") || strings.Contains(line, "statistics") {
+		if strings.Contains(line, "time=") || strings.Contains(line, "time<") ||
+			strings.Contains(line, "statistics") || strings.Contains(line, "loss") {
 			relevantLines = append(relevantLines, strings.TrimSpace(line))
 		}
 	}
@@ -0,0 +1,262 @@
+package layer3
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"ghostshell/app/layers/common"
+)
+
+// procNetDir is where the kernel exposes multicast membership tables
+var procNetDir = "/proc/net"
+
+// MulticastResult describes the membership state of a multicast group on an interface
+type MulticastResult = common.MulticastResult
+
+// multicastMembership is a single group joined on an interface
+type multicastMembership struct {
+	iface string
+	group net.IP
+}
+
+// checkMulticastGroupMembership reports whether each of groups is joined on
+// interfaceName (or any interface when empty) and also returns every other group
+// that is currently joined so callers can detect unexpected memberships
+func checkMulticastGroupMembership(interfaceName string, groups []string) ([]MulticastResult, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("multicast membership checks are only supported on linux")
+	}
+
+	var memberships []multicastMembership
+
+	igmp, err := readProcTable("igmp", parseIGMP)
+	if err != nil {
+		return nil, err
+	}
+	memberships = append(memberships, igmp...)
+
+	// IPv6 may be disabled, so a missing igmp6 table is not an error
+	igmp6, err := readProcTable("igmp6", parseIGMP6)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	memberships = append(memberships, igmp6...)
+
+	sources := readMulticastSources()
+
+	var results []MulticastResult
+	matched := make(map[int]bool)
+
+	// Report each required group first
+	for _, group := range groups {
+		groupIP := net.ParseIP(group)
+		if groupIP == nil {
+			return nil, fmt.Errorf("invalid multicast group: %s", group)
+		}
+
+		result := MulticastResult{Group: groupIP.String(), Interface: interfaceName}
+		for i, m := range memberships {
+			if interfaceName != "" && m.iface != interfaceName {
+				continue
+			}
+			if m.group.Equal(groupIP) {
+				result.Joined = true
+				result.Interface = m.iface
+				result.SourceList = sources[sourceKey(m.iface, m.group)]
+				matched[i] = true
+			}
+		}
+		results = append(results, result)
+	}
+
+	// Then every remaining membership on the interface
+	for i, m := range memberships {
+		if matched[i] || (interfaceName != "" && m.iface != interfaceName) {
+			continue
+		}
+		results = append(results, MulticastResult{
+			Group:      m.group.String(),
+			Interface:  m.iface,
+			Joined:     true,
+			SourceList: sources[sourceKey(m.iface, m.group)],
+		})
+	}
+
+	return results, nil
+}
+
+// isBaselineMulticastGroup reports whether the kernel joins group automatically
+func isBaselineMulticastGroup(group string) bool {
+	ip := net.ParseIP(group)
+	if ip == nil {
+		return false
+	}
+
+	// All-hosts groups
+	if ip.Equal(net.IPv4allsys) || ip.Equal(net.IPv6interfacelocalallnodes) || ip.Equal(net.IPv6linklocalallnodes) {
+		return true
+	}
+
+	// IPv6 solicited-node groups (ff02::1:ffxx:xxxx)
+	solicitedNode := net.IPNet{
+		IP:   net.ParseIP("ff02::1:ff00:0"),
+		Mask: net.CIDRMask(104, 128),
+	}
+	return solicitedNode.Contains(ip)
+}
+
+// readProcTable opens a file under procNetDir and parses it with parse
+func readProcTable(name string, parse func(io.Reader) ([]multicastMembership, error)) ([]multicastMembership, error) {
+	f, err := os.Open(filepath.Join(procNetDir, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	memberships, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return memberships, nil
+}
+
+// parseIGMP parses /proc/net/igmp, where each device line is followed by
+// indented lines holding the groups joined on it in host byte order
+func parseIGMP(r io.Reader) ([]multicastMembership, error) {
+	var memberships []multicastMembership
+	var iface string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "Idx") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		// Device lines start at column 0, group lines are indented
+		if line[0] != ' ' && line[0] != '\t' {
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("malformed device line: %q", line)
+			}
+			iface = strings.TrimSuffix(fields[1], ":")
+			continue
+		}
+
+		if iface == "" {
+			return nil, fmt.Errorf("group line before device line: %q", line)
+		}
+
+		raw, err := strconv.ParseUint(fields[0], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group %q: %w", fields[0], err)
+		}
+		group := make(net.IP, net.IPv4len)
+		binary.NativeEndian.PutUint32(group, uint32(raw))
+
+		memberships = append(memberships, multicastMembership{iface: iface, group: group})
+	}
+
+	return memberships, scanner.Err()
+}
+
+// parseIGMP6 parses /proc/net/igmp6, which lists one membership per line
+func parseIGMP6(r io.Reader) ([]multicastMembership, error) {
+	var memberships []multicastMembership
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		group, err := parseHexIPv6(fields[2])
+		if err != nil {
+			return nil, err
+		}
+
+		memberships = append(memberships, multicastMembership{iface: fields[1], group: group})
+	}
+
+	return memberships, scanner.Err()
+}
+
+// readMulticastSources collects source-specific filters from /proc/net/mcfilter
+// and /proc/net/mcfilter6, keyed by interface and group
+func readMulticastSources() map[string][]string {
+	sources := make(map[string][]string)
+
+	if f, err := os.Open(filepath.Join(procNetDir, "mcfilter")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 4 || fields[0] == "Idx" {
+				continue
+			}
+			group, err1 := parseHexIPv4(fields[2])
+			source, err2 := parseHexIPv4(fields[3])
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			key := sourceKey(fields[1], group)
+			sources[key] = append(sources[key], source.String())
+		}
+		f.Close()
+	}
+
+	if f, err := os.Open(filepath.Join(procNetDir, "mcfilter6")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 4 || fields[0] == "Idx" {
+				continue
+			}
+			group, err1 := parseHexIPv6(fields[2])
+			source, err2 := parseHexIPv6(fields[3])
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			key := sourceKey(fields[1], group)
+			sources[key] = append(sources[key], source.String())
+		}
+		f.Close()
+	}
+
+	return sources
+}
+
+// parseHexIPv4 parses a network byte order address such as 0xe00000fb
+func parseHexIPv4(s string) (net.IP, error) {
+	raw, err := strconv.ParseUint(strings.TrimPrefix(s, "0x"), 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IPv4 address %q: %w", s, err)
+	}
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, uint32(raw))
+	return ip, nil
+}
+
+// parseHexIPv6 parses a 32 character hex IPv6 address
+func parseHexIPv6(s string) (net.IP, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != net.IPv6len {
+		return nil, fmt.Errorf("invalid IPv6 address %q", s)
+	}
+	return net.IP(b), nil
+}
+
+// sourceKey builds the lookup key for source filters
+func sourceKey(iface string, group net.IP) string {
+	return iface + "|" + group.String()
+}
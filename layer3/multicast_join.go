@@ -0,0 +1,272 @@
+package layer3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"ghostshell/app/layers/common"
+)
+
+// multicastProbeTimeout bounds the wait for a datagram sent to a joined group
+// to loop back
+const multicastProbeTimeout = 500 * time.Millisecond
+
+// multicastGroupConn is the part of ipv4.PacketConn and ipv6.PacketConn the
+// join test uses
+type multicastGroupConn interface {
+	JoinGroup(ifi *net.Interface, group net.Addr) error
+	LeaveGroup(ifi *net.Interface, group net.Addr) error
+	SetMulticastInterface(ifi *net.Interface) error
+	SetMulticastLoopback(on bool) error
+}
+
+// multicastInterfaces returns the up, multicast capable, non-loopback
+// interfaces, or only MulticastInterface when set
+func (r *Runner) multicastInterfaces() ([]net.Interface, error) {
+	if r.MulticastInterface != "" {
+		iface, err := net.InterfaceByName(r.MulticastInterface)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Interface{*iface}, nil
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var eligible []net.Interface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		eligible = append(eligible, iface)
+	}
+	return eligible, nil
+}
+
+// hasAddressFamily reports whether iface has an address of the same family as ip
+func hasAddressFamily(iface *net.Interface, ip net.IP) bool {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if ok && (ipNet.IP.To4() != nil) == (ip.To4() != nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// kernelHasMembership reports whether the kernel lists group as joined on
+// iface. ok is false when the table cannot be read.
+func kernelHasMembership(iface string, group net.IP) (joined, ok bool) {
+	if runtime.GOOS != "linux" {
+		return false, false
+	}
+	table, parse := "igmp", parseIGMP
+	if group.To4() == nil {
+		table, parse = "igmp6", parseIGMP6
+	}
+	memberships, err := readProcTable(table, parse)
+	if err != nil {
+		return false, false
+	}
+	for _, m := range memberships {
+		if m.iface == iface && m.group.Equal(group) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// testMulticastGroups joins and leaves each of MulticastGroups on every
+// eligible interface, one sub-test per group and interface
+func (r *Runner) testMulticastGroups(ctx context.Context) []common.TestResult {
+	ifaces, ifaceErr := r.multicastInterfaces()
+
+	var results []common.TestResult
+	for _, group := range r.MulticastGroups {
+		groupIP := net.ParseIP(group)
+		if groupIP == nil || !groupIP.IsMulticast() {
+			results = append(results, common.TestResult{
+				Layer:     3,
+				Name:      fmt.Sprintf("Multicast Group Test (%s)", group),
+				Status:    common.StatusFailed,
+				Message:   fmt.Sprintf("%s is not a multicast group address", group),
+				StartTime: time.Now(),
+				EndTime:   time.Now(),
+			})
+			continue
+		}
+
+		if ifaceErr != nil || len(ifaces) == 0 {
+			msg := "no up multicast capable interfaces"
+			if ifaceErr != nil {
+				msg = fmt.Sprintf("failed to list interfaces: %v", ifaceErr)
+			}
+			results = append(results, common.TestResult{
+				Layer:     3,
+				Name:      fmt.Sprintf("Multicast Group Test (%s)", group),
+				Status:    common.StatusSkipped,
+				Message:   fmt.Sprintf("Multicast group test for %s skipped: %s", group, msg),
+				StartTime: time.Now(),
+				EndTime:   time.Now(),
+			})
+			continue
+		}
+
+		for i := range ifaces {
+			if ctx.Err() != nil {
+				return results
+			}
+			results = append(results, r.testMulticastGroup(groupIP, &ifaces[i]))
+		}
+	}
+	return results
+}
+
+// testMulticastGroup joins group on iface, checks the kernel recorded the
+// membership, optionally sends a datagram to the group and leaves again
+func (r *Runner) testMulticastGroup(group net.IP, iface *net.Interface) common.TestResult {
+	result := common.TestResult{
+		Layer:     3,
+		Name:      fmt.Sprintf("Multicast Group Test (%s@%s)", group, iface.Name),
+		StartTime: time.Now(),
+	}
+
+	info := &common.MulticastJoinInfo{Group: group.String(), Interface: iface.Name}
+	diagnostics := &common.NetworkDiagnostics{Interface: iface.Name, MulticastJoin: info}
+	result.Diagnostics.Network = diagnostics
+
+	fail := func(status common.TestStatus, msg string, err error) common.TestResult {
+		diagnostics.Error = err.Error()
+		return result.Finish(status, fmt.Sprintf("%s: %v", msg, err))
+	}
+
+	family, table := "IPv4", "igmp"
+	if group.To4() == nil {
+		family, table = "IPv6", "igmp6"
+	}
+	if !hasAddressFamily(iface, group) {
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("Interface %s has no %s address to join %s with",
+			iface.Name, family, group))
+	}
+
+	// Bind to the wildcard address so datagrams sent to the group reach the
+	// socket once it has joined
+	var conn net.PacketConn
+	var mc multicastGroupConn
+	var err error
+	if family == "IPv4" {
+		conn, err = net.ListenPacket("udp4", "0.0.0.0:0")
+		if err == nil {
+			mc = ipv4.NewPacketConn(conn)
+		}
+	} else {
+		conn, err = net.ListenPacket("udp6", "[::]:0")
+		if err == nil {
+			mc = ipv6.NewPacketConn(conn)
+		}
+	}
+	if err != nil {
+		return fail(common.StatusFailed, fmt.Sprintf("Failed to open an %s socket for %s", family, group), err)
+	}
+	defer conn.Close()
+
+	info.PreviouslyJoined, _ = kernelHasMembership(iface.Name, group)
+
+	groupAddr := &net.UDPAddr{IP: group}
+	joinStart := time.Now()
+	if err := mc.JoinGroup(iface, groupAddr); err != nil {
+		return fail(common.StatusFailed, fmt.Sprintf("Failed to join %s on %s although the interface is up", group, iface.Name), err)
+	}
+	joinLatency := time.Since(joinStart)
+	info.JoinLatencyMs = float64(joinLatency.Microseconds()) / 1000
+	result.Metrics.Latency = joinLatency
+	result.Metrics.Custom = map[string]interface{}{"join_latency_ms": info.JoinLatencyMs}
+
+	var warnings []string
+	inTable, checked := kernelHasMembership(iface.Name, group)
+	if checked {
+		info.InKernelTable = &inTable
+		result.Metrics.Custom["in_kernel_table"] = inTable
+	}
+
+	if r.MulticastSend {
+		if err := sendMulticastProbe(conn, mc, group, iface, info); err != nil {
+			warnings = append(warnings, err.Error())
+		}
+	}
+
+	if err := mc.LeaveGroup(iface, groupAddr); err != nil {
+		warnings = append(warnings, fmt.Sprintf("leaving the group failed: %v", err))
+	} else {
+		info.Left = true
+	}
+
+	if checked && !inTable {
+		diagnostics.Error = "membership missing from kernel table"
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Joined %s on %s but the kernel does not list the membership in %s/%s",
+			group, iface.Name, procNetDir, table))
+	}
+
+	msg := fmt.Sprintf("Joined %s on %s in %.2f ms", group, iface.Name, info.JoinLatencyMs)
+	if checked {
+		msg += ", membership confirmed by the kernel"
+	}
+	if info.ProbeLooped {
+		msg += ", probe datagram looped back"
+	}
+	if len(warnings) > 0 {
+		diagnostics.Error = warnings[0]
+		return result.Finish(common.StatusWarning, fmt.Sprintf("%s, but %s", msg, warnings[0]))
+	}
+	return result.Finish(common.StatusPassed, msg+", left the group")
+}
+
+// sendMulticastProbe sends a datagram to group out of iface and waits for the
+// kernel to loop it back to the joined socket
+func sendMulticastProbe(conn net.PacketConn, mc multicastGroupConn, group net.IP, iface *net.Interface, info *common.MulticastJoinInfo) error {
+	if err := mc.SetMulticastInterface(iface); err != nil {
+		return fmt.Errorf("selecting %s for the probe failed: %v", iface.Name, err)
+	}
+	if err := mc.SetMulticastLoopback(true); err != nil {
+		return fmt.Errorf("enabling multicast loopback failed: %v", err)
+	}
+
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	dst := &net.UDPAddr{IP: group, Port: port}
+	if group.IsLinkLocalMulticast() || group.IsInterfaceLocalMulticast() {
+		dst.Zone = iface.Name
+	}
+
+	payload := []byte(fmt.Sprintf("layers-multicast-probe %d %d", os.Getpid(), time.Now().UnixNano()))
+	if _, err := conn.WriteTo(payload, dst); err != nil {
+		return fmt.Errorf("sending the probe datagram failed: %v", err)
+	}
+	info.ProbeSent = true
+
+	conn.SetReadDeadline(time.Now().Add(multicastProbeTimeout))
+	buf := make([]byte, 512)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return fmt.Errorf("the probe datagram was not looped back within %v", multicastProbeTimeout)
+		}
+		if bytes.Equal(buf[:n], payload) {
+			info.ProbeLooped = true
+			return nil
+		}
+	}
+}
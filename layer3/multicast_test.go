@@ -0,0 +1,147 @@
+package layer3
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"ghostshell/app/layers/common"
+)
+
+// igmpGroup formats group as /proc/net/igmp does, in host byte order
+func igmpGroup(group string) string {
+	return fmt.Sprintf("%08X", binary.NativeEndian.Uint32(net.ParseIP(group).To4()))
+}
+
+// igmpFixture builds a /proc/net/igmp table from the groups joined on each device
+func igmpFixture(devices []string, groups map[string][]string) string {
+	var b strings.Builder
+	b.WriteString("Idx\tDevice    : Count Querier\tGroup    Users Timer\tReporter\n")
+	for i, dev := range devices {
+		fmt.Fprintf(&b, "%d\t%-10s:     %d      V3\n", i+1, dev, len(groups[dev]))
+		for _, group := range groups[dev] {
+			fmt.Fprintf(&b, "\t\t\t\t%s     1 0:00000000\t\t0\n", igmpGroup(group))
+		}
+	}
+	return b.String()
+}
+
+const igmp6Fixture = `1    lo              ff020000000000000000000000000001     1 0000000C 0
+2    eth0            ff0200000000000000000001ff5a3c21     1 00000004 0
+2    eth0            ff020000000000000000000000000001     1 0000000C 0
+`
+
+func TestParseIGMP(t *testing.T) {
+	fixture := igmpFixture([]string{"lo", "eth0"}, map[string][]string{
+		"lo":   {"224.0.0.1"},
+		"eth0": {"224.0.0.251", "224.0.0.1"},
+	})
+	memberships, err := parseIGMP(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatalf("parseIGMP: %v", err)
+	}
+
+	want := []multicastMembership{
+		{"lo", net.ParseIP("224.0.0.1").To4()},
+		{"eth0", net.ParseIP("224.0.0.251").To4()},
+		{"eth0", net.ParseIP("224.0.0.1").To4()},
+	}
+	if !reflect.DeepEqual(memberships, want) {
+		t.Errorf("parsed %v, want %v", memberships, want)
+	}
+
+	if _, err := parseIGMP(strings.NewReader("\t\t\t\tE00000FB     1 0:00000000\t\t0\n")); err == nil {
+		t.Error("no error for a group line before any device line")
+	}
+}
+
+func TestParseIGMP6(t *testing.T) {
+	memberships, err := parseIGMP6(strings.NewReader(igmp6Fixture))
+	if err != nil {
+		t.Fatalf("parseIGMP6: %v", err)
+	}
+	if len(memberships) != 3 || memberships[1].iface != "eth0" || memberships[1].group.String() != "ff02::1:ff5a:3c21" {
+		t.Errorf("parsed %v", memberships)
+	}
+}
+
+func TestMulticastMembership(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("multicast membership checks read /proc/net")
+	}
+
+	tests := []struct {
+		name           string
+		groups         map[string][]string
+		required       []string
+		want           common.TestStatus
+		wantMissing    []string
+		wantUnexpected []string
+	}{
+		{
+			name:     "required groups joined",
+			groups:   map[string][]string{"eth0": {"224.0.0.1", "239.1.1.1"}},
+			required: []string{"239.1.1.1"},
+			want:     common.StatusPassed,
+		},
+		{
+			name:        "required group missing",
+			groups:      map[string][]string{"eth0": {"224.0.0.1"}},
+			required:    []string{"239.1.1.1"},
+			want:        common.StatusFailed,
+			wantMissing: []string{"239.1.1.1"},
+		},
+		{
+			name:           "unexpected group joined",
+			groups:         map[string][]string{"eth0": {"224.0.0.1", "239.1.1.1", "239.255.255.250"}},
+			required:       []string{"239.1.1.1"},
+			want:           common.StatusWarning,
+			wantUnexpected: []string{"239.255.255.250 on eth0"},
+		},
+		{
+			name:        "required group joined on another interface",
+			groups:      map[string][]string{"eth1": {"239.1.1.1"}},
+			required:    []string{"239.1.1.1"},
+			want:        common.StatusFailed,
+			wantMissing: []string{"239.1.1.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fixture := igmpFixture([]string{"lo", "eth0", "eth1"}, tt.groups)
+			if err := os.WriteFile(filepath.Join(dir, "igmp"), []byte(fixture), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "igmp6"), []byte(igmp6Fixture), 0644); err != nil {
+				t.Fatal(err)
+			}
+			saved := procNetDir
+			procNetDir = dir
+			defer func() { procNetDir = saved }()
+
+			r := New("", "", 1)
+			r.MulticastInterface = "eth0"
+			r.RequiredMulticastGroups = tt.required
+
+			result := r.testMulticastMembership()
+			if result.Status != tt.want {
+				t.Fatalf("status %s, want %s: %s", result.Status, tt.want, result.Message)
+			}
+			diagnostics := result.Diagnostics.Network
+			if !reflect.DeepEqual(diagnostics.Missing, tt.wantMissing) {
+				t.Errorf("missing %v, want %v", diagnostics.Missing, tt.wantMissing)
+			}
+			// The all-hosts and solicited-node groups are joined by the kernel and never unexpected
+			if !reflect.DeepEqual(diagnostics.Unexpected, tt.wantUnexpected) {
+				t.Errorf("unexpected %v, want %v", diagnostics.Unexpected, tt.wantUnexpected)
+			}
+		})
+	}
+}
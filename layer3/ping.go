@@ -0,0 +1,113 @@
+package layer3
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/common"
+)
+
+// Patterns for the per-reply time and packet loss summary of the ping binary,
+// covering "time=12.3 ms", "time<1ms", "0% packet loss" and "(0% loss)"
+var (
+	pingTimePattern = regexp.MustCompile(`time[=<]\s*([\d.]+)\s*ms`)
+	pingLossPattern = regexp.MustCompile(`([\d.]+)%\s*(?:packet\s+)?loss`)
+)
+
+// testPing pings PingAddr with native ICMP echo requests, falling back to
+// the system ping binary when no ICMP socket can be opened or UseRawICMP is off
+func (r *Runner) testPing(ctx context.Context, logger *zap.Logger) common.TestResult {
+	result := common.TestResult{
+		Layer:     3,
+		Name:      fmt.Sprintf("Ping Test (%s)", r.PingAddr),
+		StartTime: time.Now(),
+	}
+
+	method := "ping_binary"
+	var output string
+	var rtts []time.Duration
+	var loss float64
+	var err error
+	if r.UseRawICMP {
+		method = "raw_icmp"
+		rtts, loss, err = RawICMPPing(ctx, r.PingAddr, r.PingCount, time.Second)
+		if err != nil && len(rtts) == 0 {
+			logger.Warn("Native ICMP ping unavailable, falling back to ping binary", zap.Error(err))
+			method = "ping_binary"
+		}
+	}
+	if method == "ping_binary" {
+		output, err = runPing(r.PingAddr, r.PingCount)
+		if err != nil {
+			result.Diagnostics.Network = &common.NetworkDiagnostics{
+				Method: method,
+				Target: r.PingAddr,
+				Error:  err.Error(),
+			}
+			return result.Finish(common.StatusFailed, fmt.Sprintf("Ping test failed: %v\nOutput: %s", err, output))
+		}
+		rtts, loss = parsePingOutput(output, r.PingCount)
+	}
+
+	stats := computeRTTStats(rtts)
+	result.Metrics.PacketLoss = loss
+	result.Metrics.Latency = stats.Avg
+	result.Metrics.Jitter = stats.Jitter
+	result.Metrics.Custom = map[string]interface{}{
+		"rtt_min_ms":    float64(stats.Min.Microseconds()) / 1000,
+		"rtt_max_ms":    float64(stats.Max.Microseconds()) / 1000,
+		"rtt_stddev_ms": float64(stats.StdDev.Microseconds()) / 1000,
+		"rtt_p50_ms":    float64(percentile(rtts, 50).Microseconds()) / 1000,
+		"rtt_p95_ms":    float64(percentile(rtts, 95).Microseconds()) / 1000,
+		"rtt_p99_ms":    float64(percentile(rtts, 99).Microseconds()) / 1000,
+	}
+	diagnostics := &common.NetworkDiagnostics{
+		Method:     method,
+		Target:     r.PingAddr,
+		Sent:       common.Ptr(r.PingCount),
+		Received:   common.Ptr(len(rtts)),
+		RTTsMs:     durationsToMs(rtts),
+		PacketLoss: common.Ptr(loss),
+	}
+	if err != nil {
+		diagnostics.Error = err.Error()
+	}
+	result.Diagnostics.Network = diagnostics
+
+	if len(rtts) == 0 {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Ping test failed: no replies from %s (%d probes sent)",
+			r.PingAddr, r.PingCount))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("Ping test successful (%s):\n"+
+		"- %d/%d replies received (%.1f%% loss)\n"+
+		"- RTT min/avg/max/stddev: %s/%s/%s/%s\n"+
+		"- Jitter: %s",
+		method, len(rtts), r.PingCount, loss,
+		stats.Min, stats.Avg, stats.Max, stats.StdDev, stats.Jitter))
+}
+
+// parsePingOutput extracts the reply RTTs and packet loss from ping output.
+// When no loss summary is present it is derived from the replies counted.
+func parsePingOutput(output string, count int) ([]time.Duration, float64) {
+	var rtts []time.Duration
+	for _, m := range pingTimePattern.FindAllStringSubmatch(output, -1) {
+		ms, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			continue
+		}
+		rtts = append(rtts, time.Duration(ms*float64(time.Millisecond)))
+	}
+
+	if m := pingLossPattern.FindStringSubmatch(output); m != nil {
+		if loss, err := strconv.ParseFloat(m[1], 64); err == nil {
+			return rtts, loss
+		}
+	}
+	return rtts, lossPct(count, len(rtts))
+}
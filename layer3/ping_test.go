@@ -0,0 +1,25 @@
+package layer3
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestPingUseRawICMP(t *testing.T) {
+	if r := New("", "127.0.0.1", 1); !r.UseRawICMP {
+		t.Error("New() disables native ICMP, want it on by default")
+	}
+
+	// Turning it off forces the ping binary, whether or not it is installed
+	r := New("", "127.0.0.1", 1)
+	r.UseRawICMP = false
+	result := r.testPing(context.Background(), zap.NewNop())
+	if result.Diagnostics.Network == nil {
+		t.Fatalf("no network diagnostics: %s", result.Message)
+	}
+	if got := result.Diagnostics.Network.Method; got != "ping_binary" {
+		t.Errorf("method = %q with UseRawICMP off, want ping_binary", got)
+	}
+}
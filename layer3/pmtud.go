@@ -0,0 +1,138 @@
+package layer3
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// Path MTU discovery settings
+const (
+	pmtudMaxPayload   = 1472 // 1500 byte MTU minus IPv4 and ICMP headers
+	pmtudHeaderBytes  = ipv4.HeaderLen + 8
+	pmtudMaxProbes    = 12
+	pmtudProbeTimeout = time.Second
+)
+
+// errPMTUDUnavailable is returned when DF-marked raw ICMP probes can't be sent
+var errPMTUDUnavailable = errors.New("path MTU discovery requires raw socket access")
+
+// RunPMTUD discovers the path MTU to target by binary searching the largest
+// ICMP echo that is answered with the Don't Fragment bit set. Probes that
+// time out, are rejected locally or draw a Fragmentation Needed reply are
+// treated as too large.
+func (r *Runner) RunPMTUD(ctx context.Context, target string) (int, error) {
+	dst, err := net.ResolveIPAddr("ip4", target)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve %s: %w", target, err)
+	}
+
+	conn, err := net.ListenIP("ip4:icmp", &net.IPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", errPMTUDUnavailable, err)
+	}
+	defer conn.Close()
+
+	if err := setDontFragment(conn); err != nil {
+		return 0, fmt.Errorf("%w: %v", errPMTUDUnavailable, err)
+	}
+
+	id := os.Getpid() & 0xffff
+	buf := make([]byte, 1500)
+
+	// lo is the largest payload known to fit, hi the smallest known not to
+	lo, hi := 0, pmtudMaxPayload+1
+	verified := false
+	size := pmtudMaxPayload
+
+	for probe := 1; probe <= pmtudMaxProbes && hi-lo > 1; probe++ {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		fits, hint, err := sendPMTUProbe(ctx, conn, dst, id, probe, size, buf)
+		if err != nil {
+			return 0, err
+		}
+
+		if fits {
+			lo, verified = size, true
+		} else {
+			hi = size
+		}
+
+		// Prefer the next-hop MTU reported by the router when it is in range
+		size = lo + (hi-lo)/2
+		if hintSize := hint - pmtudHeaderBytes; hint > 0 && hintSize > lo && hintSize < hi {
+			size = hintSize
+		}
+	}
+
+	if !verified {
+		return 0, fmt.Errorf("no echo replies received from %s", target)
+	}
+	return lo + pmtudHeaderBytes, nil
+}
+
+// sendPMTUProbe sends one echo request with size bytes of payload and reports
+// whether it was answered, along with any next-hop MTU from a Fragmentation
+// Needed reply
+func sendPMTUProbe(ctx context.Context, conn *net.IPConn, dst *net.IPAddr, id, seq, size int, buf []byte) (bool, int, error) {
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: make([]byte, size)},
+	}
+	wb, err := msg.Marshal(nil)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to marshal ICMP message: %w", err)
+	}
+
+	if _, err := conn.WriteTo(wb, dst); err != nil {
+		// The kernel rejects DF packets larger than the interface or cached path MTU
+		if errors.Is(err, syscall.EMSGSIZE) {
+			return false, 0, nil
+		}
+		return false, 0, fmt.Errorf("failed to send probe: %w", err)
+	}
+
+	deadline := time.Now().Add(pmtudProbeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return false, 0, nil
+		}
+
+		reply, err := icmp.ParseMessage(protocolICMP, buf[:n])
+		if err != nil {
+			continue
+		}
+
+		switch body := reply.Body.(type) {
+		case *icmp.Echo:
+			if reply.Type == ipv4.ICMPTypeEchoReply && body.ID == id && body.Seq == seq {
+				return true, 0, nil
+			}
+		case *icmp.DstUnreach:
+			// Code 4 is Fragmentation Needed; the next-hop MTU is in bytes 6-7 of the header
+			if reply.Code != 4 {
+				continue
+			}
+			if probeID, probeSeq, ok := quotedEcho(body.Data); ok && probeID == id && probeSeq == seq {
+				return false, int(binary.BigEndian.Uint16(buf[6:8])), nil
+			}
+		}
+	}
+}
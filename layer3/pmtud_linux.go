@@ -0,0 +1,22 @@
+package layer3
+
+import (
+	"net"
+	"syscall"
+)
+
+// setDontFragment sets the DF bit on every packet sent on conn
+func setDontFragment(conn *net.IPConn) error {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	if err := rc.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_DO)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package layer3
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// setDontFragment is only implemented on linux
+func setDontFragment(conn *net.IPConn) error {
+	return fmt.Errorf("setting the DF bit is not supported on %s", runtime.GOOS)
+}
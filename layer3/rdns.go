@@ -0,0 +1,83 @@
+package layer3
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// testReverseDNS checks that ip, one of the addresses Hostname resolved to,
+// has a PTR record whose name resolves back to one of forwardAddrs
+// (forward-confirmed reverse DNS)
+func (r *Runner) testReverseDNS(ctx context.Context, ip string, forwardAddrs []string) common.TestResult {
+	result := common.TestResult{
+		Layer:     3,
+		Name:      fmt.Sprintf("FCrDNS Validation (%s)", ip),
+		StartTime: time.Now(),
+	}
+
+	valid := false
+	diagnostics := &common.NetworkDiagnostics{
+		Target:      r.Hostname,
+		IP:          ip,
+		FCrDNSValid: &valid,
+	}
+	result.Diagnostics.Network = diagnostics
+
+	names, err := net.DefaultResolver.LookupAddr(ctx, ip)
+	if err != nil || len(names) == 0 {
+		if err != nil {
+			diagnostics.Error = err.Error()
+		}
+		return result.Finish(common.StatusWarning, fmt.Sprintf("No reverse DNS (PTR) record for %s", ip))
+	}
+	diagnostics.PTRRecord = strings.TrimSuffix(names[0], ".")
+
+	var reverseAddrs []string
+	for _, name := range names {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, name)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if containsIP(forwardAddrs, addr) {
+				valid = true
+				diagnostics.PTRRecord = strings.TrimSuffix(name, ".")
+				return result.Finish(common.StatusPassed, fmt.Sprintf("FCrDNS valid for %s: PTR %s resolves back to %s",
+					ip, diagnostics.PTRRecord, addr))
+			}
+		}
+		reverseAddrs = append(reverseAddrs, addrs...)
+	}
+
+	if len(reverseAddrs) == 0 {
+		diagnostics.Error = "PTR hostname does not resolve"
+		return result.Finish(common.StatusWarning, fmt.Sprintf("PTR record %s of %s does not resolve to any address",
+			diagnostics.PTRRecord, ip))
+	}
+
+	// The PTR name pointing somewhere else entirely can indicate DNS hijacking
+	diagnostics.Error = "PTR hostname resolves to different addresses"
+	return result.Finish(common.StatusFailed, fmt.Sprintf("FCrDNS failed for %s: PTR %s resolves to %v, none of which %s resolves to (%v)",
+		ip, diagnostics.PTRRecord, reverseAddrs, r.Hostname, forwardAddrs))
+}
+
+// containsIP reports whether addrs contains ip, comparing parsed addresses
+// so that different spellings of the same IPv6 address match
+func containsIP(addrs []string, ip string) bool {
+	parsed := net.ParseIP(ip)
+	for _, addr := range addrs {
+		if a := net.ParseIP(addr); a != nil && parsed != nil {
+			if a.Equal(parsed) {
+				return true
+			}
+		} else if addr == ip {
+			return true
+		}
+	}
+	return false
+}
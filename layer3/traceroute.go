@@ -0,0 +1,257 @@
+package layer3
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+
+	"ghostshell/app/layers/common"
+)
+
+// Traceroute settings
+const (
+	defaultMaxHops       = 30
+	tracerouteProbes     = 3
+	tracerouteProbeWait  = time.Second
+	tracerouteProbeBytes = "GhostSuite-Traceroute-Probe"
+)
+
+// tracerouteMinProbeWait is the shortest per-probe wait worth sending a probe for
+const tracerouteMinProbeWait = 50 * time.Millisecond
+
+// tracerouteProbeTimeout spreads the time left before ctx's deadline across
+// every probe of a maxHops trace, capped at tracerouteProbeWait, so an
+// unresponsive path can't outlast the layer timeout
+func tracerouteProbeTimeout(ctx context.Context, maxHops int) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return tracerouteProbeWait
+	}
+	wait := time.Until(deadline) / time.Duration(maxHops*tracerouteProbes)
+	if wait > tracerouteProbeWait {
+		return tracerouteProbeWait
+	}
+	if wait < tracerouteMinProbeWait {
+		return tracerouteMinProbeWait
+	}
+	return wait
+}
+
+// HopResult is a single hop on the path to a traceroute target
+type HopResult = common.HopResult
+
+// RunTraceroute discovers the routers between this host and target. Windows
+// uses the tracert command; other platforms send ICMP echo requests with
+// increasing TTLs over a raw socket and collect the Time Exceeded replies.
+func (r *Runner) RunTraceroute(ctx context.Context, target string, maxHops int) ([]HopResult, error) {
+	if maxHops <= 0 {
+		maxHops = defaultMaxHops
+	}
+
+	probeWait := tracerouteProbeTimeout(ctx, maxHops)
+
+	var hops []HopResult
+	var err error
+	if runtime.GOOS == "windows" {
+		hops, err = runTracert(ctx, target, maxHops, probeWait)
+	} else {
+		hops, err = icmpTraceroute(ctx, target, maxHops, probeWait)
+	}
+
+	// Reverse DNS is best effort and bounded by the caller's context
+	for i := range hops {
+		if hops[i].IP == "" {
+			continue
+		}
+		if names, err := net.DefaultResolver.LookupAddr(ctx, hops[i].IP); err == nil && len(names) > 0 {
+			hops[i].Hostname = strings.TrimSuffix(names[0], ".")
+		}
+	}
+
+	return hops, err
+}
+
+// icmpTraceroute sends tracerouteProbes echo requests per TTL over a raw ICMP
+// socket, waiting up to probeWait for each reply
+func icmpTraceroute(ctx context.Context, target string, maxHops int, probeWait time.Duration) ([]HopResult, error) {
+	dst, err := net.ResolveIPAddr("ip4", target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", target, err)
+	}
+
+	// Unprivileged ICMP sockets don't deliver Time Exceeded messages
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return nil, fmt.Errorf("traceroute requires a raw ICMP socket: %w", err)
+	}
+	defer conn.Close()
+
+	pconn := conn.IPv4PacketConn()
+	id := os.Getpid() & 0xffff
+	buf := make([]byte, 1500)
+	var hops []HopResult
+
+	for ttl := 1; ttl <= maxHops; ttl++ {
+		if err := pconn.SetTTL(ttl); err != nil {
+			return hops, fmt.Errorf("failed to set TTL: %w", err)
+		}
+
+		hop := HopResult{Index: ttl, RTTs: make([]time.Duration, tracerouteProbes)}
+
+		for probe := 0; probe < tracerouteProbes; probe++ {
+			if err := ctx.Err(); err != nil {
+				return append(hops, hop), err
+			}
+
+			seq := ttl*tracerouteProbes + probe
+			msg := icmp.Message{
+				Type: ipv4.ICMPTypeEcho,
+				Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte(tracerouteProbeBytes)},
+			}
+			wb, err := msg.Marshal(nil)
+			if err != nil {
+				return hops, fmt.Errorf("failed to marshal ICMP message: %w", err)
+			}
+
+			start := time.Now()
+			if _, err := conn.WriteTo(wb, dst); err != nil {
+				return hops, fmt.Errorf("failed to send probe: %w", err)
+			}
+
+			readDeadline := start.Add(probeWait)
+			if deadline, ok := ctx.Deadline(); ok && deadline.Before(readDeadline) {
+				readDeadline = deadline
+			}
+			conn.SetReadDeadline(readDeadline)
+			for {
+				n, peer, err := conn.ReadFrom(buf)
+				if err != nil {
+					break
+				}
+				rtt := time.Since(start)
+
+				reply, err := icmp.ParseMessage(protocolICMP, buf[:n])
+				if err != nil {
+					continue
+				}
+
+				matched := false
+				switch body := reply.Body.(type) {
+				case *icmp.Echo:
+					if reply.Type == ipv4.ICMPTypeEchoReply && body.ID == id && body.Seq == seq {
+						matched, hop.Reached = true, true
+					}
+				case *icmp.TimeExceeded:
+					if probeID, probeSeq, ok := quotedEcho(body.Data); ok && probeID == id && probeSeq == seq {
+						matched = true
+					}
+				}
+				if matched {
+					hop.IP = peer.String()
+					hop.RTTs[probe] = rtt
+					break
+				}
+			}
+		}
+
+		hops = append(hops, hop)
+		if hop.Reached {
+			break
+		}
+	}
+
+	return hops, nil
+}
+
+// quotedEcho extracts the echo ID and sequence from the original datagram
+// quoted in an ICMP error message
+func quotedEcho(data []byte) (int, int, bool) {
+	if len(data) < ipv4.HeaderLen {
+		return 0, 0, false
+	}
+	headerLen := int(data[0]&0x0f) * 4
+	if len(data) < headerLen+8 {
+		return 0, 0, false
+	}
+	echo := data[headerLen:]
+	return int(binary.BigEndian.Uint16(echo[4:6])), int(binary.BigEndian.Uint16(echo[6:8])), true
+}
+
+// tracertHopLine matches a hop line such as "  3    12 ms    <1 ms     *     10.0.0.1"
+var tracertHopLine = regexp.MustCompile(`^\s*(\d+)\s+(\S+(?: ms)?)\s+(\S+(?: ms)?)\s+(\S+(?: ms)?)\s+(.*)$`)
+
+// runTracert runs the Windows tracert command and parses its output
+func runTracert(ctx context.Context, target string, maxHops int, probeWait time.Duration) ([]HopResult, error) {
+	cmd := exec.CommandContext(ctx, "tracert", "-d", "-h", strconv.Itoa(maxHops),
+		"-w", strconv.Itoa(int(probeWait.Milliseconds())), target)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("tracert failed: %w", err)
+	}
+
+	hops := parseTracertOutput(string(output))
+	if len(hops) == 0 {
+		return nil, fmt.Errorf("no hops found in tracert output")
+	}
+
+	if dst, err := net.ResolveIPAddr("ip4", target); err == nil {
+		last := &hops[len(hops)-1]
+		last.Reached = last.IP == dst.IP.String()
+	}
+	return hops, nil
+}
+
+// parseTracertOutput extracts hops from tracert output
+func parseTracertOutput(output string) []HopResult {
+	var hops []HopResult
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		m := tracertHopLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		index, _ := strconv.Atoi(m[1])
+		hop := HopResult{Index: index}
+		for _, field := range m[2:5] {
+			hop.RTTs = append(hop.RTTs, parseTracertRTT(field))
+		}
+
+		if ip := net.ParseIP(strings.Trim(strings.TrimSpace(m[5]), "[]")); ip != nil {
+			hop.IP = ip.String()
+		}
+		hops = append(hops, hop)
+	}
+
+	return hops
+}
+
+// parseTracertRTT converts "12 ms" or "<1 ms" to a duration; "*" becomes 0
+func parseTracertRTT(field string) time.Duration {
+	field = strings.TrimSpace(strings.TrimSuffix(field, " ms"))
+	if field == "*" {
+		return 0
+	}
+	if strings.HasPrefix(field, "<") {
+		// Sub-millisecond replies are reported as "<1 ms"
+		return 500 * time.Microsecond
+	}
+	ms, err := strconv.Atoi(field)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
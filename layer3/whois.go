@@ -0,0 +1,228 @@
+package layer3
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// WHOIS lookup settings
+const (
+	whoisMaxReferrals = 3
+	whoisCacheTTL     = 24 * time.Hour
+	whoisQueryTimeout = 10 * time.Second
+)
+
+// whoisRootServer is where every lookup starts, and whoisCacheDir holds cached results
+var (
+	whoisRootServer = "whois.iana.org:43"
+	whoisCacheDir   = common.CacheDir
+)
+
+// WHOISInfo holds the ownership details of an IP address
+type WHOISInfo = common.WHOISInfo
+
+// whoisCacheEntry is the on-disk form of a cached WHOIS lookup
+type whoisCacheEntry struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Info      WHOISInfo `json:"info"`
+}
+
+// whoisFields maps WHOIS keys from the different registries onto WHOISInfo fields
+var whoisFields = map[string]func(*WHOISInfo) *string{
+	"netname":       func(w *WHOISInfo) *string { return &w.NetName },
+	"orgname":       func(w *WHOISInfo) *string { return &w.OrgName },
+	"org-name":      func(w *WHOISInfo) *string { return &w.OrgName },
+	"owner":         func(w *WHOISInfo) *string { return &w.OrgName },
+	"descr":         func(w *WHOISInfo) *string { return &w.OrgName },
+	"country":       func(w *WHOISInfo) *string { return &w.Country },
+	"cidr":          func(w *WHOISInfo) *string { return &w.CIDR },
+	"inetnum":       func(w *WHOISInfo) *string { return &w.CIDR },
+	"inet6num":      func(w *WHOISInfo) *string { return &w.CIDR },
+	"nethandle":     func(w *WHOISInfo) *string { return &w.ARINHandle },
+	"orgabuseemail": func(w *WHOISInfo) *string { return &w.AbuseEmail },
+	"abuse-mailbox": func(w *WHOISInfo) *string { return &w.AbuseEmail },
+}
+
+// QueryWHOIS looks up the owner of ip, starting at IANA and following registry
+// referrals. Results are cached on disk for 24 hours.
+func QueryWHOIS(ctx context.Context, ip string) (WHOISInfo, error) {
+	if net.ParseIP(ip) == nil {
+		return WHOISInfo{}, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	if info, ok := loadWHOISCache(ip); ok {
+		return info, nil
+	}
+
+	server := whoisRootServer
+	var response string
+	for i := 0; i <= whoisMaxReferrals; i++ {
+		query := ip
+		// ARIN returns every matching record type unless asked for networks only
+		if strings.HasPrefix(server, "whois.arin.net") {
+			query = "n + " + ip
+		}
+
+		var err error
+		response, err = queryWHOISServer(ctx, server, query)
+		if err != nil {
+			return WHOISInfo{}, err
+		}
+
+		refer := parseWHOISReferral(response)
+		if refer == "" || refer == server {
+			break
+		}
+		server = refer
+	}
+
+	info := parseWHOISResponse(response)
+	info.Server = strings.TrimSuffix(server, ":43")
+
+	if info.NetName == "" && info.OrgName == "" {
+		return info, fmt.Errorf("no ownership information found for %s", ip)
+	}
+
+	saveWHOISCache(ip, info)
+	return info, nil
+}
+
+// queryWHOISServer sends a single query and returns the full response
+func queryWHOISServer(ctx context.Context, server, query string) (string, error) {
+	dialer := &net.Dialer{Timeout: whoisQueryTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to WHOIS server %s: %w", server, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(whoisQueryTimeout)); err != nil {
+		return "", fmt.Errorf("failed to set deadline: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(conn, "%s\r\n", query); err != nil {
+		return "", fmt.Errorf("failed to send WHOIS query: %w", err)
+	}
+
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to read WHOIS response from %s: %w", server, err)
+	}
+	return string(data), nil
+}
+
+// parseWHOISReferral returns the server named in a refer or ReferralServer line
+func parseWHOISReferral(response string) string {
+	scanner := bufio.NewScanner(strings.NewReader(response))
+	for scanner.Scan() {
+		key, value, ok := splitWHOISLine(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "refer", "whois":
+			return withWHOISPort(value)
+		case "referralserver":
+			// ARIN style: whois://whois.ripe.net
+			value = strings.TrimPrefix(value, "whois://")
+			value = strings.TrimPrefix(value, "rwhois://")
+			return withWHOISPort(value)
+		}
+	}
+	return ""
+}
+
+// parseWHOISResponse extracts the first value of each known field
+func parseWHOISResponse(response string) WHOISInfo {
+	var info WHOISInfo
+
+	scanner := bufio.NewScanner(strings.NewReader(response))
+	for scanner.Scan() {
+		key, value, ok := splitWHOISLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		if field, known := whoisFields[key]; known {
+			if target := field(&info); *target == "" {
+				*target = value
+			}
+		}
+	}
+
+	return info
+}
+
+// splitWHOISLine splits a "Key: value" line, skipping comments and blanks
+func splitWHOISLine(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "%") || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+
+	key, value, found := strings.Cut(line, ":")
+	if !found {
+		return "", "", false
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", "", false
+	}
+	return strings.ToLower(strings.TrimSpace(key)), value, true
+}
+
+// withWHOISPort appends the default WHOIS port if none is present
+func withWHOISPort(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(server, "43")
+}
+
+// whoisCachePath returns the cache file for ip
+func whoisCachePath(ip string) string {
+	name := strings.NewReplacer(":", "_", ".", "_").Replace(ip)
+	return filepath.Join(whoisCacheDir, fmt.Sprintf("whois_%s.json", name))
+}
+
+// loadWHOISCache returns a cached lookup if it is younger than the TTL
+func loadWHOISCache(ip string) (WHOISInfo, bool) {
+	data, err := os.ReadFile(whoisCachePath(ip))
+	if err != nil {
+		return WHOISInfo{}, false
+	}
+
+	var entry whoisCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return WHOISInfo{}, false
+	}
+	if time.Since(entry.FetchedAt) > whoisCacheTTL {
+		return WHOISInfo{}, false
+	}
+	return entry.Info, true
+}
+
+// saveWHOISCache stores a lookup result, ignoring errors since caching is best effort
+func saveWHOISCache(ip string, info WHOISInfo) {
+	path := whoisCachePath(ip)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+
+	data, err := json.MarshalIndent(whoisCacheEntry{FetchedAt: time.Now(), Info: info}, "", "  ")
+	if err != nil {
+		return
+	}
+	os.WriteFile(path, data, 0644)
+}
@@ -0,0 +1,255 @@
+package layer3
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+const ripeResponse = `% This is the RIPE Database query service.
+
+inetnum:        192.0.2.0 - 192.0.2.255
+netname:        EXAMPLE-NET
+descr:          Example Networks Ltd
+country:        GB
+abuse-mailbox:  abuse@example.net
+`
+
+// mockWHOISServer answers each query line with the response chosen by respond
+// and records the queries it received
+type mockWHOISServer struct {
+	addr    string
+	mu      sync.Mutex
+	queries []string
+}
+
+func startWHOISServer(t *testing.T, respond func(query string) string) *mockWHOISServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	srv := &mockWHOISServer{addr: ln.Addr().String()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				query := strings.TrimSpace(line)
+				srv.mu.Lock()
+				srv.queries = append(srv.queries, query)
+				srv.mu.Unlock()
+				conn.Write([]byte(respond(query)))
+			}()
+		}
+	}()
+	return srv
+}
+
+func (s *mockWHOISServer) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+// useWHOISServers points lookups at root and caches results in a temporary directory
+func useWHOISServers(t *testing.T, root string) {
+	t.Helper()
+	origRoot, origCache := whoisRootServer, whoisCacheDir
+	whoisRootServer = root
+	whoisCacheDir = t.TempDir()
+	t.Cleanup(func() { whoisRootServer, whoisCacheDir = origRoot, origCache })
+}
+
+func TestQueryWHOISFollowsReferral(t *testing.T) {
+	registry := startWHOISServer(t, func(string) string { return ripeResponse })
+	root := startWHOISServer(t, func(string) string {
+		return "% IANA WHOIS server\n\nrefer:        " + registry.addr + "\n\ninetnum:      192.0.0.0 - 192.255.255.255\n"
+	})
+	useWHOISServers(t, root.addr)
+
+	info, err := QueryWHOIS(context.Background(), "192.0.2.10")
+	if err != nil {
+		t.Fatalf("QueryWHOIS() error = %v", err)
+	}
+
+	want := WHOISInfo{
+		NetName:    "EXAMPLE-NET",
+		OrgName:    "Example Networks Ltd",
+		Country:    "GB",
+		CIDR:       "192.0.2.0 - 192.0.2.255",
+		AbuseEmail: "abuse@example.net",
+		Server:     registry.addr,
+	}
+	if info != want {
+		t.Errorf("QueryWHOIS() = %+v, want %+v", info, want)
+	}
+
+	if got := root.Queries(); len(got) != 1 || got[0] != "192.0.2.10" {
+		t.Errorf("root server queries = %v, want [192.0.2.10]", got)
+	}
+	if got := registry.Queries(); len(got) != 1 {
+		t.Errorf("registry queries = %v, want one query", got)
+	}
+
+	// A second lookup is served from the cache without touching either server
+	cached, err := QueryWHOIS(context.Background(), "192.0.2.10")
+	if err != nil {
+		t.Fatalf("cached QueryWHOIS() error = %v", err)
+	}
+	if cached != want {
+		t.Errorf("cached QueryWHOIS() = %+v, want %+v", cached, want)
+	}
+	if got := len(root.Queries()) + len(registry.Queries()); got != 2 {
+		t.Errorf("servers received %d queries after cached lookup, want 2", got)
+	}
+}
+
+func TestQueryWHOISNoOwnership(t *testing.T) {
+	root := startWHOISServer(t, func(string) string {
+		return "% No match found for this address\n"
+	})
+	useWHOISServers(t, root.addr)
+
+	if _, err := QueryWHOIS(context.Background(), "192.0.2.10"); err == nil {
+		t.Fatal("QueryWHOIS() error = nil, want error for a response without ownership fields")
+	}
+	if _, ok := loadWHOISCache("192.0.2.10"); ok {
+		t.Error("failed lookup was cached")
+	}
+}
+
+func TestQueryWHOISInvalidIP(t *testing.T) {
+	if _, err := QueryWHOIS(context.Background(), "not-an-ip"); err == nil {
+		t.Fatal("QueryWHOIS() error = nil, want error for an invalid IP")
+	}
+}
+
+func TestLoadWHOISCacheExpired(t *testing.T) {
+	useWHOISServers(t, "127.0.0.1:0")
+
+	writeEntry := func(ip string, fetchedAt time.Time) {
+		t.Helper()
+		data, err := json.Marshal(whoisCacheEntry{
+			FetchedAt: fetchedAt,
+			Info:      WHOISInfo{NetName: "CACHED-NET"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(whoisCachePath(ip), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeEntry("192.0.2.1", time.Now().Add(-time.Hour))
+	writeEntry("192.0.2.2", time.Now().Add(-whoisCacheTTL-time.Minute))
+
+	if info, ok := loadWHOISCache("192.0.2.1"); !ok || info.NetName != "CACHED-NET" {
+		t.Errorf("loadWHOISCache(fresh) = %+v, %v, want cached entry", info, ok)
+	}
+	if _, ok := loadWHOISCache("192.0.2.2"); ok {
+		t.Error("loadWHOISCache(expired) returned an entry older than the TTL")
+	}
+}
+
+func TestParseWHOISReferral(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+	}{
+		{"iana refer", "refer:        whois.ripe.net\n", "whois.ripe.net:43"},
+		{"whois line", "whois: whois.apnic.net\n", "whois.apnic.net:43"},
+		{"arin referral", "ReferralServer:  whois://whois.lacnic.net\n", "whois.lacnic.net:43"},
+		{"rwhois with port", "ReferralServer: rwhois://rwhois.example.net:4321\n", "rwhois.example.net:4321"},
+		{"commented out", "% refer: whois.ripe.net\n", ""},
+		{"none", "netname: EXAMPLE-NET\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseWHOISReferral(tt.response); got != tt.want {
+				t.Errorf("parseWHOISReferral() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWHOISResponseARIN(t *testing.T) {
+	response := `#
+# ARIN WHOIS data and services are subject to the Terms of Use
+#
+
+NetRange:       198.51.100.0 - 198.51.100.255
+CIDR:           198.51.100.0/24
+NetName:        EXAMPLE-ARIN
+NetHandle:      NET-198-51-100-0-1
+OrgName:        Example Corp
+Country:        US
+OrgAbuseEmail:  abuse@example.com
+OrgName:        Second Org
+`
+	want := WHOISInfo{
+		NetName:    "EXAMPLE-ARIN",
+		OrgName:    "Example Corp",
+		Country:    "US",
+		CIDR:       "198.51.100.0/24",
+		ARINHandle: "NET-198-51-100-0-1",
+		AbuseEmail: "abuse@example.com",
+	}
+	if got := parseWHOISResponse(response); got != want {
+		t.Errorf("parseWHOISResponse() = %+v, want %+v", got, want)
+	}
+}
+
+func TestWHOISOwnership(t *testing.T) {
+	root := startWHOISServer(t, func(string) string { return ripeResponse })
+	useWHOISServers(t, root.addr)
+
+	tests := []struct {
+		name     string
+		expected string
+		want     common.TestStatus
+	}{
+		{"matching organisation", "example networks", common.StatusPassed},
+		{"different organisation", "Other Corp", common.StatusWarning},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New("", "192.0.2.10", 1)
+			r.VerifyIPOwnership = true
+			r.ExpectedOrgName = tt.expected
+
+			result := r.testWHOISOwnership(context.Background())
+			if result.Status != tt.want {
+				t.Errorf("status = %v, want %v (%s)", result.Status, tt.want, result.Message)
+			}
+			if result.Diagnostics.Network == nil || result.Diagnostics.Network.WHOIS == nil {
+				t.Fatal("WHOIS diagnostics not recorded")
+			}
+			if got := result.Diagnostics.Network.WHOIS.OrgName; got != "Example Networks Ltd" {
+				t.Errorf("diagnostics org = %q, want %q", got, "Example Networks Ltd")
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package layer4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// Bandwidth test settings
+const (
+	bandwidthBlockSize      = 64 * 1024
+	defaultBandwidthTime    = 5 * time.Second
+	defaultBandwidthStreams = 4
+	bandwidthDrainTimeout   = 5 * time.Second
+)
+
+// Throughput holds upload and download rates in megabits per second
+type Throughput = common.Throughput
+
+// BandwidthResult summarises a bandwidth test across all streams
+type BandwidthResult = common.BandwidthResult
+
+// RunBandwidthTest measures TCP throughput to an echo service at addr by
+// writing 64 KiB blocks over parallelStreams connections for duration and
+// counting the bytes echoed back. When addr is empty an echo server is started
+// on a random loopback port, which measures the local TCP stack.
+func (r *Runner) RunBandwidthTest(ctx context.Context, addr string, duration time.Duration, parallelStreams int) (BandwidthResult, error) {
+	if duration <= 0 {
+		duration = defaultBandwidthTime
+	}
+	if parallelStreams <= 0 {
+		parallelStreams = defaultBandwidthStreams
+	}
+
+	if addr == "" {
+		listener, err := startEchoServer()
+		if err != nil {
+			return BandwidthResult{}, err
+		}
+		defer listener.Close()
+		addr = listener.Addr().String()
+	}
+
+	result := BandwidthResult{Target: addr, Streams: parallelStreams}
+
+	// Connect every stream before starting the clock
+	dialer := net.Dialer{Timeout: r.Timeout}
+	conns := make([]*net.TCPConn, 0, parallelStreams)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < parallelStreams; i++ {
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return result, fmt.Errorf("failed to open stream %d to %s: %w", i+1, addr, err)
+		}
+		conns = append(conns, conn.(*net.TCPConn))
+	}
+
+	var sent, received atomic.Int64
+	var lastRead atomic.Int64
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*parallelStreams)
+
+	start := time.Now()
+	deadline := start.Add(duration)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	for _, conn := range conns {
+		wg.Add(2)
+
+		// Reader counts echoed bytes until the server closes its side
+		go func(conn *net.TCPConn) {
+			defer wg.Done()
+			defer common.RecoverTestGoroutine(4, common.Logger, nil)()
+			conn.SetReadDeadline(deadline.Add(bandwidthDrainTimeout))
+			buf := make([]byte, bandwidthBlockSize)
+			for {
+				n, err := conn.Read(buf)
+				if n > 0 {
+					received.Add(int64(n))
+					lastRead.Store(int64(time.Since(start)))
+				}
+				if err != nil {
+					// Services that never close their side end on the drain timeout
+					var netErr net.Error
+					if !errors.Is(err, io.EOF) && (!errors.As(err, &netErr) || !netErr.Timeout()) {
+						errs <- fmt.Errorf("read failed: %w", err)
+					}
+					return
+				}
+			}
+		}(conn)
+
+		// Writer sends blocks until the deadline, then half-closes
+		go func(conn *net.TCPConn) {
+			defer wg.Done()
+			defer conn.CloseWrite()
+			defer common.RecoverTestGoroutine(4, common.Logger, nil)()
+			conn.SetWriteDeadline(deadline)
+			block := make([]byte, bandwidthBlockSize)
+			for time.Now().Before(deadline) && ctx.Err() == nil {
+				n, err := conn.Write(block)
+				sent.Add(int64(n))
+				if err != nil {
+					var netErr net.Error
+					if !errors.As(err, &netErr) || !netErr.Timeout() {
+						errs <- fmt.Errorf("write failed: %w", err)
+					}
+					return
+				}
+			}
+		}(conn)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	elapsed := time.Since(start)
+	uploadTime := deadline.Sub(start)
+	if uploadTime > elapsed {
+		uploadTime = elapsed
+	}
+	downloadTime := time.Duration(lastRead.Load())
+
+	result.SentBytes = sent.Load()
+	result.ReceivedBytes = received.Load()
+	result.DurationMs = float64(elapsed.Microseconds()) / 1000
+	result.ThroughputMbps.Upload = megabitsPerSecond(result.SentBytes, uploadTime)
+	result.ThroughputMbps.Download = megabitsPerSecond(result.ReceivedBytes, downloadTime)
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	if err, ok := <-errs; ok {
+		return result, err
+	}
+	return result, nil
+}
+
+// megabitsPerSecond converts a byte count over d to Mbps
+func megabitsPerSecond(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) * 8 / d.Seconds() / 1e6
+}
+
+// startEchoServer listens on a random loopback port and echoes every
+// connection back to itself
+func startEchoServer() (net.Listener, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start echo server: %w", err)
+	}
+
+	go func() {
+		defer common.RecoverTestGoroutine(4, common.Logger, nil)()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				defer common.RecoverTestGoroutine(4, common.Logger, nil)()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return listener, nil
+}
+
+// testBandwidth runs the bandwidth test and reports it as a sub-test
+func (r *Runner) testBandwidth(ctx context.Context) common.TestResult {
+	target := r.BandwidthTarget
+	label := target
+	if label == "" {
+		label = "loopback"
+	}
+
+	result := common.TestResult{
+		Layer:     4,
+		Name:      fmt.Sprintf("TCP Bandwidth Test (%s)", label),
+		StartTime: time.Now(),
+	}
+
+	bw, err := r.RunBandwidthTest(ctx, target, r.BandwidthDuration, r.BandwidthStreams)
+	result.Diagnostics.Transport = &common.TransportDiagnostics{
+		Bandwidth: &bw,
+	}
+	result.Metrics.TransferRate = bw.ThroughputMbps.Upload / 8 // MB/s
+	result.Metrics.Custom = map[string]interface{}{
+		"bandwidth_mbps":     bw.ThroughputMbps.Upload,
+		"upload_mbps":        bw.ThroughputMbps.Upload,
+		"download_mbps":      bw.ThroughputMbps.Download,
+		"bandwidth_streams":  bw.Streams,
+		"bandwidth_sent":     bw.SentBytes,
+		"bandwidth_received": bw.ReceivedBytes,
+	}
+	if err != nil {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Bandwidth test to %s failed: %v", label, err))
+	}
+
+	if bw.ReceivedBytes < bw.SentBytes {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Bandwidth to %s: %.2f Mbps up, %.2f Mbps down; only %d of %d bytes were echoed",
+			label, bw.ThroughputMbps.Upload, bw.ThroughputMbps.Download, bw.ReceivedBytes, bw.SentBytes))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("Bandwidth to %s: %.2f Mbps up, %.2f Mbps down over %d streams",
+		label, bw.ThroughputMbps.Upload, bw.ThroughputMbps.Download, bw.Streams))
+}
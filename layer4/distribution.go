@@ -0,0 +1,124 @@
+package layer4
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// defaultBandwidthSamples is the number of dials in a connect time distribution
+const defaultBandwidthSamples = 10
+
+// DistributionResult summarises the dial latency of repeated TCP connections
+type DistributionResult = common.DistributionResult
+
+// runBandwidthDistribution dials addr BandwidthSamples times in sequence and
+// computes the distribution of the successful dial latencies. Sampling stops
+// early when ctx is done, leaving Samples at the number of dials attempted.
+func (r *Runner) runBandwidthDistribution(ctx context.Context, addr string) DistributionResult {
+	result := DistributionResult{Target: addr, Samples: r.BandwidthSamples}
+
+	dialer := &net.Dialer{Timeout: r.Timeout}
+	latencies := make([]time.Duration, 0, r.BandwidthSamples)
+	for i := 0; i < r.BandwidthSamples; i++ {
+		if ctx.Err() != nil {
+			result.Samples = i
+			break
+		}
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			result.Failed++
+			result.LastError = err.Error()
+			continue
+		}
+		latencies = append(latencies, time.Since(start))
+		conn.Close()
+	}
+	if len(latencies) == 0 {
+		return result
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+
+	result.MinMs = durationMs(latencies[0])
+	result.MaxMs = durationMs(latencies[len(latencies)-1])
+	result.MeanMs = durationMs(total / time.Duration(len(latencies)))
+	result.P50Ms = durationMs(percentile(latencies, 50))
+	result.P95Ms = durationMs(percentile(latencies, 95))
+	result.P99Ms = durationMs(percentile(latencies, 99))
+	return result
+}
+
+// percentile returns the p-th percentile (0-100) of sorted using nearest-rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// durationMs converts d to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// testConnectDistribution runs the connect time distribution for addr and
+// reports it as a sub-test, failing when p95 exceeds LatencyErrorMs
+func (r *Runner) testConnectDistribution(ctx context.Context, addr string) common.TestResult {
+	result := common.TestResult{
+		Layer:     4,
+		Name:      fmt.Sprintf("TCP Connection Distribution (%s)", addr),
+		StartTime: time.Now(),
+	}
+
+	dist := r.runBandwidthDistribution(ctx, addr)
+	result.Diagnostics.Transport = &common.TransportDiagnostics{
+		Target:              addr,
+		ConnectDistribution: &dist,
+	}
+
+	succeeded := dist.Samples - dist.Failed
+	if dist.Samples == 0 {
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("No TCP connections to %s sampled before the deadline", addr))
+	}
+	if succeeded == 0 {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("All %d TCP connections to %s failed: %s",
+			dist.Samples, addr, dist.LastError))
+	}
+
+	result.Metrics.Latency = time.Duration(dist.MeanMs * float64(time.Millisecond))
+	result.Metrics.PacketLoss = float64(dist.Failed) / float64(dist.Samples) * 100
+	result.Metrics.Custom = map[string]interface{}{
+		"min_connect_ms": dist.MinMs,
+		"max_connect_ms": dist.MaxMs,
+		"p95_connect_ms": dist.P95Ms,
+		"p99_connect_ms": dist.P99Ms,
+	}
+
+	summary := fmt.Sprintf("%d/%d connections to %s, connect time min/mean/p95/p99/max: %.2f/%.2f/%.2f/%.2f/%.2f ms",
+		succeeded, dist.Samples, addr, dist.MinMs, dist.MeanMs, dist.P95Ms, dist.P99Ms, dist.MaxMs)
+
+	if r.LatencyErrorMs > 0 && dist.P95Ms > float64(r.LatencyErrorMs) {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("TCP connect time p95 %.2f ms exceeds %d ms: %s",
+			dist.P95Ms, r.LatencyErrorMs, summary))
+	}
+	if dist.Failed > 0 {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("%d TCP connections failed (%s): %s",
+			dist.Failed, dist.LastError, summary))
+	}
+	return result.Finish(common.StatusPassed, summary)
+}
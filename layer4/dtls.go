@@ -0,0 +1,94 @@
+package layer4
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pion/dtls/v2"
+
+	"ghostshell/app/layers/common"
+)
+
+// dtls13Unsupported is reported for DTLS 1.3, which pion/dtls v2 does not
+// implement, so whether a target offers it is never checked
+const dtls13Unsupported = "unsupported: the DTLS client implements DTLS 1.2 only"
+
+// TestDTLSHandshake performs a DTLS 1.2 handshake with addr, verifying the
+// server certificate and recording the handshake latency. pion/dtls v2 does
+// not expose the negotiated cipher suite, so it is not recorded.
+func (r *Runner) TestDTLSHandshake(ctx context.Context, addr string, timeout time.Duration) (common.TestResult, error) {
+	result := common.TestResult{
+		Layer:     4,
+		Name:      fmt.Sprintf("DTLS Handshake Test (%s)", addr),
+		StartTime: time.Now(),
+	}
+	diagnostics := &common.TransportDiagnostics{
+		Target:       addr,
+		DTLS13Status: dtls13Unsupported,
+	}
+	result.Diagnostics.Transport = diagnostics
+
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to resolve DTLS target %s: %v", addr, err)), err
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	config := &dtls.Config{
+		ServerName:           host,
+		RootCAs:              r.dtlsRootCAs,
+		ExtendedMasterSecret: dtls.RequireExtendedMasterSecret,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	handshakeStart := time.Now()
+	conn, err := dtls.DialWithContext(ctx, "udp", raddr, config)
+	rtt := time.Since(handshakeStart)
+	diagnostics.RTTMs = common.Ptr(rtt.Milliseconds())
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("DTLS handshake with %s failed: %v", addr, err)), err
+	}
+	defer conn.Close()
+
+	result.Metrics.Latency = rtt
+
+	version := "DTLS 1.2"
+	diagnostics.TLSVersion = version
+
+	state := conn.ConnectionState()
+
+	// Check the leaf certificate's validity period
+	if len(state.PeerCertificates) > 0 {
+		cert, err := x509.ParseCertificate(state.PeerCertificates[0])
+		if err != nil {
+			diagnostics.Error = err.Error()
+			return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to parse DTLS certificate from %s: %v", addr, err)), err
+		}
+		diagnostics.CertExpiry = &cert.NotAfter
+		diagnostics.CertSubject = cert.Subject.String()
+
+		if time.Now().After(cert.NotAfter) {
+			err := fmt.Errorf("certificate expired on %s", cert.NotAfter.Format(time.RFC3339))
+			return result.Finish(common.StatusFailed, fmt.Sprintf("DTLS certificate for %s has expired", addr)), err
+		}
+	}
+
+	if r.RequireDTLS13 {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("DTLS handshake with %s negotiated %s; "+
+			"DTLS 1.3 is required but unsupported by the DTLS client, so it was not checked", addr, version)), nil
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("DTLS handshake with %s succeeded in %d ms (%s)",
+		addr, rtt.Milliseconds(), version)), nil
+}
@@ -0,0 +1,152 @@
+package layer4
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pion/dtls/v2"
+
+	"ghostshell/app/layers/common"
+)
+
+// dtlsTestCertificate returns a self-signed certificate for 127.0.0.1 valid
+// from notBefore to notAfter
+func dtlsTestCertificate(t *testing.T, notBefore, notAfter time.Time) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "dtls-echo"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+// startDTLSEchoServer serves DTLS on a local port with cert, echoing what
+// each client sends, and returns its address
+func startDTLSEchoServer(t *testing.T, cert tls.Certificate) string {
+	t.Helper()
+	listener, err := dtls.Listen("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, &dtls.Config{
+		Certificates:         []tls.Certificate{cert},
+		ExtendedMasterSecret: dtls.RequireExtendedMasterSecret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 1024)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					if _, err := conn.Write(buf[:n]); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestDTLSHandshake(t *testing.T) {
+	now := time.Now()
+	valid, validCert := dtlsTestCertificate(t, now.Add(-time.Hour), now.Add(24*time.Hour))
+	expired, expiredCert := dtlsTestCertificate(t, now.Add(-48*time.Hour), now.Add(-24*time.Hour))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(validCert)
+	roots.AddCert(expiredCert)
+
+	tests := []struct {
+		name          string
+		cert          tls.Certificate
+		requireDTLS13 bool
+		want          common.TestStatus
+	}{
+		{"valid certificate", valid, false, common.StatusPassed},
+		{"DTLS 1.3 required", valid, true, common.StatusWarning},
+		{"expired certificate", expired, false, common.StatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startDTLSEchoServer(t, tt.cert)
+			r := New(nil, "", 5*time.Second)
+			r.dtlsRootCAs = roots
+			r.RequireDTLS13 = tt.requireDTLS13
+
+			result, err := r.TestDTLSHandshake(context.Background(), addr, 5*time.Second)
+			if result.Status != tt.want {
+				t.Fatalf("status %s, want %s: %s (%v)", result.Status, tt.want, result.Message, err)
+			}
+			diagnostics := result.Diagnostics.Transport
+			if diagnostics.DTLS13Status != dtls13Unsupported {
+				t.Errorf("DTLS 1.3 status %q, want it reported as unsupported", diagnostics.DTLS13Status)
+			}
+			if tt.want == common.StatusFailed {
+				if err == nil {
+					t.Error("no error returned for a failed handshake")
+				}
+				return
+			}
+			if diagnostics.TLSVersion != "DTLS 1.2" || diagnostics.RTTMs == nil {
+				t.Errorf("version %q, RTT %v, want DTLS 1.2 with an RTT", diagnostics.TLSVersion, diagnostics.RTTMs)
+			}
+			if diagnostics.CertExpiry == nil || !diagnostics.CertExpiry.Equal(validCert.NotAfter) {
+				t.Errorf("certificate expiry %v, want %v", diagnostics.CertExpiry, validCert.NotAfter)
+			}
+			if tt.requireDTLS13 && !strings.Contains(result.Message, "unsupported") {
+				t.Errorf("message %q does not say DTLS 1.3 is unsupported", result.Message)
+			}
+		})
+	}
+}
+
+func TestDTLSHandshakeUntrustedCertificate(t *testing.T) {
+	now := time.Now()
+	cert, _ := dtlsTestCertificate(t, now.Add(-time.Hour), now.Add(time.Hour))
+	addr := startDTLSEchoServer(t, cert)
+
+	// The system roots don't trust the self-signed certificate
+	result, err := New(nil, "", 5*time.Second).TestDTLSHandshake(context.Background(), addr, 5*time.Second)
+	if result.Status != common.StatusFailed || err == nil {
+		t.Errorf("status %s with error %v, want a failed handshake", result.Status, err)
+	}
+}
@@ -0,0 +1,246 @@
+package layer4
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+
+	"ghostshell/app/layers/common"
+)
+
+// icmpRateSteps are the probe rates tried in order, in packets per second
+var icmpRateSteps = []int{10, 50, 100, 500}
+
+// Rate limit detection settings
+const (
+	icmpRateLimitMaxPPS    = 500
+	icmpRateStepDuration   = 2 * time.Second
+	icmpRateReplyGrace     = time.Second
+	icmpRateDropThreshold  = 20.0 // Percent
+	icmpRateWarnBelowPPS   = 100
+	protocolICMP           = 1
+	icmpRateLimitProbeData = "GhostSuite-RateLimit-Probe"
+)
+
+// icmpRateDeadlineMargin is left before the context deadline for the Layer 4
+// tests that follow the probe
+const icmpRateDeadlineMargin = 500 * time.Millisecond
+
+// ICMPRateStep is the outcome of probing at a single rate
+type ICMPRateStep = common.ICMPRateStep
+
+// ICMPRateLimitResult summarises an ICMP rate limit probe
+type ICMPRateLimitResult = common.ICMPRateLimitResult
+
+// DetectICMPRateLimit sends ICMP echo requests to target at increasing rates up
+// to pps, each for duration, and reports the rate at which replies start being
+// dropped. Loss that is already present at the lowest rate is not treated as
+// rate limiting. When ctx has a deadline, the steps are shortened so that all
+// of them, with their wait for late replies, finish before it.
+func DetectICMPRateLimit(ctx context.Context, target string, pps int, duration time.Duration) (ICMPRateLimitResult, error) {
+	var result ICMPRateLimitResult
+
+	var rates []int
+	for _, rate := range icmpRateSteps {
+		if rate <= pps {
+			rates = append(rates, rate)
+		}
+	}
+	grace := icmpRateReplyGrace
+	if deadline, ok := ctx.Deadline(); ok && len(rates) > 0 {
+		perStep := (time.Until(deadline) - icmpRateDeadlineMargin) / time.Duration(len(rates))
+		if perStep <= 0 {
+			return result, fmt.Errorf("no time left before the deadline to probe %s", target)
+		}
+		if perStep < duration+grace {
+			// Split each step's share between sending and waiting for late replies
+			duration = perStep * 2 / 3
+			grace = perStep - duration
+		}
+	}
+
+	dst, err := net.ResolveIPAddr("ip4", target)
+	if err != nil {
+		return result, fmt.Errorf("failed to resolve %s: %w", target, err)
+	}
+
+	conn, network, err := listenICMP()
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+
+	// Unprivileged datagram sockets expect a UDP address and rewrite the ID
+	var addr net.Addr = dst
+	if network == "udp4" {
+		addr = &net.UDPAddr{IP: dst.IP}
+	}
+	id := os.Getpid() & 0xffff
+
+	// A single reader records every sequence number answered
+	var mu sync.Mutex
+	replies := make(map[int]bool)
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		defer common.RecoverTestGoroutine(4, common.Logger, nil)()
+		buf := make([]byte, 1500)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			msg, err := icmp.ParseMessage(protocolICMP, buf[:n])
+			if err != nil || msg.Type != ipv4.ICMPTypeEchoReply {
+				continue
+			}
+			echo, ok := msg.Body.(*icmp.Echo)
+			if !ok || (network == "ip4:icmp" && echo.ID != id) {
+				continue
+			}
+			mu.Lock()
+			replies[echo.Seq] = true
+			mu.Unlock()
+		}
+	}()
+
+	seq := 0
+	for _, rate := range rates {
+		step := ICMPRateStep{PPS: rate}
+		first := seq
+		interval := time.Second / time.Duration(rate)
+		count := int(duration.Seconds() * float64(rate))
+		if count < 1 {
+			count = 1
+		}
+
+		ticker := time.NewTicker(interval)
+		for i := 0; i < count; i++ {
+			select {
+			case <-ctx.Done():
+				ticker.Stop()
+				conn.Close()
+				<-readerDone
+				return result, ctx.Err()
+			case <-ticker.C:
+			}
+
+			msg := icmp.Message{
+				Type: ipv4.ICMPTypeEcho,
+				Body: &icmp.Echo{ID: id, Seq: seq & 0xffff, Data: []byte(icmpRateLimitProbeData)},
+			}
+			wb, err := msg.Marshal(nil)
+			if err != nil {
+				ticker.Stop()
+				return result, fmt.Errorf("failed to marshal ICMP message: %w", err)
+			}
+			if _, err := conn.WriteTo(wb, addr); err == nil {
+				step.Sent++
+			}
+			seq++
+		}
+		ticker.Stop()
+
+		// Allow late replies for this step to arrive before counting
+		graceTimer := time.NewTimer(grace)
+		select {
+		case <-ctx.Done():
+			graceTimer.Stop()
+			conn.Close()
+			<-readerDone
+			return result, ctx.Err()
+		case <-graceTimer.C:
+		}
+
+		mu.Lock()
+		for s := first; s < seq; s++ {
+			if replies[s&0xffff] {
+				step.Received++
+			}
+		}
+		mu.Unlock()
+
+		if step.Sent > 0 {
+			step.DroppedPct = float64(step.Sent-step.Received) / float64(step.Sent) * 100
+		}
+		result.Steps = append(result.Steps, step)
+		result.Sent += step.Sent
+		result.Received += step.Received
+
+		if step.DroppedPct > icmpRateDropThreshold {
+			if len(result.Steps) == 1 {
+				break
+			}
+			result.LimitDetected = true
+			result.ApproxLimitPPS = rate
+			break
+		}
+	}
+
+	conn.Close()
+	<-readerDone
+
+	if result.Sent > 0 {
+		result.DroppedPct = float64(result.Sent-result.Received) / float64(result.Sent) * 100
+	}
+	if result.Received == 0 {
+		return result, fmt.Errorf("no ICMP echo replies received from %s", target)
+	}
+
+	return result, nil
+}
+
+// listenICMP opens a privileged raw ICMP socket, falling back to an
+// unprivileged datagram ICMP socket where the kernel allows it
+func listenICMP() (*icmp.PacketConn, string, error) {
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err == nil {
+		return conn, "ip4:icmp", nil
+	}
+
+	conn, udpErr := icmp.ListenPacket("udp4", "0.0.0.0")
+	if udpErr == nil {
+		return conn, "udp4", nil
+	}
+
+	return nil, "", fmt.Errorf("failed to open ICMP socket: %v", err)
+}
+
+// testICMPRateLimit probes host for ICMP rate limiting
+func testICMPRateLimit(ctx context.Context, host string) common.TestResult {
+	result := common.TestResult{
+		Layer:     4,
+		Name:      fmt.Sprintf("ICMP Rate Limit Test (%s)", host),
+		StartTime: time.Now(),
+	}
+
+	limit, err := DetectICMPRateLimit(ctx, host, icmpRateLimitMaxPPS, icmpRateStepDuration)
+	result.Diagnostics.Transport = &common.TransportDiagnostics{
+		Target:    host,
+		RateLimit: &limit,
+	}
+	result.Metrics.PacketLoss = limit.DroppedPct
+	if err != nil {
+		// Hosts that filter ICMP entirely are not a transport layer failure
+		return result.Finish(common.StatusWarning, fmt.Sprintf("ICMP rate limit test for %s inconclusive: %v", host, err))
+	}
+
+	if limit.LimitDetected && limit.ApproxLimitPPS < icmpRateWarnBelowPPS {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("ICMP responses from %s are throttled at approximately %d PPS",
+			host, limit.ApproxLimitPPS))
+	}
+
+	if limit.LimitDetected {
+		return result.Finish(common.StatusPassed, fmt.Sprintf("ICMP rate limiting detected on %s at approximately %d PPS",
+			host, limit.ApproxLimitPPS))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("No ICMP rate limiting detected on %s (%d/%d replies)",
+		host, limit.Received, limit.Sent))
+}
@@ -3,6 +3,7 @@ package layer4
 
 import (
 	"context"
+	"crypto/x509"
 	"fmt"
 	"net"
 	"os"
@@ -18,15 +19,20 @@ import (
 // Runner implements transport layer tests
 type Runner struct {
 	*common.Layer4Runner
+
+	// dtlsRootCAs verifies DTLS server certificates; nil uses the system roots
+	dtlsRootCAs *x509.CertPool
 }
 
 // New creates a new Layer4Runner
 func New(tcpAddresses []string, udpAddress string, timeout time.Duration) *Runner {
 	return &Runner{
 		Layer4Runner: &common.Layer4Runner{
-			TCPAddresses: tcpAddresses,
-			UDPAddress:   udpAddress,
-			Timeout:      timeout,
+			TCPAddresses:     tcpAddresses,
+			UDPAddress:       udpAddress,
+			Timeout:          timeout,
+			BandwidthSamples: defaultBandwidthSamples,
+			UDPSamples:       defaultUDPSamples,
 		},
 	}
 }
@@ -52,10 +58,16 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		parentResult.Status = common.StatusFailed
 		parentResult.Message = "Test cancelled"
 		parentResult.EndTime = time.Now()
-		return []common.TestResult{parentResult}, ctx.Err()
+		return []common.TestResult{parentResult}, common.NewLayerError(4, ctx.Err())
 	default:
 		var failedTests []string
 
+		// Sample the TCP counters around every other test
+		var retransWindow retransmissionWindow
+		if r.MeasureRetransmissions {
+			retransWindow = startRetransmissionWindow()
+		}
+
 		// Test TCP connections
 		for _, addr := range r.TCPAddresses {
 			tcpResult := common.TestResult{
@@ -77,12 +89,22 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 			tcpResult.EndTime = time.Now()
 			tcpResult.Metrics.Duration = tcpResult.EndTime.Sub(tcpResult.StartTime)
 			parentResult.SubResults = append(parentResult.SubResults, tcpResult)
+
+			// Connect time distribution over repeated dials, skipped when
+			// the single dial already failed
+			if r.BandwidthSamples > 1 && success {
+				distResult := r.testConnectDistribution(ctx, addr)
+				if distResult.Status == common.StatusFailed {
+					failedTests = append(failedTests, distResult.Message)
+				}
+				parentResult.SubResults = append(parentResult.SubResults, distResult)
+			}
 		}
 
-		// Test UDP connection
+		// Test UDP connectivity; this only sends, so it can't measure RTT
 		udpResult := common.TestResult{
 			Layer:     4,
-			Name:      fmt.Sprintf("UDP Connection Test (%s)", r.UDPAddress),
+			Name:      fmt.Sprintf("UDP Connectivity (%s)", r.UDPAddress),
 			StartTime: time.Now(),
 		}
 
@@ -100,6 +122,59 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		udpResult.Metrics.Duration = udpResult.EndTime.Sub(udpResult.StartTime)
 		parentResult.SubResults = append(parentResult.SubResults, udpResult)
 
+		// Measure UDP round trip times through the local stack and to UDPAddress
+		loopbackResult := r.testUDPLoopbackRTT(ctx)
+		if loopbackResult.Status == common.StatusFailed {
+			failedTests = append(failedTests, loopbackResult.Message)
+		}
+		parentResult.SubResults = append(parentResult.SubResults, loopbackResult)
+		parentResult.SubResults = append(parentResult.SubResults, r.testUDPRTT(ctx))
+
+		// Test DTLS handshakes
+		for _, addr := range r.DTLSTargets {
+			dtlsResult, err := r.TestDTLSHandshake(ctx, addr, r.Timeout)
+			if err != nil {
+				failedTests = append(failedTests, dtlsResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, dtlsResult)
+		}
+
+		// Probe each TCP target host for ICMP rate limiting
+		if r.CheckICMPRateLimit {
+			seen := make(map[string]bool)
+			for _, addr := range r.TCPAddresses {
+				host, _, err := net.SplitHostPort(addr)
+				if err != nil || seen[host] {
+					continue
+				}
+				seen[host] = true
+				parentResult.SubResults = append(parentResult.SubResults, testICMPRateLimit(ctx, host))
+			}
+		}
+
+		// Measure TCP throughput
+		if r.EnableBandwidth {
+			bwResult := r.testBandwidth(ctx)
+			if bwResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, bwResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, bwResult)
+		}
+
+		// Detect TCP Fast Open support; informational, so it never fails the layer
+		if r.DetectTFO {
+			parentResult.SubResults = append(parentResult.SubResults, r.testTFO(ctx))
+		}
+
+		// Retransmissions over the window spanning the tests above
+		if r.MeasureRetransmissions {
+			retransResult := r.testRetransmissions(retransWindow)
+			if retransResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, retransResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, retransResult)
+		}
+
 		// Set overall test status and message
 		if len(failedTests) > 0 {
 			parentResult.Status = common.StatusFailed
@@ -124,7 +199,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		}
 
 		if len(failedTests) > 0 {
-			return []common.TestResult{parentResult}, fmt.Errorf("layer 4 tests failed")
+			return []common.TestResult{parentResult}, &common.LayerError{Layer: 4, Code: common.ErrTestFailed, Cause: fmt.Errorf("layer 4 tests failed")}
 		}
 		return []common.TestResult{parentResult}, nil
 	}
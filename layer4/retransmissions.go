@@ -0,0 +1,147 @@
+package layer4
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// procNetSNMP holds the kernel's cumulative protocol counters
+const procNetSNMP = "/proc/net/snmp"
+
+// tcpSegmentCounters are the cumulative TCP segment counters of the system
+type tcpSegmentCounters struct {
+	RetransSegs int64
+	OutSegs     int64
+}
+
+// parseTCPSNMP reads the Tcp counters from /proc/net/snmp, where a "Tcp:"
+// line of counter names is followed by a "Tcp:" line of their values
+func parseTCPSNMP(r io.Reader) (tcpSegmentCounters, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "Tcp:" {
+			continue
+		}
+		if names == nil {
+			names = fields[1:]
+			continue
+		}
+
+		values := make(map[string]int64, len(names))
+		for i, name := range names {
+			if i+1 >= len(fields) {
+				break
+			}
+			value, err := strconv.ParseInt(fields[i+1], 10, 64)
+			if err != nil {
+				return tcpSegmentCounters{}, fmt.Errorf("invalid Tcp %s value %q: %w", name, fields[i+1], err)
+			}
+			values[name] = value
+		}
+
+		retrans, ok1 := values["RetransSegs"]
+		out, ok2 := values["OutSegs"]
+		if !ok1 || !ok2 {
+			return tcpSegmentCounters{}, fmt.Errorf("RetransSegs or OutSegs missing from Tcp counters")
+		}
+		return tcpSegmentCounters{RetransSegs: retrans, OutSegs: out}, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return tcpSegmentCounters{}, err
+	}
+	return tcpSegmentCounters{}, fmt.Errorf("no Tcp counters found")
+}
+
+// readTCPSegmentCounters reads the current TCP segment counters
+func readTCPSegmentCounters() (tcpSegmentCounters, error) {
+	f, err := os.Open(procNetSNMP)
+	if err != nil {
+		return tcpSegmentCounters{}, err
+	}
+	defer f.Close()
+	return parseTCPSNMP(f)
+}
+
+// retransmissionWindow is a TCP counter sample taken when the Layer 4 tests
+// start, compared against a second sample once they finish
+type retransmissionWindow struct {
+	start  time.Time
+	before tcpSegmentCounters
+	err    error
+}
+
+// startRetransmissionWindow takes the first sample; on platforms other than
+// linux there is nothing to read
+func startRetransmissionWindow() retransmissionWindow {
+	window := retransmissionWindow{start: time.Now()}
+	if runtime.GOOS == "linux" {
+		window.before, window.err = readTCPSegmentCounters()
+	}
+	return window
+}
+
+// testRetransmissions takes the second sample and rates the retransmissions
+// in between against the packet loss thresholds. The counters are system
+// wide, so traffic outside these tests is included.
+func (r *Runner) testRetransmissions(window retransmissionWindow) common.TestResult {
+	result := common.TestResult{
+		Layer:     4,
+		Name:      "TCP Retransmission Rate",
+		StartTime: window.start,
+	}
+
+	if runtime.GOOS != "linux" {
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("TCP retransmission counters are not available on %s", runtime.GOOS))
+	}
+
+	diagnostics := &common.TransportDiagnostics{}
+	result.Diagnostics.Transport = diagnostics
+
+	after, err := readTCPSegmentCounters()
+	if window.err != nil {
+		err = window.err
+	}
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("Failed to read TCP counters from %s: %v", procNetSNMP, err))
+	}
+
+	sample := &common.RetransmissionSample{
+		RetransSegs: after.RetransSegs - window.before.RetransSegs,
+		OutSegs:     after.OutSegs - window.before.OutSegs,
+		WindowMs:    float64(time.Since(window.start).Microseconds()) / 1000,
+	}
+	diagnostics.Retransmissions = sample
+
+	if sample.OutSegs <= 0 {
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("No TCP segments were sent in the %.0f ms sample window", sample.WindowMs))
+	}
+	sample.RatePct = float64(sample.RetransSegs) / float64(sample.OutSegs) * 100
+	result.Metrics.Custom = map[string]interface{}{
+		"retransmission_rate_pct": sample.RatePct,
+		"retrans_segs":            sample.RetransSegs,
+		"out_segs":                sample.OutSegs,
+	}
+
+	summary := fmt.Sprintf("%d of %d TCP segments retransmitted in %.0f ms",
+		sample.RetransSegs, sample.OutSegs, sample.WindowMs)
+	if r.PacketLossErrorPct > 0 && sample.RatePct > r.PacketLossErrorPct {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("TCP retransmission rate %.2f%% is above %.2f%%: %s",
+			sample.RatePct, r.PacketLossErrorPct, summary))
+	}
+	if r.PacketLossWarningPct > 0 && sample.RatePct > r.PacketLossWarningPct {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("TCP retransmission rate %.2f%% is above %.2f%%: %s",
+			sample.RatePct, r.PacketLossWarningPct, summary))
+	}
+	return result.Finish(common.StatusPassed, fmt.Sprintf("TCP retransmission rate %.2f%%: %s", sample.RatePct, summary))
+}
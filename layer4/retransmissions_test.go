@@ -0,0 +1,65 @@
+package layer4
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const snmpFixture = `Ip: Forwarding DefaultTTL InReceives InHdrErrors InAddrErrors ForwDatagrams InUnknownProtos InDiscards InDelivers OutRequests OutDiscards OutNoRoutes ReasmTimeout ReasmReqds ReasmOKs ReasmFails FragOKs FragFails FragCreates
+Ip: 1 64 2873467 0 0 0 0 0 2873301 2150417 24 0 0 0 0 0 0 0 0
+Icmp: InMsgs InErrors InCsumErrors InDestUnreachs InTimeExcds InParmProbs InSrcQuenchs InRedirects InEchos InEchoReps InTimestamps InTimestampReps InAddrMasks InAddrMaskReps OutMsgs OutErrors OutRateLimitGlobal OutRateLimitHost OutDestUnreachs OutTimeExcds OutParmProbs OutSrcQuenchs OutRedirects OutEchos OutEchoReps OutTimestamps OutTimestampReps OutAddrMasks OutAddrMaskReps
+Icmp: 45 0 0 45 0 0 0 0 0 0 0 0 0 0 45 0 0 0 45 0 0 0 0 0 0 0 0 0 0
+IcmpMsg: InType3 OutType3
+IcmpMsg: 45 45
+Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts InCsumErrors
+Tcp: 1 200 120000 -1 10342 2411 318 1290 17 2741822 2398815 4521 3 9983 0
+Udp: InDatagrams NoPorts InErrors OutDatagrams RcvbufErrors SndbufErrors InCsumErrors IgnoredMulti MemErrors
+Udp: 130225 45 0 130398 0 0 0 1201 0
+`
+
+func TestParseTCPSNMP(t *testing.T) {
+	counters, err := parseTCPSNMP(strings.NewReader(snmpFixture))
+	if err != nil {
+		t.Fatalf("parseTCPSNMP() error = %v", err)
+	}
+	want := tcpSegmentCounters{RetransSegs: 4521, OutSegs: 2398815}
+	if counters != want {
+		t.Errorf("parseTCPSNMP() = %+v, want %+v", counters, want)
+	}
+}
+
+func TestParseTCPSNMPErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"no tcp section", "Udp: InDatagrams NoPorts\nUdp: 1 2\n"},
+		{"header only", "Tcp: RtoAlgorithm OutSegs RetransSegs\n"},
+		{"missing counter", "Tcp: RtoAlgorithm OutSegs\nTcp: 1 200\n"},
+		{"truncated values", "Tcp: RtoAlgorithm OutSegs RetransSegs\nTcp: 1 200\n"},
+		{"invalid value", "Tcp: RtoAlgorithm OutSegs RetransSegs\nTcp: 1 lots 3\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if counters, err := parseTCPSNMP(strings.NewReader(tt.input)); err == nil {
+				t.Errorf("parseTCPSNMP() = %+v, want error", counters)
+			}
+		})
+	}
+}
+
+func TestReadTCPSegmentCounters(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skipf("%s is only available on linux", procNetSNMP)
+	}
+	counters, err := readTCPSegmentCounters()
+	if err != nil {
+		t.Skipf("cannot read %s: %v", procNetSNMP, err)
+	}
+	if counters.OutSegs < 0 || counters.RetransSegs < 0 {
+		t.Errorf("negative counters %+v", counters)
+	}
+}
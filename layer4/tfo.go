@@ -0,0 +1,125 @@
+package layer4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// tfoProbeData is sent in the SYN of the TFO probe. A bare CRLF is ignored by
+// most line based protocols, HTTP included.
+var tfoProbeData = []byte("\r\n")
+
+// errTFOProbeUnsupported is returned where the server side of TFO can't be
+// probed
+var errTFOProbeUnsupported = errors.New("TFO server probing is only supported on linux")
+
+// tfoKernelSupported reports whether the kernel has client side TCP Fast
+// Open enabled
+func tfoKernelSupported(ctx context.Context) (bool, error) {
+	switch runtime.GOOS {
+	case "linux":
+		// Bit 0x1 enables TFO for outgoing connections
+		data, err := os.ReadFile("/proc/sys/net/ipv4/tcp_fastopen")
+		if err != nil {
+			return false, fmt.Errorf("failed to read tcp_fastopen setting: %w", err)
+		}
+		mode, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return false, fmt.Errorf("invalid tcp_fastopen setting %q", strings.TrimSpace(string(data)))
+		}
+		return mode&0x1 != 0, nil
+	case "darwin":
+		// Bit 0x2 enables TFO for outgoing connections
+		output, err := exec.CommandContext(ctx, "sysctl", "-n", "net.inet.tcp.fastopen").Output()
+		if err != nil {
+			return false, fmt.Errorf("failed to read net.inet.tcp.fastopen: %w", err)
+		}
+		mode, err := strconv.Atoi(strings.TrimSpace(string(output)))
+		if err != nil {
+			return false, fmt.Errorf("invalid net.inet.tcp.fastopen setting %q", strings.TrimSpace(string(output)))
+		}
+		return mode&0x2 != 0, nil
+	case "windows":
+		output, err := exec.CommandContext(ctx, "reg", "query",
+			`HKLM\SYSTEM\CurrentControlSet\Services\Tcpip\Parameters`, "/v", "EnableFastOpen").Output()
+		if err != nil {
+			// reg fails when the value is not set
+			return false, nil
+		}
+		return parseRegDWORD(string(output), "EnableFastOpen") != 0, nil
+	}
+	return false, fmt.Errorf("TFO detection is not supported on %s", runtime.GOOS)
+}
+
+// parseRegDWORD returns the REG_DWORD value name from `reg query` output, or
+// 0 when it is not present
+func parseRegDWORD(output, name string) uint64 {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && strings.EqualFold(fields[0], name) && fields[1] == "REG_DWORD" {
+			value, err := strconv.ParseUint(strings.TrimPrefix(fields[2], "0x"), 16, 32)
+			if err == nil {
+				return value
+			}
+		}
+	}
+	return 0
+}
+
+// testTFO checks whether TCP Fast Open is enabled locally and whether the
+// first TCP address accepts data in the SYN. The result is informational:
+// it warns at worst.
+func (r *Runner) testTFO(ctx context.Context) common.TestResult {
+	result := common.TestResult{
+		Layer:     4,
+		Name:      "TCP Fast Open Detection",
+		StartTime: time.Now(),
+	}
+
+	diagnostics := &common.TransportDiagnostics{}
+	result.Diagnostics.Transport = diagnostics
+
+	kernel, err := tfoKernelSupported(ctx)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("Could not determine TFO support: %v", err))
+	}
+	diagnostics.TFOKernelSupported = &kernel
+	if !kernel {
+		return result.Finish(common.StatusSkipped, "TCP Fast Open is not enabled for outgoing connections in the kernel")
+	}
+
+	if len(r.TCPAddresses) == 0 {
+		return result.Finish(common.StatusSkipped, "TCP Fast Open is enabled in the kernel; no TCP address to probe the server side")
+	}
+	addr := r.TCPAddresses[0]
+	diagnostics.Target = addr
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	server, err := probeTFOServer(ctx, addr, timeout)
+	if errors.Is(err, errTFOProbeUnsupported) {
+		return result.Finish(common.StatusSkipped, fmt.Sprintf("TCP Fast Open is enabled in the kernel; %v", err))
+	}
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusWarning, fmt.Sprintf("TCP Fast Open probe of %s failed: %v", addr, err))
+	}
+	diagnostics.TFOServerSupported = &server
+
+	if !server {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("TCP Fast Open is enabled in the kernel but %s did not accept data in the SYN", addr))
+	}
+	return result.Finish(common.StatusPassed, fmt.Sprintf("TCP Fast Open is enabled in the kernel and %s accepted data in the SYN", addr))
+}
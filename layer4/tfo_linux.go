@@ -0,0 +1,102 @@
+package layer4
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// tcp_info values from the kernel's tcp.h and tcp_states.h
+const (
+	tcpiOptSynData = 0x20 // Set once the server acknowledged data sent in the SYN
+	tcpSynSent     = 2
+)
+
+// tfoDialer returns a dialer whose connections use TCP_FASTOPEN_CONNECT, so
+// the SYN carries a cookie request, or the first write when a cookie is
+// cached
+func tfoDialer(timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			if err := c.Control(func(fd uintptr) {
+				sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_FASTOPEN_CONNECT, 1)
+			}); err != nil {
+				return err
+			}
+			if sockErr != nil {
+				return fmt.Errorf("failed to enable TCP_FASTOPEN_CONNECT: %w", sockErr)
+			}
+			return nil
+		},
+	}
+}
+
+// tcpInfo reads the kernel's TCP state for conn
+func tcpInfo(conn net.Conn) (*unix.TCPInfo, error) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil, fmt.Errorf("not a TCP connection")
+	}
+	rc, err := tcpConn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var info *unix.TCPInfo
+	var sockErr error
+	if err := rc.Control(func(fd uintptr) {
+		info, sockErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil {
+		return nil, err
+	}
+	return info, sockErr
+}
+
+// probeTFOServer reports whether addr accepts data in the SYN. A first
+// connection obtains a TFO cookie from the server; a second sends probe data
+// with that cookie and checks whether the SYN-ACK acknowledged it.
+func probeTFOServer(ctx context.Context, addr string, timeout time.Duration) (bool, error) {
+	dialer := tfoDialer(timeout)
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return false, fmt.Errorf("cookie request connection failed: %w", err)
+	}
+	conn.Close()
+
+	conn, err = dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return false, fmt.Errorf("TFO connection failed: %w", err)
+	}
+	defer conn.Close()
+
+	// With a cached cookie the SYN is only sent with this write
+	conn.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := conn.Write(tfoProbeData); err != nil {
+		return false, fmt.Errorf("failed to send SYN data: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		info, err := tcpInfo(conn)
+		if err != nil {
+			return false, fmt.Errorf("failed to read TCP_INFO: %w", err)
+		}
+		if info.State != tcpSynSent {
+			return info.Options&tcpiOptSynData != 0, nil
+		}
+		if time.Now().After(deadline) {
+			return false, fmt.Errorf("handshake with %s did not complete within %v", addr, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
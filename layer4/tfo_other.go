@@ -0,0 +1,13 @@
+//go:build !linux
+
+package layer4
+
+import (
+	"context"
+	"time"
+)
+
+// probeTFOServer is only implemented on linux
+func probeTFOServer(ctx context.Context, addr string, timeout time.Duration) (bool, error) {
+	return false, errTFOProbeUnsupported
+}
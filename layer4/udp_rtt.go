@@ -0,0 +1,242 @@
+package layer4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"ghostshell/app/layers/common"
+)
+
+// defaultUDPSamples is the number of RTT probes used when UDPSamples is unset
+const defaultUDPSamples = 10
+
+// defaultUDPProbeTimeout bounds each probe when the runner has no timeout
+const defaultUDPProbeTimeout = 2 * time.Second
+
+// udpProbePayload is echoed back by the loopback echo server
+var udpProbePayload = []byte("UDP RTT probe")
+
+// rttStats summarises the round trip times of a set of probes
+type rttStats struct {
+	min, avg, max time.Duration
+	sent, lost    int
+}
+
+// probeRTTs runs probe samples times and summarises the round trip times of
+// the probes that got a reply. The last probe error is returned when none
+// did.
+func probeRTTs(ctx context.Context, samples int, probe func() (time.Duration, error)) (rttStats, error) {
+	var stats rttStats
+	var total time.Duration
+	var lastErr error
+	for i := 0; i < samples; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		stats.sent++
+		rtt, err := probe()
+		if err != nil {
+			stats.lost++
+			lastErr = err
+			continue
+		}
+		if stats.min == 0 || rtt < stats.min {
+			stats.min = rtt
+		}
+		if rtt > stats.max {
+			stats.max = rtt
+		}
+		total += rtt
+	}
+	if received := stats.sent - stats.lost; received > 0 {
+		stats.avg = total / time.Duration(received)
+		return stats, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no probes sent")
+	}
+	return stats, lastErr
+}
+
+// startUDPEchoServer listens on a random loopback port and echoes every
+// packet back to its sender until the returned conn is closed
+func startUDPEchoServer() (net.PacketConn, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start UDP echo server: %w", err)
+	}
+	go func() {
+		defer common.RecoverTestGoroutine(4, common.Logger, nil)()
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo(buf[:n], addr)
+		}
+	}()
+	return conn, nil
+}
+
+// probeDeadline returns the deadline for a probe's reply: the runner timeout
+// shared across all samples, and never later than ctx's deadline
+func (r *Runner) probeDeadline(ctx context.Context) time.Time {
+	timeout := defaultUDPProbeTimeout
+	if r.Timeout > 0 {
+		timeout = r.Timeout
+	}
+	deadline := time.Now().Add(timeout / time.Duration(r.samples()))
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline
+	}
+	return deadline
+}
+
+// samples returns the number of RTT probes to send
+func (r *Runner) samples() int {
+	if r.UDPSamples > 0 {
+		return r.UDPSamples
+	}
+	return defaultUDPSamples
+}
+
+// checkLoopbackUDPRTT measures the round trip time of UDPSamples probes to a
+// local echo server
+func (r *Runner) checkLoopbackUDPRTT(ctx context.Context) (rttStats, error) {
+	server, err := startUDPEchoServer()
+	if err != nil {
+		return rttStats{}, err
+	}
+	defer server.Close()
+
+	conn, err := net.Dial("udp", server.LocalAddr().String())
+	if err != nil {
+		return rttStats{}, fmt.Errorf("failed to dial UDP echo server: %w", err)
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 2048)
+	return probeRTTs(ctx, r.samples(), func() (time.Duration, error) {
+		conn.SetDeadline(r.probeDeadline(ctx))
+		start := time.Now()
+		if _, err := conn.Write(udpProbePayload); err != nil {
+			return 0, err
+		}
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		if string(buf[:n]) != string(udpProbePayload) {
+			return 0, errors.New("echo mismatch")
+		}
+		return time.Since(start), nil
+	})
+}
+
+// checkExternalUDPRTT sends a DNS query for type ANY of the root zone to addr
+// and returns the time until the matching reply arrives
+func (r *Runner) checkExternalUDPRTT(ctx context.Context, addr string) (time.Duration, error) {
+	id := uint16(rand.Intn(1 << 16))
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: dnsmessage.MustNewName("."), Type: dnsmessage.TypeALL, Class: dnsmessage.ClassINET},
+		},
+	}
+	query, err := msg.Pack()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build DNS query: %w", err)
+	}
+
+	deadline := r.probeDeadline(ctx)
+	dialer := &net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(deadline)
+
+	start := time.Now()
+	if _, err := conn.Write(query); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		var parser dnsmessage.Parser
+		header, err := parser.Start(buf[:n])
+		if err != nil || header.ID != id || !header.Response {
+			// Ignore stray or malformed packets until the deadline
+			continue
+		}
+		return time.Since(start), nil
+	}
+}
+
+// rttResult builds an RTT sub-test result from stats
+func rttResult(result common.TestResult, stats rttStats) common.TestResult {
+	result.Metrics.Latency = stats.avg
+	if stats.sent > 0 {
+		result.Metrics.PacketLoss = float64(stats.lost) / float64(stats.sent) * 100
+	}
+	result.Metrics.Custom = map[string]interface{}{
+		"rtt_min_ms": float64(stats.min.Microseconds()) / 1000,
+		"rtt_avg_ms": float64(stats.avg.Microseconds()) / 1000,
+		"rtt_max_ms": float64(stats.max.Microseconds()) / 1000,
+		"probes":     stats.sent,
+		"lost":       stats.lost,
+	}
+	return result
+}
+
+// testUDPLoopbackRTT measures the UDP round trip time through the local
+// stack using an echo server on a random loopback port
+func (r *Runner) testUDPLoopbackRTT(ctx context.Context) common.TestResult {
+	result := common.TestResult{
+		Layer:     4,
+		Name:      "UDP Loopback RTT",
+		StartTime: time.Now(),
+	}
+
+	stats, err := r.checkLoopbackUDPRTT(ctx)
+	result = rttResult(result, stats)
+	if err != nil {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("UDP loopback RTT measurement failed: %v", err))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("UDP loopback RTT over %d probes: min %v, avg %v, max %v, %d lost",
+		stats.sent, stats.min, stats.avg, stats.max, stats.lost))
+}
+
+// testUDPRTT measures the round trip time of DNS queries to UDPAddress. A
+// missing reply is a warning, since UDPAddress need not be a DNS server.
+func (r *Runner) testUDPRTT(ctx context.Context) common.TestResult {
+	result := common.TestResult{
+		Layer:     4,
+		Name:      fmt.Sprintf("UDP RTT (%s)", r.UDPAddress),
+		StartTime: time.Now(),
+	}
+
+	stats, err := probeRTTs(ctx, r.samples(), func() (time.Duration, error) {
+		return r.checkExternalUDPRTT(ctx, r.UDPAddress)
+	})
+	result = rttResult(result, stats)
+	if err != nil {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("No DNS reply from %s: %v", r.UDPAddress, err))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("UDP RTT to %s over %d DNS queries: min %v, avg %v, max %v, %d lost",
+		r.UDPAddress, stats.sent, stats.min, stats.avg, stats.max, stats.lost))
+}
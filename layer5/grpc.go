@@ -0,0 +1,96 @@
+package layer5
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"ghostshell/app/layers/common"
+)
+
+// GRPCTarget is a gRPC server to health check
+type GRPCTarget = common.GRPCTarget
+
+// testGRPCHealth connects to target and calls grpc.health.v1.Health/Check
+func (r *Runner) testGRPCHealth(ctx context.Context, target GRPCTarget) common.TestResult {
+	result := common.TestResult{
+		Layer:     5,
+		Name:      fmt.Sprintf("gRPC Health Check (%s/%s)", target.Address, target.ServiceName),
+		StartTime: time.Now(),
+	}
+
+	diagnostics := &common.SessionDiagnostics{
+		Address: target.Address,
+		Service: target.ServiceName,
+		TLS:     common.Ptr(target.TLSEnabled),
+	}
+	result.Diagnostics.Session = diagnostics
+
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	creds := insecure.NewCredentials()
+	if target.TLSEnabled {
+		creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: target.InsecureSkipVerify})
+	}
+
+	conn, err := grpc.NewClient(target.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("gRPC dial to %s failed: %v", target.Address, err))
+	}
+	defer conn.Close()
+
+	// Connect eagerly so the connection time is measured separately from the RPC
+	connectStart := time.Now()
+	conn.Connect()
+	for state := conn.GetState(); state != connectivity.Ready; state = conn.GetState() {
+		if state == connectivity.TransientFailure || !conn.WaitForStateChange(ctx, state) {
+			err := ctx.Err()
+			if err == nil {
+				err = fmt.Errorf("connection entered %s", state)
+			}
+			diagnostics.Error = err.Error()
+			return result.Finish(common.StatusFailed, fmt.Sprintf("gRPC connection to %s failed: %v", target.Address, err))
+		}
+	}
+	connectTime := time.Since(connectStart)
+
+	rpcStart := time.Now()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: target.ServiceName})
+	rpcTime := time.Since(rpcStart)
+
+	result.Metrics.Latency = connectTime
+	result.Metrics.ResponseTime = rpcTime
+	result.Metrics.Custom = map[string]interface{}{
+		"connect_time_ms": float64(connectTime.Microseconds()) / 1000,
+		"rpc_time_ms":     float64(rpcTime.Microseconds()) / 1000,
+	}
+	diagnostics.ConnectTimeMs = float64(connectTime.Microseconds()) / 1000
+
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("gRPC health check of %s failed: %v", target.Address, err))
+	}
+
+	status := resp.GetStatus()
+	diagnostics.ServingStatus = status.String()
+	result.Metrics.Custom["serving_status"] = status.String()
+
+	switch status {
+	case healthpb.HealthCheckResponse_SERVING:
+		return result.Finish(common.StatusPassed, fmt.Sprintf("gRPC service %q at %s is SERVING (connect %v, check %v)",
+			target.ServiceName, target.Address, connectTime.Round(time.Millisecond), rpcTime.Round(time.Millisecond)))
+	case healthpb.HealthCheckResponse_NOT_SERVING:
+		return result.Finish(common.StatusFailed, fmt.Sprintf("gRPC service %q at %s is NOT_SERVING", target.ServiceName, target.Address))
+	default:
+		return result.Finish(common.StatusWarning, fmt.Sprintf("gRPC service %q at %s reported %s", target.ServiceName, target.Address, status))
+	}
+}
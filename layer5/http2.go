@@ -0,0 +1,124 @@
+package layer5
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"ghostshell/app/layers/common"
+)
+
+// http2Streams is the number of concurrent requests sent to each HTTP/2 target
+const http2Streams = 5
+
+// testHTTP2Multiplexing sends concurrent requests to url over one HTTP/2
+// transport and checks that they were multiplexed onto a single connection
+func (r *Runner) testHTTP2Multiplexing(ctx context.Context, url string) common.TestResult {
+	result := common.TestResult{
+		Layer:     5,
+		Name:      fmt.Sprintf("HTTP/2 Multiplexing Test (%s)", url),
+		StartTime: time.Now(),
+	}
+
+	diagnostics := &common.SessionDiagnostics{URL: url, TLS: common.Ptr(true)}
+	result.Diagnostics.Session = diagnostics
+
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	transport := &http2.Transport{
+		TLSClientConfig: &tls.Config{NextProtos: []string{http2.NextProtoTLS}},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	var (
+		mu          sync.Mutex
+		connections = make(map[string]bool) // Keyed by local address
+		remoteAddrs = make(map[string]bool)
+		multiplexed int
+		errs        []error
+		wg          sync.WaitGroup
+	)
+
+	start := time.Now()
+	for i := 0; i < http2Streams; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer common.RecoverTestGoroutine(5, common.Logger, nil)()
+
+			trace := &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					mu.Lock()
+					connections[info.Conn.LocalAddr().String()] = true
+					remoteAddrs[info.Conn.RemoteAddr().String()] = true
+					mu.Unlock()
+				},
+			}
+			req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, url, nil)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				return
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			mu.Lock()
+			if resp.Proto == "HTTP/2.0" {
+				multiplexed++
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	reused := len(connections) == 1 && multiplexed == http2Streams
+	diagnostics.StreamsMultiplexed = multiplexed
+	diagnostics.ConnectionReuseVerified = &reused
+	diagnostics.DistinctRemoteAddrs = len(remoteAddrs)
+	for addr := range remoteAddrs {
+		diagnostics.RemoteAddr = addr
+		break
+	}
+
+	result.Metrics.Latency = elapsed
+	result.Metrics.Custom = map[string]interface{}{
+		"streams_sent":          http2Streams,
+		"streams_multiplexed":   multiplexed,
+		"connections":           len(connections),
+		"distinct_remote_addrs": len(remoteAddrs),
+		"total_ms":              float64(elapsed.Microseconds()) / 1000,
+	}
+
+	if len(errs) > 0 {
+		diagnostics.Error = errs[0].Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("HTTP/2 requests to %s failed (%d of %d): %v",
+			url, len(errs), http2Streams, errs[0]))
+	}
+	if !reused {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("HTTP/2 requests to %s were not multiplexed: %d responses over HTTP/2 on %d connections to %d addresses",
+			url, multiplexed, len(connections), len(remoteAddrs)))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("%d HTTP/2 streams to %s multiplexed on one connection in %v",
+		multiplexed, url, elapsed.Round(time.Millisecond)))
+}
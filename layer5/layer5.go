@@ -49,7 +49,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		parentResult.Status = common.StatusFailed
 		parentResult.Message = "Test cancelled"
 		parentResult.EndTime = time.Now()
-		return []common.TestResult{parentResult}, ctx.Err()
+		return []common.TestResult{parentResult}, common.NewLayerError(5, ctx.Err())
 	default:
 		var failedTests []string
 
@@ -72,12 +72,93 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 			}
 
 			// Add detailed diagnostics
-			sessionResult.Diagnostics = details
+			sessionResult.Diagnostics.Session = details
 			sessionResult.EndTime = time.Now()
 			sessionResult.Metrics.Duration = sessionResult.EndTime.Sub(sessionResult.StartTime)
 			parentResult.SubResults = append(parentResult.SubResults, sessionResult)
 		}
 
+		// RDP availability tests
+		for _, target := range r.RDPTargets {
+			rdpResult, err := testRDPSession(ctx, target, r.Timeout)
+			if err != nil {
+				failedTests = append(failedTests, rdpResult.Message)
+			} else if r.FlagOpenRDP {
+				flagOpenRDP(&rdpResult, r.RDPServers)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, rdpResult)
+		}
+
+		// Session ticket lifetime measurement
+		if r.MeasureTicketLifetime {
+			ticketTargets := r.TicketTargets
+			if len(ticketTargets) == 0 {
+				ticketTargets = r.Targets
+			}
+			for _, target := range ticketTargets {
+				ticketResult := r.testSessionTicketLifetime(ctx, target)
+				if ticketResult.Status == common.StatusFailed {
+					failedTests = append(failedTests, ticketResult.Message)
+				}
+				parentResult.SubResults = append(parentResult.SubResults, ticketResult)
+			}
+		}
+
+		// SSH key exchange tests
+		for _, target := range r.SSHTargets {
+			sshResult := r.testSSH(ctx, target)
+			if sshResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, sshResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, sshResult)
+		}
+
+		// gRPC health checks
+		for _, target := range r.GRPCTargets {
+			grpcResult := r.testGRPCHealth(ctx, target)
+			if grpcResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, grpcResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, grpcResult)
+		}
+
+		// MQTT publish/subscribe round trips
+		for _, target := range r.MQTTTargets {
+			mqttResult := r.testMQTT(ctx, target)
+			if mqttResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, mqttResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, mqttResult)
+		}
+
+		// WebSocket echo round trips
+		for _, target := range r.WebSocketTargets {
+			wsResult := r.testWebSocket(ctx, target)
+			if wsResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, wsResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, wsResult)
+		}
+
+		// HTTP/2 stream multiplexing
+		for _, url := range r.HTTP2Targets {
+			h2Result := r.testHTTP2Multiplexing(ctx, url)
+			if h2Result.Status == common.StatusFailed {
+				failedTests = append(failedTests, h2Result.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, h2Result)
+		}
+
+		// WireGuard peer status
+		for _, iface := range r.WireGuardInterfaces {
+			for _, wgResult := range r.testWireGuard(ctx, iface) {
+				if wgResult.Status == common.StatusFailed {
+					failedTests = append(failedTests, wgResult.Message)
+				}
+				parentResult.SubResults = append(parentResult.SubResults, wgResult)
+			}
+		}
+
 		// Set overall test status and message
 		if len(failedTests) > 0 {
 			parentResult.Status = common.StatusFailed
@@ -97,47 +178,48 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		parentResult.Metrics.Duration = parentResult.EndTime.Sub(parentResult.StartTime)
 
 		if len(failedTests) > 0 {
-			return []common.TestResult{parentResult}, fmt.Errorf("layer 5 tests failed")
+			return []common.TestResult{parentResult}, &common.LayerError{Layer: 5, Code: common.ErrTestFailed, Cause: fmt.Errorf("layer 5 tests failed")}
 		}
 		return []common.TestResult{parentResult}, nil
 	}
 }
 
 // testSessionEstablishment attempts to establish a session with the target
-func testSessionEstablishment(target string, timeout time.Duration) (bool, string, map[string]interface{}) {
-	// Create diagnostics map
-	diagnostics := make(map[string]interface{})
-	diagnostics["target"] = target
-	diagnostics["timeout"] = timeout.String()
+func testSessionEstablishment(target string, timeout time.Duration) (bool, string, *common.SessionDiagnostics) {
+	// Create diagnostics
+	diagnostics := &common.SessionDiagnostics{
+		Target:  target,
+		Timeout: timeout.String(),
+	}
 
 	// Try to establish TCP connection first (as base for session)
 	conn, err := net.DialTimeout("tcp", target, timeout)
 	if err != nil {
-		diagnostics["error"] = err.Error()
-		diagnostics["connection_state"] = "failed"
+		diagnostics.Error = err.Error()
+		diagnostics.ConnectionState = "failed"
 		return false, fmt.Sprintf("Failed to establish session with %s: %v", target, err), diagnostics
 	}
 	defer conn.Close()
 
 	// Get connection details
 	if tcpConn, ok := conn.(*net.TCPConn); ok {
-		diagnostics["local_addr"] = tcpConn.LocalAddr().String()
-		diagnostics["remote_addr"] = tcpConn.RemoteAddr().String()
+		diagnostics.LocalAddr = tcpConn.LocalAddr().String()
+		diagnostics.RemoteAddr = tcpConn.RemoteAddr().String()
 
 		// Try to get more TCP-specific info
 		if err := tcpConn.SetKeepAlive(true); err == nil {
-			diagnostics["keepalive_enabled"] = true
+			diagnostics.KeepaliveEnabled = true
 		}
 	}
 
 	// Try basic session handshake
 	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
-		diagnostics["error"] = "Failed to set connection deadline"
-		diagnostics["connection_state"] = "unstable"
+		diagnostics.Error = "Failed to set connection deadline"
+		diagnostics.ConnectionState = "unstable"
 		return false, fmt.Sprintf("Session with %s is unstable: failed to set timeout", target), diagnostics
 	}
 
-	diagnostics["connection_state"] = "established"
+	diagnostics.ConnectionState = "established"
 	return true, fmt.Sprintf("Successfully established session with %s", target), diagnostics
 }
 
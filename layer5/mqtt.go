@@ -0,0 +1,172 @@
+package layer5
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"ghostshell/app/layers/common"
+)
+
+// MQTTTarget is an MQTT broker to test with a publish/subscribe round trip
+type MQTTTarget = common.MQTTTarget
+
+// mqttProtocolVersions names the MQTT protocol levels paho negotiates
+var mqttProtocolVersions = map[uint]string{
+	3: "3.1",
+	4: "3.1.1",
+	5: "5.0",
+}
+
+// mqttBrokerURL returns the broker URL paho should dial, switching plain
+// schemes to their TLS equivalents when TLS is enabled
+func mqttBrokerURL(target MQTTTarget) (string, error) {
+	u, err := url.Parse(target.BrokerURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid broker URL: %w", err)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid broker URL %q: missing host", target.BrokerURL)
+	}
+	if target.TLSEnabled {
+		switch u.Scheme {
+		case "mqtt":
+			u.Scheme = "mqtts"
+		case "tcp":
+			u.Scheme = "tls"
+		}
+	}
+	return u.String(), nil
+}
+
+// testMQTT connects to target's broker, subscribes to its topic and
+// publishes a timestamped message, timing how long it takes to echo back
+func (r *Runner) testMQTT(ctx context.Context, target MQTTTarget) common.TestResult {
+	result := common.TestResult{
+		Layer:     5,
+		Name:      fmt.Sprintf("MQTT Publish/Subscribe (%s)", target.BrokerURL),
+		StartTime: time.Now(),
+	}
+
+	clientID := target.ClientID
+	if clientID == "" {
+		clientID = fmt.Sprintf("osi-tester-%d-%d", os.Getpid(), time.Now().UnixNano())
+	}
+	topic := target.Topic
+	if topic == "" {
+		topic = "osi-tester/" + clientID
+	}
+
+	diagnostics := &common.SessionDiagnostics{
+		Broker:   target.BrokerURL,
+		ClientID: clientID,
+		Topic:    topic,
+		QoS:      common.Ptr(target.QoS),
+		TLS:      common.Ptr(target.TLSEnabled),
+	}
+	result.Diagnostics.Session = diagnostics
+
+	if target.QoS > 2 {
+		diagnostics.Error = "invalid QoS"
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Invalid MQTT QoS %d for %s (must be 0, 1 or 2)", target.QoS, target.BrokerURL))
+	}
+
+	brokerURL, err := mqttBrokerURL(target)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("MQTT test of %s failed: %v", target.BrokerURL, err))
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(brokerURL).
+		SetClientID(clientID).
+		SetCleanSession(true).
+		SetAutoReconnect(false).
+		SetConnectRetry(false).
+		SetConnectTimeout(r.Timeout)
+	if target.TLSEnabled {
+		opts.SetTLSConfig(&tls.Config{})
+	}
+	client := mqtt.NewClient(opts)
+
+	connectStart := time.Now()
+	token := client.Connect()
+	if !token.WaitTimeout(r.Timeout) {
+		diagnostics.Error = "connect timed out"
+		return result.Finish(common.StatusFailed, fmt.Sprintf("MQTT connection to %s timed out after %v", target.BrokerURL, r.Timeout))
+	}
+	if err := token.Error(); err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("MQTT connection to %s failed: %v", target.BrokerURL, err))
+	}
+	connectTime := time.Since(connectStart)
+	defer client.Disconnect(250)
+
+	optionsReader := client.OptionsReader()
+	protocolVersion := mqttProtocolVersions[optionsReader.ProtocolVersion()]
+	result.Metrics.Latency = connectTime
+	result.Metrics.Custom = map[string]interface{}{
+		"connect_time_ms":  float64(connectTime.Microseconds()) / 1000,
+		"protocol_version": protocolVersion,
+	}
+	diagnostics.ConnectTimeMs = float64(connectTime.Microseconds()) / 1000
+	diagnostics.ProtocolVersion = protocolVersion
+
+	payload := fmt.Sprintf("osi-tester %s %d", clientID, time.Now().UnixNano())
+	received := make(chan time.Time, 1)
+	token = client.Subscribe(topic, target.QoS, func(_ mqtt.Client, msg mqtt.Message) {
+		if string(msg.Payload()) == payload {
+			select {
+			case received <- time.Now():
+			default:
+			}
+		}
+	})
+	if !token.WaitTimeout(r.Timeout) {
+		diagnostics.Error = "subscribe timed out"
+		return result.Finish(common.StatusFailed, fmt.Sprintf("MQTT subscribe to %s on %s timed out", topic, target.BrokerURL))
+	}
+	if err := token.Error(); err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("MQTT subscribe to %s on %s failed: %v", topic, target.BrokerURL, err))
+	}
+	defer func() {
+		client.Unsubscribe(topic).WaitTimeout(r.Timeout)
+	}()
+
+	publishStart := time.Now()
+	token = client.Publish(topic, target.QoS, false, payload)
+	if !token.WaitTimeout(r.Timeout) {
+		diagnostics.Error = "publish timed out"
+		return result.Finish(common.StatusFailed, fmt.Sprintf("MQTT publish to %s on %s timed out", topic, target.BrokerURL))
+	}
+	if err := token.Error(); err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("MQTT publish to %s on %s failed: %v", topic, target.BrokerURL, err))
+	}
+
+	timer := time.NewTimer(r.Timeout)
+	defer timer.Stop()
+
+	select {
+	case receivedAt := <-received:
+		roundTrip := receivedAt.Sub(publishStart)
+		result.Metrics.ResponseTime = roundTrip
+		result.Metrics.Custom["round_trip_ms"] = float64(roundTrip.Microseconds()) / 1000
+		diagnostics.RoundTripMs = float64(roundTrip.Microseconds()) / 1000
+		return result.Finish(common.StatusPassed, fmt.Sprintf("MQTT round trip via %s succeeded (MQTT %s, connect %v, round trip %v)",
+			target.BrokerURL, protocolVersion, connectTime.Round(time.Millisecond), roundTrip.Round(time.Millisecond)))
+	case <-timer.C:
+		diagnostics.Error = "message not received"
+		return result.Finish(common.StatusFailed, fmt.Sprintf("MQTT message published to %s on %s was not received within %v",
+			topic, target.BrokerURL, r.Timeout))
+	case <-ctx.Done():
+		diagnostics.Error = ctx.Err().Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("MQTT test of %s cancelled: %v", target.BrokerURL, ctx.Err()))
+	}
+}
@@ -0,0 +1,225 @@
+package layer5
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// defaultRDPPort is used when an RDP target does not specify a port
+const defaultRDPPort = "3389"
+
+// RDP security protocols from the RDP Negotiation Response (MS-RDPBCGR 2.2.1.2.1)
+const (
+	rdpProtocolRDP      = 0x00
+	rdpProtocolSSL      = 0x01
+	rdpProtocolHybrid   = 0x02
+	rdpProtocolRDSTLS   = 0x04
+	rdpProtocolHybridEx = 0x08
+)
+
+// Roles of an RDP target. Nothing in the unauthenticated handshake tells a
+// server from a workstation, so a target is only a server when listed as one.
+const (
+	rdpRoleServer       = "server"
+	rdpRoleUnclassified = "unclassified"
+)
+
+// rdpNegotiationTypes identify the structure following the X.224 Connection Confirm
+const (
+	rdpTypeNegRsp     = 0x02
+	rdpTypeNegFailure = 0x03
+)
+
+// rdpConnectionRequest is a TPKT + X.224 Connection Request carrying an RDP
+// Negotiation Request for TLS, CredSSP and CredSSP with Early User Auth
+var rdpConnectionRequest = []byte{
+	// TPKT header: version 3, reserved, length 19
+	0x03, 0x00, 0x00, 0x13,
+	// X.224 Connection Request: length 14, CR code, dst ref, src ref, class 0
+	0x0e, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00,
+	// RDP Negotiation Request: type 1, flags 0, length 8, requested protocols
+	0x01, 0x00, 0x08, 0x00, 0x0b, 0x00, 0x00, 0x00,
+}
+
+// testRDPSession sends an X.224 Connection Request to addr and inspects the
+// Connection Confirm without attempting authentication
+func testRDPSession(ctx context.Context, addr string, timeout time.Duration) (common.TestResult, error) {
+	result := common.TestResult{
+		Layer:     5,
+		Name:      fmt.Sprintf("RDP Session Test (%s)", addr),
+		StartTime: time.Now(),
+	}
+	diagnostics := &common.SessionDiagnostics{}
+	result.Diagnostics.Session = diagnostics
+
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, defaultRDPPort)
+	}
+	diagnostics.Target = addr
+
+	dialer := &net.Dialer{Timeout: timeout}
+	connectStart := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to connect to RDP service at %s: %v", addr, err)), err
+	}
+	defer conn.Close()
+
+	connectTime := time.Since(connectStart)
+	diagnostics.ConnectMs = float64(connectTime.Microseconds()) / 1000
+	result.Metrics.Latency = connectTime
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to set deadline for %s: %v", addr, err)), err
+	}
+
+	responseStart := time.Now()
+	if _, err := conn.Write(rdpConnectionRequest); err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to send RDP connection request to %s: %v", addr, err)), err
+	}
+
+	response, err := readTPKT(conn)
+	diagnostics.ResponseBytes = common.Ptr(len(response))
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("No valid RDP response from %s within %s: %v", addr, timeout, err)), err
+	}
+	diagnostics.ResponseMs = float64(time.Since(responseStart).Microseconds()) / 1000
+
+	hint, protocol, err := parseRDPConnectionConfirm(response)
+	diagnostics.RDPVersionHint = hint
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Invalid RDP response from %s: %v", addr, err)), err
+	}
+	if protocol != "" {
+		diagnostics.SelectedProtocol = protocol
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("RDP service responding at %s (%s)", addr, hint)), nil
+}
+
+// rdpHostRole classifies addr as a server when its host is in servers
+func rdpHostRole(addr string, servers []string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	for _, server := range servers {
+		if h, _, err := net.SplitHostPort(server); err == nil {
+			server = h
+		}
+		if strings.EqualFold(host, server) {
+			return rdpRoleServer
+		}
+	}
+	return rdpRoleUnclassified
+}
+
+// flagOpenRDP warns about a responding RDP target that isn't a known server,
+// since it can't be told apart from a workstation exposing RDP to the network
+func flagOpenRDP(result *common.TestResult, servers []string) {
+	diagnostics := result.Diagnostics.Session
+	diagnostics.RDPHostRole = rdpHostRole(diagnostics.Target, servers)
+	if diagnostics.RDPHostRole == rdpRoleServer {
+		return
+	}
+	result.Status = common.StatusWarning
+	result.Message = fmt.Sprintf("%s; RDP is exposed to the network on a host not listed in rdp_servers, "+
+		"which may be a workstation", result.Message)
+}
+
+// readTPKT reads a single TPKT framed packet
+func readTPKT(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read TPKT header: %w", err)
+	}
+	if header[0] != 0x03 {
+		return header, fmt.Errorf("unexpected TPKT version 0x%02x", header[0])
+	}
+
+	length := int(binary.BigEndian.Uint16(header[2:4]))
+	if length < 4 {
+		return header, fmt.Errorf("invalid TPKT length %d", length)
+	}
+
+	packet := make([]byte, length)
+	copy(packet, header)
+	n, err := io.ReadFull(r, packet[4:])
+	if err != nil {
+		return packet[:4+n], fmt.Errorf("failed to read TPKT payload: %w", err)
+	}
+	return packet, nil
+}
+
+// parseRDPConnectionConfirm validates an X.224 Connection Confirm and derives a
+// server version hint from the negotiated security protocol and flags
+func parseRDPConnectionConfirm(packet []byte) (string, string, error) {
+	if len(packet) < 11 {
+		return "unknown", "", fmt.Errorf("response too short (%d bytes)", len(packet))
+	}
+
+	// X.224 Connection Confirm code is 0xD0 in the upper nibble
+	if packet[5]&0xf0 != 0xd0 {
+		return "unknown", "", fmt.Errorf("expected X.224 Connection Confirm, got code 0x%02x", packet[5])
+	}
+
+	// Servers predating RDP 5.2 do not send a negotiation response
+	if len(packet) < 19 {
+		return "RDP 5.x or earlier (no negotiation support)", "rdp", nil
+	}
+
+	negType := packet[11]
+	flags := packet[12]
+	value := binary.LittleEndian.Uint32(packet[15:19])
+
+	switch negType {
+	case rdpTypeNegRsp:
+		protocol := rdpProtocolName(value)
+		hint := "RDP 5.2+"
+		switch {
+		case flags&0x02 != 0:
+			hint = "RDP 8.0+ (graphics pipeline supported)"
+		case value == rdpProtocolHybridEx:
+			hint = "RDP 8.1+ (early user authorization)"
+		case value == rdpProtocolHybrid:
+			hint = "RDP 6.0+ (NLA)"
+		case flags&0x01 != 0:
+			hint = "RDP 6.0+"
+		}
+		return hint, protocol, nil
+	case rdpTypeNegFailure:
+		return fmt.Sprintf("negotiation failure (code %d)", value), "", nil
+	default:
+		return "unknown", "", fmt.Errorf("unexpected negotiation type 0x%02x", negType)
+	}
+}
+
+// rdpProtocolName returns a readable name for a selected RDP security protocol
+func rdpProtocolName(protocol uint32) string {
+	switch protocol {
+	case rdpProtocolRDP:
+		return "rdp"
+	case rdpProtocolSSL:
+		return "tls"
+	case rdpProtocolHybrid:
+		return "credssp"
+	case rdpProtocolRDSTLS:
+		return "rdstls"
+	case rdpProtocolHybridEx:
+		return "credssp_early_auth"
+	default:
+		return fmt.Sprintf("unknown (0x%x)", protocol)
+	}
+}
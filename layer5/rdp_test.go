@@ -0,0 +1,36 @@
+package layer5
+
+import (
+	"testing"
+
+	"ghostshell/app/layers/common"
+)
+
+func TestFlagOpenRDP(t *testing.T) {
+	servers := []string{"ts01.example.com", "10.0.0.5:3390"}
+	tests := []struct {
+		target   string
+		wantRole string
+		want     common.TestStatus
+	}{
+		{"ts01.example.com:3389", rdpRoleServer, common.StatusPassed},
+		{"TS01.example.com:3389", rdpRoleServer, common.StatusPassed},
+		{"10.0.0.5:3389", rdpRoleServer, common.StatusPassed},
+		{"laptop.example.com:3389", rdpRoleUnclassified, common.StatusWarning},
+		{"10.0.0.6:3389", rdpRoleUnclassified, common.StatusWarning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			result := common.TestResult{Status: common.StatusPassed, Message: "RDP service responding"}
+			result.Diagnostics.Session = &common.SessionDiagnostics{Target: tt.target}
+
+			flagOpenRDP(&result, servers)
+			if role := result.Diagnostics.Session.RDPHostRole; role != tt.wantRole {
+				t.Errorf("role %q, want %q", role, tt.wantRole)
+			}
+			if result.Status != tt.want {
+				t.Errorf("status %s, want %s: %s", result.Status, tt.want, result.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package layer5
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// maxRecommendedTicketLifetime is the RFC 5077 recommended upper bound on ticket lifetime
+const maxRecommendedTicketLifetime = 24 * time.Hour
+
+// Defaults for session ticket lifetime measurement
+const (
+	defaultTicketMaxWait      = 10 * time.Minute
+	defaultTicketPollInterval = 30 * time.Second
+)
+
+// ticketDeadlineMargin is kept free before ctx's deadline for the last
+// resumption handshake
+const ticketDeadlineMargin = 2 * time.Second
+
+// ticketReadWait is how long to wait for a TLS 1.3 ticket after the handshake
+const ticketReadWait = 500 * time.Millisecond
+
+// SessionTicketLifetime records how long a server honoured a TLS session ticket
+type SessionTicketLifetime struct {
+	Issued     time.Time     `json:"issued"`
+	Expired    time.Time     `json:"expired"`
+	Lifetime   time.Duration `json:"lifetime"`
+	StillValid bool          `json:"still_valid"`
+	// MaxWait and PollInterval are the values used after fitting them to ctx's deadline
+	MaxWait      time.Duration `json:"max_wait"`
+	PollInterval time.Duration `json:"poll_interval"`
+}
+
+// firstTicketCache is a ClientSessionCache that keeps only the first ticket it
+// is given, so tickets reissued on resumption don't extend the measurement
+type firstTicketCache struct {
+	mu      sync.Mutex
+	session *tls.ClientSessionState
+	issued  time.Time
+}
+
+func (c *firstTicketCache) Get(sessionKey string) (*tls.ClientSessionState, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session, c.session != nil
+}
+
+func (c *firstTicketCache) Put(sessionKey string, cs *tls.ClientSessionState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session == nil && cs != nil {
+		c.session = cs
+		c.issued = time.Now()
+	}
+}
+
+// ticket returns the cached ticket's issue time, or false if none was received
+func (c *firstTicketCache) ticket() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.issued, c.session != nil
+}
+
+// MeasureSessionTicketLifetime obtains a session ticket from addr and then
+// resumes with it every pollInterval until the server stops accepting it or
+// maxWait elapses. maxWait is shortened to end ticketDeadlineMargin before
+// ctx's deadline, and pollInterval to fit at least one resumption in it.
+func MeasureSessionTicketLifetime(ctx context.Context, addr string, maxWait time.Duration, pollInterval time.Duration) (SessionTicketLifetime, error) {
+	var lifetime SessionTicketLifetime
+
+	if maxWait <= 0 {
+		maxWait = defaultTicketMaxWait
+	}
+	if pollInterval <= 0 {
+		pollInterval = defaultTicketPollInterval
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline) - ticketDeadlineMargin; remaining < maxWait {
+			maxWait = remaining
+		}
+	}
+	if maxWait <= 0 {
+		return lifetime, fmt.Errorf("too close to the deadline to measure the session ticket lifetime")
+	}
+	if pollInterval > maxWait {
+		pollInterval = maxWait
+	}
+	lifetime.MaxWait = maxWait
+	lifetime.PollInterval = pollInterval
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return lifetime, fmt.Errorf("invalid address %s: %w", addr, err)
+	}
+
+	cache := &firstTicketCache{}
+	config := &tls.Config{
+		ServerName:         host,
+		ClientSessionCache: cache,
+	}
+
+	if _, err := ticketHandshake(ctx, addr, config); err != nil {
+		return lifetime, fmt.Errorf("initial handshake failed: %w", err)
+	}
+
+	issued, ok := cache.ticket()
+	if !ok {
+		return lifetime, fmt.Errorf("server did not issue a session ticket")
+	}
+	lifetime.Issued = issued
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return lifetime, ctx.Err()
+		case <-ticker.C:
+		}
+
+		resumed, err := ticketHandshake(ctx, addr, config)
+		if err != nil {
+			return lifetime, fmt.Errorf("resumption attempt failed: %w", err)
+		}
+
+		now := time.Now()
+		if !resumed {
+			lifetime.Expired = now
+			lifetime.Lifetime = now.Sub(lifetime.Issued)
+			return lifetime, nil
+		}
+
+		// Stop once another poll would run past maxWait
+		if now.Add(pollInterval).Sub(lifetime.Issued) > maxWait {
+			lifetime.StillValid = true
+			lifetime.Lifetime = now.Sub(lifetime.Issued)
+			return lifetime, nil
+		}
+	}
+}
+
+// ticketHandshake completes a TLS handshake with addr and reports whether the
+// session was resumed. A read error other than the wait for a TLS 1.3
+// ticket timing out is returned.
+func ticketHandshake(ctx context.Context, addr string, config *tls.Config) (bool, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: 10 * time.Second},
+		Config:    config,
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	tlsConn := conn.(*tls.Conn)
+
+	// TLS 1.3 tickets arrive after the handshake, so give the server a moment to send them
+	readDeadline := time.Now().Add(ticketReadWait)
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(readDeadline) {
+		readDeadline = deadline
+	}
+	if err := tlsConn.SetReadDeadline(readDeadline); err != nil {
+		return false, err
+	}
+	if _, err := tlsConn.Read(make([]byte, 1)); err != nil && !isTimeout(err) {
+		return false, fmt.Errorf("reading after handshake: %w", err)
+	}
+
+	return tlsConn.ConnectionState().DidResume, nil
+}
+
+// isTimeout reports whether err is a timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// testSessionTicketLifetime wraps MeasureSessionTicketLifetime in a test result
+func (r *Runner) testSessionTicketLifetime(ctx context.Context, addr string) common.TestResult {
+	result := common.TestResult{
+		Layer:     5,
+		Name:      fmt.Sprintf("Session Ticket Lifetime Test (%s)", addr),
+		StartTime: time.Now(),
+	}
+	diagnostics := &common.SessionDiagnostics{
+		Target:         addr,
+		MaxWaitS:       r.MaxWait.Seconds(),
+		PollIntervalS:  r.PollInterval.Seconds(),
+		RecommendedMax: maxRecommendedTicketLifetime.String(),
+	}
+	result.Diagnostics.Session = diagnostics
+
+	lifetime, err := MeasureSessionTicketLifetime(ctx, addr, r.MaxWait, r.PollInterval)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to measure session ticket lifetime for %s: %v", addr, err))
+	}
+
+	diagnostics.MaxWaitS = lifetime.MaxWait.Seconds()
+	diagnostics.PollIntervalS = lifetime.PollInterval.Seconds()
+	diagnostics.Issued = &lifetime.Issued
+	diagnostics.LifetimeS = common.Ptr(lifetime.Lifetime.Seconds())
+	diagnostics.StillValid = common.Ptr(lifetime.StillValid)
+	if !lifetime.Expired.IsZero() {
+		diagnostics.Expired = &lifetime.Expired
+	}
+
+	if lifetime.Lifetime > maxRecommendedTicketLifetime {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("Session ticket for %s was accepted for %s, "+
+			"exceeding the RFC 5077 recommended maximum of 24h and widening the session hijacking window",
+			addr, lifetime.Lifetime.Round(time.Second)))
+	}
+
+	if lifetime.StillValid {
+		return result.Finish(common.StatusPassed, fmt.Sprintf("Session ticket for %s still valid after %s",
+			addr, lifetime.Lifetime.Round(time.Second)))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("Session ticket for %s expired after %s",
+		addr, lifetime.Lifetime.Round(time.Second)))
+}
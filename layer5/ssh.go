@@ -0,0 +1,348 @@
+package layer5
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"ghostshell/app/layers/common"
+)
+
+// SSH test settings
+const (
+	defaultSSHPort = "22"
+	defaultSSHUser = "ghostsuite"
+	// sshRecordLimit bounds how much of each direction is kept to find the
+	// KEXINIT messages, which are the first packets after the version banner
+	sshRecordLimit  = 64 * 1024
+	sshMsgKexInit   = 20
+	sshKexCookieLen = 16
+)
+
+// SessionResult is the outcome of an SSH key exchange with a server
+type SessionResult struct {
+	Addr              string        `json:"addr"`
+	ServerVersion     string        `json:"server_version,omitempty"`
+	HostKeyType       string        `json:"host_key_type,omitempty"`
+	Fingerprint       string        `json:"fingerprint,omitempty"`
+	KeyExchange       string        `json:"key_exchange,omitempty"`
+	Cipher            string        `json:"cipher,omitempty"`
+	MAC               string        `json:"mac,omitempty"`
+	HandshakeDuration time.Duration `json:"handshake_duration"`
+	HostKeyVerified   bool          `json:"host_key_verified"`
+	Authenticated     bool          `json:"authenticated"`
+	Err               error         `json:"-"`
+}
+
+// KeyExchanged reports whether the server's host key was received
+func (s SessionResult) KeyExchanged() bool {
+	return s.Fingerprint != ""
+}
+
+// kexInit holds the algorithm name-lists of an SSH_MSG_KEXINIT (RFC 4253 7.1)
+type kexInit struct {
+	kex                 []string
+	hostKey             []string
+	ciphersClientServer []string
+	ciphersServerClient []string
+	macsClientServer    []string
+	macsServerClient    []string
+}
+
+// testSSHSession connects to addr and performs the SSH key exchange and,
+// when authMethods are given, user authentication. No session channel is
+// opened and no commands are run. The host key is checked against
+// KnownHostsPath when it is set and accepted otherwise.
+func (r *Runner) testSSHSession(ctx context.Context, addr, user string, authMethods []ssh.AuthMethod) SessionResult {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, defaultSSHPort)
+	}
+	result := SessionResult{Addr: addr}
+	timeout := r.Timeout
+
+	var hostKeyCallback ssh.HostKeyCallback
+	if r.KnownHostsPath != "" {
+		callback, err := knownhosts.New(expandHome(r.KnownHostsPath))
+		if err != nil {
+			result.Err = fmt.Errorf("failed to load known hosts %s: %w", r.KnownHostsPath, err)
+			return result
+		}
+		hostKeyCallback = callback
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	start := time.Now()
+	rawConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to connect: %w", err)
+		return result
+	}
+	conn := &recordingConn{Conn: rawConn}
+	defer conn.Close()
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	config := &ssh.ClientConfig{
+		User: user,
+		Auth: authMethods,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			// The host key arrives with the server's key exchange reply
+			result.HandshakeDuration = time.Since(start)
+			result.HostKeyType = key.Type()
+			result.Fingerprint = ssh.FingerprintSHA256(key)
+			if hostKeyCallback == nil {
+				return nil
+			}
+			if err := hostKeyCallback(hostname, remote, key); err != nil {
+				return err
+			}
+			result.HostKeyVerified = true
+			return nil
+		},
+		Timeout: timeout,
+	}
+
+	sshConn, _, _, err := ssh.NewClientConn(conn, addr, config)
+	if sshConn != nil {
+		result.ServerVersion = string(sshConn.ServerVersion())
+		result.Authenticated = true
+		sshConn.Close()
+	}
+	result.Err = err
+
+	// Work out what was negotiated from both KEXINIT messages
+	clientStream, serverStream := conn.recorded()
+	client, clientOK := parseKexInit(clientStream)
+	server, serverOK := parseKexInit(serverStream)
+	if clientOK && serverOK {
+		result.KeyExchange = negotiateAlgorithm(client.kex, server.kex)
+		result.Cipher = negotiateAlgorithm(client.ciphersClientServer, server.ciphersClientServer)
+		result.MAC = negotiateAlgorithm(client.macsClientServer, server.macsClientServer)
+	}
+	if result.ServerVersion == "" {
+		result.ServerVersion = versionBanner(serverStream)
+	}
+
+	return result
+}
+
+// testSSH runs testSSHSession against addr and reports it as a sub-test
+func (r *Runner) testSSH(ctx context.Context, addr string) common.TestResult {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, defaultSSHPort)
+	}
+
+	result := common.TestResult{
+		Layer:     5,
+		Name:      fmt.Sprintf("SSH Session Test (%s)", addr),
+		StartTime: time.Now(),
+	}
+
+	authMethods, err := r.sshAuthMethods()
+	if err != nil {
+		result.Diagnostics.Session = &common.SessionDiagnostics{Target: addr, Error: err.Error()}
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to load SSH key for %s: %v", addr, err))
+	}
+
+	user := r.SSHUser
+	if user == "" {
+		user = defaultSSHUser
+	}
+
+	session := r.testSSHSession(ctx, addr, user, authMethods)
+	result.Metrics.Latency = session.HandshakeDuration
+	diagnostics := &common.SessionDiagnostics{
+		Target:          addr,
+		ServerVersion:   session.ServerVersion,
+		HostKeyType:     session.HostKeyType,
+		Fingerprint:     session.Fingerprint,
+		KeyExchange:     session.KeyExchange,
+		Cipher:          session.Cipher,
+		MAC:             session.MAC,
+		HandshakeMs:     float64(session.HandshakeDuration.Microseconds()) / 1000,
+		HostKeyVerified: common.Ptr(session.HostKeyVerified),
+		Authenticated:   common.Ptr(session.Authenticated),
+	}
+	if session.Err != nil {
+		diagnostics.Error = session.Err.Error()
+	}
+	result.Diagnostics.Session = diagnostics
+
+	switch {
+	case !session.KeyExchanged():
+		return result.Finish(common.StatusFailed, fmt.Sprintf("SSH key exchange with %s failed: %v", addr, session.Err))
+	case r.KnownHostsPath != "" && !session.HostKeyVerified:
+		return result.Finish(common.StatusFailed, fmt.Sprintf("SSH host key for %s (%s) failed verification: %v",
+			addr, session.Fingerprint, session.Err))
+	case session.Err != nil && len(authMethods) > 0:
+		return result.Finish(common.StatusWarning, fmt.Sprintf("SSH key exchange with %s succeeded but authentication as %s failed: %v",
+			addr, user, session.Err))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("SSH key exchange with %s succeeded in %v using %s (host key %s)",
+		addr, session.HandshakeDuration.Round(time.Millisecond), session.Cipher, session.Fingerprint))
+}
+
+// sshAuthMethods returns public key authentication for SSHKeyPath, or none
+// when no key is configured
+func (r *Runner) sshAuthMethods() ([]ssh.AuthMethod, error) {
+	if r.SSHKeyPath == "" {
+		return nil, nil
+	}
+
+	key, err := os.ReadFile(expandHome(r.SSHKeyPath))
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+}
+
+// expandHome replaces a leading ~ with the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// recordingConn keeps a copy of the first bytes read and written so the
+// unencrypted start of the SSH handshake can be inspected afterwards
+type recordingConn struct {
+	net.Conn
+	mu      sync.Mutex
+	written bytes.Buffer
+	read    bytes.Buffer
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.record(&c.read, p[:n])
+	return n, err
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.record(&c.written, p[:n])
+	return n, err
+}
+
+// record appends p to buf up to sshRecordLimit
+func (c *recordingConn) record(buf *bytes.Buffer, p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if room := sshRecordLimit - buf.Len(); room > 0 {
+		if len(p) > room {
+			p = p[:room]
+		}
+		buf.Write(p)
+	}
+}
+
+// recorded returns copies of the bytes written and read so far
+func (c *recordingConn) recorded() ([]byte, []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.written.Bytes()), bytes.Clone(c.read.Bytes())
+}
+
+// versionBanner returns the SSH identification string at the start of stream
+func versionBanner(stream []byte) string {
+	for len(stream) > 0 {
+		line, rest, _ := bytes.Cut(stream, []byte("\n"))
+		if bytes.HasPrefix(line, []byte("SSH-")) {
+			return string(bytes.TrimRight(line, "\r"))
+		}
+		stream = rest
+	}
+	return ""
+}
+
+// parseKexInit finds and decodes the KEXINIT packet that follows the
+// identification string in stream
+func parseKexInit(stream []byte) (kexInit, bool) {
+	// Servers may send other lines before their identification string
+	for {
+		line, rest, found := bytes.Cut(stream, []byte("\n"))
+		if !found {
+			return kexInit{}, false
+		}
+		stream = rest
+		if bytes.HasPrefix(line, []byte("SSH-")) {
+			break
+		}
+	}
+
+	// Binary packet: uint32 length, byte padding length, payload, padding
+	if len(stream) < 5 {
+		return kexInit{}, false
+	}
+	packetLen := int(binary.BigEndian.Uint32(stream[:4]))
+	paddingLen := int(stream[4])
+	payloadLen := packetLen - paddingLen - 1
+	if payloadLen < 1+sshKexCookieLen || len(stream) < 5+payloadLen {
+		return kexInit{}, false
+	}
+	payload := stream[5 : 5+payloadLen]
+	if payload[0] != sshMsgKexInit {
+		return kexInit{}, false
+	}
+
+	fields := payload[1+sshKexCookieLen:]
+	lists := make([][]string, 6)
+	for i := range lists {
+		if len(fields) < 4 {
+			return kexInit{}, false
+		}
+		n := int(binary.BigEndian.Uint32(fields[:4]))
+		if len(fields) < 4+n {
+			return kexInit{}, false
+		}
+		if n > 0 {
+			lists[i] = strings.Split(string(fields[4:4+n]), ",")
+		}
+		fields = fields[4+n:]
+	}
+
+	return kexInit{
+		kex:                 lists[0],
+		hostKey:             lists[1],
+		ciphersClientServer: lists[2],
+		ciphersServerClient: lists[3],
+		macsClientServer:    lists[4],
+		macsServerClient:    lists[5],
+	}, true
+}
+
+// negotiateAlgorithm picks the first client algorithm the server also supports
+func negotiateAlgorithm(client, server []string) string {
+	for _, c := range client {
+		for _, s := range server {
+			if c == s {
+				return c
+			}
+		}
+	}
+	return ""
+}
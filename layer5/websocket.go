@@ -0,0 +1,125 @@
+package layer5
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"os"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ghostshell/app/layers/common"
+)
+
+// WebSocketTarget is a WebSocket echo endpoint to test with a message round trip
+type WebSocketTarget = common.WebSocketTarget
+
+// testWebSocket upgrades a connection to target's URL, sends a text message
+// and times how long the server takes to echo it back
+func (r *Runner) testWebSocket(ctx context.Context, target WebSocketTarget) common.TestResult {
+	result := common.TestResult{
+		Layer:     5,
+		Name:      fmt.Sprintf("WebSocket Session Test (%s)", target.URL),
+		StartTime: time.Now(),
+	}
+
+	diagnostics := &common.SessionDiagnostics{URL: target.URL}
+	result.Diagnostics.Session = diagnostics
+
+	payload := target.PingPayload
+	if payload == "" {
+		payload = fmt.Sprintf("osi-tester %d %d", os.Getpid(), time.Now().UnixNano())
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	var handshakeStart, handshakeDone time.Time
+	dialCtx = httptrace.WithClientTrace(dialCtx, &httptrace.ClientTrace{
+		TLSHandshakeStart: func() { handshakeStart = time.Now() },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { handshakeDone = time.Now() },
+	})
+
+	dialer := websocket.Dialer{
+		HandshakeTimeout: r.Timeout,
+		TLSClientConfig:  &tls.Config{InsecureSkipVerify: !target.TLSVerify},
+	}
+
+	upgradeStart := time.Now()
+	conn, resp, err := dialer.DialContext(dialCtx, target.URL, nil)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		if resp != nil {
+			return result.Finish(common.StatusFailed, fmt.Sprintf("WebSocket upgrade of %s failed with HTTP %d: %v",
+				target.URL, resp.StatusCode, err))
+		}
+		return result.Finish(common.StatusFailed, fmt.Sprintf("WebSocket connection to %s failed: %v", target.URL, err))
+	}
+	upgradeTime := time.Since(upgradeStart)
+	defer conn.Close()
+
+	diagnostics.UpgradeMs = float64(upgradeTime.Microseconds()) / 1000
+	diagnostics.Subprotocol = conn.Subprotocol()
+	if tlsConn, ok := conn.UnderlyingConn().(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		info := &common.TLSHandshakeInfo{
+			Version:     tls.VersionName(state.Version),
+			CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+			ServerName:  state.ServerName,
+			Verified:    target.TLSVerify,
+		}
+		if !handshakeStart.IsZero() && !handshakeDone.IsZero() {
+			info.HandshakeMs = float64(handshakeDone.Sub(handshakeStart).Microseconds()) / 1000
+		}
+		if len(state.PeerCertificates) > 0 {
+			info.CertificateExpiry = state.PeerCertificates[0].NotAfter
+		}
+		diagnostics.TLS = common.Ptr(true)
+		diagnostics.TLSHandshake = info
+	}
+
+	result.Metrics.Latency = upgradeTime
+	result.Metrics.Custom = map[string]interface{}{
+		"upgrade_ms": diagnostics.UpgradeMs,
+	}
+
+	deadline := time.Now().Add(r.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetWriteDeadline(deadline)
+	conn.SetReadDeadline(deadline)
+
+	sendStart := time.Now()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(payload)); err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("WebSocket send to %s failed: %v", target.URL, err))
+	}
+	_, echo, err := conn.ReadMessage()
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("WebSocket echo from %s not received within %v: %v",
+			target.URL, r.Timeout, err))
+	}
+	roundTrip := time.Since(sendStart)
+
+	diagnostics.FirstMessageRTTMs = float64(roundTrip.Microseconds()) / 1000
+	result.Metrics.ResponseTime = roundTrip
+	result.Metrics.Custom["first_message_rtt_ms"] = diagnostics.FirstMessageRTTMs
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	if err := conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second)); err != nil {
+		diagnostics.Error = fmt.Sprintf("close: %v", err)
+	}
+
+	if string(echo) != payload {
+		diagnostics.Error = "echo mismatch"
+		return result.Finish(common.StatusFailed, fmt.Sprintf("WebSocket echo from %s did not match the message sent (sent %d bytes, received %d)",
+			target.URL, len(payload), len(echo)))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("WebSocket session with %s succeeded (upgrade %v, round trip %v)",
+		target.URL, upgradeTime.Round(time.Millisecond), roundTrip.Round(time.Millisecond)))
+}
@@ -0,0 +1,142 @@
+package layer5
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// defaultWireGuardHandshakeMaxAge is used when WireGuardHandshakeMaxAge is
+// unset. WireGuard rekeys every two minutes on an active tunnel.
+const defaultWireGuardHandshakeMaxAge = 3 * time.Minute
+
+// WireGuardPeer is a peer of a WireGuard interface as reported by wg
+type WireGuardPeer = common.WireGuardPeer
+
+// parseWGDump parses the output of `wg show <iface> dump`: a line for the
+// interface followed by a tab separated line per peer
+func parseWGDump(output string) ([]WireGuardPeer, error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return nil, errors.New("empty wg dump")
+	}
+
+	var peers []WireGuardPeer
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 8 {
+			return nil, fmt.Errorf("unexpected wg dump peer line: %q", line)
+		}
+		peer := WireGuardPeer{PublicKey: fields[0]}
+		if fields[2] != "(none)" {
+			peer.Endpoint = fields[2]
+		}
+		if fields[3] != "(none)" {
+			peer.AllowedIPs = strings.Split(fields[3], ",")
+		}
+		if secs, err := strconv.ParseInt(fields[4], 10, 64); err == nil && secs > 0 {
+			handshake := time.Unix(secs, 0)
+			peer.LatestHandshake = &handshake
+		}
+		peer.TransferRx, _ = strconv.ParseInt(fields[5], 10, 64)
+		peer.TransferTx, _ = strconv.ParseInt(fields[6], 10, 64)
+		if fields[7] != "off" {
+			peer.PersistentKeepalive, _ = strconv.Atoi(fields[7])
+		}
+		peers = append(peers, peer)
+	}
+	return peers, nil
+}
+
+// testWireGuard checks the peers of a WireGuard interface. A peer without a
+// recent handshake warns and a peer nothing was received from fails. The
+// interface gets a single result when it can't be inspected or has no peers.
+func (r *Runner) testWireGuard(ctx context.Context, iface string) []common.TestResult {
+	startTime := time.Now()
+	newResult := func(name string) common.TestResult {
+		return common.TestResult{Layer: 5, Name: fmt.Sprintf("WireGuard Peer Status (%s)", name), StartTime: startTime}
+	}
+	ifaceResult := func(status common.TestStatus, msg string, err error) []common.TestResult {
+		result := newResult(iface)
+		diagnostics := &common.SessionDiagnostics{Target: iface}
+		if err != nil {
+			diagnostics.Error = err.Error()
+		}
+		result.Diagnostics.Session = diagnostics
+		return []common.TestResult{result.Finish(status, msg)}
+	}
+
+	if runtime.GOOS != "linux" {
+		return ifaceResult(common.StatusSkipped, fmt.Sprintf("WireGuard peer checks are not supported on %s", runtime.GOOS), nil)
+	}
+	wg, err := exec.LookPath("wg")
+	if err != nil {
+		return ifaceResult(common.StatusSkipped, "WireGuard peer checks need the wg tool in PATH", err)
+	}
+
+	output, err := exec.CommandContext(ctx, wg, "show", iface, "dump").CombinedOutput()
+	if err != nil {
+		detail := strings.TrimSpace(string(output))
+		if strings.Contains(detail, "Operation not permitted") {
+			return ifaceResult(common.StatusSkipped, fmt.Sprintf("Not permitted to read WireGuard interface %s; run as root", iface), err)
+		}
+		return ifaceResult(common.StatusFailed, fmt.Sprintf("Failed to read WireGuard interface %s: %s", iface, detail), err)
+	}
+	peers, err := parseWGDump(string(output))
+	if err != nil {
+		return ifaceResult(common.StatusFailed, fmt.Sprintf("Failed to parse wg output for %s: %v", iface, err), err)
+	}
+	if len(peers) == 0 {
+		return ifaceResult(common.StatusWarning, fmt.Sprintf("WireGuard interface %s has no peers", iface), nil)
+	}
+
+	maxAge := r.WireGuardHandshakeMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultWireGuardHandshakeMaxAge
+	}
+
+	results := make([]common.TestResult, 0, len(peers))
+	for _, peer := range peers {
+		result := newResult(iface + "/" + peer.PublicKey)
+		result.Diagnostics.Session = &common.SessionDiagnostics{Target: iface, WireGuardPeers: peers}
+		result.Metrics.Custom = map[string]interface{}{
+			"transfer_rx_bytes": peer.TransferRx,
+			"transfer_tx_bytes": peer.TransferTx,
+		}
+
+		handshake := "never"
+		var age time.Duration
+		if peer.LatestHandshake != nil {
+			age = time.Since(*peer.LatestHandshake)
+			handshake = fmt.Sprintf("%v ago", age.Round(time.Second))
+			result.Metrics.Custom["handshake_age_s"] = age.Seconds()
+		}
+		endpoint := peer.Endpoint
+		if endpoint == "" {
+			endpoint = "unknown"
+		}
+		summary := fmt.Sprintf("endpoint %s, latest handshake %s, %d bytes received, %d bytes sent",
+			endpoint, handshake, peer.TransferRx, peer.TransferTx)
+
+		switch {
+		case peer.TransferRx == 0:
+			result.Finish(common.StatusFailed, fmt.Sprintf("Nothing received from WireGuard peer %s on %s: %s",
+				peer.PublicKey, iface, summary))
+		case peer.LatestHandshake == nil || age > maxAge:
+			result.Finish(common.StatusWarning, fmt.Sprintf("WireGuard peer %s on %s has no handshake within %v: %s",
+				peer.PublicKey, iface, maxAge, summary))
+		default:
+			result.Finish(common.StatusPassed, fmt.Sprintf("WireGuard peer %s on %s is up: %s",
+				peer.PublicKey, iface, summary))
+		}
+		results = append(results, result)
+	}
+	return results
+}
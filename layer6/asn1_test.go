@@ -0,0 +1,138 @@
+package layer6
+
+import (
+	"encoding/asn1"
+	"reflect"
+	"testing"
+)
+
+// Nested structures shaped like certificate fields: a SEQUENCE holding a
+// SEQUENCE of SEQUENCEs
+type asn1TestAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []string `asn1:"set"`
+}
+
+type asn1TestName struct {
+	Attributes []asn1TestAttribute
+}
+
+type asn1TestRecord struct {
+	Version int
+	Subject asn1TestName
+	Issuer  asn1TestName
+	Serial  []byte
+}
+
+func TestDecodeBERNestedSequences(t *testing.T) {
+	record := asn1TestRecord{
+		Version: 2,
+		Subject: asn1TestName{Attributes: []asn1TestAttribute{
+			{Type: asn1.ObjectIdentifier{2, 5, 4, 3}, Values: []string{"example.com"}},
+			// DER sorts SET OF by encoding, so the shorter string comes first
+			{Type: asn1.ObjectIdentifier{2, 5, 4, 10}, Values: []string{"Org", "Example"}},
+		}},
+		Issuer: asn1TestName{Attributes: []asn1TestAttribute{
+			{Type: asn1.ObjectIdentifier{2, 5, 4, 3}, Values: []string{"Example CA"}},
+		}},
+		Serial: []byte{0x01, 0x02, 0x03},
+	}
+	der, err := asn1.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// DER round trip keeps the nested structure
+	var decoded asn1TestRecord
+	if rest, err := asn1.Unmarshal(der, &decoded); err != nil || len(rest) > 0 {
+		t.Fatalf("Unmarshal: %v with %d trailing bytes", err, len(rest))
+	}
+	if !reflect.DeepEqual(decoded, record) {
+		t.Errorf("round trip gave %+v, want %+v", decoded, record)
+	}
+
+	// record, version, subject, its 2 attributes of OID, SET and 1 or 2
+	// strings, issuer, its attribute of OID, SET and string, serial
+	const wantElements = 1 + 1 + (1 + 1) + (1 + 1 + 1 + 1) + (1 + 1 + 1 + 2) + (1 + 1) + (1 + 1 + 1 + 1) + 1
+	elements, err := decodeBER(der)
+	if err != nil {
+		t.Fatalf("decodeBER: %v", err)
+	}
+	if elements != wantElements {
+		t.Errorf("decodeBER found %d elements, want %d", elements, wantElements)
+	}
+}
+
+func TestDecodeBERIndefiniteLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    int
+		wantErr bool
+	}{
+		{
+			// SEQUENCE { SEQUENCE { INTEGER 1 } OCTET STRING "A" }, both of indefinite length
+			name: "nested indefinite",
+			data: []byte{0x30, 0x80, 0x30, 0x80, 0x02, 0x01, 0x01, 0x00, 0x00, 0x04, 0x01, 0x41, 0x00, 0x00},
+			want: 4,
+		},
+		{
+			// Indefinite SEQUENCE holding a definite SEQUENCE
+			name: "mixed lengths",
+			data: []byte{0x30, 0x80, 0x30, 0x03, 0x02, 0x01, 0x05, 0x00, 0x00},
+			want: 3,
+		},
+		{
+			name:    "missing end-of-contents",
+			data:    []byte{0x30, 0x80, 0x02, 0x01, 0x01},
+			wantErr: true,
+		},
+		{
+			name:    "indefinite primitive",
+			data:    []byte{0x04, 0x80, 0x41, 0x00, 0x00},
+			wantErr: true,
+		},
+		{
+			name:    "inner length past outer end",
+			data:    []byte{0x30, 0x03, 0x30, 0x05, 0x02, 0x01, 0x01},
+			wantErr: true,
+		},
+		{
+			name:    "trailing data",
+			data:    []byte{0x02, 0x01, 0x01, 0xff},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elements, err := decodeBER(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("no error, decoded %d elements", elements)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeBER: %v", err)
+			}
+			if elements != tt.want {
+				t.Errorf("decoded %d elements, want %d", elements, tt.want)
+			}
+		})
+	}
+}
+
+func TestASN1Transformation(t *testing.T) {
+	data := map[string]string{"b": "second", "a": "first", "c": ""}
+	ok, msg, diagnostics := testASN1Transformation(data)
+	if !ok {
+		t.Fatalf("transformation failed: %s (%s)", msg, diagnostics.Error)
+	}
+	if diagnostics.SequenceLength != 3 || !diagnostics.BERDecodedOK || diagnostics.DERBytes == 0 {
+		t.Errorf("diagnostics %+v", diagnostics)
+	}
+	// The outer SEQUENCE plus a SEQUENCE and two strings per pair
+	if diagnostics.BERElements != 1+3*3 {
+		t.Errorf("%d BER elements, want %d", diagnostics.BERElements, 1+3*3)
+	}
+}
@@ -0,0 +1,129 @@
+package layer6
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+
+	"ghostshell/app/layers/common"
+)
+
+// compressionCodec compresses and decompresses a buffer with one algorithm
+type compressionCodec struct {
+	compress   func(data []byte) ([]byte, error)
+	decompress func(data []byte) ([]byte, error)
+}
+
+// compressionCodecs are the supported compression algorithms
+var compressionCodecs = map[string]compressionCodec{
+	"gzip": {
+		compress: func(data []byte) ([]byte, error) {
+			var buf bytes.Buffer
+			w := gzip.NewWriter(&buf)
+			if _, err := w.Write(data); err != nil {
+				return nil, err
+			}
+			if err := w.Close(); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		},
+		decompress: func(data []byte) ([]byte, error) {
+			r, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			defer r.Close()
+			return io.ReadAll(r)
+		},
+	},
+	"zstd": {
+		compress: func(data []byte) ([]byte, error) {
+			enc, err := zstd.NewWriter(nil)
+			if err != nil {
+				return nil, err
+			}
+			defer enc.Close()
+			return enc.EncodeAll(data, nil), nil
+		},
+		decompress: func(data []byte) ([]byte, error) {
+			dec, err := zstd.NewReader(nil)
+			if err != nil {
+				return nil, err
+			}
+			defer dec.Close()
+			return dec.DecodeAll(data, nil)
+		},
+	},
+	"brotli": {
+		compress: func(data []byte) ([]byte, error) {
+			var buf bytes.Buffer
+			w := brotli.NewWriter(&buf)
+			if _, err := w.Write(data); err != nil {
+				return nil, err
+			}
+			if err := w.Close(); err != nil {
+				return nil, err
+			}
+			return buf.Bytes(), nil
+		},
+		decompress: func(data []byte) ([]byte, error) {
+			return io.ReadAll(brotli.NewReader(bytes.NewReader(data)))
+		},
+	},
+}
+
+// testCompressionTransformation compresses data with algorithm, decompresses
+// it again and verifies the round trip, timing both directions
+func testCompressionTransformation(data []byte, algorithm string) (bool, string, *common.PresentationDiagnostics) {
+	diagnostics := &common.PresentationDiagnostics{}
+	diagnostics.Algorithm = algorithm
+	diagnostics.OriginalSize = len(data)
+
+	codec, ok := compressionCodecs[algorithm]
+	if !ok {
+		diagnostics.Error = "unsupported algorithm"
+		return false, fmt.Sprintf("Unsupported compression algorithm: %s", algorithm), diagnostics
+	}
+
+	start := time.Now()
+	compressed, err := codec.compress(data)
+	compressTime := time.Since(start)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "compression"
+		return false, fmt.Sprintf("%s compression failed: %v", algorithm, err), diagnostics
+	}
+
+	start = time.Now()
+	decompressed, err := codec.decompress(compressed)
+	decompressTime := time.Since(start)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "decompression"
+		return false, fmt.Sprintf("%s decompression failed: %v", algorithm, err), diagnostics
+	}
+
+	ratio := 0.0
+	if len(compressed) > 0 {
+		ratio = float64(len(data)) / float64(len(compressed))
+	}
+	diagnostics.CompressedSize = len(compressed)
+	diagnostics.CompressionRatio = ratio
+	diagnostics.CompressTimeMs = float64(compressTime.Microseconds()) / 1000
+	diagnostics.DecompressTimeMs = float64(decompressTime.Microseconds()) / 1000
+
+	if !bytes.Equal(decompressed, data) {
+		diagnostics.Error = "Data mismatch"
+		diagnostics.DecompressedSize = len(decompressed)
+		return false, fmt.Sprintf("%s round trip failed: decompressed data does not match original", algorithm), diagnostics
+	}
+
+	return true, fmt.Sprintf("%s round trip successful: %d -> %d bytes (ratio %.2f)",
+		algorithm, len(data), len(compressed), ratio), diagnostics
+}
@@ -0,0 +1,156 @@
+package layer6
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// earlyDataTimeout bounds each connection made by the 0-RTT test
+const earlyDataTimeout = 10 * time.Second
+
+// earlyDataUntested is recorded as the early data result: crypto/tls has no
+// client side 0-RTT, so the payload is only sent once the handshake is done
+// and whether the server would accept early data is not tested
+const earlyDataUntested = "untested: crypto/tls does not send 0-RTT early data"
+
+// test0RTTEarlyData checks whether target resumes TLS 1.3 sessions, which
+// 0-RTT early data requires, and measures the latency saved compared to a
+// full handshake. Early data itself cannot be sent with crypto/tls.
+func test0RTTEarlyData(ctx context.Context, target string, earlyDataPayload []byte) (common.TestResult, error) {
+	result := common.TestResult{
+		Layer:     6,
+		Name:      "TLS 1.3 0-RTT Early Data Test",
+		StartTime: time.Now(),
+	}
+	diagnostics := &common.PresentationDiagnostics{}
+	result.Diagnostics.Presentation = diagnostics
+
+	host, addr, err := parseEarlyDataTarget(target)
+	if err != nil {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Invalid 0-RTT target: %v", err)), err
+	}
+	diagnostics.Target = addr
+
+	if len(earlyDataPayload) == 0 {
+		earlyDataPayload = []byte(fmt.Sprintf("GET / HTTP/1.1\r\nHost: %s\r\nEarly-Data: 1\r\nConnection: close\r\n\r\n", host))
+	}
+	diagnostics.PayloadSize = len(earlyDataPayload)
+	diagnostics.EarlyData = earlyDataUntested
+
+	config := &tls.Config{
+		ServerName:             host,
+		MinVersion:             tls.VersionTLS13,
+		SessionTicketsDisabled: false,
+		ClientSessionCache:     tls.NewLRUClientSessionCache(1),
+	}
+
+	// First connection performs a full 1-RTT handshake and caches the session ticket
+	fullLatency, _, _, err := earlyDataRoundTrip(ctx, addr, config, earlyDataPayload)
+	if err != nil {
+		diagnostics.Stage = "initial_handshake"
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("0-RTT test failed during initial handshake: %v", err)), err
+	}
+	diagnostics.FullHandshakeMs = float64(fullLatency.Microseconds()) / 1000
+
+	// Second connection resumes with the cached ticket
+	resumedLatency, state, resp, err := earlyDataRoundTrip(ctx, addr, config, earlyDataPayload)
+	if err != nil {
+		diagnostics.Stage = "resumed_handshake"
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("0-RTT test failed during resumed handshake: %v", err)), err
+	}
+
+	improvement := fullLatency - resumedLatency
+	diagnostics.ResumedHandshakeMs = float64(resumedLatency.Microseconds()) / 1000
+	diagnostics.ImprovementMs = common.Ptr(float64(improvement.Microseconds()) / 1000)
+	if fullLatency > 0 {
+		diagnostics.ImprovementPct = float64(improvement) / float64(fullLatency) * 100
+	}
+	diagnostics.DidResume = common.Ptr(state.DidResume)
+	diagnostics.TLSVersion = tls.VersionName(state.Version)
+	diagnostics.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	result.Metrics.Latency = resumedLatency
+
+	if resp != nil {
+		diagnostics.HTTPStatus = resp.StatusCode
+	}
+	diagnostics.Stage = "complete"
+
+	if !state.DidResume {
+		return result.Finish(common.StatusWarning,
+			"Server did not resume the TLS 1.3 session; 0-RTT early data cannot be used"), nil
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("TLS 1.3 session resumed, a prerequisite for 0-RTT:\n"+
+		"- Full handshake: %s\n"+
+		"- Resumed handshake: %s\n"+
+		"- Improvement: %s\n"+
+		"- Early data acceptance: %s",
+		fullLatency, resumedLatency, improvement, earlyDataUntested)), nil
+}
+
+// earlyDataRoundTrip dials addr, writes payload and returns the time until the
+// first response arrived. The write completes the handshake before the
+// payload is sent.
+func earlyDataRoundTrip(ctx context.Context, addr string, config *tls.Config, payload []byte) (time.Duration, tls.ConnectionState, *http.Response, error) {
+	dialer := &net.Dialer{Timeout: earlyDataTimeout}
+
+	start := time.Now()
+	rawConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return 0, tls.ConnectionState{}, nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer rawConn.Close()
+
+	if err := rawConn.SetDeadline(time.Now().Add(earlyDataTimeout)); err != nil {
+		return 0, tls.ConnectionState{}, nil, fmt.Errorf("failed to set deadline: %w", err)
+	}
+
+	conn := tls.Client(rawConn, config)
+	if _, err := conn.Write(payload); err != nil {
+		return 0, tls.ConnectionState{}, nil, fmt.Errorf("failed to write early data: %w", err)
+	}
+
+	// Reading the response also processes the session ticket sent after the handshake
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	latency := time.Since(start)
+	if err != nil {
+		// Non-HTTP payloads are still useful for latency measurements
+		return latency, conn.ConnectionState(), nil, nil
+	}
+	resp.Body.Close()
+
+	return latency, conn.ConnectionState(), resp, nil
+}
+
+// parseEarlyDataTarget accepts either a URL or host[:port] and returns the
+// server name and dial address, defaulting to port 443
+func parseEarlyDataTarget(target string) (string, string, error) {
+	if target == "" {
+		return "", "", fmt.Errorf("target must be specified")
+	}
+
+	hostPort := target
+	if u, err := url.Parse(target); err == nil && u.Host != "" {
+		hostPort = u.Host
+	}
+
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host, port = hostPort, "443"
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("missing host in %q", target)
+	}
+
+	return host, net.JoinHostPort(host, port), nil
+}
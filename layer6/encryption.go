@@ -0,0 +1,91 @@
+package layer6
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// defaultEncryptionKeyLen is the AES key length used by RunTests (AES-256)
+const defaultEncryptionKeyLen = 32
+
+// testEncryptionTransformation encrypts plaintext with AES-GCM under a random
+// key of keyLen bytes (16, 24 or 32), decrypts it again and verifies the
+// round trip, timing both directions
+func testEncryptionTransformation(plaintext []byte, keyLen int) (bool, string, *common.PresentationDiagnostics) {
+	diagnostics := &common.PresentationDiagnostics{}
+	diagnostics.Algorithm = fmt.Sprintf("AES-%d-GCM", keyLen*8)
+	diagnostics.KeyLength = keyLen
+	diagnostics.PlaintextSize = len(plaintext)
+
+	switch keyLen {
+	case 16, 24, 32:
+	default:
+		diagnostics.Error = "invalid key length"
+		return false, fmt.Sprintf("Invalid AES key length: %d bytes (must be 16, 24 or 32)", keyLen), diagnostics
+	}
+
+	key := make([]byte, keyLen)
+	if _, err := rand.Read(key); err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "key generation"
+		return false, fmt.Sprintf("Failed to generate AES key: %v", err), diagnostics
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "cipher setup"
+		return false, fmt.Sprintf("Failed to create AES cipher: %v", err), diagnostics
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "cipher setup"
+		return false, fmt.Sprintf("Failed to create GCM mode: %v", err), diagnostics
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "nonce generation"
+		return false, fmt.Sprintf("Failed to generate GCM nonce: %v", err), diagnostics
+	}
+
+	start := time.Now()
+	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+	encryptTime := time.Since(start)
+
+	start = time.Now()
+	decrypted, err := gcm.Open(nil, nonce, ciphertext, nil)
+	decryptTime := time.Since(start)
+
+	expansion := 0.0
+	if len(plaintext) > 0 {
+		expansion = float64(len(ciphertext)) / float64(len(plaintext))
+	}
+	diagnostics.CiphertextSize = len(ciphertext)
+	diagnostics.ExpansionRatio = expansion
+	diagnostics.EncryptTimeMs = float64(encryptTime.Microseconds()) / 1000
+	diagnostics.DecryptTimeMs = float64(decryptTime.Microseconds()) / 1000
+
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "decryption"
+		return false, fmt.Sprintf("AES-%d-GCM decryption failed: %v", keyLen*8, err), diagnostics
+	}
+
+	if !bytes.Equal(decrypted, plaintext) {
+		diagnostics.Error = "Data mismatch"
+		diagnostics.DecryptedSize = len(decrypted)
+		return false, fmt.Sprintf("AES-%d-GCM round trip failed: decrypted data does not match original", keyLen*8), diagnostics
+	}
+
+	return true, fmt.Sprintf("AES-%d-GCM round trip successful: %d -> %d bytes (expansion %.2f)",
+		keyLen*8, len(plaintext), len(ciphertext), expansion), diagnostics
+}
@@ -2,10 +2,14 @@
 package layer6
 
 import (
+	"bytes"
 	"context"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"time"
 
@@ -19,13 +23,49 @@ type Runner struct {
 	*common.Layer6Runner
 }
 
+// Option configures a Runner
+type Option func(*Runner)
+
+// WithAlgorithms sets the compression algorithms tested for each dataset
+func WithAlgorithms(algorithms ...string) Option {
+	return func(r *Runner) {
+		r.Algorithms = algorithms
+	}
+}
+
+// WithEncryption enables the AES-GCM encryption round trip of each dataset
+func WithEncryption(enabled bool) Option {
+	return func(r *Runner) {
+		r.TestEncryption = enabled
+	}
+}
+
+// WithProtobuf enables the Protobuf round trip of each dataset
+func WithProtobuf(enabled bool) Option {
+	return func(r *Runner) {
+		r.TestProtobuf = enabled
+	}
+}
+
+// WithMessagePack enables the MessagePack round trip of each dataset
+func WithMessagePack(enabled bool) Option {
+	return func(r *Runner) {
+		r.TestMessagePack = enabled
+	}
+}
+
 // New creates a new Layer6Runner
-func New(dataSets []map[string]string) *Runner {
-	return &Runner{
+func New(dataSets []map[string]string, opts ...Option) *Runner {
+	r := &Runner{
 		Layer6Runner: &common.Layer6Runner{
-			DataSets: dataSets,
+			DataSets:   dataSets,
+			Algorithms: []string{"gzip"},
 		},
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 // RunTests implements the LayerRunner interface
@@ -47,7 +87,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		parentResult.Status = common.StatusFailed
 		parentResult.Message = "Test cancelled"
 		parentResult.EndTime = time.Now()
-		return []common.TestResult{parentResult}, ctx.Err()
+		return []common.TestResult{parentResult}, common.NewLayerError(6, ctx.Err())
 	default:
 		var failedTests []string
 
@@ -70,7 +110,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 				jsonResult.Message = msg
 			}
 
-			jsonResult.Diagnostics = jsonDetails
+			jsonResult.Diagnostics.Presentation = jsonDetails
 			jsonResult.EndTime = time.Now()
 			jsonResult.Metrics.Duration = jsonResult.EndTime.Sub(jsonResult.StartTime)
 			parentResult.SubResults = append(parentResult.SubResults, jsonResult)
@@ -92,10 +132,174 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 				base64Result.Message = msg
 			}
 
-			base64Result.Diagnostics = base64Details
+			base64Result.Diagnostics.Presentation = base64Details
 			base64Result.EndTime = time.Now()
 			base64Result.Metrics.Duration = base64Result.EndTime.Sub(base64Result.StartTime)
 			parentResult.SubResults = append(parentResult.SubResults, base64Result)
+
+			// Compression round trip tests
+			if payload, err := json.Marshal(data); err == nil {
+				for _, algorithm := range r.Algorithms {
+					compressionResult := common.TestResult{
+						Layer:     6,
+						Name:      fmt.Sprintf("%s Compression Test (Dataset %d)", algorithm, i+1),
+						StartTime: time.Now(),
+					}
+
+					success, msg, compressionDetails := testCompressionTransformation(payload, algorithm)
+					if !success {
+						compressionResult.Status = common.StatusFailed
+						compressionResult.Message = msg
+						failedTests = append(failedTests, msg)
+					} else {
+						compressionResult.Status = common.StatusPassed
+						compressionResult.Message = msg
+					}
+
+					compressionResult.Metrics.Custom = map[string]interface{}{}
+					if compressionDetails.CompressedSize > 0 {
+						compressionResult.Metrics.Custom["compressed_size"] = compressionDetails.CompressedSize
+						compressionResult.Metrics.Custom["compression_ratio"] = compressionDetails.CompressionRatio
+						compressionResult.Metrics.Custom["compress_time_ms"] = compressionDetails.CompressTimeMs
+						compressionResult.Metrics.Custom["decompress_time_ms"] = compressionDetails.DecompressTimeMs
+					}
+
+					compressionResult.Diagnostics.Presentation = compressionDetails
+					compressionResult.EndTime = time.Now()
+					compressionResult.Metrics.Duration = compressionResult.EndTime.Sub(compressionResult.StartTime)
+					parentResult.SubResults = append(parentResult.SubResults, compressionResult)
+				}
+			}
+
+			// Encryption round trip test
+			if r.TestEncryption {
+				encryptionResult := common.TestResult{
+					Layer:     6,
+					Name:      fmt.Sprintf("AES-GCM Encryption Test (Dataset %d)", i+1),
+					StartTime: time.Now(),
+				}
+
+				var success bool
+				var msg string
+				var encryptionDetails *common.PresentationDiagnostics
+				if payload, err := json.Marshal(data); err != nil {
+					success = false
+					msg = fmt.Sprintf("Failed to marshal dataset for encryption: %v", err)
+					encryptionDetails = &common.PresentationDiagnostics{Error: err.Error()}
+				} else {
+					success, msg, encryptionDetails = testEncryptionTransformation(payload, defaultEncryptionKeyLen)
+				}
+				if !success {
+					encryptionResult.Status = common.StatusFailed
+					encryptionResult.Message = msg
+					failedTests = append(failedTests, msg)
+				} else {
+					encryptionResult.Status = common.StatusPassed
+					encryptionResult.Message = msg
+				}
+
+				encryptionResult.Diagnostics.Presentation = encryptionDetails
+				encryptionResult.EndTime = time.Now()
+				encryptionResult.Metrics.Duration = encryptionResult.EndTime.Sub(encryptionResult.StartTime)
+				parentResult.SubResults = append(parentResult.SubResults, encryptionResult)
+			}
+
+			// ASN.1 transformation test
+			if r.TestASN1 {
+				asn1Result := common.TestResult{
+					Layer:     6,
+					Name:      fmt.Sprintf("ASN.1 Transformation Test (Dataset %d)", i+1),
+					StartTime: time.Now(),
+				}
+
+				success, msg, asn1Details := testASN1Transformation(data)
+				if !success {
+					asn1Result.Status = common.StatusFailed
+					asn1Result.Message = msg
+					failedTests = append(failedTests, msg)
+				} else {
+					asn1Result.Status = common.StatusPassed
+					asn1Result.Message = msg
+				}
+
+				asn1Result.Diagnostics.Presentation = asn1Details
+				asn1Result.EndTime = time.Now()
+				asn1Result.Metrics.Duration = asn1Result.EndTime.Sub(asn1Result.StartTime)
+				parentResult.SubResults = append(parentResult.SubResults, asn1Result)
+			}
+
+			// Protobuf round trip test
+			if r.TestProtobuf {
+				protobufResult := common.TestResult{
+					Layer:     6,
+					Name:      fmt.Sprintf("Protobuf Round-Trip Test (Dataset %d)", i+1),
+					StartTime: time.Now(),
+				}
+
+				success, msg, protobufDetails := testProtobufTransformation(data)
+				if !success {
+					protobufResult.Status = common.StatusFailed
+					protobufResult.Message = msg
+					failedTests = append(failedTests, msg)
+				} else {
+					protobufResult.Status = common.StatusPassed
+					protobufResult.Message = msg
+				}
+
+				protobufResult.Metrics.Custom = map[string]interface{}{
+					"proto_encode_ms": protobufDetails.EncodeTimeMs,
+					"proto_decode_ms": protobufDetails.DecodeTimeMs,
+				}
+
+				protobufResult.Diagnostics.Presentation = protobufDetails
+				protobufResult.EndTime = time.Now()
+				protobufResult.Metrics.Duration = protobufResult.EndTime.Sub(protobufResult.StartTime)
+				parentResult.SubResults = append(parentResult.SubResults, protobufResult)
+			}
+
+			// MessagePack round trip test
+			if r.TestMessagePack {
+				msgpackResult := common.TestResult{
+					Layer:     6,
+					Name:      fmt.Sprintf("MessagePack Round-Trip Test (Dataset %d)", i+1),
+					StartTime: time.Now(),
+				}
+
+				success, msg, msgpackDetails := testMessagePackTransformation(data)
+				if !success {
+					msgpackResult.Status = common.StatusFailed
+					msgpackResult.Message = msg
+					failedTests = append(failedTests, msg)
+				} else {
+					msgpackResult.Status = common.StatusPassed
+					msgpackResult.Message = msg
+				}
+
+				msgpackResult.Metrics.Custom = map[string]interface{}{
+					"msgpack_size_bytes": msgpackDetails.WireSize,
+					"json_size_bytes":    msgpackDetails.JSONSize,
+					"compression_ratio":  msgpackDetails.SizeRatio,
+					"msgpack_encode_us":  math.Round(msgpackDetails.EncodeTimeMs * 1000),
+					"msgpack_decode_us":  math.Round(msgpackDetails.DecodeTimeMs * 1000),
+				}
+
+				msgpackResult.Diagnostics.Presentation = msgpackDetails
+				msgpackResult.EndTime = time.Now()
+				msgpackResult.Metrics.Duration = msgpackResult.EndTime.Sub(msgpackResult.StartTime)
+				parentResult.SubResults = append(parentResult.SubResults, msgpackResult)
+			}
+		}
+
+		// TLS 1.3 0-RTT early data test
+		if r.Test0RTT && r.EarlyDataEndpoint != "" {
+			earlyDataResult, err := test0RTTEarlyData(ctx, r.EarlyDataEndpoint, nil)
+			if err != nil {
+				logger.Error("0-RTT early data test failed", zap.Error(err))
+			}
+			if earlyDataResult.Status == common.StatusFailed {
+				failedTests = append(failedTests, earlyDataResult.Message)
+			}
+			parentResult.SubResults = append(parentResult.SubResults, earlyDataResult)
 		}
 
 		// Set overall test status and message
@@ -105,11 +309,24 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 				len(failedTests), strings.Join(failedTests, "\n\n"))
 			logger.Error(parentResult.Message)
 		} else {
+			transformsPerDataset := 2 + len(r.Algorithms)
+			if r.TestASN1 {
+				transformsPerDataset++
+			}
+			if r.TestEncryption {
+				transformsPerDataset++
+			}
+			if r.TestProtobuf {
+				transformsPerDataset++
+			}
+			if r.TestMessagePack {
+				transformsPerDataset++
+			}
 			parentResult.Status = common.StatusPassed
 			parentResult.Message = fmt.Sprintf("All Layer 6 tests passed successfully:\n"+
 				"- Datasets tested: %d\n"+
 				"- Total transformations: %d",
-				len(r.DataSets), len(r.DataSets)*2)
+				len(r.DataSets), len(r.DataSets)*transformsPerDataset)
 			logger.Info(parentResult.Message)
 		}
 
@@ -117,110 +334,292 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		parentResult.Metrics.Duration = parentResult.EndTime.Sub(parentResult.StartTime)
 
 		if len(failedTests) > 0 {
-			return []common.TestResult{parentResult}, fmt.Errorf("layer 6 tests failed")
+			return []common.TestResult{parentResult}, &common.LayerError{Layer: 6, Code: common.ErrTestFailed, Cause: fmt.Errorf("layer 6 tests failed")}
 		}
 		return []common.TestResult{parentResult}, nil
 	}
 }
 
 // testJSONTransformation tests JSON encoding and decoding
-func testJSONTransformation(data map[string]string) (bool, string, map[string]interface{}) {
-	diagnostics := make(map[string]interface{})
-	diagnostics["data_size"] = len(data)
+func testJSONTransformation(data map[string]string) (bool, string, *common.PresentationDiagnostics) {
+	diagnostics := &common.PresentationDiagnostics{}
+	diagnostics.DataSize = len(data)
 
 	// Try to marshal to JSON
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		diagnostics["error"] = err.Error()
-		diagnostics["stage"] = "encoding"
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "encoding"
 		return false, fmt.Sprintf("JSON encoding failed: %v", err), diagnostics
 	}
-	diagnostics["encoded_size"] = len(jsonData)
+	diagnostics.EncodedSize = len(jsonData)
 
 	// Try to unmarshal back
 	var decoded map[string]string
 	if err := json.Unmarshal(jsonData, &decoded); err != nil {
-		diagnostics["error"] = err.Error()
-		diagnostics["stage"] = "decoding"
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "decoding"
 		return false, fmt.Sprintf("JSON decoding failed: %v", err), diagnostics
 	}
 
 	// Verify data integrity
 	if len(decoded) != len(data) {
-		diagnostics["error"] = "Data size mismatch"
-		diagnostics["original_size"] = len(data)
-		diagnostics["decoded_size"] = len(decoded)
+		diagnostics.Error = "Data size mismatch"
+		diagnostics.OriginalSize = len(data)
+		diagnostics.DecodedSize = len(decoded)
 		return false, "JSON transformation failed: data size mismatch", diagnostics
 	}
 
 	for k, v := range data {
 		if decoded[k] != v {
-			diagnostics["error"] = "Data content mismatch"
-			diagnostics["mismatched_key"] = k
+			diagnostics.Error = "Data content mismatch"
+			diagnostics.MismatchedKey = k
 			return false, "JSON transformation failed: data content mismatch", diagnostics
 		}
 	}
 
-	diagnostics["stage"] = "complete"
-	diagnostics["success"] = true
+	diagnostics.Stage = "complete"
+	diagnostics.Success = true
 	return true, "JSON transformation successful", diagnostics
 }
 
 // testBase64Transformation tests Base64 encoding and decoding
-func testBase64Transformation(data map[string]string) (bool, string, map[string]interface{}) {
-	diagnostics := make(map[string]interface{})
-	diagnostics["data_size"] = len(data)
+func testBase64Transformation(data map[string]string) (bool, string, *common.PresentationDiagnostics) {
+	diagnostics := &common.PresentationDiagnostics{}
+	diagnostics.DataSize = len(data)
 
 	// Convert map to JSON first
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		diagnostics["error"] = err.Error()
-		diagnostics["stage"] = "json_encoding"
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "json_encoding"
 		return false, fmt.Sprintf("Base64 pre-processing failed: %v", err), diagnostics
 	}
 
 	// Encode to Base64
 	encoded := base64.StdEncoding.EncodeToString(jsonData)
-	diagnostics["encoded_size"] = len(encoded)
+	diagnostics.EncodedSize = len(encoded)
 
 	// Decode from Base64
 	decoded, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
-		diagnostics["error"] = err.Error()
-		diagnostics["stage"] = "base64_decoding"
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "base64_decoding"
 		return false, fmt.Sprintf("Base64 decoding failed: %v", err), diagnostics
 	}
 
 	// Verify data integrity
 	if len(decoded) != len(jsonData) {
-		diagnostics["error"] = "Data size mismatch"
-		diagnostics["original_size"] = len(jsonData)
-		diagnostics["decoded_size"] = len(decoded)
+		diagnostics.Error = "Data size mismatch"
+		diagnostics.OriginalSize = len(jsonData)
+		diagnostics.DecodedSize = len(decoded)
 		return false, "Base64 transformation failed: data size mismatch", diagnostics
 	}
 
 	// Try to unmarshal back to verify data
 	var finalData map[string]string
 	if err := json.Unmarshal(decoded, &finalData); err != nil {
-		diagnostics["error"] = err.Error()
-		diagnostics["stage"] = "json_decoding"
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "json_decoding"
 		return false, fmt.Sprintf("Base64 post-processing failed: %v", err), diagnostics
 	}
 
 	// Verify content
 	for k, v := range data {
 		if finalData[k] != v {
-			diagnostics["error"] = "Data content mismatch"
-			diagnostics["mismatched_key"] = k
+			diagnostics.Error = "Data content mismatch"
+			diagnostics.MismatchedKey = k
 			return false, "Base64 transformation failed: data content mismatch", diagnostics
 		}
 	}
 
-	diagnostics["stage"] = "complete"
-	diagnostics["success"] = true
+	diagnostics.Stage = "complete"
+	diagnostics.Success = true
 	return true, "Base64 transformation successful", diagnostics
 }
 
+// asn1Pair is a key/value entry encoded as an ASN.1 SEQUENCE of two UTF8Strings
+type asn1Pair struct {
+	Key   string `asn1:"utf8"`
+	Value string `asn1:"utf8"`
+}
+
+// testASN1Transformation tests ASN.1 DER encoding and decoding, plus BER decoding of the DER output
+func testASN1Transformation(data map[string]string) (bool, string, *common.PresentationDiagnostics) {
+	diagnostics := &common.PresentationDiagnostics{}
+	diagnostics.DataSize = len(data)
+
+	// Encode pairs in key order since DER output must be deterministic
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var content bytes.Buffer
+	for _, k := range keys {
+		encoded, err := asn1.Marshal(asn1Pair{Key: k, Value: data[k]})
+		if err != nil {
+			diagnostics.Error = err.Error()
+			diagnostics.Stage = "pair_encoding"
+			return false, fmt.Sprintf("ASN.1 encoding failed for key %s: %v", k, err), diagnostics
+		}
+		content.Write(encoded)
+	}
+
+	sequence := asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSequence,
+		IsCompound: true,
+		Bytes:      content.Bytes(),
+	}
+
+	der, err := asn1.Marshal(sequence)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "der_encoding"
+		return false, fmt.Sprintf("ASN.1 DER encoding failed: %v", err), diagnostics
+	}
+	diagnostics.DERBytes = len(der)
+	diagnostics.SequenceLength = len(keys)
+
+	// Decode with the strict DER parser
+	var decoded []asn1Pair
+	rest, err := asn1.Unmarshal(der, &decoded)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "der_decoding"
+		return false, fmt.Sprintf("ASN.1 DER decoding failed: %v", err), diagnostics
+	}
+	if len(rest) > 0 {
+		diagnostics.Error = "Trailing data after SEQUENCE"
+		diagnostics.TrailingBytes = len(rest)
+		return false, "ASN.1 transformation failed: trailing data after SEQUENCE", diagnostics
+	}
+
+	// Verify structural equality
+	if len(decoded) != len(keys) {
+		diagnostics.Error = "Sequence length mismatch"
+		diagnostics.DecodedLength = len(decoded)
+		return false, "ASN.1 transformation failed: sequence length mismatch", diagnostics
+	}
+	for i, pair := range decoded {
+		if pair.Key != keys[i] || pair.Value != data[keys[i]] {
+			diagnostics.Error = "Data content mismatch"
+			diagnostics.MismatchedKey = keys[i]
+			return false, "ASN.1 transformation failed: data content mismatch", diagnostics
+		}
+	}
+
+	// BER is a superset of DER, so a generic BER parser must also accept the output
+	elements, err := decodeBER(der)
+	diagnostics.BERDecodedOK = err == nil
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "ber_decoding"
+		return false, fmt.Sprintf("ASN.1 BER decoding failed: %v", err), diagnostics
+	}
+	diagnostics.BERElements = elements
+
+	diagnostics.Stage = "complete"
+	diagnostics.Success = true
+	return true, "ASN.1 transformation successful", diagnostics
+}
+
+// decodeBER walks BER encoded data, including indefinite length forms, and
+// returns the number of elements found
+func decodeBER(data []byte) (int, error) {
+	count, n, err := decodeBERElements(data, false)
+	if err != nil {
+		return count, err
+	}
+	if n != len(data) {
+		return count, fmt.Errorf("trailing data after element at offset %d", n)
+	}
+	return count, nil
+}
+
+// decodeBERElements parses consecutive TLVs until the data ends or, when
+// untilEOC is set, an end-of-contents marker is reached
+func decodeBERElements(data []byte, untilEOC bool) (int, int, error) {
+	count, offset := 0, 0
+
+	for offset < len(data) {
+		if untilEOC && offset+1 < len(data) && data[offset] == 0x00 && data[offset+1] == 0x00 {
+			return count, offset + 2, nil
+		}
+
+		// Identifier octets
+		identifier := data[offset]
+		constructed := identifier&0x20 != 0
+		offset++
+		if identifier&0x1f == 0x1f {
+			for {
+				if offset >= len(data) {
+					return count, offset, fmt.Errorf("truncated high tag number")
+				}
+				b := data[offset]
+				offset++
+				if b&0x80 == 0 {
+					break
+				}
+			}
+		}
+
+		// Length octets
+		if offset >= len(data) {
+			return count, offset, fmt.Errorf("missing length at offset %d", offset)
+		}
+		lengthByte := data[offset]
+		offset++
+		count++
+
+		if lengthByte == 0x80 {
+			if !constructed {
+				return count, offset, fmt.Errorf("indefinite length on primitive element")
+			}
+			inner, n, err := decodeBERElements(data[offset:], true)
+			if err != nil {
+				return count, offset, err
+			}
+			count += inner
+			offset += n
+			continue
+		}
+
+		length := int(lengthByte)
+		if lengthByte&0x80 != 0 {
+			numBytes := int(lengthByte & 0x7f)
+			if numBytes > 4 || offset+numBytes > len(data) {
+				return count, offset, fmt.Errorf("invalid length encoding at offset %d", offset)
+			}
+			length = 0
+			for _, b := range data[offset : offset+numBytes] {
+				length = length<<8 | int(b)
+			}
+			offset += numBytes
+		}
+
+		if length < 0 || offset+length > len(data) {
+			return count, offset, fmt.Errorf("element length %d exceeds available data", length)
+		}
+
+		if constructed {
+			inner, _, err := decodeBERElements(data[offset:offset+length], false)
+			if err != nil {
+				return count, offset, err
+			}
+			count += inner
+		}
+		offset += length
+	}
+
+	if untilEOC {
+		return count, offset, fmt.Errorf("missing end-of-contents marker")
+	}
+	return count, offset, nil
+}
+
 // GetDependencies returns the layer numbers this layer depends on
 func (r *Runner) GetDependencies() []int {
 	return []int{1, 2, 3, 4, 5} // Layer 6 depends on Layers 1-5
@@ -236,6 +635,11 @@ func (r *Runner) ValidateConfig() error {
 			return fmt.Errorf("data set %d is empty", i+1)
 		}
 	}
+	for _, algorithm := range r.Algorithms {
+		if _, ok := compressionCodecs[algorithm]; !ok {
+			return fmt.Errorf("unsupported compression algorithm: %s", algorithm)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,68 @@
+package layer6
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"ghostshell/app/layers/common"
+)
+
+// testMessagePackTransformation round trips data through MessagePack, timing
+// both directions and comparing the encoded size against the JSON encoding
+func testMessagePackTransformation(data map[string]string) (bool, string, *common.PresentationDiagnostics) {
+	diagnostics := &common.PresentationDiagnostics{}
+	diagnostics.DataSize = len(data)
+
+	start := time.Now()
+	wire, err := msgpack.Marshal(data)
+	encodeTime := time.Since(start)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "encoding"
+		return false, fmt.Sprintf("MessagePack encoding failed: %v", err), diagnostics
+	}
+	diagnostics.WireSize = len(wire)
+	diagnostics.EncodeTimeMs = float64(encodeTime.Microseconds()) / 1000
+
+	if jsonData, err := json.Marshal(data); err == nil {
+		diagnostics.JSONSize = len(jsonData)
+		if len(jsonData) > 0 {
+			diagnostics.SizeRatio = float64(len(wire)) / float64(len(jsonData))
+		}
+	}
+
+	start = time.Now()
+	var decoded map[string]string
+	err = msgpack.Unmarshal(wire, &decoded)
+	decodeTime := time.Since(start)
+	diagnostics.DecodeTimeMs = float64(decodeTime.Microseconds()) / 1000
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "decoding"
+		return false, fmt.Sprintf("MessagePack decoding failed: %v", err), diagnostics
+	}
+
+	// Verify data integrity
+	if len(decoded) != len(data) {
+		diagnostics.Error = "Data size mismatch"
+		diagnostics.OriginalSize = len(data)
+		diagnostics.DecodedSize = len(decoded)
+		return false, "MessagePack transformation failed: data size mismatch", diagnostics
+	}
+
+	for k, v := range data {
+		if got, ok := decoded[k]; !ok || got != v {
+			diagnostics.Error = "Data content mismatch"
+			diagnostics.MismatchedKey = k
+			return false, "MessagePack transformation failed: data content mismatch", diagnostics
+		}
+	}
+
+	diagnostics.Stage = "complete"
+	diagnostics.Success = true
+	return true, fmt.Sprintf("MessagePack round trip successful: %d bytes encoded vs %d bytes of JSON (ratio %.2f)",
+		len(wire), diagnostics.JSONSize, diagnostics.SizeRatio), diagnostics
+}
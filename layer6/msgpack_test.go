@@ -0,0 +1,96 @@
+package layer6
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/common"
+)
+
+func TestMessagePackTransformation(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]string
+	}{
+		{"empty", map[string]string{}},
+		{"ascii", map[string]string{"key1": "value1", "key2": "value2"}},
+		{"unicode and escapes", map[string]string{"greeting": "héllo wörld ✓", "quote": `"quoted"\n`}},
+		{"long value", map[string]string{"blob": fmt.Sprintf("%0300d", 7)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, msg, diag := testMessagePackTransformation(tt.data)
+			if !ok {
+				t.Fatalf("round trip failed: %s (%+v)", msg, diag)
+			}
+			if diag.Stage != "complete" || !diag.Success {
+				t.Errorf("stage %q success %v, want complete round trip", diag.Stage, diag.Success)
+			}
+			if diag.WireSize == 0 || diag.JSONSize == 0 {
+				t.Errorf("sizes not recorded: msgpack %d, json %d", diag.WireSize, diag.JSONSize)
+			}
+		})
+	}
+}
+
+func TestRunTestsMessagePackSmallerThanJSON(t *testing.T) {
+	// A representative record of short identifiers and values
+	payload := map[string]string{
+		"id":          "3f2b9c1e-8a47-4d2e-9b61-0c5a7e1d2f48",
+		"hostname":    "edge-router-01.dc2.example.net",
+		"interface":   "eth0",
+		"ip":          "192.0.2.44",
+		"mac":         "00:1a:2b:3c:4d:5e",
+		"status":      "up",
+		"speed":       "10000",
+		"mtu":         "9000",
+		"vlan":        "120",
+		"site":        "ams",
+		"rack":        "r14",
+		"owner":       "netops",
+		"last_seen":   "2025-01-01T12:00:00Z",
+		"description": "Uplink to core switch",
+	}
+
+	r := New([]map[string]string{payload}, WithMessagePack(true))
+	results, err := r.RunTests(context.Background(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("RunTests() error = %v", err)
+	}
+
+	var msgpackResult *common.TestResult
+	for i, sub := range results[0].SubResults {
+		if sub.Name == "MessagePack Round-Trip Test (Dataset 1)" {
+			msgpackResult = &results[0].SubResults[i]
+		}
+	}
+	if msgpackResult == nil {
+		t.Fatal("no MessagePack sub-result")
+	}
+	if msgpackResult.Status != common.StatusPassed {
+		t.Fatalf("status %s: %s", msgpackResult.Status, msgpackResult.Message)
+	}
+
+	custom := msgpackResult.Metrics.Custom
+	msgpackSize, _ := custom["msgpack_size_bytes"].(int)
+	jsonSize, _ := custom["json_size_bytes"].(int)
+	ratio, _ := custom["compression_ratio"].(float64)
+	if msgpackSize == 0 || jsonSize == 0 {
+		t.Fatalf("sizes not recorded: %v", custom)
+	}
+	if msgpackSize >= jsonSize {
+		t.Errorf("MessagePack is %d bytes, not smaller than %d bytes of JSON", msgpackSize, jsonSize)
+	}
+	if want := float64(msgpackSize) / float64(jsonSize); ratio != want || ratio >= 1 {
+		t.Errorf("compression_ratio = %v, want %v", ratio, want)
+	}
+	for _, key := range []string{"msgpack_encode_us", "msgpack_decode_us"} {
+		if _, ok := custom[key]; !ok {
+			t.Errorf("custom metric %q not recorded", key)
+		}
+	}
+}
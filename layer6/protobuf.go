@@ -0,0 +1,92 @@
+package layer6
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"ghostshell/app/layers/common"
+)
+
+// testProtobufTransformation round trips data through the Protobuf wire
+// format as a google.protobuf.Struct, timing both directions and comparing
+// the wire size against the JSON encoding
+func testProtobufTransformation(data map[string]string) (bool, string, *common.PresentationDiagnostics) {
+	diagnostics := &common.PresentationDiagnostics{}
+	diagnostics.DataSize = len(data)
+
+	fields := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		fields[k] = v
+	}
+
+	start := time.Now()
+	message, err := structpb.NewStruct(fields)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "struct_conversion"
+		return false, fmt.Sprintf("Protobuf struct conversion failed: %v", err), diagnostics
+	}
+	wire, err := proto.Marshal(message)
+	encodeTime := time.Since(start)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "encoding"
+		return false, fmt.Sprintf("Protobuf encoding failed: %v", err), diagnostics
+	}
+	diagnostics.WireSize = len(wire)
+	diagnostics.EncodeTimeMs = float64(encodeTime.Microseconds()) / 1000
+
+	if jsonData, err := json.Marshal(data); err == nil {
+		diagnostics.JSONSize = len(jsonData)
+		if len(jsonData) > 0 {
+			diagnostics.SizeRatio = float64(len(wire)) / float64(len(jsonData))
+		}
+	}
+
+	start = time.Now()
+	var decodedMessage structpb.Struct
+	err = proto.Unmarshal(wire, &decodedMessage)
+	decodeTime := time.Since(start)
+	diagnostics.DecodeTimeMs = float64(decodeTime.Microseconds()) / 1000
+	if err != nil {
+		diagnostics.Error = err.Error()
+		diagnostics.Stage = "decoding"
+		return false, fmt.Sprintf("Protobuf decoding failed: %v", err), diagnostics
+	}
+
+	decoded := make(map[string]string, len(decodedMessage.GetFields()))
+	for k, v := range decodedMessage.GetFields() {
+		s, ok := v.GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			diagnostics.Error = "Unexpected value type"
+			diagnostics.MismatchedKey = k
+			return false, fmt.Sprintf("Protobuf transformation failed: field %q is not a string", k), diagnostics
+		}
+		decoded[k] = s.StringValue
+	}
+
+	// Verify data integrity
+	if len(decoded) != len(data) {
+		diagnostics.Error = "Data size mismatch"
+		diagnostics.OriginalSize = len(data)
+		diagnostics.DecodedSize = len(decoded)
+		return false, "Protobuf transformation failed: data size mismatch", diagnostics
+	}
+
+	for k, v := range data {
+		if got, ok := decoded[k]; !ok || got != v {
+			diagnostics.Error = "Data content mismatch"
+			diagnostics.MismatchedKey = k
+			return false, "Protobuf transformation failed: data content mismatch", diagnostics
+		}
+	}
+
+	diagnostics.Stage = "complete"
+	diagnostics.Success = true
+	return true, fmt.Sprintf("Protobuf round trip successful: %d bytes on the wire vs %d bytes of JSON (ratio %.2f)",
+		len(wire), diagnostics.JSONSize, diagnostics.SizeRatio), diagnostics
+}
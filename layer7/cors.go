@@ -0,0 +1,164 @@
+package layer7
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// defaultCORSOrigin is the Origin sent when a target doesn't set one
+const defaultCORSOrigin = "https://example.com"
+
+// corsMandatoryHeaders must be present in every preflight response
+var corsMandatoryHeaders = []string{
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Methods",
+}
+
+// CORSTarget is an endpoint whose CORS preflight response is validated
+type CORSTarget struct {
+	URL             string            `json:"url"`
+	OriginHeader    string            `json:"origin_header,omitempty"`    // Origin to send; defaults to https://example.com
+	ExpectedHeaders map[string]string `json:"expected_headers,omitempty"` // Response headers and the values they must have
+}
+
+// WithCORSTargets adds endpoints whose CORS preflight responses are validated
+func (r *Runner) WithCORSTargets(targets []CORSTarget) *Runner {
+	r.CORSTargets = append(r.CORSTargets, targets...)
+	return r
+}
+
+// sendCORSPreflight sends an OPTIONS preflight for a POST with a
+// Content-Type header from origin
+func (r *Runner) sendCORSPreflight(ctx context.Context, endpoint, origin string) (*http.Response, error) {
+	client, err := r.createHTTPClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	// Browsers don't follow redirects of preflight requests
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodOptions, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	if r.BasicAuth.Enabled {
+		req.SetBasicAuth(r.BasicAuth.Username, r.BasicAuth.Password)
+	} else if r.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+r.BearerToken)
+	}
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	return resp, nil
+}
+
+// testCORSTarget sends a CORS preflight to target and checks the mandatory
+// and expected Access-Control headers of the response
+func (r *Runner) testCORSTarget(ctx context.Context, target CORSTarget) common.TestResult {
+	result := common.TestResult{
+		Layer:     7,
+		Name:      fmt.Sprintf("CORS Policy Validation (%s)", target.URL),
+		StartTime: time.Now(),
+	}
+
+	origin := target.OriginHeader
+	if origin == "" {
+		origin = defaultCORSOrigin
+	}
+
+	diagnostics := &common.ApplicationDiagnostics{URL: target.URL, Origin: origin}
+	result.Diagnostics.Application = diagnostics
+
+	requestStart := time.Now()
+	resp, err := r.sendCORSPreflight(ctx, target.URL, origin)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("CORS preflight to %s failed: %v", target.URL, err))
+	}
+	result.Metrics.ResponseTime = time.Since(requestStart)
+	diagnostics.StatusCode = resp.StatusCode
+
+	diagnostics.CORSHeaders = make(map[string]string)
+	for name, values := range resp.Header {
+		if strings.HasPrefix(name, "Access-Control-") {
+			diagnostics.CORSHeaders[name] = strings.Join(values, ", ")
+		}
+	}
+
+	for _, header := range corsMandatoryHeaders {
+		if resp.Header.Get(header) == "" {
+			diagnostics.MissingHeaders = append(diagnostics.MissingHeaders, header)
+		}
+	}
+
+	expected := make([]string, 0, len(target.ExpectedHeaders))
+	for header := range target.ExpectedHeaders {
+		expected = append(expected, header)
+	}
+	sort.Strings(expected)
+	var mismatches []string
+	for _, header := range expected {
+		want := target.ExpectedHeaders[header]
+		got := strings.Join(resp.Header.Values(header), ", ")
+		if got == "" {
+			diagnostics.MissingHeaders = append(diagnostics.MissingHeaders, http.CanonicalHeaderKey(header))
+			continue
+		}
+		if !strings.EqualFold(strings.TrimSpace(got), strings.TrimSpace(want)) {
+			diagnostics.MismatchedHeaders = append(diagnostics.MismatchedHeaders, http.CanonicalHeaderKey(header))
+			mismatches = append(mismatches, fmt.Sprintf("%s is %q, expected %q", http.CanonicalHeaderKey(header), got, want))
+		}
+	}
+
+	if len(diagnostics.MissingHeaders) > 0 {
+		diagnostics.Error = "missing CORS headers"
+		return result.Finish(common.StatusFailed, fmt.Sprintf("CORS preflight to %s (HTTP %d) is missing headers: %s",
+			target.URL, resp.StatusCode, strings.Join(diagnostics.MissingHeaders, ", ")))
+	}
+	if len(mismatches) > 0 {
+		diagnostics.Error = "unexpected CORS header values"
+		return result.Finish(common.StatusFailed, fmt.Sprintf("CORS preflight to %s returned unexpected values: %s",
+			target.URL, strings.Join(mismatches, "; ")))
+	}
+
+	allowOrigin := strings.TrimSpace(resp.Header.Get("Access-Control-Allow-Origin"))
+	if allowOrigin != "*" && allowOrigin != origin {
+		diagnostics.Error = "origin not allowed"
+		return result.Finish(common.StatusFailed, fmt.Sprintf("CORS preflight to %s does not allow origin %s (Access-Control-Allow-Origin: %s)",
+			target.URL, origin, allowOrigin))
+	}
+
+	// A wildcard origin on an endpoint that takes credentials is rejected by
+	// browsers and usually means the policy is broader than intended
+	credentialed := strings.EqualFold(resp.Header.Get("Access-Control-Allow-Credentials"), "true") ||
+		r.BasicAuth.Enabled || r.BearerToken != ""
+	if allowOrigin == "*" && credentialed {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("CORS preflight to %s allows any origin (*) on a credentialed endpoint",
+			target.URL))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("CORS policy of %s allows %s (methods: %s)",
+		target.URL, origin, resp.Header.Get("Access-Control-Allow-Methods")))
+}
@@ -0,0 +1,139 @@
+package layer7
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"ghostshell/app/layers/common"
+)
+
+// CSP issue severities
+const (
+	CSPSeverityHigh   = common.CSPSeverityHigh
+	CSPSeverityMedium = common.CSPSeverityMedium
+	CSPSeverityLow    = common.CSPSeverityLow
+)
+
+// CSPIssue describes a weakness found in a Content-Security-Policy
+type CSPIssue = common.CSPIssue
+
+// CSPAnalysis is the parsed form of a Content-Security-Policy header with its issues
+type CSPAnalysis = common.CSPAnalysis
+
+// cspSeverityPenalty is the score deduction per issue of each severity
+var cspSeverityPenalty = map[string]int{
+	CSPSeverityHigh:   30,
+	CSPSeverityMedium: 15,
+	CSPSeverityLow:    5,
+}
+
+// analyzeCSPHeader parses a Content-Security-Policy header value and scores it
+func analyzeCSPHeader(csp string) (CSPAnalysis, error) {
+	analysis := CSPAnalysis{
+		Directives: make(map[string][]string),
+		Issues:     []CSPIssue{},
+	}
+
+	if strings.TrimSpace(csp) == "" {
+		return analysis, fmt.Errorf("empty Content-Security-Policy header")
+	}
+
+	for _, part := range strings.Split(csp, ";") {
+		tokens := strings.Fields(part)
+		if len(tokens) == 0 {
+			continue
+		}
+
+		// Per the spec, only the first occurrence of a directive is honoured
+		name := strings.ToLower(tokens[0])
+		if _, exists := analysis.Directives[name]; exists {
+			continue
+		}
+		analysis.Directives[name] = tokens[1:]
+	}
+
+	if len(analysis.Directives) == 0 {
+		return analysis, fmt.Errorf("no directives found in Content-Security-Policy")
+	}
+
+	// Walk directives in a stable order so issues are reported consistently
+	names := make([]string, 0, len(analysis.Directives))
+	for name := range analysis.Directives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, directive := range names {
+		values := analysis.Directives[directive]
+		hasHashOrNonce := false
+		for _, v := range values {
+			lower := strings.ToLower(v)
+			if strings.HasPrefix(lower, "'sha256-") || strings.HasPrefix(lower, "'sha384-") ||
+				strings.HasPrefix(lower, "'sha512-") || strings.HasPrefix(lower, "'nonce-") {
+				hasHashOrNonce = true
+				break
+			}
+		}
+
+		for _, v := range values {
+			switch strings.ToLower(v) {
+			case "*":
+				if directive == "default-src" || directive == "script-src" {
+					analysis.Issues = append(analysis.Issues, CSPIssue{
+						Directive:   directive,
+						Value:       v,
+						Severity:    CSPSeverityHigh,
+						Description: "Wildcard source allows content to be loaded from any origin",
+					})
+				}
+			case "'unsafe-inline'":
+				// Hashes and nonces cause browsers to ignore 'unsafe-inline'
+				if !hasHashOrNonce {
+					analysis.Issues = append(analysis.Issues, CSPIssue{
+						Directive:   directive,
+						Value:       v,
+						Severity:    CSPSeverityHigh,
+						Description: "'unsafe-inline' without hashes or nonces permits inline script injection",
+					})
+				}
+			case "'unsafe-eval'":
+				analysis.Issues = append(analysis.Issues, CSPIssue{
+					Directive:   directive,
+					Value:       v,
+					Severity:    CSPSeverityHigh,
+					Description: "'unsafe-eval' allows strings to be evaluated as code",
+				})
+			}
+		}
+	}
+
+	if _, ok := analysis.Directives["frame-ancestors"]; !ok {
+		analysis.Issues = append(analysis.Issues, CSPIssue{
+			Directive:   "frame-ancestors",
+			Severity:    CSPSeverityMedium,
+			Description: "Missing frame-ancestors directive leaves the page open to clickjacking",
+		})
+	}
+
+	_, hasReportURI := analysis.Directives["report-uri"]
+	_, hasReportTo := analysis.Directives["report-to"]
+	if !hasReportURI && !hasReportTo {
+		analysis.Issues = append(analysis.Issues, CSPIssue{
+			Directive:   "report-uri",
+			Severity:    CSPSeverityLow,
+			Description: "No report-uri or report-to directive, so violations are not reported",
+		})
+	}
+
+	// Deduct from a perfect score for each issue found
+	analysis.Score = 100
+	for _, issue := range analysis.Issues {
+		analysis.Score -= cspSeverityPenalty[issue.Severity]
+	}
+	if analysis.Score < 0 {
+		analysis.Score = 0
+	}
+
+	return analysis, nil
+}
@@ -0,0 +1,122 @@
+package layer7
+
+import (
+	"testing"
+
+	"ghostshell/app/layers/common"
+)
+
+func TestAnalyzeCSPHeader(t *testing.T) {
+	tests := []struct {
+		name         string
+		csp          string
+		wantSeverity string
+		wantScore    int
+		wantIssues   []string // directive of each issue, in order
+	}{
+		{
+			name:         "strong policy",
+			csp:          "default-src 'self'; script-src 'self' 'nonce-r4nd0m'; frame-ancestors 'none'; report-to csp-endpoint",
+			wantSeverity: "",
+			wantScore:    100,
+		},
+		{
+			name:         "inline scripts allowed by hash",
+			csp:          "script-src 'self' 'unsafe-inline' 'sha256-abc123='; frame-ancestors 'self'; report-uri /csp",
+			wantSeverity: "",
+			wantScore:    100,
+		},
+		{
+			name:         "no reporting",
+			csp:          "default-src 'self'; frame-ancestors 'none'",
+			wantSeverity: CSPSeverityLow,
+			wantScore:    95,
+			wantIssues:   []string{"report-uri"},
+		},
+		{
+			name:         "missing frame-ancestors",
+			csp:          "default-src 'self'; report-uri /csp",
+			wantSeverity: CSPSeverityMedium,
+			wantScore:    85,
+			wantIssues:   []string{"frame-ancestors"},
+		},
+		{
+			name:         "unsafe-inline without hashes",
+			csp:          "default-src 'self'; script-src 'self' 'unsafe-inline'; frame-ancestors 'none'; report-uri /csp",
+			wantSeverity: CSPSeverityHigh,
+			wantScore:    70,
+			wantIssues:   []string{"script-src"},
+		},
+		{
+			name:         "weak policy",
+			csp:          "default-src *; script-src * 'unsafe-inline' 'unsafe-eval'",
+			wantSeverity: CSPSeverityHigh,
+			wantScore:    0,
+			wantIssues:   []string{"default-src", "script-src", "script-src", "script-src", "frame-ancestors", "report-uri"},
+		},
+		{
+			name:         "only the first of a repeated directive counts",
+			csp:          "script-src 'self'; script-src 'unsafe-eval'; frame-ancestors 'none'; report-uri /csp",
+			wantSeverity: "",
+			wantScore:    100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analysis, err := analyzeCSPHeader(tt.csp)
+			if err != nil {
+				t.Fatalf("analyzeCSPHeader: %v", err)
+			}
+			if got := analysis.HighestSeverity(); got != tt.wantSeverity {
+				t.Errorf("highest severity %q, want %q: %+v", got, tt.wantSeverity, analysis.Issues)
+			}
+			if analysis.Score != tt.wantScore {
+				t.Errorf("score %d, want %d", analysis.Score, tt.wantScore)
+			}
+			if len(analysis.Issues) != len(tt.wantIssues) {
+				t.Fatalf("issues %+v, want ones for %v", analysis.Issues, tt.wantIssues)
+			}
+			for i, directive := range tt.wantIssues {
+				if analysis.Issues[i].Directive != directive {
+					t.Errorf("issue %d is for %s, want %s", i, analysis.Issues[i].Directive, directive)
+				}
+			}
+		})
+	}
+
+	for _, csp := range []string{"", "  ;  ; "} {
+		if _, err := analyzeCSPHeader(csp); err == nil {
+			t.Errorf("no error for CSP %q", csp)
+		}
+	}
+}
+
+func TestApplyCSPAnalysis(t *testing.T) {
+	tests := []struct {
+		name string
+		csp  string
+		want common.TestStatus
+	}{
+		{"strong", "default-src 'self'; frame-ancestors 'none'; report-uri /csp", common.StatusPassed},
+		{"medium issue", "default-src 'self'; report-uri /csp", common.StatusWarning},
+		{"high issue", "default-src 'self' 'unsafe-eval'; frame-ancestors 'none'", common.StatusFailed},
+		{"missing header", "", common.StatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &common.TestResult{Status: common.StatusPassed, Message: "ok"}
+			info := &HTTPRequestInfo{ServerHeaders: map[string]string{}}
+			if tt.csp != "" {
+				info.ServerHeaders["Content-Security-Policy"] = tt.csp
+			}
+
+			New(nil, 0).applyCSPAnalysis(result, info)
+			if result.Status != tt.want {
+				t.Errorf("status %s, want %s: %s", result.Status, tt.want, result.Message)
+			}
+			if tt.csp != "" && info.CSPAnalysis == nil {
+				t.Error("csp_analysis not recorded")
+			}
+		})
+	}
+}
@@ -0,0 +1,536 @@
+package layer7
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	cbasn1 "golang.org/x/crypto/cryptobyte/asn1"
+	"golang.org/x/crypto/ocsp"
+
+	"ghostshell/app/layers/common"
+)
+
+// DefaultCTLogListURL is the list of known CT logs used when CTLogListURL is
+// unset
+const DefaultCTLogListURL = "https://www.gstatic.com/ct/log_list/v3/log_list.json"
+
+// ctRequiredSince is when browsers began requiring SCTs for newly issued
+// certificates; certificates issued before it may lack them
+var ctRequiredSince = time.Date(2018, time.April, 30, 0, 0, 0, 0, time.UTC)
+
+// OIDs of the SCT list extension in certificates and OCSP responses
+var (
+	oidEmbeddedSCT = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+	oidOCSPSCT     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 5}
+)
+
+// SCT entry types (RFC 6962 section 3.2)
+const (
+	ctX509Entry    = 0
+	ctPrecertEntry = 1
+)
+
+// SCTInfo and CTLogInfo are reported in the application diagnostics
+type (
+	SCTInfo   = common.SCTInfo
+	CTLogInfo = common.CTLogInfo
+)
+
+// errNoIssuer is returned for embedded SCTs when the server did not send the
+// issuer certificate, whose key is part of the signed data
+var errNoIssuer = errors.New("issuer certificate not sent, embedded SCT cannot be verified")
+
+// sct is a parsed signed certificate timestamp
+type sct struct {
+	source     string
+	logID      []byte
+	timestamp  uint64
+	extensions []byte
+	hashAlg    uint8
+	sigAlg     uint8
+	signature  []byte
+}
+
+// ctLog is a log from the CT log list
+type ctLog struct {
+	description string
+	operator    string
+	key         crypto.PublicKey
+}
+
+// ctLogList is the subset of the v3 log list schema used here
+type ctLogList struct {
+	Operators []struct {
+		Name string `json:"name"`
+		Logs []struct {
+			Description string `json:"description"`
+			LogID       string `json:"log_id"`
+			Key         string `json:"key"`
+		} `json:"logs"`
+		TiledLogs []struct {
+			Description string `json:"description"`
+			LogID       string `json:"log_id"`
+			Key         string `json:"key"`
+		} `json:"tiled_logs"`
+	} `json:"operators"`
+}
+
+// WithCTVerification checks the SCTs of HTTPS endpoints against the CT log
+// list at logListURL, or DefaultCTLogListURL when empty
+func (r *Runner) WithCTVerification(logListURL string) *Runner {
+	r.VerifyCTLogs = true
+	r.CTLogListURL = logListURL
+	return r
+}
+
+// knownCTLogs returns the logs of the CT log list keyed by log ID. The list
+// is fetched once per runner.
+func (r *Runner) knownCTLogs(ctx context.Context) (map[string]ctLog, error) {
+	r.ctLogsMu.Lock()
+	defer r.ctLogsMu.Unlock()
+	if r.ctLogs != nil {
+		return r.ctLogs, nil
+	}
+
+	listURL := r.CTLogListURL
+	if listURL == "" {
+		listURL = DefaultCTLogListURL
+	}
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, listURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CT log list returned HTTP %d", resp.StatusCode)
+	}
+
+	var list ctLogList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to parse CT log list: %w", err)
+	}
+
+	logs := make(map[string]ctLog)
+	for _, operator := range list.Operators {
+		add := func(description, logID, key string) {
+			der, err := base64.StdEncoding.DecodeString(key)
+			if err != nil {
+				return
+			}
+			pub, err := x509.ParsePKIXPublicKey(der)
+			if err != nil {
+				return
+			}
+			logs[logID] = ctLog{description: description, operator: operator.Name, key: pub}
+		}
+		for _, l := range operator.Logs {
+			add(l.Description, l.LogID, l.Key)
+		}
+		for _, l := range operator.TiledLogs {
+			add(l.Description, l.LogID, l.Key)
+		}
+	}
+	r.ctLogs = logs
+	return logs, nil
+}
+
+// parseSCTList parses a TLS-encoded SignedCertificateTimestampList
+func parseSCTList(data []byte, source string) ([]sct, error) {
+	input := cryptobyte.String(data)
+	var list cryptobyte.String
+	if !input.ReadUint16LengthPrefixed(&list) || !input.Empty() {
+		return nil, errors.New("malformed SCT list")
+	}
+	var scts []sct
+	for !list.Empty() {
+		var raw []byte
+		if !list.ReadUint16LengthPrefixed((*cryptobyte.String)(&raw)) {
+			return nil, errors.New("malformed SCT list")
+		}
+		s, err := parseSCT(raw, source)
+		if err != nil {
+			return nil, err
+		}
+		scts = append(scts, s)
+	}
+	return scts, nil
+}
+
+// parseSCT parses a single v1 SignedCertificateTimestamp
+func parseSCT(data []byte, source string) (sct, error) {
+	s := sct{source: source}
+	input := cryptobyte.String(data)
+	var version uint8
+	if !input.ReadUint8(&version) {
+		return s, errors.New("malformed SCT")
+	}
+	if version != 0 {
+		return s, fmt.Errorf("unsupported SCT version %d", version)
+	}
+	if !input.ReadBytes(&s.logID, 32) ||
+		!input.ReadUint64(&s.timestamp) ||
+		!input.ReadUint16LengthPrefixed((*cryptobyte.String)(&s.extensions)) ||
+		!input.ReadUint8(&s.hashAlg) ||
+		!input.ReadUint8(&s.sigAlg) ||
+		!input.ReadUint16LengthPrefixed((*cryptobyte.String)(&s.signature)) ||
+		!input.Empty() {
+		return s, errors.New("malformed SCT")
+	}
+	return s, nil
+}
+
+// embeddedSCTs returns the SCTs in cert's SCT list extension
+func embeddedSCTs(cert *x509.Certificate) ([]sct, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidEmbeddedSCT) {
+			continue
+		}
+		var list []byte
+		if _, err := asn1.Unmarshal(ext.Value, &list); err != nil {
+			return nil, fmt.Errorf("malformed SCT extension: %w", err)
+		}
+		return parseSCTList(list, "embedded")
+	}
+	return nil, nil
+}
+
+// ocspSCTs returns the SCTs in a stapled OCSP response
+func ocspSCTs(staple []byte, leaf, issuer *x509.Certificate) ([]sct, error) {
+	if len(staple) == 0 || issuer == nil {
+		return nil, nil
+	}
+	resp, err := ocsp.ParseResponseForCert(staple, leaf, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stapled OCSP response: %w", err)
+	}
+	for _, ext := range resp.Extensions {
+		if !ext.Id.Equal(oidOCSPSCT) {
+			continue
+		}
+		var list []byte
+		if _, err := asn1.Unmarshal(ext.Value, &list); err != nil {
+			return nil, fmt.Errorf("malformed OCSP SCT extension: %w", err)
+		}
+		return parseSCTList(list, "ocsp")
+	}
+	return nil, nil
+}
+
+// precertTBS returns cert's TBSCertificate with the SCT list extension
+// removed, which is what the log signed for an embedded SCT
+func precertTBS(cert *x509.Certificate) ([]byte, error) {
+	input := cryptobyte.String(cert.RawTBSCertificate)
+	var tbs cryptobyte.String
+	if !input.ReadASN1(&tbs, cbasn1.SEQUENCE) {
+		return nil, errors.New("malformed TBSCertificate")
+	}
+
+	var b cryptobyte.Builder
+	b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !tbs.Empty() {
+			var element cryptobyte.String
+			var tag cbasn1.Tag
+			if !tbs.ReadAnyASN1Element(&element, &tag) {
+				b.SetError(errors.New("malformed TBSCertificate"))
+				return
+			}
+			if tag != cbasn1.Tag(3).Constructed().ContextSpecific() {
+				b.AddBytes(element)
+				continue
+			}
+
+			// Rebuild [3] EXPLICIT Extensions without the SCT list
+			var wrapper, extensions cryptobyte.String
+			if !element.ReadASN1(&wrapper, tag) || !wrapper.ReadASN1(&extensions, cbasn1.SEQUENCE) {
+				b.SetError(errors.New("malformed extensions"))
+				return
+			}
+			b.AddASN1(tag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cbasn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for !extensions.Empty() {
+						var ext, body cryptobyte.String
+						var oid asn1.ObjectIdentifier
+						if !extensions.ReadASN1Element(&ext, cbasn1.SEQUENCE) {
+							b.SetError(errors.New("malformed extension"))
+							return
+						}
+						element := ext
+						if !element.ReadASN1(&body, cbasn1.SEQUENCE) || !body.ReadASN1ObjectIdentifier(&oid) {
+							b.SetError(errors.New("malformed extension"))
+							return
+						}
+						if !oid.Equal(oidEmbeddedSCT) {
+							b.AddBytes(ext)
+						}
+					}
+				})
+			})
+		}
+	})
+	return b.Bytes()
+}
+
+// signedData returns the data a log signed for s over the given entry
+func (s sct) signedData(entryType uint16, entry []byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(0) // Version v1
+	b.AddUint8(0) // certificate_timestamp
+	b.AddUint64(s.timestamp)
+	b.AddUint16(entryType)
+	b.AddBytes(entry)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(s.extensions)
+	})
+	return b.BytesOrPanic()
+}
+
+// entry returns the signed entry type and body for s: the leaf certificate
+// for SCTs from the handshake or OCSP, and the issuer key hash with the
+// precertificate TBS for embedded ones
+func (s sct) entry(leaf, issuer *x509.Certificate) (uint16, []byte, error) {
+	var b cryptobyte.Builder
+	if s.source != "embedded" {
+		b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(leaf.Raw)
+		})
+		return ctX509Entry, b.BytesOrPanic(), nil
+	}
+
+	if issuer == nil {
+		return 0, nil, errNoIssuer
+	}
+	tbs, err := precertTBS(leaf)
+	if err != nil {
+		return 0, nil, err
+	}
+	keyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	b.AddBytes(keyHash[:])
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(tbs)
+	})
+	return ctPrecertEntry, b.BytesOrPanic(), nil
+}
+
+// verify checks the signature of s with the log's public key
+func (s sct) verify(key crypto.PublicKey, leaf, issuer *x509.Certificate) error {
+	if s.hashAlg != 4 { // SHA-256
+		return fmt.Errorf("unsupported SCT hash algorithm %d", s.hashAlg)
+	}
+	entryType, entry, err := s.entry(leaf, issuer)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(s.signedData(entryType, entry))
+
+	switch pub := key.(type) {
+	case *ecdsa.PublicKey:
+		if s.sigAlg != 3 || !ecdsa.VerifyASN1(pub, digest[:], s.signature) {
+			return errors.New("invalid SCT signature")
+		}
+	case *rsa.PublicKey:
+		if s.sigAlg != 1 {
+			return errors.New("invalid SCT signature")
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], s.signature); err != nil {
+			return errors.New("invalid SCT signature")
+		}
+	default:
+		return fmt.Errorf("unsupported log key type %T", key)
+	}
+	return nil
+}
+
+// isTimeout reports whether err is a timeout
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// testCertificateTransparency collects the SCTs endpoint's certificate was
+// delivered with, from the TLS handshake, the certificate itself and a
+// stapled OCSP response, and verifies them against the known CT logs
+func (r *Runner) testCertificateTransparency(ctx context.Context, endpoint string) common.TestResult {
+	testResult := common.TestResult{
+		Layer:     7,
+		Name:      fmt.Sprintf("Certificate Transparency (%s)", endpoint),
+		StartTime: time.Now(),
+	}
+
+	verified := false
+	info := &CTLogInfo{}
+	diagnostics := &common.ApplicationDiagnostics{URL: endpoint, CTVerified: &verified, CTLog: info}
+	testResult.Diagnostics.Application = diagnostics
+
+	client, err := r.createHTTPClient()
+	if err != nil {
+		return testResult.Finish(common.StatusFailed, fmt.Sprintf("Failed to create HTTP client: %v", err))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return testResult.Finish(common.StatusFailed, fmt.Sprintf("Invalid endpoint %s: %v", endpoint, err))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return testResult.Finish(common.StatusFailed, fmt.Sprintf("TLS connection to %s failed: %v", endpoint, err))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+	resp.Body.Close()
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return testResult.Finish(common.StatusSkipped, fmt.Sprintf("No TLS certificate received from %s", endpoint))
+	}
+
+	state := resp.TLS
+	leaf := state.PeerCertificates[0]
+	var issuer *x509.Certificate
+	if len(state.PeerCertificates) > 1 {
+		issuer = state.PeerCertificates[1]
+	}
+	info.CertSubject = leaf.Subject.String()
+	info.CertIssuer = leaf.Issuer.String()
+	info.NotBefore = leaf.NotBefore
+
+	// Gather SCTs from every delivery mechanism
+	var scts []sct
+	var parseErrs []string
+	for _, raw := range state.SignedCertificateTimestamps {
+		s, err := parseSCT(raw, "tls")
+		if err != nil {
+			parseErrs = append(parseErrs, err.Error())
+			continue
+		}
+		scts = append(scts, s)
+	}
+	if embedded, err := embeddedSCTs(leaf); err != nil {
+		parseErrs = append(parseErrs, err.Error())
+	} else {
+		scts = append(scts, embedded...)
+	}
+	if stapled, err := ocspSCTs(state.OCSPResponse, leaf, issuer); err != nil {
+		parseErrs = append(parseErrs, err.Error())
+	} else {
+		scts = append(scts, stapled...)
+	}
+	if len(parseErrs) > 0 {
+		diagnostics.Error = strings.Join(parseErrs, "; ")
+	}
+
+	testResult.Metrics.Custom = map[string]interface{}{
+		"ct_verified": false,
+		"sct_count":   len(scts),
+	}
+
+	if len(scts) == 0 {
+		if leaf.NotBefore.Before(ctRequiredSince) {
+			return testResult.Finish(common.StatusSkipped, fmt.Sprintf("Certificate of %s has no SCTs but was issued on %s, before CT was required",
+				endpoint, leaf.NotBefore.Format("2006-01-02")))
+		}
+		return testResult.Finish(common.StatusWarning, fmt.Sprintf("Certificate of %s issued on %s has no SCTs: it is not logged in Certificate Transparency",
+			endpoint, leaf.NotBefore.Format("2006-01-02")))
+	}
+
+	logs, err := r.knownCTLogs(ctx)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		reason := "unavailable"
+		if isTimeout(err) {
+			reason = "timed out"
+		}
+		return testResult.Finish(common.StatusSkipped, fmt.Sprintf("%d SCTs found for %s but fetching the CT log list %s: %v",
+			len(scts), endpoint, reason, err))
+	}
+
+	var verifiedCount, unknownCount, invalidCount int
+	for _, s := range scts {
+		logID := base64.StdEncoding.EncodeToString(s.logID)
+		sctInfo := SCTInfo{
+			Source:    s.source,
+			LogID:     logID,
+			Timestamp: time.UnixMilli(int64(s.timestamp)).UTC(),
+		}
+		log, ok := logs[logID]
+		switch {
+		case !ok:
+			unknownCount++
+			sctInfo.Error = "log not in the CT log list"
+		default:
+			sctInfo.LogDescription = log.description
+			sctInfo.Operator = log.operator
+			if err := s.verify(log.key, leaf, issuer); errors.Is(err, errNoIssuer) {
+				unknownCount++
+				sctInfo.Error = err.Error()
+			} else if err != nil {
+				invalidCount++
+				sctInfo.Error = err.Error()
+			} else {
+				verifiedCount++
+				sctInfo.Verified = true
+			}
+		}
+		info.SCTs = append(info.SCTs, sctInfo)
+	}
+
+	verified = verifiedCount > 0
+	testResult.Metrics.Custom["ct_verified"] = verified
+	testResult.Metrics.Custom["scts_verified"] = verifiedCount
+
+	if verified {
+		operators := make([]string, 0, len(info.SCTs))
+		seen := make(map[string]bool)
+		for _, s := range info.SCTs {
+			if s.Verified && !seen[s.Operator] {
+				seen[s.Operator] = true
+				operators = append(operators, s.Operator)
+			}
+		}
+		return testResult.Finish(common.StatusPassed, fmt.Sprintf("%d of %d SCTs for %s verified (log operators: %s)",
+			verifiedCount, len(scts), endpoint, strings.Join(operators, ", ")))
+	}
+	if invalidCount > 0 {
+		return testResult.Finish(common.StatusFailed, fmt.Sprintf("SCT signatures for %s failed verification against known CT logs (%d invalid, %d unverifiable)",
+			endpoint, invalidCount, unknownCount))
+	}
+	return testResult.Finish(common.StatusWarning, fmt.Sprintf("None of the %d SCTs for %s could be verified: %s",
+		len(scts), endpoint, firstSCTError(info.SCTs)))
+}
+
+// firstSCTError returns the first error recorded for an SCT
+func firstSCTError(scts []SCTInfo) string {
+	for _, s := range scts {
+		if s.Error != "" {
+			return s.Error
+		}
+	}
+	return "unknown error"
+}
+
+// isHTTPS reports whether endpoint is an https URL
+func isHTTPS(endpoint string) bool {
+	return strings.HasPrefix(strings.ToLower(endpoint), "https://")
+}
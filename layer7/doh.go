@@ -0,0 +1,153 @@
+package layer7
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"ghostshell/app/layers/common"
+)
+
+// dnsMessageContentType is the media type of DNS wire format messages (RFC 8484)
+const dnsMessageContentType = "application/dns-message"
+
+// dohQueryTypes maps the supported query type names to DNS record types
+var dohQueryTypes = map[string]dnsmessage.Type{
+	"A":    dnsmessage.TypeA,
+	"AAAA": dnsmessage.TypeAAAA,
+	"MX":   dnsmessage.TypeMX,
+}
+
+// DoHTarget is a DNS-over-HTTPS resolver and the query to send it
+type DoHTarget struct {
+	ResolverURL string `json:"resolver_url"` // e.g. https://cloudflare-dns.com/dns-query
+	QueryName   string `json:"query_name"`
+	QueryType   string `json:"query_type,omitempty"` // A, AAAA or MX; defaults to A
+}
+
+// WithDoHTargets adds DNS-over-HTTPS resolvers to query
+func (r *Runner) WithDoHTargets(targets []DoHTarget) *Runner {
+	r.DoHTargets = append(r.DoHTargets, targets...)
+	return r
+}
+
+// buildDoHQuery packs a recursive query for name in DNS wire format. The ID
+// is zero as RFC 8484 recommends, so responses stay cacheable.
+func buildDoHQuery(name string, qtype dnsmessage.Type) ([]byte, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query name %q: %w", name, err)
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: qname, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+	}
+	return msg.Pack()
+}
+
+// formatDNSAnswer renders an answer record as "<type> <value>"
+func formatDNSAnswer(answer dnsmessage.Resource) string {
+	switch body := answer.Body.(type) {
+	case *dnsmessage.AResource:
+		return "A " + net.IP(body.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return "AAAA " + net.IP(body.AAAA[:]).String()
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("MX %d %s", body.Pref, body.MX)
+	case *dnsmessage.CNAMEResource:
+		return "CNAME " + body.CNAME.String()
+	default:
+		return answer.Header.Type.String()
+	}
+}
+
+// testDoHTarget sends the target's query to its resolver over HTTPS and
+// passes when the response carries at least one answer record
+func (r *Runner) testDoHTarget(ctx context.Context, target DoHTarget) common.TestResult {
+	result := common.TestResult{
+		Layer:     7,
+		Name:      fmt.Sprintf("DoH Query (%s: %s)", target.ResolverURL, target.QueryName),
+		StartTime: time.Now(),
+	}
+
+	queryType := strings.ToUpper(target.QueryType)
+	if queryType == "" {
+		queryType = "A"
+	}
+	diagnostics := &common.ApplicationDiagnostics{
+		URL:       target.ResolverURL,
+		QueryName: target.QueryName,
+		QueryType: queryType,
+	}
+	result.Diagnostics.Application = diagnostics
+
+	qtype, ok := dohQueryTypes[queryType]
+	if !ok {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Unsupported DoH query type %q (supported: A, AAAA, MX)", target.QueryType))
+	}
+
+	query, err := buildDoHQuery(target.QueryName, qtype)
+	if err != nil {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to build DoH query: %v", err))
+	}
+
+	client, err := r.createHTTPClient()
+	if err != nil {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to create HTTP client: %v", err))
+	}
+
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	requestInfo, body, err := r.executeHTTPRequestWithContent(ctx, client, http.MethodPost, target.ResolverURL, dnsMessageContentType, query, true)
+	if requestInfo != nil {
+		diagnostics.Query = requestInfo
+		result.Metrics.ResponseTime = requestInfo.TotalTime
+		result.Metrics.Latency = requestInfo.FirstByteTime
+	}
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("DoH query to %s failed: %v", target.ResolverURL, err))
+	}
+	if requestInfo.StatusCode != http.StatusOK {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("DoH resolver %s returned HTTP %d", target.ResolverURL, requestInfo.StatusCode))
+	}
+
+	var response dnsmessage.Message
+	if err := response.Unpack(body); err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Invalid DNS response from %s: %v", target.ResolverURL, err))
+	}
+
+	diagnostics.RCode = strings.TrimPrefix(response.Header.RCode.String(), "RCode")
+	for _, answer := range response.Answers {
+		diagnostics.Answers = append(diagnostics.Answers, formatDNSAnswer(answer))
+	}
+	result.Metrics.Custom = map[string]interface{}{
+		"answer_count": len(response.Answers),
+		"rcode":        diagnostics.RCode,
+	}
+
+	if len(response.Answers) == 0 {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("DoH resolver %s returned no %s records for %s (%s)",
+			target.ResolverURL, queryType, target.QueryName, diagnostics.RCode))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("DoH resolver %s answered %s %s with %d records in %d ms over %s",
+		target.ResolverURL, queryType, target.QueryName, len(response.Answers),
+		requestInfo.TotalTime.Milliseconds(), requestInfo.TLSVersion))
+}
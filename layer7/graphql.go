@@ -0,0 +1,166 @@
+package layer7
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// defaultGraphQLIntrospectionQuery is the smallest query that proves the
+// schema can be introspected
+const defaultGraphQLIntrospectionQuery = "{__schema{queryType{name}}}"
+
+// GraphQLTarget is a GraphQL endpoint to health check
+type GraphQLTarget struct {
+	URL                string   `json:"url"`
+	IntrospectionQuery string   `json:"introspection_query,omitempty"` // Defaults to {__schema{queryType{name}}}
+	CustomQuery        string   `json:"custom_query,omitempty"`        // Optional query run after introspection
+	ExpectedFields     []string `json:"expected_fields,omitempty"`     // Fields that must appear in the CustomQuery response
+}
+
+// graphQLResponse is the standard GraphQL response envelope
+type graphQLResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// errorMessages joins the messages of all GraphQL errors in the response
+func (g graphQLResponse) errorMessages() string {
+	messages := make([]string, len(g.Errors))
+	for i, e := range g.Errors {
+		messages[i] = e.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// WithGraphQLTargets adds GraphQL endpoints to health check
+func (r *Runner) WithGraphQLTargets(targets []GraphQLTarget) *Runner {
+	r.GraphQLTargets = append(r.GraphQLTargets, targets...)
+	return r
+}
+
+// postGraphQL sends query to endpoint and decodes the GraphQL response
+func (r *Runner) postGraphQL(ctx context.Context, client *http.Client, endpoint, query string) (*HTTPRequestInfo, graphQLResponse, error) {
+	var response graphQLResponse
+
+	body, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		return nil, response, fmt.Errorf("failed to marshal query: %w", err)
+	}
+
+	requestInfo, respBody, err := r.executeHTTPRequestWithBody(ctx, client, http.MethodPost, endpoint, body, true)
+	if err != nil {
+		return requestInfo, response, err
+	}
+	if requestInfo.StatusCode >= 400 && len(respBody) == 0 {
+		return requestInfo, response, fmt.Errorf("received HTTP status %d", requestInfo.StatusCode)
+	}
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return requestInfo, response, fmt.Errorf("invalid GraphQL response (HTTP %d): %w", requestInfo.StatusCode, err)
+	}
+	return requestInfo, response, nil
+}
+
+// testGraphQLTarget introspects a GraphQL endpoint, then runs its custom
+// query and checks the expected fields are present
+func (r *Runner) testGraphQLTarget(ctx context.Context, target GraphQLTarget) common.TestResult {
+	result := common.TestResult{
+		Layer:     7,
+		Name:      fmt.Sprintf("GraphQL Health Check (%s)", target.URL),
+		StartTime: time.Now(),
+	}
+
+	diagnostics := &common.ApplicationDiagnostics{URL: target.URL}
+	result.Diagnostics.Application = diagnostics
+
+	client, err := r.createHTTPClient()
+	if err != nil {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to create HTTP client: %v", err))
+	}
+
+	introspectionQuery := target.IntrospectionQuery
+	if introspectionQuery == "" {
+		introspectionQuery = defaultGraphQLIntrospectionQuery
+	}
+
+	introspectionInfo, introspection, err := r.postGraphQL(ctx, client, target.URL, introspectionQuery)
+	if introspectionInfo != nil {
+		diagnostics.Introspection = introspectionInfo
+		result.Metrics.Latency = introspectionInfo.FirstByteTime
+		result.Metrics.ResponseTime = introspectionInfo.TotalTime
+		result.Metrics.Custom = map[string]interface{}{
+			"introspection_time_ms": introspectionInfo.TotalTime.Milliseconds(),
+			"status_code":           introspectionInfo.StatusCode,
+		}
+	}
+	if err != nil {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("GraphQL introspection of %s failed: %v", target.URL, err))
+	}
+	if _, ok := introspection.Data["__schema"]; !ok {
+		msg := fmt.Sprintf("GraphQL introspection of %s returned no data.__schema", target.URL)
+		if len(introspection.Errors) > 0 {
+			msg += ": " + introspection.errorMessages()
+		}
+		return result.Finish(common.StatusFailed, msg)
+	}
+
+	if target.CustomQuery == "" {
+		return result.Finish(common.StatusPassed, fmt.Sprintf("GraphQL introspection of %s succeeded in %d ms",
+			target.URL, introspectionInfo.TotalTime.Milliseconds()))
+	}
+
+	queryInfo, query, err := r.postGraphQL(ctx, client, target.URL, target.CustomQuery)
+	if queryInfo != nil {
+		diagnostics.Query = queryInfo
+		result.Metrics.Custom["query_time_ms"] = queryInfo.TotalTime.Milliseconds()
+	}
+	if err != nil {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("GraphQL query to %s failed: %v", target.URL, err))
+	}
+	if len(query.Errors) > 0 {
+		diagnostics.Errors = query.errorMessages()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("GraphQL query to %s returned errors: %s",
+			target.URL, query.errorMessages()))
+	}
+
+	var missing []string
+	for _, field := range target.ExpectedFields {
+		if !hasJSONField(query.Data, field) {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		diagnostics.MissingFields = missing
+		return result.Finish(common.StatusFailed, fmt.Sprintf("GraphQL query to %s is missing expected fields: %s",
+			target.URL, strings.Join(missing, ", ")))
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("GraphQL endpoint %s is healthy (introspection %d ms, query %d ms, %d fields verified)",
+		target.URL, introspectionInfo.TotalTime.Milliseconds(), queryInfo.TotalTime.Milliseconds(), len(target.ExpectedFields)))
+}
+
+// hasJSONField reports whether field is a key anywhere in the decoded JSON value
+func hasJSONField(value interface{}, field string) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if key == field || hasJSONField(child, field) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			if hasJSONField(child, field) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package layer7
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"ghostshell/app/layers/common"
+)
+
+// graphQLWSSubprotocol is the subprotocol defined by the GraphQL over WebSocket spec
+const graphQLWSSubprotocol = "graphql-transport-ws"
+
+// graphQLWSMessage is a GraphQL over WebSocket protocol message
+type graphQLWSMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// RunGraphQLSubscription subscribes to a GraphQL endpoint over WebSocket and
+// waits for expectedMessages data frames before timeout
+func (r *Runner) RunGraphQLSubscription(ctx context.Context, wsURL string, subscriptionQuery string, expectedMessages int, timeout time.Duration) (common.TestResult, error) {
+	result := common.TestResult{
+		Layer:     7,
+		Name:      fmt.Sprintf("GraphQL Subscription %s", wsURL),
+		StartTime: time.Now(),
+	}
+	subscriptionID := fmt.Sprintf("ghostsuite-%d", result.StartTime.UnixNano())
+	diagnostics := &common.ApplicationDiagnostics{
+		URL:              wsURL,
+		SubscriptionID:   subscriptionID,
+		ExpectedMessages: common.Ptr(expectedMessages),
+		MessagesReceived: common.Ptr(0),
+	}
+	result.Diagnostics.Application = diagnostics
+
+	if expectedMessages <= 0 {
+		expectedMessages = 1
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Apply the runner's headers and authentication to the handshake
+	header := http.Header{}
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+	if r.BearerToken != "" {
+		header.Set("Authorization", "Bearer "+r.BearerToken)
+	}
+
+	dialer := websocket.Dialer{
+		HandshakeTimeout: timeout,
+		Subprotocols:     []string{graphQLWSSubprotocol},
+		Proxy:            http.ProxyFromEnvironment,
+	}
+
+	conn, _, err := dialer.DialContext(ctx, wsURL, header)
+	if err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("WebSocket connection failed: %v", err)), err
+	}
+	defer conn.Close()
+
+	// Close the connection when the context expires so blocking reads return
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	if err := conn.WriteJSON(graphQLWSMessage{Type: "connection_init"}); err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to send connection_init: %v", err)), err
+	}
+
+	// Wait for the server to acknowledge the connection
+	for {
+		var msg graphQLWSMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			diagnostics.Error = err.Error()
+			return result.Finish(common.StatusFailed, fmt.Sprintf("No connection_ack received: %v", err)), err
+		}
+		if msg.Type == "connection_ack" {
+			break
+		}
+		if msg.Type == "ping" {
+			conn.WriteJSON(graphQLWSMessage{Type: "pong"})
+		}
+	}
+
+	payload, err := json.Marshal(map[string]string{"query": subscriptionQuery})
+	if err != nil {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to encode subscription: %v", err)), err
+	}
+
+	subscribeStart := time.Now()
+	if err := conn.WriteJSON(graphQLWSMessage{ID: subscriptionID, Type: "subscribe", Payload: payload}); err != nil {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Failed to send subscribe: %v", err)), err
+	}
+
+	received := 0
+	var firstMessage, lastMessage time.Duration
+	var readErr error
+
+	for received < expectedMessages {
+		var msg graphQLWSMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			readErr = err
+			break
+		}
+
+		switch msg.Type {
+		case "next", "data":
+			if msg.ID != subscriptionID {
+				continue
+			}
+			received++
+			lastMessage = time.Since(subscribeStart)
+			if received == 1 {
+				firstMessage = lastMessage
+			}
+		case "error":
+			readErr = fmt.Errorf("subscription error: %s", string(msg.Payload))
+		case "complete":
+			readErr = fmt.Errorf("server completed subscription after %d messages", received)
+		case "ping":
+			conn.WriteJSON(graphQLWSMessage{Type: "pong"})
+		}
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	// Tell the server we are done with the subscription
+	conn.WriteJSON(graphQLWSMessage{ID: subscriptionID, Type: "complete"})
+
+	diagnostics.MessagesReceived = common.Ptr(received)
+	diagnostics.FirstMessageMs = firstMessage.Milliseconds()
+	diagnostics.LastMessageMs = lastMessage.Milliseconds()
+	result.Metrics.Latency = firstMessage
+	result.Metrics.ResponseTime = lastMessage
+
+	if received < expectedMessages {
+		if ctx.Err() != nil {
+			readErr = fmt.Errorf("timed out after %s", timeout)
+		}
+		if readErr != nil {
+			diagnostics.Error = readErr.Error()
+		}
+		return result.Finish(common.StatusFailed, fmt.Sprintf("Received %d of %d subscription messages: %v",
+			received, expectedMessages, readErr)), fmt.Errorf("subscription incomplete")
+	}
+
+	return result.Finish(common.StatusPassed, fmt.Sprintf("Received %d subscription messages (first: %d ms, last: %d ms)",
+		received, firstMessage.Milliseconds(), lastMessage.Milliseconds())), nil
+}
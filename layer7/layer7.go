@@ -38,29 +38,54 @@ type Runner struct {
 	}
 	BearerToken string
 	Proxy       string
+
+	// Days before certificate expiry at which HTTPS tests warn
+	CertExpiryWarningDays int
+
+	// SLA tracking against saved history
+	SLATargetPct   float64
+	SLAWindowHours int
+	SLAHistoryDir  string
+
+	// Content-Security-Policy analysis
+	ValidateCSP bool
+
+	// HTTP method enumeration
+	EnumerateHTTPMethods bool
+
+	// HTTP security header assessment
+	CheckSecurityHeaders bool
+
+	// GraphQL endpoint health checks
+	GraphQLTargets []GraphQLTarget
+
+	// GraphQL subscriptions over WebSocket
+	GraphQLSubscriptionEndpoints []string
+	GraphQLSubscriptionQuery     string
+	GraphQLExpectedMessages      int
+
+	// DNS-over-HTTPS resolver checks
+	DoHTargets []DoHTarget
+
+	// HTTP load test of Endpoints
+	LoadTest LoadTestConfig
+
+	// CORS preflight validation
+	CORSTargets []CORSTarget
+
+	// SMTP mail server connectivity
+	SMTPTargets []SMTPTarget
+
+	// Certificate Transparency verification of HTTPS endpoints
+	VerifyCTLogs bool
+	CTLogListURL string // Known CT logs in the v3 log list format; DefaultCTLogListURL when empty
+
+	ctLogsMu sync.Mutex
+	ctLogs   map[string]ctLog // Fetched CT log list, keyed by log ID
 }
 
 // HTTPRequestInfo stores detailed information about an HTTP request
-type HTTPRequestInfo struct {
-	URL               string            `json:"url"`
-	Method            string            `json:"method"`
-	DNSLookupTime     time.Duration     `json:"dns_lookup_time_ms"`
-	ConnectTime       time.Duration     `json:"connect_time_ms"`
-	TLSHandshakeTime  time.Duration     `json:"tls_handshake_time_ms,omitempty"`
-	FirstByteTime     time.Duration     `json:"first_byte_time_ms"`
-	TotalTime         time.Duration     `json:"total_time_ms"`
-	StatusCode        int               `json:"status_code"`
-	ContentLength     int64             `json:"content_length"`
-	ContentType       string            `json:"content_type"`
-	RemoteAddr        string            `json:"remote_addr"`
-	TLSVersion        string            `json:"tls_version,omitempty"`
-	TLSCipherSuite    string            `json:"tls_cipher_suite,omitempty"`
-	CertificateExpiry time.Time         `json:"certificate_expiry,omitempty"`
-	ServerHeaders     map[string]string `json:"server_headers"`
-	RedirectCount     int               `json:"redirect_count"`
-	Error             string            `json:"error,omitempty"`
-	ContentMatch      bool              `json:"content_match,omitempty"`
-}
+type HTTPRequestInfo = common.HTTPRequestInfo
 
 // New creates a new Layer7Runner
 func New(endpoints []string, timeout time.Duration) *Runner {
@@ -76,6 +101,8 @@ func New(endpoints []string, timeout time.Duration) *Runner {
 		VerifySSL:       true,
 		ValidateContent: false,
 		ContentPattern:  "",
+
+		CertExpiryWarningDays: 30,
 	}
 }
 
@@ -122,6 +149,46 @@ func (r *Runner) WithProxy(proxyURL string) *Runner {
 	return r
 }
 
+// WithSLATracking enables SLA compliance tracking against saved history
+func (r *Runner) WithSLATracking(targetPct float64, windowHours int, historyDir string) *Runner {
+	r.SLATargetPct = targetPct
+	r.SLAWindowHours = windowHours
+	r.SLAHistoryDir = historyDir
+	return r
+}
+
+// WithCertExpiryWarning sets how many days before expiry a certificate triggers a warning
+func (r *Runner) WithCertExpiryWarning(days int) *Runner {
+	r.CertExpiryWarningDays = days
+	return r
+}
+
+// WithCSPValidation enables Content-Security-Policy header analysis
+func (r *Runner) WithCSPValidation() *Runner {
+	r.ValidateCSP = true
+	return r
+}
+
+// WithMethodEnumeration enables HTTP method enumeration and dangerous method detection
+func (r *Runner) WithMethodEnumeration() *Runner {
+	r.EnumerateHTTPMethods = true
+	return r
+}
+
+// WithSecurityHeaderCheck enables HTTP security header assessment
+func (r *Runner) WithSecurityHeaderCheck() *Runner {
+	r.CheckSecurityHeaders = true
+	return r
+}
+
+// WithGraphQLSubscriptions adds GraphQL subscription endpoints to test over WebSocket
+func (r *Runner) WithGraphQLSubscriptions(endpoints []string, query string, expectedMessages int) *Runner {
+	r.GraphQLSubscriptionEndpoints = endpoints
+	r.GraphQLSubscriptionQuery = query
+	r.GraphQLExpectedMessages = expectedMessages
+	return r
+}
+
 // GetName returns the name of this layer
 func (r *Runner) GetName() string {
 	return "Application Layer"
@@ -189,7 +256,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 
 	// Test each endpoint with specified methods
 	var wg sync.WaitGroup
-	resultsChan := make(chan common.TestResult, len(r.Endpoints)*len(r.HTTPMethods))
+	resultsChan := make(chan common.TestResult, len(r.Endpoints)*(len(r.HTTPMethods)+2)+len(r.GraphQLTargets)+len(r.GraphQLSubscriptionEndpoints)+len(r.DoHTargets)+len(r.CORSTargets)+len(r.SMTPTargets)+1)
 
 	for _, endpoint := range r.Endpoints {
 		for _, method := range r.HTTPMethods {
@@ -205,13 +272,13 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
+				defer common.RecoverTestGoroutine(7, logger, resultsChan)()
 
-				testResult := common.TestResult{
-					Layer:     7,
-					Name:      fmt.Sprintf("%s %s", method, endpoint),
-					StartTime: time.Now(),
-					Metrics:   common.TestMetrics{},
-				}
+				testResult := common.GetTestResult()
+				defer common.PutTestResult(testResult)
+				testResult.Layer = 7
+				testResult.Name = fmt.Sprintf("%s %s", method, endpoint)
+				testResult.StartTime = time.Now()
 
 				// Create HTTP client
 				client, err := r.createHTTPClient()
@@ -220,7 +287,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 					testResult.Message = fmt.Sprintf("Failed to create HTTP client: %v", err)
 					testResult.EndTime = time.Now()
 					testResult.Metrics.Duration = testResult.EndTime.Sub(testResult.StartTime)
-					resultsChan <- testResult
+					resultsChan <- *testResult
 					return
 				}
 
@@ -249,7 +316,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 					}
 
 					// Set diagnostic data
-					testResult.Diagnostics = requestInfo
+					testResult.Diagnostics.Application = &common.ApplicationDiagnostics{Request: requestInfo}
 				}
 
 				// Determine test status
@@ -271,13 +338,185 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 						method, endpoint, requestInfo.StatusCode, requestInfo.TotalTime.Milliseconds())
 				}
 
-				resultsChan <- testResult
+				// Check the server certificate's remaining validity
+				if err == nil && requestInfo != nil {
+					r.applyCertExpiryCheck(testResult, requestInfo, endpoint)
+				}
+
+				// Analyze the Content-Security-Policy header
+				if r.ValidateCSP && err == nil && requestInfo != nil {
+					r.applyCSPAnalysis(testResult, requestInfo)
+				}
+
+				// Enumerate supported methods once per endpoint
+				if r.EnumerateHTTPMethods && err == nil && requestInfo != nil && method == r.HTTPMethods[0] {
+					r.applyMethodEnumeration(ctx, testResult, requestInfo, endpoint)
+				}
+
+				// Attach SLA compliance computed from previous runs
+				if r.SLATargetPct > 0 && requestInfo != nil {
+					sla, err := TrackSLACompliance(testResult.Name, r.SLAHistoryDir, r.SLATargetPct, r.SLAWindowHours)
+					if err != nil {
+						logger.Debug("SLA compliance unavailable",
+							zap.String("test", testResult.Name),
+							zap.Error(err))
+					} else {
+						requestInfo.SLACompliance = &sla
+						testResult.Metrics.Custom["sla_uptime_pct"] = sla.UptimePct
+						testResult.Metrics.Custom["sla_met"] = sla.SLAMet
+						if !sla.SLAMet && testResult.Status == common.StatusPassed {
+							testResult.Status = common.StatusWarning
+							testResult.Message += fmt.Sprintf(" - SLA not met: %.2f%% uptime (target %.2f%%)",
+								sla.UptimePct, sla.TargetPct)
+						}
+					}
+				}
+
+				resultsChan <- *testResult
+			}()
+		}
+	}
+
+	// Assess security headers once per endpoint
+	if r.CheckSecurityHeaders {
+		for _, endpoint := range r.Endpoints {
+			if ctx.Err() != nil {
+				logger.Warn("Context cancelled, skipping remaining tests")
+				break
+			}
+
+			endpoint := endpoint
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer common.RecoverTestGoroutine(7, logger, resultsChan)()
+				resultsChan <- r.testSecurityHeaders(ctx, endpoint)
+			}()
+		}
+	}
+
+	// Verify Certificate Transparency once per HTTPS endpoint
+	if r.VerifyCTLogs {
+		for _, endpoint := range r.Endpoints {
+			if ctx.Err() != nil {
+				logger.Warn("Context cancelled, skipping remaining tests")
+				break
+			}
+			if !isHTTPS(endpoint) {
+				continue
+			}
+
+			endpoint := endpoint
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer common.RecoverTestGoroutine(7, logger, resultsChan)()
+				resultsChan <- r.testCertificateTransparency(ctx, endpoint)
 			}()
 		}
 	}
 
+	// Health check GraphQL endpoints
+	for _, target := range r.GraphQLTargets {
+		if ctx.Err() != nil {
+			logger.Warn("Context cancelled, skipping remaining tests")
+			break
+		}
+
+		target := target
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer common.RecoverTestGoroutine(7, logger, resultsChan)()
+			resultsChan <- r.testGraphQLTarget(ctx, target)
+		}()
+	}
+
+	// Query DNS-over-HTTPS resolvers
+	for _, target := range r.DoHTargets {
+		if ctx.Err() != nil {
+			logger.Warn("Context cancelled, skipping remaining tests")
+			break
+		}
+
+		target := target
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer common.RecoverTestGoroutine(7, logger, resultsChan)()
+			resultsChan <- r.testDoHTarget(ctx, target)
+		}()
+	}
+
+	// Validate CORS preflight responses
+	for _, target := range r.CORSTargets {
+		if ctx.Err() != nil {
+			logger.Warn("Context cancelled, skipping remaining tests")
+			break
+		}
+
+		target := target
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer common.RecoverTestGoroutine(7, logger, resultsChan)()
+			resultsChan <- r.testCORSTarget(ctx, target)
+		}()
+	}
+
+	// Test SMTP mail servers
+	for _, target := range r.SMTPTargets {
+		if ctx.Err() != nil {
+			logger.Warn("Context cancelled, skipping remaining tests")
+			break
+		}
+
+		target := target
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer common.RecoverTestGoroutine(7, logger, resultsChan)()
+			resultsChan <- r.testSMTPTarget(ctx, target)
+		}()
+	}
+
+	// Test GraphQL subscriptions
+	for _, wsURL := range r.GraphQLSubscriptionEndpoints {
+		if ctx.Err() != nil {
+			logger.Warn("Context cancelled, skipping remaining tests")
+			break
+		}
+
+		wsURL := wsURL
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer common.RecoverTestGoroutine(7, logger, resultsChan)()
+
+			result, err := r.RunGraphQLSubscription(ctx, wsURL, r.GraphQLSubscriptionQuery, r.GraphQLExpectedMessages, r.Timeout)
+			if err != nil {
+				logger.Debug("GraphQL subscription test failed",
+					zap.String("url", wsURL),
+					zap.Error(err))
+			}
+			resultsChan <- result
+		}()
+	}
+
 	// Wait for all tests to complete
 	wg.Wait()
+
+	// Load test the endpoints once the single-request tests are done
+	if r.LoadTest.Enabled && ctx.Err() == nil {
+		resultsChan <- r.testLoad(ctx, logger)
+	}
 	close(resultsChan)
 
 	// Process results
@@ -315,7 +554,11 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 		parentResult.Status = common.StatusFailed
 		parentResult.Message = fmt.Sprintf("Layer 7 tests failed with %d failures and %d warnings",
 			failureCount, warningCount)
-		return []common.TestResult{parentResult}, fmt.Errorf("layer 7 tests failed with %d failures", failureCount)
+		return []common.TestResult{parentResult}, &common.LayerError{
+			Layer: 7,
+			Code:  common.ErrTestFailed,
+			Cause: fmt.Errorf("layer 7 tests failed with %d failures", failureCount),
+		}
 	} else if warningCount > 0 {
 		parentResult.Status = common.StatusWarning
 		parentResult.Message = fmt.Sprintf("Layer 7 tests completed with %d warnings", warningCount)
@@ -325,7 +568,7 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 	}
 
 	logger.Info("Layer 7 tests completed",
-		zap.String("status", string(parentResult.Status)),
+		zap.String("status", parentResult.Status.String()),
 		zap.Int("sub_tests", len(subResults)),
 		zap.Int("failures", failureCount),
 		zap.Int("warnings", warningCount),
@@ -334,6 +577,95 @@ func (r *Runner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.Tes
 	return []common.TestResult{parentResult}, nil
 }
 
+// applyCertExpiryCheck fails HTTPS tests whose certificate has expired and
+// warns when it expires within CertExpiryWarningDays
+func (r *Runner) applyCertExpiryCheck(testResult *common.TestResult, requestInfo *HTTPRequestInfo, endpoint string) {
+	parsedURL, err := url.Parse(endpoint)
+	if err != nil || parsedURL.Scheme != "https" || requestInfo.CertificateExpiry.IsZero() {
+		return
+	}
+
+	expiry := requestInfo.CertificateExpiry
+	remaining := time.Until(expiry)
+	days := int(remaining.Hours() / 24)
+	testResult.Metrics.Custom["cert_expiry_days"] = days
+	testResult.Metrics.Custom["cert_expiry_date"] = expiry.Format("2006-01-02")
+
+	host := parsedURL.Hostname()
+	if remaining <= 0 {
+		testResult.Status = common.StatusFailed
+		testResult.Message = fmt.Sprintf("Certificate for %s expired on %s", host, expiry.Format("2006-01-02"))
+		return
+	}
+
+	if remaining < time.Duration(r.CertExpiryWarningDays)*24*time.Hour && testResult.Status == common.StatusPassed {
+		testResult.Status = common.StatusWarning
+		testResult.Message = fmt.Sprintf("Certificate for %s expires in %d days (%s)",
+			host, days, expiry.Format("2006-01-02"))
+	}
+}
+
+// applyCSPAnalysis analyzes the response CSP header and adjusts the test status
+func (r *Runner) applyCSPAnalysis(testResult *common.TestResult, requestInfo *HTTPRequestInfo) {
+	csp := requestInfo.ServerHeaders["Content-Security-Policy"]
+	analysis, err := analyzeCSPHeader(csp)
+	if err != nil {
+		if testResult.Status != common.StatusFailed {
+			testResult.Status = common.StatusFailed
+			testResult.Message = fmt.Sprintf("CSP validation failed: %v", err)
+		}
+		return
+	}
+	requestInfo.CSPAnalysis = &analysis
+
+	switch analysis.HighestSeverity() {
+	case CSPSeverityHigh:
+		if testResult.Status != common.StatusFailed {
+			testResult.Status = common.StatusFailed
+			testResult.Message = fmt.Sprintf("CSP has high severity issues (score %d/100)", analysis.Score)
+		}
+	case CSPSeverityMedium:
+		if testResult.Status == common.StatusPassed {
+			testResult.Status = common.StatusWarning
+			testResult.Message += fmt.Sprintf(" - CSP has medium severity issues (score %d/100)", analysis.Score)
+		}
+	}
+}
+
+// applyMethodEnumeration records the endpoint's supported methods and fails the test if TRACE is enabled
+func (r *Runner) applyMethodEnumeration(ctx context.Context, testResult *common.TestResult, requestInfo *HTTPRequestInfo, endpoint string) {
+	allowed, dangerous, err := r.EnumerateSupportedMethods(ctx, endpoint)
+	if err != nil {
+		if testResult.Status == common.StatusPassed {
+			testResult.Status = common.StatusWarning
+			testResult.Message += fmt.Sprintf(" - method enumeration failed: %v", err)
+		}
+		return
+	}
+	requestInfo.AllowedMethods = allowed
+	requestInfo.DangerousMethods = dangerous
+
+	if len(dangerous) == 0 {
+		return
+	}
+
+	traceEnabled := false
+	for _, method := range dangerous {
+		if method == "TRACE" {
+			traceEnabled = true
+		}
+	}
+
+	if traceEnabled {
+		testResult.Status = common.StatusFailed
+		testResult.Message = fmt.Sprintf("TRACE method enabled (cross-site tracing risk); dangerous methods: %s",
+			strings.Join(dangerous, ", "))
+	} else if testResult.Status == common.StatusPassed {
+		testResult.Status = common.StatusWarning
+		testResult.Message += fmt.Sprintf(" - dangerous methods enabled: %s", strings.Join(dangerous, ", "))
+	}
+}
+
 // createHTTPClient creates an HTTP client with the given options
 func (r *Runner) createHTTPClient() (*http.Client, error) {
 	// Set up TLS configuration
@@ -385,6 +717,19 @@ func (r *Runner) createHTTPClient() (*http.Client, error) {
 
 // executeHTTPRequest performs an HTTP request and captures detailed metrics
 func (r *Runner) executeHTTPRequest(ctx context.Context, client *http.Client, method string, endpoint string) (*HTTPRequestInfo, error) {
+	reqInfo, _, err := r.executeHTTPRequestWithBody(ctx, client, method, endpoint, nil, false)
+	return reqInfo, err
+}
+
+// executeHTTPRequestWithBody is executeHTTPRequest with an optional JSON
+// request body. When captureBody is set the response body is returned.
+func (r *Runner) executeHTTPRequestWithBody(ctx context.Context, client *http.Client, method string, endpoint string, body []byte, captureBody bool) (*HTTPRequestInfo, []byte, error) {
+	return r.executeHTTPRequestWithContent(ctx, client, method, endpoint, "application/json", body, captureBody)
+}
+
+// executeHTTPRequestWithContent is executeHTTPRequestWithBody for a request
+// body of any content type
+func (r *Runner) executeHTTPRequestWithContent(ctx context.Context, client *http.Client, method string, endpoint string, contentType string, body []byte, captureBody bool) (*HTTPRequestInfo, []byte, error) {
 	reqInfo := &HTTPRequestInfo{
 		URL:           endpoint,
 		Method:        method,
@@ -392,16 +737,23 @@ func (r *Runner) executeHTTPRequest(ctx context.Context, client *http.Client, me
 	}
 
 	// Create request with context
-	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bodyReader)
 	if err != nil {
 		reqInfo.Error = err.Error()
-		return reqInfo, fmt.Errorf("failed to create request: %w", err)
+		return reqInfo, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Add headers
 	for k, v := range r.Headers {
 		req.Header.Set(k, v)
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
 
 	// Add auth if specified
 	if r.BasicAuth.Enabled {
@@ -484,7 +836,7 @@ func (r *Runner) executeHTTPRequest(ctx context.Context, client *http.Client, me
 
 	if err != nil {
 		reqInfo.Error = err.Error()
-		return reqInfo, err
+		return reqInfo, nil, err
 	}
 	defer resp.Body.Close()
 
@@ -509,23 +861,31 @@ func (r *Runner) executeHTTPRequest(ctx context.Context, client *http.Client, me
 		}
 	}
 
-	// Read response body if content validation is enabled
-	if r.ValidateContent && r.ContentPattern != "" {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return reqInfo, fmt.Errorf("failed to read response body: %w", err)
-		}
+	// Read response body if content validation is enabled or the caller wants it
+	validate := r.ValidateContent && r.ContentPattern != ""
+	if !validate && !captureBody {
+		return reqInfo, nil, nil
+	}
 
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return reqInfo, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if validate {
 		// Validate content
 		contentRegex, err := regexp.Compile(r.ContentPattern)
 		if err != nil {
-			return reqInfo, fmt.Errorf("invalid content pattern: %w", err)
+			return reqInfo, respBody, fmt.Errorf("invalid content pattern: %w", err)
 		}
 
-		reqInfo.ContentMatch = contentRegex.Match(body)
+		reqInfo.ContentMatch = contentRegex.Match(respBody)
 	}
 
-	return reqInfo, nil
+	if !captureBody {
+		respBody = nil
+	}
+	return reqInfo, respBody, nil
 }
 
 // tlsVersionToString converts TLS version constants to human-readable strings
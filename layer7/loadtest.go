@@ -0,0 +1,220 @@
+package layer7
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/common"
+)
+
+// Load test defaults used when the config leaves them unset
+const (
+	defaultLoadTestConcurrency = 10
+	defaultLoadTestDuration    = 10 * time.Second
+)
+
+// LoadTestConfig configures the HTTP load test of the runner's endpoints
+type LoadTestConfig struct {
+	Enabled      bool
+	Concurrency  int           // Concurrent workers; defaults to 10
+	Duration     time.Duration // How long workers keep sending requests; defaults to 10s
+	RampUpPeriod time.Duration // Worker start times are spread evenly over this period
+	MaxErrorPct  float64       // Error rates above this percentage fail the load test; 0 disables the check
+}
+
+// LoadTestResult aggregates the requests made during a load test
+type LoadTestResult struct {
+	Requests       int            `json:"requests"`
+	Errors         int            `json:"errors"`
+	ErrorRatePct   float64        `json:"error_rate_pct"`
+	RequestsPerSec float64        `json:"requests_per_sec"`
+	MinMs          float64        `json:"min_ms"`
+	MaxMs          float64        `json:"max_ms"`
+	MeanMs         float64        `json:"mean_ms"`
+	P50Ms          float64        `json:"p50_ms"`
+	P95Ms          float64        `json:"p95_ms"`
+	P99Ms          float64        `json:"p99_ms"`
+	StatusCodes    map[string]int `json:"status_codes"` // HTTP status -> count; "error" counts requests with no response
+	DurationMs     float64        `json:"duration_ms"`
+}
+
+// WithLoadTest enables the HTTP load test of the runner's endpoints
+func (r *Runner) WithLoadTest(config LoadTestConfig) *Runner {
+	config.Enabled = true
+	r.LoadTest = config
+	return r
+}
+
+// loadSample is the outcome of a single load test request
+type loadSample struct {
+	latency time.Duration
+	status  string
+	failed  bool
+}
+
+// runLoadTest has LoadTest.Concurrency workers GET every endpoint in turn
+// until LoadTest.Duration has passed, and aggregates their samples
+func (r *Runner) runLoadTest(ctx context.Context) (LoadTestResult, error) {
+	result := LoadTestResult{StatusCodes: make(map[string]int)}
+
+	concurrency := r.LoadTest.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultLoadTestConcurrency
+	}
+	duration := r.LoadTest.Duration
+	if duration <= 0 {
+		duration = defaultLoadTestDuration
+	}
+
+	client, err := r.createHTTPClient()
+	if err != nil {
+		return result, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	start := time.Now()
+	deadline := start.Add(duration)
+
+	var mu sync.Mutex
+	var samples []loadSample
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			defer common.RecoverTestGoroutine(7, common.Logger, nil)()
+
+			// Spread worker start times over the ramp-up period
+			if r.LoadTest.RampUpPeriod > 0 {
+				delay := r.LoadTest.RampUpPeriod * time.Duration(worker) / time.Duration(concurrency)
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			var local []loadSample
+			for time.Now().Before(deadline) && ctx.Err() == nil {
+				for _, endpoint := range r.Endpoints {
+					requestStart := time.Now()
+					info, err := r.executeHTTPRequest(ctx, client, "GET", endpoint)
+					if ctx.Err() != nil {
+						// Requests cut short by cancellation say nothing about the endpoint
+						break
+					}
+					sample := loadSample{latency: time.Since(requestStart)}
+					switch {
+					case err != nil:
+						sample.status = "error"
+						sample.failed = true
+					default:
+						sample.status = strconv.Itoa(info.StatusCode)
+						sample.failed = info.StatusCode >= 400
+					}
+					local = append(local, sample)
+				}
+			}
+
+			mu.Lock()
+			samples = append(samples, local...)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	result.DurationMs = float64(elapsed.Microseconds()) / 1000
+	if len(samples) == 0 {
+		return result, fmt.Errorf("no requests completed")
+	}
+
+	latencies := make([]time.Duration, len(samples))
+	var total time.Duration
+	for i, sample := range samples {
+		latencies[i] = sample.latency
+		total += sample.latency
+		result.StatusCodes[sample.status]++
+		if sample.failed {
+			result.Errors++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	result.Requests = len(samples)
+	result.ErrorRatePct = float64(result.Errors) / float64(result.Requests) * 100
+	result.RequestsPerSec = float64(result.Requests) / elapsed.Seconds()
+	result.MinMs = durationMs(latencies[0])
+	result.MaxMs = durationMs(latencies[len(latencies)-1])
+	result.MeanMs = durationMs(total / time.Duration(len(latencies)))
+	result.P50Ms = durationMs(latencyPercentile(latencies, 50))
+	result.P95Ms = durationMs(latencyPercentile(latencies, 95))
+	result.P99Ms = durationMs(latencyPercentile(latencies, 99))
+
+	return result, nil
+}
+
+// latencyPercentile returns the p-th percentile of sorted using the
+// nearest-rank method
+func latencyPercentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// durationMs converts d to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// testLoad runs the load test as a sub-test, failing it when the error rate
+// exceeds LoadTest.MaxErrorPct
+func (r *Runner) testLoad(ctx context.Context, logger *zap.Logger) common.TestResult {
+	concurrency := r.LoadTest.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultLoadTestConcurrency
+	}
+	duration := r.LoadTest.Duration
+	if duration <= 0 {
+		duration = defaultLoadTestDuration
+	}
+
+	result := common.TestResult{
+		Layer:     7,
+		Name:      fmt.Sprintf("HTTP Load Test (%d workers, %v)", concurrency, duration),
+		StartTime: time.Now(),
+	}
+
+	logger.Info("Starting HTTP load test",
+		zap.Strings("endpoints", r.Endpoints),
+		zap.Int("concurrency", concurrency),
+		zap.Duration("duration", duration),
+		zap.Duration("ramp_up", r.LoadTest.RampUpPeriod))
+
+	load, err := r.runLoadTest(ctx)
+	result.Metrics.Custom = map[string]interface{}{
+		"load_test": load,
+	}
+	if err != nil {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("HTTP load test failed: %v", err))
+	}
+
+	result.Metrics.Latency = time.Duration(load.P50Ms * float64(time.Millisecond))
+	result.Metrics.ResponseTime = time.Duration(load.MeanMs * float64(time.Millisecond))
+
+	summary := fmt.Sprintf("%d requests at %.1f req/s, latency p50/p95/p99 %.1f/%.1f/%.1f ms, %.2f%% errors",
+		load.Requests, load.RequestsPerSec, load.P50Ms, load.P95Ms, load.P99Ms, load.ErrorRatePct)
+	if r.LoadTest.MaxErrorPct > 0 && load.ErrorRatePct > r.LoadTest.MaxErrorPct {
+		return result.Finish(common.StatusFailed, fmt.Sprintf("HTTP load test error rate above %.2f%%: %s",
+			r.LoadTest.MaxErrorPct, summary))
+	}
+	return result.Finish(common.StatusPassed, fmt.Sprintf("HTTP load test completed: %s", summary))
+}
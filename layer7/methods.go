@@ -0,0 +1,102 @@
+package layer7
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// dangerousProbeMethods are requested explicitly because servers rarely list them in Allow
+var dangerousProbeMethods = []string{"TRACE", "TRACK", "CONNECT"}
+
+// EnumerateSupportedMethods discovers the HTTP methods endpoint accepts using
+// OPTIONS and explicit TRACE, TRACK and CONNECT probes. It returns every
+// allowed method and the subset that is considered dangerous.
+func (r *Runner) EnumerateSupportedMethods(ctx context.Context, endpoint string) ([]string, []string, error) {
+	client, err := r.createHTTPClient()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	// Probe responses must be judged on their own, not on redirect targets
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	allowed := make(map[string]bool)
+
+	resp, err := r.sendMethodProbe(ctx, client, http.MethodOptions, endpoint)
+	if err != nil {
+		return nil, nil, fmt.Errorf("OPTIONS request failed: %w", err)
+	}
+	for _, header := range resp.Header.Values("Allow") {
+		for _, method := range strings.Split(header, ",") {
+			if method = strings.ToUpper(strings.TrimSpace(method)); method != "" {
+				allowed[method] = true
+			}
+		}
+	}
+	if resp.StatusCode < 400 {
+		allowed[http.MethodOptions] = true
+	}
+
+	var dangerous []string
+	for _, method := range dangerousProbeMethods {
+		probe, err := r.sendMethodProbe(ctx, client, method, endpoint)
+
+		enabled := allowed[method]
+		isProxy := false
+		if err == nil {
+			enabled = enabled || (probe.StatusCode >= 200 && probe.StatusCode < 300)
+			isProxy = probe.Header.Get("Proxy-Agent") != ""
+		}
+		if !enabled {
+			continue
+		}
+
+		allowed[method] = true
+
+		// CONNECT is only expected on servers that announce themselves as proxies
+		if method == http.MethodConnect && isProxy {
+			continue
+		}
+		dangerous = append(dangerous, method)
+	}
+
+	methods := make([]string, 0, len(allowed))
+	for method := range allowed {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	return methods, dangerous, nil
+}
+
+// sendMethodProbe sends a bodiless request with the given method and discards the body
+func (r *Runner) sendMethodProbe(ctx context.Context, client *http.Client, method, endpoint string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	if r.BasicAuth.Enabled {
+		req.SetBasicAuth(r.BasicAuth.Username, r.BasicAuth.Password)
+	} else if r.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+r.BearerToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	return resp, nil
+}
@@ -0,0 +1,151 @@
+package layer7
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// optionsServer answers OPTIONS with the given Allow headers and returns 200
+// for the probe methods listed in accept, 405 otherwise
+func optionsServer(t *testing.T, allow []string, accept map[string]bool, proxy bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if proxy {
+			w.Header().Set("Proxy-Agent", "test-proxy")
+		}
+		switch {
+		case req.Method == http.MethodOptions:
+			for _, header := range allow {
+				w.Header().Add("Allow", header)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case accept[req.Method]:
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEnumerateSupportedMethods(t *testing.T) {
+	tests := []struct {
+		name          string
+		allow         []string
+		accept        map[string]bool
+		proxy         bool
+		wantAllowed   []string
+		wantDangerous []string
+	}{
+		{
+			name:        "safe methods only",
+			allow:       []string{"GET, HEAD, POST"},
+			wantAllowed: []string{"GET", "HEAD", "OPTIONS", "POST"},
+		},
+		{
+			name:        "multiple headers and odd spacing",
+			allow:       []string{"get,head", " PUT ,, delete "},
+			wantAllowed: []string{"DELETE", "GET", "HEAD", "OPTIONS", "PUT"},
+		},
+		{
+			name:          "trace listed in allow",
+			allow:         []string{"GET, TRACE"},
+			wantAllowed:   []string{"GET", "OPTIONS", "TRACE"},
+			wantDangerous: []string{"TRACE"},
+		},
+		{
+			name:          "trace and track answered but not listed",
+			allow:         []string{"GET"},
+			accept:        map[string]bool{"TRACE": true, "TRACK": true},
+			wantAllowed:   []string{"GET", "OPTIONS", "TRACE", "TRACK"},
+			wantDangerous: []string{"TRACE", "TRACK"},
+		},
+		{
+			name:          "connect on a non-proxy",
+			allow:         []string{"GET, CONNECT"},
+			wantAllowed:   []string{"CONNECT", "GET", "OPTIONS"},
+			wantDangerous: []string{"CONNECT"},
+		},
+		{
+			name:        "connect on a proxy",
+			allow:       []string{"GET, CONNECT"},
+			proxy:       true,
+			wantAllowed: []string{"CONNECT", "GET", "OPTIONS"},
+		},
+		{
+			name:        "no allow header",
+			wantAllowed: []string{"OPTIONS"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := optionsServer(t, tt.allow, tt.accept, tt.proxy)
+
+			allowed, dangerous, err := New(nil, 5*time.Second).EnumerateSupportedMethods(context.Background(), srv.URL)
+			if err != nil {
+				t.Fatalf("EnumerateSupportedMethods() error = %v", err)
+			}
+			if !reflect.DeepEqual(allowed, tt.wantAllowed) {
+				t.Errorf("allowed = %v, want %v", allowed, tt.wantAllowed)
+			}
+			if !reflect.DeepEqual(dangerous, tt.wantDangerous) {
+				t.Errorf("dangerous = %v, want %v", dangerous, tt.wantDangerous)
+			}
+		})
+	}
+}
+
+func TestApplyMethodEnumeration(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   []string
+		want    common.TestStatus
+		message string
+	}{
+		{"safe methods", []string{"GET, HEAD"}, common.StatusPassed, "ok"},
+		{"trace enabled", []string{"GET, TRACE, TRACK"}, common.StatusFailed, "TRACE method enabled"},
+		{"track enabled", []string{"GET, TRACK"}, common.StatusWarning, "dangerous methods enabled: TRACK"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := optionsServer(t, tt.allow, nil, false)
+
+			result := &common.TestResult{Status: common.StatusPassed, Message: "ok"}
+			info := &HTTPRequestInfo{}
+			New(nil, 5*time.Second).applyMethodEnumeration(context.Background(), result, info, srv.URL)
+
+			if result.Status != tt.want {
+				t.Errorf("status %s, want %s: %s", result.Status, tt.want, result.Message)
+			}
+			if !strings.Contains(result.Message, tt.message) {
+				t.Errorf("message %q does not contain %q", result.Message, tt.message)
+			}
+			if len(info.AllowedMethods) == 0 {
+				t.Error("allowed_methods not recorded")
+			}
+		})
+	}
+}
+
+func TestApplyMethodEnumerationUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	result := &common.TestResult{Status: common.StatusPassed, Message: "ok"}
+	New(nil, time.Second).applyMethodEnumeration(context.Background(), result, &HTTPRequestInfo{}, url)
+
+	if result.Status != common.StatusWarning {
+		t.Errorf("status %s, want %s: %s", result.Status, common.StatusWarning, result.Message)
+	}
+}
@@ -0,0 +1,214 @@
+package layer7
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// hstsMinMaxAge is the smallest HSTS max-age (180 days) given full credit
+const hstsMinMaxAge = 180 * 24 * 60 * 60
+
+// securityHeaderWeights are the points each header contributes to the score
+var securityHeaderWeights = []struct {
+	header string
+	points int
+}{
+	{"Strict-Transport-Security", 25},
+	{"Content-Security-Policy", 25},
+	{"X-Content-Type-Options", 15},
+	{"X-Frame-Options", 10},
+	{"Referrer-Policy", 10},
+	{"Permissions-Policy", 10},
+	{"X-XSS-Protection", 5},
+}
+
+// SecurityHeaderFinding is the assessment of a single response header
+type SecurityHeaderFinding = common.SecurityHeaderFinding
+
+// SecurityHeaderResult is the security header assessment of an endpoint
+type SecurityHeaderResult = common.SecurityHeaderResult
+
+// RunSecurityHeaderCheck requests endpoint and assesses the security headers
+// in its response. HSTS is only assessed for HTTPS endpoints.
+func (r *Runner) RunSecurityHeaderCheck(ctx context.Context, endpoint string) (SecurityHeaderResult, error) {
+	result := SecurityHeaderResult{Endpoint: endpoint}
+
+	client, err := r.createHTTPClient()
+	if err != nil {
+		return result, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+
+	requestInfo, err := r.executeHTTPRequest(ctx, client, http.MethodGet, endpoint)
+	if err != nil {
+		return result, err
+	}
+	result.StatusCode = requestInfo.StatusCode
+
+	parsedURL, _ := url.Parse(endpoint)
+	isHTTPS := parsedURL != nil && parsedURL.Scheme == "https"
+
+	earned, possible := 0, 0
+	for _, weight := range securityHeaderWeights {
+		if weight.header == "Strict-Transport-Security" && !isHTTPS {
+			continue
+		}
+
+		value := requestInfo.ServerHeaders[http.CanonicalHeaderKey(weight.header)]
+		finding := assessSecurityHeader(weight.header, value, weight.points, requestInfo.ServerHeaders)
+		result.Findings = append(result.Findings, finding)
+		earned += finding.Points
+		possible += finding.Max
+	}
+
+	if possible > 0 {
+		result.Score = earned * 100 / possible
+	}
+	return result, nil
+}
+
+// assessSecurityHeader scores one header value out of max points
+func assessSecurityHeader(header, value string, max int, headers map[string]string) SecurityHeaderFinding {
+	finding := SecurityHeaderFinding{Header: header, Value: value, Max: max}
+
+	pass := func(msg string) SecurityHeaderFinding {
+		finding.Status = common.StatusPassed
+		finding.Points = max
+		finding.Message = msg
+		return finding
+	}
+	warn := func(points int, msg string) SecurityHeaderFinding {
+		finding.Status = common.StatusWarning
+		finding.Points = points
+		finding.Message = msg
+		return finding
+	}
+
+	// X-XSS-Protection is deprecated, so it is the one header better left out
+	if header == "X-XSS-Protection" {
+		if value == "" || strings.TrimSpace(value) == "0" {
+			return pass("X-XSS-Protection is absent or disabled")
+		}
+		return warn(0, fmt.Sprintf("X-XSS-Protection: %s enables the deprecated XSS auditor; remove it or set it to 0", value))
+	}
+
+	if value == "" {
+		// CSP frame-ancestors supersedes X-Frame-Options
+		if header == "X-Frame-Options" && cspHasFrameAncestors(headers["Content-Security-Policy"]) {
+			return pass("X-Frame-Options absent but Content-Security-Policy sets frame-ancestors")
+		}
+		return warn(0, fmt.Sprintf("Missing %s header", header))
+	}
+
+	switch header {
+	case "Strict-Transport-Security":
+		maxAge := hstsMaxAge(value)
+		switch {
+		case maxAge <= 0:
+			return warn(0, "Strict-Transport-Security has no usable max-age")
+		case maxAge < hstsMinMaxAge:
+			return warn(max/2, fmt.Sprintf("Strict-Transport-Security max-age %d is below %d seconds", maxAge, hstsMinMaxAge))
+		}
+		return pass("Strict-Transport-Security is set")
+
+	case "Content-Security-Policy":
+		analysis, err := analyzeCSPHeader(value)
+		if err != nil {
+			return warn(0, fmt.Sprintf("Content-Security-Policy could not be parsed: %v", err))
+		}
+		points := max * analysis.Score / 100
+		if len(analysis.Issues) > 0 {
+			return warn(points, fmt.Sprintf("Content-Security-Policy has %d issues", len(analysis.Issues)))
+		}
+		return pass("Content-Security-Policy is set")
+
+	case "X-Content-Type-Options":
+		if !strings.EqualFold(strings.TrimSpace(value), "nosniff") {
+			return warn(0, fmt.Sprintf("X-Content-Type-Options should be nosniff, got %q", value))
+		}
+		return pass("X-Content-Type-Options is nosniff")
+
+	case "X-Frame-Options":
+		switch strings.ToUpper(strings.TrimSpace(value)) {
+		case "DENY", "SAMEORIGIN":
+			return pass(fmt.Sprintf("X-Frame-Options is %s", value))
+		}
+		return warn(max/2, fmt.Sprintf("X-Frame-Options %q is not DENY or SAMEORIGIN", value))
+
+	case "Referrer-Policy":
+		for _, policy := range strings.Split(value, ",") {
+			switch strings.ToLower(strings.TrimSpace(policy)) {
+			case "unsafe-url", "no-referrer-when-downgrade":
+				return warn(max/2, fmt.Sprintf("Referrer-Policy %q leaks full URLs to other origins", value))
+			}
+		}
+		return pass(fmt.Sprintf("Referrer-Policy is %s", value))
+	}
+
+	return pass(fmt.Sprintf("%s is set", header))
+}
+
+// hstsMaxAge returns the max-age directive of an HSTS header, or -1
+func hstsMaxAge(value string) int {
+	for _, directive := range strings.Split(value, ";") {
+		name, arg, found := strings.Cut(strings.TrimSpace(directive), "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(name), "max-age") {
+			continue
+		}
+		maxAge, err := strconv.Atoi(strings.Trim(strings.TrimSpace(arg), `"`))
+		if err != nil {
+			return -1
+		}
+		return maxAge
+	}
+	return -1
+}
+
+// cspHasFrameAncestors reports whether a CSP sets the frame-ancestors directive
+func cspHasFrameAncestors(csp string) bool {
+	if csp == "" {
+		return false
+	}
+	analysis, err := analyzeCSPHeader(csp)
+	if err != nil {
+		return false
+	}
+	_, ok := analysis.Directives["frame-ancestors"]
+	return ok
+}
+
+// testSecurityHeaders runs the security header check and reports it as a sub-test
+func (r *Runner) testSecurityHeaders(ctx context.Context, endpoint string) common.TestResult {
+	testResult := common.TestResult{
+		Layer:     7,
+		Name:      fmt.Sprintf("Security Header Check (%s)", endpoint),
+		StartTime: time.Now(),
+	}
+
+	check, err := r.RunSecurityHeaderCheck(ctx, endpoint)
+	testResult.Diagnostics.Application = &common.ApplicationDiagnostics{SecurityHeaders: &check}
+	if err != nil {
+		return testResult.Finish(common.StatusFailed, fmt.Sprintf("Security header check failed: %v", err))
+	}
+	testResult.Metrics.Custom = map[string]interface{}{
+		"security_score": check.Score,
+	}
+
+	warnings := check.Warnings()
+	if len(warnings) > 0 {
+		messages := make([]string, len(warnings))
+		for i, finding := range warnings {
+			messages[i] = finding.Message
+		}
+		return testResult.Finish(common.StatusWarning, fmt.Sprintf("Security header score %d/100 for %s: %s",
+			check.Score, endpoint, strings.Join(messages, "; ")))
+	}
+
+	return testResult.Finish(common.StatusPassed, fmt.Sprintf("Security header score %d/100 for %s", check.Score, endpoint))
+}
@@ -0,0 +1,119 @@
+package layer7
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// SLAResult summarises availability of a single test over a time window
+type SLAResult = common.SLAResult
+
+// historyFilePrefix is the file name prefix used by TestSession when saving history
+const historyFilePrefix = "layer_tests_"
+
+// TrackSLACompliance computes uptime for testName from the history files saved
+// within the last windowHours and compares it against targetUptimePct
+func TrackSLACompliance(testName string, historyDir string, targetUptimePct float64, windowHours int) (SLAResult, error) {
+	result := SLAResult{
+		TestName:    testName,
+		TargetPct:   targetUptimePct,
+		WindowHours: windowHours,
+	}
+
+	if testName == "" {
+		return result, fmt.Errorf("test name must be specified")
+	}
+	if targetUptimePct <= 0 || targetUptimePct > 100 {
+		return result, fmt.Errorf("target uptime must be between 0 and 100")
+	}
+	if windowHours <= 0 {
+		return result, fmt.Errorf("window must be greater than 0 hours")
+	}
+
+	files, err := os.ReadDir(historyDir)
+	if err != nil {
+		return result, fmt.Errorf("failed to read history directory: %w", err)
+	}
+
+	windowStart := time.Now().Add(-time.Duration(windowHours) * time.Hour)
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+
+		// Determine when the run happened, preferring the run ID in the file name
+		runTime, ok := parseHistoryTimestamp(file.Name())
+		if !ok {
+			info, err := file.Info()
+			if err != nil {
+				continue
+			}
+			runTime = info.ModTime()
+		}
+		if runTime.Before(windowStart) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(historyDir, file.Name()))
+		if err != nil {
+			continue
+		}
+
+		var results []common.TestResult
+		if err := json.Unmarshal(data, &results); err != nil {
+			continue
+		}
+
+		// Count every occurrence of the test, including nested sub-tests
+		for _, status := range findTestStatuses(results, testName) {
+			result.TotalRuns++
+			if status == common.StatusPassed || status == common.StatusWarning {
+				result.PassedRuns++
+			} else if status == common.StatusFailed {
+				result.ViolationCount++
+			}
+		}
+	}
+
+	if result.TotalRuns == 0 {
+		return result, fmt.Errorf("no runs of '%s' found in the last %d hours", testName, windowHours)
+	}
+
+	// Multiplying first keeps an uptime exactly on the target from rounding below it
+	result.UptimePct = float64(result.PassedRuns) * 100 / float64(result.TotalRuns)
+	result.DowntimeMins = float64(windowHours*60) * (100 - result.UptimePct) / 100
+	result.SLAMet = result.UptimePct >= targetUptimePct
+
+	return result, nil
+}
+
+// parseHistoryTimestamp extracts the run timestamp from a history file name
+func parseHistoryTimestamp(name string) (time.Time, bool) {
+	runID := strings.TrimSuffix(strings.TrimPrefix(name, historyFilePrefix), ".json")
+	t, err := time.ParseInLocation("20060102_150405", runID, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// findTestStatuses walks a result tree and returns the status of every result named testName
+func findTestStatuses(results []common.TestResult, testName string) []common.TestStatus {
+	var statuses []common.TestStatus
+	for _, r := range results {
+		if r.Name == testName {
+			statuses = append(statuses, r.Status)
+		}
+		if len(r.SubResults) > 0 {
+			statuses = append(statuses, findTestStatuses(r.SubResults, testName)...)
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,171 @@
+package layer7
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/common"
+)
+
+const slaTestName = "GET https://api.example.com"
+
+// writeSLAHistory saves one history file per run, each holding a result for
+// slaTestName nested below a layer result as TestSession saves them
+func writeSLAHistory(t *testing.T, dir string, start time.Time, statuses []common.TestStatus) {
+	t.Helper()
+	for i, status := range statuses {
+		runTime := start.Add(-time.Duration(i) * time.Minute)
+		results := []common.TestResult{{
+			Layer:  7,
+			Name:   "Application Layer Tests",
+			Status: status,
+			SubResults: []common.TestResult{
+				{Layer: 7, Name: slaTestName, Status: status},
+				{Layer: 7, Name: "GET https://other.example.com", Status: common.StatusFailed},
+			},
+		}}
+		data, err := json.Marshal(results)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("%s%s.json", historyFilePrefix, runTime.Format("20060102_150405"))
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// runStatuses returns total statuses of which failed are StatusFailed
+func runStatuses(total, failed int) []common.TestStatus {
+	statuses := make([]common.TestStatus, total)
+	for i := range statuses {
+		statuses[i] = common.StatusPassed
+		if i < failed {
+			statuses[i] = common.StatusFailed
+		}
+	}
+	return statuses
+}
+
+func TestTrackSLAComplianceBoundary(t *testing.T) {
+	tests := []struct {
+		name       string
+		total      int
+		failed     int
+		target     float64
+		wantUptime float64
+		wantMet    bool
+	}{
+		{"exactly 99.9", 1000, 1, 99.9, 99.9, true},
+		{"just below 99.9", 1000, 2, 99.9, 99.8, false},
+		{"exactly 99", 100, 1, 99, 99, true},
+		{"just below 99", 100, 2, 99, 98, false},
+		{"exactly 100", 10, 0, 100, 100, true},
+		// 57/100*100 rounds to just under 57
+		{"exactly 57", 100, 43, 57, 57, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeSLAHistory(t, dir, time.Now(), runStatuses(tt.total, tt.failed))
+
+			result, err := TrackSLACompliance(slaTestName, dir, tt.target, 720)
+			if err != nil {
+				t.Fatalf("TrackSLACompliance: %v", err)
+			}
+			if result.TotalRuns != tt.total || result.ViolationCount != tt.failed {
+				t.Errorf("%d runs with %d violations, want %d with %d", result.TotalRuns, result.ViolationCount, tt.total, tt.failed)
+			}
+			if result.UptimePct != tt.wantUptime {
+				t.Errorf("uptime %v%%, want %v%%", result.UptimePct, tt.wantUptime)
+			}
+			if result.SLAMet != tt.wantMet {
+				t.Errorf("SLA met %v at %v%% uptime for a %v%% target, want %v", result.SLAMet, result.UptimePct, tt.target, tt.wantMet)
+			}
+		})
+	}
+}
+
+func TestTrackSLAComplianceWindow(t *testing.T) {
+	dir := t.TempDir()
+	// Two recent passes, and failures from before the one hour window
+	writeSLAHistory(t, dir, time.Now(), runStatuses(2, 0))
+	writeSLAHistory(t, dir, time.Now().Add(-2*time.Hour), runStatuses(3, 3))
+
+	result, err := TrackSLACompliance(slaTestName, dir, 99.9, 1)
+	if err != nil {
+		t.Fatalf("TrackSLACompliance: %v", err)
+	}
+	if result.TotalRuns != 2 || !result.SLAMet {
+		t.Errorf("%d runs, SLA met %v, want only the 2 passing runs in the window", result.TotalRuns, result.SLAMet)
+	}
+
+	if _, err := TrackSLACompliance("GET https://missing.example.com", dir, 99.9, 1); err == nil {
+		t.Error("no error for a test without runs")
+	}
+}
+
+func TestRunTestsReportsSLACompliance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// 1 failure in 100 runs meets a 99% target exactly
+	dir := t.TempDir()
+	name := "GET " + server.URL
+	for i, status := range runStatuses(100, 1) {
+		results := []common.TestResult{{Layer: 7, Name: name, Status: status}}
+		data, err := json.Marshal(results)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := fmt.Sprintf("%s%s.json", historyFilePrefix, time.Now().Add(-time.Duration(i)*time.Minute).Format("20060102_150405"))
+		if err := os.WriteFile(filepath.Join(dir, file), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := New([]string{server.URL}, 5*time.Second).WithSLATracking(99, 24, dir)
+	results, err := r.RunTests(context.Background(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("RunTests: %v", err)
+	}
+	if len(results) != 1 || len(results[0].SubResults) != 1 {
+		t.Fatalf("got %+v, want one request result", results)
+	}
+	result := results[0].SubResults[0]
+	if result.Status != common.StatusPassed {
+		t.Errorf("status %s: %s", result.Status, result.Message)
+	}
+	if result.Metrics.Custom["sla_uptime_pct"] != 99.0 || result.Metrics.Custom["sla_met"] != true {
+		t.Errorf("custom metrics %v, want the SLA uptime and outcome", result.Metrics.Custom)
+	}
+
+	// sla_compliance reaches the serialised diagnostics
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Diagnostics struct {
+			SLACompliance *SLAResult `json:"sla_compliance"`
+		} `json:"diagnostics"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	sla := decoded.Diagnostics.SLACompliance
+	if sla == nil || sla.TotalRuns != 100 || !sla.SLAMet {
+		t.Errorf("serialised sla_compliance %+v, want 100 runs meeting the SLA", sla)
+	}
+}
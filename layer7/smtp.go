@@ -0,0 +1,271 @@
+package layer7
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// Well known SMTP ports
+const (
+	smtpPort           = 25
+	smtpsPort          = 465 // Implicit TLS (RFC 8314)
+	smtpSubmissionPort = 587
+)
+
+// SMTPTarget is a mail server to connect to and the session to run with it
+type SMTPTarget struct {
+	Host        string `json:"host"`
+	Port        int    `json:"port,omitempty"`     // 25, 465 or 587; defaults to 25
+	STARTTLS    bool   `json:"starttls,omitempty"` // Upgrade the connection with STARTTLS; ignored on 465, which always uses TLS
+	AuthEnabled bool   `json:"auth_enabled,omitempty"`
+	Username    string `json:"username,omitempty"`
+	Password    string `json:"password,omitempty"`
+	HeloDomain  string `json:"helo_domain,omitempty"` // Name sent with EHLO; defaults to localhost
+}
+
+// WithSMTPTargets adds mail servers to test
+func (r *Runner) WithSMTPTargets(targets []SMTPTarget) *Runner {
+	r.SMTPTargets = append(r.SMTPTargets, targets...)
+	return r
+}
+
+// recordingConn keeps a copy of what is read from the connection while
+// recording, so replies net/smtp consumes itself (the greeting and the EHLO
+// response) can be inspected
+type recordingConn struct {
+	net.Conn
+	recording bool
+	buf       bytes.Buffer
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if c.recording {
+		c.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+// take returns what was recorded since the last call
+func (c *recordingConn) take() string {
+	s := c.buf.String()
+	c.buf.Reset()
+	return s
+}
+
+// implicitTLSAuth tells an smtp.Auth that the session is encrypted. net/smtp
+// detects TLS by the connection's type, which recordingConn hides on port
+// 465, and PLAIN refuses to send credentials over a connection it thinks is
+// unencrypted.
+type implicitTLSAuth struct {
+	smtp.Auth
+}
+
+func (a implicitTLSAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	server.TLS = true
+	return a.Auth.Start(server)
+}
+
+// lastSMTPReply returns the text lines of the last reply in raw, without
+// their reply codes. A reply ends at the line whose code is followed by a
+// space rather than a hyphen.
+func lastSMTPReply(raw string) []string {
+	var reply, current []string
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if len(line) < 3 {
+			continue
+		}
+		text := ""
+		if len(line) > 4 {
+			text = line[4:]
+		}
+		current = append(current, text)
+		if len(line) == 3 || line[3] == ' ' {
+			reply, current = current, nil
+		}
+	}
+	return reply
+}
+
+// dialSMTP connects to the target, with implicit TLS on port 465
+func (r *Runner) dialSMTP(ctx context.Context, target SMTPTarget, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: r.Timeout}
+	if target.Port != smtpsPort {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+	tlsDialer := &tls.Dialer{
+		NetDialer: dialer,
+		Config:    &tls.Config{ServerName: target.Host, InsecureSkipVerify: !r.VerifySSL},
+	}
+	return tlsDialer.DialContext(ctx, "tcp", addr)
+}
+
+// smtpAuth picks a mechanism the server advertises for the target's
+// credentials
+func smtpAuth(target SMTPTarget, mechanisms string) (smtp.Auth, error) {
+	for _, mech := range strings.Fields(strings.ToUpper(mechanisms)) {
+		switch mech {
+		case "PLAIN":
+			return smtp.PlainAuth("", target.Username, target.Password, target.Host), nil
+		case "CRAM-MD5":
+			return smtp.CRAMMD5Auth(target.Username, target.Password), nil
+		}
+	}
+	return nil, fmt.Errorf("no supported AUTH mechanism (server offers %q)", mechanisms)
+}
+
+// testSMTPTarget opens an SMTP session with the target: it reads the banner,
+// sends EHLO, upgrades with STARTTLS and authenticates when configured, and
+// finally sends VRFY. Refused connections fail; a submission server (587)
+// without STARTTLS is a warning.
+func (r *Runner) testSMTPTarget(ctx context.Context, target SMTPTarget) common.TestResult {
+	if target.Port == 0 {
+		target.Port = smtpPort
+	}
+	addr := net.JoinHostPort(target.Host, strconv.Itoa(target.Port))
+
+	result := common.TestResult{
+		Layer:     7,
+		Name:      fmt.Sprintf("SMTP Test (%s)", addr),
+		StartTime: time.Now(),
+	}
+
+	info := &common.SMTPInfo{Host: target.Host, Port: target.Port, ImplicitTLS: target.Port == smtpsPort}
+	diagnostics := &common.ApplicationDiagnostics{URL: "smtp://" + addr, SMTP: info}
+	result.Diagnostics.Application = diagnostics
+	result.Metrics.Custom = map[string]interface{}{}
+
+	fail := func(msg string, err error) common.TestResult {
+		diagnostics.Error = err.Error()
+		return result.Finish(common.StatusFailed, fmt.Sprintf("%s: %v", msg, err))
+	}
+
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	connectStart := time.Now()
+	rawConn, err := r.dialSMTP(ctx, target, addr)
+	if err != nil {
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return fail(fmt.Sprintf("SMTP connection to %s refused", addr), err)
+		}
+		return fail(fmt.Sprintf("Failed to connect to SMTP server %s", addr), err)
+	}
+	connectTime := time.Since(connectStart)
+	info.ConnectMs = float64(connectTime.Microseconds()) / 1000
+	result.Metrics.Latency = connectTime
+	result.Metrics.Custom["connect_ms"] = info.ConnectMs
+
+	// net/smtp takes no context, so the session is bounded by the connection
+	// deadline and closed on cancellation
+	if deadline, ok := ctx.Deadline(); ok {
+		rawConn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { rawConn.Close() })
+	defer stop()
+
+	conn := &recordingConn{Conn: rawConn, recording: true}
+	client, err := smtp.NewClient(conn, target.Host)
+	if err != nil {
+		rawConn.Close()
+		return fail(fmt.Sprintf("SMTP server %s did not greet", addr), err)
+	}
+	defer client.Close()
+	info.Banner = strings.Join(lastSMTPReply(conn.take()), " ")
+
+	helo := target.HeloDomain
+	if helo == "" {
+		helo = "localhost"
+	}
+	if err := client.Hello(helo); err != nil {
+		return fail("Invalid HELO domain", err)
+	}
+
+	// The first extension lookup sends EHLO; its reply lists the extensions
+	// after the server's greeting line
+	hasStartTLS, _ := client.Extension("STARTTLS")
+	if reply := lastSMTPReply(conn.take()); len(reply) > 1 {
+		info.Extensions = reply[1:]
+	}
+	conn.recording = false
+	result.Metrics.Custom["extension_count"] = len(info.Extensions)
+
+	var warnings []string
+	if !info.ImplicitTLS {
+		switch {
+		case target.STARTTLS && hasStartTLS:
+			upgradeStart := time.Now()
+			err := client.StartTLS(&tls.Config{ServerName: target.Host, InsecureSkipVerify: !r.VerifySSL})
+			if err != nil {
+				return fail(fmt.Sprintf("STARTTLS with %s failed", addr), err)
+			}
+			info.TLSUpgraded = true
+			info.StartTLSMs = float64(time.Since(upgradeStart).Microseconds()) / 1000
+			result.Metrics.Custom["starttls_ms"] = info.StartTLSMs
+		case !hasStartTLS && (target.STARTTLS || target.Port == smtpSubmissionPort):
+			warnings = append(warnings, "STARTTLS not offered")
+		}
+	}
+
+	if target.AuthEnabled {
+		ok, mechanisms := client.Extension("AUTH")
+		if !ok {
+			return result.Finish(common.StatusFailed, fmt.Sprintf("SMTP server %s does not offer AUTH", addr))
+		}
+		auth, err := smtpAuth(target, mechanisms)
+		if err != nil {
+			return fail(fmt.Sprintf("Cannot authenticate with %s", addr), err)
+		}
+		if info.ImplicitTLS {
+			auth = implicitTLSAuth{auth}
+		}
+		if err := client.Auth(auth); err != nil {
+			return fail(fmt.Sprintf("SMTP authentication as %s failed", target.Username), err)
+		}
+		info.Authenticated = true
+	}
+
+	// Most servers disable VRFY (502) or answer it vaguely (252), so the code
+	// is recorded rather than checked
+	info.VRFYCode = 250
+	if err := client.Verify("admin@" + target.Host); err != nil {
+		var protoErr *textproto.Error
+		if !errors.As(err, &protoErr) {
+			return fail(fmt.Sprintf("VRFY to %s failed", addr), err)
+		}
+		info.VRFYCode = protoErr.Code
+	}
+	result.Metrics.Custom["vrfy_code"] = info.VRFYCode
+
+	client.Quit()
+
+	security := "no TLS"
+	switch {
+	case info.ImplicitTLS:
+		security = "implicit TLS"
+	case info.TLSUpgraded:
+		security = fmt.Sprintf("STARTTLS in %.1f ms", info.StartTLSMs)
+	}
+	msg := fmt.Sprintf("SMTP server %s connected in %.1f ms with %s, %d ESMTP extensions",
+		addr, info.ConnectMs, security, len(info.Extensions))
+	if len(warnings) > 0 {
+		return result.Finish(common.StatusWarning, fmt.Sprintf("%s: %s", msg, strings.Join(warnings, ", ")))
+	}
+	return result.Finish(common.StatusPassed, msg)
+}
@@ -3,17 +3,23 @@ package layers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
 	"ghostshell/app/layers/common"
+	"ghostshell/app/layers/exporters"
+	"ghostshell/app/layers/history"
 	"ghostshell/app/layers/layer1"
 	"ghostshell/app/layers/layer2"
 	"ghostshell/app/layers/layer3"
@@ -29,30 +35,61 @@ type TestSession struct {
 	Logger          *zap.Logger
 	Results         map[int][]common.TestResult
 	ProgressCallback common.TestProgressCallback
+	ResultCallback  common.TestResultCallback
+	ResultStreamCallback func(common.TestResult) // Called with each result as its layer completes
+	LayerHooks      map[int]LayerHookConfig // Layer -> hooks run around its runner
 	StartTime       time.Time
 	EndTime         time.Time
 	RunID           string
+	Tags            []string // Only layers tagged with one of these run; all when empty
+	BaselineRunID   string   // History run the results are compared against; none when empty
+
+	baseline     []common.TestResult // Results of BaselineRunID, loaded when a run starts
+	fileLogOnly  bool                // Keep the session's own logger off the console
+
+	mu           sync.Mutex    // Protects Results and resources
+	dependencies map[int][]int // Layer -> layers it depends on, built by initializeRunners
+	resources    ResourceSummary // Resource usage sampled across the run and, in sequential mode, each layer
+	tracer       trace.Tracer
+	parentSpan   trace.SpanContext // Remote parent of the session span, if any
 }
 
-// NewTestSession creates a new test session with the given configuration
-func NewTestSession(config *Config) (*TestSession, error) {
-	// Create logger
-	logger, err := initializeLogger(config.LogLevel)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize logger: %w", err)
-	}
+// LayerHookConfig holds callbacks run around a single layer's tests. Either
+// may be nil.
+type LayerHookConfig struct {
+	BeforeRun func(layer int, runner common.LayerRunner)
+	AfterRun  func(layer int, results []common.TestResult, err error)
+}
 
+// NewTestSession creates a new test session with the given configuration
+func NewTestSession(config *Config, opts ...SessionOption) (*TestSession, error) {
 	// Create run ID based on timestamp
 	runID := time.Now().Format("20060102_150405")
 
-	// Return new session
-	return &TestSession{
+	session := &TestSession{
 		Config:     config,
-		Logger:     logger,
 		Results:    make(map[int][]common.TestResult),
 		StartTime:  time.Now(),
 		RunID:      runID,
-	}, nil
+		BaselineRunID: config.BaselineRunID,
+		tracer:     defaultTracer(),
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(session)
+	}
+
+	// Create logger, unless an option supplied one
+	if session.Logger == nil {
+		logger, err := buildLogger(config.LogLevel, !session.fileLogOnly)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize logger: %w", err)
+		}
+		session.Logger = logger
+	}
+
+	return session, nil
 }
 
 // SetProgressCallback sets a callback function for progress updates
@@ -60,6 +97,100 @@ func (ts *TestSession) SetProgressCallback(callback common.TestProgressCallback)
 	ts.ProgressCallback = callback
 }
 
+// SetResultCallback sets a callback function for completed layer results
+func (ts *TestSession) SetResultCallback(callback common.TestResultCallback) {
+	ts.ResultCallback = callback
+}
+
+// SetResultStreamCallback sets a callback function called with each result
+// as soon as its layer completes, rather than when the whole run finishes
+func (ts *TestSession) SetResultStreamCallback(fn func(common.TestResult)) {
+	ts.ResultStreamCallback = fn
+}
+
+// WithResultStream sets the session's result stream callback
+func WithResultStream(fn func(common.TestResult)) SessionOption {
+	return func(ts *TestSession) {
+		ts.ResultStreamCallback = fn
+	}
+}
+
+// WithProgress sets the session's progress callback
+func WithProgress(callback common.TestProgressCallback) SessionOption {
+	return func(ts *TestSession) {
+		ts.ProgressCallback = callback
+	}
+}
+
+// WithLogger makes the session log to logger instead of creating its own
+// logger from Config.LogLevel
+func WithLogger(logger *zap.Logger) SessionOption {
+	return func(ts *TestSession) {
+		ts.Logger = logger
+	}
+}
+
+// WithPlugins loads the layer runner plugins of plugins into the session,
+// overriding Config.Plugins and Config.AllowPluginOverride, so sessions built
+// from a default config can still run custom layers. The session's config is
+// copied rather than modified.
+func WithPlugins(plugins []PluginConfig, allowOverride bool) SessionOption {
+	return func(ts *TestSession) {
+		config := *ts.Config
+		config.Plugins = plugins
+		config.AllowPluginOverride = allowOverride
+		ts.Config = &config
+	}
+}
+
+// WithFileLogging makes the session's logger write only to the log file,
+// still at Config.LogLevel, for use while the terminal is taken over by a UI
+func WithFileLogging() SessionOption {
+	return func(ts *TestSession) {
+		ts.fileLogOnly = true
+	}
+}
+
+// SetLayerHook sets the hooks run before and after the given layer's tests
+func (ts *TestSession) SetLayerHook(layer int, cfg LayerHookConfig) *TestSession {
+	if ts.LayerHooks == nil {
+		ts.LayerHooks = make(map[int]LayerHookConfig)
+	}
+	ts.LayerHooks[layer] = cfg
+	return ts
+}
+
+// runBeforeHook calls the layer's BeforeRun hook, if any
+func (ts *TestSession) runBeforeHook(layer int, runner common.LayerRunner) {
+	hook := ts.LayerHooks[layer].BeforeRun
+	if hook == nil {
+		return
+	}
+	defer ts.recoverHook(layer, "BeforeRun")
+	hook(layer, runner)
+}
+
+// runAfterHook calls the layer's AfterRun hook, if any
+func (ts *TestSession) runAfterHook(layer int, results []common.TestResult, err error) {
+	hook := ts.LayerHooks[layer].AfterRun
+	if hook == nil {
+		return
+	}
+	defer ts.recoverHook(layer, "AfterRun")
+	hook(layer, results, err)
+}
+
+// recoverHook logs a panic from a layer hook instead of letting it abort the run
+func (ts *TestSession) recoverHook(layer int, hook string) {
+	if r := recover(); r != nil {
+		ts.Logger.Warn("Layer hook panicked",
+			zap.Int("layer", layer),
+			zap.String("hook", hook),
+			zap.Any("panic", r),
+		)
+	}
+}
+
 // RunAllTests runs tests for all enabled layers
 func (ts *TestSession) RunAllTests() ([]common.TestResult, error) {
 	// Get enabled layers in priority order
@@ -79,30 +210,49 @@ func (ts *TestSession) RunAllTests() ([]common.TestResult, error) {
 	defer cancel()
 
 	// Initialize layer runners
-	runners, err := ts.initializeRunners(enabledLayers)
+	runners, err := ts.initializeRunners(enabledLayers, ts.layerFilters()...)
+	if err != nil {
+		return nil, err
+	}
+	if len(runners) == 0 && len(ts.Tags) > 0 {
+		return nil, fmt.Errorf("no enabled layers match tags %v", ts.Tags)
+	}
+
+	// Load the baseline up front so a bad run ID fails before any tests run
+	ts.baseline, err = ts.loadBaseline()
 	if err != nil {
 		return nil, err
 	}
 
+	// Trace the whole run under a single session span
+	ctx, span := ts.startSessionSpan(ctx)
+
 	// Run tests
 	var results []common.TestResult
 	ts.StartTime = time.Now()
 
 	if ts.Config.ConcurrentMode {
 		// Run tests concurrently
-		results, err = ts.runConcurrentTests(ctx, runners)
+		results, err = ts.runConcurrentTests(ctx, runners, ts.ResultStreamCallback)
 	} else {
 		// Run tests sequentially
-		results, err = ts.runSequentialTests(ctx, runners)
+		results, err = ts.runSequentialTests(ctx, runners, ts.ResultStreamCallback)
 	}
 
 	ts.EndTime = time.Now()
+	endSpan(span, results, err)
+
+	// Drop duplicate results before reporting
+	results = ts.deduplicateResults(results)
 
 	// Generate reports
 	if err := ts.generateReports(results); err != nil {
 		ts.Logger.Error("Failed to generate reports", zap.Error(err))
 	}
 
+	// Notify about the completed run
+	ts.notifyCompletion(results, err)
+
 	// Save results to history if enabled
 	if ts.Config.SaveHistoricalData {
 		if err := ts.saveHistoricalData(results); err != nil {
@@ -110,6 +260,9 @@ func (ts *TestSession) RunAllTests() ([]common.TestResult, error) {
 		}
 	}
 
+	// Export results to external systems
+	ts.exportResults(results)
+
 	return results, err
 }
 
@@ -147,10 +300,22 @@ func (ts *TestSession) RunSelectedLayers(layers []int) ([]common.TestResult, err
 	defer cancel()
 
 	// Initialize layer runners
-	runners, err := ts.initializeRunners(selectedLayers)
+	runners, err := ts.initializeRunners(selectedLayers, ts.layerFilters()...)
 	if err != nil {
 		return nil, err
 	}
+	if len(runners) == 0 && len(ts.Tags) > 0 {
+		return nil, fmt.Errorf("no selected layers match tags %v", ts.Tags)
+	}
+
+	// Load the baseline up front so a bad run ID fails before any tests run
+	ts.baseline, err = ts.loadBaseline()
+	if err != nil {
+		return nil, err
+	}
+
+	// Trace the whole run under a single session span
+	ctx, span := ts.startSessionSpan(ctx)
 
 	// Run tests
 	var results []common.TestResult
@@ -158,24 +323,35 @@ func (ts *TestSession) RunSelectedLayers(layers []int) ([]common.TestResult, err
 
 	if ts.Config.ConcurrentMode {
 		// Run tests concurrently
-		results, err = ts.runConcurrentTests(ctx, runners)
+		results, err = ts.runConcurrentTests(ctx, runners, ts.ResultStreamCallback)
 	} else {
 		// Run tests sequentially
-		results, err = ts.runSequentialTests(ctx, runners)
+		results, err = ts.runSequentialTests(ctx, runners, ts.ResultStreamCallback)
 	}
 
 	ts.EndTime = time.Now()
+	endSpan(span, results, err)
+
+	// Drop duplicate results before reporting
+	results = ts.deduplicateResults(results)
 
 	// Generate reports
 	if err := ts.generateReports(results); err != nil {
 		ts.Logger.Error("Failed to generate reports", zap.Error(err))
 	}
 
+	// Notify about the completed run
+	ts.notifyCompletion(results, err)
+
+	// Export results to external systems
+	ts.exportResults(results)
+
 	return results, err
 }
 
-// runSequentialTests runs tests one after another
-func (ts *TestSession) runSequentialTests(ctx context.Context, runners map[int]common.LayerRunner) ([]common.TestResult, error) {
+// runSequentialTests runs tests one after another. When onResult is set it
+// is called with each result as soon as its layer completes.
+func (ts *TestSession) runSequentialTests(ctx context.Context, runners map[int]common.LayerRunner, onResult func(common.TestResult)) ([]common.TestResult, error) {
 	var allResults []common.TestResult
 	layers := make([]int, 0, len(runners))
 
@@ -185,8 +361,25 @@ func (ts *TestSession) runSequentialTests(ctx context.Context, runners map[int]c
 	}
 	sort.Ints(layers)
 
+	// Final status of each completed layer, for dependency checks
+	statuses := make(map[int]common.TestStatus)
+
+	runStart := sampleResources()
+	ts.recordSample(runStart)
 	for _, layer := range layers {
 		runner := runners[layer]
+
+		// Skip layers whose dependencies failed in strict mode
+		failedDeps := ts.failedDependencies(layer, statuses)
+		if len(failedDeps) > 0 && ts.Config.DependencyMode == "strict" {
+			results := ts.dependencySkippedResults(layer, runner, failedDeps)
+			ts.applyBaseline(layer, results)
+			allResults = append(allResults, results...)
+			ts.storeResult(layer, results)
+			streamResults(onResult, results)
+			statuses[layer] = common.StatusSkipped
+			continue
+		}
 		
 		// Get layer specific timeout
 		layerConfig, err := ts.Config.GetLayerConfig(layer)
@@ -203,10 +396,22 @@ func (ts *TestSession) runSequentialTests(ctx context.Context, runners map[int]c
 			ts.ProgressCallback(layer, 0, 1, "Running")
 		}
 
-		// Run tests for this layer
-		results, err := ts.runLayerTestsWithRetry(layerCtx, layer, runner)
+		// Run tests for this layer, sampling resources around the run
+		ts.runBeforeHook(layer, runner)
+		before := sampleResources()
+		results, err := ts.runLayerTestsRecovered(layerCtx, layer, runner)
+		after := sampleResources()
+		ts.recordSample(after)
+		results = ts.recordResourceUsage(layer, results, resourceUsage(before, after))
+		ts.runAfterHook(layer, results, err)
 		layerCancel()
 
+		statuses[layer] = layerStatus(results, err)
+		if len(failedDeps) > 0 && ts.Config.DependencyMode == "warn" {
+			applyDependencyWarning(results, failedDeps)
+		}
+		ts.applyBaseline(layer, results)
+
 		// Progress update - complete
 		if ts.ProgressCallback != nil {
 			ts.ProgressCallback(layer, 1, 1, "Complete")
@@ -221,7 +426,8 @@ func (ts *TestSession) runSequentialTests(ctx context.Context, runners map[int]c
 			// Store results even if failed
 			if results != nil && len(results) > 0 {
 				allResults = append(allResults, results...)
-				ts.Results[layer] = results
+				ts.storeResult(layer, results)
+				streamResults(onResult, results)
 			}
 			
 			// Check if we should stop on failure
@@ -234,18 +440,45 @@ func (ts *TestSession) runSequentialTests(ctx context.Context, runners map[int]c
 		} else {
 			// Add results
 			allResults = append(allResults, results...)
-			ts.Results[layer] = results
+			ts.storeResult(layer, results)
+			streamResults(onResult, results)
 		}
 	}
+	ts.recordRunUsage(runStart, sampleResources())
 
 	return allResults, nil
 }
 
-// runConcurrentTests runs tests concurrently with controlled concurrency
-func (ts *TestSession) runConcurrentTests(ctx context.Context, runners map[int]common.LayerRunner) ([]common.TestResult, error) {
+// runLayerTestsRecovered runs a layer's tests, turning a panicking runner
+// into a failed result so the remaining layers still run
+func (ts *TestSession) runLayerTestsRecovered(ctx context.Context, layer int, runner common.LayerRunner) (results []common.TestResult, err error) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		common.LogPanic(ts.Logger, layer, recovered)
+		results = []common.TestResult{common.PanicResult(layer, recovered)}
+		err = &common.LayerError{Layer: layer, Code: common.ErrTestFailed, Cause: fmt.Errorf("layer %d panicked: %v", layer, recovered)}
+	}()
+	return ts.runLayerTestsWithRetry(ctx, layer, runner)
+}
+
+// runConcurrentTests runs tests concurrently with controlled concurrency.
+// When onResult is set it is called with each result as soon as its layer
+// completes, from one layer at a time.
+func (ts *TestSession) runConcurrentTests(ctx context.Context, runners map[int]common.LayerRunner, onResult func(common.TestResult)) ([]common.TestResult, error) {
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 	var allResults []common.TestResult
+
+	// Layers finish in parallel, so streaming is serialised
+	var streamMu sync.Mutex
+	stream := func(results []common.TestResult) {
+		streamMu.Lock()
+		defer streamMu.Unlock()
+		streamResults(onResult, results)
+	}
 	
 	// Create channel for concurrency control
 	semaphore := make(chan struct{}, ts.Config.MaxConcurrent)
@@ -258,8 +491,20 @@ func (ts *TestSession) runConcurrentTests(ctx context.Context, runners map[int]c
 	}
 	sort.Ints(layers)
 
+	// Each layer's channel is closed when it finishes so dependents can start
+	done := make(map[int]chan struct{}, len(layers))
+	for _, layer := range layers {
+		done[layer] = make(chan struct{})
+	}
+	statuses := make(map[int]common.TestStatus)
+
 	// Track errors
 	errChan := make(chan error, len(runners))
+
+	// Goroutine and heap counters are process wide, so each layer runs with
+	// a profiler label to attribute the goroutines it leaves running
+	runStart := sampleResources()
+	ts.recordSample(runStart)
 	
 	// Run each layer test in its own goroutine
 	for _, layer := range layers {
@@ -269,16 +514,72 @@ func (ts *TestSession) runConcurrentTests(ctx context.Context, runners map[int]c
 		layerConfig, err := ts.Config.GetLayerConfig(layer)
 		if err != nil {
 			ts.Logger.Error("Failed to get layer config", zap.Int("layer", layer), zap.Error(err))
+			close(done[layer])
 			wg.Done()
 			continue
 		}
 		
-		// Acquire semaphore slot
-		semaphore <- struct{}{}
-		
 		// Run test in goroutine
 		go func(l int, r common.LayerRunner, lc LayerConfig) {
 			defer wg.Done()
+			defer close(done[l])
+
+			// A panicking runner fails its layer instead of the whole run
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				common.LogPanic(ts.Logger, l, recovered)
+				results := []common.TestResult{common.PanicResult(l, recovered)}
+				ts.applyBaseline(l, results)
+				select {
+				case errChan <- &common.LayerError{Layer: l, Code: common.ErrTestFailed, Cause: fmt.Errorf("layer %d panicked: %v", l, recovered)}:
+				default:
+				}
+				mu.Lock()
+				statuses[l] = common.StatusFailed
+				allResults = append(allResults, results...)
+				mu.Unlock()
+				ts.storeResult(l, results)
+				stream(results)
+			}()
+
+			// Wait for dependencies to finish before taking a concurrency slot
+			var failedDeps []int
+			if ts.Config.DependencyMode != "ignore" {
+				for _, dep := range ts.dependencies[l] {
+					if ch, ok := done[dep]; ok {
+						select {
+						case <-ch:
+						case <-ctx.Done():
+						}
+					}
+				}
+				mu.Lock()
+				failedDeps = ts.failedDependencies(l, statuses)
+				mu.Unlock()
+			}
+
+			if len(failedDeps) > 0 && ts.Config.DependencyMode == "strict" {
+				results := ts.dependencySkippedResults(l, r, failedDeps)
+				ts.applyBaseline(l, results)
+				errChan <- &common.LayerError{
+					Layer: l,
+					Code:  common.ErrDependencyFailed,
+					Cause: fmt.Errorf("layer %d skipped: depends on %s which failed", l, formatLayerList(failedDeps)),
+				}
+				mu.Lock()
+				allResults = append(allResults, results...)
+				statuses[l] = common.StatusSkipped
+				mu.Unlock()
+				ts.storeResult(l, results)
+				stream(results)
+				return
+			}
+
+			// Acquire semaphore slot
+			semaphore <- struct{}{}
 			defer func() { <-semaphore }() // Release semaphore when done
 			
 			// Progress update - starting
@@ -290,8 +591,24 @@ func (ts *TestSession) runConcurrentTests(ctx context.Context, runners map[int]c
 			layerCtx, layerCancel := context.WithTimeout(ctx, lc.Timeout)
 			defer layerCancel()
 			
-			// Run tests for this layer
-			results, err := ts.runLayerTestsWithRetry(layerCtx, l, r)
+			// Run tests for this layer under its label, sampling resources
+			// around the run
+			ts.runBeforeHook(l, r)
+			before := sampleResources()
+			var results []common.TestResult
+			var err error
+			runLabelled(layerCtx, l, func(ctx context.Context) {
+				results, err = ts.runLayerTestsWithRetry(ctx, l, r)
+			})
+			after := sampleResources()
+			ts.recordSample(after)
+			results = ts.recordResourceUsage(l, results, labelledUsage(l, before, after))
+			ts.runAfterHook(l, results, err)
+
+			if len(failedDeps) > 0 && ts.Config.DependencyMode == "warn" {
+				applyDependencyWarning(results, failedDeps)
+			}
+			ts.applyBaseline(l, results)
 			
 			// Progress update - complete
 			if ts.ProgressCallback != nil {
@@ -307,11 +624,13 @@ func (ts *TestSession) runConcurrentTests(ctx context.Context, runners map[int]c
 			}
 			
 			// Store results
-			if results != nil && len(results) > 0 {
-				mu.Lock()
-				allResults = append(allResults, results...)
-				ts.Results[l] = results
-				mu.Unlock()
+			mu.Lock()
+			statuses[l] = layerStatus(results, err)
+			allResults = append(allResults, results...)
+			mu.Unlock()
+			if len(results) > 0 {
+				ts.storeResult(l, results)
+				stream(results)
 			}
 		}(layer, runners[layer], layerConfig)
 	}
@@ -319,6 +638,7 @@ func (ts *TestSession) runConcurrentTests(ctx context.Context, runners map[int]c
 	// Wait for all tests to complete
 	wg.Wait()
 	close(errChan)
+	ts.recordRunUsage(runStart, sampleResources())
 	
 	// Check for errors
 	var lastError error
@@ -332,8 +652,129 @@ func (ts *TestSession) runConcurrentTests(ctx context.Context, runners map[int]c
 	return allResults, lastError
 }
 
+// failedDependencies returns the dependencies of layer that have already
+// failed or been skipped. Dependencies that were not run are ignored.
+func (ts *TestSession) failedDependencies(layer int, statuses map[int]common.TestStatus) []int {
+	var failed []int
+	for _, dep := range ts.dependencies[layer] {
+		if status, ok := statuses[dep]; ok && (status == common.StatusFailed || status == common.StatusSkipped) {
+			failed = append(failed, dep)
+		}
+	}
+	return failed
+}
+
+// dependencySkippedResults builds the result for a layer skipped because its dependencies failed
+func (ts *TestSession) dependencySkippedResults(layer int, runner common.LayerRunner, failedDeps []int) []common.TestResult {
+	ts.Logger.Warn("Skipping layer due to failed dependencies",
+		zap.Int("layer", layer),
+		zap.Ints("failed_dependencies", failedDeps),
+	)
+
+	now := time.Now()
+	return []common.TestResult{{
+		Layer:     layer,
+		Name:      runner.GetName(),
+		Status:    common.StatusSkipped,
+		Message:   fmt.Sprintf("Skipped: depends on %s which failed", formatLayerList(failedDeps)),
+		StartTime: now,
+		EndTime:   now,
+	}}
+}
+
+// applyDependencyWarning prefixes each result message with the failed dependencies
+func applyDependencyWarning(results []common.TestResult, failedDeps []int) {
+	for i := range results {
+		results[i].Message = fmt.Sprintf("Warning: dependency %s failed; %s",
+			formatLayerList(failedDeps), results[i].Message)
+	}
+}
+
+// layerStatus reduces a layer's run to passed or failed for dependency checks
+func layerStatus(results []common.TestResult, err error) common.TestStatus {
+	if err != nil {
+		return common.StatusFailed
+	}
+	for _, result := range results {
+		if result.Status == common.StatusFailed {
+			return common.StatusFailed
+		}
+	}
+	return common.StatusPassed
+}
+
+// formatLayerList renders layer numbers as "layer 2" or "layers 1, 2"
+func formatLayerList(layers []int) string {
+	names := make([]string, len(layers))
+	for i, layer := range layers {
+		names[i] = strconv.Itoa(layer)
+	}
+	if len(layers) == 1 {
+		return "layer " + names[0]
+	}
+	return "layers " + strings.Join(names, ", ")
+}
+
+// storeResult records the results for a layer
+func (ts *TestSession) storeResult(layer int, results []common.TestResult) {
+	ts.mu.Lock()
+	ts.Results[layer] = results
+	ts.mu.Unlock()
+
+	if ts.ResultCallback != nil {
+		ts.ResultCallback(layer, results)
+	}
+}
+
+// streamResults passes each result to onResult, if set
+func streamResults(onResult func(common.TestResult), results []common.TestResult) {
+	if onResult == nil {
+		return
+	}
+	for _, result := range results {
+		onResult(result)
+	}
+}
+
+// deduplicateResults keeps only the most recent result for each (Layer, Name) pair
+func (ts *TestSession) deduplicateResults(results []common.TestResult) []common.TestResult {
+	type resultKey struct {
+		layer int
+		name  string
+	}
+
+	index := make(map[resultKey]int)
+	deduped := make([]common.TestResult, 0, len(results))
+	duplicates := 0
+
+	for _, result := range results {
+		key := resultKey{layer: result.Layer, name: result.Name}
+		if i, exists := index[key]; exists {
+			duplicates++
+			if result.StartTime.After(deduped[i].StartTime) {
+				deduped[i] = result
+			}
+			continue
+		}
+		index[key] = len(deduped)
+		deduped = append(deduped, result)
+	}
+
+	if duplicates > 0 {
+		ts.Logger.Warn("Duplicate layer results found, keeping most recent",
+			zap.Int("duplicates", duplicates),
+			zap.String("run_id", ts.RunID),
+		)
+	}
+
+	return deduped
+}
+
 // runLayerTestsWithRetry runs tests for a specific layer with retry logic
-func (ts *TestSession) runLayerTestsWithRetry(ctx context.Context, layer int, runner common.LayerRunner) ([]common.TestResult, error) {
+func (ts *TestSession) runLayerTestsWithRetry(ctx context.Context, layer int, runner common.LayerRunner) (results []common.TestResult, err error) {
+	ctx, span := ts.startLayerSpan(ctx, layer)
+	defer func() { endSpan(span, results, err) }()
+
 	layerConfig, err := ts.Config.GetLayerConfig(layer)
 	if err != nil {
 		return nil, err
@@ -341,7 +782,6 @@ func (ts *TestSession) runLayerTestsWithRetry(ctx context.Context, layer int, ru
 
 	var attempt int
 	var lastErr error
-	var results []common.TestResult
 
 	// Determine retry settings
 	retry := layerConfig.Retry
@@ -353,11 +793,8 @@ func (ts *TestSession) runLayerTestsWithRetry(ctx context.Context, layer int, ru
 	for attempt = 0; attempt <= retry.Count; attempt++ {
 		// If not first attempt, wait before retry
 		if attempt > 0 {
-			// Calculate backoff duration
-			waitTime := retry.Interval
-			for i := 1; i < attempt; i++ {
-				waitTime = time.Duration(float64(waitTime) * retry.BackoffFactor)
-			}
+			// Calculate backoff duration with jitter
+			waitTime := retry.Backoff(attempt)
 			
 			ts.Logger.Info("Retrying layer test",
 				zap.Int("layer", layer),
@@ -386,6 +823,17 @@ func (ts *TestSession) runLayerTestsWithRetry(ctx context.Context, layer int, ru
 		if lastErr == nil {
 			return results, nil
 		}
+
+		// Retrying can't fix missing privileges, bad configuration or cancellation
+		var layerErr *common.LayerError
+		if errors.As(lastErr, &layerErr) && !layerErr.Retryable() {
+			ts.Logger.Warn("Not retrying layer test",
+				zap.Int("layer", layer),
+				zap.String("code", string(layerErr.Code)),
+				zap.Error(lastErr),
+			)
+			return results, lastErr
+		}
 		
 		// If we've reached the maximum retry count, return the last error
 		if attempt >= retry.Count {
@@ -407,6 +855,14 @@ func (ts *TestSession) generateReports(results []common.TestResult) error {
 		generator.OutputDir = ts.Config.OutputPath
 	}
 
+	// Sign the report when a key is configured
+	key, err := ResolveReportSigningKey(ts.Config)
+	if err != nil {
+		return err
+	}
+	generator.SigningKey = key
+	generator.ConfigPath = ts.Config.sourcePath
+
 	// Generate report in configured format
 	format := common.ReportFormat(ts.Config.OutputFormat)
 	
@@ -423,80 +879,108 @@ func (ts *TestSession) generateReports(results []common.TestResult) error {
 	return nil
 }
 
+// exportResults sends results to any configured external exporters
+func (ts *TestSession) exportResults(results []common.TestResult) {
+	otlpConfig := ts.Config.Exporters.OTLPLogs
+	if !otlpConfig.Enabled {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	exporter, err := exporters.NewOTLPLogExporter(ctx, otlpConfig.Endpoint, otlpConfig.BatchSize, otlpConfig.Insecure)
+	if err != nil {
+		ts.Logger.Error("Failed to create OTLP log exporter", zap.Error(err))
+		return
+	}
+	defer exporter.Shutdown(ctx)
+
+	if err := exporter.Export(ctx, results); err != nil {
+		ts.Logger.Error("Failed to export results as OTLP logs", zap.Error(err))
+		return
+	}
+
+	ts.Logger.Info("Exported results as OTLP logs", zap.String("endpoint", otlpConfig.Endpoint))
+}
+
+// OpenHistoryStore opens the history store selected by the configuration
+func OpenHistoryStore(config *Config) (history.Store, error) {
+	switch config.HistoryBackend {
+	case "", "file":
+		return history.NewFileStore(filepath.Join(common.MetricsDir, "history")), nil
+	case "sqlite":
+		path := config.HistoryDBPath
+		if path == "" {
+			path = filepath.Join(common.MetricsDir, "history.db")
+		}
+		return history.NewSQLiteStore(path)
+	default:
+		return nil, fmt.Errorf("unknown history backend: %s", config.HistoryBackend)
+	}
+}
+
 // saveHistoricalData saves test results for historical comparison
 func (ts *TestSession) saveHistoricalData(results []common.TestResult) error {
-	historyDir := filepath.Join(common.MetricsDir, "history")
-	if err := os.MkdirAll(historyDir, 0755); err != nil {
-		return fmt.Errorf("failed to create history directory: %w", err)
+	store, err := OpenHistoryStore(ts.Config)
+	if err != nil {
+		return err
 	}
+	defer store.Close()
 
-	// Create JSON report in history directory
-	path := filepath.Join(historyDir, fmt.Sprintf("layer_tests_%s.json", ts.RunID))
-	if err := common.WriteJSONReport(results, path); err != nil {
-		return fmt.Errorf("failed to save historical data: %w", err)
+	if err := store.Save(ts.RunID, results); err != nil {
+		return err
 	}
 
-	ts.Logger.Info("Saved historical data", zap.String("path", path))
+	ts.Logger.Info("Saved historical data",
+		zap.String("run_id", ts.RunID),
+		zap.String("backend", ts.Config.HistoryBackend),
+	)
 
-	// Perform history retention cleanup (async)
-	go ts.cleanupHistoricalData(historyDir)
+	// Perform history retention cleanup
+	ts.cleanupHistoricalData(store)
 
 	return nil
 }
 
-// cleanupHistoricalData removes old historical data files
-func (ts *TestSession) cleanupHistoricalData(historyDir string) {
-	// List all history files
-	files, err := os.ReadDir(historyDir)
-	if err != nil {
-		ts.Logger.Error("Failed to read history directory", zap.Error(err))
+// cleanupHistoricalData removes runs beyond the retention limit, oldest first
+func (ts *TestSession) cleanupHistoricalData(store history.Store) {
+	if ts.Config.HistoryRetention <= 0 {
 		return
 	}
 
-	// Sort files by modification time (oldest first)
-	type fileInfo struct {
-		name  string
-		mtime time.Time
+	// Everything after the newest HistoryRetention runs is expired
+	expired, err := store.List(0, ts.Config.HistoryRetention, history.OrderDesc)
+	if err != nil {
+		ts.Logger.Error("Failed to list historical data", zap.Error(err))
+		return
 	}
 
-	var filesInfo []fileInfo
-	for _, file := range files {
-		info, err := file.Info()
-		if err != nil {
-			continue
-		}
-		filesInfo = append(filesInfo, fileInfo{
-			name:  file.Name(),
-			mtime: info.ModTime(),
-		})
-	}
-
-	// Sort by modification time (newest first)
-	sort.Slice(filesInfo, func(i, j int) bool {
-		return filesInfo[i].mtime.After(filesInfo[j].mtime)
-	})
-
-	// Delete old files beyond retention limit
-	if len(filesInfo) > ts.Config.HistoryRetention {
-		for i := ts.Config.HistoryRetention; i < len(filesInfo); i++ {
-			path := filepath.Join(historyDir, filesInfo[i].name)
-			if err := os.Remove(path); err != nil {
-				ts.Logger.Error("Failed to delete old history file",
-					zap.String("file", path),
-					zap.Error(err),
-				)
-			} else {
-				ts.Logger.Debug("Deleted old history file",
-					zap.String("file", path),
-				)
-			}
+	for _, run := range expired {
+		if err := store.Delete(run.RunID); err != nil {
+			ts.Logger.Error("Failed to delete old history run",
+				zap.String("run_id", run.RunID),
+				zap.Error(err),
+			)
+		} else {
+			ts.Logger.Debug("Deleted old history run",
+				zap.String("run_id", run.RunID),
+			)
 		}
 	}
 }
 
-// initializeRunners creates runner instances for the specified layers
-func (ts *TestSession) initializeRunners(layers []int) (map[int]common.LayerRunner, error) {
+// initializeRunners creates runner instances for the specified layers,
+// skipping any layer whose config fails one of filters
+func (ts *TestSession) initializeRunners(layers []int, filters ...func(*LayerConfig) bool) (map[int]common.LayerRunner, error) {
 	runners := make(map[int]common.LayerRunner)
+	ts.dependencies = make(map[int][]int)
+
+	// Plugins take the place of built-in runners for their layers
+	pluginRunners, err := ts.loadPluginRunners(layers)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, l := range layers {
 		layerConfig, err := ts.Config.GetLayerConfig(l)
@@ -510,6 +994,18 @@ func (ts *TestSession) initializeRunners(layers []int) (map[int]common.LayerRunn
 			continue
 		}
 
+		// Skip layers excluded by the session's filters
+		if !matchesAll(&layerConfig, filters) {
+			ts.Logger.Debug("Layer excluded by filter", zap.Int("layer", l), zap.Strings("tags", layerConfig.Tags))
+			continue
+		}
+
+		if runner, ok := pluginRunners[l]; ok {
+			runners[l] = runner
+			ts.dependencies[l] = runner.GetDependencies()
+			continue
+		}
+
 		// Create runner based on layer
 		var runner common.LayerRunner
 		switch l {
@@ -529,7 +1025,59 @@ func (ts *TestSession) initializeRunners(layers []int) (map[int]common.LayerRunn
 				}
 			}
 			
-			runner = layer1.New(attemptCount, minSignalStrength)
+			l1 := layer1.New(attemptCount, minSignalStrength)
+
+			// Error rate monitoring with hysteresis
+			if val, ok := layerConfig.Options["monitor_error_rate"]; ok {
+				if b, ok := val.(bool); ok {
+					l1.MonitorErrorRate = b
+				}
+			}
+			if val, ok := layerConfig.Options["error_rate_warning_pps"]; ok {
+				if pps, ok := val.(float64); ok {
+					l1.ErrorRateWarningPPS = pps
+				}
+			}
+			if val, ok := layerConfig.Options["monitor_samples"]; ok {
+				if samples, ok := val.(float64); ok {
+					l1.MonitorSamples = int(samples)
+				}
+			}
+
+			// Utilization measurement window
+			if val, ok := layerConfig.Options["sample_duration_ms"]; ok {
+				if ms, ok := val.(float64); ok && ms > 0 {
+					l1.SampleDuration = time.Duration(ms) * time.Millisecond
+				}
+			}
+			l1.PacketLossWarningPct = ts.Config.AlertThresholds.PacketLossWarningPct
+			l1.PacketLossErrorPct = ts.Config.AlertThresholds.PacketLossErrorPct
+
+			// Packet capture, saved alongside the reports
+			if val, ok := layerConfig.Options["enable_capture"]; ok {
+				if b, ok := val.(bool); ok {
+					l1.EnableCapture = b
+				}
+			}
+			if ts.Config.OutputPath != "" {
+				l1.CaptureDir = filepath.Join(ts.Config.OutputPath, "captures")
+			}
+
+			// Scan for nearby wireless networks
+			if val, ok := layerConfig.Options["run_wifi_scan"]; ok {
+				if b, ok := val.(bool); ok {
+					l1.RunWiFiScan = b
+				}
+			}
+
+			// Link flap monitoring
+			if val, ok := layerConfig.Options["monitor_duration_s"]; ok {
+				if secs, ok := val.(float64); ok {
+					l1.MonitorDuration = time.Duration(secs * float64(time.Second))
+				}
+			}
+
+			runner = l1
 			
 		case 2:
 			// Layer 2 options
@@ -547,7 +1095,58 @@ func (ts *TestSession) initializeRunners(layers []int) (map[int]common.LayerRunn
 				}
 			}
 			
-			runner = layer2.New(layerConfig.Targets, checkMAC, checkMTU)
+			l2 := layer2.New(layerConfig.Targets, checkMAC, checkMTU)
+
+			// Carrier Ethernet OAM loopback
+			if val, ok := layerConfig.Options["check_ethernet_oam"]; ok {
+				if b, ok := val.(bool); ok {
+					l2.CheckEthernetOAM = b
+				}
+			}
+			if val, ok := layerConfig.Options["oam_interface"]; ok {
+				if iface, ok := val.(string); ok {
+					l2.OAMInterface = iface
+				}
+			}
+			if val, ok := layerConfig.Options["remote_mep"]; ok {
+				if mep, ok := val.(float64); ok {
+					l2.RemoteMEP = int(mep)
+				}
+			}
+			if val, ok := layerConfig.Options["oam_timeout_ms"]; ok {
+				if ms, ok := val.(float64); ok {
+					l2.OAMTimeout = time.Duration(ms) * time.Millisecond
+				}
+			}
+
+			// ARP cache inspection
+			if val, ok := layerConfig.Options["check_arp"]; ok {
+				if b, ok := val.(bool); ok {
+					l2.CheckARP = b
+				}
+			}
+
+			// VLAN detection
+			if val, ok := layerConfig.Options["detect_vlan"]; ok {
+				if b, ok := val.(bool); ok {
+					l2.DetectVLAN = b
+				}
+			}
+
+			// Frame error counters
+			if val, ok := layerConfig.Options["check_frame_errors"]; ok {
+				if b, ok := val.(bool); ok {
+					l2.CheckFrameErrors = b
+				}
+			}
+			if val, ok := layerConfig.Options["frame_error_threshold"]; ok {
+				if f, ok := val.(float64); ok {
+					l2.FrameErrorThreshold = int(f)
+				}
+			}
+			l2.PacketLossErrorPct = ts.Config.AlertThresholds.PacketLossErrorPct
+
+			runner = l2
 			
 		case 3:
 			// Layer 3 options
@@ -572,7 +1171,146 @@ func (ts *TestSession) initializeRunners(layers []int) (map[int]common.LayerRunn
 				}
 			}
 			
-			runner = layer3.New(hostname, pingAddr, pingCount)
+			l3 := layer3.New(hostname, pingAddr, pingCount)
+
+			// Native ICMP is the default; false forces the ping binary
+			if val, ok := layerConfig.Options["use_raw_icmp"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.UseRawICMP = b
+				}
+			}
+
+			// WHOIS lookup and IP ownership verification
+			if val, ok := layerConfig.Options["check_whois"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.CheckWHOIS = b
+				}
+			}
+			if val, ok := layerConfig.Options["expected_org_name"]; ok {
+				if org, ok := val.(string); ok && org != "" {
+					l3.ExpectedOrgName = org
+					l3.VerifyIPOwnership = true
+				}
+			}
+			if val, ok := layerConfig.Options["verify_ip_ownership"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.VerifyIPOwnership = b
+				}
+			}
+
+			// Path MTU discovery
+			if val, ok := layerConfig.Options["check_pmtu"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.CheckPMTU = b
+				}
+			}
+
+			// Traceroute to the ping address
+			if val, ok := layerConfig.Options["traceroute"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.Traceroute = b
+				}
+			}
+			if val, ok := layerConfig.Options["max_hops"]; ok {
+				if hops, ok := val.(float64); ok {
+					l3.MaxHops = int(hops)
+				}
+			}
+
+			// IP geolocation and route distance
+			if val, ok := layerConfig.Options["check_geolocation"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.CheckGeolocation = b
+				}
+			}
+			l3.GeoIPDBPath = ts.Config.GeoIPDBPath
+			if val, ok := layerConfig.Options["geoip_db_path"]; ok {
+				if path, ok := val.(string); ok {
+					l3.GeoIPDBPath = path
+				}
+			}
+
+			// ASN and location lookup of the ping target
+			if val, ok := layerConfig.Options["lookup_asn"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.LookupASN = b
+				}
+			}
+			if val, ok := layerConfig.Options["max_expected_distance_km"]; ok {
+				if km, ok := val.(float64); ok {
+					l3.MaxExpectedDistanceKm = int(km)
+				}
+			}
+
+			// Forward-confirmed reverse DNS of the resolved hostname
+			if val, ok := layerConfig.Options["check_reverse_dns"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.CheckReverseDNS = b
+				}
+			}
+
+			// DNSSEC validation of the hostname
+			if val, ok := layerConfig.Options["check_dnssec"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.CheckDNSSEC = b
+				}
+			}
+			if val, ok := layerConfig.Options["dnssec_resolver"]; ok {
+				if s, ok := val.(string); ok {
+					l3.DNSSECResolver = s
+				}
+			}
+
+			// Default gateway reachability before the external tests
+			if val, ok := layerConfig.Options["test_gateway"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.TestGateway = b
+				}
+			}
+
+			// Multicast group membership verification
+			if val, ok := layerConfig.Options["check_multicast"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.CheckMulticast = b
+				}
+			}
+			if val, ok := layerConfig.Options["multicast_interface"]; ok {
+				if iface, ok := val.(string); ok {
+					l3.MulticastInterface = iface
+				}
+			}
+			if val, ok := layerConfig.Options["required_multicast_groups"]; ok {
+				if groups, ok := val.([]interface{}); ok {
+					for _, g := range groups {
+						if group, ok := g.(string); ok {
+							l3.RequiredMulticastGroups = append(l3.RequiredMulticastGroups, group)
+						}
+					}
+				}
+			}
+
+			// Multicast group join and leave
+			if val, ok := layerConfig.Options["test_multicast"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.TestMulticast = b
+				}
+			}
+			if val, ok := layerConfig.Options["multicast_groups"]; ok {
+				if groups, ok := val.([]interface{}); ok {
+					for _, g := range groups {
+						if group, ok := g.(string); ok {
+							l3.MulticastGroups = append(l3.MulticastGroups, group)
+						}
+					}
+				}
+			}
+			if val, ok := layerConfig.Options["multicast_send"]; ok {
+				if b, ok := val.(bool); ok {
+					l3.MulticastSend = b
+				}
+			}
+
+			runner = l3
 			
 		case 4:
 			// Layer 4 options
@@ -588,7 +1326,85 @@ func (ts *TestSession) initializeRunners(layers []int) (map[int]common.LayerRunn
 				}
 			}
 			
-			runner = layer4.New(tcpAddresses, udpAddress, layerConfig.Timeout)
+			l4 := layer4.New(tcpAddresses, udpAddress, layerConfig.Timeout)
+
+			// DTLS handshake targets
+			if val, ok := layerConfig.Options["dtls_targets"]; ok {
+				if targets, ok := val.([]interface{}); ok {
+					for _, t := range targets {
+						if target, ok := t.(string); ok {
+							l4.DTLSTargets = append(l4.DTLSTargets, target)
+						}
+					}
+				}
+			}
+			if val, ok := layerConfig.Options["require_dtls13"]; ok {
+				if b, ok := val.(bool); ok {
+					l4.RequireDTLS13 = b
+				}
+			}
+
+			// ICMP rate limiting detection
+			if val, ok := layerConfig.Options["check_icmp_rate_limit"]; ok {
+				if b, ok := val.(bool); ok {
+					l4.CheckICMPRateLimit = b
+				}
+			}
+
+			// TCP bandwidth measurement
+			if val, ok := layerConfig.Options["enable_bandwidth"]; ok {
+				if b, ok := val.(bool); ok {
+					l4.EnableBandwidth = b
+				}
+			}
+			if val, ok := layerConfig.Options["bandwidth_target"]; ok {
+				if target, ok := val.(string); ok {
+					l4.BandwidthTarget = target
+				}
+			}
+			if val, ok := layerConfig.Options["bandwidth_duration_s"]; ok {
+				if f, ok := val.(float64); ok {
+					l4.BandwidthDuration = time.Duration(f * float64(time.Second))
+				}
+			}
+			if val, ok := layerConfig.Options["bandwidth_streams"]; ok {
+				if f, ok := val.(float64); ok {
+					l4.BandwidthStreams = int(f)
+				}
+			}
+
+			// TCP connect time distribution, failed against the latency error threshold
+			if val, ok := layerConfig.Options["bandwidth_samples"]; ok {
+				if f, ok := val.(float64); ok {
+					l4.BandwidthSamples = int(f)
+				}
+			}
+			l4.LatencyErrorMs = ts.Config.AlertThresholds.LatencyErrorMs
+
+			// UDP round trip time probes
+			if val, ok := layerConfig.Options["udp_samples"]; ok {
+				if f, ok := val.(float64); ok {
+					l4.UDPSamples = int(f)
+				}
+			}
+
+			// TCP Fast Open detection
+			if val, ok := layerConfig.Options["detect_tfo"]; ok {
+				if b, ok := val.(bool); ok {
+					l4.DetectTFO = b
+				}
+			}
+
+			// TCP retransmission rate, judged against the packet loss thresholds
+			if val, ok := layerConfig.Options["measure_retransmissions"]; ok {
+				if b, ok := val.(bool); ok {
+					l4.MeasureRetransmissions = b
+				}
+			}
+			l4.PacketLossWarningPct = ts.Config.AlertThresholds.PacketLossWarningPct
+			l4.PacketLossErrorPct = ts.Config.AlertThresholds.PacketLossErrorPct
+
+			runner = l4
 			
 		case 5:
 			// Layer 5 options
@@ -597,7 +1413,177 @@ func (ts *TestSession) initializeRunners(layers []int) (map[int]common.LayerRunn
 				sessionTargets = layerConfig.Targets
 			}
 			
-			runner = layer5.New(sessionTargets, layerConfig.Timeout)
+			l5 := layer5.New(sessionTargets, layerConfig.Timeout)
+
+			// RDP availability and banner checks
+			if val, ok := layerConfig.Options["rdp_targets"]; ok {
+				if targets, ok := val.([]interface{}); ok {
+					for _, t := range targets {
+						if target, ok := t.(string); ok {
+							l5.RDPTargets = append(l5.RDPTargets, target)
+						}
+					}
+				}
+			}
+			if val, ok := layerConfig.Options["flag_open_rdp"]; ok {
+				if b, ok := val.(bool); ok {
+					l5.FlagOpenRDP = b
+				}
+			}
+			if val, ok := layerConfig.Options["rdp_servers"]; ok {
+				if servers, ok := val.([]interface{}); ok {
+					for _, s := range servers {
+						if server, ok := s.(string); ok {
+							l5.RDPServers = append(l5.RDPServers, server)
+						}
+					}
+				}
+			}
+
+			// Session ticket lifetime measurement
+			if val, ok := layerConfig.Options["measure_ticket_lifetime"]; ok {
+				if b, ok := val.(bool); ok {
+					l5.MeasureTicketLifetime = b
+				}
+			}
+			if val, ok := layerConfig.Options["ticket_targets"]; ok {
+				if targets, ok := val.([]interface{}); ok {
+					for _, t := range targets {
+						if target, ok := t.(string); ok {
+							l5.TicketTargets = append(l5.TicketTargets, target)
+						}
+					}
+				}
+			}
+			if val, ok := layerConfig.Options["ticket_max_wait_s"]; ok {
+				if secs, ok := val.(float64); ok {
+					l5.MaxWait = time.Duration(secs * float64(time.Second))
+				}
+			}
+			if val, ok := layerConfig.Options["ticket_poll_interval_s"]; ok {
+				if secs, ok := val.(float64); ok {
+					l5.PollInterval = time.Duration(secs * float64(time.Second))
+				}
+			}
+
+			// SSH key exchange targets
+			if val, ok := layerConfig.Options["ssh_targets"]; ok {
+				if targets, ok := val.([]interface{}); ok {
+					for _, t := range targets {
+						if target, ok := t.(string); ok {
+							l5.SSHTargets = append(l5.SSHTargets, target)
+						}
+					}
+				}
+			}
+			if val, ok := layerConfig.Options["ssh_user"]; ok {
+				if user, ok := val.(string); ok {
+					l5.SSHUser = user
+				}
+			}
+			if val, ok := layerConfig.Options["ssh_key_path"]; ok {
+				if path, ok := val.(string); ok {
+					l5.SSHKeyPath = path
+				}
+			}
+			if val, ok := layerConfig.Options["known_hosts_path"]; ok {
+				if path, ok := val.(string); ok {
+					l5.KnownHostsPath = path
+				}
+			}
+
+			// gRPC health check targets
+			if val, ok := layerConfig.Options["grpc_targets"]; ok {
+				if items, ok := val.([]interface{}); ok {
+					for _, item := range items {
+						m, ok := item.(map[string]interface{})
+						if !ok {
+							continue
+						}
+						var target layer5.GRPCTarget
+						target.Address, _ = m["address"].(string)
+						target.ServiceName, _ = m["service_name"].(string)
+						target.TLSEnabled, _ = m["tls_enabled"].(bool)
+						target.InsecureSkipVerify, _ = m["insecure_skip_verify"].(bool)
+						if target.Address != "" {
+							l5.GRPCTargets = append(l5.GRPCTargets, target)
+						}
+					}
+				}
+			}
+
+			// MQTT publish/subscribe targets
+			if val, ok := layerConfig.Options["mqtt_targets"]; ok {
+				if items, ok := val.([]interface{}); ok {
+					for _, item := range items {
+						m, ok := item.(map[string]interface{})
+						if !ok {
+							continue
+						}
+						var target layer5.MQTTTarget
+						target.BrokerURL, _ = m["broker_url"].(string)
+						target.ClientID, _ = m["client_id"].(string)
+						target.Topic, _ = m["topic"].(string)
+						if qos, ok := m["qos"].(float64); ok {
+							target.QoS = byte(qos)
+						}
+						target.TLSEnabled, _ = m["tls_enabled"].(bool)
+						if target.BrokerURL != "" {
+							l5.MQTTTargets = append(l5.MQTTTargets, target)
+						}
+					}
+				}
+			}
+
+			// WebSocket echo targets
+			if val, ok := layerConfig.Options["websocket_targets"]; ok {
+				if items, ok := val.([]interface{}); ok {
+					for _, item := range items {
+						m, ok := item.(map[string]interface{})
+						if !ok {
+							continue
+						}
+						target := layer5.WebSocketTarget{TLSVerify: true}
+						target.URL, _ = m["url"].(string)
+						target.PingPayload, _ = m["ping_payload"].(string)
+						if verify, ok := m["tls_verify"].(bool); ok {
+							target.TLSVerify = verify
+						}
+						if target.URL != "" {
+							l5.WebSocketTargets = append(l5.WebSocketTargets, target)
+						}
+					}
+				}
+			}
+
+			// HTTP/2 multiplexing targets
+			if val, ok := layerConfig.Options["http2_targets"]; ok {
+				if targets, ok := val.([]interface{}); ok {
+					for _, t := range targets {
+						if target, ok := t.(string); ok {
+							l5.HTTP2Targets = append(l5.HTTP2Targets, target)
+						}
+					}
+				}
+			}
+
+			// WireGuard peer status
+			if val, ok := layerConfig.Options["wireguard_interfaces"]; ok {
+				if ifaces, ok := val.([]interface{}); ok {
+					for _, i := range ifaces {
+						if iface, ok := i.(string); ok {
+							l5.WireGuardInterfaces = append(l5.WireGuardInterfaces, iface)
+						}
+					}
+				}
+			}
+			if val, ok := layerConfig.Options["wireguard_handshake_max_age_s"]; ok {
+				if secs, ok := val.(float64); ok {
+					l5.WireGuardHandshakeMaxAge = time.Duration(secs * float64(time.Second))
+				}
+			}
+
+			runner = l5
 			
 		case 6:
 			// Layer 6 options
@@ -613,7 +1599,62 @@ func (ts *TestSession) initializeRunners(layers []int) (map[int]common.LayerRunn
 				}
 			}
 			
-			runner = layer6.New(dataSets)
+			// Compression algorithms to round trip
+			var l6Opts []layer6.Option
+			if val, ok := layerConfig.Options["compression_algorithms"]; ok {
+				if algs, ok := val.([]interface{}); ok {
+					var algorithms []string
+					for _, a := range algs {
+						if algorithm, ok := a.(string); ok {
+							algorithms = append(algorithms, algorithm)
+						}
+					}
+					l6Opts = append(l6Opts, layer6.WithAlgorithms(algorithms...))
+				}
+			}
+
+			// AES-GCM encryption round trip
+			if val, ok := layerConfig.Options["test_encryption"]; ok {
+				if b, ok := val.(bool); ok {
+					l6Opts = append(l6Opts, layer6.WithEncryption(b))
+				}
+			}
+
+			// Protobuf round trip
+			if val, ok := layerConfig.Options["test_protobuf"]; ok {
+				if b, ok := val.(bool); ok {
+					l6Opts = append(l6Opts, layer6.WithProtobuf(b))
+				}
+			}
+
+			// MessagePack round trip
+			if val, ok := layerConfig.Options["test_msgpack"]; ok {
+				if b, ok := val.(bool); ok {
+					l6Opts = append(l6Opts, layer6.WithMessagePack(b))
+				}
+			}
+
+			l6 := layer6.New(dataSets, l6Opts...)
+
+			if val, ok := layerConfig.Options["test_asn1"]; ok {
+				if b, ok := val.(bool); ok {
+					l6.TestASN1 = b
+				}
+			}
+
+			// TLS 1.3 0-RTT early data testing
+			if val, ok := layerConfig.Options["test_0rtt"]; ok {
+				if b, ok := val.(bool); ok {
+					l6.Test0RTT = b
+				}
+			}
+			if val, ok := layerConfig.Options["early_data_endpoint"]; ok {
+				if endpoint, ok := val.(string); ok {
+					l6.EarlyDataEndpoint = endpoint
+				}
+			}
+
+			runner = l6
 			
 		case 7:
 			// Layer 7 options
@@ -626,14 +1667,225 @@ func (ts *TestSession) initializeRunners(layers []int) (map[int]common.LayerRunn
 				endpoints = layerConfig.Targets
 			}
 			
-			runner = layer7.New(endpoints, layerConfig.Timeout)
+			l7 := layer7.New(endpoints, layerConfig.Timeout)
+
+			if val, ok := layerConfig.Options["verify_ssl"]; ok {
+				if b, ok := val.(bool); ok {
+					l7.VerifySSL = b
+				}
+			}
+
+			// Certificate expiry warning threshold
+			if val, ok := layerConfig.Options["cert_expiry_warning_days"]; ok {
+				if days, ok := val.(float64); ok {
+					l7.WithCertExpiryWarning(int(days))
+				}
+			}
+
+			// Content-Security-Policy analysis
+			if val, ok := layerConfig.Options["validate_csp"]; ok {
+				if b, ok := val.(bool); ok && b {
+					l7.WithCSPValidation()
+				}
+			}
+
+			// HTTP method enumeration
+			if val, ok := layerConfig.Options["enumerate_http_methods"]; ok {
+				if b, ok := val.(bool); ok && b {
+					l7.WithMethodEnumeration()
+				}
+			}
+
+			// HTTP security header assessment
+			if val, ok := layerConfig.Options["check_security_headers"]; ok {
+				if b, ok := val.(bool); ok && b {
+					l7.WithSecurityHeaderCheck()
+				}
+			}
+
+			// Certificate Transparency verification
+			if val, ok := layerConfig.Options["verify_ct_logs"]; ok {
+				if b, ok := val.(bool); ok && b {
+					listURL, _ := layerConfig.Options["ct_log_list_url"].(string)
+					l7.WithCTVerification(listURL)
+				}
+			}
+
+			// GraphQL endpoint health checks
+			if val, ok := layerConfig.Options["graphql_targets"]; ok {
+				if items, ok := val.([]interface{}); ok {
+					var targets []layer7.GraphQLTarget
+					for _, item := range items {
+						m, ok := item.(map[string]interface{})
+						if !ok {
+							continue
+						}
+						var target layer7.GraphQLTarget
+						target.URL, _ = m["url"].(string)
+						target.IntrospectionQuery, _ = m["introspection_query"].(string)
+						target.CustomQuery, _ = m["custom_query"].(string)
+						if fields, ok := m["expected_fields"].([]interface{}); ok {
+							for _, f := range fields {
+								if field, ok := f.(string); ok {
+									target.ExpectedFields = append(target.ExpectedFields, field)
+								}
+							}
+						}
+						if target.URL != "" {
+							targets = append(targets, target)
+						}
+					}
+					l7.WithGraphQLTargets(targets)
+				}
+			}
+
+			// DNS-over-HTTPS resolver checks
+			if val, ok := layerConfig.Options["doh_targets"]; ok {
+				if items, ok := val.([]interface{}); ok {
+					var targets []layer7.DoHTarget
+					for _, item := range items {
+						m, ok := item.(map[string]interface{})
+						if !ok {
+							continue
+						}
+						var target layer7.DoHTarget
+						target.ResolverURL, _ = m["resolver_url"].(string)
+						target.QueryName, _ = m["query_name"].(string)
+						target.QueryType, _ = m["query_type"].(string)
+						if target.ResolverURL != "" && target.QueryName != "" {
+							targets = append(targets, target)
+						}
+					}
+					l7.WithDoHTargets(targets)
+				}
+			}
+
+			// GraphQL subscriptions over WebSocket
+			if val, ok := layerConfig.Options["graphql_subscription_endpoints"]; ok {
+				if urls, ok := val.([]interface{}); ok {
+					var wsURLs []string
+					for _, u := range urls {
+						if wsURL, ok := u.(string); ok {
+							wsURLs = append(wsURLs, wsURL)
+						}
+					}
+
+					query := ""
+					if val, ok := layerConfig.Options["graphql_subscription_query"]; ok {
+						if q, ok := val.(string); ok {
+							query = q
+						}
+					}
+
+					expectedMessages := 1 // Default
+					if val, ok := layerConfig.Options["graphql_expected_messages"]; ok {
+						if n, ok := val.(float64); ok {
+							expectedMessages = int(n)
+						}
+					}
+
+					l7.WithGraphQLSubscriptions(wsURLs, query, expectedMessages)
+				}
+			}
+
+			// CORS preflight validation
+			if val, ok := layerConfig.Options["cors_targets"]; ok {
+				if items, ok := val.([]interface{}); ok {
+					var targets []layer7.CORSTarget
+					for _, item := range items {
+						m, ok := item.(map[string]interface{})
+						if !ok {
+							continue
+						}
+						var target layer7.CORSTarget
+						target.URL, _ = m["url"].(string)
+						target.OriginHeader, _ = m["origin_header"].(string)
+						if headers, ok := m["expected_headers"].(map[string]interface{}); ok {
+							target.ExpectedHeaders = make(map[string]string, len(headers))
+							for k, v := range headers {
+								if s, ok := v.(string); ok {
+									target.ExpectedHeaders[k] = s
+								}
+							}
+						}
+						if target.URL != "" {
+							targets = append(targets, target)
+						}
+					}
+					l7.WithCORSTargets(targets)
+				}
+			}
+
+			// SMTP connectivity
+			if val, ok := layerConfig.Options["smtp_targets"]; ok {
+				if items, ok := val.([]interface{}); ok {
+					var targets []layer7.SMTPTarget
+					for _, item := range items {
+						m, ok := item.(map[string]interface{})
+						if !ok {
+							continue
+						}
+						var target layer7.SMTPTarget
+						target.Host, _ = m["host"].(string)
+						if port, ok := m["port"].(float64); ok {
+							target.Port = int(port)
+						}
+						target.STARTTLS, _ = m["starttls"].(bool)
+						target.AuthEnabled, _ = m["auth_enabled"].(bool)
+						target.Username, _ = m["username"].(string)
+						target.Password, _ = m["password"].(string)
+						target.HeloDomain, _ = m["helo_domain"].(string)
+						if target.Host != "" {
+							targets = append(targets, target)
+						}
+					}
+					l7.WithSMTPTargets(targets)
+				}
+			}
+
+			// HTTP load test
+			if val, ok := layerConfig.Options["load_test"]; ok {
+				if m, ok := val.(map[string]interface{}); ok {
+					if enabled, _ := m["enabled"].(bool); enabled {
+						loadTest := layer7.LoadTestConfig{
+							MaxErrorPct: ts.Config.AlertThresholds.PacketLossErrorPct,
+						}
+						if n, ok := m["concurrency"].(float64); ok {
+							loadTest.Concurrency = int(n)
+						}
+						if s, ok := m["duration_s"].(float64); ok {
+							loadTest.Duration = time.Duration(s * float64(time.Second))
+						}
+						if s, ok := m["ramp_up_s"].(float64); ok {
+							loadTest.RampUpPeriod = time.Duration(s * float64(time.Second))
+						}
+						l7.WithLoadTest(loadTest)
+					}
+				}
+			}
+
+			// SLA tracking against saved history
+			if val, ok := layerConfig.Options["sla_target_pct"]; ok {
+				if pct, ok := val.(float64); ok && pct > 0 {
+					windowHours := 720 // Default: 30 days
+					if val, ok := layerConfig.Options["sla_window_hours"]; ok {
+						if hours, ok := val.(float64); ok {
+							windowHours = int(hours)
+						}
+					}
+					l7.WithSLATracking(pct, windowHours, filepath.Join(common.MetricsDir, "history"))
+				}
+			}
+
+			runner = l7
 			
 		default:
 			return nil, fmt.Errorf("unknown layer: %d", l)
 		}
 
-		// Store runner
+		// Store runner and its dependency edges
 		runners[l] = runner
+		ts.dependencies[l] = runner.GetDependencies()
 	}
 
 	return runners, nil
@@ -646,6 +1898,12 @@ func CreateDefaultConfigFile(path string) error {
 
 // initializeLogger creates a configured logger
 func initializeLogger(level string) (*zap.Logger, error) {
+	return buildLogger(level, true)
+}
+
+// buildLogger creates a logger writing to a file in common.LogDir and, when
+// console is set, to stdout
+func buildLogger(level string, console bool) (*zap.Logger, error) {
 	// Create log directory
 	if err := os.MkdirAll(common.LogDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create log directory: %w", err)
@@ -671,7 +1929,9 @@ func initializeLogger(level string) (*zap.Logger, error) {
 	cfg.Level = zap.NewAtomicLevelAt(logLevel)
 	cfg.OutputPaths = []string{
 		filepath.Join(common.LogDir, fmt.Sprintf("layers_%s.log", time.Now().Format("20060102_150405"))),
-		"stdout",
+	}
+	if console {
+		cfg.OutputPaths = append(cfg.OutputPaths, "stdout")
 	}
 	cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 
@@ -696,7 +1956,7 @@ type Options struct {
 }
 
 // RunLayerTests initializes and runs OSI layer tests for selected layers
-func RunLayerTests(selectedLayers []int) ([]common.TestResult, error) {
+func RunLayerTests(selectedLayers []int, opts ...SessionOption) ([]common.TestResult, error) {
 	// Create a default config
 	config := &Config{
 		OutputFormat:  "pdf",
@@ -709,10 +1969,12 @@ func RunLayerTests(selectedLayers []int) ([]common.TestResult, error) {
 			Options: map[string]any{
 				"attempt_count": 3,
 			},
+			Tags: []string{"physical", "hardware"},
 		},
 		Layer2: LayerConfig{
 			Enabled: true,
 			Timeout: 5 * time.Second,
+			Tags:    []string{"datalink", "ethernet"},
 		},
 		Layer3: LayerConfig{
 			Enabled: true,
@@ -722,6 +1984,7 @@ func RunLayerTests(selectedLayers []int) ([]common.TestResult, error) {
 				"ping_addr": "8.8.8.8",
 				"ping_count": 3,
 			},
+			Tags: []string{"network", "ip", "ping"},
 		},
 		Layer4: LayerConfig{
 			Enabled: true,
@@ -730,15 +1993,18 @@ func RunLayerTests(selectedLayers []int) ([]common.TestResult, error) {
 			Options: map[string]any{
 				"udp_addr": "8.8.8.8:53",
 			},
+			Tags: []string{"transport", "tcp", "udp"},
 		},
 		Layer5: LayerConfig{
 			Enabled: true,
 			Timeout: 15 * time.Second,
 			Targets: []string{"8.8.8.8:53", "1.1.1.1:53"},
+			Tags:    []string{"session", "connection"},
 		},
 		Layer6: LayerConfig{
 			Enabled: true,
 			Timeout: 10 * time.Second,
+			Tags:    []string{"presentation", "encoding", "encryption"},
 		},
 		Layer7: LayerConfig{
 			Enabled: true,
@@ -747,11 +2013,12 @@ func RunLayerTests(selectedLayers []int) ([]common.TestResult, error) {
 				"https://www.google.com",
 				"https://www.cloudflare.com",
 			},
+			Tags: []string{"application", "http", "api"},
 		},
 	}
 
 	// Create test session
-	session, err := NewTestSession(config)
+	session, err := NewTestSession(config, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -810,7 +2077,10 @@ func ExecuteLayers(runners []common.LayerRunner, opts Options) []common.TestResu
 	
 	// Generate report based on format
 	generator := common.NewReportGenerator(results, "layer_tests")
-	_, err = generator.GenerateReport(common.ReportFormat(opts.OutputFormat))
+	generator.SigningKey, err = ResolveReportSigningKey(config)
+	if err == nil {
+		_, err = generator.GenerateReport(common.ReportFormat(opts.OutputFormat))
+	}
 	if err != nil {
 		session.Logger.Error("Failed to generate report", zap.Error(err))
 	}
@@ -0,0 +1,316 @@
+package layers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/common"
+)
+
+// stubRunner is a LayerRunner whose RunTests is supplied by the test
+type stubRunner struct {
+	name string
+	run  func(ctx context.Context) ([]common.TestResult, error)
+}
+
+func (s *stubRunner) RunTests(ctx context.Context, logger *zap.Logger) ([]common.TestResult, error) {
+	return s.run(ctx)
+}
+func (s *stubRunner) GetName() string        { return s.name }
+func (s *stubRunner) GetDescription() string { return s.name }
+func (s *stubRunner) GetDependencies() []int { return nil }
+func (s *stubRunner) ValidateConfig() error  { return nil }
+
+// passingRunner returns a single passed result for layer
+func passingRunner(layer int) *stubRunner {
+	return &stubRunner{name: "passing", run: func(ctx context.Context) ([]common.TestResult, error) {
+		return []common.TestResult{{Layer: layer, Name: "passing", Status: common.StatusPassed}}, nil
+	}}
+}
+
+// panickingRunner dereferences a nil pointer, like a buggy runner would
+func panickingRunner() *stubRunner {
+	return &stubRunner{name: "panicking", run: func(ctx context.Context) ([]common.TestResult, error) {
+		var results *[]common.TestResult
+		return *results, nil
+	}}
+}
+
+// newTestSession returns a session over layers 1 and 2 that logs nowhere
+func newTestSession(t *testing.T) *TestSession {
+	t.Helper()
+	config := &Config{
+		OutputFormat:     "json",
+		LogLevel:         "error",
+		GlobalTimeout:    5 * time.Second,
+		MaxConcurrent:    2,
+		MaxGoroutineLeak: 10,
+		DependencyMode:   "ignore",
+		Layer1:           LayerConfig{Enabled: true, Timeout: 5 * time.Second},
+		Layer2:           LayerConfig{Enabled: true, Timeout: 5 * time.Second},
+	}
+	ts, err := NewTestSession(config, WithLogger(zap.NewNop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestPanickingRunnerFailsItsLayer(t *testing.T) {
+	modes := map[string]func(ts *TestSession, runners map[int]common.LayerRunner) ([]common.TestResult, error){
+		"sequential": func(ts *TestSession, runners map[int]common.LayerRunner) ([]common.TestResult, error) {
+			return ts.runSequentialTests(context.Background(), runners, nil)
+		},
+		"concurrent": func(ts *TestSession, runners map[int]common.LayerRunner) ([]common.TestResult, error) {
+			return ts.runConcurrentTests(context.Background(), runners, nil)
+		},
+	}
+
+	for mode, run := range modes {
+		t.Run(mode, func(t *testing.T) {
+			ts := newTestSession(t)
+			runners := map[int]common.LayerRunner{
+				1: panickingRunner(),
+				2: passingRunner(2),
+			}
+
+			results, err := run(ts, runners)
+			if mode == "concurrent" && common.ErrorCodeOf(err) != common.ErrTestFailed {
+				t.Errorf("error = %v, want a test_failed LayerError", err)
+			}
+
+			byLayer := make(map[int]common.TestResult)
+			for _, result := range results {
+				byLayer[result.Layer] = result
+			}
+
+			panicked, ok := byLayer[1]
+			if !ok {
+				t.Fatalf("no result for the panicking layer in %+v", results)
+			}
+			if panicked.Status != common.StatusFailed || !strings.HasPrefix(panicked.Message, "panic: ") {
+				t.Errorf("panicking layer reported %s %q, want Failed \"panic: ...\"", panicked.Status, panicked.Message)
+			}
+			if byLayer[2].Status != common.StatusPassed {
+				t.Errorf("layer 2 reported %s, want it to run and pass despite layer 1", byLayer[2].Status)
+			}
+			if len(ts.Results[1]) != 1 {
+				t.Errorf("session stored %d results for layer 1, want the panic result", len(ts.Results[1]))
+			}
+		})
+	}
+}
+
+func TestRecoverTestGoroutine(t *testing.T) {
+	results := make(chan common.TestResult, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer common.RecoverTestGoroutine(3, zap.NewNop(), results)()
+		panic(errors.New("index out of range"))
+	}()
+	<-done
+
+	select {
+	case result := <-results:
+		if result.Layer != 3 || result.Status != common.StatusFailed || result.Message != "panic: index out of range" {
+			t.Errorf("unexpected panic result %+v", result)
+		}
+	default:
+		t.Fatal("panic produced no result")
+	}
+
+	// Helper goroutines without a results channel only log
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		defer common.RecoverTestGoroutine(3, zap.NewNop(), nil)()
+		panic("helper failed")
+	}()
+	<-done
+}
+
+// leakingRunner leaves n goroutines, tracked by running, blocked until
+// release is closed
+func leakingRunner(layer, n int, release chan struct{}, running *sync.WaitGroup) *stubRunner {
+	return &stubRunner{name: "leaking", run: func(ctx context.Context) ([]common.TestResult, error) {
+		running.Add(n)
+		for i := 0; i < n; i++ {
+			go func() {
+				defer running.Done()
+				<-release
+			}()
+		}
+		return []common.TestResult{{Layer: layer, Name: "leaking", Status: common.StatusPassed}}, nil
+	}}
+}
+
+func TestGoroutineLeakWarning(t *testing.T) {
+	release := make(chan struct{})
+	var running sync.WaitGroup
+	defer func() {
+		close(release)
+		running.Wait()
+	}()
+
+	// Sequential runs attribute the leftover goroutines to the layer
+	ts := newTestSession(t)
+	results, err := ts.runSequentialTests(context.Background(), map[int]common.LayerRunner{
+		1: leakingRunner(1, 20, release, &running),
+		2: passingRunner(2),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaky := results[0]
+	if leaky.Layer != 1 || leaky.Status != common.StatusWarning || len(leaky.SubResults) != 1 ||
+		leaky.SubResults[0].Name != "Resource Leak Warning" {
+		t.Errorf("leaking layer reported %+v, want a Resource Leak Warning sub-test", leaky)
+	}
+	if results[1].Status != common.StatusPassed {
+		t.Errorf("layer 2 reported %s, want Passed", results[1].Status)
+	}
+	if summary := ts.GetResourceSummary(); len(summary.LeakWarningLayers) != 1 || summary.LeakWarningLayers[0] != 1 {
+		t.Errorf("leak warning layers = %v, want [1]", summary.LeakWarningLayers)
+	}
+
+	// Concurrent runs attribute the goroutines each layer started
+	ts = newTestSession(t)
+	results, err = ts.runConcurrentTests(context.Background(), map[int]common.LayerRunner{
+		1: leakingRunner(1, 20, release, &running),
+		2: passingRunner(2),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byLayer := make(map[int]common.TestResult)
+	for _, result := range results {
+		byLayer[result.Layer] = result
+	}
+	leaky = byLayer[1]
+	if leaky.Status != common.StatusWarning || len(leaky.SubResults) != 1 ||
+		leaky.SubResults[0].Name != "Resource Leak Warning" ||
+		leaky.ResourceUsage == nil || leaky.ResourceUsage.GoroutinesDelta != 20 {
+		t.Errorf("concurrent leaking layer reported %+v, want a Resource Leak Warning sub-test for 20 goroutines", leaky)
+	}
+	if clean := byLayer[2]; clean.Status != common.StatusPassed ||
+		clean.ResourceUsage == nil || clean.ResourceUsage.GoroutinesDelta != 0 {
+		t.Errorf("concurrent layer 2 reported %s with usage %v, want Passed with no goroutines left",
+			clean.Status, clean.ResourceUsage)
+	}
+	summary := ts.GetResourceSummary()
+	if len(summary.LeakWarningLayers) != 1 || summary.LeakWarningLayers[0] != 1 {
+		t.Errorf("leak warning layers = %v, want [1]", summary.LeakWarningLayers)
+	}
+	if summary.LayerUsage[1].GoroutinesDelta != 20 || summary.RunUsage.GoroutinesDelta < 20 {
+		t.Errorf("layer usage %v and run usage %+v, want 20 goroutines left by layer 1",
+			summary.LayerUsage, summary.RunUsage)
+	}
+}
+
+func TestCountLabelledGoroutines(t *testing.T) {
+	profile := `goroutine profile: total 9
+4 @ 0x43e8ce 0x44f2e5 0x4b1c69 0x471b81
+# labels: {"osi_layer":"1"}
+#	0x4b1c68	main.worker+0x48	/src/main.go:12
+
+3 @ 0x43e8ce 0x40a5d2 0x471b81
+# labels: {"osi_layer":"2", "span":"x"}
+#	0x40a5d1	main.other+0x31	/src/main.go:30
+
+2 @ 0x43e8ce 0x40a5d2 0x471b81
+#	0x40a5d1	main.main+0x31	/src/main.go:8
+`
+	for layer, want := range map[string]int{"1": 4, "2": 3, "3": 0} {
+		if got := countLabelledGoroutines(profile, layer); got != want {
+			t.Errorf("countLabelledGoroutines(layer %s) = %d, want %d", layer, got, want)
+		}
+	}
+}
+
+func TestConcurrentRunStreamsResults(t *testing.T) {
+	ts := newTestSession(t)
+	var mu sync.Mutex
+	streamed := make(map[int]int)
+	results, err := ts.runConcurrentTests(context.Background(), map[int]common.LayerRunner{
+		1: passingRunner(1),
+		2: passingRunner(2),
+	}, func(result common.TestResult) {
+		mu.Lock()
+		streamed[result.Layer]++
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || streamed[1] != 1 || streamed[2] != 1 {
+		t.Errorf("streamed %v for %d results, want each layer's result once", streamed, len(results))
+	}
+}
+
+func TestStoreResultConcurrent(t *testing.T) {
+	ts := newTestSession(t)
+	ts.Results = make(map[int][]common.TestResult)
+
+	var mu sync.Mutex
+	callbacks := make(map[int]int)
+	ts.ResultCallback = func(layer int, results []common.TestResult) {
+		mu.Lock()
+		callbacks[layer]++
+		mu.Unlock()
+	}
+
+	// Run with -race to catch unsynchronised writes to ts.Results
+	const layers = 100
+	var wg sync.WaitGroup
+	for layer := 1; layer <= layers; layer++ {
+		wg.Add(1)
+		go func(layer int) {
+			defer wg.Done()
+			ts.storeResult(layer, []common.TestResult{{Layer: layer, Name: "stored", Status: common.StatusPassed}})
+		}(layer)
+	}
+	wg.Wait()
+
+	if len(ts.Results) != layers {
+		t.Fatalf("stored %d layers, want %d", len(ts.Results), layers)
+	}
+	for layer := 1; layer <= layers; layer++ {
+		if results := ts.Results[layer]; len(results) != 1 || results[0].Layer != layer {
+			t.Errorf("layer %d stored %+v", layer, results)
+		}
+		if callbacks[layer] != 1 {
+			t.Errorf("layer %d called back %d times, want once", layer, callbacks[layer])
+		}
+	}
+}
+
+func TestDeduplicateResults(t *testing.T) {
+	ts := newTestSession(t)
+	start := time.Now()
+	results := []common.TestResult{
+		{Layer: 3, Name: "Ping Test", Status: common.StatusFailed, StartTime: start},
+		{Layer: 4, Name: "Ping Test", Status: common.StatusPassed, StartTime: start},
+		{Layer: 3, Name: "Ping Test", Status: common.StatusPassed, StartTime: start.Add(time.Second)},
+		{Layer: 3, Name: "DNS Test", Status: common.StatusPassed, StartTime: start},
+		{Layer: 3, Name: "Ping Test", Status: common.StatusWarning, StartTime: start.Add(-time.Second)},
+	}
+
+	deduped := ts.deduplicateResults(results)
+	if len(deduped) != 3 {
+		t.Fatalf("kept %d results, want 3: %+v", len(deduped), deduped)
+	}
+	// The most recent duplicate keeps the position of the first
+	if deduped[0].Layer != 3 || deduped[0].Status != common.StatusPassed || !deduped[0].StartTime.Equal(start.Add(time.Second)) {
+		t.Errorf("kept %+v for layer 3 Ping Test, want the most recent run", deduped[0])
+	}
+	if deduped[1].Layer != 4 || deduped[2].Name != "DNS Test" {
+		t.Errorf("results reordered: %+v", deduped)
+	}
+}
@@ -0,0 +1,120 @@
+package layers
+
+import (
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/common"
+	"ghostshell/app/layers/notify"
+)
+
+// Run outcomes that can trigger a notification
+const (
+	NotifyOnFailure = "failure"
+	NotifyOnWarning = "warning"
+	NotifyAlways    = "always"
+)
+
+// notifyCompletion sends the run summary to Slack when
+// the run's status is one of Notifications.OnStatus
+func (ts *TestSession) notifyCompletion(results []common.TestResult, runErr error) {
+	cfg := ts.Config.Notifications
+	if cfg.SlackWebhook == "" {
+		return
+	}
+
+	event := ts.notifyEvent(results, runErr)
+	if !shouldNotify(cfg.OnStatus, event.Status) {
+		return
+	}
+
+	if err := notify.NewSlackNotifier(cfg.SlackWebhook).Send(event); err != nil {
+		ts.Logger.Error("Failed to send Slack notification", zap.Error(err))
+		return
+	}
+	ts.Logger.Info("Sent Slack notification", zap.String("run_id", event.RunID), zap.String("status", event.Status.String()))
+}
+
+// notifyEvent summarises the run, treating a run error as a failure
+func (ts *TestSession) notifyEvent(results []common.TestResult, runErr error) notify.NotifyEvent {
+	event := notify.NotifyEvent{
+		RunID:      ts.RunID,
+		Status:     common.StatusPassed,
+		DurationMs: ts.EndTime.Sub(ts.StartTime).Milliseconds(),
+		SummaryURL: ts.Config.Notifications.SummaryURL,
+	}
+
+	byLayer := make(map[int]int) // Layer -> index in event.Layers
+	for _, result := range results {
+		i, ok := byLayer[result.Layer]
+		if !ok {
+			i = len(event.Layers)
+			byLayer[result.Layer] = i
+			event.Layers = append(event.Layers, notify.LayerStatus{
+				Layer:  result.Layer,
+				Name:   result.Name,
+				Status: result.Status,
+			})
+		} else {
+			event.Layers[i].Status = worseStatus(event.Layers[i].Status, result.Status)
+		}
+
+		event.Status = worseStatus(event.Status, result.Status)
+		event.FailureCount += countFailures(result)
+	}
+	event.LayerCount = len(event.Layers)
+
+	if runErr != nil {
+		event.Status = common.StatusFailed
+	}
+	return event
+}
+
+// shouldNotify reports whether a run with status matches any of onStatus
+func shouldNotify(onStatus []string, status common.TestStatus) bool {
+	for _, on := range onStatus {
+		switch {
+		case on == NotifyAlways,
+			on == NotifyOnFailure && status == common.StatusFailed,
+			on == NotifyOnWarning && status == common.StatusWarning:
+			return true
+		}
+	}
+	return false
+}
+
+// worseStatus returns the more severe of a and b, failures first
+func worseStatus(a, b common.TestStatus) common.TestStatus {
+	severity := func(s common.TestStatus) int {
+		switch s {
+		case common.StatusFailed:
+			return 2
+		case common.StatusWarning:
+			return 1
+		}
+		return 0
+	}
+	if severity(b) > severity(a) {
+		return b
+	}
+	return a
+}
+
+// countFailures counts failed tests in result, counting the sub-tests of a
+// result that has them instead of the result itself unless none of them failed
+func countFailures(result common.TestResult) int {
+	if len(result.SubResults) == 0 {
+		if result.Status == common.StatusFailed {
+			return 1
+		}
+		return 0
+	}
+
+	count := 0
+	for _, sub := range result.SubResults {
+		count += countFailures(sub)
+	}
+	if count == 0 && result.Status == common.StatusFailed {
+		return 1
+	}
+	return count
+}
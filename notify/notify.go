@@ -0,0 +1,27 @@
+// Package notify sends test run notifications to chat and alerting services
+package notify
+
+import "ghostshell/app/layers/common"
+
+// NotifyEvent summarises a completed test run
+type NotifyEvent struct {
+	RunID        string
+	Status       common.TestStatus // Worst outcome of the run
+	LayerCount   int
+	FailureCount int // Failed tests, counting sub-tests rather than their parents
+	DurationMs   int64
+	SummaryURL   string        // Visualizer page for the run; omitted when empty
+	Layers       []LayerStatus // Outcome of each layer in layer order
+}
+
+// LayerStatus is the outcome of one layer in a run
+type LayerStatus struct {
+	Layer  int
+	Name   string
+	Status common.TestStatus
+}
+
+// Notifier delivers run notifications to an external service
+type Notifier interface {
+	Send(event NotifyEvent) error
+}
@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// slackTimeout bounds a single webhook post
+const slackTimeout = 10 * time.Second
+
+// Sidebar colours of the Slack attachment for each run status
+var slackColors = map[common.TestStatus]string{
+	common.StatusPassed:  "#2EB886",
+	common.StatusFailed:  "#D00000",
+	common.StatusWarning: "#FF9900",
+}
+
+// slackEmoji marks each layer's status in the message
+var slackEmoji = map[common.TestStatus]string{
+	common.StatusPassed:  ":white_check_mark:",
+	common.StatusFailed:  ":x:",
+	common.StatusWarning: ":warning:",
+	common.StatusSkipped: ":fast_forward:",
+}
+
+// SlackNotifier posts run notifications to a Slack incoming webhook
+type SlackNotifier struct {
+	WebhookURL string
+
+	client *http.Client
+}
+
+// NewSlackNotifier creates a notifier that posts to webhookURL
+func NewSlackNotifier(webhookURL string) *SlackNotifier {
+	return &SlackNotifier{
+		WebhookURL: webhookURL,
+		client:     &http.Client{Timeout: slackTimeout},
+	}
+}
+
+// slackText is a Block Kit text object
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// slackBlock is a Block Kit layout block
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+// slackAttachment carries the blocks so the message gets a coloured sidebar
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+// slackMessage is the webhook payload
+type slackMessage struct {
+	Text        string            `json:"text"` // Fallback for notifications
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+// Send posts event to the webhook as a Block Kit message
+func (s *SlackNotifier) Send(event NotifyEvent) error {
+	if s.WebhookURL == "" {
+		return fmt.Errorf("slack webhook URL must be specified")
+	}
+
+	body, err := json.Marshal(buildSlackMessage(event))
+	if err != nil {
+		return fmt.Errorf("failed to marshal Slack message: %w", err)
+	}
+
+	resp, err := s.client.Post(s.WebhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post Slack message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("slack webhook returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// buildSlackMessage lays out event as a summary, its counts, one line per
+// layer and a link to the visualizer
+func buildSlackMessage(event NotifyEvent) slackMessage {
+	summary := fmt.Sprintf("OSI layer test run %s: %s", event.RunID, event.Status)
+
+	blocks := []slackBlock{
+		{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: fmt.Sprintf("%s *%s*", statusEmoji(event.Status), summary)},
+		},
+		{
+			Type: "section",
+			Fields: []slackText{
+				{Type: "mrkdwn", Text: fmt.Sprintf("*Layers*\n%d", event.LayerCount)},
+				{Type: "mrkdwn", Text: fmt.Sprintf("*Failures*\n%d", event.FailureCount)},
+				{Type: "mrkdwn", Text: fmt.Sprintf("*Duration*\n%s", time.Duration(event.DurationMs)*time.Millisecond)},
+			},
+		},
+	}
+
+	if len(event.Layers) > 0 {
+		lines := make([]string, len(event.Layers))
+		for i, layer := range event.Layers {
+			lines[i] = fmt.Sprintf("%s Layer %d %s: %s", statusEmoji(layer.Status), layer.Layer, layer.Name, layer.Status)
+		}
+		blocks = append(blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{Type: "mrkdwn", Text: strings.Join(lines, "\n")},
+		})
+	}
+
+	if event.SummaryURL != "" {
+		blocks = append(blocks, slackBlock{
+			Type:     "context",
+			Elements: []slackText{{Type: "mrkdwn", Text: fmt.Sprintf("<%s|View results>", event.SummaryURL)}},
+		})
+	}
+
+	color, ok := slackColors[event.Status]
+	if !ok {
+		color = slackColors[common.StatusWarning]
+	}
+
+	return slackMessage{
+		Text:        summary,
+		Attachments: []slackAttachment{{Color: color, Blocks: blocks}},
+	}
+}
+
+// statusEmoji returns the emoji shown for status
+func statusEmoji(status common.TestStatus) string {
+	if emoji, ok := slackEmoji[status]; ok {
+		return emoji
+	}
+	return ":grey_question:"
+}
@@ -0,0 +1,472 @@
+package layers
+
+import (
+	"embed"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+
+	"ghostshell/app/layers/analysis"
+	"ghostshell/app/layers/common"
+	"ghostshell/app/layers/layer7"
+)
+
+// APIVersion is the version of the REST API reported in its OpenAPI document
+const APIVersion = "1.0.0"
+
+// Paths of the self-documentation endpoints, which are served without
+// authentication
+const (
+	openAPIPath     = "/api/v1/openapi.json"
+	swaggerUIPrefix = "/api/v1/docs/"
+)
+
+//go:embed swaggerui/*
+var swaggerUIFS embed.FS
+
+// queryParam is a query string parameter of an endpoint
+type queryParam struct {
+	Name        string
+	Type        string // OpenAPI primitive type
+	Description string
+}
+
+// endpointDoc describes the body types of an endpoint. Request and Response
+// are zero values whose types are reflected into schemas; a nil Response is
+// documented as a free-form object.
+type endpointDoc struct {
+	Summary     string
+	Request     interface{}
+	Response    interface{}
+	Status      int    // Success status; defaults to 200
+	ContentType string // Response media type; defaults to application/json
+	Query       []queryParam
+}
+
+// endpointDocs documents the registered routes by method and path template.
+// Routes missing here are still listed, with untyped bodies.
+var endpointDocs = map[string]endpointDoc{
+	"GET /healthz":                      {Summary: "Report the health of the server and its components", Response: HealthResponse{}},
+	"GET /api/v1/tests":                 {Summary: "List running tests", Response: []TestInfo{}},
+	"POST /api/v1/tests":                {Summary: "Start a test session", Request: TestRequest{}, Response: map[string]string{}, Status: http.StatusCreated},
+	"GET /api/v1/tests/{id}":            {Summary: "Get a test session"},
+	"POST /api/v1/tests/{id}/cancel":    {Summary: "Cancel a running test session", Response: map[string]string{}},
+	"GET /api/v1/tests/{id}/results":    {Summary: "Get the results of a test session with a summary", Response: TestResultsResponse{}},
+	"GET /api/v1/schedule":              {Summary: "List scheduled jobs", Response: []ScheduledJob{}},
+	"POST /api/v1/schedule":             {Summary: "Schedule a recurring test run", Request: ScheduleRequest{}, Status: http.StatusCreated},
+	"GET /api/v1/events":                {Summary: "Stream test events", ContentType: "text/event-stream"},
+	"GET /api/v1/config":                {Summary: "Get the configuration", Response: Config{}},
+	"PUT /api/v1/config":                {Summary: "Replace the configuration", Request: Config{}, Response: map[string]string{}},
+	"POST /api/v1/config/reset":         {Summary: "Reset the configuration to defaults", Response: map[string]string{}},
+	"GET /api/v1/layers":                {Summary: "List layers", Response: []LayerInfo{}},
+	"GET /api/v1/layers/{layer}":        {Summary: "Get a layer"},
+	"GET /api/v1/layers/{layer}/config": {Summary: "Get the configuration of a layer", Response: LayerConfig{}},
+	"PUT /api/v1/layers/{layer}/config": {Summary: "Replace the configuration of a layer", Request: LayerConfig{}, Response: map[string]string{}},
+	"GET /api/v1/history": {
+		Summary:  "List stored runs",
+		Response: HistoryPage{},
+		Query: []queryParam{
+			{Name: "limit", Type: "integer", Description: "Page size; defaults to 10"},
+			{Name: "order", Type: "string", Description: "asc or desc; defaults to desc"},
+			{Name: "after", Type: "string", Description: "Run ID to continue after"},
+		},
+	},
+	"GET /api/v1/history/search": {
+		Summary:  "Search stored runs by their results",
+		Response: HistorySearchPage{},
+		Query: []queryParam{
+			{Name: "layer", Type: "integer", Description: "Only results of this layer"},
+			{Name: "status", Type: "string", Description: "Only results with this status, e.g. Failed"},
+			{Name: "from", Type: "string", Description: "Earliest run time, RFC3339"},
+			{Name: "to", Type: "string", Description: "Latest run time, RFC3339"},
+			{Name: "name_pattern", Type: "string", Description: "Case-insensitive substring of the result name"},
+			{Name: "limit", Type: "integer", Description: "Page size; defaults to 10"},
+			{Name: "offset", Type: "integer", Description: "Number of matching runs to skip"},
+			{Name: "order", Type: "string", Description: "asc or desc; defaults to desc"},
+		},
+	},
+	"GET /api/v1/history/{id}":     {Summary: "Get the results of a stored run", Response: []common.TestResult{}},
+	"DELETE /api/v1/history/{id}":  {Summary: "Delete a stored run", Response: map[string]string{}},
+	"POST /api/v1/history/compare": {Summary: "Compare two stored runs", Request: CompareRequest{}, Response: common.DiffReport{}},
+	"GET /api/v1/analysis/regressions": {
+		Summary:  "Detect regressions across stored runs",
+		Response: []analysis.Regression{},
+		Query:    []queryParam{{Name: "window", Type: "integer", Description: "Number of recent runs to analyse; defaults to 10"}},
+	},
+	"GET /api/v1/reports":           {Summary: "List generated reports", Response: []ReportItem{}},
+	"POST /api/v1/reports/generate": {Summary: "Generate a report from test results", Request: ReportRequest{}, Response: map[string]string{}},
+	"GET /api/v1/sla": {
+		Summary:  "Get SLA compliance of a test",
+		Response: layer7.SLAResult{},
+		Query: []queryParam{
+			{Name: "test", Type: "string", Description: "Test name"},
+			{Name: "target", Type: "number", Description: "Target uptime percentage; defaults to 99.9"},
+			{Name: "window", Type: "integer", Description: "Window in hours; defaults to 720"},
+		},
+	},
+	"GET /api/v1/alert-rules":       {Summary: "Get Prometheus alert rules", ContentType: "application/x-yaml"},
+	"GET /api/v1/grafana-dashboard": {Summary: "Get a Grafana dashboard"},
+	"GET " + openAPIPath:            {Summary: "Get this OpenAPI document"},
+}
+
+// registerDocRoutes serves the OpenAPI document and, when enabled, Swagger
+// UI. They are registered on the root router so they don't require a token.
+func (api *API) registerDocRoutes() {
+	api.Router.HandleFunc(openAPIPath, api.handleGetOpenAPI).Methods("GET")
+
+	if api.Config.SwaggerUI {
+		assets, err := fs.Sub(swaggerUIFS, "swaggerui")
+		if err != nil {
+			api.Logger.Error("Failed to load Swagger UI assets")
+			return
+		}
+		api.Router.PathPrefix(swaggerUIPrefix).Handler(http.StripPrefix(swaggerUIPrefix, http.FileServer(http.FS(assets))))
+	}
+}
+
+// handleGetOpenAPI returns the OpenAPI document built at startup
+func (api *API) handleGetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	api.respondWithJSON(w, http.StatusOK, api.openAPI)
+}
+
+// serverURL returns the base URL of a server listening on addr
+func serverURL(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return "http://" + addr
+}
+
+// buildOpenAPISpec builds an OpenAPI 3.0 document from the routes registered
+// on router
+func buildOpenAPISpec(router *mux.Router, serverURL string, authenticated bool) (map[string]interface{}, error) {
+	gen := newSchemaGenerator()
+	paths := make(map[string]map[string]interface{})
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Prefixes and subrouters aren't endpoints
+			return nil
+		}
+
+		path, params := openAPIPathTemplate(tmpl)
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+		for _, method := range methods {
+			doc := endpointDocs[method+" "+path]
+			op := gen.operation(doc, params, handlerOperationID(route.GetHandler()))
+			if authenticated && (path == openAPIPath || path == healthzPath) {
+				op["security"] = []interface{}{}
+			}
+			paths[path][strings.ToLower(method)] = op
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk routes: %w", err)
+	}
+
+	components := map[string]interface{}{"schemas": gen.schemas}
+	spec := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Layers OSI Tester API",
+			"version": APIVersion,
+		},
+		"servers":    []map[string]string{{"url": serverURL}},
+		"paths":      paths,
+		"components": components,
+	}
+	if authenticated {
+		components["securitySchemes"] = map[string]interface{}{
+			"bearerAuth": map[string]string{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+		}
+		spec["security"] = []map[string][]string{{"bearerAuth": {}}}
+	}
+	return spec, nil
+}
+
+// pathVariable matches a mux path variable with an optional pattern
+var pathVariable = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// openAPIPathTemplate strips variable patterns from a mux path template and
+// returns the variable names
+func openAPIPathTemplate(tmpl string) (string, []string) {
+	var params []string
+	path := pathVariable.ReplaceAllStringFunc(tmpl, func(v string) string {
+		name := pathVariable.FindStringSubmatch(v)[1]
+		params = append(params, name)
+		return "{" + name + "}"
+	})
+	return path, params
+}
+
+// handlerOperationID derives an operation ID from the name of an API handler
+// method, so handleGetAllTests becomes getAllTests
+func handlerOperationID(h http.Handler) string {
+	if h == nil {
+		return ""
+	}
+	v := reflect.ValueOf(h)
+	if v.Kind() != reflect.Func {
+		return ""
+	}
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return ""
+	}
+	name := fn.Name()
+	name = name[strings.LastIndex(name, ".")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	name = strings.TrimPrefix(name, "handle")
+	if name == "" {
+		return ""
+	}
+	r := []rune(name)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// operation builds the OpenAPI operation of an endpoint
+func (g *schemaGenerator) operation(doc endpointDoc, pathParams []string, operationID string) map[string]interface{} {
+	op := map[string]interface{}{}
+	if doc.Summary != "" {
+		op["summary"] = doc.Summary
+	}
+	if operationID != "" {
+		op["operationId"] = operationID
+	}
+
+	var params []map[string]interface{}
+	for _, name := range pathParams {
+		params = append(params, map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]string{"type": "string"},
+		})
+	}
+	for _, q := range doc.Query {
+		params = append(params, map[string]interface{}{
+			"name":        q.Name,
+			"in":          "query",
+			"description": q.Description,
+			"schema":      map[string]string{"type": q.Type},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if doc.Request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": g.schemaFor(reflect.TypeOf(doc.Request))},
+			},
+		}
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	contentType := doc.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	var schema map[string]interface{}
+	switch {
+	case doc.Response != nil:
+		schema = g.schemaFor(reflect.TypeOf(doc.Response))
+	case contentType == "application/json":
+		schema = map[string]interface{}{"type": "object"}
+	default:
+		schema = map[string]interface{}{"type": "string"}
+	}
+	op["responses"] = map[string]interface{}{
+		fmt.Sprint(status): map[string]interface{}{
+			"description": http.StatusText(status),
+			"content": map[string]interface{}{
+				contentType: map[string]interface{}{"schema": schema},
+			},
+		},
+		"default": map[string]interface{}{
+			"description": "Error",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": errorSchema},
+			},
+		},
+	}
+	return op
+}
+
+// errorSchema is the body of error responses
+var errorSchema = map[string]interface{}{
+	"type":       "object",
+	"properties": map[string]interface{}{"error": map[string]string{"type": "string"}},
+}
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+	testStatusType  = reflect.TypeOf(common.TestStatus(0))
+	rawMessageType  = reflect.TypeOf(json.RawMessage(nil))
+	diagnosticsType = reflect.TypeOf(common.DiagnosticsPayload{})
+	jsonMarshaler   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerTy = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaGenerator reflects Go types into OpenAPI schemas, collecting named
+// structs as components
+type schemaGenerator struct {
+	schemas map[string]interface{}
+	names   map[reflect.Type]string
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{
+		schemas: make(map[string]interface{}),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// schemaFor returns the schema of t, a $ref for named structs
+func (g *schemaGenerator) schemaFor(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "Duration in nanoseconds"}
+	case testStatusType:
+		enum := []string{}
+		for s := common.StatusPassed; s <= common.StatusMixed; s++ {
+			enum = append(enum, s.String())
+		}
+		return map[string]interface{}{"type": "string", "enum": enum}
+	case rawMessageType:
+		return map[string]interface{}{}
+	case diagnosticsType:
+		// Marshals to whichever layer's diagnostics is set
+		var oneOf []interface{}
+		for i := 0; i < t.NumField(); i++ {
+			if field := t.Field(i); field.IsExported() && field.Type.Kind() == reflect.Ptr {
+				oneOf = append(oneOf, g.schemaFor(field.Type))
+			}
+		}
+		return map[string]interface{}{"oneOf": append(oneOf, map[string]interface{}{"type": "object"})}
+	}
+	// Custom marshalling of structs is assumed to keep their fields, but other
+	// types may encode as anything
+	if t.Kind() != reflect.Struct && t.Kind() != reflect.Interface {
+		if t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler) {
+			return map[string]interface{}{}
+		}
+		if t.Implements(textMarshalerTy) || reflect.PointerTo(t).Implements(textMarshalerTy) {
+			return map[string]interface{}{"type": "string"}
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + g.componentName(t)}
+	default:
+		// Interfaces and anything else hold arbitrary JSON
+		return map[string]interface{}{}
+	}
+}
+
+// componentName registers the named struct t as a component and returns its
+// name, qualified by package when another type already has the plain name
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+	g.names[t] = name
+	// Reserve the name before recursing so self references terminate
+	g.schemas[name] = map[string]interface{}{}
+	g.schemas[name] = g.structSchema(t)
+	return name
+}
+
+// structSchema returns the object schema of a struct, following the json
+// struct tags and flattening untagged embedded structs
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	g.addFields(t, properties)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+func (g *schemaGenerator) addFields(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(ft, properties)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schemaFor(field.Type)
+	}
+}
@@ -0,0 +1,39 @@
+package layers
+
+import (
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSwaggerUIAssetsEmbedded(t *testing.T) {
+	assets, err := fs.Sub(swaggerUIFS, "swaggerui")
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := fs.ReadFile(assets, "index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every stylesheet and script comes from the embedded files, not a CDN
+	refs := regexp.MustCompile(`(?:href|src)="([^"]+)"`).FindAllStringSubmatch(string(index), -1)
+	if len(refs) == 0 {
+		t.Fatal("index.html references no assets")
+	}
+	for _, ref := range refs {
+		name := ref[1]
+		if strings.Contains(name, "://") {
+			t.Errorf("index.html loads %s from outside the binary", name)
+			continue
+		}
+		if _, err := fs.Stat(assets, name); err != nil {
+			t.Errorf("index.html references %s, which is not embedded: %v", name, err)
+		}
+	}
+
+	if _, err := fs.Stat(assets, "LICENSE"); err != nil {
+		t.Errorf("Swagger UI license is not embedded: %v", err)
+	}
+}
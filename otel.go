@@ -0,0 +1,123 @@
+// Package layers provides OSI layer testing functionality
+package layers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"ghostshell/app/layers/common"
+)
+
+// tracerName identifies spans created by this package
+const tracerName = "ghostshell/app/layers"
+
+// SessionOption configures optional TestSession behaviour
+type SessionOption func(*TestSession)
+
+// WithTracerProvider makes the session emit spans through tp instead of the
+// global tracer provider
+func WithTracerProvider(tp trace.TracerProvider) SessionOption {
+	return func(ts *TestSession) {
+		if tp != nil {
+			ts.tracer = tp.Tracer(tracerName)
+		}
+	}
+}
+
+// WithParentContext parents the session span on the span carried by ctx, such
+// as one extracted from an incoming request. Only the span context is kept, so
+// the session outlives ctx being cancelled.
+func WithParentContext(ctx context.Context) SessionOption {
+	return func(ts *TestSession) {
+		ts.parentSpan = trace.SpanContextFromContext(ctx)
+	}
+}
+
+// startSessionSpan starts the root span for a test run
+func (ts *TestSession) startSessionSpan(ctx context.Context) (context.Context, trace.Span) {
+	if ts.parentSpan.IsValid() {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, ts.parentSpan)
+	}
+	return ts.tracer.Start(ctx, "layers.session",
+		trace.WithAttributes(attribute.String("session.run_id", ts.RunID)),
+	)
+}
+
+// startLayerSpan starts the span covering one layer runner invocation
+func (ts *TestSession) startLayerSpan(ctx context.Context, layer int) (context.Context, trace.Span) {
+	return ts.tracer.Start(ctx, fmt.Sprintf("layers.layer.%d", layer),
+		trace.WithAttributes(
+			attribute.String("session.run_id", ts.RunID),
+			attribute.Int("layer.number", layer),
+		),
+	)
+}
+
+// endSpan records each result as a span event, sets the span status from the
+// worst result and ends the span
+func endSpan(span trace.Span, results []common.TestResult, err error) {
+	status := common.StatusPassed
+	for _, result := range results {
+		span.AddEvent("test.result",
+			trace.WithTimestamp(result.EndTime),
+			trace.WithAttributes(
+				attribute.Int("test.layer", result.Layer),
+				attribute.String("test.name", result.Name),
+				attribute.String("test.status", result.Status.String()),
+				attribute.Float64("test.duration_ms", float64(result.Metrics.Duration.Microseconds())/1000),
+			),
+		)
+
+		switch result.Status {
+		case common.StatusFailed:
+			status = common.StatusFailed
+		case common.StatusWarning:
+			if status != common.StatusFailed {
+				status = common.StatusWarning
+			}
+		}
+	}
+	span.SetAttributes(attribute.Int("test.count", len(results)))
+
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case status == common.StatusFailed:
+		span.SetStatus(codes.Error, "one or more tests failed")
+	case status == common.StatusWarning:
+		span.AddEvent("layers.warning")
+		span.SetStatus(codes.Ok, "")
+	default:
+		span.SetStatus(codes.Ok, "")
+	}
+
+	span.End()
+}
+
+// tracePropagator extracts W3C trace context and baggage from HTTP headers
+var tracePropagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// WithTraceContext returns middleware that extracts the caller's trace
+// context from the request headers into the request context
+func WithTraceContext() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := tracePropagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// defaultTracer returns the tracer used when no provider is configured
+func defaultTracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
@@ -64,15 +64,15 @@ func writeCSV(results []common.TestResult, outputPath string, logger *zap.Logger
 		row := []string{
 			fmt.Sprintf("%d", result.Layer),
 			result.Name,
-			string(result.Status),
+			result.Status.String(),
 			result.Message,
 			result.StartTime.Format(time.RFC3339),
 			result.EndTime.Format(time.RFC3339),
-			fmt.Sprintf("%.2f", result.Metrics.Duration.Milliseconds()),
+			fmt.Sprintf("%d", result.Metrics.Duration.Milliseconds()),
 			fmt.Sprintf("%.2f", result.Metrics.TransferRate),
-			fmt.Sprintf("%.2f", result.Metrics.Latency.Milliseconds()),
+			fmt.Sprintf("%d", result.Metrics.Latency.Milliseconds()),
 			fmt.Sprintf("%.2f", result.Metrics.PacketLoss),
-			fmt.Sprintf("%.2f", result.Metrics.ResponseTime.Milliseconds()),
+			fmt.Sprintf("%d", result.Metrics.ResponseTime.Milliseconds()),
 		}
 		if err := writer.Write(row); err != nil {
 			return fmt.Errorf("error writing CSV row: %w", err)
@@ -104,7 +104,7 @@ func writePDF(results []common.TestResult, outputPath string, logger *zap.Logger
 	pdf.SetFont("Arial", "", 12)
 	for _, result := range results {
 		pdf.Cell(30, 10, fmt.Sprintf("%d", result.Layer))
-		pdf.Cell(40, 10, string(result.Status))
+		pdf.Cell(40, 10, result.Status.String())
 		pdf.MultiCell(120, 10, result.Message, "", "", false)
 	}
 
@@ -0,0 +1,57 @@
+// Package plugin loads layer runners from Go plugins built against
+// common.PluginContract
+package plugin
+
+import (
+	"fmt"
+	goplugin "plugin"
+
+	"ghostshell/app/layers/common"
+)
+
+// LoadPlugin opens the plugin at path and returns a runner from its
+// LayerRunnerFactory along with the layer slot from its LayerNumber
+func LoadPlugin(path string) (common.LayerRunner, int, error) {
+	p, err := goplugin.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open plugin %s: %w", path, err)
+	}
+
+	factorySym, err := p.Lookup(common.PluginFactorySymbol)
+	if err != nil {
+		return nil, 0, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	var factory func() common.LayerRunner
+	switch f := factorySym.(type) {
+	case func() common.LayerRunner:
+		factory = f
+	case *func() common.LayerRunner:
+		factory = *f
+	default:
+		return nil, 0, fmt.Errorf("plugin %s: %s has type %T, expected func() common.LayerRunner",
+			path, common.PluginFactorySymbol, factorySym)
+	}
+	if factory == nil {
+		return nil, 0, fmt.Errorf("plugin %s: %s is nil", path, common.PluginFactorySymbol)
+	}
+
+	layerSym, err := p.Lookup(common.PluginLayerSymbol)
+	if err != nil {
+		return nil, 0, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	layer, ok := layerSym.(*int)
+	if !ok {
+		return nil, 0, fmt.Errorf("plugin %s: %s has type %T, expected int",
+			path, common.PluginLayerSymbol, layerSym)
+	}
+	if *layer < 1 {
+		return nil, 0, fmt.Errorf("plugin %s: invalid layer number %d", path, *layer)
+	}
+
+	runner := factory()
+	if runner == nil {
+		return nil, 0, fmt.Errorf("plugin %s: %s returned nil", path, common.PluginFactorySymbol)
+	}
+
+	return runner, *layer, nil
+}
@@ -0,0 +1,51 @@
+package layers
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/common"
+	"ghostshell/app/layers/plugin"
+)
+
+// loadPluginRunners loads the configured plugins whose layer is in layers and
+// returns their runners by layer. Plugins only replace built-in layers 1-7
+// when AllowPluginOverride is set.
+func (ts *TestSession) loadPluginRunners(layers []int) (map[int]common.LayerRunner, error) {
+	runners := make(map[int]common.LayerRunner)
+	if len(ts.Config.Plugins) == 0 {
+		return runners, nil
+	}
+
+	wanted := make(map[int]bool, len(layers))
+	for _, l := range layers {
+		wanted[l] = true
+	}
+
+	for _, p := range ts.Config.Plugins {
+		if !wanted[p.LayerNumber] {
+			continue
+		}
+		if p.LayerNumber <= 7 && !ts.Config.AllowPluginOverride {
+			return nil, fmt.Errorf("plugin %s replaces built-in layer %d but allow_plugin_override is not set", p.Path, p.LayerNumber)
+		}
+
+		runner, layer, err := plugin.LoadPlugin(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		if layer != p.LayerNumber {
+			return nil, fmt.Errorf("plugin %s is built for layer %d but configured for layer %d", p.Path, layer, p.LayerNumber)
+		}
+
+		ts.Logger.Info("Loaded layer plugin",
+			zap.String("path", p.Path),
+			zap.Int("layer", layer),
+			zap.String("name", runner.GetName()),
+		)
+		runners[layer] = runner
+	}
+
+	return runners, nil
+}
@@ -0,0 +1,206 @@
+package layers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/common"
+)
+
+// ResourceSummary aggregates the process resources sampled around each
+// layer's run. In concurrent mode a layer's goroutine delta counts only the
+// goroutines it started, while its heap delta is process wide and includes
+// the layers running alongside it.
+type ResourceSummary struct {
+	PeakGoroutines    int                          `json:"peak_goroutines"`
+	PeakHeapInuse     uint64                       `json:"peak_heap_inuse_bytes"`
+	RunUsage          common.ResourceUsage         `json:"run_usage"` // Change across the whole run
+	LayerUsage        map[int]common.ResourceUsage `json:"layer_usage"`
+	LeakWarningLayers []int                        `json:"leak_warning_layers,omitempty"` // Layers whose goroutine delta exceeded Config.MaxGoroutineLeak
+}
+
+// defaultMaxGoroutineLeak is used when Config.MaxGoroutineLeak is unset
+const defaultMaxGoroutineLeak = 10
+
+// resourceSample is the process state at one point in time
+type resourceSample struct {
+	goroutines int
+	heapInuse  uint64
+}
+
+// sampleResources reads the current goroutine count and heap in use
+func sampleResources() resourceSample {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return resourceSample{goroutines: runtime.NumGoroutine(), heapInuse: stats.HeapInuse}
+}
+
+// recordSample updates the session's peak resource usage with s
+func (ts *TestSession) recordSample(s resourceSample) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if s.goroutines > ts.resources.PeakGoroutines {
+		ts.resources.PeakGoroutines = s.goroutines
+	}
+	if s.heapInuse > ts.resources.PeakHeapInuse {
+		ts.resources.PeakHeapInuse = s.heapInuse
+	}
+}
+
+// resourceUsage is the change from before to after
+func resourceUsage(before, after resourceSample) common.ResourceUsage {
+	return common.ResourceUsage{
+		GoroutinesDelta: after.goroutines - before.goroutines,
+		HeapDeltaBytes:  int64(after.heapInuse) - int64(before.heapInuse),
+	}
+}
+
+// goroutineLeakLimit returns Config.MaxGoroutineLeak or its default
+func (ts *TestSession) goroutineLeakLimit() int {
+	if ts.Config.MaxGoroutineLeak > 0 {
+		return ts.Config.MaxGoroutineLeak
+	}
+	return defaultMaxGoroutineLeak
+}
+
+// recordRunUsage records the usage across a whole run and logs a leak. Leaks
+// are reported per layer by recordResourceUsage.
+func (ts *TestSession) recordRunUsage(before, after resourceSample) {
+	ts.recordSample(after)
+	usage := resourceUsage(before, after)
+
+	ts.mu.Lock()
+	ts.resources.RunUsage = usage
+	ts.mu.Unlock()
+
+	if limit := ts.goroutineLeakLimit(); usage.GoroutinesDelta > limit {
+		ts.Logger.Warn("Goroutines still running after the tests",
+			zap.Int("goroutines_delta", usage.GoroutinesDelta),
+			zap.Int("limit", limit),
+			zap.Int64("heap_delta_bytes", usage.HeapDeltaBytes))
+	}
+}
+
+// layerLabel is the profiler label carried by the goroutines a layer starts
+// in concurrent mode
+const layerLabel = "osi_layer"
+
+// runLabelled calls f with the calling goroutine labelled with layer. Every
+// goroutine started during f inherits the label, so those still running
+// afterwards can be counted with labelledGoroutines.
+func runLabelled(ctx context.Context, layer int, f func(context.Context)) {
+	pprof.Do(ctx, pprof.Labels(layerLabel, strconv.Itoa(layer)), f)
+}
+
+// labelledGoroutines returns how many goroutines labelled with layer are
+// running
+func labelledGoroutines(layer int) int {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return 0
+	}
+	return countLabelledGoroutines(buf.String(), strconv.Itoa(layer))
+}
+
+// countLabelledGoroutines counts the goroutines labelled with layer in a
+// goroutine profile written with debug=1, where each "N @ ..." stack line is
+// followed by a "# labels: {...}" line when its goroutines carry labels
+func countLabelledGoroutines(profile, layer string) int {
+	count, stackCount := 0, 0
+	for _, line := range strings.Split(profile, "\n") {
+		if n, _, ok := strings.Cut(line, " @ "); ok {
+			stackCount, _ = strconv.Atoi(n)
+			continue
+		}
+		labels, ok := strings.CutPrefix(line, "# labels: ")
+		if !ok {
+			continue
+		}
+		var values map[string]string
+		if json.Unmarshal([]byte(labels), &values) == nil && values[layerLabel] == layer {
+			count += stackCount
+		}
+	}
+	return count
+}
+
+// labelledUsage is the usage of a layer run by runLabelled, with the
+// goroutines it left running and the process-wide heap change from before
+// to after
+func labelledUsage(layer int, before, after resourceSample) common.ResourceUsage {
+	return common.ResourceUsage{
+		GoroutinesDelta: labelledGoroutines(layer),
+		HeapDeltaBytes:  int64(after.heapInuse) - int64(before.heapInuse),
+	}
+}
+
+// recordResourceUsage attaches usage to the layer's results and adds a
+// warning sub-test when the layer left more than Config.MaxGoroutineLeak
+// goroutines behind. It returns the updated results.
+func (ts *TestSession) recordResourceUsage(layer int, results []common.TestResult, usage common.ResourceUsage) []common.TestResult {
+	for i := range results {
+		results[i].ResourceUsage = &usage
+	}
+
+	limit := ts.goroutineLeakLimit()
+	leaked := usage.GoroutinesDelta > limit
+
+	ts.mu.Lock()
+	if ts.resources.LayerUsage == nil {
+		ts.resources.LayerUsage = make(map[int]common.ResourceUsage)
+	}
+	ts.resources.LayerUsage[layer] = usage
+	if leaked {
+		ts.resources.LeakWarningLayers = append(ts.resources.LeakWarningLayers, layer)
+	}
+	ts.mu.Unlock()
+
+	if !leaked {
+		return results
+	}
+
+	now := time.Now()
+	warning := common.TestResult{
+		Layer:     layer,
+		Name:      "Resource Leak Warning",
+		Status:    common.StatusWarning,
+		Message:   fmt.Sprintf("%d goroutines still running after layer %d tests (limit %d)", usage.GoroutinesDelta, layer, limit),
+		StartTime: now,
+		EndTime:   now,
+		Metrics: common.TestMetrics{Custom: map[string]interface{}{
+			"goroutines_delta": usage.GoroutinesDelta,
+			"heap_delta_bytes": usage.HeapDeltaBytes,
+		}},
+		ResourceUsage: &usage,
+	}
+	if len(results) == 0 {
+		return append(results, warning)
+	}
+	results[0].SubResults = append(results[0].SubResults, warning)
+	results[0].Status = common.MaxSeverity(results[0].Status, common.StatusWarning)
+	return results
+}
+
+// GetResourceSummary returns the peak goroutine count and heap usage seen
+// across all layers, the change across the run and each layer's deltas
+func (ts *TestSession) GetResourceSummary() ResourceSummary {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	summary := ts.resources
+	summary.LayerUsage = make(map[int]common.ResourceUsage, len(ts.resources.LayerUsage))
+	for layer, usage := range ts.resources.LayerUsage {
+		summary.LayerUsage[layer] = usage
+	}
+	summary.LeakWarningLayers = append([]int(nil), ts.resources.LeakWarningLayers...)
+	return summary
+}
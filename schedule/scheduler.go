@@ -0,0 +1,248 @@
+// Package schedule runs layer tests on cron schedules
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers"
+	"ghostshell/app/layers/common"
+)
+
+// ErrJobNotFound is returned when a job ID is not scheduled
+var ErrJobNotFound = errors.New("scheduled job not found")
+
+// JobHook holds callbacks run around each run of a job. Either may be nil.
+type JobHook struct {
+	OnStart    func(jobID string)
+	OnComplete func(jobID string, results []common.TestResult)
+}
+
+// JobResult is the outcome of one run of a scheduled job
+type JobResult struct {
+	JobID   string
+	RunID   string
+	Results []common.TestResult
+	Err     error
+	Time    time.Time // When the run finished
+}
+
+// ResultSink receives the result of every scheduled run
+type ResultSink func(result JobResult)
+
+// ChannelSink returns a ResultSink that sends each result on ch, dropping
+// results while ch is full so a slow reader can't stall the scheduler
+func ChannelSink(ch chan<- JobResult) ResultSink {
+	return func(result JobResult) {
+		select {
+		case ch <- result:
+		default:
+		}
+	}
+}
+
+// job is a scheduled test run
+type job struct {
+	id       string
+	cronExpr string
+	entryID  cron.EntryID
+	config   *layers.Config
+	hooks    []JobHook
+	lastRun  time.Time
+}
+
+// Scheduler runs test sessions on cron schedules. Runs of the same job never
+// overlap; a run that is due while the previous one is still going is
+// skipped.
+type Scheduler struct {
+	cron   *cron.Cron
+	logger *zap.Logger
+	sink   ResultSink
+
+	mu      sync.Mutex
+	jobs    map[string]*job
+	nextID  int
+	started bool
+}
+
+// NewScheduler creates a scheduler that passes run results to sink, which
+// may be nil. Expressions use the standard five cron fields or descriptors
+// such as @hourly and @every 15m.
+func NewScheduler(logger *zap.Logger, sink ResultSink) *Scheduler {
+	cronLogger := &cronLogger{logger: logger}
+	return &Scheduler{
+		cron:   cron.New(cron.WithLogger(cronLogger), cron.WithChain(cron.SkipIfStillRunning(cronLogger))),
+		logger: logger,
+		sink:   sink,
+		jobs:   make(map[string]*job),
+	}
+}
+
+// AddJob schedules config to be tested on cronExpr and returns the job's ID
+func (s *Scheduler) AddJob(cronExpr string, config *layers.Config, hooks ...JobHook) (string, error) {
+	if config == nil {
+		return "", fmt.Errorf("config is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	j := &job{
+		id:       fmt.Sprintf("job-%d", s.nextID),
+		cronExpr: cronExpr,
+		config:   config,
+		hooks:    hooks,
+	}
+
+	entryID, err := s.cron.AddFunc(cronExpr, func() { s.run(j) })
+	if err != nil {
+		return "", fmt.Errorf("invalid cron expression %q: %w", cronExpr, err)
+	}
+	j.entryID = entryID
+	s.jobs[j.id] = j
+
+	s.logger.Info("Scheduled test job",
+		zap.String("job_id", j.id),
+		zap.String("cron", cronExpr),
+		zap.Ints("layers", config.GetEnabledLayers()),
+	)
+	return j.id, nil
+}
+
+// ScheduleJob schedules config without hooks, for the REST API
+func (s *Scheduler) ScheduleJob(cronExpr string, config *layers.Config) (string, error) {
+	return s.AddJob(cronExpr, config)
+}
+
+// RemoveJob unschedules a job. A run in progress is allowed to finish.
+func (s *Scheduler) RemoveJob(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	s.cron.Remove(j.entryID)
+	delete(s.jobs, id)
+
+	s.logger.Info("Removed test job", zap.String("job_id", id))
+	return nil
+}
+
+// Jobs describes the scheduled jobs
+func (s *Scheduler) Jobs() []layers.ScheduledJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	jobs := make([]layers.ScheduledJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		entry := s.cron.Entry(j.entryID)
+		next := entry.Next
+		if next.IsZero() && entry.Schedule != nil {
+			// Entries have no next time until the scheduler starts
+			next = entry.Schedule.Next(now)
+		}
+
+		info := layers.ScheduledJob{
+			ID:       j.id,
+			CronExpr: j.cronExpr,
+			Layers:   j.config.GetEnabledLayers(),
+			NextRun:  next,
+		}
+		if !j.lastRun.IsZero() {
+			lastRun := j.lastRun
+			info.LastRun = &lastRun
+		}
+		jobs = append(jobs, info)
+	}
+	return jobs
+}
+
+// Start starts running jobs on their schedules
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return fmt.Errorf("scheduler already started")
+	}
+	s.started = true
+	s.cron.Start()
+	return nil
+}
+
+// Stop stops scheduling jobs and waits for runs in progress to finish
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = false
+	s.mu.Unlock()
+
+	<-s.cron.Stop().Done()
+}
+
+// run runs one test session for j and hands its results to the hooks and sink
+func (s *Scheduler) run(j *job) {
+	for _, hook := range j.hooks {
+		if hook.OnStart != nil {
+			hook.OnStart(j.id)
+		}
+	}
+
+	result := JobResult{JobID: j.id}
+	session, err := layers.NewTestSession(j.config)
+	if err != nil {
+		result.Err = fmt.Errorf("failed to create test session: %w", err)
+	} else {
+		result.RunID = session.RunID
+		result.Results, result.Err = session.RunAllTests()
+	}
+	result.Time = time.Now()
+
+	s.mu.Lock()
+	j.lastRun = result.Time
+	s.mu.Unlock()
+
+	if result.Err != nil {
+		s.logger.Error("Scheduled test run failed", zap.String("job_id", j.id), zap.Error(result.Err))
+	} else {
+		s.logger.Info("Scheduled test run completed",
+			zap.String("job_id", j.id),
+			zap.String("run_id", result.RunID),
+			zap.Int("results", len(result.Results)),
+		)
+	}
+
+	for _, hook := range j.hooks {
+		if hook.OnComplete != nil {
+			hook.OnComplete(j.id, result.Results)
+		}
+	}
+	if s.sink != nil {
+		s.sink(result)
+	}
+}
+
+// cronLogger adapts a zap logger to cron.Logger
+type cronLogger struct {
+	logger *zap.Logger
+}
+
+func (l *cronLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Sugar().Debugw(msg, keysAndValues...)
+}
+
+func (l *cronLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.logger.Sugar().Errorw(msg, append(keysAndValues, "error", err)...)
+}
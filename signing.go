@@ -0,0 +1,39 @@
+package layers
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReportSigningKeyEnv is the environment variable holding the hex-encoded
+// report signing key. It takes precedence over Config.ReportSigningKey.
+const ReportSigningKeyEnv = "LAYERS_REPORT_SIGNING_KEY"
+
+// reportSigningKeyLen is the length of a report signing key in bytes
+const reportSigningKeyLen = 32
+
+// parseReportSigningKey decodes a hex-encoded 32-byte signing key
+func parseReportSigningKey(encoded string) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("report signing key must be hex-encoded: %w", err)
+	}
+	if len(key) != reportSigningKeyLen {
+		return nil, fmt.Errorf("report signing key must be %d bytes, got %d", reportSigningKeyLen, len(key))
+	}
+	return key, nil
+}
+
+// ResolveReportSigningKey returns the report signing key from the environment
+// or config, or nil when neither sets one and reports are not signed
+func ResolveReportSigningKey(config *Config) ([]byte, error) {
+	if encoded := os.Getenv(ReportSigningKeyEnv); encoded != "" {
+		return parseReportSigningKey(encoded)
+	}
+	if config != nil && config.ReportSigningKey != "" {
+		return parseReportSigningKey(config.ReportSigningKey)
+	}
+	return nil, nil
+}
@@ -0,0 +1,156 @@
+// Package sse fans events out to Server-Sent Events subscribers
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Broker defaults
+const (
+	DefaultHeartbeat  = 15 * time.Second
+	clientBufferSize  = 64
+	contentTypeStream = "text/event-stream"
+)
+
+// Event is a named event with a JSON payload
+type Event struct {
+	Name string
+	Data []byte
+}
+
+// Broker accepts subscriptions and delivers every published event to each
+// subscriber. Subscribers that fall behind miss events rather than blocking
+// publishers.
+type Broker struct {
+	// Heartbeat is the interval between keep-alive comments sent to idle
+	// clients so proxies don't time the connection out
+	Heartbeat time.Duration
+
+	mu      sync.RWMutex
+	clients map[chan Event]struct{}
+	closed  bool
+}
+
+// NewBroker creates a broker with the default heartbeat
+func NewBroker() *Broker {
+	return &Broker{
+		Heartbeat: DefaultHeartbeat,
+		clients:   make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe registers a new subscriber. The returned function unsubscribes it
+// and must be called once the subscriber is done.
+func (b *Broker) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, clientBufferSize)
+
+	b.mu.Lock()
+	if b.closed {
+		close(ch)
+	} else {
+		b.clients[ch] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			if _, ok := b.clients[ch]; ok {
+				delete(b.clients, ch)
+				close(ch)
+			}
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Publish encodes data as JSON and delivers it to every subscriber as event name
+func (b *Broker) Publish(name string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", name, err)
+	}
+	event := Event{Name: name, Data: payload}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.clients {
+		select {
+		case ch <- event:
+		default:
+			// Slow subscriber, drop the event
+		}
+	}
+	return nil
+}
+
+// Clients returns the number of connected subscribers
+func (b *Broker) Clients() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.clients)
+}
+
+// Close disconnects every subscriber and rejects new ones
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.clients {
+		close(ch)
+		delete(b.clients, ch)
+	}
+}
+
+// ServeHTTP streams published events to the client until it disconnects or
+// the broker is closed
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := b.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", contentTypeStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := b.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			// Client went away
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Name, event.Data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
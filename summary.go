@@ -0,0 +1,178 @@
+package layers
+
+import (
+	"sort"
+	"time"
+
+	"ghostshell/app/layers/common"
+)
+
+// LayerSummary aggregates the results of a single layer
+type LayerSummary struct {
+	Layer          int               `json:"layer"`
+	TotalTests     int               `json:"total_tests"`
+	Passed         int               `json:"passed"`
+	Failed         int               `json:"failed"`
+	Warning        int               `json:"warning"`
+	Skipped        int               `json:"skipped"`
+	Mixed          int               `json:"mixed"`
+	Status         common.TestStatus `json:"status"`   // Most severe status of the layer's results
+	Duration       time.Duration     `json:"duration"` // From the first result's start to the last result's end
+	AverageLatency time.Duration     `json:"average_latency"`
+	MaxLatency     time.Duration     `json:"max_latency"`
+}
+
+// SessionSummary aggregates the results of a test session. Tests are
+// counted at the leaves: a result with sub-results counts its sub-results
+// rather than itself.
+type SessionSummary struct {
+	TotalTests       int                  `json:"total_tests"`
+	Passed           int                  `json:"passed"`
+	Failed           int                  `json:"failed"`
+	Warning          int                  `json:"warning"`
+	Skipped          int                  `json:"skipped"`
+	Mixed            int                  `json:"mixed"`
+	OverallStatus    common.TestStatus    `json:"overall_status"`
+	TotalDuration    time.Duration        `json:"total_duration"`
+	AverageLatency   time.Duration        `json:"average_latency"` // Over the tests that measured a latency
+	MaxLatency       time.Duration        `json:"max_latency"`
+	WorstLayer       int                  `json:"worst_layer,omitempty"` // Lowest layer with the most severe status; 0 when there are no results
+	WorstLayerStatus common.TestStatus    `json:"worst_layer_status"`
+	LayerSummaries   map[int]LayerSummary `json:"layer_summaries"`
+}
+
+// summaryCounts accumulates test counts and latencies
+type summaryCounts struct {
+	total, passed, failed, warning, skipped, mixed int
+	latencyTotal, maxLatency                       time.Duration
+	latencies                                      int
+}
+
+// add counts result, or its sub-results when it has any
+func (c *summaryCounts) add(result common.TestResult) {
+	if len(result.SubResults) > 0 {
+		for _, sub := range result.SubResults {
+			c.add(sub)
+		}
+		return
+	}
+
+	c.total++
+	switch result.Status {
+	case common.StatusPassed:
+		c.passed++
+	case common.StatusFailed:
+		c.failed++
+	case common.StatusWarning:
+		c.warning++
+	case common.StatusSkipped:
+		c.skipped++
+	case common.StatusMixed:
+		c.mixed++
+	}
+	if latency := result.Metrics.Latency; latency > 0 {
+		c.latencyTotal += latency
+		c.latencies++
+		if latency > c.maxLatency {
+			c.maxLatency = latency
+		}
+	}
+}
+
+// averageLatency returns the mean of the latencies counted
+func (c *summaryCounts) averageLatency() time.Duration {
+	if c.latencies == 0 {
+		return 0
+	}
+	return c.latencyTotal / time.Duration(c.latencies)
+}
+
+// resultSpan returns the time from the earliest start to the latest end of
+// results, which also covers layers that ran concurrently
+func resultSpan(results []common.TestResult) time.Duration {
+	var start, end time.Time
+	for _, result := range results {
+		if !result.StartTime.IsZero() && (start.IsZero() || result.StartTime.Before(start)) {
+			start = result.StartTime
+		}
+		if result.EndTime.After(end) {
+			end = result.EndTime
+		}
+	}
+	if start.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// Summarize aggregates results by status, latency and layer
+func Summarize(results []common.TestResult) SessionSummary {
+	byLayer := make(map[int][]common.TestResult)
+	for _, result := range results {
+		byLayer[result.Layer] = append(byLayer[result.Layer], result)
+	}
+	layerNums := make([]int, 0, len(byLayer))
+	for layer := range byLayer {
+		layerNums = append(layerNums, layer)
+	}
+	sort.Ints(layerNums)
+
+	summary := SessionSummary{
+		TotalDuration:  resultSpan(results),
+		LayerSummaries: make(map[int]LayerSummary, len(byLayer)),
+	}
+
+	var session summaryCounts
+	for _, layer := range layerNums {
+		var counts summaryCounts
+		statuses := make([]common.TestStatus, 0, len(byLayer[layer]))
+		for _, result := range byLayer[layer] {
+			counts.add(result)
+			session.add(result)
+			statuses = append(statuses, result.Status)
+		}
+
+		status := common.MaxSeverity(statuses...)
+		summary.LayerSummaries[layer] = LayerSummary{
+			Layer:          layer,
+			TotalTests:     counts.total,
+			Passed:         counts.passed,
+			Failed:         counts.failed,
+			Warning:        counts.warning,
+			Skipped:        counts.skipped,
+			Mixed:          counts.mixed,
+			Status:         status,
+			Duration:       resultSpan(byLayer[layer]),
+			AverageLatency: counts.averageLatency(),
+			MaxLatency:     counts.maxLatency,
+		}
+
+		if summary.WorstLayer == 0 || status.IsWorseThan(summary.WorstLayerStatus) {
+			summary.WorstLayer = layer
+			summary.WorstLayerStatus = status
+		}
+	}
+
+	summary.TotalTests = session.total
+	summary.Passed = session.passed
+	summary.Failed = session.failed
+	summary.Warning = session.warning
+	summary.Skipped = session.skipped
+	summary.Mixed = session.mixed
+	summary.OverallStatus = summary.WorstLayerStatus
+	summary.AverageLatency = session.averageLatency()
+	summary.MaxLatency = session.maxLatency
+	return summary
+}
+
+// Aggregate summarises the session's results by status, latency and layer
+func (ts *TestSession) Aggregate() SessionSummary {
+	ts.mu.Lock()
+	var results []common.TestResult
+	for _, layerResults := range ts.Results {
+		results = append(results, layerResults...)
+	}
+	ts.mu.Unlock()
+
+	return Summarize(results)
+}
@@ -0,0 +1,95 @@
+package layers
+
+import "strings"
+
+// Untagged layers policies, deciding whether a layer without tags runs when
+// tests are filtered by tag
+const (
+	UntaggedLayersInclude = "include"
+	UntaggedLayersExclude = "exclude"
+)
+
+// FilterByTags returns a predicate matching layers that carry at least one
+// of tags. Every layer matches an empty tag list; untagged layers never match
+// a non-empty one.
+func FilterByTags(tags []string) func(*LayerConfig) bool {
+	wanted := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		wanted[tag] = true
+	}
+
+	return func(lc *LayerConfig) bool {
+		if len(wanted) == 0 {
+			return true
+		}
+		for _, tag := range lc.Tags {
+			if wanted[tag] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ParseTags splits a comma-separated tag list, dropping empty entries
+func ParseTags(list string) []string {
+	var tags []string
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// WithTags restricts the session to layers tagged with at least one of tags
+func WithTags(tags []string) SessionOption {
+	return func(ts *TestSession) {
+		ts.Tags = tags
+	}
+}
+
+// layerFilters returns the predicates layers must satisfy to run in this
+// session: the tag filter, which lets untagged layers through only under the
+// include policy
+func (ts *TestSession) layerFilters() []func(*LayerConfig) bool {
+	if len(ts.Tags) == 0 {
+		return nil
+	}
+
+	matches := FilterByTags(ts.Tags)
+	return []func(*LayerConfig) bool{
+		func(lc *LayerConfig) bool {
+			if len(lc.Tags) == 0 {
+				return ts.Config.UntaggedLayersPolicy == UntaggedLayersInclude
+			}
+			return matches(lc)
+		},
+	}
+}
+
+// matchingLayers returns the layers whose config passes the session's filters
+func (ts *TestSession) matchingLayers(layers []int) []int {
+	filters := ts.layerFilters()
+	var matched []int
+	for _, layer := range layers {
+		layerConfig, err := ts.Config.GetLayerConfig(layer)
+		if err != nil {
+			continue
+		}
+		if matchesAll(&layerConfig, filters) {
+			matched = append(matched, layer)
+		}
+	}
+	return matched
+}
+
+// matchesAll reports whether lc satisfies every filter
+func matchesAll(lc *LayerConfig, filters []func(*LayerConfig) bool) bool {
+	for _, filter := range filters {
+		if !filter(lc) {
+			return false
+		}
+	}
+	return true
+}
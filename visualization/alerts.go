@@ -0,0 +1,95 @@
+package visualization
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+
+	"ghostshell/app/layers/common"
+)
+
+// alertRuleGroup is the name of the generated Prometheus rule group
+const alertRuleGroup = "osi-layer-tests"
+
+// alertRuleFile is a Prometheus alerting rules file
+type alertRuleFile struct {
+	Groups []alertGroup `yaml:"groups"`
+}
+
+type alertGroup struct {
+	Name  string      `yaml:"name"`
+	Rules []alertRule `yaml:"rules"`
+}
+
+type alertRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// GenerateAlertRules returns a Prometheus alerting rules file with a warning
+// and a critical rule for each threshold, evaluated against the metrics the
+// Visualizer exports. Thresholds that are not set produce no rule.
+func GenerateAlertRules(thresholds common.AlertThresholds) (string, error) {
+	var rules []alertRule
+
+	add := func(name, severity, expr string, threshold float64, summary string) {
+		if threshold <= 0 {
+			return
+		}
+		rules = append(rules, alertRule{
+			Alert: name,
+			Expr:  expr,
+			For:   "5m",
+			Labels: map[string]string{
+				"severity": severity,
+			},
+			Annotations: map[string]string{
+				"summary": summary,
+			},
+		})
+	}
+
+	latency := "histogram_quantile(0.95, sum by (le) (rate(osi_test_duration_seconds_bucket[5m]))) > %g"
+	add("OSITestLatencyWarning", "warning", fmt.Sprintf(latency, float64(thresholds.LatencyWarningMs)/1000),
+		float64(thresholds.LatencyWarningMs),
+		fmt.Sprintf("95th percentile OSI test duration is above %d ms", thresholds.LatencyWarningMs))
+	add("OSITestLatencyCritical", "critical", fmt.Sprintf(latency, float64(thresholds.LatencyErrorMs)/1000),
+		float64(thresholds.LatencyErrorMs),
+		fmt.Sprintf("95th percentile OSI test duration is above %d ms", thresholds.LatencyErrorMs))
+
+	packetLoss := "max by (layer) (osi_layer_packet_loss_pct) > %g"
+	add("OSIPacketLossWarning", "warning", fmt.Sprintf(packetLoss, thresholds.PacketLossWarningPct),
+		thresholds.PacketLossWarningPct,
+		fmt.Sprintf("Packet loss on {{ $labels.layer }} is above %g%%", thresholds.PacketLossWarningPct))
+	add("OSIPacketLossCritical", "critical", fmt.Sprintf(packetLoss, thresholds.PacketLossErrorPct),
+		thresholds.PacketLossErrorPct,
+		fmt.Sprintf("Packet loss on {{ $labels.layer }} is above %g%%", thresholds.PacketLossErrorPct))
+
+	// Lower signal strength is worse
+	signal := "min by (test) (osi_signal_strength_pct) < %d"
+	add("OSISignalStrengthWarning", "warning", fmt.Sprintf(signal, thresholds.SignalStrengthWarning),
+		float64(thresholds.SignalStrengthWarning),
+		fmt.Sprintf("{{ $labels.test }} is below %d%%", thresholds.SignalStrengthWarning))
+	add("OSISignalStrengthCritical", "critical", fmt.Sprintf(signal, thresholds.SignalStrengthError),
+		float64(thresholds.SignalStrengthError),
+		fmt.Sprintf("{{ $labels.test }} is below %d%%", thresholds.SignalStrengthError))
+
+	jitter := "max by (layer) (osi_layer_jitter_seconds) > %g"
+	add("OSIJitterWarning", "warning", fmt.Sprintf(jitter, float64(thresholds.JitterWarningMs)/1000),
+		float64(thresholds.JitterWarningMs),
+		fmt.Sprintf("Jitter on {{ $labels.layer }} is above %d ms", thresholds.JitterWarningMs))
+	add("OSIJitterCritical", "critical", fmt.Sprintf(jitter, float64(thresholds.JitterErrorMs)/1000),
+		float64(thresholds.JitterErrorMs),
+		fmt.Sprintf("Jitter on {{ $labels.layer }} is above %d ms", thresholds.JitterErrorMs))
+
+	data, err := yaml.Marshal(alertRuleFile{
+		Groups: []alertGroup{{Name: alertRuleGroup, Rules: rules}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal alert rules: %w", err)
+	}
+	return string(data), nil
+}
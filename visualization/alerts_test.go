@@ -0,0 +1,116 @@
+package visualization
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"ghostshell/app/layers/common"
+)
+
+// parsedRules decodes a generated rules file the way Prometheus reads it
+func parsedRules(t *testing.T, data string) map[string]map[string]interface{} {
+	t.Helper()
+
+	var file struct {
+		Groups []struct {
+			Name  string                   `yaml:"name"`
+			Rules []map[string]interface{} `yaml:"rules"`
+		} `yaml:"groups"`
+	}
+	if err := yaml.Unmarshal([]byte(data), &file); err != nil {
+		t.Fatalf("generated rules are not valid YAML: %v\n%s", err, data)
+	}
+	if len(file.Groups) != 1 || file.Groups[0].Name != alertRuleGroup {
+		t.Fatalf("groups = %+v, want a single %q group", file.Groups, alertRuleGroup)
+	}
+
+	rules := make(map[string]map[string]interface{})
+	for _, rule := range file.Groups[0].Rules {
+		name, _ := rule["alert"].(string)
+		if _, dup := rules[name]; dup {
+			t.Errorf("duplicate alert %q", name)
+		}
+		rules[name] = rule
+	}
+	return rules
+}
+
+func TestGenerateAlertRules(t *testing.T) {
+	data, err := GenerateAlertRules(common.AlertThresholds{
+		LatencyWarningMs:      100,
+		LatencyErrorMs:        500,
+		PacketLossWarningPct:  1.5,
+		PacketLossErrorPct:    5,
+		SignalStrengthWarning: 60,
+		SignalStrengthError:   30,
+		JitterWarningMs:       20,
+		JitterErrorMs:         50,
+	})
+	if err != nil {
+		t.Fatalf("GenerateAlertRules() error = %v", err)
+	}
+
+	rules := parsedRules(t, data)
+
+	want := map[string]struct {
+		severity string
+		expr     string
+	}{
+		"OSITestLatencyWarning":     {"warning", "histogram_quantile(0.95, sum by (le) (rate(osi_test_duration_seconds_bucket[5m]))) > 0.1"},
+		"OSITestLatencyCritical":    {"critical", "histogram_quantile(0.95, sum by (le) (rate(osi_test_duration_seconds_bucket[5m]))) > 0.5"},
+		"OSIPacketLossWarning":      {"warning", "max by (layer) (osi_layer_packet_loss_pct) > 1.5"},
+		"OSIPacketLossCritical":     {"critical", "max by (layer) (osi_layer_packet_loss_pct) > 5"},
+		"OSISignalStrengthWarning":  {"warning", "min by (test) (osi_signal_strength_pct) < 60"},
+		"OSISignalStrengthCritical": {"critical", "min by (test) (osi_signal_strength_pct) < 30"},
+		"OSIJitterWarning":          {"warning", "max by (layer) (osi_layer_jitter_seconds) > 0.02"},
+		"OSIJitterCritical":         {"critical", "max by (layer) (osi_layer_jitter_seconds) > 0.05"},
+	}
+	if len(rules) != len(want) {
+		t.Errorf("got %d rules, want %d", len(rules), len(want))
+	}
+
+	for name, w := range want {
+		rule, ok := rules[name]
+		if !ok {
+			t.Errorf("missing alert %q", name)
+			continue
+		}
+		if rule["expr"] != w.expr {
+			t.Errorf("%s expr = %q, want %q", name, rule["expr"], w.expr)
+		}
+		if rule["for"] != "5m" {
+			t.Errorf("%s for = %v, want 5m", name, rule["for"])
+		}
+		labels, _ := rule["labels"].(map[string]interface{})
+		if labels["severity"] != w.severity {
+			t.Errorf("%s severity = %v, want %s", name, labels["severity"], w.severity)
+		}
+		annotations, _ := rule["annotations"].(map[string]interface{})
+		if summary, _ := annotations["summary"].(string); summary == "" {
+			t.Errorf("%s has no summary annotation", name)
+		}
+	}
+
+	// Prometheus expands label templates, so they must survive YAML quoting
+	summary, _ := rules["OSIJitterWarning"]["annotations"].(map[string]interface{})["summary"].(string)
+	if !strings.Contains(summary, "{{ $labels.layer }}") {
+		t.Errorf("jitter summary %q lost its label template", summary)
+	}
+}
+
+func TestGenerateAlertRulesSkipsUnsetThresholds(t *testing.T) {
+	data, err := GenerateAlertRules(common.AlertThresholds{LatencyErrorMs: 250})
+	if err != nil {
+		t.Fatalf("GenerateAlertRules() error = %v", err)
+	}
+
+	rules := parsedRules(t, data)
+	if len(rules) != 1 {
+		t.Fatalf("got %d rules, want only the latency critical rule", len(rules))
+	}
+	if _, ok := rules["OSITestLatencyCritical"]; !ok {
+		t.Errorf("rules %v do not include OSITestLatencyCritical", rules)
+	}
+}
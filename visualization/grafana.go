@@ -0,0 +1,240 @@
+package visualization
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// grafanaDashboardUID is the stable UID of the exported dashboard, so
+// re-importing it replaces the previous copy
+const grafanaDashboardUID = "osi-layer-tests"
+
+// grafanaDashboard is a Grafana dashboard JSON model
+type grafanaDashboard struct {
+	UID           string            `json:"uid"`
+	Title         string            `json:"title"`
+	Tags          []string          `json:"tags"`
+	Timezone      string            `json:"timezone"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Version       int               `json:"version"`
+	Editable      bool              `json:"editable"`
+	Refresh       string            `json:"refresh"`
+	Time          grafanaTimeRange  `json:"time"`
+	Templating    grafanaTemplating `json:"templating"`
+	Panels        []grafanaPanel    `json:"panels"`
+}
+
+type grafanaTimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaTemplating struct {
+	List []grafanaVariable `json:"list"`
+}
+
+type grafanaVariable struct {
+	Name    string                 `json:"name"`
+	Label   string                 `json:"label"`
+	Type    string                 `json:"type"`
+	Query   string                 `json:"query"`
+	Current grafanaVariableCurrent `json:"current"`
+	Hide    int                    `json:"hide"`
+}
+
+type grafanaVariableCurrent struct {
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+type grafanaDatasourceRef struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type grafanaGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type grafanaTarget struct {
+	RefID        string               `json:"refId"`
+	Datasource   grafanaDatasourceRef `json:"datasource"`
+	Expr         string               `json:"expr"`
+	LegendFormat string               `json:"legendFormat,omitempty"`
+	Format       string               `json:"format,omitempty"`
+}
+
+type grafanaPanel struct {
+	ID          int                    `json:"id"`
+	Type        string                 `json:"type"`
+	Title       string                 `json:"title"`
+	Description string                 `json:"description,omitempty"`
+	GridPos     grafanaGridPos         `json:"gridPos"`
+	Datasource  grafanaDatasourceRef   `json:"datasource"`
+	Targets     []grafanaTarget        `json:"targets"`
+	FieldConfig map[string]interface{} `json:"fieldConfig"`
+	Options     map[string]interface{} `json:"options"`
+}
+
+// GenerateGrafanaDashboard returns a Grafana dashboard that charts the
+// metrics the Visualizer exports. The dashboard has a datasource variable
+// defaulting to datasource, so it can be imported as is.
+func GenerateGrafanaDashboard(datasource string) ([]byte, error) {
+	if datasource == "" {
+		datasource = "Prometheus"
+	}
+
+	ds := grafanaDatasourceRef{Type: "prometheus", UID: "${datasource}"}
+	target := func(refID, expr, legend string) grafanaTarget {
+		return grafanaTarget{RefID: refID, Datasource: ds, Expr: expr, LegendFormat: legend}
+	}
+	unit := func(unit string) map[string]interface{} {
+		return map[string]interface{}{
+			"defaults":  map[string]interface{}{"unit": unit},
+			"overrides": []interface{}{},
+		}
+	}
+
+	panels := []grafanaPanel{
+		{
+			Type:        "piechart",
+			Title:       "Pass/Fail Ratio",
+			Description: "Passed and failed OSI layer tests in the selected time range",
+			GridPos:     grafanaGridPos{H: 8, W: 8, X: 0, Y: 0},
+			Targets: []grafanaTarget{
+				target("A", "sum(increase(osi_tests_passed_total[$__range]))", "Passed"),
+				target("B", "sum(increase(osi_tests_failed_total[$__range]))", "Failed"),
+			},
+			FieldConfig: unit("short"),
+			Options: map[string]interface{}{
+				"pieType":       "pie",
+				"reduceOptions": map[string]interface{}{"calcs": []string{"lastNotNull"}, "fields": "", "values": false},
+				"legend":        map[string]interface{}{"displayMode": "list", "placement": "right", "values": []string{"percent"}},
+			},
+		},
+		{
+			Type:        "gauge",
+			Title:       "Layer Status",
+			Description: "Status of each OSI layer's last run (0=failed, 1=passed)",
+			GridPos:     grafanaGridPos{H: 8, W: 16, X: 8, Y: 0},
+			Targets: []grafanaTarget{
+				target("A", "osi_layer_status", "{{layer}}"),
+			},
+			FieldConfig: map[string]interface{}{
+				"defaults": map[string]interface{}{
+					"min": 0,
+					"max": 1,
+					"thresholds": map[string]interface{}{
+						"mode": "absolute",
+						"steps": []map[string]interface{}{
+							{"color": "red", "value": nil},
+							{"color": "green", "value": 1},
+						},
+					},
+					"mappings": []map[string]interface{}{
+						{
+							"type": "value",
+							"options": map[string]interface{}{
+								"0": map[string]interface{}{"text": "Failed"},
+								"1": map[string]interface{}{"text": "Passed"},
+							},
+						},
+					},
+				},
+				"overrides": []interface{}{},
+			},
+			Options: map[string]interface{}{
+				"reduceOptions":        map[string]interface{}{"calcs": []string{"lastNotNull"}, "fields": "", "values": false},
+				"showThresholdLabels":  false,
+				"showThresholdMarkers": true,
+			},
+		},
+		{
+			Type:        "heatmap",
+			Title:       "Test Duration Histogram",
+			Description: "Distribution of OSI layer test durations",
+			GridPos:     grafanaGridPos{H: 8, W: 12, X: 0, Y: 8},
+			Targets: []grafanaTarget{
+				{
+					RefID:        "A",
+					Datasource:   ds,
+					Expr:         "sum by (le) (increase(osi_test_duration_seconds_bucket[$__rate_interval]))",
+					LegendFormat: "{{le}}",
+					Format:       "heatmap",
+				},
+			},
+			FieldConfig: unit("s"),
+			Options: map[string]interface{}{
+				"calculate": false,
+				"yAxis":     map[string]interface{}{"unit": "s"},
+				"color":     map[string]interface{}{"mode": "scheme", "scheme": "Oranges"},
+			},
+		},
+		{
+			Type:        "timeseries",
+			Title:       "Latency",
+			Description: "Average and 95th percentile OSI layer test duration",
+			GridPos:     grafanaGridPos{H: 8, W: 12, X: 12, Y: 8},
+			Targets: []grafanaTarget{
+				target("A", "histogram_quantile(0.95, sum by (le) (rate(osi_test_duration_seconds_bucket[$__rate_interval])))", "p95"),
+				target("B", "rate(osi_test_duration_seconds_sum[$__rate_interval]) / rate(osi_test_duration_seconds_count[$__rate_interval])", "average"),
+			},
+			FieldConfig: unit("s"),
+			Options: map[string]interface{}{
+				"legend":  map[string]interface{}{"displayMode": "list", "placement": "bottom"},
+				"tooltip": map[string]interface{}{"mode": "multi"},
+			},
+		},
+		{
+			Type:        "timeseries",
+			Title:       "Packet Loss",
+			Description: "Highest packet loss reported by each OSI layer's last run",
+			GridPos:     grafanaGridPos{H: 8, W: 24, X: 0, Y: 16},
+			Targets: []grafanaTarget{
+				target("A", "osi_layer_packet_loss_pct", "{{layer}}"),
+			},
+			FieldConfig: unit("percent"),
+			Options: map[string]interface{}{
+				"legend":  map[string]interface{}{"displayMode": "list", "placement": "bottom"},
+				"tooltip": map[string]interface{}{"mode": "multi"},
+			},
+		},
+	}
+	for i := range panels {
+		panels[i].ID = i + 1
+		panels[i].Datasource = ds
+	}
+
+	dashboard := grafanaDashboard{
+		UID:           grafanaDashboardUID,
+		Title:         "OSI Layer Tests",
+		Tags:          []string{"osi", "network"},
+		Timezone:      "browser",
+		SchemaVersion: 39,
+		Version:       1,
+		Editable:      true,
+		Refresh:       "30s",
+		Time:          grafanaTimeRange{From: "now-6h", To: "now"},
+		Templating: grafanaTemplating{
+			List: []grafanaVariable{
+				{
+					Name:    "datasource",
+					Label:   "Datasource",
+					Type:    "datasource",
+					Query:   "prometheus",
+					Current: grafanaVariableCurrent{Text: datasource, Value: datasource},
+				},
+			},
+		},
+		Panels: panels,
+	}
+
+	data, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Grafana dashboard: %w", err)
+	}
+	return data, nil
+}
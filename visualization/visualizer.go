@@ -3,29 +3,80 @@ package visualization
 import (
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 
+	"ghostshell/app/layers/analysis"
 	"ghostshell/app/layers/common"
+	"ghostshell/app/layers/history"
 )
 
 //go:embed templates/*
 var templateFS embed.FS
 
+// historyPath is where the compare page lists stored runs, and with
+// "/compare" appended, compares two of them
+const historyPath = "/api/history"
+
+// defaultHistoryLimit is how many stored runs are listed when no limit is given
+const defaultHistoryLimit = 100
+
+// Stream buffer sizes
+const (
+	broadcastBufferSize = 256
+	clientBufferSize    = 64
+)
+
+// streamUpgrader upgrades dashboard connections to WebSocket
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
 // Visualizer manages the web-based visualization of test results
 type Visualizer struct {
-	logger     *zap.Logger
-	results    []common.TestResult
-	mu         sync.RWMutex
-	httpServer *http.Server
-	metrics    *metrics
+	logger      *zap.Logger
+	results     []common.TestResult
+	lastRun     time.Time // When UpdateResults was last called
+	scheduleURL string    // API schedule endpoint the dashboard polls for the next run
+
+	// Stored runs, analysed for regressions and compared on the compare page;
+	// nil unless EnableAnalysis was called
+	history        history.Store
+	analysisWindow int
+	thresholds     common.AlertThresholds
+	mu             sync.RWMutex
+	httpServer     *http.Server
+	metrics        *metrics
+
+	// Live result streaming
+	clients   map[string]chan common.TestResult
+	clientsMu sync.RWMutex
+	broadcast chan common.TestResult
+	done      chan struct{}
+	stopOnce  sync.Once
+	nextID    atomic.Uint64
+	streaming atomic.Bool // Results are streamed as each layer completes
+
+	// Request tracing, nil unless EnableTracing was called
+	tracer trace.Tracer
+
+	// Extra handlers served alongside the dashboard, added with Handle
+	handlers map[string]http.Handler
 }
 
 // metrics holds Prometheus metrics for test results
@@ -34,6 +85,9 @@ type metrics struct {
 	testsFailed prometheus.Counter
 	testLatency prometheus.Histogram
 	layerStatus *prometheus.GaugeVec
+	packetLoss  *prometheus.GaugeVec
+	jitter      *prometheus.GaugeVec
+	signal      *prometheus.GaugeVec
 }
 
 // NewVisualizer creates a new web-based visualizer
@@ -57,6 +111,18 @@ func NewVisualizer(logger *zap.Logger) (*Visualizer, error) {
 			Name: "osi_layer_status",
 			Help: "Status of each OSI layer (0=failed, 1=passed)",
 		}, []string{"layer"}),
+		packetLoss: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "osi_layer_packet_loss_pct",
+			Help: "Highest packet loss percentage reported by each OSI layer's last run",
+		}, []string{"layer"}),
+		jitter: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "osi_layer_jitter_seconds",
+			Help: "Highest jitter reported by each OSI layer's last run",
+		}, []string{"layer"}),
+		signal: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "osi_signal_strength_pct",
+			Help: "Wireless signal strength reported by each Layer 1 signal test",
+		}, []string{"test"}),
 	}
 
 	// Register metrics
@@ -64,11 +130,20 @@ func NewVisualizer(logger *zap.Logger) (*Visualizer, error) {
 	prometheus.MustRegister(m.testsFailed)
 	prometheus.MustRegister(m.testLatency)
 	prometheus.MustRegister(m.layerStatus)
+	prometheus.MustRegister(m.packetLoss)
+	prometheus.MustRegister(m.jitter)
+	prometheus.MustRegister(m.signal)
 
-	return &Visualizer{
-		logger:  logger,
-		metrics: m,
-	}, nil
+	v := &Visualizer{
+		logger:    logger,
+		metrics:   m,
+		clients:   make(map[string]chan common.TestResult),
+		broadcast: make(chan common.TestResult, broadcastBufferSize),
+		done:      make(chan struct{}),
+	}
+	go v.fanOut()
+
+	return v, nil
 }
 
 // Start initializes and starts the web server
@@ -79,12 +154,24 @@ func (v *Visualizer) Start(addr string) error {
 	// Register handlers
 	mux.Handle("/metrics", promhttp.Handler())
 	mux.HandleFunc("/", v.handleDashboard)
+	mux.HandleFunc("/compare", v.handleCompare)
+	mux.HandleFunc(historyPath, v.handleHistory)
+	mux.HandleFunc(historyPath+"/compare", v.handleHistoryCompare)
 	mux.HandleFunc("/api/results", v.handleResults)
+	mux.HandleFunc("/api/v1/stream", v.handleStream)
+	for pattern, handler := range v.handlers {
+		mux.Handle(pattern, handler)
+	}
+
+	var handler http.Handler = mux
+	if v.tracer != nil {
+		handler = v.traceRequests(mux)
+	}
 
 	// Create server
 	v.httpServer = &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: handler,
 	}
 
 	// Start server
@@ -92,26 +179,76 @@ func (v *Visualizer) Start(addr string) error {
 	return v.httpServer.ListenAndServe()
 }
 
+// Handle serves handler for pattern on the dashboard's address, so pages can
+// reach it without cross-origin requests. It must be called before Start.
+func (v *Visualizer) Handle(pattern string, handler http.Handler) {
+	if v.handlers == nil {
+		v.handlers = make(map[string]http.Handler)
+	}
+	v.handlers[pattern] = handler
+}
+
+// EnableTracing traces dashboard requests with tp, continuing any trace
+// context sent in the request headers. It must be called before Start.
+func (v *Visualizer) EnableTracing(tp trace.TracerProvider) {
+	v.tracer = tp.Tracer("ghostshell/app/layers/visualization")
+}
+
+// traceRequests wraps next in a server span parented on the incoming trace context
+func (v *Visualizer) traceRequests(next http.Handler) http.Handler {
+	propagator := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := v.tracer.Start(ctx, "visualizer "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 // Stop gracefully shuts down the server
 func (v *Visualizer) Stop() error {
+	v.stopOnce.Do(func() {
+		close(v.done)
+
+		// Closing the client channels lets each stream flush what is buffered and exit
+		v.clientsMu.Lock()
+		for id, ch := range v.clients {
+			close(ch)
+			delete(v.clients, id)
+		}
+		v.clientsMu.Unlock()
+	})
+
 	if v.httpServer != nil {
 		return v.httpServer.Close()
 	}
 	return nil
 }
 
-// UpdateResults updates the test results and metrics
+// UpdateResults merges results into those served by the dashboard and
+// updates the metrics. A result replaces the stored one of the same layer and
+// name, so runs of a subset of layers, such as scheduled runs, keep the
+// latest results of the other layers.
 func (v *Visualizer) UpdateResults(results []common.TestResult) {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
-	v.results = results
+	for _, result := range results {
+		v.results = mergeResult(v.results, result)
+	}
+	v.lastRun = time.Now()
 
 	// Update metrics
 	passed := 0
 	failed := 0
 	for _, result := range results {
-		if result.Status == "Passed" {
+		if result.Status == common.StatusPassed {
 			passed++
 			v.metrics.layerStatus.WithLabelValues(fmt.Sprintf("layer%d", result.Layer)).Set(1)
 		} else {
@@ -122,7 +259,135 @@ func (v *Visualizer) UpdateResults(results []common.TestResult) {
 
 	v.metrics.testsPassed.Add(float64(passed))
 	v.metrics.testsFailed.Add(float64(failed))
-	v.metrics.testLatency.Observe(time.Since(time.Now()).Seconds())
+	v.recordNetworkMetrics(results)
+
+	// Push each result to the streaming clients
+	for _, result := range results {
+		select {
+		case v.broadcast <- result:
+		case <-v.done:
+			return
+		}
+	}
+}
+
+// EnableAnalysis has the dashboard analyse the last window runs in store
+// and show an Analysis tab listing any regressions. The compare page lists
+// and compares the runs in store too.
+func (v *Visualizer) EnableAnalysis(store history.Store, window int, thresholds common.AlertThresholds) {
+	v.mu.Lock()
+	v.history = store
+	v.analysisWindow = window
+	v.thresholds = thresholds
+	v.mu.Unlock()
+}
+
+// SetScheduleURL sets the REST API's /api/v1/schedule URL, which the
+// dashboard polls to show when the next scheduled run starts. The API must be
+// reachable from the browser; an empty URL hides the countdown.
+func (v *Visualizer) SetScheduleURL(url string) {
+	v.mu.Lock()
+	v.scheduleURL = url
+	v.mu.Unlock()
+}
+
+// EnableStreaming sets whether ResultStreamCallback returns StreamResult, so
+// results reach the dashboard as each layer completes instead of only when
+// UpdateResults is called at the end of the run
+func (v *Visualizer) EnableStreaming(enabled bool) {
+	v.streaming.Store(enabled)
+}
+
+// ResultStreamCallback returns the callback to pass to a test session's
+// SetResultStreamCallback, or nil when streaming is disabled
+func (v *Visualizer) ResultStreamCallback() func(common.TestResult) {
+	if !v.streaming.Load() {
+		return nil
+	}
+	return v.StreamResult
+}
+
+// StreamResult immediately pushes a single result to the streaming clients
+// and merges it into the results served by the dashboard. UpdateResults
+// should still be called with the full set once the run finishes; the
+// dashboard replaces streamed results with their final copies rather than
+// duplicating them.
+func (v *Visualizer) StreamResult(result common.TestResult) {
+	v.mu.Lock()
+	v.results = mergeResult(v.results, result)
+	v.lastRun = time.Now()
+	v.mu.Unlock()
+
+	select {
+	case v.broadcast <- result:
+	case <-v.done:
+	}
+}
+
+// mergeResult replaces the result in results with the layer and name of
+// result, or appends result when there is none
+func mergeResult(results []common.TestResult, result common.TestResult) []common.TestResult {
+	for i := range results {
+		if results[i].Layer == result.Layer && results[i].Name == result.Name {
+			results[i] = result
+			return results
+		}
+	}
+	return append(results, result)
+}
+
+// recordNetworkMetrics updates the gauges and histogram that the generated
+// alert rules are evaluated against
+func (v *Visualizer) recordNetworkMetrics(results []common.TestResult) {
+	packetLoss := make(map[int]float64)
+	jitter := make(map[int]time.Duration)
+
+	var record func(result common.TestResult)
+	record = func(result common.TestResult) {
+		v.metrics.testLatency.Observe(result.Metrics.Duration.Seconds())
+		if result.Metrics.PacketLoss > packetLoss[result.Layer] {
+			packetLoss[result.Layer] = result.Metrics.PacketLoss
+		}
+		if result.Metrics.Jitter > jitter[result.Layer] {
+			jitter[result.Layer] = result.Metrics.Jitter
+		}
+		switch strength := result.Metrics.Custom["signal_strength"].(type) {
+		case int:
+			v.metrics.signal.WithLabelValues(result.Name).Set(float64(strength))
+		case float64: // Results loaded from JSON
+			v.metrics.signal.WithLabelValues(result.Name).Set(strength)
+		}
+		for _, sub := range result.SubResults {
+			record(sub)
+		}
+	}
+	for _, result := range results {
+		record(result)
+		layer := fmt.Sprintf("layer%d", result.Layer)
+		v.metrics.packetLoss.WithLabelValues(layer).Set(packetLoss[result.Layer])
+		v.metrics.jitter.WithLabelValues(layer).Set(jitter[result.Layer].Seconds())
+	}
+}
+
+// fanOut copies broadcast results to every connected stream client. Slow
+// clients whose buffer is full miss the result rather than blocking others.
+func (v *Visualizer) fanOut() {
+	for {
+		select {
+		case <-v.done:
+			return
+		case result := <-v.broadcast:
+			v.clientsMu.RLock()
+			for id, ch := range v.clients {
+				select {
+				case ch <- result:
+				default:
+					v.logger.Warn("Dropping result for slow stream client", zap.String("client", id))
+				}
+			}
+			v.clientsMu.RUnlock()
+		}
+	}
 }
 
 // handleDashboard serves the main dashboard page
@@ -135,20 +400,157 @@ func (v *Visualizer) handleDashboard(w http.ResponseWriter, r *http.Request) {
 
 	v.mu.RLock()
 	data := struct {
-		Results []common.TestResult
-		Time    time.Time
+		Results     []common.TestResult
+		Time        time.Time
+		LastRun     time.Time
+		ScheduleURL string
+		Regressions []analysis.Regression
 	}{
-		Results: v.results,
-		Time:    time.Now(),
+		Results:     v.results,
+		Time:        time.Now(),
+		LastRun:     v.lastRun,
+		ScheduleURL: v.scheduleURL,
 	}
+	store, window, thresholds := v.history, v.analysisWindow, v.thresholds
 	v.mu.RUnlock()
 
+	if store != nil {
+		regressions, err := analysis.DetectRegressions(store, window, thresholds)
+		if err != nil {
+			v.logger.Warn("Regression analysis failed", zap.Error(err))
+		}
+		data.Regressions = regressions
+	}
+
 	if err := tmpl.Execute(w, data); err != nil {
 		http.Error(w, "Failed to render template", http.StatusInternalServerError)
 		return
 	}
 }
 
+// handleCompare serves the page comparing two stored runs
+func (v *Visualizer) handleCompare(w http.ResponseWriter, r *http.Request) {
+	tmpl, err := template.ParseFS(templateFS, "templates/compare.html")
+	if err != nil {
+		http.Error(w, "Failed to load template", http.StatusInternalServerError)
+		return
+	}
+
+	v.mu.RLock()
+	data := struct {
+		Time       time.Time
+		HistoryURL string
+	}{
+		Time: time.Now(),
+	}
+	if v.history != nil {
+		data.HistoryURL = historyPath
+	}
+	v.mu.RUnlock()
+
+	if err := tmpl.Execute(w, data); err != nil {
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		return
+	}
+}
+
+// historyStore returns the store of stored runs, writing a 503 when there is none
+func (v *Visualizer) historyStore(w http.ResponseWriter) history.Store {
+	v.mu.RLock()
+	store := v.history
+	v.mu.RUnlock()
+	if store == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "history is not enabled")
+	}
+	return store
+}
+
+// handleHistory lists the stored runs for the compare page, newest first
+// unless order is asc
+func (v *Visualizer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	store := v.historyStore(w)
+	if store == nil {
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	order := r.URL.Query().Get("order")
+	if order == "" {
+		order = history.OrderDesc
+	}
+	if order != history.OrderAsc && order != history.OrderDesc {
+		writeJSONError(w, http.StatusBadRequest, "invalid order: must be asc or desc")
+		return
+	}
+
+	runs, err := store.List(limit, 0, order)
+	if err != nil {
+		v.logger.Warn("Failed to list stored runs", zap.Error(err))
+		writeJSONError(w, http.StatusInternalServerError, "failed to list stored runs")
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Items []history.RunSummary `json:"items"`
+	}{Items: runs})
+}
+
+// handleHistoryCompare diffs the two stored runs named in the request body
+func (v *Visualizer) handleHistoryCompare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	store := v.historyStore(w)
+	if store == nil {
+		return
+	}
+
+	var req struct {
+		BaseID    string `json:"base_id"`
+		CompareID string `json:"compare_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	runs := make([][]common.TestResult, 2)
+	for i, id := range []string{req.BaseID, req.CompareID} {
+		results, err := store.Get(id)
+		if errors.Is(err, history.ErrNotFound) {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("run %s not found", id))
+			return
+		}
+		if err != nil {
+			v.logger.Warn("Failed to load stored run", zap.String("run_id", id), zap.Error(err))
+			writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("failed to load run %s", id))
+			return
+		}
+		runs[i] = results
+	}
+
+	writeJSON(w, http.StatusOK, common.NewDiffReportGenerator(runs[0], runs[1]).Diff())
+}
+
+// writeJSON writes body as a JSON response with status
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeJSONError writes an {"error": msg} response with status
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
 // handleResults serves the test results as JSON
 func (v *Visualizer) handleResults(w http.ResponseWriter, r *http.Request) {
 	v.mu.RLock()
@@ -160,3 +562,67 @@ func (v *Visualizer) handleResults(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// handleStream upgrades the connection to a WebSocket and pushes each result
+// passed to UpdateResults as a JSON frame
+func (v *Visualizer) handleStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		v.logger.Error("Failed to upgrade stream connection", zap.Error(err))
+		return
+	}
+	defer conn.Close()
+
+	id := fmt.Sprintf("%s-%d", r.RemoteAddr, v.nextID.Add(1))
+	ch := make(chan common.TestResult, clientBufferSize)
+
+	v.clientsMu.Lock()
+	select {
+	case <-v.done:
+		v.clientsMu.Unlock()
+		return
+	default:
+	}
+	v.clients[id] = ch
+	v.clientsMu.Unlock()
+
+	v.logger.Info("Stream client connected", zap.String("client", id))
+
+	// The client never sends data; reading detects when it goes away
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	defer func() {
+		v.clientsMu.Lock()
+		if _, ok := v.clients[id]; ok {
+			close(ch)
+			delete(v.clients, id)
+		}
+		v.clientsMu.Unlock()
+		v.logger.Info("Stream client disconnected", zap.String("client", id))
+	}()
+
+	for {
+		select {
+		case <-gone:
+			return
+		case result, ok := <-ch:
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"))
+				return
+			}
+			if err := conn.WriteJSON(result); err != nil {
+				v.logger.Warn("Failed to write to stream client", zap.String("client", id), zap.Error(err))
+				return
+			}
+		}
+	}
+}
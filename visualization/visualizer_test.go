@@ -0,0 +1,121 @@
+package visualization
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"ghostshell/app/layers/common"
+	"ghostshell/app/layers/history"
+)
+
+// newTestVisualizer returns a visualizer for handler tests. NewVisualizer
+// registers global Prometheus metrics, so it can only be called once per
+// process.
+func newTestVisualizer() *Visualizer {
+	return &Visualizer{logger: zap.NewNop()}
+}
+
+func TestComparePage(t *testing.T) {
+	v := newTestVisualizer()
+
+	rec := httptest.NewRecorder()
+	v.handleCompare(rec, httptest.NewRequest(http.MethodGet, "/compare", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /compare returned %d, want 200", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "history is not enabled") {
+		t.Error("compare page without history does not say comparison is unavailable")
+	}
+
+	v.EnableAnalysis(history.NewFileStore(t.TempDir()), 10, common.AlertThresholds{})
+	rec = httptest.NewRecorder()
+	v.handleCompare(rec, httptest.NewRequest(http.MethodGet, "/compare", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /compare returned %d, want 200", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `data-url="`+historyPath+`"`) {
+		t.Error("compare page does not list runs from the visualizer's history endpoint")
+	}
+}
+
+func TestHistoryCompare(t *testing.T) {
+	store := history.NewFileStore(t.TempDir())
+	if err := store.Save("base", []common.TestResult{{Layer: 3, Name: "Ping Test", Status: common.StatusPassed}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Save("compare", []common.TestResult{{Layer: 3, Name: "Ping Test", Status: common.StatusFailed}}); err != nil {
+		t.Fatal(err)
+	}
+
+	v := newTestVisualizer()
+	rec := httptest.NewRecorder()
+	v.handleHistory(rec, httptest.NewRequest(http.MethodGet, historyPath, nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("history without a store returned %d, want 503", rec.Code)
+	}
+
+	v.EnableAnalysis(store, 10, common.AlertThresholds{})
+	rec = httptest.NewRecorder()
+	v.handleHistory(rec, httptest.NewRequest(http.MethodGet, historyPath+"?limit=100&order=desc", nil))
+	var page struct {
+		Items []history.RunSummary `json:"items"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil || rec.Code != http.StatusOK || len(page.Items) != 2 {
+		t.Fatalf("history returned %d with %d runs (%v), want 200 with 2", rec.Code, len(page.Items), err)
+	}
+
+	body, _ := json.Marshal(map[string]string{"base_id": "base", "compare_id": "compare"})
+	rec = httptest.NewRecorder()
+	v.handleHistoryCompare(rec, httptest.NewRequest(http.MethodPost, historyPath+"/compare", bytes.NewReader(body)))
+	var report common.DiffReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("compare returned %d (%v), want 200", rec.Code, err)
+	}
+	if len(report.StatusChanges) != 1 || report.StatusChanges[0].CompareStatus != common.StatusFailed {
+		t.Errorf("status changes %+v, want Ping Test Passed -> Failed", report.StatusChanges)
+	}
+
+	body, _ = json.Marshal(map[string]string{"base_id": "base", "compare_id": "missing"})
+	rec = httptest.NewRecorder()
+	v.handleHistoryCompare(rec, httptest.NewRequest(http.MethodPost, historyPath+"/compare", bytes.NewReader(body)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("comparing a missing run returned %d, want 404", rec.Code)
+	}
+}
+
+func TestMergeResult(t *testing.T) {
+	var results []common.TestResult
+	for _, result := range []common.TestResult{
+		{Layer: 1, Name: "Physical", Status: common.StatusPassed},
+		{Layer: 3, Name: "Network", Status: common.StatusPassed},
+		// A later run of layer 3 alone replaces only its result
+		{Layer: 3, Name: "Network", Status: common.StatusFailed},
+		{Layer: 3, Name: "Traceroute", Status: common.StatusWarning},
+	} {
+		results = mergeResult(results, result)
+	}
+
+	want := []struct {
+		layer  int
+		name   string
+		status common.TestStatus
+	}{
+		{1, "Physical", common.StatusPassed},
+		{3, "Network", common.StatusFailed},
+		{3, "Traceroute", common.StatusWarning},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i, w := range want {
+		if r := results[i]; r.Layer != w.layer || r.Name != w.name || r.Status != w.status {
+			t.Errorf("result %d is layer %d %q %s, want layer %d %q %s", i, r.Layer, r.Name, r.Status, w.layer, w.name, w.status)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package layers
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+)
+
+// configReloadDebounce is how long the config file must be quiet before it is
+// reloaded, so an editor's several writes cause one reload
+const configReloadDebounce = 500 * time.Millisecond
+
+// WatchConfig reloads the config file at path whenever it is written or
+// recreated and calls onChange with the new configuration. A file that fails
+// to load or validate is logged and ignored. The returned function stops
+// watching.
+func WatchConfig(path string, onChange func(*Config)) (func(), error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config path: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config watcher: %w", err)
+	}
+
+	// Watch the directory, as editors often replace the file rather than
+	// writing to it, which would drop a watch on the file itself
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch config directory: %w", err)
+	}
+
+	logger := zap.L()
+	reload := func() {
+		config, err := LoadConfig(path)
+		if err != nil {
+			logger.Error("Config reload failed, keeping current config",
+				zap.String("path", path),
+				zap.Error(err),
+			)
+			return
+		}
+		logger.Info("Config reloaded", zap.String("path", path))
+		onChange(config)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		var timer *time.Timer
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != path || !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+					continue
+				}
+				if timer == nil {
+					timer = time.AfterFunc(configReloadDebounce, reload)
+				} else {
+					timer.Reset(configReloadDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("Config watcher error", zap.Error(err))
+			}
+		}
+	}()
+
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() {
+			close(done)
+			watcher.Close()
+		})
+	}
+	return stop, nil
+}